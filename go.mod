@@ -4,6 +4,7 @@ go 1.25.3
 
 require (
 	github.com/mark3labs/mcp-go v0.43.2
+	golang.org/x/text v0.30.0
 	modernc.org/sqlite v1.44.3
 )
 
@@ -0,0 +1,294 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of export_account.
+const (
+	AccountExportQIF = "qif"
+	AccountExportOFX = "ofx"
+)
+
+// registerAccountTypes are the account types export_account writes: those
+// with a register a bank, card or accountant's tool can read.
+var registerAccountTypes = []string{"BANK", "CASH", "CREDIT", "ASSET", "LIABILITY", "RECEIVABLE", "PAYABLE"}
+
+// registerLine is a transaction as seen from one account: its amount in the
+// account's commodity and its counterparts.
+type registerLine struct {
+	GUID        string // FITID of the OFX statement
+	Date        time.Time
+	Description string
+	Memo        string
+	Amount      *big.Rat
+	Splits      []registerSplit
+}
+
+// registerSplit is a counterpart of a register line, its amount in the
+// commodity of the register's account, opposite to its value.
+type registerSplit struct {
+	Account *Account
+	Memo    string
+	Amount  *big.Rat
+}
+
+// registerLines converts the transactions of an account into register lines.
+// Counterpart values are converted to the account's commodity at the rate
+// of the transaction. Lines with a zero amount, as voided transactions
+// have, are left out and counted.
+func registerLines(acc *Account, txs []exportTransaction, accounts map[string]*Account) ([]registerLine, int) {
+	var lines []registerLine
+	skipped := 0
+	for _, tx := range txs {
+		line := registerLine{Date: tx.PostDate, Description: tx.Description, Amount: new(big.Rat)}
+		value := new(big.Rat)
+		var memos []string
+		for _, sp := range tx.Splits {
+			if sp.AccountGUID != acc.GUID {
+				continue
+			}
+			if line.GUID == "" {
+				line.GUID = sp.GUID
+			}
+			line.Amount.Add(line.Amount, big.NewRat(sp.QuantityNum, sp.QuantityDenom))
+			value.Add(value, big.NewRat(sp.ValueNum, sp.ValueDenom))
+			if sp.Memo != "" {
+				memos = append(memos, sp.Memo)
+			}
+		}
+		if line.Amount.Sign() == 0 {
+			skipped++
+			continue
+		}
+		line.Memo = strings.Join(memos, "; ")
+		rate := big.NewRat(1, 1)
+		if value.Sign() != 0 {
+			rate.Quo(line.Amount, value)
+		}
+		for _, sp := range tx.Splits {
+			if sp.AccountGUID == acc.GUID || sp.ValueNum == 0 {
+				continue
+			}
+			amount := new(big.Rat).Mul(big.NewRat(-sp.ValueNum, sp.ValueDenom), rate)
+			line.Splits = append(line.Splits, registerSplit{Account: accounts[sp.AccountGUID], Memo: sp.Memo, Amount: amount})
+		}
+		lines = append(lines, line)
+	}
+	return lines, skipped
+}
+
+// qifType returns the QIF register type of an account type.
+func qifType(accountType string) string {
+	switch accountType {
+	case "BANK":
+		return "Bank"
+	case "CASH":
+		return "Cash"
+	case "CREDIT":
+		return "CCard"
+	case "LIABILITY", "PAYABLE":
+		return "Oth L"
+	}
+	return "Oth A"
+}
+
+// qifCategoryOf writes an account as a QIF category: income and expense
+// accounts by path, others as a transfer in brackets.
+func qifCategoryOf(acc *Account) string {
+	if acc.AccountType == "INCOME" || acc.AccountType == "EXPENSE" {
+		return acc.FullName
+	}
+	return "[" + acc.FullName + "]"
+}
+
+// qifText keeps a QIF field on one line.
+func qifText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// writeQIF writes register lines as a QIF file, dates in dateFormat.
+func writeQIF(acc *Account, lines []registerLine, dateFormat string, digits int) string {
+	layout := dateLayout(dateFormat)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "!Account\nN%s\nT%s\n", acc.FullName, qifType(acc.AccountType))
+	if acc.Description != "" {
+		fmt.Fprintf(&sb, "D%s\n", qifText(acc.Description))
+	}
+	fmt.Fprintf(&sb, "^\n!Type:%s\n", qifType(acc.AccountType))
+	for _, line := range lines {
+		fmt.Fprintf(&sb, "D%s\nT%s\n", line.Date.Format(layout), line.Amount.FloatString(digits))
+		if line.Description != "" {
+			fmt.Fprintf(&sb, "P%s\n", qifText(line.Description))
+		}
+		if line.Memo != "" {
+			fmt.Fprintf(&sb, "M%s\n", qifText(line.Memo))
+		}
+		if len(line.Splits) == 1 {
+			fmt.Fprintf(&sb, "L%s\n", qifCategoryOf(line.Splits[0].Account))
+		} else {
+			for _, sp := range line.Splits {
+				fmt.Fprintf(&sb, "S%s\n", qifCategoryOf(sp.Account))
+				if sp.Memo != "" {
+					fmt.Fprintf(&sb, "E%s\n", qifText(sp.Memo))
+				}
+				fmt.Fprintf(&sb, "$%s\n", sp.Amount.FloatString(digits))
+			}
+		}
+		sb.WriteString("^\n")
+	}
+	return sb.String()
+}
+
+// ofxText escapes s for an OFX element, cut to limit characters as the
+// specification requires.
+func ofxText(s string, limit int) string {
+	s = qifText(s)
+	if r := []rune(s); len(r) > limit {
+		s = string(r[:limit])
+	}
+	return html.EscapeString(s)
+}
+
+// writeOFX writes register lines as an OFX 2 statement of the account, with
+// its balance at the end of the period. GnuCash does not know the bank's
+// identifiers, so the account is identified by the start of its GUID.
+func writeOFX(acc *Account, currency string, lines []registerLine, start, end time.Time, balance string, digits int) string {
+	var sb strings.Builder
+	now := time.Now().Format("20060102150405")
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	sb.WriteString(`<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	sb.WriteString("<OFX>\n<SIGNONMSGSRSV1><SONRS>\n<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	fmt.Fprintf(&sb, "<DTSERVER>%s</DTSERVER>\n<LANGUAGE>ENG</LANGUAGE>\n</SONRS></SIGNONMSGSRSV1>\n", now)
+
+	card := acc.AccountType == "CREDIT"
+	acctID := acc.GUID[:min(len(acc.GUID), 22)]
+	if card {
+		sb.WriteString("<CREDITCARDMSGSRSV1><CCSTMTTRNRS>\n<TRNUID>0</TRNUID>\n")
+		sb.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n<CCSTMTRS>\n")
+		fmt.Fprintf(&sb, "<CURDEF>%s</CURDEF>\n<CCACCTFROM><ACCTID>%s</ACCTID></CCACCTFROM>\n", currency, acctID)
+	} else {
+		accountType := "CHECKING"
+		if acc.AccountType == "LIABILITY" || acc.AccountType == "PAYABLE" {
+			accountType = "CREDITLINE"
+		}
+		sb.WriteString("<BANKMSGSRSV1><STMTTRNRS>\n<TRNUID>0</TRNUID>\n")
+		sb.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n<STMTRS>\n")
+		fmt.Fprintf(&sb, "<CURDEF>%s</CURDEF>\n<BANKACCTFROM><BANKID>0</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>%s</ACCTTYPE></BANKACCTFROM>\n",
+			currency, acctID, accountType)
+	}
+	fmt.Fprintf(&sb, "<BANKTRANLIST>\n<DTSTART>%s</DTSTART>\n<DTEND>%s</DTEND>\n", start.Format("20060102"), end.Format("20060102"))
+	for _, line := range lines {
+		kind := "CREDIT"
+		if line.Amount.Sign() < 0 {
+			kind = "DEBIT"
+		}
+		fmt.Fprintf(&sb, "<STMTTRN>\n<TRNTYPE>%s</TRNTYPE>\n<DTPOSTED>%s</DTPOSTED>\n<TRNAMT>%s</TRNAMT>\n<FITID>%s</FITID>\n",
+			kind, line.Date.Format("20060102"), line.Amount.FloatString(digits), line.GUID)
+		if line.Description != "" {
+			fmt.Fprintf(&sb, "<NAME>%s</NAME>\n", ofxText(line.Description, 32))
+		}
+		if line.Memo != "" {
+			fmt.Fprintf(&sb, "<MEMO>%s</MEMO>\n", ofxText(line.Memo, 255))
+		}
+		sb.WriteString("</STMTTRN>\n")
+	}
+	sb.WriteString("</BANKTRANLIST>\n")
+	fmt.Fprintf(&sb, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", balance, end.Format("20060102"))
+	if card {
+		sb.WriteString("</CCSTMTRS>\n</CCSTMTTRNRS></CREDITCARDMSGSRSV1>\n")
+	} else {
+		sb.WriteString("</STMTRS>\n</STMTTRNRS></BANKMSGSRSV1>\n")
+	}
+	sb.WriteString("</OFX>\n")
+	return sb.String()
+}
+
+// ExportAccount writes the register of a bank, cash, credit card, asset or
+// liability account between startDate and endDate (both optional) to a QIF
+// or OFX file in the export directory, to import it into another tool or
+// send it to an accountant. QIF files keep the counterpart accounts as
+// categories, with dates in dateFormat (MM/DD/YYYY by default, as Quicken
+// writes them); OFX files carry the balance at the end of the period.
+func (s *Service) ExportAccount(ctx context.Context, account, format, startDate, endDate, dateFormat string) (string, error) {
+	if err := s.checkExports(); err != nil {
+		return "", err
+	}
+	format = strings.ToLower(format)
+	if format != AccountExportQIF && format != AccountExportOFX {
+		return "", fmt.Errorf("unsupported format '%s' (expected %s or %s)", format, AccountExportQIF, AccountExportOFX)
+	}
+	if err := checkExportPeriod(startDate, endDate); err != nil {
+		return "", err
+	}
+	acc, err := s.resolveAccount(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(registerAccountTypes, acc.AccountType) {
+		return "", fmt.Errorf("%s is a %s account; only bank, cash, credit card, asset and liability accounts can be exported", acc.FullName, acc.AccountType)
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	txs, err := s.db.getExportTransactions(ctx, startDate, endDate, []string{acc.GUID})
+	if err != nil {
+		return "", err
+	}
+	txs = bookTransactions(txs, accounts)
+	currency, err := s.transactionCurrency(ctx, []*Account{acc})
+	if err != nil {
+		return "", err
+	}
+	digits := fractionDigits(currency.Fraction)
+	lines, skipped := registerLines(acc, txs, accounts)
+
+	var content string
+	switch format {
+	case AccountExportQIF:
+		if dateFormat == "" {
+			dateFormat = "MM/DD/YYYY"
+		}
+		content = writeQIF(acc, lines, dateFormat, digits)
+	case AccountExportOFX:
+		end := s.now()
+		if endDate != "" {
+			end, _ = time.Parse("2006-01-02", endDate)
+		} else if len(txs) > 0 {
+			end = txs[len(txs)-1].PostDate
+		}
+		start := end
+		if startDate != "" {
+			start, _ = time.Parse("2006-01-02", startDate)
+		} else if len(txs) > 0 {
+			start = txs[0].PostDate
+		}
+		balances, err := s.db.getOpeningQuantities(ctx, end.AddDate(0, 0, 1).Format("2006-01-02"))
+		if err != nil {
+			return "", err
+		}
+		balance := strconv.FormatFloat(balances[acc.GUID], 'f', digits, 64)
+		content = writeOFX(acc, currency.Mnemonic, lines, start, end, balance, digits)
+	}
+
+	path, err := s.writeExport(exportName(acc.FullName), startDate, endDate, format, content)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s register of %s (%s) written to %s: %d transaction(s)", strings.ToUpper(format), acc.FullName,
+		periodLabel(startDate, endDate), path, len(lines))
+	if skipped > 0 {
+		fmt.Fprintf(&sb, ", %d with a zero amount (voided) left out", skipped)
+	}
+	sb.WriteString(".\n")
+	return sb.String(), nil
+}
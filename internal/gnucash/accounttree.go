@@ -0,0 +1,34 @@
+package gnucash
+
+import (
+	"context"
+	"sync"
+)
+
+// accountCache is the chart of accounts of the book as last read, with the
+// state of the book file it was read in.
+type accountCache struct {
+	mu       sync.Mutex
+	stamp    string // FileStamp of the book when read
+	accounts map[string]*Account
+}
+
+// accountTree returns the accounts of the book by GUID, with their full
+// names and children (see DB.GetAllAccounts). The tree is read once per
+// state of the book file (see DB.FileStamp) and shared between calls, so
+// callers must not modify it. Books not backed by a file (tests) are read
+// every time.
+func (s *Service) accountTree(ctx context.Context) (map[string]*Account, error) {
+	stamp := s.db.FileStamp()
+	s.tree.mu.Lock()
+	defer s.tree.mu.Unlock()
+	if stamp != "" && stamp == s.tree.stamp {
+		return s.tree.accounts, nil
+	}
+	accounts, err := s.db.GetAllAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.tree.stamp, s.tree.accounts = stamp, accounts
+	return accounts, nil
+}
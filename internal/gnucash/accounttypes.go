@@ -0,0 +1,96 @@
+package gnucash
+
+import (
+	"fmt"
+	"strings"
+)
+
+// accountTypes lists GnuCash's account types in the order they are documented.
+var accountTypes = []string{
+	"ASSET", "BANK", "CASH", "CREDIT", "LIABILITY", "STOCK", "MUTUAL",
+	"INCOME", "EXPENSE", "EQUITY", "RECEIVABLE", "PAYABLE", "TRADING",
+}
+
+// accountTypeAliases maps lowercase, unaccented synonyms and translations
+// (English, French, German, Spanish) to GnuCash account types.
+var accountTypeAliases = map[string]string{
+	"asset": "ASSET", "actif": "ASSET", "vermogen": "ASSET", "activo": "ASSET",
+	"bank": "BANK", "bank account": "BANK", "checking": "BANK", "chequing": "BANK",
+	"savings": "BANK", "current account": "BANK", "banque": "BANK", "compte bancaire": "BANK",
+	"bankkonto": "BANK", "girokonto": "BANK", "banco": "BANK", "cuenta bancaria": "BANK",
+	"cash": "CASH", "especes": "CASH", "liquide": "CASH", "caisse": "CASH",
+	"bargeld": "CASH", "kasse": "CASH", "efectivo": "CASH",
+	"credit": "CREDIT", "credit card": "CREDIT", "card": "CREDIT", "carte de credit": "CREDIT",
+	"carte bancaire": "CREDIT", "kreditkarte": "CREDIT", "tarjeta de credito": "CREDIT",
+	"liability": "LIABILITY", "liabilities": "LIABILITY", "debt": "LIABILITY", "loan": "LIABILITY",
+	"mortgage": "LIABILITY", "passif": "LIABILITY", "dette": "LIABILITY", "emprunt": "LIABILITY",
+	"schulden": "LIABILITY", "verbindlichkeiten": "LIABILITY", "pasivo": "LIABILITY", "deuda": "LIABILITY",
+	"stock": "STOCK", "share": "STOCK", "action": "STOCK",
+	"aktie": "STOCK", "aktien": "STOCK", "accion": "STOCK", "acciones": "STOCK",
+	"mutual": "MUTUAL", "mutual fund": "MUTUAL", "fund": "MUTUAL", "etf": "MUTUAL",
+	"fonds": "MUTUAL", "sicav": "MUTUAL", "opcvm": "MUTUAL", "fondo": "MUTUAL",
+	"income": "INCOME", "revenue": "INCOME", "earning": "INCOME", "revenu": "INCOME",
+	"recette": "INCOME", "produit": "INCOME", "einnahmen": "INCOME", "einkommen": "INCOME",
+	"ertrag": "INCOME", "ingreso": "INCOME",
+	"expense": "EXPENSE", "spending": "EXPENSE", "cost": "EXPENSE", "depense": "EXPENSE",
+	"charge": "EXPENSE", "ausgaben": "EXPENSE", "aufwand": "EXPENSE", "gasto": "EXPENSE",
+	"equity": "EQUITY", "capitaux propres": "EQUITY", "eigenkapital": "EQUITY", "patrimonio": "EQUITY",
+	"receivable": "RECEIVABLE", "accounts receivable": "RECEIVABLE", "a/r": "RECEIVABLE",
+	"creance": "RECEIVABLE", "client": "RECEIVABLE", "forderungen": "RECEIVABLE",
+	"payable": "PAYABLE", "accounts payable": "PAYABLE", "a/p": "PAYABLE",
+	"fournisseur": "PAYABLE", "cuentas por pagar": "PAYABLE",
+	"trading": "TRADING",
+}
+
+var unaccent = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "î", "i", "ï", "i", "ñ", "n", "ó", "o", "ô", "o", "ö", "o", "ú", "u", "û", "u", "ü", "u", "ß", "ss",
+)
+
+// NormalizeAccountType maps an account type as a user or model might write it
+// ("bank", "Chequing", "dépenses", "debt") to its GnuCash name. An empty input
+// yields an empty type.
+func NormalizeAccountType(name string) (string, error) {
+	key := unaccent.Replace(strings.Join(strings.Fields(strings.ToLower(name)), " "))
+	if key == "" {
+		return "", nil
+	}
+	for _, t := range accountTypes {
+		if key == strings.ToLower(t) {
+			return t, nil
+		}
+	}
+	if t, ok := accountTypeAliases[key]; ok {
+		return t, nil
+	}
+	// Plurals: "expenses", "dépenses", "dettes", "actions".
+	if t, ok := accountTypeAliases[strings.TrimSuffix(key, "s")]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown account type '%s' (expected one of: %s)", name, strings.Join(accountTypes, ", "))
+}
+
+// accountTypeFamily groups account types the way GnuCash restricts nesting:
+// an account may only be placed under an account of the same family (or at
+// the top level).
+func accountTypeFamily(accountType string) string {
+	switch accountType {
+	case "INCOME", "EXPENSE":
+		return "income/expense"
+	case "EQUITY":
+		return "equity"
+	case "TRADING":
+		return "trading"
+	}
+	return "asset/liability"
+}
+
+// checkAccountNesting returns an error unless an account of type child may
+// be placed under an account of type parent.
+func checkAccountNesting(parent, child string) error {
+	if parent == "ROOT" || accountTypeFamily(parent) == accountTypeFamily(child) {
+		return nil
+	}
+	return fmt.Errorf("a %s account cannot be placed under a %s account: GnuCash only nests %s accounts together",
+		child, parent, accountTypeFamily(parent))
+}
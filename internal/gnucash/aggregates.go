@@ -0,0 +1,453 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// monthTotal is the total of the splits of an income or expense account in
+// a month of the book's time zone, those of closing entries apart.
+type monthTotal struct {
+	AccountGUID string
+	Month       string // YYYY-MM
+	Closing     bool   // splits of closing entries (see closingFilter)
+	Value       Numeric
+	Quantity    Numeric
+	Splits      int
+}
+
+// getMonthTotals returns the monthly totals of the income and expense
+// accounts over the posting dates from startDate to endDate, either of which
+// may be empty for no bound, sorted by month, account and closing.
+func (d *DB) getMonthTotals(ctx context.Context, startDate, endDate string) ([]monthTotal, error) {
+	closing, err := d.closingCondition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT s.account_guid, t.post_date, ` + closing + ` AS closing,
+		       SUM(s.value_num), s.value_denom, SUM(s.quantity_num), s.quantity_denom, COUNT(*)
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		WHERE a.account_type IN ('INCOME', 'EXPENSE')
+		  AND t.post_date IS NOT NULL`
+	var args []any
+	if startDate != "" {
+		query += ` AND t.post_date >= ?`
+		args = append(args, d.dayStart(startDate))
+	}
+	if endDate != "" {
+		query += ` AND t.post_date <= ?`
+		args = append(args, d.dayEnd(endDate))
+	}
+	// Grouped by posting time, the months of the book's time zone are summed
+	// up below.
+	query += ` GROUP BY s.account_guid, t.post_date, closing, s.value_denom, s.quantity_denom`
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query monthly account totals: %w", err)
+	}
+	defer rows.Close()
+
+	type key struct {
+		account, month string
+		closing        bool
+	}
+	totals := make(map[key]*monthTotal)
+	for rows.Next() {
+		var account, postDate string
+		var closing bool
+		var valueNum, valueDenom, quantityNum, quantityDenom int64
+		var splits int
+		if err := rows.Scan(&account, &postDate, &closing, &valueNum, &valueDenom, &quantityNum, &quantityDenom, &splits); err != nil {
+			return nil, fmt.Errorf("scan monthly account total: %w", err)
+		}
+		date, _ := d.parseDate(postDate)
+		k := key{account, date.Format("2006-01"), closing}
+		t, ok := totals[k]
+		if !ok {
+			t = &monthTotal{AccountGUID: account, Month: k.month, Closing: closing}
+			totals[k] = t
+		}
+		t.Value = t.Value.Add(NewNumeric(valueNum, valueDenom))
+		t.Quantity = t.Quantity.Add(NewNumeric(quantityNum, quantityDenom))
+		t.Splits += splits
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	results := make([]monthTotal, 0, len(totals))
+	for _, t := range totals {
+		results = append(results, *t)
+	}
+	slices.SortFunc(results, compareMonthTotals)
+	return results, nil
+}
+
+func compareMonthTotals(a, b monthTotal) int {
+	return cmp.Or(cmp.Compare(a.Month, b.Month), cmp.Compare(a.AccountGUID, b.AccountGUID), compareBool(a.Closing, b.Closing))
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// AggregateCache keeps the monthly totals of the income and expense
+// accounts of a book in a separate, writable SQLite database, so that
+// reports over whole months of a very large book do not sum up its splits
+// again. Syncs happen off the calls of tools, at startup and when the book
+// watcher sees the file change (see Service.Refresh): the totals of the book
+// are computed afresh and only the months that differ are rewritten. Until
+// a sync has caught up with the book, reports read it directly. The book
+// itself is never written to.
+type AggregateCache struct {
+	db *sql.DB
+	mu sync.Mutex // serializes syncs
+
+	synced atomic.Pointer[aggregateState] // nil until the first sync
+}
+
+// aggregateState is the state of the book the cache was synced with.
+type aggregateState struct {
+	signature string // FileStamp of the book
+	location  string // time zone months were bucketed in
+}
+
+// current reports whether the cache is in sync with book as it is now.
+// Books not backed by a file (tests) are taken as unchanged since the last
+// sync.
+func (c *AggregateCache) current(book *DB) bool {
+	st := c.synced.Load()
+	return st != nil && st.signature == book.FileStamp() && st.location == book.location().String()
+}
+
+// OpenAggregateCache opens or creates an aggregate cache database at path.
+// The cache is empty until the first Sync.
+func OpenAggregateCache(path string) (*AggregateCache, error) {
+	// Reports read the cache while a sync writes it.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open aggregate cache: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS monthly_totals (
+			month TEXT NOT NULL,
+			account_guid TEXT NOT NULL,
+			closing INTEGER NOT NULL,
+			value TEXT NOT NULL,
+			quantity TEXT NOT NULL,
+			splits INTEGER NOT NULL,
+			PRIMARY KEY (month, account_guid, closing)
+		);
+		CREATE TABLE IF NOT EXISTS monthly_totals_meta (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			signature TEXT NOT NULL,
+			location TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create aggregate cache tables: %w", err)
+	}
+	return &AggregateCache{db: db}, nil
+}
+
+// Close closes the aggregate cache database.
+func (c *AggregateCache) Close() error {
+	return c.db.Close()
+}
+
+// Sync updates the cache from book if the book file changed since the last
+// sync, and reports whether any month changed. Books not backed by a file
+// (tests) are synced every time.
+func (c *AggregateCache) Sync(ctx context.Context, book *DB) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	signature, location := book.FileStamp(), book.location().String()
+	var synced, syncedLocation string
+	err := c.db.QueryRowContext(ctx, `SELECT signature, location FROM monthly_totals_meta WHERE id = 1`).Scan(&synced, &syncedLocation)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("read aggregate cache state: %w", err)
+	}
+	state := &aggregateState{signature, location}
+	if signature != "" && signature == synced && location == syncedLocation {
+		c.synced.Store(state)
+		return false, nil
+	}
+
+	totals, err := book.getMonthTotals(ctx, "", "")
+	if err != nil {
+		return false, err
+	}
+	current := make(map[string][]monthTotal)
+	for _, t := range totals {
+		current[t.Month] = append(current[t.Month], t)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Months are bucketed in the book's time zone: another one moves them all.
+	if location != syncedLocation {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM monthly_totals`); err != nil {
+			return false, fmt.Errorf("clear aggregate cache: %w", err)
+		}
+	}
+	cached, err := cachedTotals(ctx, tx, "", "")
+	if err != nil {
+		return false, err
+	}
+	stored := make(map[string][]monthTotal)
+	for _, t := range cached {
+		stored[t.Month] = append(stored[t.Month], t)
+	}
+
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO monthly_totals (month, account_guid, closing, value, quantity, splits) VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return false, fmt.Errorf("update aggregate cache: %w", err)
+	}
+	defer insert.Close()
+	changed := false
+	for _, month := range mapKeysUnion(current, stored) {
+		if slices.EqualFunc(current[month], stored[month], sameMonthTotal) {
+			continue
+		}
+		changed = true
+		if _, err := tx.ExecContext(ctx, `DELETE FROM monthly_totals WHERE month = ?`, month); err != nil {
+			return false, fmt.Errorf("update aggregate cache: %w", err)
+		}
+		for _, t := range current[month] {
+			_, err := insert.ExecContext(ctx, t.Month, t.AccountGUID, t.Closing, ratString(t.Value), ratString(t.Quantity), t.Splits)
+			if err != nil {
+				return false, fmt.Errorf("update aggregate cache: %w", err)
+			}
+		}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO monthly_totals_meta (id, signature, location) VALUES (1, ?, ?)`, signature, location)
+	if err != nil {
+		return false, fmt.Errorf("record aggregate cache state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	c.synced.Store(state)
+	return changed, nil
+}
+
+// totals returns the cached totals of the months from first to last
+// (YYYY-MM), first empty for no bound.
+func (c *AggregateCache) totals(ctx context.Context, first, last string) ([]monthTotal, error) {
+	return cachedTotals(ctx, c.db, first, last)
+}
+
+// querier is what cachedTotals reads the cache with.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// cachedTotals reads the cached totals of the months from first to last,
+// either of which may be empty for no bound, in the order of getMonthTotals.
+func cachedTotals(ctx context.Context, q querier, first, last string) ([]monthTotal, error) {
+	if last == "" {
+		last = "9999-12"
+	}
+	rows, err := q.QueryContext(ctx, `
+		SELECT month, account_guid, closing, value, quantity, splits FROM monthly_totals
+		WHERE month >= ? AND month <= ?
+		ORDER BY month, account_guid, closing
+	`, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("query aggregate cache: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []monthTotal
+	for rows.Next() {
+		var t monthTotal
+		var value, quantity string
+		if err := rows.Scan(&t.Month, &t.AccountGUID, &t.Closing, &value, &quantity, &t.Splits); err != nil {
+			return nil, fmt.Errorf("scan aggregate cache: %w", err)
+		}
+		if t.Value, err = parseRat(value); err != nil {
+			return nil, err
+		}
+		if t.Quantity, err = parseRat(quantity); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+func sameMonthTotal(a, b monthTotal) bool {
+	return a.AccountGUID == b.AccountGUID && a.Month == b.Month && a.Closing == b.Closing &&
+		a.Value.Cmp(b.Value) == 0 && a.Quantity.Cmp(b.Quantity) == 0 && a.Splits == b.Splits
+}
+
+// mapKeysUnion returns the keys of a and b, sorted.
+func mapKeysUnion[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// ratString formats n exactly, as "num/denom" or an integer.
+func ratString(n Numeric) string {
+	return n.value().RatString()
+}
+
+// parseRat parses what ratString formats.
+func parseRat(s string) (Numeric, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Numeric{}, fmt.Errorf("invalid amount %q in aggregate cache", s)
+	}
+	if r.Sign() == 0 {
+		return Numeric{}, nil
+	}
+	return Numeric{rat: r}, nil
+}
+
+// SyncAggregates syncs the aggregate cache with the book, if one is
+// configured. It scans the book, so it is meant for startup and the book
+// watcher rather than the calls of tools.
+func (s *Service) SyncAggregates(ctx context.Context) error {
+	if s.aggregates == nil {
+		return nil
+	}
+	_, err := s.aggregates.Sync(ctx, s.db)
+	return err
+}
+
+// monthTotals returns the monthly totals of the income and expense accounts
+// from startDate (empty for the beginning of the book) to endDate, leaving
+// out closing entries unless the call includes them. With an aggregate
+// cache in sync with the book the whole months of the period are read from
+// it and only the parts of months at either end from the book; a cache the
+// book watcher has yet to sync is not waited for.
+func (s *Service) monthTotals(ctx context.Context, startDate, endDate string) ([]monthTotal, error) {
+	var totals []monthTotal
+	periods := [][2]string{{startDate, endDate}}
+	if s.aggregates != nil && s.aggregates.current(s.db) {
+		if first, last, edges, ok := wholeMonths(startDate, endDate); ok {
+			cached, err := s.aggregates.totals(ctx, first, last)
+			if err != nil {
+				return nil, err
+			}
+			totals, periods = cached, edges
+		}
+	}
+	for _, p := range periods {
+		live, err := s.db.getMonthTotals(ctx, p[0], p[1])
+		if err != nil {
+			return nil, err
+		}
+		totals = append(totals, live...)
+	}
+	if !s.closingEntries {
+		totals = slices.DeleteFunc(totals, func(t monthTotal) bool { return t.Closing })
+	}
+	slices.SortFunc(totals, compareMonthTotals)
+	return totals, nil
+}
+
+// MonthlyTotal is the total of the splits of one account type in a month.
+type MonthlyTotal struct {
+	Month   string // YYYY-MM
+	AccType string
+	Total   Numeric
+}
+
+// monthlyIncomeExpenses returns the monthly totals of the income and
+// expense accounts by account type, sorted by month and type, through the
+// aggregate cache when there is one.
+func (s *Service) monthlyIncomeExpenses(ctx context.Context, startDate, endDate string) ([]MonthlyTotal, error) {
+	totals, err := s.monthTotals(ctx, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var results []MonthlyTotal
+	index := make(map[[2]string]int)
+	for _, t := range totals {
+		acc, ok := accounts[t.AccountGUID]
+		if !ok {
+			continue
+		}
+		key := [2]string{t.Month, acc.AccountType}
+		if i, ok := index[key]; ok {
+			results[i].Total = results[i].Total.Add(t.Value)
+			continue
+		}
+		index[key] = len(results)
+		results = append(results, MonthlyTotal{Month: t.Month, AccType: acc.AccountType, Total: t.Value})
+	}
+	slices.SortFunc(results, func(a, b MonthlyTotal) int {
+		return cmp.Or(cmp.Compare(a.Month, b.Month), cmp.Compare(a.AccType, b.AccType))
+	})
+	return results, nil
+}
+
+// wholeMonths splits the period from startDate (empty for no bound) to
+// endDate into the whole months it covers, first to last (YYYY-MM, first
+// empty for no bound), and the parts of months before and after them. ok
+// is false when the period covers no whole month or a date is invalid.
+func wholeMonths(startDate, endDate string) (first, last string, edges [][2]string, ok bool) {
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", "", nil, false
+	}
+	lastMonth := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var after [][2]string
+	if end.AddDate(0, 0, 1).Month() == end.Month() {
+		after = [][2]string{{lastMonth.Format("2006-01-02"), endDate}}
+		lastMonth = lastMonth.AddDate(0, -1, 0)
+	}
+	if startDate != "" {
+		start, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return "", "", nil, false
+		}
+		firstMonth := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if start.Day() != 1 {
+			firstMonth = firstMonth.AddDate(0, 1, 0)
+			edges = append(edges, [2]string{startDate, firstMonth.AddDate(0, 0, -1).Format("2006-01-02")})
+		}
+		if firstMonth.After(lastMonth) {
+			return "", "", nil, false
+		}
+		first = firstMonth.Format("2006-01")
+	}
+	return first, lastMonth.Format("2006-01"), append(edges, after...), true
+}
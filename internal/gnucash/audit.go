@@ -0,0 +1,81 @@
+package gnucash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one line of the audit log: a change made to the book.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Tool      string    `json:"tool"`
+	Arguments any       `json:"arguments,omitempty"`
+	GUIDs     []string  `json:"guids"` // transactions, splits or accounts affected
+}
+
+// AuditLog appends an entry for every change made through write mode to a
+// JSON Lines file. Existing entries are never rewritten.
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAuditLog opens or creates the audit log at path for appending.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &AuditLog{f: f}, nil
+}
+
+// Close closes the audit log.
+func (l *AuditLog) Close() error {
+	return l.f.Close()
+}
+
+// Record appends e to the log and flushes it to disk.
+func (l *AuditLog) Record(e AuditEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode audit entry: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return l.f.Sync()
+}
+
+// toolCall is the MCP tool call a service method runs for.
+type toolCall struct {
+	name string
+	args any
+}
+
+// WithToolCall records the tool call a service method runs for, so the
+// audit log shows the tool and its arguments as the client sent them.
+func WithToolCall(name string, args any) Option {
+	return func(s *Service) { s.toolCall = &toolCall{name, args} }
+}
+
+// audit records a change in the audit log, if one is configured. op and
+// input describe the change when the call records no tool call.
+func (s *Service) audit(op string, input any, guids ...string) error {
+	if s.auditLog == nil {
+		return nil
+	}
+	call := toolCall{op, input}
+	if s.toolCall != nil {
+		call = *s.toolCall
+	}
+	err := s.auditLog.Record(AuditEntry{Time: time.Now().UTC(), Tool: call.name, Arguments: call.args, GUIDs: guids})
+	if err != nil {
+		return fmt.Errorf("the change was saved but could not be audited: %w", err)
+	}
+	return nil
+}
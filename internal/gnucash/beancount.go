@@ -0,0 +1,289 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// beancountRoot maps a GnuCash account type to one of Beancount's five root
+// accounts.
+func beancountRoot(accountType string) string {
+	switch accountType {
+	case "LIABILITY", "CREDIT", "PAYABLE":
+		return "Liabilities"
+	case "INCOME":
+		return "Income"
+	case "EXPENSE":
+		return "Expenses"
+	case "EQUITY", "TRADING":
+		return "Equity"
+	}
+	return "Assets"
+}
+
+// beancountComponent turns an account name into a Beancount account name
+// component: letters, digits and dashes, starting with a capital letter or a
+// digit.
+func beancountComponent(name string) string {
+	var sb strings.Builder
+	gap := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			gap = true
+			continue
+		}
+		if gap && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteRune(r)
+		gap = false
+	}
+	s := sb.String()
+	if s == "" {
+		return "X"
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if r = unicode.ToUpper(r); !unicode.IsUpper(r) && !unicode.IsDigit(r) {
+		return "X" + s // a letter without case
+	}
+	return string(r) + s[size:]
+}
+
+// beancountCommodity turns a GnuCash mnemonic into a Beancount commodity:
+// capital letters, digits and ' . _ -, starting with a letter and ending with
+// a letter or a digit, at most 24 characters.
+func beancountCommodity(mnemonic string) string {
+	s := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '\'', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, strings.ToUpper(mnemonic))
+	s = strings.TrimRightFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		s = "X" + s
+	}
+	return s[:min(len(s), 24)]
+}
+
+// uniqueName returns name, or name with a numeric suffix when it is already
+// taken, and marks it taken.
+func uniqueName(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// beancountAccountNames names every account the Beancount way: the root
+// account of its top-level account's type followed by its path, minus the
+// top-level account when it already is the root ("Assets:Checking" rather
+// than "Assets:Assets:Checking"). Top-level accounts keep their name, as
+// Beancount has no postings to a root account.
+func beancountAccountNames(accounts map[string]*Account) map[string]string {
+	sorted := make([]*Account, 0, len(accounts))
+	for _, acc := range accounts {
+		sorted = append(sorted, acc)
+	}
+	slices.SortFunc(sorted, func(a, b *Account) int { return cmp.Compare(a.FullName, b.FullName) })
+
+	names := make(map[string]string, len(accounts))
+	taken := make(map[string]bool)
+	for _, acc := range sorted {
+		top := acc
+		for {
+			parent, ok := accounts[top.ParentGUID]
+			if !ok {
+				break
+			}
+			top = parent
+		}
+		root := beancountRoot(top.AccountType)
+		components := []string{root}
+		segments := strings.Split(acc.FullName, ":")
+		for i, segment := range segments {
+			c := beancountComponent(segment)
+			if i == 0 && c == root && len(segments) > 1 {
+				continue
+			}
+			components = append(components, c)
+		}
+		names[acc.GUID] = uniqueName(strings.Join(components, ":"), taken)
+	}
+	return names
+}
+
+// beancountString quotes s as a Beancount string.
+func beancountString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+// ExportBeancount writes the accounts, commodities, prices and transactions
+// of the book between startDate and endDate (both optional) to a Beancount
+// file in the export directory. When the period has a start, the balances
+// of asset, liability and equity accounts at that date are opened against
+// Equity:Opening-Balances so that the file balances to the book.
+func (s *Service) ExportBeancount(ctx context.Context, startDate, endDate string) (string, error) {
+	if err := s.checkExports(); err != nil {
+		return "", err
+	}
+	if err := checkExportPeriod(startDate, endDate); err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	commodities, err := s.db.getCommodities(ctx)
+	if err != nil {
+		return "", err
+	}
+	txs, err := s.db.getExportTransactions(ctx, startDate, endDate, nil)
+	if err != nil {
+		return "", err
+	}
+	txs = bookTransactions(txs, accounts)
+	prices, err := s.db.getAllPrices(ctx, commodities, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	defaultCurrency, err := s.db.defaultCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	names := beancountAccountNames(accounts)
+	commodityList := sortedCommodities(commodities)
+	symbols := make(map[string]string, len(commodities))
+	taken := make(map[string]bool)
+	for _, c := range commodityList {
+		symbols[c.GUID] = uniqueName(beancountCommodity(c.Mnemonic), taken)
+	}
+	// Everything is opened on the first day of the file.
+	openDate := startDate
+	if openDate == "" {
+		openDate = s.now().Format("2006-01-02")
+		if len(txs) > 0 {
+			openDate = txs[0].PostDate.Format("2006-01-02")
+		}
+		if len(prices) > 0 {
+			openDate = min(openDate, prices[0].Date.Format("2006-01-02"))
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; Beancount export of a GnuCash book, %s\n", periodLabel(startDate, endDate))
+	fmt.Fprintf(&sb, "; Generated on %s\n\n", time.Now().Format("2006-01-02 15:04"))
+	if c, ok := commodities[defaultCurrency]; ok {
+		fmt.Fprintf(&sb, "option \"operating_currency\" %s\n\n", beancountString(symbols[c.GUID]))
+	}
+
+	for _, c := range commodityList {
+		if c.Namespace == "template" {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s commodity %s\n", openDate, symbols[c.GUID])
+		if c.FullName != "" {
+			fmt.Fprintf(&sb, "  name: %s\n", beancountString(c.FullName))
+		}
+		if c.CUSIP != "" && c.Namespace != "CURRENCY" {
+			fmt.Fprintf(&sb, "  code: %s\n", beancountString(c.CUSIP))
+		}
+	}
+	sb.WriteString("\n")
+
+	sorted := make([]*Account, 0, len(accounts))
+	for _, acc := range accounts {
+		sorted = append(sorted, acc)
+	}
+	slices.SortFunc(sorted, func(a, b *Account) int { return cmp.Compare(names[a.GUID], names[b.GUID]) })
+	used := make(map[string]bool) // accounts with postings
+	for _, tx := range txs {
+		for _, sp := range tx.Splits {
+			used[sp.AccountGUID] = true
+		}
+	}
+
+	equity := "Equity:Opening-Balances"
+	var opening strings.Builder
+	if startDate != "" {
+		balances, totals, err := s.openingBalances(ctx, startDate, accounts, commodities, defaultCurrency,
+			func(*Account) bool { return true })
+		if err != nil {
+			return "", err
+		}
+		for _, b := range balances {
+			fmt.Fprintf(&opening, "  %s  %s %s\n", names[b.Account.GUID], b.Amount(), symbols[b.Commodity.GUID])
+			used[b.Account.GUID] = true
+		}
+		for _, b := range totals {
+			fmt.Fprintf(&opening, "  %s  %s %s\n", equity, b.Amount(), symbols[b.Commodity.GUID])
+		}
+	}
+
+	// Parent accounts are only opened when they have postings of their own.
+	opened := make(map[string]bool)
+	for _, acc := range sorted {
+		if len(acc.Children) > 0 && !used[acc.GUID] {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s open %s\n", openDate, names[acc.GUID])
+		if acc.Description != "" {
+			fmt.Fprintf(&sb, "  description: %s\n", beancountString(acc.Description))
+		}
+		opened[names[acc.GUID]] = true
+	}
+	if opening.Len() > 0 && !opened[equity] {
+		fmt.Fprintf(&sb, "%s open %s\n", openDate, equity)
+		opened[equity] = true
+	}
+	sb.WriteString("\n")
+	if opening.Len() > 0 {
+		fmt.Fprintf(&sb, "%s * \"Opening balances\"\n%s\n", startDate, opening.String())
+	}
+
+	for _, tx := range txs {
+		fmt.Fprintf(&sb, "%s * %s\n", tx.PostDate.Format("2006-01-02"), beancountString(tx.Description))
+		fmt.Fprintf(&sb, "  guid: %s\n", beancountString(tx.GUID))
+		currency := symbols[tx.CurrencyGUID]
+		for _, sp := range tx.Splits {
+			acc := accounts[sp.AccountGUID]
+			commodity := accountCommodity(acc, tx.CurrencyGUID)
+			value := exactDecimal(sp.ValueNum, sp.ValueDenom)
+			switch {
+			case commodity == tx.CurrencyGUID || sp.QuantityNum == 0:
+				// A zero quantity with a value is a gain or loss booked
+				// on a security account.
+				fmt.Fprintf(&sb, "  %s  %s %s\n", names[acc.GUID], value, currency)
+			default:
+				fmt.Fprintf(&sb, "  %s  %s %s @@ %s %s\n", names[acc.GUID], exactDecimal(sp.QuantityNum, sp.QuantityDenom),
+					symbols[commodity], strings.TrimPrefix(value, "-"), currency)
+			}
+			if sp.Memo != "" {
+				fmt.Fprintf(&sb, "    memo: %s\n", beancountString(sp.Memo))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, p := range prices {
+		fmt.Fprintf(&sb, "%s price %s %s %s\n", p.Date.Format("2006-01-02"), symbols[p.Commodity.GUID],
+			exactDecimal(p.ValueNum, p.ValueDenom), beancountCommodity(p.Currency))
+	}
+
+	path, err := s.writeExport("book", startDate, endDate, "beancount", sb.String())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Beancount export (%s) written to %s: %d account(s), %d transaction(s), %d price(s). Check it with bean-check.\n",
+		periodLabel(startDate, endDate), path, len(opened), len(txs), len(prices)), nil
+}
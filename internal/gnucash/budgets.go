@@ -0,0 +1,202 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurrencePeriod returns the first and last day of period i of a budget
+// or scheduled transaction recurring every r.
+func recurrencePeriod(r *jsonRecurrence, i int) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", r.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid recurrence start '%s'", r.Start)
+	}
+	n := max(1, r.Multiplier)
+	switch r.PeriodType {
+	case "month", "end of month":
+		return start.AddDate(0, i*n, 0), start.AddDate(0, (i+1)*n, -1), nil
+	case "year":
+		return start.AddDate(i*n, 0, 0), start.AddDate((i+1)*n, 0, -1), nil
+	case "week":
+		return start.AddDate(0, 0, i*n*7), start.AddDate(0, 0, (i+1)*n*7-1), nil
+	case "day":
+		return start.AddDate(0, 0, i*n), start.AddDate(0, 0, (i+1)*n-1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unsupported recurrence period '%s'", r.PeriodType)
+}
+
+// budgetAlert is an expense category over, or heading over, its budget.
+type budgetAlert struct {
+	Account   string
+	Budget    float64
+	Prorated  float64 // budget for the part of the period elapsed
+	Actual    float64
+	Projected float64 // actual extended to the whole period
+}
+
+// over reports whether the category already spent more than its budget.
+func (a budgetAlert) over() bool {
+	return cents(a.Actual) > cents(a.Budget)
+}
+
+// budgetCheck is the state of the expense accounts of a budget at a date.
+type budgetCheck struct {
+	Budget     *jsonBudget
+	Start, End time.Time // of the period containing the date
+	Fraction   float64   // of the period elapsed
+	Budgeted   int       // expense accounts with an amount for the period
+	Alerts     []budgetAlert
+	Missing    string // why no budget applies, when Budget is nil
+}
+
+// checkBudget compares the spending of each expense account with its amount
+// in the budget named budgetName (the first budget covering asOf when empty)
+// for the period containing asOf. Accounts that spent more than their
+// amount prorated to the days elapsed are alerts, the largest projected
+// overruns first. When no budget applies, Budget is nil and Missing says
+// why.
+func (s *Service) checkBudget(ctx context.Context, budgetName string, asOf time.Time) (budgetCheck, error) {
+	var c budgetCheck
+	budgets, err := s.db.getBudgets(ctx)
+	if err != nil {
+		return c, err
+	}
+	if len(budgets) == 0 {
+		c.Missing = "the book has no budgets"
+		return c, nil
+	}
+
+	// The budget, and its period containing asOf.
+	var period int
+	var names []string
+	for i := range budgets {
+		b := &budgets[i]
+		names = append(names, b.Name)
+		if b.Recurrence == nil || (budgetName != "" && !strings.EqualFold(b.Name, budgetName)) {
+			continue
+		}
+		for p := 0; p < b.Periods; p++ {
+			first, last, err := recurrencePeriod(b.Recurrence, p)
+			if err != nil {
+				return c, fmt.Errorf("budget '%s': %w", b.Name, err)
+			}
+			if !asOf.Before(first) && !asOf.After(last) {
+				c.Budget, period, c.Start, c.End = b, p, first, last
+				break
+			}
+		}
+		if c.Budget != nil {
+			break
+		}
+	}
+	date := asOf.Format("2006-01-02")
+	if c.Budget == nil {
+		if budgetName != "" && !slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, budgetName) }) {
+			return c, fmt.Errorf("no budget named '%s' (budgets: %s)", budgetName, strings.Join(names, ", "))
+		}
+		c.Missing = fmt.Sprintf("no budget covers %s", date)
+		return c, nil
+	}
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return c, err
+	}
+	totals, err := s.db.GetAccountTotals(ctx, []string{"EXPENSE"}, c.Start.Format("2006-01-02"), date, s.closingEntries)
+	if err != nil {
+		return c, err
+	}
+	days := c.End.Sub(c.Start).Hours()/24 + 1
+	elapsed := asOf.Sub(c.Start).Hours()/24 + 1
+	c.Fraction = elapsed / days
+
+	for _, a := range c.Budget.Amounts {
+		acc := accounts[a.AccountGUID]
+		if a.Period != period || acc == nil || acc.AccountType != "EXPENSE" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(a.Amount, 64)
+		if err != nil || amount <= 0 {
+			continue
+		}
+		c.Budgeted++
+		alert := budgetAlert{Account: acc.FullName, Budget: amount, Prorated: amount * c.Fraction}
+		for _, guid := range descendantGUIDs(acc) {
+			alert.Actual += totals[guid]
+		}
+		alert.Projected = alert.Actual / c.Fraction
+		if cents(alert.Actual) > cents(alert.Prorated) {
+			c.Alerts = append(c.Alerts, alert)
+		}
+	}
+	slices.SortFunc(c.Alerts, func(a, b budgetAlert) int {
+		return cmp.Or(cmp.Compare(b.Projected-b.Budget, a.Projected-a.Budget), cmp.Compare(a.Account, b.Account))
+	})
+	return c, nil
+}
+
+// BudgetAlerts compares the spending of each expense account with a budget
+// in the GnuCash budget named budgetName (the budget covering date when
+// empty) for the period containing date (today when empty), and lists only
+// the accounts over budget, or heading over it: those that spent more than
+// the budget prorated to the days elapsed. Budgeted accounts include their
+// sub-accounts.
+func (s *Service) BudgetAlerts(ctx context.Context, budgetName, date, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if date == "" {
+		date = s.now().Format("2006-01-02")
+	}
+	asOf, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date '%s': %w", date, err)
+	}
+	c, err := s.checkBudget(ctx, budgetName, asOf)
+	if err != nil {
+		return "", err
+	}
+	if c.Budget == nil {
+		return "", errors.New(c.Missing)
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	status := func(a budgetAlert) string {
+		if a.over() {
+			return "over"
+		}
+		return "trending over"
+	}
+	if format != FormatText {
+		t := table{Headers: []string{"account", "budget", "prorated", "actual", "projected", "status"}}
+		for _, a := range c.Alerts {
+			t.add(a.Account, fmt.Sprintf("%.2f", a.Budget), fmt.Sprintf("%.2f", a.Prorated), fmt.Sprintf("%.2f", a.Actual), fmt.Sprintf("%.2f", a.Projected), status(a))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Budget '%s', period %s to %s, at %s (%.0f%% elapsed), in %s:\n",
+		c.Budget.Name, c.Start.Format("2006-01-02"), c.End.Format("2006-01-02"), date, c.Fraction*100, cur.Mnemonic)
+	if len(c.Alerts) == 0 {
+		fmt.Fprintf(&sb, "\nAll %d budgeted expense account(s) are on track.\n", c.Budgeted)
+		return sb.String(), nil
+	}
+	fmt.Fprintf(&sb, "\n  %-40s %12s %12s %12s %12s\n", "Account", "Budget", "Prorated", "Actual", "Projected")
+	for _, a := range c.Alerts {
+		fmt.Fprintf(&sb, "  %-40s %12.2f %12.2f %12.2f %12.2f  %s\n", a.Account, a.Budget, a.Prorated, a.Actual, a.Projected, status(a))
+	}
+	fmt.Fprintf(&sb, "\n%d of %d budgeted expense account(s) over or heading over budget.\n", len(c.Alerts), c.Budgeted)
+	return sb.String(), nil
+}
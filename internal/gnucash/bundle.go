@@ -0,0 +1,233 @@
+package gnucash
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Reports that can be exported as a bundle.
+const (
+	ReportSpendingByCategory = "spending_by_category"
+	ReportIncomeVsExpenses   = "income_vs_expenses"
+)
+
+// BundleRequest selects the report exported by ExportReportBundle and its
+// parameters. It is recorded verbatim in the bundle's manifest.
+type BundleRequest struct {
+	Report        string `json:"report"`
+	StartDate     string `json:"start_date,omitempty"`     // spending_by_category
+	EndDate       string `json:"end_date,omitempty"`       // spending_by_category
+	ParentAccount string `json:"parent_account,omitempty"` // spending_by_category
+	Grouping      string `json:"grouping,omitempty"`       // spending_by_category
+	Months        int    `json:"months,omitempty"`         // income_vs_expenses
+}
+
+type bundleManifest struct {
+	GeneratedAt string        `json:"generated_at"`
+	Request     BundleRequest `json:"request"`
+	StartDate   string        `json:"start_date"`
+	EndDate     string        `json:"end_date"`
+	Book        struct {
+		Path   string `json:"path,omitempty"`
+		SHA256 string `json:"sha256,omitempty"`
+	} `json:"book"`
+	Files []string `json:"files"`
+}
+
+// BookHash returns the SHA-256 of the book file, or "" for a database that is
+// not backed by a file.
+func (d *DB) BookHash() (string, error) {
+	if d.path == "" {
+		return "", nil
+	}
+	f, err := os.Open(d.path)
+	if err != nil {
+		return "", fmt.Errorf("hash book: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash book: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ExportReportBundle writes an audit-ready directory under the export
+// directory holding the report as Markdown, CSV and JSON, the transactions it
+// was computed from, and a manifest with the parameters and the book's hash.
+func (s *Service) ExportReportBundle(ctx context.Context, req BundleRequest) (string, error) {
+	if s.exportDir == "" {
+		return "", fmt.Errorf("report bundles are disabled (set GNUCASH_EXPORT_DIR to enable)")
+	}
+	now := time.Now()
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Resolve defaults up front so the report and its provenance cover the
+	// same period.
+	var report func(format string) (string, error)
+	var provenance []string
+	startDate, endDate := req.StartDate, req.EndDate
+	switch req.Report {
+	case ReportSpendingByCategory:
+		if startDate == "" {
+			startDate = now.Format("2006-01") + "-01"
+		}
+		if endDate == "" {
+			endDate = now.Format("2006-01-02")
+		}
+		var parentGUID string
+		if req.ParentAccount != "" {
+			parent, err := s.resolveAccount(ctx, req.ParentAccount)
+			if err != nil {
+				return "", err
+			}
+			parentGUID = parent.GUID
+		}
+		for guid, acc := range accounts {
+			// Like the report, a parent account only covers its direct children.
+			if acc.AccountType == "EXPENSE" && (parentGUID == "" || acc.ParentGUID == parentGUID) {
+				provenance = append(provenance, guid)
+			}
+		}
+		report = func(format string) (string, error) {
+			return s.SpendingByCategory(ctx, startDate, endDate, req.ParentAccount, req.Grouping, "", "", format)
+		}
+	case ReportIncomeVsExpenses:
+		if req.Months <= 0 {
+			req.Months = 6
+		}
+		startDate, endDate = monthsPeriod(now, req.Months)
+		for guid, acc := range accounts {
+			if acc.AccountType == "INCOME" || acc.AccountType == "EXPENSE" {
+				provenance = append(provenance, guid)
+			}
+		}
+		report = func(format string) (string, error) {
+			return s.IncomeVsExpenses(ctx, req.Months, "", format)
+		}
+	default:
+		return "", fmt.Errorf("unsupported report '%s' (expected %s or %s)", req.Report, ReportSpendingByCategory, ReportIncomeVsExpenses)
+	}
+
+	markdown, err := report(FormatMarkdown)
+	if err != nil {
+		return "", err
+	}
+	csvReport, err := report(FormatCSV)
+	if err != nil {
+		return "", err
+	}
+	jsonReport, err := csvToJSON(csvReport)
+	if err != nil {
+		return "", err
+	}
+
+	txStart, _, err := s.horizonStart(ctx, startDate)
+	if err != nil {
+		return "", err
+	}
+	q := txQuery{StartDate: txStart, EndDate: endDate, Order: txOrder{By: SortByDate}}
+	var transactions []Transaction
+	if len(provenance) > 0 {
+		q.AccountGUIDs = provenance
+		if transactions, _, err = s.db.SearchTransactions(ctx, q); err != nil {
+			return "", err
+		}
+	}
+
+	m := bundleManifest{GeneratedAt: now.Format(time.RFC3339), Request: req, StartDate: startDate, EndDate: endDate}
+	m.Book.Path = s.db.path
+	if m.Book.SHA256, err = s.db.BookHash(); err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(s.exportDir, fmt.Sprintf("%s_%s_%s_%s", req.Report, startDate, endDate, now.Format("20060102T150405")))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create bundle directory: %w", err)
+	}
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"report.md", markdown},
+		{"report.csv", csvReport},
+		{"report.json", jsonReport},
+		{"transactions.csv", provenanceCSV(transactions, accounts)},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.content), 0o644); err != nil {
+			return "", fmt.Errorf("write %s: %w", f.name, err)
+		}
+		m.Files = append(m.Files, f.name)
+	}
+	manifest, _ := json.MarshalIndent(m, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), append(manifest, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("write manifest.json: %w", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Report bundle for %s (%s to %s) written to %s:\n\n", req.Report, startDate, endDate, dir)
+	for _, name := range append([]string{"manifest.json"}, m.Files...) {
+		fmt.Fprintf(&sb, "  %s\n", name)
+	}
+	fmt.Fprintf(&sb, "\n%d source transactions", len(transactions))
+	if m.Book.SHA256 != "" {
+		fmt.Fprintf(&sb, ", book SHA-256 %s", m.Book.SHA256)
+	}
+	sb.WriteString("\n")
+	return sb.String(), nil
+}
+
+// csvToJSON converts a CSV report into a JSON array of objects keyed by
+// column header.
+func csvToJSON(content string) (string, error) {
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("parse CSV report: %w", err)
+	}
+	rows := make([]map[string]string, 0, len(records))
+	if len(records) > 0 {
+		for _, rec := range records[1:] {
+			row := make(map[string]string, len(rec))
+			for i, cell := range rec {
+				row[records[0][i]] = cell
+			}
+			rows = append(rows, row)
+		}
+	}
+	data, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// provenanceCSV lists every split of the given transactions, one per row.
+func provenanceCSV(transactions []Transaction, accounts map[string]*Account) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"date", "transaction_guid", "description", "account", "amount", "memo"})
+	for _, tx := range transactions {
+		for _, sp := range tx.Splits {
+			account := sp.AccountName
+			if acc, ok := accounts[sp.AccountGUID]; ok {
+				account = acc.FullName
+			}
+			w.Write([]string{tx.PostDate.Format("2006-01-02"), tx.GUID, tx.Description, account, sp.FormatAmount(), sp.Memo})
+		}
+	}
+	w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,156 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cashMonth is the cash that came in and went out in one month.
+type cashMonth struct {
+	Month         string // YYYY-MM
+	Inflow        float64
+	Outflow       float64 // positive
+	Net           float64
+	EndingBalance float64
+}
+
+// BurnRate reports the average monthly net cash outflow of the months
+// complete months before date (today when empty), and the runway the cash
+// at date gives at that pace. Cash is held in the BANK and CASH accounts,
+// those below accountName when set. Transfers between them are neither
+// inflows nor outflows.
+func (s *Service) BurnRate(ctx context.Context, accountName, date string, months int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if months <= 0 {
+		months = 6
+	}
+	if date == "" {
+		date = s.now().Format("2006-01-02")
+	}
+	asOf, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date '%s': %w", date, err)
+	}
+	windowEnd := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	if asOf.AddDate(0, 0, 1).Day() == 1 {
+		windowEnd = asOf // date closes its month
+	}
+	windowStart := time.Date(windowEnd.Year(), windowEnd.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1-months, 0)
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	candidates := make([]*Account, 0, len(accounts))
+	if accountName != "" {
+		acc, err := s.resolveAccount(ctx, accountName)
+		if err != nil {
+			return "", err
+		}
+		for _, guid := range descendantGUIDs(acc) {
+			candidates = append(candidates, accounts[guid])
+		}
+	} else {
+		for _, acc := range accounts {
+			candidates = append(candidates, acc)
+		}
+	}
+	cash := make(map[string]bool)
+	var guids []string
+	for _, acc := range candidates {
+		if acc != nil && (acc.AccountType == "BANK" || acc.AccountType == "CASH") {
+			cash[acc.GUID] = true
+			guids = append(guids, acc.GUID)
+		}
+	}
+	if len(guids) == 0 {
+		return "", fmt.Errorf("no BANK or CASH accounts found")
+	}
+	slices.Sort(guids)
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	splits, err := s.db.getRelatedSplits(ctx, guids, date, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+
+	window := make([]*cashMonth, months)
+	for i := range window {
+		window[i] = &cashMonth{Month: windowStart.AddDate(0, i, 0).Format("2006-01")}
+	}
+	var balance float64
+	for i := 0; i < len(splits); {
+		tx, day := splits[i].TxGUID, splits[i].Date
+		var net float64
+		for ; i < len(splits) && splits[i].TxGUID == tx; i++ {
+			if cash[splits[i].AccountGUID] {
+				net += splits[i].Value
+			}
+		}
+		balance += net
+		if day.Before(windowStart) || day.After(windowEnd) {
+			continue
+		}
+		m := window[(day.Year()-windowStart.Year())*12+int(day.Month()-windowStart.Month())]
+		if net > 0 {
+			m.Inflow += net
+		} else {
+			m.Outflow -= net
+		}
+		m.Net += net
+	}
+	// Ending balances, back from the balance at date.
+	ending := balance
+	for _, sp := range splits {
+		if sp.Date.After(windowEnd) && cash[sp.AccountGUID] {
+			ending -= sp.Value
+		}
+	}
+	for i := len(window) - 1; i >= 0; i-- {
+		window[i].EndingBalance = ending
+		ending -= window[i].Net
+	}
+
+	var net float64
+	for _, m := range window {
+		net += m.Net
+	}
+	burn := -net / float64(months)
+
+	if format != FormatText {
+		t := table{Headers: []string{"month", "inflow", "outflow", "net", "ending_balance"}}
+		for _, m := range window {
+			t.add(m.Month, fmt.Sprintf("%.2f", m.Inflow), fmt.Sprintf("%.2f", m.Outflow), fmt.Sprintf("%.2f", m.Net), fmt.Sprintf("%.2f", m.EndingBalance))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Cash burn over the %d month(s) from %s to %s, in %s:\n\n", months, window[0].Month, window[len(window)-1].Month, cur.Mnemonic)
+	for _, m := range window {
+		fmt.Fprintf(&sb, "  %s  in %12.2f  out %12.2f  net %+12.2f  balance %12.2f\n", m.Month, m.Inflow, m.Outflow, m.Net, m.EndingBalance)
+	}
+	fmt.Fprintf(&sb, "\n  Cash at %s: %.2f\n", date, balance)
+	switch {
+	case burn <= 0:
+		fmt.Fprintf(&sb, "  Average net cash flow: %+.2f a month; cash is not burning, the runway is unlimited at this pace.\n", -burn)
+	case balance <= 0:
+		fmt.Fprintf(&sb, "  Burn rate: %.2f a month, with no cash left.\n", burn)
+	default:
+		runway := balance / burn
+		whole := int(math.Floor(runway))
+		end := asOf.AddDate(0, whole, int(math.Round((runway-float64(whole))*30)))
+		fmt.Fprintf(&sb, "  Burn rate: %.2f a month\n", burn)
+		fmt.Fprintf(&sb, "  Runway: %.1f months, until about %s\n", runway, end.Format("2006-01-02"))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,822 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Owner types of the business features, as stored in the owner_type
+// columns and slots (GncOwnerType).
+const (
+	ownerCustomer = 2
+	ownerJob      = 3
+	ownerVendor   = 4
+	ownerEmployee = 5
+)
+
+// businessOwner is the customer, job, vendor or employee an invoice, bill or
+// payment belongs to.
+type businessOwner struct {
+	Type int
+	GUID string
+}
+
+// getLotOwners returns the owner of the lots of A/R and A/P accounts: the
+// owner of the invoice or bill posted to the lot, or the one recorded in
+// the lot's slots for payments not applied to any. None when the book does
+// not use the business features.
+func (d *DB) getLotOwners(ctx context.Context) (map[string]businessOwner, error) {
+	owners := make(map[string]businessOwner)
+	if ok, err := d.hasTable(ctx, "invoices"); err != nil {
+		return nil, err
+	} else if ok {
+		rows, err := d.db.QueryContext(ctx, `
+			SELECT post_lot, owner_type, owner_guid FROM invoices
+			WHERE post_lot IS NOT NULL AND owner_guid IS NOT NULL
+		`)
+		if err != nil {
+			return nil, fmt.Errorf("query invoice owners: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var lot string
+			var o businessOwner
+			if err := rows.Scan(&lot, &o.Type, &o.GUID); err != nil {
+				return nil, fmt.Errorf("scan invoice owner: %w", err)
+			}
+			owners[lot] = o
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterate invoice owners: %w", err)
+		}
+	}
+
+	if ok, err := d.hasColumn(ctx, "slots", "guid_val"); err != nil || !ok {
+		return owners, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT t.obj_guid, t.int64_val, g.guid_val
+		FROM slots t
+		JOIN slots g ON g.obj_guid = t.obj_guid AND g.name = 'gncOwner/owner-guid'
+		WHERE t.name = 'gncOwner/owner-type' AND g.guid_val IS NOT NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query lot owners: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lot string
+		var o businessOwner
+		if err := rows.Scan(&lot, &o.Type, &o.GUID); err != nil {
+			return nil, fmt.Errorf("scan lot owner: %w", err)
+		}
+		if _, ok := owners[lot]; !ok {
+			owners[lot] = o
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate lot owners: %w", err)
+	}
+	return owners, nil
+}
+
+// getOwnerNames returns the names of the customers, vendors, employees and
+// jobs of the book by GUID, and the owner of each job.
+func (d *DB) getOwnerNames(ctx context.Context) (names map[string]string, jobOwners map[string]businessOwner, err error) {
+	names = make(map[string]string)
+	jobOwners = make(map[string]businessOwner)
+	for _, q := range []struct{ table, query string }{
+		{"customers", `SELECT guid, name FROM customers`},
+		{"vendors", `SELECT guid, name FROM vendors`},
+		{"employees", `SELECT guid, COALESCE(NULLIF(addr_name, ''), username) FROM employees`},
+	} {
+		if ok, err := d.hasTable(ctx, q.table); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			continue
+		}
+		if err := d.scanNames(ctx, q.table, q.query, names); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	jobs, err := d.getJobs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, j := range jobs {
+		names[j.GUID] = j.Name
+		jobOwners[j.GUID] = j.Owner
+	}
+	return names, jobOwners, nil
+}
+
+// job is a customer or vendor job of the business features.
+type job struct {
+	GUID   string
+	ID     string
+	Name   string
+	Active bool
+	Owner  businessOwner
+}
+
+// getJobs returns the jobs of the book, none when it has no jobs table.
+func (d *DB) getJobs(ctx context.Context) ([]job, error) {
+	if ok, err := d.hasTable(ctx, "jobs"); err != nil || !ok {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT guid, COALESCE(id, ''), name, active, owner_type, COALESCE(owner_guid, '') FROM jobs ORDER BY id, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query jobs: %w", err)
+	}
+	defer rows.Close()
+	var jobs []job
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.GUID, &j.ID, &j.Name, &j.Active, &j.Owner.Type, &j.Owner.GUID); err != nil {
+			return nil, fmt.Errorf("scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// resolveOwner returns the customer or vendor of o when it is a job.
+func resolveOwner(o businessOwner, jobOwners map[string]businessOwner) businessOwner {
+	if o.Type == ownerJob {
+		return jobOwners[o.GUID]
+	}
+	return o
+}
+
+// scanNames adds the GUIDs and names query returns to names.
+func (d *DB) scanNames(ctx context.Context, table, query string, names map[string]string) error {
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var guid, name string
+		if err := rows.Scan(&guid, &name); err != nil {
+			return fmt.Errorf("scan %s: %w", table, err)
+		}
+		names[guid] = name
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate %s: %w", table, err)
+	}
+	return nil
+}
+
+// vendorPayments is what was paid to one vendor in a year.
+type vendorPayments struct {
+	Year     int
+	Vendor   string
+	Payments int
+	Total    float64
+	Card     float64 // part of Total paid from CREDIT accounts
+}
+
+// VendorPayments totals the payments to each vendor per calendar year, in
+// year only when it is not zero, for 1099 preparation. With the business
+// features, a payment is a transaction debiting an A/P account from other
+// asset or liability accounts, and its vendor the owner of the bill or
+// prepayment lot it settles. Books without vendor payments fall back to
+// expenses paid from asset or liability accounts, by transaction
+// description. Vendors paid less than minAmount in a year are left out.
+// The part paid by credit card, reported on 1099-K by the card issuer
+// rather than on 1099-NEC, is shown apart.
+func (s *Service) VendorPayments(ctx context.Context, year int, minAmount float64, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	endDate := s.now().Format("2006-01-02")
+	if year != 0 {
+		endDate = fmt.Sprintf("%d-12-31", year)
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	type key struct {
+		year   int
+		vendor string
+	}
+	payments := make(map[key]*vendorPayments)
+	add := func(date time.Time, vendor string, amount, card float64) {
+		if year != 0 && date.Year() != year {
+			return
+		}
+		k := key{date.Year(), vendor}
+		p, ok := payments[k]
+		if !ok {
+			p = &vendorPayments{Year: k.year, Vendor: vendor}
+			payments[k] = p
+		}
+		p.Payments++
+		p.Total += amount
+		p.Card += card
+	}
+	// cardShare is the part of a payment funded from CREDIT accounts.
+	cardShare := func(txSplits []relatedSplit, skip string) float64 {
+		var funded, card float64
+		for _, sp := range txSplits {
+			acc := accounts[sp.AccountGUID]
+			if acc == nil || acc.AccountType == skip || !isBalanceSheet(acc.AccountType) || sp.Value >= 0 {
+				continue
+			}
+			funded -= sp.Value
+			if acc.AccountType == "CREDIT" {
+				card -= sp.Value
+			}
+		}
+		if funded == 0 {
+			return 0
+		}
+		return card / funded
+	}
+
+	var payables []string
+	for guid, acc := range accounts {
+		if acc.AccountType == "PAYABLE" {
+			payables = append(payables, guid)
+		}
+	}
+	source := "the business tables"
+	if len(payables) > 0 {
+		slices.Sort(payables)
+		lotOwners, err := s.db.getLotOwners(ctx)
+		if err != nil {
+			return "", err
+		}
+		names, jobOwners, err := s.db.getOwnerNames(ctx)
+		if err != nil {
+			return "", err
+		}
+		splits, err := s.db.getRelatedSplits(ctx, payables, endDate, s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		for i := 0; i < len(splits); {
+			tx := splits[i].TxGUID
+			j := i
+			for j < len(splits) && splits[j].TxGUID == tx {
+				j++
+			}
+			txSplits := splits[i:j]
+			i = j
+			// Bills and credit notes post income or expenses; transfers
+			// between lots of A/P accounts move no money.
+			payment := false
+			for _, sp := range txSplits {
+				acc := accounts[sp.AccountGUID]
+				if acc == nil {
+					continue
+				}
+				if acc.AccountType == "INCOME" || acc.AccountType == "EXPENSE" {
+					payment = false
+					break
+				}
+				if acc.AccountType != "PAYABLE" && isBalanceSheet(acc.AccountType) {
+					payment = true
+				}
+			}
+			if !payment {
+				continue
+			}
+			paid := make(map[string]float64)
+			for _, sp := range txSplits {
+				if acc := accounts[sp.AccountGUID]; acc == nil || acc.AccountType != "PAYABLE" {
+					continue
+				}
+				o := resolveOwner(lotOwners[sp.LotGUID], jobOwners)
+				vendor := names[o.GUID]
+				switch {
+				case o.Type != ownerVendor && o.GUID != "":
+					continue // an employee's expense voucher
+				case vendor == "":
+					vendor = counterparty(sp.Description)
+				}
+				paid[vendor] += sp.Value
+			}
+			share := cardShare(txSplits, "PAYABLE")
+			for vendor, amount := range paid {
+				if amount > 0 {
+					add(txSplits[0].Date, vendor, amount, amount*share)
+				}
+			}
+		}
+	}
+
+	if len(payments) == 0 {
+		source = "expenses paid, by transaction description (the book records no vendor payments)"
+		var expenses []string
+		for guid, acc := range accounts {
+			if acc.AccountType == "EXPENSE" {
+				expenses = append(expenses, guid)
+			}
+		}
+		slices.Sort(expenses)
+		splits, err := s.db.getRelatedSplits(ctx, expenses, endDate, s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		for i := 0; i < len(splits); {
+			tx := splits[i].TxGUID
+			j := i
+			for j < len(splits) && splits[j].TxGUID == tx {
+				j++
+			}
+			txSplits := splits[i:j]
+			i = j
+			var amount float64
+			paid := false
+			for _, sp := range txSplits {
+				acc := accounts[sp.AccountGUID]
+				switch {
+				case acc == nil:
+				case acc.AccountType == "EXPENSE":
+					amount += sp.Value
+				case isBalanceSheet(acc.AccountType) && sp.Value < 0:
+					paid = true
+				}
+			}
+			if paid && amount > 0 {
+				add(txSplits[0].Date, counterparty(txSplits[0].Description), amount, amount*cardShare(txSplits, ""))
+			}
+		}
+	}
+
+	var sorted []*vendorPayments
+	for _, p := range payments {
+		if p.Total >= minAmount {
+			sorted = append(sorted, p)
+		}
+	}
+	if len(sorted) == 0 {
+		return "No vendor payments found.", nil
+	}
+	slices.SortFunc(sorted, func(a, b *vendorPayments) int {
+		return cmp.Or(cmp.Compare(a.Year, b.Year), cmp.Compare(strings.ToLower(a.Vendor), strings.ToLower(b.Vendor)))
+	})
+
+	if format != FormatText {
+		t := table{Headers: []string{"year", "vendor", "payments", "total", "by_card"}}
+		for _, p := range sorted {
+			t.add(fmt.Sprint(p.Year), p.Vendor, fmt.Sprint(p.Payments), fmt.Sprintf("%.2f", p.Total), fmt.Sprintf("%.2f", p.Card))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Payments to vendors from %s, in %s", source, cur.Mnemonic)
+	if minAmount > 0 {
+		fmt.Fprintf(&sb, ", vendors paid at least %.2f in the year", minAmount)
+	}
+	sb.WriteString(":\n")
+	var total float64
+	var vendors int
+	for i, p := range sorted {
+		if i == 0 || p.Year != sorted[i-1].Year {
+			fmt.Fprintf(&sb, "\n%d\n", p.Year)
+			total, vendors = 0, 0
+		}
+		fmt.Fprintf(&sb, "  %-40s %4d payment(s) %12.2f", p.Vendor, p.Payments, p.Total)
+		if p.Card > 0.005 {
+			fmt.Fprintf(&sb, "  (%.2f by card)", p.Card)
+		}
+		sb.WriteString("\n")
+		total += p.Total
+		vendors++
+		if i == len(sorted)-1 || sorted[i+1].Year != p.Year {
+			fmt.Fprintf(&sb, "  Total %d: %.2f to %d vendor(s)\n", p.Year, total, vendors)
+		}
+	}
+	return sb.String(), nil
+}
+
+// counterparty names the other party of a transaction from its description,
+// with its spaces collapsed.
+func counterparty(description string) string {
+	if name := strings.Join(strings.Fields(description), " "); name != "" {
+		return name
+	}
+	return "(no description)"
+}
+
+// getInvoiceIDs returns the IDs of the posted invoices, bills and vouchers
+// by the GUID of their posting transaction.
+func (d *DB) getInvoiceIDs(ctx context.Context) (map[string]string, error) {
+	ids := make(map[string]string)
+	if ok, err := d.hasTable(ctx, "invoices"); err != nil || !ok {
+		return ids, err
+	}
+	if err := d.scanNames(ctx, "invoices", `SELECT post_txn, id FROM invoices WHERE post_txn IS NOT NULL`, ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// statementLine is an invoice, payment or adjustment on a customer
+// statement.
+type statementLine struct {
+	Date      time.Time
+	Reference string
+	Invoiced  float64
+	Paid      float64
+	Balance   float64 // after the line
+}
+
+// customerStatement is the activity of one customer over a period.
+type customerStatement struct {
+	Customer string
+	Opening  float64
+	Invoiced float64 // net of credit notes
+	Paid     float64
+	Adjusted float64 // other changes, such as write-offs
+	Closing  float64
+	Lines    []statementLine
+}
+
+// CustomerStatement reports the activity of each customer between
+// startDate and endDate (today when empty), those whose name contains
+// customer when set: the balance owed at the start, the invoices issued, the
+// payments received and the outstanding balance at the end, from the A/R
+// accounts. Transactions posting income or expenses to A/R are invoices and
+// credit notes, those from asset or liability accounts payments. The
+// customer of an entry is the owner of the invoice or prepayment lot it
+// belongs to, of its job for job invoices, else the transaction's
+// description. The text report of a single customer lists every entry.
+func (s *Service) CustomerStatement(ctx context.Context, customer, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var receivables []string
+	for guid, acc := range accounts {
+		if acc.AccountType == "RECEIVABLE" {
+			receivables = append(receivables, guid)
+		}
+	}
+	if len(receivables) == 0 {
+		return "", fmt.Errorf("the book has no RECEIVABLE (A/R) accounts")
+	}
+	slices.Sort(receivables)
+	lotOwners, err := s.db.getLotOwners(ctx)
+	if err != nil {
+		return "", err
+	}
+	names, jobOwners, err := s.db.getOwnerNames(ctx)
+	if err != nil {
+		return "", err
+	}
+	invoiceIDs, err := s.db.getInvoiceIDs(ctx)
+	if err != nil {
+		return "", err
+	}
+	splits, err := s.db.getRelatedSplits(ctx, receivables, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	statements := make(map[string]*customerStatement)
+	for i := 0; i < len(splits); {
+		tx := splits[i].TxGUID
+		j := i
+		for j < len(splits) && splits[j].TxGUID == tx {
+			j++
+		}
+		txSplits := splits[i:j]
+		i = j
+		invoice, payment := false, false
+		for _, sp := range txSplits {
+			acc := accounts[sp.AccountGUID]
+			switch {
+			case acc == nil || acc.AccountType == "RECEIVABLE":
+			case acc.AccountType == "INCOME" || acc.AccountType == "EXPENSE":
+				invoice = true
+			case isBalanceSheet(acc.AccountType):
+				payment = true
+			}
+		}
+		changes := make(map[string]float64)
+		var order []string
+		for _, sp := range txSplits {
+			if acc := accounts[sp.AccountGUID]; acc == nil || acc.AccountType != "RECEIVABLE" {
+				continue
+			}
+			o := resolveOwner(lotOwners[sp.LotGUID], jobOwners)
+			name := names[o.GUID]
+			switch {
+			case o.GUID != "" && o.Type != ownerCustomer:
+				continue
+			case name == "":
+				name = counterparty(sp.Description)
+			}
+			if _, ok := changes[name]; !ok {
+				order = append(order, name)
+			}
+			changes[name] += sp.Value
+		}
+		date := txSplits[0].Date
+		before := startDate != "" && date.Format("2006-01-02") < startDate
+		for _, name := range order {
+			change := changes[name]
+			st, ok := statements[name]
+			if !ok {
+				st = &customerStatement{Customer: name}
+				statements[name] = st
+			}
+			st.Closing += change
+			if before {
+				st.Opening += change
+				continue
+			}
+			if change == 0 {
+				continue
+			}
+			line := statementLine{Date: date, Reference: txSplits[0].Description, Balance: st.Closing}
+			if id, ok := invoiceIDs[tx]; ok {
+				line.Reference = "Invoice " + id
+			}
+			switch {
+			case invoice:
+				st.Invoiced += change
+				line.Invoiced = change
+			case payment:
+				st.Paid -= change
+				line.Paid = -change
+			default:
+				st.Adjusted += change
+				line.Invoiced = change
+			}
+			st.Lines = append(st.Lines, line)
+		}
+	}
+
+	var sorted []*customerStatement
+	for name, st := range statements {
+		if customer != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(customer)) {
+			continue
+		}
+		if len(st.Lines) == 0 && st.Closing > -0.005 && st.Closing < 0.005 {
+			continue // settled before the period
+		}
+		sorted = append(sorted, st)
+	}
+	if len(sorted) == 0 {
+		if customer != "" {
+			return "", fmt.Errorf("no customer activity matches '%s'", customer)
+		}
+		return "No customer activity found.", nil
+	}
+	slices.SortFunc(sorted, func(a, b *customerStatement) int {
+		return cmp.Compare(strings.ToLower(a.Customer), strings.ToLower(b.Customer))
+	})
+
+	if format != FormatText {
+		t := table{Headers: []string{"customer", "opening_balance", "invoiced", "paid", "adjusted", "closing_balance"}}
+		for _, st := range sorted {
+			t.add(st.Customer, fmt.Sprintf("%.2f", st.Opening), fmt.Sprintf("%.2f", st.Invoiced),
+				fmt.Sprintf("%.2f", st.Paid), fmt.Sprintf("%.2f", st.Adjusted), fmt.Sprintf("%.2f", st.Closing))
+		}
+		return t.render(format), nil
+	}
+
+	period := "up to " + endDate
+	if startDate != "" {
+		period = fmt.Sprintf("from %s to %s", startDate, endDate)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Customer statements (%s), in %s:\n", period, cur.Mnemonic)
+	var outstanding float64
+	for _, st := range sorted {
+		fmt.Fprintf(&sb, "\n  %s\n", st.Customer)
+		if len(sorted) == 1 {
+			fmt.Fprintf(&sb, "    %-10s  %-36s %12s %12s %12.2f\n", "", "Opening balance", "", "", st.Opening)
+			for _, l := range st.Lines {
+				invoiced, paid := "", ""
+				if l.Invoiced != 0 {
+					invoiced = fmt.Sprintf("%.2f", l.Invoiced)
+				}
+				if l.Paid != 0 {
+					paid = fmt.Sprintf("%.2f", l.Paid)
+				}
+				fmt.Fprintf(&sb, "    %-10s  %-36s %12s %12s %12.2f\n", l.Date.Format("2006-01-02"), l.Reference, invoiced, paid, l.Balance)
+			}
+		} else {
+			fmt.Fprintf(&sb, "    Opening balance: %12.2f\n", st.Opening)
+			fmt.Fprintf(&sb, "    Invoiced:        %12.2f\n", st.Invoiced)
+			fmt.Fprintf(&sb, "    Paid:            %12.2f\n", st.Paid)
+			if st.Adjusted != 0 {
+				fmt.Fprintf(&sb, "    Adjusted:        %12.2f\n", st.Adjusted)
+			}
+		}
+		fmt.Fprintf(&sb, "    Outstanding:     %12.2f\n", st.Closing)
+		outstanding += st.Closing
+	}
+	if len(sorted) > 1 {
+		fmt.Fprintf(&sb, "\nTOTAL outstanding: %.2f %s\n", outstanding, cur.Mnemonic)
+	}
+	return sb.String(), nil
+}
+
+// jobPosting is the income or expense an invoice, bill or voucher posted
+// for a job.
+type jobPosting struct {
+	JobGUID     string
+	InvoiceGUID string
+	Income      float64 // credit, negative
+	Expense     float64
+}
+
+// getJobPostings returns what the invoices and bills of jobs, or billed to
+// jobs, posted to income and expense accounts between startDate and
+// endDate.
+func (d *DB) getJobPostings(ctx context.Context, startDate, endDate string) ([]jobPosting, error) {
+	if ok, err := d.hasTable(ctx, "invoices"); err != nil || !ok {
+		return nil, err
+	}
+	job := "CASE WHEN i.owner_type = 3 THEN i.owner_guid END"
+	if ok, err := d.hasColumn(ctx, "invoices", "billto_guid"); err != nil {
+		return nil, err
+	} else if ok {
+		job = "CASE WHEN i.owner_type = 3 THEN i.owner_guid WHEN i.billto_type = 3 THEN i.billto_guid END"
+	}
+	if startDate == "" {
+		startDate = "0001-01-01"
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+job+` AS job_guid, i.guid,
+		       SUM(CASE WHEN a.account_type = 'INCOME' THEN CAST(s.value_num AS REAL) / s.value_denom ELSE 0 END),
+		       SUM(CASE WHEN a.account_type = 'EXPENSE' THEN CAST(s.value_num AS REAL) / s.value_denom ELSE 0 END)
+		FROM invoices i
+		JOIN transactions t ON t.guid = i.post_txn
+		JOIN splits s ON s.tx_guid = t.guid
+		JOIN accounts a ON a.guid = s.account_guid
+		WHERE t.post_date >= ? AND t.post_date <= ?
+		GROUP BY job_guid, i.guid
+		HAVING job_guid IS NOT NULL
+	`, d.dayStart(startDate), d.dayEnd(endDate))
+	if err != nil {
+		return nil, fmt.Errorf("query job postings: %w", err)
+	}
+	defer rows.Close()
+	var postings []jobPosting
+	for rows.Next() {
+		var p jobPosting
+		if err := rows.Scan(&p.JobGUID, &p.InvoiceGUID, &p.Income, &p.Expense); err != nil {
+			return nil, fmt.Errorf("scan job posting: %w", err)
+		}
+		postings = append(postings, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate job postings: %w", err)
+	}
+	return postings, nil
+}
+
+// jobResult is the profitability of one job.
+type jobResult struct {
+	Job      job
+	Owner    string
+	Invoices int
+	Bills    int
+	Revenue  float64
+	Expenses float64
+}
+
+// JobReport aggregates, per job, the revenue of the invoices posted between
+// startDate and endDate (today when empty) and the expenses of the bills
+// and vouchers of the job or billed to it, for the profit and margin of
+// each project. Jobs without postings in the period are left out unless
+// all is set.
+func (s *Service) JobReport(ctx context.Context, startDate, endDate string, all bool, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	jobs, err := s.db.getJobs(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(jobs) == 0 {
+		return "The book has no jobs (Business > Customer or Vendor > New Job in GnuCash).", nil
+	}
+	names, _, err := s.db.getOwnerNames(ctx)
+	if err != nil {
+		return "", err
+	}
+	postings, err := s.db.getJobPostings(ctx, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	results := make(map[string]*jobResult, len(jobs))
+	for _, j := range jobs {
+		results[j.GUID] = &jobResult{Job: j, Owner: names[j.Owner.GUID]}
+	}
+	for _, p := range postings {
+		r, ok := results[p.JobGUID]
+		if !ok {
+			continue
+		}
+		r.Revenue -= p.Income
+		r.Expenses += p.Expense
+		if -p.Income > p.Expense {
+			r.Invoices++
+		} else {
+			r.Bills++
+		}
+	}
+	var sorted []*jobResult
+	for _, j := range jobs {
+		if r := results[j.GUID]; all || r.Invoices+r.Bills > 0 {
+			sorted = append(sorted, r)
+		}
+	}
+	if len(sorted) == 0 {
+		return "No invoices or bills posted for jobs in the period.", nil
+	}
+	slices.SortStableFunc(sorted, func(a, b *jobResult) int {
+		return cmp.Compare(strings.ToLower(a.Owner), strings.ToLower(b.Owner))
+	})
+	margin := func(r *jobResult) string {
+		if r.Revenue == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", (r.Revenue-r.Expenses)/r.Revenue*100)
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"job_id", "job", "owner", "active", "invoices", "bills", "revenue", "expenses", "profit", "margin"}}
+		for _, r := range sorted {
+			t.add(r.Job.ID, r.Job.Name, r.Owner, fmt.Sprint(r.Job.Active), fmt.Sprint(r.Invoices), fmt.Sprint(r.Bills),
+				fmt.Sprintf("%.2f", r.Revenue), fmt.Sprintf("%.2f", r.Expenses), fmt.Sprintf("%.2f", r.Revenue-r.Expenses), margin(r))
+		}
+		return t.render(format), nil
+	}
+
+	period := "up to " + endDate
+	if startDate != "" {
+		period = fmt.Sprintf("from %s to %s", startDate, endDate)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Jobs (%s), in %s:\n", period, cur.Mnemonic)
+	var revenue, expenses float64
+	for i, r := range sorted {
+		if i == 0 || r.Owner != sorted[i-1].Owner {
+			fmt.Fprintf(&sb, "\n  %s\n", r.Owner)
+		}
+		name := r.Job.Name
+		if r.Job.ID != "" {
+			name = r.Job.ID + " " + name
+		}
+		if !r.Job.Active {
+			name += " (closed)"
+		}
+		fmt.Fprintf(&sb, "    %-36s revenue %12.2f  expenses %12.2f  profit %12.2f", name, r.Revenue, r.Expenses, r.Revenue-r.Expenses)
+		if m := margin(r); m != "" {
+			fmt.Fprintf(&sb, "  margin %s%%", m)
+		}
+		sb.WriteString("\n")
+		revenue += r.Revenue
+		expenses += r.Expenses
+	}
+	fmt.Fprintf(&sb, "\nTOTAL: revenue %.2f, expenses %.2f, profit %.2f %s\n", revenue, expenses, revenue-expenses, cur.Mnemonic)
+	return sb.String(), nil
+}
@@ -0,0 +1,230 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxPayoffMonths bounds the payoff projection of a credit card.
+const maxPayoffMonths = 600
+
+// closingDate returns the statement closing date of the month of t for a
+// statement closing on day, the last day of shorter months.
+func closingDate(t time.Time, day int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+	return first.AddDate(0, 0, min(day, last.Day())-1)
+}
+
+// cardActivity is what was charged to and paid on a card in a period.
+type cardActivity struct {
+	Spend, Payments, Credits float64
+}
+
+// cardSummary is the state of one credit card for CreditCardSummary.
+type cardSummary struct {
+	Account            *Account
+	Balance            float64 // owed at the date
+	Statement, Current cardActivity
+	Utilization        float64 // of the credit limit, when given
+	Months             int     // to pay off at the monthly payment, -1 when never
+	Interest           float64 // paid until then
+}
+
+// CreditCardSummary reports on CREDIT accounts, the one named accountName
+// or all of them, at date (today when empty): the balance owed, what was
+// spent, paid and credited over the last statement period and the current
+// one, and the utilization of creditLimit when positive. Statements close
+// on statementDay of each month, the last day of the month when zero.
+// Charges are the amounts the card owes more; payments are amounts paid
+// from other asset or liability accounts, credits the other reductions
+// such as refunds. With a positive monthlyPayment, the payoff of the
+// balance is projected month by month at apr percent of yearly interest.
+func (s *Service) CreditCardSummary(ctx context.Context, accountName, date string, statementDay int, creditLimit, monthlyPayment, apr float64, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if statementDay < 0 || statementDay > 31 {
+		return "", fmt.Errorf("invalid statement_day %d (expected 1 to 31)", statementDay)
+	}
+	if statementDay == 0 {
+		statementDay = 31
+	}
+	if date == "" {
+		date = s.now().Format("2006-01-02")
+	}
+	asOf, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date '%s': %w", date, err)
+	}
+	closed := closingDate(asOf, statementDay)
+	if closed.After(asOf) {
+		closed = closingDate(closed.AddDate(0, 0, 1-closed.Day()).AddDate(0, -1, 0), statementDay)
+	}
+	opened := closingDate(closed.AddDate(0, 0, 1-closed.Day()).AddDate(0, -1, 0), statementDay).AddDate(0, 0, 1)
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var cards []*Account
+	if accountName != "" {
+		acc, err := s.resolveAccount(ctx, accountName)
+		if err != nil {
+			return "", err
+		}
+		if acc.AccountType != "CREDIT" {
+			return "", fmt.Errorf("account '%s' is a %s account, not a CREDIT account", acc.FullName, acc.AccountType)
+		}
+		cards = append(cards, acc)
+	} else {
+		for _, a := range accounts {
+			if a.AccountType == "CREDIT" && !a.Placeholder {
+				cards = append(cards, a)
+			}
+		}
+		if len(cards) == 0 {
+			return "No CREDIT accounts found.", nil
+		}
+		slices.SortFunc(cards, func(a, b *Account) int { return strings.Compare(a.FullName, b.FullName) })
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var summaries []cardSummary
+	for _, card := range cards {
+		splits, err := s.db.getRelatedSplits(ctx, []string{card.GUID}, date, s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		c := cardSummary{Account: card, Months: -1}
+		for i := 0; i < len(splits); {
+			tx, day := splits[i].TxGUID, splits[i].Date
+			var change float64 // negative when the card owes more
+			funded := false
+			for ; i < len(splits) && splits[i].TxGUID == tx; i++ {
+				sp := splits[i]
+				if sp.AccountGUID == card.GUID {
+					change += sp.Value
+				} else if acc := accounts[sp.AccountGUID]; acc != nil && isBalanceSheet(acc.AccountType) {
+					funded = true
+				}
+			}
+			c.Balance -= change
+			var activity *cardActivity
+			switch {
+			case day.After(closed):
+				activity = &c.Current
+			case !day.Before(opened):
+				activity = &c.Statement
+			default:
+				continue
+			}
+			switch {
+			case change < 0:
+				activity.Spend -= change
+			case funded:
+				activity.Payments += change
+			default:
+				activity.Credits += change
+			}
+		}
+		if creditLimit > 0 {
+			c.Utilization = c.Balance / creditLimit * 100
+		}
+		if monthlyPayment > 0 {
+			c.Months, c.Interest = payoff(c.Balance, monthlyPayment, apr)
+		}
+		summaries = append(summaries, c)
+	}
+
+	day := func(t time.Time) string { return t.Format("2006-01-02") }
+	if format != FormatText {
+		t := table{Headers: []string{"account", "balance", "utilization", "statement_start", "statement_end",
+			"statement_spend", "statement_payments", "statement_credits", "current_spend", "current_payments",
+			"current_credits", "payoff_months", "payoff_interest"}}
+		for _, c := range summaries {
+			utilization, months, interest := "", "", ""
+			if creditLimit > 0 {
+				utilization = fmt.Sprintf("%.1f", c.Utilization)
+			}
+			if monthlyPayment > 0 && c.Months >= 0 {
+				months, interest = fmt.Sprint(c.Months), fmt.Sprintf("%.2f", c.Interest)
+			}
+			t.add(c.Account.FullName, fmt.Sprintf("%.2f", c.Balance), utilization, day(opened), day(closed),
+				fmt.Sprintf("%.2f", c.Statement.Spend), fmt.Sprintf("%.2f", c.Statement.Payments), fmt.Sprintf("%.2f", c.Statement.Credits),
+				fmt.Sprintf("%.2f", c.Current.Spend), fmt.Sprintf("%.2f", c.Current.Payments), fmt.Sprintf("%.2f", c.Current.Credits),
+				months, interest)
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Credit cards at %s, in %s:\n", date, cur.Mnemonic)
+	activity := func(label string, a cardActivity) {
+		fmt.Fprintf(&sb, "    %-34s spent %.2f, paid %.2f", label, a.Spend, a.Payments)
+		if a.Credits != 0 {
+			fmt.Fprintf(&sb, ", credited %.2f", a.Credits)
+		}
+		sb.WriteString("\n")
+	}
+	for _, c := range summaries {
+		fmt.Fprintf(&sb, "\n  %s\n", c.Account.FullName)
+		fmt.Fprintf(&sb, "    Balance owed: %.2f", c.Balance)
+		if creditLimit > 0 {
+			fmt.Fprintf(&sb, " (%.1f%% of the %.2f limit)", c.Utilization, creditLimit)
+		}
+		sb.WriteString("\n")
+		activity(fmt.Sprintf("Statement %s to %s:", day(opened), day(closed)), c.Statement)
+		activity(fmt.Sprintf("Since %s:", day(closed)), c.Current)
+		switch {
+		case monthlyPayment <= 0:
+		case c.Balance <= 0.005:
+			sb.WriteString("    Nothing to pay off\n")
+		case c.Months < 0:
+			fmt.Fprintf(&sb, "    A payment of %.2f a month does not cover the interest: the balance is never paid off\n", monthlyPayment)
+		default:
+			fmt.Fprintf(&sb, "    Paid off in %d month(s), by %s, at %.2f a month and %.2f%% APR, with %.2f of interest\n",
+				c.Months, asOf.AddDate(0, c.Months, 0).Format("2006-01"), monthlyPayment, apr, c.Interest)
+		}
+	}
+	return sb.String(), nil
+}
+
+// isBalanceSheet reports whether accounts of type accountType hold assets
+// or liabilities, rather than income, expenses, equity or trading.
+func isBalanceSheet(accountType string) bool {
+	switch accountType {
+	case "INCOME", "EXPENSE", "EQUITY", "TRADING", "ROOT":
+		return false
+	}
+	return true
+}
+
+// payoff projects the monthly payments of balance at apr percent of yearly
+// interest, charged monthly before each payment. It returns the number of
+// months and the interest paid, or -1 months when the payment does not
+// cover the interest.
+func payoff(balance, payment, apr float64) (months int, interest float64) {
+	rate := apr / 100 / 12
+	for balance > 0.005 {
+		if months == maxPayoffMonths {
+			return -1, 0
+		}
+		charge := math.Round(balance*rate*100) / 100
+		if charge >= payment {
+			return -1, 0
+		}
+		interest += charge
+		balance += charge - payment
+		months++
+	}
+	return months, interest
+}
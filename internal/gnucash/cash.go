@@ -0,0 +1,142 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dailyChange is the net amount posted to an account on one day.
+type dailyChange struct {
+	Date   time.Time
+	Amount float64
+}
+
+// GetDailyChanges returns the net split value per day for an account up to
+// endDate, oldest first.
+func (d *DB) GetDailyChanges(ctx context.Context, accountGUID, endDate string) ([]dailyChange, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT t.post_date, SUM(CAST(s.value_num AS REAL) / s.value_denom)
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE s.account_guid = ? AND t.post_date <= ?
+		GROUP BY t.post_date
+		ORDER BY t.post_date
+	`, accountGUID, d.dayEnd(endDate))
+	if err != nil {
+		return nil, fmt.Errorf("query daily changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []dailyChange
+	for rows.Next() {
+		var postDate string
+		var amount float64
+		if err := rows.Scan(&postDate, &amount); err != nil {
+			return nil, fmt.Errorf("scan daily change: %w", err)
+		}
+		// Days of the book's time zone, which posting times may share.
+		t, _ := d.parseDate(postDate)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		if n := len(changes); n > 0 && changes[n-1].Date.Equal(day) {
+			changes[n-1].Amount += amount
+			continue
+		}
+		changes = append(changes, dailyChange{Date: day, Amount: amount})
+	}
+	return changes, rows.Err()
+}
+
+// IdleCash reports cash sitting above buffer in BANK and CASH accounts for
+// stretches longer than minDays within the period, and the interest it could
+// have earned at annualRate percent.
+func (s *Service) IdleCash(ctx context.Context, startDate, endDate string, buffer float64, minDays int, annualRate float64) (string, error) {
+	now := s.now()
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	if startDate == "" {
+		startDate = now.AddDate(-1, 0, 0).Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid start_date '%s': %w", startDate, err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid end_date '%s': %w", endDate, err)
+	}
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var cashAccounts []*Account
+	for _, acc := range accounts {
+		if acc.AccountType == "BANK" || acc.AccountType == "CASH" {
+			cashAccounts = append(cashAccounts, acc)
+		}
+	}
+	slices.SortFunc(cashAccounts, func(a, b *Account) int { return cmp.Compare(a.FullName, b.FullName) })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Idle cash above %.2f for more than %d days (%s to %s, %.2f%% annual rate):\n\n",
+		buffer, minDays, startDate, endDate, annualRate)
+
+	var totalInterest float64
+	found := 0
+	for _, acc := range cashAccounts {
+		changes, err := s.db.GetDailyChanges(ctx, acc.GUID, endDate)
+		if err != nil {
+			return "", err
+		}
+
+		// Walk every day of the period, tracking stretches above the buffer.
+		var balance, idleDays, excessDays, streakExcess float64
+		var streak, longest int
+		i := 0
+		closeStreak := func() {
+			if streak > minDays {
+				idleDays += float64(streak)
+				excessDays += streakExcess
+			}
+			longest = max(longest, streak)
+			streak, streakExcess = 0, 0
+		}
+		for day := start.AddDate(0, 0, -1); !day.After(end); day = day.AddDate(0, 0, 1) {
+			for i < len(changes) && !changes[i].Date.After(day) {
+				balance += changes[i].Amount
+				i++
+			}
+			if day.Before(start) {
+				continue // opening balance only
+			}
+			if balance > buffer {
+				streak++
+				streakExcess += balance - buffer
+			} else {
+				closeStreak()
+			}
+		}
+		closeStreak()
+
+		if idleDays == 0 {
+			continue
+		}
+		found++
+		interest := excessDays * annualRate / 100 / 365
+		totalInterest += interest
+		fmt.Fprintf(&sb, "  %s\n", acc.FullName)
+		fmt.Fprintf(&sb, "    current balance %.2f, %d idle days (longest stretch %d), average excess %.2f, foregone interest %.2f\n",
+			balance, int(idleDays), longest, excessDays/idleDays, interest)
+	}
+
+	if found == 0 {
+		return fmt.Sprintf("No idle cash above %.2f for more than %d days between %s and %s.", buffer, minDays, startDate, endDate), nil
+	}
+	fmt.Fprintf(&sb, "\n  Total foregone interest: %.2f\n", totalInterest)
+	return sb.String(), nil
+}
@@ -0,0 +1,173 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// categorizedSplit is a split of an income or expense account with the
+// description of its transaction.
+type categorizedSplit struct {
+	AccountGUID string
+	Description string
+	PostDate    time.Time
+}
+
+// getCategorizedSplits returns every income and expense split with the
+// description of its transaction.
+func (d *DB) getCategorizedSplits(ctx context.Context) ([]categorizedSplit, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT s.account_guid, COALESCE(t.description, ''), t.post_date
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		WHERE a.account_type IN ('EXPENSE', 'INCOME')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query categorized splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []categorizedSplit
+	for rows.Next() {
+		var sp categorizedSplit
+		var postDate string
+		if err := rows.Scan(&sp.AccountGUID, &sp.Description, &postDate); err != nil {
+			return nil, fmt.Errorf("scan categorized split: %w", err)
+		}
+		sp.PostDate, _ = d.parseDate(postDate)
+		splits = append(splits, sp)
+	}
+	return splits, rows.Err()
+}
+
+// descriptionTokens splits a description into lowercase words, leaving out
+// numbers and single characters, which are mostly dates and references.
+func descriptionTokens(description string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) < 2 || strings.IndexFunc(word, unicode.IsLetter) < 0 || slices.Contains(tokens, word) {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// categorySuggestion is an account scored for a description.
+type categorySuggestion struct {
+	Account     *Account
+	Probability float64
+	Matches     int       // past transactions sharing a word with the description
+	Last        time.Time // most recent of them
+}
+
+// suggestCategories scores accounts for description the way GnuCash's
+// Bayesian import matcher does: each word of the description seen in the
+// history gives every account it was used with the probability
+// count(word, account) / count(word), and an account's probabilities combine
+// as P / (P + Q), P being their product and Q that of their complements.
+// Words an account never saw do not count against it.
+func suggestCategories(description string, history []categorizedSplit, accounts map[string]*Account) []categorySuggestion {
+	tokens := descriptionTokens(description)
+	wordTotals := make(map[string]int)
+	wordCounts := make(map[string]map[string]int) // word -> account -> count
+	type stats struct {
+		matches int
+		last    time.Time
+	}
+	seen := make(map[string]*stats)
+	for _, sp := range history {
+		shared := false
+		for _, word := range descriptionTokens(sp.Description) {
+			if !slices.Contains(tokens, word) {
+				continue
+			}
+			shared = true
+			wordTotals[word]++
+			if wordCounts[word] == nil {
+				wordCounts[word] = make(map[string]int)
+			}
+			wordCounts[word][sp.AccountGUID]++
+		}
+		if !shared {
+			continue
+		}
+		st := seen[sp.AccountGUID]
+		if st == nil {
+			st = &stats{}
+			seen[sp.AccountGUID] = st
+		}
+		st.matches++
+		if sp.PostDate.After(st.last) {
+			st.last = sp.PostDate
+		}
+	}
+
+	var suggestions []categorySuggestion
+	for guid, st := range seen {
+		acc, ok := accounts[guid]
+		if !ok {
+			continue
+		}
+		product, complement := 1.0, 1.0
+		for word, byAccount := range wordCounts {
+			if n := byAccount[guid]; n > 0 {
+				p := float64(n) / float64(wordTotals[word])
+				product *= p
+				complement *= 1 - p
+			}
+		}
+		suggestions = append(suggestions, categorySuggestion{
+			Account:     acc,
+			Probability: product / (product + complement),
+			Matches:     st.matches,
+			Last:        st.last,
+		})
+	}
+	slices.SortFunc(suggestions, func(a, b categorySuggestion) int {
+		return cmp.Or(cmp.Compare(b.Probability, a.Probability), cmp.Compare(b.Matches, a.Matches),
+			cmp.Compare(a.Account.FullName, b.Account.FullName))
+	})
+	return suggestions
+}
+
+// SuggestCategory suggests the income or expense accounts a transaction
+// described as description most likely belongs to, from the transactions
+// already in the book. limit caps the number of suggestions (default 3).
+func (s *Service) SuggestCategory(ctx context.Context, description string, limit int) (string, error) {
+	description = strings.TrimSpace(description)
+	if len(descriptionTokens(description)) == 0 {
+		return "", fmt.Errorf("description must contain at least one word")
+	}
+	if limit <= 0 {
+		limit = 3
+	}
+	history, err := s.db.getCategorizedSplits(ctx)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	suggestions := suggestCategories(description, history, accounts)
+	if len(suggestions) == 0 {
+		return fmt.Sprintf("No past transaction resembles '%s'; no category to suggest.\n", description), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Suggested categories for '%s':\n\n", description)
+	for i, sg := range suggestions[:min(limit, len(suggestions))] {
+		fmt.Fprintf(&sb, "  %d. %s  %.0f%%  (%d similar transaction(s), last %s)\n",
+			i+1, sg.Account.FullName, sg.Probability*100, sg.Matches, sg.Last.Format("2006-01-02"))
+	}
+	return sb.String(), nil
+}
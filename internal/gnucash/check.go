@@ -0,0 +1,157 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// bookStats counts the main objects of a book.
+type bookStats struct {
+	Accounts, Transactions, Splits, Prices int
+	FirstDate, LastDate                    string
+}
+
+func (d *DB) getBookStats(ctx context.Context) (bookStats, error) {
+	var st bookStats
+	err := d.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM accounts),
+			(SELECT COUNT(*) FROM transactions),
+			(SELECT COUNT(*) FROM splits),
+			(SELECT COUNT(*) FROM prices),
+			COALESCE((SELECT MIN(post_date) FROM transactions), ''),
+			COALESCE((SELECT MAX(post_date) FROM transactions), '')
+	`).Scan(&st.Accounts, &st.Transactions, &st.Splits, &st.Prices, &st.FirstDate, &st.LastDate)
+	if err != nil {
+		return st, fmt.Errorf("query book statistics: %w", err)
+	}
+	st.FirstDate, st.LastDate = d.day(st.FirstDate), d.day(st.LastDate)
+	return st, nil
+}
+
+// unbalancedTransaction is a transaction whose splits do not sum to zero.
+type unbalancedTransaction struct {
+	GUID        string
+	Date        string // YYYY-MM-DD
+	Description string
+	Imbalance   float64
+}
+
+// getUnbalancedTransactions returns the transactions whose splits do not
+// balance, posted between startDate and endDate when they are set.
+func (d *DB) getUnbalancedTransactions(ctx context.Context, startDate, endDate string) ([]unbalancedTransaction, error) {
+	query := `
+		SELECT t.guid, t.post_date, COALESCE(t.description, ''),
+		       SUM(CAST(s.value_num AS REAL) / s.value_denom)
+		FROM transactions t
+		JOIN splits s ON s.tx_guid = t.guid
+		WHERE 1 = 1`
+	var args []any
+	if startDate != "" {
+		query += " AND t.post_date >= ?"
+		args = append(args, d.dayStart(startDate))
+	}
+	if endDate != "" {
+		query += " AND t.post_date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += `
+		GROUP BY t.guid
+		HAVING ABS(SUM(CAST(s.value_num AS REAL) / s.value_denom)) > 0.000001
+		ORDER BY t.post_date, t.guid`
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query unbalanced transactions: %w", err)
+	}
+	defer rows.Close()
+	var unbalanced []unbalancedTransaction
+	for rows.Next() {
+		var u unbalancedTransaction
+		if err := rows.Scan(&u.GUID, &u.Date, &u.Description, &u.Imbalance); err != nil {
+			return nil, fmt.Errorf("scan unbalanced transaction: %w", err)
+		}
+		u.Date = d.day(u.Date)
+		u.Description = d.redacted(u.Description)
+		unbalanced = append(unbalanced, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate unbalanced transactions: %w", err)
+	}
+	return unbalanced, nil
+}
+
+// getBookProblems looks for inconsistencies GnuCash would not produce:
+// transactions whose splits do not balance, splits pointing to missing
+// accounts or transactions, and accounts whose parent is missing.
+func (d *DB) getBookProblems(ctx context.Context) ([]string, error) {
+	var problems []string
+	unbalanced, err := d.getUnbalancedTransactions(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range unbalanced {
+		problems = append(problems, fmt.Sprintf("transaction %s (%s %s) is unbalanced by %.2f", u.GUID, u.Date, u.Description, u.Imbalance))
+	}
+
+	var orphans struct{ accounts, transactions, parents int }
+	err = d.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM splits WHERE account_guid NOT IN (SELECT guid FROM accounts)),
+			(SELECT COUNT(*) FROM splits WHERE tx_guid NOT IN (SELECT guid FROM transactions)),
+			(SELECT COUNT(*) FROM accounts WHERE parent_guid IS NOT NULL AND parent_guid != ''
+			   AND parent_guid NOT IN (SELECT guid FROM accounts))
+	`).Scan(&orphans.accounts, &orphans.transactions, &orphans.parents)
+	if err != nil {
+		return nil, fmt.Errorf("query orphaned records: %w", err)
+	}
+	if orphans.accounts > 0 {
+		problems = append(problems, fmt.Sprintf("%d split(s) belong to a missing account", orphans.accounts))
+	}
+	if orphans.transactions > 0 {
+		problems = append(problems, fmt.Sprintf("%d split(s) belong to a missing transaction", orphans.transactions))
+	}
+	if orphans.parents > 0 {
+		problems = append(problems, fmt.Sprintf("%d account(s) have a missing parent account", orphans.parents))
+	}
+	return problems, nil
+}
+
+// CheckBook reports what the book contains, whether GnuCash has it open, and
+// the inconsistencies found in it. It also returns the number of problems,
+// zero for a healthy book.
+func (s *Service) CheckBook(ctx context.Context) (string, int, error) {
+	st, err := s.db.getBookStats(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	holder, err := s.db.currentLock(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	problems, err := s.db.getBookProblems(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Book: %d account(s), %d transaction(s), %d split(s), %d price(s)", st.Accounts, st.Transactions, st.Splits, st.Prices)
+	if st.FirstDate != "" {
+		fmt.Fprintf(&sb, ", from %s to %s", st.FirstDate, st.LastDate)
+	}
+	sb.WriteString("\n")
+	if holder != "" {
+		fmt.Fprintf(&sb, "Open in GnuCash: locked by %s; write mode refuses to write until it is closed\n", holder)
+	} else {
+		sb.WriteString("Open in GnuCash: no\n")
+	}
+	if len(problems) == 0 {
+		sb.WriteString("Problems: none\n")
+		return sb.String(), 0, nil
+	}
+	fmt.Fprintf(&sb, "Problems (%d):\n", len(problems))
+	for _, p := range problems {
+		fmt.Fprintf(&sb, "- %s\n", p)
+	}
+	return sb.String(), len(problems), nil
+}
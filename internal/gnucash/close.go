@@ -0,0 +1,301 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Status of a month-end close check.
+const (
+	checkOK        = "ok"
+	checkAttention = "attention"
+	checkSkipped   = "skipped"
+)
+
+// closeCheck is one item of the month-end close checklist.
+type closeCheck struct {
+	Name    string
+	Status  string
+	Count   int
+	Summary string
+	Details []string
+}
+
+// unreconciledAccount counts the unreconciled splits of an account.
+type unreconciledAccount struct {
+	AccountGUID string
+	Splits      int
+	Value       float64
+}
+
+// getUnreconciled returns, per BANK and CREDIT account, the splits posted
+// between startDate and endDate that are neither cleared nor reconciled. It
+// reports false when the book does not record reconcile states.
+func (d *DB) getUnreconciled(ctx context.Context, startDate, endDate string) ([]unreconciledAccount, bool, error) {
+	if ok, err := d.hasColumn(ctx, "splits", "reconcile_state"); err != nil || !ok {
+		return nil, false, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT s.account_guid, COUNT(*), SUM(CAST(s.value_num AS REAL) / s.value_denom)
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		WHERE a.account_type IN ('BANK', 'CREDIT') AND s.reconcile_state = 'n'
+		  AND t.post_date >= ? AND t.post_date <= ?
+		GROUP BY s.account_guid
+	`, d.dayStart(startDate), d.dayEnd(endDate))
+	if err != nil {
+		return nil, true, fmt.Errorf("query unreconciled splits: %w", err)
+	}
+	defer rows.Close()
+	var accounts []unreconciledAccount
+	for rows.Next() {
+		var u unreconciledAccount
+		if err := rows.Scan(&u.AccountGUID, &u.Splits, &u.Value); err != nil {
+			return nil, true, fmt.Errorf("scan unreconciled splits: %w", err)
+		}
+		accounts = append(accounts, u)
+	}
+	return accounts, true, rows.Err()
+}
+
+// scheduledTransaction is a GnuCash scheduled transaction.
+type scheduledTransaction struct {
+	Name        string
+	Enabled     bool
+	Start, End  string // YYYY-MM-DD, End "" when open-ended
+	LastOccur   string // YYYY-MM-DD of the last instance created, "" for none
+	Occurrences int    // total number of occurrences, 0 for unlimited
+	Recurrence  *jsonRecurrence
+}
+
+// occurrence returns the date of occurrence i of sx.
+func (sx scheduledTransaction) occurrence(i int) (time.Time, error) {
+	first, _, err := recurrencePeriod(sx.Recurrence, i)
+	if err != nil {
+		return first, err
+	}
+	if sx.Recurrence.PeriodType == "end of month" {
+		first = time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	}
+	return first, nil
+}
+
+// gDate reads a date GnuCash stores as YYYYMMDD, or as a timestamp.
+func (d *DB) gDate(s string) string {
+	if s == "" {
+		return ""
+	}
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return d.day(s)
+}
+
+// getScheduledTransactions returns the scheduled transactions of the book
+// with their recurrence, none when the book has never had any.
+func (d *DB) getScheduledTransactions(ctx context.Context) ([]scheduledTransaction, error) {
+	for _, table := range []string{"schedxactions", "recurrences"} {
+		if ok, err := d.hasTable(ctx, table); err != nil || !ok {
+			return nil, err
+		}
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT COALESCE(x.name, ''), x.enabled, COALESCE(x.start_date, ''), COALESCE(x.end_date, ''),
+		       COALESCE(x.last_occur, ''), COALESCE(x.num_occur, 0),
+		       r.recurrence_mult, r.recurrence_period_type, r.recurrence_period_start
+		FROM schedxactions x
+		JOIN recurrences r ON r.obj_guid = x.guid
+		ORDER BY x.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query scheduled transactions: %w", err)
+	}
+	defer rows.Close()
+	var scheduled []scheduledTransaction
+	for rows.Next() {
+		var sx scheduledTransaction
+		var r jsonRecurrence
+		if err := rows.Scan(&sx.Name, &sx.Enabled, &sx.Start, &sx.End, &sx.LastOccur, &sx.Occurrences,
+			&r.Multiplier, &r.PeriodType, &r.Start); err != nil {
+			return nil, fmt.Errorf("scan scheduled transaction: %w", err)
+		}
+		sx.Start, sx.End, sx.LastOccur, r.Start = d.gDate(sx.Start), d.gDate(sx.End), d.gDate(sx.LastOccur), d.gDate(r.Start)
+		sx.Recurrence = &r
+		scheduled = append(scheduled, sx)
+	}
+	return scheduled, rows.Err()
+}
+
+// MonthClose runs the month-end close checks for month (YYYY-MM, the
+// previous month when empty): transactions that do not balance, splits of
+// bank and credit card accounts left unreconciled, transactions left in
+// Imbalance or Orphan accounts, occurrences of scheduled transactions due in
+// the month but not created yet, and expense accounts over their GnuCash
+// budget. Checks the book has nothing to run on are skipped.
+func (s *Service) MonthClose(ctx context.Context, month, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if month == "" {
+		month = s.now().AddDate(0, 0, 1-s.now().Day()).AddDate(0, -1, 0).Format("2006-01")
+	}
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return "", fmt.Errorf("invalid month '%s' (expected YYYY-MM)", month)
+	}
+	end := start.AddDate(0, 1, -1)
+	startDate, endDate := start.Format("2006-01-02"), end.Format("2006-01-02")
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	name := func(guid string) string {
+		if acc := accounts[guid]; acc != nil {
+			return acc.FullName
+		}
+		return guid
+	}
+
+	var checks []closeCheck
+
+	unbalanced, err := s.db.getUnbalancedTransactions(ctx, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	c := closeCheck{Name: "Unbalanced entries", Count: len(unbalanced)}
+	for _, u := range unbalanced {
+		c.Details = append(c.Details, fmt.Sprintf("%s %s: off by %.2f (transaction %s)", u.Date, u.Description, u.Imbalance, u.GUID))
+	}
+	checks = append(checks, c)
+
+	unreconciled, ok, err := s.db.getUnreconciled(ctx, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	c = closeCheck{Name: "Unreconciled splits"}
+	if !ok {
+		c.Status, c.Summary = checkSkipped, "the book does not record reconcile states"
+	}
+	slices.SortFunc(unreconciled, func(a, b unreconciledAccount) int { return strings.Compare(name(a.AccountGUID), name(b.AccountGUID)) })
+	for _, u := range unreconciled {
+		c.Count += u.Splits
+		c.Details = append(c.Details, fmt.Sprintf("%s: %d split(s), %.2f", name(u.AccountGUID), u.Splits, u.Value))
+	}
+	if c.Count > 0 {
+		c.Summary = fmt.Sprintf("%d in %d account(s)", c.Count, len(unreconciled))
+	}
+	checks = append(checks, c)
+
+	found, err := s.uncategorized(ctx, accounts, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	c = closeCheck{Name: "Uncategorized transactions", Count: len(found)}
+	for _, u := range found {
+		c.Details = append(c.Details, fmt.Sprintf("%s %s: %.2f in %s", u.Date, u.Description, u.Value, u.Account))
+	}
+	checks = append(checks, c)
+
+	scheduled, err := s.db.getScheduledTransactions(ctx)
+	if err != nil {
+		return "", err
+	}
+	c = closeCheck{Name: "Missing scheduled transactions"}
+	if len(scheduled) == 0 {
+		c.Status, c.Summary = checkSkipped, "the book has no scheduled transactions"
+	}
+	for _, sx := range scheduled {
+		if !sx.Enabled {
+			continue
+		}
+		for i := 0; i < 5000 && (sx.Occurrences == 0 || i < sx.Occurrences); i++ {
+			at, err := sx.occurrence(i)
+			if err != nil {
+				c.Details = append(c.Details, fmt.Sprintf("%s: %v", sx.Name, err))
+				break
+			}
+			day := at.Format("2006-01-02")
+			if day > endDate || (sx.End != "" && day > sx.End) {
+				break
+			}
+			if day >= startDate && day >= sx.Start && day > sx.LastOccur {
+				c.Count++
+				c.Details = append(c.Details, fmt.Sprintf("%s due %s", sx.Name, day))
+			}
+		}
+	}
+	checks = append(checks, c)
+
+	c = closeCheck{Name: "Budget overruns"}
+	budget, err := s.checkBudget(ctx, "", end)
+	if err != nil {
+		return "", err
+	}
+	if budget.Budget == nil {
+		c.Status, c.Summary = checkSkipped, budget.Missing
+	}
+	for _, a := range budget.Alerts {
+		if !a.over() && budget.End.After(end) {
+			continue // heading over a budget longer than the month
+		}
+		c.Count++
+		c.Details = append(c.Details, fmt.Sprintf("%s: %.2f spent of %.2f", a.Account, a.Actual, a.Budget))
+	}
+	if budget.Budget != nil {
+		c.Summary = fmt.Sprintf("%d of %d account(s) in budget '%s'", c.Count, budget.Budgeted, budget.Budget.Name)
+	}
+	checks = append(checks, c)
+
+	attention := 0
+	for i := range checks {
+		c := &checks[i]
+		if c.Status == "" {
+			c.Status = checkOK
+			if c.Count > 0 || len(c.Details) > 0 {
+				c.Status = checkAttention
+			}
+		}
+		if c.Status == checkAttention {
+			attention++
+		}
+		if c.Summary == "" {
+			c.Summary = "none"
+			if c.Count > 0 {
+				c.Summary = fmt.Sprint(c.Count)
+			}
+		}
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"check", "status", "count", "details"}}
+		for _, c := range checks {
+			t.add(c.Name, c.Status, fmt.Sprint(c.Count), strings.Join(c.Details, "; "))
+		}
+		return t.render(format), nil
+	}
+
+	marks := map[string]string{checkOK: "[x]", checkAttention: "[ ]", checkSkipped: "[-]"}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Month-end close for %s (%s to %s), amounts in %s:\n\n", month, startDate, endDate, cur.Mnemonic)
+	for _, c := range checks {
+		fmt.Fprintf(&sb, "%s %s: %s\n", marks[c.Status], c.Name, c.Summary)
+		for _, d := range c.Details {
+			fmt.Fprintf(&sb, "      %s\n", d)
+		}
+	}
+	if attention == 0 {
+		sb.WriteString("\nAll checks passed; the month is ready to close.\n")
+	} else {
+		fmt.Fprintf(&sb, "\n%d of %d check(s) need attention.\n", attention, len(checks))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,39 @@
+package gnucash
+
+import "context"
+
+// WithClosingEntries makes aggregates include the closing transactions of
+// GnuCash's Close Book, left out by default.
+func WithClosingEntries() Option {
+	return func(s *Service) { s.closingEntries = true }
+}
+
+// closingFilter returns the SQL condition leaving the closing transactions
+// of GnuCash's Close Book out of an aggregate over transactions t, to
+// append to its WHERE clause, or "" when include is set. Close Book
+// moves the income and expense balances to equity with transactions it
+// marks with the "book_closing" slot, described "Closing Entries" unless
+// the user changed it; such transactions would cancel the income and
+// expenses of the period they close.
+func (d *DB) closingFilter(ctx context.Context, include bool) (string, error) {
+	if include {
+		return "", nil
+	}
+	closing, err := d.closingCondition(ctx)
+	if err != nil {
+		return "", err
+	}
+	return " AND NOT " + closing, nil
+}
+
+// closingCondition returns the SQL condition true for the closing
+// transactions t (see closingFilter).
+func (d *DB) closingCondition(ctx context.Context) (string, error) {
+	condition := `LOWER(COALESCE(t.description, '')) LIKE 'closing entries%'`
+	if ok, err := d.hasTable(ctx, "slots"); err != nil {
+		return "", err
+	} else if ok {
+		condition += ` OR EXISTS (SELECT 1 FROM slots c WHERE c.obj_guid = t.guid AND c.name = 'book_closing' AND c.int64_val != 0)`
+	}
+	return "(" + condition + ")", nil
+}
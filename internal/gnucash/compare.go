@@ -0,0 +1,180 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// periodLine is one line of PeriodDiff: a total in the base period and in
+// the compared one.
+type periodLine struct {
+	Label string
+	Type  string // INCOME or EXPENSE for categories, "" for totals
+	Base  float64
+	Value float64
+}
+
+// change returns the percentage change of l from the base period, and false
+// when the base is zero.
+func (l periodLine) change() (float64, bool) {
+	if cents(l.Base) == 0 {
+		return 0, false
+	}
+	return (l.Value - l.Base) / math.Abs(l.Base) * 100, true
+}
+
+// PeriodDiff compares the income, expenses and net of the period from
+// startDate to endDate with those of the base period from compareStartDate
+// to compareEndDate, and lists the limit income and expense categories that
+// changed the most between them. Categories are accounts, or the configured
+// category groups when grouping is GroupByGroup. Income is counted
+// positive. When the call asks for inflation-adjusted amounts, each period is
+// restated at the price level of its middle.
+func (s *Service) PeriodDiff(ctx context.Context, compareStartDate, compareEndDate, startDate, endDate, grouping string, limit int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range []struct{ name, value string }{
+		{"compare_start_date", compareStartDate}, {"compare_end_date", compareEndDate},
+		{"start_date", startDate}, {"end_date", endDate},
+	} {
+		if d.value == "" {
+			return "", fmt.Errorf("%s is required", d.name)
+		}
+		if _, err := time.Parse("2006-01-02", d.value); err != nil {
+			return "", fmt.Errorf("invalid %s '%s': %w", d.name, d.value, err)
+		}
+	}
+	if compareStartDate > compareEndDate || startDate > endDate {
+		return "", fmt.Errorf("each period must start before it ends")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	groupOf, err := s.groupLabeler(ctx, grouping)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	infl, err := s.inflation()
+	if err != nil {
+		return "", err
+	}
+
+	income := periodLine{Label: "Income"}
+	expenses := periodLine{Label: "Expenses"}
+	categories := make(map[string]*periodLine)
+	for i, p := range [][2]string{{compareStartDate, compareEndDate}, {startDate, endDate}} {
+		totals, err := s.db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, p[0], p[1], s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		start, _ := time.Parse("2006-01-02", p[0])
+		end, _ := time.Parse("2006-01-02", p[1])
+		middle := start.Add(end.Sub(start) / 2)
+		for guid, total := range totals {
+			total = infl.restate(total, middle)
+			acc, ok := accounts[guid]
+			if !ok {
+				continue
+			}
+			line := &expenses
+			if acc.AccountType == "INCOME" {
+				// Income is credited, so its splits are negative.
+				total, line = -total, &income
+			}
+			label := acc.FullName
+			if groupOf != nil {
+				label = groupOf(guid)
+			}
+			key := acc.AccountType + "\x00" + label
+			c, ok := categories[key]
+			if !ok {
+				c = &periodLine{Label: label, Type: acc.AccountType}
+				categories[key] = c
+			}
+			if i == 0 {
+				line.Base += total
+				c.Base += total
+			} else {
+				line.Value += total
+				c.Value += total
+			}
+		}
+	}
+	net := periodLine{Label: "Net", Base: income.Base - expenses.Base, Value: income.Value - expenses.Value}
+
+	var changed []periodLine
+	for _, c := range categories {
+		if cents(c.Value-c.Base) != 0 {
+			changed = append(changed, *c)
+		}
+	}
+	slices.SortFunc(changed, func(a, b periodLine) int {
+		return cmp.Or(cmp.Compare(math.Abs(b.Value-b.Base), math.Abs(a.Value-a.Base)), cmp.Compare(a.Label, b.Label))
+	})
+	if len(changed) > limit {
+		changed = changed[:limit]
+	}
+
+	pct := func(l periodLine) string {
+		if p, ok := l.change(); ok {
+			return fmt.Sprintf("%.1f", p)
+		}
+		return ""
+	}
+	if format != FormatText {
+		t := table{Headers: []string{"line", "type", "base", "period", "change", "change_pct"}}
+		for _, l := range append([]periodLine{income, expenses, net}, changed...) {
+			t.add(l.Label, l.Type, fmt.Sprintf("%.2f", l.Base), fmt.Sprintf("%.2f", l.Value), fmt.Sprintf("%.2f", l.Value-l.Base), pct(l))
+		}
+		return t.render(format), nil
+	}
+
+	days := func(start, end string) int {
+		s, _ := time.Parse("2006-01-02", start)
+		e, _ := time.Parse("2006-01-02", end)
+		return int(e.Sub(s).Hours()/24) + 1
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s to %s compared with %s to %s, in %s:\n", startDate, endDate, compareStartDate, compareEndDate, cur.Mnemonic)
+	if a, b := days(compareStartDate, compareEndDate), days(startDate, endDate); a != b {
+		fmt.Fprintf(&sb, "(the periods differ in length: %d days against %d)\n", b, a)
+	}
+	sb.WriteString(infl.note())
+	line := func(l periodLine) {
+		fmt.Fprintf(&sb, "  %-40s %12.2f %12.2f %+12.2f", l.Label, l.Base, l.Value, l.Value-l.Base)
+		if p, ok := l.change(); ok {
+			fmt.Fprintf(&sb, "  %+.0f%%", p)
+		} else if cents(l.Value) != 0 {
+			sb.WriteString("  new")
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "\n  %-40s %12s %12s %12s\n", "", "Base", "Period", "Change")
+	line(income)
+	line(expenses)
+	line(net)
+	if len(changed) == 0 {
+		sb.WriteString("\nNo category changed.\n")
+		return sb.String(), nil
+	}
+	sb.WriteString("\nLargest category changes:\n")
+	for _, c := range changed {
+		line(c)
+	}
+	return sb.String(), nil
+}
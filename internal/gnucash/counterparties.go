@@ -0,0 +1,161 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// counterpartyFlow is the money an account received from and sent to one
+// other account in a period.
+type counterpartyFlow struct {
+	Account *Account
+	Inflow  float64
+	Outflow float64 // positive
+	Splits  int
+}
+
+// CounterpartySummary groups the money that came in and went out of
+// accountName (with its sub-accounts) between startDate (the first day of
+// the current month when empty) and endDate (today when empty) by the
+// account on the other side: the income, expense, transfer and other
+// accounts it came from and went to. Each split of the other accounts
+// counts for its own value, so that a paycheck splitting gross salary into
+// net pay and withholdings shows both. Transfers between the sub-accounts
+// are left out. Counterparties beyond limit, by volume, are folded into
+// "Other".
+func (s *Service) CounterpartySummary(ctx context.Context, accountName, startDate, endDate string, limit int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	if startDate == "" {
+		startDate = now.AddDate(0, 0, 1-now.Day()).Format("2006-01-02")
+	}
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	account, err := s.resolveAccount(ctx, accountName)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	guids := descendantGUIDs(account)
+	own := make(map[string]bool, len(guids))
+	for _, guid := range guids {
+		own[guid] = true
+	}
+	splits, err := s.db.getRelatedSplits(ctx, guids, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	byAccount := make(map[string]*counterpartyFlow)
+	for i := 0; i < len(splits); {
+		tx := splits[i].TxGUID
+		j := i
+		for j < len(splits) && splits[j].TxGUID == tx {
+			j++
+		}
+		txSplits := splits[i:j]
+		i = j
+		if txSplits[0].Date.Format("2006-01-02") < startDate {
+			continue
+		}
+		for _, sp := range txSplits {
+			acc := accounts[sp.AccountGUID]
+			if own[sp.AccountGUID] || acc == nil || acc.AccountType == "TRADING" || sp.Value == 0 {
+				continue
+			}
+			f, ok := byAccount[sp.AccountGUID]
+			if !ok {
+				f = &counterpartyFlow{Account: acc}
+				byAccount[sp.AccountGUID] = f
+			}
+			// The other side is credited when money comes in.
+			if sp.Value < 0 {
+				f.Inflow -= sp.Value
+			} else {
+				f.Outflow += sp.Value
+			}
+			f.Splits++
+		}
+	}
+	if len(byAccount) == 0 {
+		return fmt.Sprintf("No money moved in or out of %s from %s to %s.", account.FullName, startDate, endDate), nil
+	}
+	flows := make([]*counterpartyFlow, 0, len(byAccount))
+	for _, f := range byAccount {
+		flows = append(flows, f)
+	}
+	slices.SortFunc(flows, func(a, b *counterpartyFlow) int {
+		return cmp.Or(cmp.Compare(b.Inflow+b.Outflow, a.Inflow+a.Outflow), cmp.Compare(a.Account.FullName, b.Account.FullName))
+	})
+	type row struct {
+		name, accountType string
+		flow              counterpartyFlow
+	}
+	var rows []row
+	var other, total counterpartyFlow
+	for i, f := range flows {
+		total.Inflow, total.Outflow, total.Splits = total.Inflow+f.Inflow, total.Outflow+f.Outflow, total.Splits+f.Splits
+		if i < limit {
+			rows = append(rows, row{f.Account.FullName, f.Account.AccountType, *f})
+			continue
+		}
+		other.Inflow, other.Outflow, other.Splits = other.Inflow+f.Inflow, other.Outflow+f.Outflow, other.Splits+f.Splits
+	}
+	if len(flows) > limit {
+		rows = append(rows, row{fmt.Sprintf("Other (%d more)", len(flows)-limit), "", other})
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"counterparty", "type", "inflow", "outflow", "net", "splits"}}
+		for _, r := range rows {
+			t.add(r.name, r.accountType, fmt.Sprintf("%.2f", r.flow.Inflow), fmt.Sprintf("%.2f", r.flow.Outflow),
+				fmt.Sprintf("%.2f", r.flow.Inflow-r.flow.Outflow), fmt.Sprint(r.flow.Splits))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Money in and out of %s from %s to %s, by counterparty, in %s:\n\n", account.FullName, startDate, endDate, cur.Mnemonic)
+	fmt.Fprintf(&sb, "  %-44s %12s %12s %13s %7s\n", "Counterparty", "In", "Out", "Net", "Splits")
+	for _, r := range rows {
+		fmt.Fprintf(&sb, "  %-44s %12.2f %12.2f %+13.2f %7d\n", r.name, r.flow.Inflow, r.flow.Outflow, r.flow.Inflow-r.flow.Outflow, r.flow.Splits)
+	}
+	fmt.Fprintf(&sb, "  %-44s %12.2f %12.2f %+13.2f %7d\n", "Total", total.Inflow, total.Outflow, total.Inflow-total.Outflow, total.Splits)
+	if total.Inflow > 0 && len(rows) > 0 {
+		top := rows[0]
+		for _, r := range rows {
+			if r.flow.Inflow > top.flow.Inflow {
+				top = r
+			}
+		}
+		fmt.Fprintf(&sb, "\n  Largest source: %s (%.0f%% of the money in)\n", top.name, math.Round(top.flow.Inflow/total.Inflow*100))
+	}
+	if total.Outflow > 0 && len(rows) > 0 {
+		top := rows[0]
+		for _, r := range rows {
+			if r.flow.Outflow > top.flow.Outflow {
+				top = r
+			}
+		}
+		fmt.Fprintf(&sb, "  Largest destination: %s (%.0f%% of the money out)\n", top.name, math.Round(top.flow.Outflow/total.Outflow*100))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,222 @@
+package gnucash
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CSVProfile maps the columns of a bank's CSV export. Columns are given by
+// header name (case-insensitive) or, for files without a header, by 1-based
+// number.
+type CSVProfile struct {
+	Date         string `json:"date"`
+	DateFormat   string `json:"date_format,omitempty"` // e.g. DD/MM/YYYY; default YYYY-MM-DD
+	Description  string `json:"description"`
+	Amount       string `json:"amount,omitempty"` // signed amount; or debit and credit
+	Debit        string `json:"debit,omitempty"`  // money out
+	Credit       string `json:"credit,omitempty"` // money in
+	Memo         string `json:"memo,omitempty"`
+	Category     string `json:"category,omitempty"` // counterpart account name or path
+	Delimiter    string `json:"delimiter,omitempty"`
+	DecimalComma bool   `json:"decimal_comma,omitempty"`
+	NoHeader     bool   `json:"no_header,omitempty"`
+	Negate       bool   `json:"negate,omitempty"` // amounts are positive for money out, as on card statements
+}
+
+// dateLayout turns a DD/MM/YYYY-style date format into a Go layout. Days
+// and months may then be written with or without a leading zero.
+func dateLayout(format string) string {
+	if format == "" {
+		return "2006-01-02"
+	}
+	return strings.NewReplacer("YYYY", "2006", "yyyy", "2006", "YY", "06", "yy", "06",
+		"MM", "1", "mm", "1", "DD", "2", "dd", "2").Replace(format)
+}
+
+// parseStatementAmount parses an amount as banks write them: with thousands
+// separators, currency symbols, a trailing minus or parentheses for negative
+// amounts. An empty cell is zero.
+func parseStatementAmount(s string, decimalComma bool) (float64, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u202f', '\'', '$', '€', '£', '¥':
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return 0, nil
+	}
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, s[1:len(s)-1]
+	}
+	if strings.HasSuffix(s, "-") {
+		negative, s = true, strings.TrimSuffix(s, "-")
+	}
+	if decimalComma {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid amount")
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// parseStatementCSV reads the lines of a CSV statement according to p.
+// Lines that cannot be read are returned as failures.
+func parseStatementCSV(content string, p CSVProfile) ([]importRow, []string, error) {
+	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	if p.Delimiter != "" {
+		if p.Delimiter == `\t` {
+			p.Delimiter = "\t"
+		}
+		d, size := utf8.DecodeRuneInString(p.Delimiter)
+		if size != len(p.Delimiter) {
+			return nil, nil, fmt.Errorf("delimiter must be a single character")
+		}
+		r.Comma = d
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("the CSV is empty")
+	}
+
+	var header []string
+	first := 1
+	if !p.NoHeader {
+		header, records, first = records[0], records[1:], 2
+	}
+	column := func(field, ref string, required bool) (int, error) {
+		if ref == "" {
+			if required {
+				return -1, fmt.Errorf("the profile must give the %s column", field)
+			}
+			return -1, nil
+		}
+		if n, err := strconv.Atoi(ref); err == nil && n >= 1 {
+			return n - 1, nil
+		}
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(ref)) {
+				return i, nil
+			}
+		}
+		if header == nil {
+			return -1, fmt.Errorf("%s column '%s' must be a number: the file has no header", field, ref)
+		}
+		return -1, fmt.Errorf("%s column '%s' not found; the columns are: %s", field, ref, strings.Join(header, ", "))
+	}
+
+	var cols struct{ date, description, amount, debit, credit, memo, category int }
+	for _, c := range []struct {
+		dst      *int
+		field    string
+		ref      string
+		required bool
+	}{
+		{&cols.date, "date", p.Date, true},
+		{&cols.description, "description", p.Description, true},
+		{&cols.amount, "amount", p.Amount, false},
+		{&cols.debit, "debit", p.Debit, false},
+		{&cols.credit, "credit", p.Credit, false},
+		{&cols.memo, "memo", p.Memo, false},
+		{&cols.category, "category", p.Category, false},
+	} {
+		if *c.dst, err = column(c.field, c.ref, c.required); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cols.amount < 0 && cols.debit < 0 && cols.credit < 0 {
+		return nil, nil, fmt.Errorf("the profile must give the amount column, or the debit and credit columns")
+	}
+	layout, format := dateLayout(p.DateFormat), p.DateFormat
+	if format == "" {
+		format = "YYYY-MM-DD"
+	}
+
+	var rows []importRow
+	var failures []string
+	for i, rec := range records {
+		line := first + i
+		cell := func(col int) string {
+			if col < 0 || col >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[col])
+		}
+		if strings.Join(rec, "") == "" {
+			continue
+		}
+		date, err := time.Parse(layout, cell(cols.date))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("line %d: invalid date '%s' (expected %s)", line, cell(cols.date), format))
+			continue
+		}
+		var amount float64
+		for _, c := range []struct {
+			col  int
+			sign float64
+		}{{cols.amount, 1}, {cols.credit, 1}, {cols.debit, -1}} {
+			v, err := parseStatementAmount(cell(c.col), p.DecimalComma)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("line %d: invalid amount '%s'", line, cell(c.col)))
+				amount = math.NaN()
+				break
+			}
+			if c.sign < 0 {
+				v = -math.Abs(v)
+			}
+			amount += v
+		}
+		if math.IsNaN(amount) {
+			continue
+		}
+		if p.Negate {
+			amount = -amount
+		}
+		rows = append(rows, importRow{
+			Line:        line,
+			Date:        date,
+			Description: cell(cols.description),
+			Memo:        cell(cols.memo),
+			Amount:      amount,
+			Category:    cell(cols.category),
+		})
+	}
+	return rows, failures, nil
+}
+
+// ImportCSV imports a bank or card statement in CSV into account. Lines with
+// a category column go to the matching account, which is created when
+// createAccounts is set and none matches; other lines go to defaultAccount,
+// else to Imbalance-<currency> as in GnuCash. Lines already in the book are
+// skipped, so a statement can be imported again after a partial import.
+func (s *Service) ImportCSV(ctx context.Context, account, content string, profile CSVProfile, defaultAccount string, createAccounts bool) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	rows, failures, err := parseStatementCSV(content, profile)
+	if err != nil {
+		return "", err
+	}
+	input := map[string]any{"account": account, "profile": profile, "default_account": defaultAccount, "create_accounts": createAccounts, "lines": len(rows) + len(failures)}
+	return s.importRows(ctx, "import_csv", input, account, rows, failures, importOptions{DefaultAccount: defaultAccount, CreateAccounts: createAccounts})
+}
@@ -0,0 +1,337 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exchangeRate returns the price of one unit of from in to on date: the
+// latest price recorded on or before date between the two commodities, in
+// either direction. ok is false when the book has none.
+func (d *DB) exchangeRate(ctx context.Context, from, to Commodity, date string) (rate Numeric, ok bool, err error) {
+	if from.GUID == to.GUID {
+		return NewNumeric(1, 1), true, nil
+	}
+	var commodity string
+	var num, denom int64
+	err = d.db.QueryRowContext(ctx, `
+		SELECT commodity_guid, value_num, value_denom
+		FROM prices
+		WHERE ((commodity_guid = ? AND currency_guid = ?) OR (commodity_guid = ? AND currency_guid = ?))
+		  AND date <= ? AND value_num != 0
+		ORDER BY date DESC
+		LIMIT 1
+	`, from.GUID, to.GUID, to.GUID, from.GUID, d.dayEnd(date)).Scan(&commodity, &num, &denom)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Numeric{}, false, nil
+	}
+	if err != nil {
+		return Numeric{}, false, fmt.Errorf("query exchange rate: %w", err)
+	}
+	rate = NewNumeric(num, denom)
+	if commodity != from.GUID {
+		rate = rate.Inv()
+	}
+	return rate, true, nil
+}
+
+// formatRate formats an exchange rate with up to six decimals.
+func formatRate(rate Numeric) string {
+	s := strings.TrimRight(rate.Format(1000000), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// findCurrency returns the currency with the ISO code code.
+func (s *Service) findCurrency(ctx context.Context, code string) (Commodity, error) {
+	commodities, err := s.db.FindCommodities(ctx, code)
+	if err != nil {
+		return Commodity{}, err
+	}
+	for _, c := range commodities {
+		if c.Namespace == "CURRENCY" {
+			return c, nil
+		}
+	}
+	return Commodity{}, fmt.Errorf("currency '%s' not found in the book", code)
+}
+
+// Interpolations accepted by ExchangeRates.
+const (
+	InterpolateNone     = "none"     // recorded rates only
+	InterpolatePrevious = "previous" // the latest recorded rate
+	InterpolateLinear   = "linear"   // linear between the surrounding rates
+)
+
+// Steps of an interpolated rate series.
+const (
+	StepDay   = "day"
+	StepWeek  = "week"
+	StepMonth = "month"
+)
+
+// DefaultMaxRateAge is the age in days past which ExchangeRates warns that
+// a rate is stale.
+const DefaultMaxRateAge = 30
+
+// datedRate is the rate of one unit of a currency in another on a day.
+type datedRate struct {
+	Date time.Time
+	Rate Numeric
+}
+
+// getRates returns the rates of from in to recorded up to endDate (all of
+// them when empty), oldest first, from prices in either direction. Of
+// several prices on a day, the last one recorded wins.
+func (d *DB) getRates(ctx context.Context, from, to Commodity, endDate string) ([]datedRate, error) {
+	query := `
+		SELECT commodity_guid, date, value_num, value_denom
+		FROM prices
+		WHERE ((commodity_guid = ? AND currency_guid = ?) OR (commodity_guid = ? AND currency_guid = ?))
+		  AND value_num != 0
+	`
+	args := []any{from.GUID, to.GUID, to.GUID, from.GUID}
+	if endDate != "" {
+		query += " AND date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += " ORDER BY date"
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []datedRate
+	for rows.Next() {
+		var commodity, date string
+		var num, denom int64
+		if err := rows.Scan(&commodity, &date, &num, &denom); err != nil {
+			return nil, fmt.Errorf("scan exchange rate: %w", err)
+		}
+		t, err := d.parseDate(date)
+		if err != nil {
+			continue
+		}
+		r := datedRate{Date: t.Truncate(24 * time.Hour), Rate: NewNumeric(num, denom)}
+		if commodity != from.GUID {
+			r.Rate = r.Rate.Inv()
+		}
+		if n := len(rates); n > 0 && rates[n-1].Date.Equal(r.Date) {
+			rates[n-1] = r
+			continue
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// days returns the number of whole days from a to b.
+func days(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// rateOn returns the rate on date interpolated from rates, the age in days
+// of the nearest recorded rate it is based on, and a note on how it was
+// obtained. ok is false before the first recorded rate.
+func rateOn(rates []datedRate, date time.Time, interpolation string) (rate Numeric, age int, note string, ok bool) {
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(date) })
+	if i == 0 {
+		return Numeric{}, 0, "", false
+	}
+	prev := rates[i-1]
+	if prev.Date.Equal(date) {
+		return prev.Rate, 0, "recorded", true
+	}
+	if interpolation == InterpolateLinear && i < len(rates) {
+		next := rates[i]
+		span := NewNumeric(int64(days(prev.Date, date)), int64(days(prev.Date, next.Date)))
+		rate = prev.Rate.Add(next.Rate.Sub(prev.Rate).Mul(span))
+		age = min(days(prev.Date, date), days(date, next.Date))
+		return rate, age, fmt.Sprintf("between %s and %s", prev.Date.Format("2006-01-02"), next.Date.Format("2006-01-02")), true
+	}
+	return prev.Rate, days(prev.Date, date), "rate of " + prev.Date.Format("2006-01-02"), true
+}
+
+// seriesDates returns the dates of a rate series from start to end: every
+// day, the end of every week (the day before weekStart) or of every month,
+// and end itself.
+func seriesDates(start, end time.Time, step string, weekStart time.Weekday) []time.Time {
+	var dates []time.Time
+	switch step {
+	case StepDay:
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			dates = append(dates, d)
+		}
+	case StepWeek:
+		last := (weekStart + 6) % 7
+		for d := start.AddDate(0, 0, (int(last)-int(start.Weekday())+7)%7); !d.After(end); d = d.AddDate(0, 0, 7) {
+			dates = append(dates, d)
+		}
+	default:
+		for m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(end); m = m.AddDate(0, 1, 0) {
+			if d := m.AddDate(0, 1, -1); !d.After(end) {
+				dates = append(dates, d)
+			}
+		}
+	}
+	if len(dates) == 0 || dates[len(dates)-1].Before(end) {
+		dates = append(dates, end)
+	}
+	return dates
+}
+
+// ExchangeRates lists the rates of one unit of the currency from in the
+// currency to between startDate and endDate, from the prices recorded in
+// either direction. With InterpolateNone, the recorded rates are listed;
+// otherwise the series has a rate per step (StepDay, StepWeek or
+// StepMonth), carried forward from the latest rate or interpolated
+// linearly between the surrounding ones. Rates based on a price older than
+// maxAge days, and gaps longer than that between recorded rates, are
+// flagged as stale.
+func (s *Service) ExchangeRates(ctx context.Context, from, to, startDate, endDate, interpolation, step string, maxAge int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if interpolation == "" {
+		interpolation = InterpolateNone
+	}
+	if !slices.Contains([]string{InterpolateNone, InterpolatePrevious, InterpolateLinear}, interpolation) {
+		return "", fmt.Errorf("unsupported interpolation '%s' (expected %s, %s or %s)", interpolation, InterpolateNone, InterpolatePrevious, InterpolateLinear)
+	}
+	if step == "" {
+		step = StepMonth
+	}
+	if !slices.Contains([]string{StepDay, StepWeek, StepMonth}, step) {
+		return "", fmt.Errorf("unsupported step '%s' (expected %s, %s or %s)", step, StepDay, StepWeek, StepMonth)
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxRateAge
+	}
+	fromCur, err := s.findCurrency(ctx, from)
+	if err != nil {
+		return "", err
+	}
+	toCur, err := s.findCurrency(ctx, to)
+	if err != nil {
+		return "", err
+	}
+	if fromCur.GUID == toCur.GUID {
+		return "", fmt.Errorf("from and to are the same currency")
+	}
+
+	now := s.now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if endDate != "" {
+		if end, err = time.Parse("2006-01-02", endDate); err != nil {
+			return "", fmt.Errorf("invalid end_date '%s' (expected YYYY-MM-DD)", endDate)
+		}
+	}
+	rates, err := s.db.getRates(ctx, fromCur, toCur, end.Format("2006-01-02"))
+	if err != nil {
+		return "", err
+	}
+	if len(rates) == 0 {
+		return fmt.Sprintf("No exchange rates between %s and %s recorded up to %s.", fromCur.Mnemonic, toCur.Mnemonic, end.Format("2006-01-02")), nil
+	}
+	start := rates[0].Date
+	if startDate != "" {
+		if start, err = time.Parse("2006-01-02", startDate); err != nil {
+			return "", fmt.Errorf("invalid start_date '%s' (expected YYYY-MM-DD)", startDate)
+		}
+	}
+	if start.After(end) {
+		return "", fmt.Errorf("start_date %s is after end_date %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	type point struct {
+		Date  time.Time
+		Rate  Numeric
+		Note  string
+		Stale bool
+	}
+	var points []point
+	var warnings []string
+	if interpolation == InterpolateNone {
+		var last time.Time
+		for _, r := range rates {
+			if r.Date.Before(start) {
+				last = r.Date
+				continue
+			}
+			if !last.IsZero() && days(last, r.Date) > maxAge {
+				warnings = append(warnings, fmt.Sprintf("no rate for %d days between %s and %s", days(last, r.Date), last.Format("2006-01-02"), r.Date.Format("2006-01-02")))
+			}
+			points = append(points, point{Date: r.Date, Rate: r.Rate})
+			last = r.Date
+		}
+		if age := days(last, end); age > maxAge {
+			warnings = append(warnings, fmt.Sprintf("the latest rate, of %s, is %d days older than %s", last.Format("2006-01-02"), age, end.Format("2006-01-02")))
+		}
+	} else {
+		var stale int
+		for _, d := range seriesDates(start, end, step, s.weekStart) {
+			rate, age, note, ok := rateOn(rates, d, interpolation)
+			if !ok {
+				continue
+			}
+			points = append(points, point{Date: d, Rate: rate, Note: note, Stale: age > maxAge})
+			if age > maxAge {
+				stale++
+			}
+		}
+		if start.Before(rates[0].Date) {
+			warnings = append(warnings, fmt.Sprintf("no rate before %s", rates[0].Date.Format("2006-01-02")))
+		}
+		if stale > 0 {
+			warnings = append(warnings, fmt.Sprintf("%d rate(s) are based on a price more than %d days away", stale, maxAge))
+		}
+	}
+	if len(points) == 0 {
+		return fmt.Sprintf("No exchange rates between %s and %s from %s to %s.", fromCur.Mnemonic, toCur.Mnemonic, start.Format("2006-01-02"), end.Format("2006-01-02")), nil
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"date", "rate", "source", "stale"}}
+		for _, p := range points {
+			source := p.Note
+			if interpolation == InterpolateNone {
+				source = "recorded"
+			}
+			t.add(p.Date.Format("2006-01-02"), formatRate(p.Rate), source, strconv.FormatBool(p.Stale))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Exchange rate of 1 %s in %s (%s to %s", fromCur.Mnemonic, toCur.Mnemonic, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if interpolation == InterpolateNone {
+		fmt.Fprintf(&sb, ", %d recorded rate(s)):\n\n", len(points))
+	} else {
+		fmt.Fprintf(&sb, ", per %s, %s interpolation):\n\n", step, interpolation)
+	}
+	for _, p := range points {
+		fmt.Fprintf(&sb, "  %s  %12s", p.Date.Format("2006-01-02"), formatRate(p.Rate))
+		if p.Note != "" && p.Note != "recorded" {
+			fmt.Fprintf(&sb, "  (%s)", p.Note)
+		}
+		if p.Stale {
+			sb.WriteString("  STALE")
+		}
+		sb.WriteString("\n")
+	}
+	if len(warnings) > 0 {
+		sb.WriteString("\nWarnings:\n")
+		for _, w := range warnings {
+			fmt.Fprintf(&sb, "- %s\n", w)
+		}
+	}
+	return sb.String(), nil
+}
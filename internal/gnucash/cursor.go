@@ -0,0 +1,161 @@
+package gnucash
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Sort keys accepted by transaction listings.
+const (
+	SortByDate        = "date"
+	SortByAmount      = "amount"
+	SortByDescription = "description"
+	SortByRelevance   = "relevance" // full-text rank, search with an index only
+)
+
+// txOrder is the sort order of a transaction listing. Ties are broken by
+// transaction GUID in the same direction so paging is deterministic.
+type txOrder struct {
+	By   string
+	Desc bool
+}
+
+// parseTxOrder validates sortBy and order. Dates and amounts default to
+// descending order, descriptions and relevance (best match first) to ascending.
+func parseTxOrder(sortBy, order string) (txOrder, error) {
+	o := txOrder{By: strings.ToLower(sortBy)}
+	switch o.By {
+	case "":
+		o.By = SortByDate
+		o.Desc = true
+	case SortByDate, SortByAmount:
+		o.Desc = true
+	case SortByDescription, SortByRelevance:
+	default:
+		return txOrder{}, fmt.Errorf("unsupported sort_by '%s' (expected %s, %s, %s or %s)", sortBy, SortByDate, SortByAmount, SortByDescription, SortByRelevance)
+	}
+	switch strings.ToLower(order) {
+	case "":
+	case "asc":
+		o.Desc = false
+	case "desc":
+		o.Desc = true
+	default:
+		return txOrder{}, fmt.Errorf("unsupported order '%s' (expected asc or desc)", order)
+	}
+	return o, nil
+}
+
+func (o txOrder) String() string {
+	if o.Desc {
+		return o.By + " desc"
+	}
+	return o.By + " asc"
+}
+
+// orderBy returns the ORDER BY clause sorting on key.
+func (o txOrder) orderBy(key string) string {
+	dir := "ASC"
+	if o.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, t.guid %s", key, dir, dir)
+}
+
+// after returns a condition selecting rows past the cursor, sorting on key.
+func (o txOrder) after(key string, c *pageCursor) (string, []any) {
+	op := ">"
+	if o.Desc {
+		op = "<"
+	}
+	cond := fmt.Sprintf(" AND (%s %s ? OR (%s = ? AND t.guid %s ?))", key, op, key, op)
+	return cond, []any{c.Key, c.Key, c.GUID}
+}
+
+// pageCursor marks the last transaction of a page by its sort key and GUID,
+// so the next page starts strictly after it.
+type pageCursor struct {
+	Order string `json:"o"`
+	Key   any    `json:"k"` // string, or float64 for amounts
+	GUID  string `json:"g"`
+}
+
+// encode returns the cursor as an opaque token.
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token produced by encode for a listing sorted by
+// order. An empty token yields nil.
+func decodeCursor(token string, order txOrder) (*pageCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.GUID == "" || c.Key == nil {
+		return nil, fmt.Errorf("invalid cursor '%s'", token)
+	}
+	if c.Order != order.String() {
+		return nil, fmt.Errorf("cursor was issued for sort order '%s', not '%s'", c.Order, order)
+	}
+	return &c, nil
+}
+
+// txQuery selects a page of a transaction listing.
+type txQuery struct {
+	StartDate string
+	EndDate   string
+	Order     txOrder
+	Limit     int
+	After     *pageCursor
+
+	// Search filters, ignored by account listings.
+	Text            string
+	AccountGUIDs    []string
+	ReconcileStates []string
+	MinAmount       float64 // ignored unless positive
+	MaxAmount       float64 // ignored unless positive
+
+	// Matches, when non-nil, holds the full-text index hits for Text, best
+	// first, and replaces substring matching.
+	Matches []string
+}
+
+// newTxQuery validates the paging and sorting parameters of a listing.
+func newTxQuery(startDate, endDate string, limit int, sortBy, order, cursor string) (txQuery, error) {
+	o, err := parseTxOrder(sortBy, order)
+	if err != nil {
+		return txQuery{}, err
+	}
+	after, err := decodeCursor(cursor, o)
+	if err != nil {
+		return txQuery{}, err
+	}
+	return txQuery{StartDate: startDate, EndDate: endDate, Order: o, Limit: limit, After: after}, nil
+}
+
+// page trims rows fetched with one extra row beyond limit and returns the
+// cursor of the next page, or "" when there is none.
+func (q txQuery) page(transactions []Transaction, keys []any) ([]Transaction, string) {
+	if q.Limit <= 0 || len(transactions) <= q.Limit {
+		return transactions, ""
+	}
+	transactions = transactions[:q.Limit]
+	last := transactions[q.Limit-1]
+	return transactions, pageCursor{Order: q.Order.String(), Key: keys[q.Limit-1], GUID: last.GUID}.encode()
+}
+
+// sortKey normalizes a scanned sort key so it round-trips through a cursor.
+func sortKey(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case int64:
+		return float64(v)
+	}
+	return v
+}
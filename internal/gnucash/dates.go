@@ -0,0 +1,216 @@
+package gnucash
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithTimezone reads the book's dates in loc rather than UTC, and resolves
+// today's date there. GnuCash stores posting dates as UTC timestamps: books
+// saved by older versions store the local midnight of the date, which falls
+// on the day before in UTC east of Greenwich.
+func WithTimezone(loc *time.Location) Option {
+	return func(s *Service) { s.db.loc = loc }
+}
+
+// WithWeekStart starts the weeks of date presets and weekly series on day
+// rather than Monday.
+func WithWeekStart(day time.Weekday) Option {
+	return func(s *Service) { s.weekStart = day }
+}
+
+// ParseTimezone returns the time zone named name in the IANA database, such
+// as Europe/Paris, or Local for the system's.
+func ParseTimezone(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone '%s' (expected a name like Europe/Paris or America/New_York)", name)
+	}
+	return loc, nil
+}
+
+// ParseWeekday returns the day of the week named name in English, in full
+// or by its first three letters.
+func ParseWeekday(name string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if n := strings.ToLower(name); n == full || n == full[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day of the week '%s' (expected monday to sunday)", name)
+}
+
+// now returns the current time in the book's time zone.
+func (s *Service) now() time.Time {
+	return time.Now().In(s.db.location())
+}
+
+// datePresets lists the named ranges date parameters accept besides
+// YYYY-MM-DD dates. last_N_days, last_N_months and N_days_ago work for any
+// N.
+var datePresets = []string{
+	"today", "yesterday", "this_week", "last_week", "this_month", "last_month",
+	"this_quarter", "last_quarter", "this_year", "last_year", "mtd", "qtd", "ytd",
+	"last_7_days", "last_30_days", "last_90_days", "last_12_months",
+}
+
+// datePhrases maps the natural-language forms of presets, once lowercased
+// with words joined by underscores, to them.
+var datePhrases = map[string]string{
+	"month_to_date":    "mtd",
+	"quarter_to_date":  "qtd",
+	"year_to_date":     "ytd",
+	"previous_week":    "last_week",
+	"previous_month":   "last_month",
+	"previous_quarter": "last_quarter",
+	"previous_year":    "last_year",
+	"current_week":     "this_week",
+	"current_month":    "this_month",
+	"current_quarter":  "this_quarter",
+	"current_year":     "this_year",
+	"past_week":        "last_7_days",
+	"past_month":       "last_30_days",
+	"past_year":        "last_12_months",
+}
+
+var (
+	lastNPreset = regexp.MustCompile(`^(?:last|past)_(\d+)_(day|week|month|year)s?$`)
+	agoPreset   = regexp.MustCompile(`^(\d+)_(day|week|month|year)s?_ago$`)
+)
+
+// presetRange returns the first and last days of the range preset names
+// relative to today, with weeks starting on weekStart.
+func presetRange(preset string, today time.Time, weekStart time.Weekday) (start, end time.Time, ok bool) {
+	preset = strings.Join(strings.FieldsFunc(strings.ToLower(preset), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), "_")
+	if p, found := datePhrases[preset]; found {
+		preset = p
+	}
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	quarter := time.Date(today.Year(), (today.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	year := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	week := today.AddDate(0, 0, -((int(today.Weekday()) - int(weekStart) + 7) % 7))
+
+	switch preset {
+	case "today":
+		return today, today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today.AddDate(0, 0, -1), true
+	case "this_week":
+		return week, week.AddDate(0, 0, 6), true
+	case "last_week":
+		return week.AddDate(0, 0, -7), week.AddDate(0, 0, -1), true
+	case "this_month":
+		return month, month.AddDate(0, 1, -1), true
+	case "last_month":
+		return month.AddDate(0, -1, 0), month.AddDate(0, 0, -1), true
+	case "this_quarter":
+		return quarter, quarter.AddDate(0, 3, -1), true
+	case "last_quarter":
+		return quarter.AddDate(0, -3, 0), quarter.AddDate(0, 0, -1), true
+	case "this_year":
+		return year, year.AddDate(1, 0, -1), true
+	case "last_year":
+		return year.AddDate(-1, 0, 0), year.AddDate(0, 0, -1), true
+	case "mtd":
+		return month, today, true
+	case "qtd":
+		return quarter, today, true
+	case "ytd":
+		return year, today, true
+	}
+	if m := lastNPreset.FindStringSubmatch(preset); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n <= 0 {
+			return time.Time{}, time.Time{}, false
+		}
+		// The last N days end today: last_7_days is today and the 6 days
+		// before it.
+		return shiftDate(today, m[2], -n).AddDate(0, 0, 1), today, true
+	}
+	if m := agoPreset.FindStringSubmatch(preset); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		day := shiftDate(today, m[2], -n)
+		return day, day, true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// shiftDate moves t by n days, weeks, months or years.
+func shiftDate(t time.Time, unit string, n int) time.Time {
+	switch unit {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return t.AddDate(0, n, 0)
+	case "year":
+		return t.AddDate(n, 0, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// resolveDate returns value as a YYYY-MM-DD date: unchanged when it is one
+// (or empty), else the first day of the preset it names, or its last day
+// when end is set.
+func resolveDate(value string, end bool, today time.Time, weekStart time.Weekday) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return value, nil
+	}
+	start, last, ok := presetRange(value, today, weekStart)
+	if !ok {
+		return "", fmt.Errorf("invalid date '%s' (expected YYYY-MM-DD or a preset: %s, last_N_days, last_N_months or N_days_ago)",
+			value, strings.Join(datePresets, ", "))
+	}
+	if end {
+		return last.Format("2006-01-02"), nil
+	}
+	return start.Format("2006-01-02"), nil
+}
+
+// DateArgs lists the date arguments of tool calls that accept presets: the
+// bounds of a period, those of the period it is compared with, and as-of
+// dates.
+var DateArgs = []string{"start_date", "end_date", "compare_start_date", "compare_end_date", "date"}
+
+// ResolveDateArgs replaces the date presets of the date arguments of a tool
+// call (see DateArgs) with YYYY-MM-DD dates, so that every tool accepts
+// them. A preset start date without an end date also sets the end date to
+// the end of its range; date, an as-of date, takes the end of the range.
+func (s *Service) ResolveDateArgs(args map[string]any) error {
+	today := s.now()
+	for _, prefix := range []string{"", "compare_"} {
+		start, _ := args[prefix+"start_date"].(string)
+		if end, _ := args[prefix+"end_date"].(string); start != "" && end == "" {
+			if _, _, ok := presetRange(start, today, s.weekStart); ok {
+				args[prefix+"end_date"] = start
+			}
+		}
+	}
+	for _, p := range []struct {
+		key string
+		end bool
+	}{{"start_date", false}, {"end_date", true}, {"compare_start_date", false}, {"compare_end_date", true}, {"date", true}} {
+		value, ok := args[p.key].(string)
+		if !ok {
+			continue
+		}
+		resolved, err := resolveDate(value, p.end, today, s.weekStart)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.key, err)
+		}
+		args[p.key] = resolved
+	}
+	return nil
+}
@@ -1,10 +1,18 @@
 package gnucash
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -12,34 +20,115 @@ import (
 
 // DB wraps a read-only SQLite connection to a GnuCash database.
 type DB struct {
-	db *sql.DB
+	db       *sql.DB
+	rw       *sql.DB // writable connection, nil unless EnableWrites was called
+	path     string  // book file, empty for in-memory test databases
+	queries  queryLog
+	loc      *time.Location // time zone of the book's dates, UTC when nil
+	legacy   bool           // timestamps in legacyTimestampLayout
+	stamp    bookStamp      // book file last seen by Refresh
+	version  atomic.Uint64  // changes of the book file seen by Refresh or written
+	snapshot liveSnapshot   // copy of the book read while GnuCash has it open
+	stmts    stmtCache      // prepared statements of the hot queries
+	redact   bool           // descriptions and memos hidden, see EnableRedaction
 }
 
+// Layouts of the timestamps of GnuCash books: GnuCash 2.6 and later store
+// them as text, older versions as 14-digit numbers such as 20250115000000.
+const (
+	timestampLayout       = "2006-01-02 15:04:05"
+	legacyTimestampLayout = "20060102150405"
+)
+
+// ErrXMLBook is returned when the book file uses GnuCash's XML backend,
+// which this server cannot read.
+var ErrXMLBook = errors.New("file is a GnuCash XML book; only SQLite books are supported (File → Save As → sqlite3)")
+
 // NewDB opens a GnuCash SQLite database in read-only mode.
 func NewDB(filepath string) (*DB, error) {
-	dsn := fmt.Sprintf("file:%s?mode=ro", filepath)
-	db, err := sql.Open("sqlite", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+	if err := checkBookFormat(filepath); err != nil {
+		return nil, err
 	}
-	if err := db.Ping(); err != nil {
-		db.Close()
+	d := &DB{path: filepath}
+	d.db = openLogged(func() string {
+		// Waits out GnuCash's writes rather than failing with SQLITE_BUSY.
+		return fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(5000)", d.readPath())
+	}, &d.queries)
+	// Readers do not block each other in SQLite, so the pool is not capped,
+	// which could also stall a query run while the rows of another are
+	// open; a few connections stay ready between the calls of a
+	// conversation, and are closed once it has gone quiet.
+	d.db.SetMaxIdleConns(readIdleConns)
+	d.db.SetConnMaxIdleTime(10 * time.Minute)
+	if err := d.db.Ping(); err != nil {
+		d.db.Close()
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
-	return &DB{db: db}, nil
+	if err := d.detectTimestampLayout(context.Background()); err != nil {
+		d.db.Close()
+		return nil, err
+	}
+	d.Refresh() // first sight of the file
+	return d, nil
 }
 
-// Close closes the database connection.
+// detectTimestampLayout tells books storing timestamps as 14-digit numbers
+// from the first transaction or price found, so that date bounds are
+// written the way the book compares them.
+func (d *DB) detectTimestampLayout(ctx context.Context) error {
+	var sample sql.NullString
+	err := d.db.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT post_date FROM transactions WHERE post_date IS NOT NULL LIMIT 1),
+		                (SELECT date FROM prices WHERE date IS NOT NULL LIMIT 1))
+	`).Scan(&sample)
+	if err != nil {
+		return fmt.Errorf("query timestamp layout: %w", err)
+	}
+	_, err = time.Parse(legacyTimestampLayout, sample.String)
+	d.legacy = sample.Valid && err == nil
+	return nil
+}
+
+// checkBookFormat rejects XML books (plain or gzip-compressed) up front.
+// GnuCash's XML backups share the same format, so falling back to the newest
+// backup of a locked XML book is not possible either.
+func checkBookFormat(filepath string) error {
+	f, err := os.Open(filepath)
+	if err != nil {
+		// Let the driver report missing or unreadable files.
+		return nil
+	}
+	defer f.Close()
+
+	header := make([]byte, 5)
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+	if bytes.HasPrefix(header, []byte{0x1f, 0x8b}) || bytes.HasPrefix(header, []byte("<?xml")) {
+		return ErrXMLBook
+	}
+	return nil
+}
+
+// Close closes the database connections and removes any snapshot.
 func (d *DB) Close() error {
-	return d.db.Close()
+	if d.rw != nil && d.rw != d.db {
+		d.rw.Close()
+	}
+	d.closeStatements()
+	err := d.db.Close()
+	if d.snapshot.dir != "" {
+		os.RemoveAll(d.snapshot.dir)
+	}
+	return err
 }
 
 // GetAllAccounts returns all accounts from the database.
 func (d *DB) GetAllAccounts(ctx context.Context) (map[string]*Account, error) {
-	rows, err := d.db.QueryContext(ctx, `
+	rows, err := d.queryPrepared(ctx, `
 		SELECT c.guid, c.name, c.account_type,
 			   COALESCE(c.parent_guid, ''),
 			   COALESCE(c.description, ''),
+			   COALESCE(c.commodity_guid, ''),
 			   c.hidden, c.placeholder
 		FROM accounts c inner join accounts p on c.parent_guid = p.guid
 		WHERE c.parent_guid IS NOT NULL AND p.name != 'Template Root'
@@ -54,7 +143,8 @@ func (d *DB) GetAllAccounts(ctx context.Context) (map[string]*Account, error) {
 	for rows.Next() {
 		acc := &Account{}
 		var hidden, placeholder int
-		if err := rows.Scan(&acc.GUID, &acc.Name, &acc.AccountType, &acc.ParentGUID, &acc.Description, &hidden, &placeholder); err != nil {
+		if err := rows.Scan(&acc.GUID, &acc.Name, &acc.AccountType, &acc.ParentGUID, &acc.Description,
+			&acc.CommodityGUID, &hidden, &placeholder); err != nil {
 			return nil, fmt.Errorf("scan account: %w", err)
 		}
 		acc.Hidden = hidden != 0
@@ -63,6 +153,14 @@ func (d *DB) GetAllAccounts(ctx context.Context) (map[string]*Account, error) {
 	}
 	for _, acc := range accounts {
 		acc.FullName = buildPath(acc, accounts)
+		if parent, ok := accounts[acc.ParentGUID]; ok {
+			parent.Children = append(parent.Children, acc)
+		}
+	}
+	for _, acc := range accounts {
+		slices.SortFunc(acc.Children, func(a, b *Account) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
 	}
 
 	return accounts, rows.Err()
@@ -82,154 +180,287 @@ func buildPath(acc *Account, index map[string]*Account) string {
 	return strings.Join(parts, ":")
 }
 
-// FindAccountsByName returns accounts matching a case-insensitive name pattern.
+// FindAccountsByName returns the accounts whose name contains name,
+// compared case-insensitively, sorted by name, with their full names.
 func (d *DB) FindAccountsByName(ctx context.Context, name string) ([]Account, error) {
-	pattern := "%" + strings.ToLower(name) + "%"
-	rows, err := d.db.QueryContext(ctx, `
-		SELECT guid, name, account_type,
-		       COALESCE(parent_guid, ''),
-		       COALESCE(description, ''),
-		       hidden, placeholder
-		FROM accounts
-		WHERE LOWER(name) LIKE ?
-		ORDER BY name
-	`, pattern)
+	accounts, err := d.GetAllAccounts(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("query accounts by name: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+	named := accountsNamed(accounts, name)
+	found := make([]Account, len(named))
+	for i, acc := range named {
+		found[i] = *acc
+	}
+	return found, nil
+}
 
-	var accounts []Account
-	for rows.Next() {
-		var a Account
-		var hidden, placeholder int
-		if err := rows.Scan(&a.GUID, &a.Name, &a.AccountType, &a.ParentGUID, &a.Description, &hidden, &placeholder); err != nil {
-			return nil, fmt.Errorf("scan account: %w", err)
+// accountsNamed returns the accounts whose name contains name, compared
+// case-insensitively, sorted by name and full name.
+func accountsNamed(accounts map[string]*Account, name string) []*Account {
+	name = strings.ToLower(name)
+	var named []*Account
+	for _, acc := range accounts {
+		if strings.Contains(strings.ToLower(acc.Name), name) {
+			named = append(named, acc)
 		}
-		a.Hidden = hidden != 0
-		a.Placeholder = placeholder != 0
-		accounts = append(accounts, a)
 	}
-	return accounts, rows.Err()
+	slices.SortFunc(named, func(a, b *Account) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.FullName, b.FullName))
+	})
+	return named
 }
 
 // GetSplitsForAccount returns splits for an account, optionally filtered by date range.
-// Splits are returned with their parent transaction data joined.
-func (d *DB) GetSplitsForAccount(ctx context.Context, accountGUID string, startDate, endDate string, limit int) ([]Transaction, error) {
-	query := `
-		SELECT t.guid, t.post_date, t.description,
-		       s.guid, s.memo, s.value_num, s.value_denom,
-		       s2.account_guid, COALESCE(a2.name, ''), s2.value_num, s2.value_denom, COALESCE(s2.memo, '')
+// Splits are returned with their parent transaction data joined, in q's order,
+// followed by their counterparts in other accounts than trading accounts.
+// The limit applies to transactions, and the returned cursor points to the next
+// page ("" when there is none). Amounts sort on the account's own split.
+func (d *DB) GetSplitsForAccount(ctx context.Context, accountGUID string, q txQuery) ([]Transaction, string, error) {
+	key := splitSortKey(q.Order.By, "CAST(s.value_num AS REAL) / s.value_denom")
+	inner := `
+		SELECT s.guid
 		FROM splits s
 		JOIN transactions t ON s.tx_guid = t.guid
-		JOIN splits s2 ON s2.tx_guid = t.guid AND s2.guid != s.guid
-		JOIN accounts a2 ON s2.account_guid = a2.guid
 		WHERE s.account_guid = ?
 	`
 	args := []any{accountGUID}
 
-	if startDate != "" {
-		query += " AND t.post_date >= ?"
-		args = append(args, startDate+" 00:00:00")
+	if q.StartDate != "" {
+		inner += " AND t.post_date >= ?"
+		args = append(args, d.dayStart(q.StartDate))
 	}
-	if endDate != "" {
-		query += " AND t.post_date <= ?"
-		args = append(args, endDate+" 23:59:59")
+	if q.EndDate != "" {
+		inner += " AND t.post_date <= ?"
+		args = append(args, d.dayEnd(q.EndDate))
 	}
-	query += " ORDER BY t.post_date DESC"
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+	if q.After != nil {
+		cond, condArgs := q.Order.after(key, q.After)
+		inner += cond
+		args = append(args, condArgs...)
 	}
+	inner += q.Order.orderBy(key)
+	if q.Limit > 0 {
+		// One extra row tells whether another page exists.
+		inner += fmt.Sprintf(" LIMIT %d", q.Limit+1)
+	}
+
+	query := `
+		SELECT t.guid, t.post_date, t.description, t.currency_guid,
+		       s.guid, s.memo, s.value_num, s.value_denom, s.quantity_num, s.quantity_denom,
+		       s2.account_guid, COALESCE(a2.name, ''), s2.value_num, s2.value_denom, COALESCE(s2.memo, ''),
+		       ` + key + `
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN splits s2 ON s2.tx_guid = t.guid AND s2.guid != s.guid
+		JOIN accounts a2 ON s2.account_guid = a2.guid AND a2.account_type != 'TRADING'
+		WHERE s.guid IN (` + inner + `)
+	` + q.Order.orderBy(key)
 
-	rows, err := d.db.QueryContext(ctx, query, args...)
+	rows, err := d.queryPrepared(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query splits: %w", err)
+		return nil, "", fmt.Errorf("query splits: %w", err)
 	}
 	defer rows.Close()
 
 	txMap := make(map[string]*Transaction)
 	var txOrder []string
+	var keys []any
 	for rows.Next() {
-		var txGUID, postDateStr, desc string
+		var txGUID, postDateStr, desc, currencyGUID string
 		var splitGUID, memo string
-		var valueNum, valueDenom int64
+		var valueNum, valueDenom, quantityNum, quantityDenom int64
 		var counterAccGUID, counterAccName string
 		var counterNum, counterDenom int64
 		var counterMemo string
+		var key any
 
-		if err := rows.Scan(&txGUID, &postDateStr, &desc,
-			&splitGUID, &memo, &valueNum, &valueDenom,
-			&counterAccGUID, &counterAccName, &counterNum, &counterDenom, &counterMemo); err != nil {
-			return nil, fmt.Errorf("scan split: %w", err)
+		if err := rows.Scan(&txGUID, &postDateStr, &desc, &currencyGUID,
+			&splitGUID, &memo, &valueNum, &valueDenom, &quantityNum, &quantityDenom,
+			&counterAccGUID, &counterAccName, &counterNum, &counterDenom, &counterMemo, &key); err != nil {
+			return nil, "", fmt.Errorf("scan split: %w", err)
 		}
 
 		tx, exists := txMap[txGUID]
 		if !exists {
-			postDate, _ := parseDate(postDateStr)
+			postDate, _ := d.parseDate(postDateStr)
 			tx = &Transaction{
-				GUID:        txGUID,
-				PostDate:    postDate,
-				Description: desc,
+				GUID:         txGUID,
+				PostDate:     postDate,
+				Description:  d.redacted(desc),
+				CurrencyGUID: currencyGUID,
 				Splits: []Split{{
-					GUID:        splitGUID,
-					TxGUID:      txGUID,
-					AccountGUID: accountGUID,
-					Memo:        memo,
-					ValueNum:    valueNum,
-					ValueDenom:  valueDenom,
+					GUID:          splitGUID,
+					TxGUID:        txGUID,
+					AccountGUID:   accountGUID,
+					Memo:          d.redacted(memo),
+					ValueNum:      valueNum,
+					ValueDenom:    valueDenom,
+					QuantityNum:   quantityNum,
+					QuantityDenom: quantityDenom,
 				}},
 			}
 			txMap[txGUID] = tx
 			txOrder = append(txOrder, txGUID)
+			keys = append(keys, sortKey(key))
 		}
 		// Add counterpart split
 		tx.Splits = append(tx.Splits, Split{
 			TxGUID:      txGUID,
 			AccountGUID: counterAccGUID,
 			AccountName: counterAccName,
-			Memo:        counterMemo,
+			Memo:        d.redacted(counterMemo),
 			ValueNum:    counterNum,
 			ValueDenom:  counterDenom,
 		})
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	var transactions []Transaction
 	for _, guid := range txOrder {
 		transactions = append(transactions, *txMap[guid])
 	}
-	return transactions, nil
+	transactions, next := q.page(transactions, keys)
+	return transactions, next, nil
+}
+
+// splitSortKey returns the SQL expression a listing sorts on, given the
+// expression for a transaction's amount.
+func splitSortKey(by, amount string) string {
+	switch by {
+	case SortByAmount:
+		return amount
+	case SortByDescription:
+		return "LOWER(t.description)"
+	}
+	return "t.post_date"
 }
 
 // GetBalanceForAccount returns the sum of all splits for an account up to the given date.
-func (d *DB) GetBalanceForAccount(ctx context.Context, accountGUID string, endDate string) (int64, int64, error) {
+func (d *DB) GetBalanceForAccount(ctx context.Context, accountGUID string, endDate string) (Numeric, error) {
+	return d.GetBalanceForAccounts(ctx, []string{accountGUID}, endDate)
+}
+
+// GetBalanceForAccounts returns the sum of all splits across several accounts
+// up to the given date.
+func (d *DB) GetBalanceForAccounts(ctx context.Context, accountGUIDs []string, endDate string) (Numeric, error) {
+	if len(accountGUIDs) == 0 {
+		return Numeric{}, nil
+	}
 	query := `
-		SELECT COALESCE(SUM(s.value_num), 0), COALESCE(MAX(s.value_denom), 100)
+		SELECT SUM(s.value_num), s.value_denom
 		FROM splits s
 		JOIN transactions t ON s.tx_guid = t.guid
-		WHERE s.account_guid = ?
+		WHERE s.account_guid IN (` + placeholders(len(accountGUIDs)) + `)
 	`
-	args := []any{accountGUID}
+	args := make([]any, 0, len(accountGUIDs)+1)
+	for _, guid := range accountGUIDs {
+		args = append(args, guid)
+	}
 	if endDate != "" {
 		query += " AND t.post_date <= ?"
-		args = append(args, endDate+" 23:59:59")
+		args = append(args, d.dayEnd(endDate))
 	}
+	// Values are summed per denominator in SQL, and exactly across them.
+	query += " GROUP BY s.value_denom"
 
-	var num, denom int64
-	err := d.db.QueryRowContext(ctx, query, args...).Scan(&num, &denom)
+	rows, err := d.queryPrepared(ctx, query, args...)
 	if err != nil {
-		return 0, 0, fmt.Errorf("query balance: %w", err)
+		return Numeric{}, fmt.Errorf("query balance: %w", err)
+	}
+	defer rows.Close()
+	var balance Numeric
+	for rows.Next() {
+		var num, denom int64
+		if err := rows.Scan(&num, &denom); err != nil {
+			return Numeric{}, fmt.Errorf("scan balance: %w", err)
+		}
+		balance = balance.Add(NewNumeric(num, denom))
 	}
-	return num, denom, nil
+	if err := rows.Err(); err != nil {
+		return Numeric{}, fmt.Errorf("iterate balance: %w", err)
+	}
+	return balance, nil
+}
+
+// accountDetails is what the accounts table and the account's splits tell
+// about one account beyond the columns of Account.
+type accountDetails struct {
+	Code      string
+	Notes     string
+	Splits    int
+	FirstDate string // YYYY-MM-DD of the first transaction, "" without any
+	LastDate  string
+	Quantity  Numeric // in the account's commodity
 }
 
-func (d *DB) loadBalances(ctx context.Context) (map[string]float64, error) {
+// getAccountDetails returns the code, notes and activity of the account
+// guid.
+func (d *DB) getAccountDetails(ctx context.Context, guid string) (accountDetails, error) {
+	var det accountDetails
+	if ok, err := d.hasColumn(ctx, "accounts", "code"); err != nil {
+		return det, err
+	} else if ok {
+		err := d.queryRowPrepared(ctx, `SELECT COALESCE(code, '') FROM accounts WHERE guid = ?`, guid).Scan(&det.Code)
+		if err != nil {
+			return det, fmt.Errorf("query account code: %w", err)
+		}
+	}
+	if ok, err := d.hasTable(ctx, "slots"); err != nil {
+		return det, err
+	} else if ok {
+		err := d.queryRowPrepared(ctx, `
+			SELECT COALESCE((SELECT string_val FROM slots WHERE obj_guid = ? AND name = 'notes'), '')
+		`, guid).Scan(&det.Notes)
+		if err != nil {
+			return det, fmt.Errorf("query account notes: %w", err)
+		}
+	}
+
+	var first, last string
+	err := d.queryRowPrepared(ctx, `
+		SELECT COUNT(*), COALESCE(MIN(t.post_date), ''), COALESCE(MAX(t.post_date), '')
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE s.account_guid = ?
+	`, guid).Scan(&det.Splits, &first, &last)
+	if err != nil {
+		return det, fmt.Errorf("query account activity: %w", err)
+	}
+	det.FirstDate, det.LastDate = d.day(first), d.day(last)
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT SUM(quantity_num), quantity_denom FROM splits WHERE account_guid = ? GROUP BY quantity_denom
+	`, guid)
+	if err != nil {
+		return det, fmt.Errorf("query account quantity: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var num, denom int64
+		if err := rows.Scan(&num, &denom); err != nil {
+			return det, fmt.Errorf("scan account quantity: %w", err)
+		}
+		det.Quantity = det.Quantity.Add(NewNumeric(num, denom))
+	}
+	if err := rows.Err(); err != nil {
+		return det, fmt.Errorf("iterate account quantity: %w", err)
+	}
+	return det, nil
+}
+
+// placeholders returns n comma-separated SQL bind placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func (d *DB) loadBalances(ctx context.Context) (map[string]Numeric, error) {
 	query := `
-		SELECT account_guid, ROUND(SUM(CAST(value_num AS REAL) / value_denom), 2) 
-		FROM splits 
-		GROUP BY account_guid
+		SELECT account_guid, SUM(value_num), value_denom
+		FROM splits
+		GROUP BY account_guid, value_denom
 	`
 	rows, err := d.db.QueryContext(ctx, query)
 	if err != nil {
@@ -237,209 +468,260 @@ func (d *DB) loadBalances(ctx context.Context) (map[string]float64, error) {
 	}
 	defer rows.Close()
 
-	result := make(map[string]float64)
+	result := make(map[string]Numeric)
 	for rows.Next() {
 		var accGUID string
-		var balance float64
-		if err := rows.Scan(&accGUID, &balance); err != nil {
+		var num, denom int64
+		if err := rows.Scan(&accGUID, &num, &denom); err != nil {
 			return nil, err
 		}
-		result[accGUID] = balance
+		result[accGUID] = result[accGUID].Add(NewNumeric(num, denom))
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
-// SearchTransactions searches transaction descriptions and split memos.
-func (d *DB) SearchTransactions(ctx context.Context, query string, limit int) ([]Transaction, error) {
-	pattern := "%" + strings.ToLower(query) + "%"
+// txAmount is the SQL expression for the amount of transaction t: the total
+// of its debit splits, leaving out those of trading accounts, which would
+// count a currency exchange twice.
+const txAmount = `(
+		SELECT COALESCE(SUM(CAST(x.value_num AS REAL) / x.value_denom), 0)
+		FROM splits x JOIN accounts xa ON xa.guid = x.account_guid
+		WHERE x.tx_guid = t.guid AND x.value_num > 0 AND xa.account_type != 'TRADING')`
+
+// SearchTransactions returns the transactions matching all of q's filters, in
+// q's order, with a single statement, and the cursor of the next page ("" when
+// there is none). q.Text matches descriptions and split memos, unless
+// q.Matches holds its full-text index hits; q.AccountGUIDs
+// and q.ReconcileStates must hold for the same split; positive q.MinAmount and
+// q.MaxAmount bound the transaction amount (see txAmount).
+func (d *DB) SearchTransactions(ctx context.Context, q txQuery) ([]Transaction, string, error) {
+	key := splitSortKey(q.Order.By, txAmount)
+	if q.Order.By == SortByRelevance {
+		key = "m.rank"
+	}
 	sqlQuery := `
-		SELECT DISTINCT t.guid, t.post_date, t.description
+		SELECT t.guid, t.post_date, t.description, ` + key + `
 		FROM transactions t
-		LEFT JOIN splits s ON s.tx_guid = t.guid
-		WHERE LOWER(t.description) LIKE ? OR LOWER(s.memo) LIKE ?
-		ORDER BY t.post_date DESC
-		LIMIT ?
 	`
-	rows, err := d.db.QueryContext(ctx, sqlQuery, pattern, pattern, limit)
+	var args []any
+	if q.Matches != nil {
+		// Join the ranked index hits; the rank is their position in the list.
+		sqlQuery += ` JOIN (SELECT value AS guid, key AS rank FROM json_each(?)) m ON m.guid = t.guid`
+		hits, _ := json.Marshal(q.Matches) // a string slice always marshals
+		args = append(args, string(hits))
+	}
+	sqlQuery += " WHERE 1 = 1"
+	if q.Text != "" && q.Matches == nil {
+		pattern := "%" + strings.ToLower(q.Text) + "%"
+		sqlQuery += ` AND (LOWER(t.description) LIKE ?
+		       OR EXISTS (SELECT 1 FROM splits s WHERE s.tx_guid = t.guid AND LOWER(s.memo) LIKE ?))`
+		args = append(args, pattern, pattern)
+	}
+	if q.StartDate != "" {
+		sqlQuery += " AND t.post_date >= ?"
+		args = append(args, d.dayStart(q.StartDate))
+	}
+	if q.EndDate != "" {
+		sqlQuery += " AND t.post_date <= ?"
+		args = append(args, d.dayEnd(q.EndDate))
+	}
+	if len(q.AccountGUIDs) > 0 || len(q.ReconcileStates) > 0 {
+		sqlQuery += " AND EXISTS (SELECT 1 FROM splits s WHERE s.tx_guid = t.guid"
+		if len(q.AccountGUIDs) > 0 {
+			sqlQuery += " AND s.account_guid IN (" + placeholders(len(q.AccountGUIDs)) + ")"
+			for _, guid := range q.AccountGUIDs {
+				args = append(args, guid)
+			}
+		}
+		if len(q.ReconcileStates) > 0 {
+			sqlQuery += " AND s.reconcile_state IN (" + placeholders(len(q.ReconcileStates)) + ")"
+			for _, state := range q.ReconcileStates {
+				args = append(args, state)
+			}
+		}
+		sqlQuery += ")"
+	}
+	if q.MinAmount > 0 {
+		sqlQuery += " AND " + txAmount + " >= ?"
+		args = append(args, q.MinAmount)
+	}
+	if q.MaxAmount > 0 {
+		sqlQuery += " AND " + txAmount + " <= ?"
+		args = append(args, q.MaxAmount)
+	}
+	if q.After != nil {
+		cond, condArgs := q.Order.after(key, q.After)
+		sqlQuery += cond
+		args = append(args, condArgs...)
+	}
+	sqlQuery += q.Order.orderBy(key)
+	if q.Limit > 0 {
+		// One extra row tells whether another page exists.
+		sqlQuery += fmt.Sprintf(" LIMIT %d", q.Limit+1)
+	}
+	rows, err := d.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("search transactions: %w", err)
+		return nil, "", fmt.Errorf("search transactions: %w", err)
 	}
 	defer rows.Close()
 
-	var txGUIDs []string
-	txMap := make(map[string]*Transaction)
+	var transactions []Transaction
+	var keys []any
 	for rows.Next() {
 		var guid, postDateStr, desc string
-		if err := rows.Scan(&guid, &postDateStr, &desc); err != nil {
-			return nil, fmt.Errorf("scan transaction: %w", err)
+		var key any
+		if err := rows.Scan(&guid, &postDateStr, &desc, &key); err != nil {
+			return nil, "", fmt.Errorf("scan transaction: %w", err)
 		}
-		postDate, _ := parseDate(postDateStr)
-		tx := &Transaction{GUID: guid, PostDate: postDate, Description: desc}
-		txMap[guid] = tx
-		txGUIDs = append(txGUIDs, guid)
+		postDate, _ := d.parseDate(postDateStr)
+		transactions = append(transactions, Transaction{GUID: guid, PostDate: postDate, Description: d.redacted(desc)})
+		keys = append(keys, sortKey(key))
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// Load splits for each transaction
-	for _, guid := range txGUIDs {
-		splits, err := d.getSplitsForTransaction(ctx, guid)
-		if err != nil {
-			return nil, err
-		}
-		txMap[guid].Splits = splits
+	transactions, next := q.page(transactions, keys)
+	txGUIDs := make([]string, len(transactions))
+	for i, tx := range transactions {
+		txGUIDs[i] = tx.GUID
 	}
+	splits, err := d.getSplitsForTransactions(ctx, txGUIDs)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range transactions {
+		transactions[i].Splits = splits[transactions[i].GUID]
+	}
+	return transactions, next, nil
+}
 
-	var transactions []Transaction
-	for _, guid := range txGUIDs {
-		transactions = append(transactions, *txMap[guid])
+// GetTransaction returns the transaction with the given GUID and its splits.
+func (d *DB) GetTransaction(ctx context.Context, guid string) (Transaction, error) {
+	var postDateStr string
+	tx := Transaction{GUID: guid}
+	err := d.db.QueryRowContext(ctx, `
+		SELECT post_date, COALESCE(description, '') FROM transactions WHERE guid = ?
+	`, guid).Scan(&postDateStr, &tx.Description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Transaction{}, fmt.Errorf("no transaction found with GUID '%s'", guid)
+	}
+	if err != nil {
+		return Transaction{}, fmt.Errorf("query transaction: %w", err)
 	}
-	return transactions, nil
+	tx.PostDate, _ = d.parseDate(postDateStr)
+	tx.Description = d.redacted(tx.Description)
+	splits, err := d.getSplitsForTransactions(ctx, []string{guid})
+	if err != nil {
+		return Transaction{}, err
+	}
+	tx.Splits = splits[guid]
+	return tx, nil
 }
 
-func (d *DB) getSplitsForTransaction(ctx context.Context, txGUID string) ([]Split, error) {
+// getSplitsForTransactions loads the splits of several transactions with a
+// single query, keyed by transaction GUID.
+func (d *DB) getSplitsForTransactions(ctx context.Context, txGUIDs []string) (map[string][]Split, error) {
+	splits := make(map[string][]Split, len(txGUIDs))
+	if len(txGUIDs) == 0 {
+		return splits, nil
+	}
+	// A JSON array keeps the statement within SQLite's parameter limit
+	// however many transactions are requested.
+	list, _ := json.Marshal(txGUIDs) // a string slice always marshals
 	rows, err := d.db.QueryContext(ctx, `
 		SELECT s.guid, s.tx_guid, s.account_guid, COALESCE(a.name, ''),
 		       COALESCE(s.memo, ''), s.value_num, s.value_denom
 		FROM splits s
 		JOIN accounts a ON s.account_guid = a.guid
-		WHERE s.tx_guid = ?
-	`, txGUID)
+		WHERE s.tx_guid IN (SELECT value FROM json_each(?))
+	`, string(list))
 	if err != nil {
-		return nil, fmt.Errorf("query splits for tx: %w", err)
+		return nil, fmt.Errorf("query splits for transactions: %w", err)
 	}
 	defer rows.Close()
 
-	var splits []Split
 	for rows.Next() {
 		var s Split
 		if err := rows.Scan(&s.GUID, &s.TxGUID, &s.AccountGUID, &s.AccountName,
 			&s.Memo, &s.ValueNum, &s.ValueDenom); err != nil {
 			return nil, fmt.Errorf("scan split: %w", err)
 		}
-		splits = append(splits, s)
+		s.Memo = d.redacted(s.Memo)
+		splits[s.TxGUID] = append(splits[s.TxGUID], s)
 	}
 	return splits, rows.Err()
 }
 
-// GetExpenseSplits returns all splits for expense accounts in a date range,
-// grouped by account.
-func (d *DB) GetExpenseSplits(ctx context.Context, startDate, endDate string, parentAccountGUID string) (map[string][]Split, map[string]string, error) {
-	query := `
-		SELECT s.value_num, s.value_denom, a.guid, a.name, a.parent_guid
-		FROM splits s
-		JOIN transactions t ON s.tx_guid = t.guid
-		JOIN accounts a ON s.account_guid = a.guid
-		WHERE a.account_type = 'EXPENSE'
-		  AND t.post_date >= ?
-		  AND t.post_date <= ?
-	`
-	args := []any{startDate + " 00:00:00", endDate + " 23:59:59"}
+// location returns the time zone the book's dates are read in.
+func (d *DB) location() *time.Location {
+	if d.loc == nil {
+		return time.UTC
+	}
+	return d.loc
+}
 
-	rows, err := d.db.QueryContext(ctx, query, args...)
+// dayStart returns the stored UTC timestamp of the first second of date
+// (YYYY-MM-DD) in the book's time zone, to compare with post_date.
+func (d *DB) dayStart(date string) string {
+	t, err := time.ParseInLocation("2006-01-02", date, d.location())
 	if err != nil {
-		return nil, nil, fmt.Errorf("query expense splits: %w", err)
+		return date + " 00:00:00"
 	}
-	defer rows.Close()
-
-	// accountGUID -> []Split
-	byAccount := make(map[string][]Split)
-	// accountGUID -> accountName
-	names := make(map[string]string)
-	// accountGUID -> parentGUID
-	parents := make(map[string]string)
+	return d.timestamp(t)
+}
 
-	for rows.Next() {
-		var s Split
-		var accGUID, accName, parentGUID string
-		if err := rows.Scan(&s.ValueNum, &s.ValueDenom, &accGUID, &accName, &parentGUID); err != nil {
-			return nil, nil, fmt.Errorf("scan expense split: %w", err)
-		}
-		s.AccountGUID = accGUID
-		s.AccountName = accName
-		byAccount[accGUID] = append(byAccount[accGUID], s)
-		names[accGUID] = accName
-		parents[accGUID] = parentGUID
-	}
-	if err := rows.Err(); err != nil {
-		return nil, nil, err
+// dayEnd returns the stored UTC timestamp of the last second of date in the
+// book's time zone.
+func (d *DB) dayEnd(date string) string {
+	t, err := time.ParseInLocation("2006-01-02", date, d.location())
+	if err != nil {
+		return date + " 23:59:59"
 	}
+	return d.timestamp(t.AddDate(0, 0, 1).Add(-time.Second))
+}
 
-	// Filter by parent account if specified
-	if parentAccountGUID != "" {
-		filtered := make(map[string][]Split)
-		for guid, splits := range byAccount {
-			if parents[guid] == parentAccountGUID {
-				filtered[guid] = splits
-			}
-		}
-		byAccount = filtered
+// timestamp returns t in UTC the way the book stores timestamps.
+func (d *DB) timestamp(t time.Time) string {
+	if d.legacy {
+		return t.UTC().Format(legacyTimestampLayout)
 	}
-
-	return byAccount, names, nil
+	return t.UTC().Format(timestampLayout)
 }
 
-// GetMonthlyIncomeExpenses returns monthly totals for income and expense accounts.
-func (d *DB) GetMonthlyIncomeExpenses(ctx context.Context, startDate, endDate string) ([]struct {
-	Month   string
-	AccType string
-	Total   int64
-	Denom   int64
-}, error) {
-	rows, err := d.db.QueryContext(ctx, `
-		SELECT strftime('%Y-%m', t.post_date) as month,
-		       a.account_type,
-		       SUM(s.value_num) as total,
-		       MAX(s.value_denom) as denom
-		FROM splits s
-		JOIN transactions t ON s.tx_guid = t.guid
-		JOIN accounts a ON s.account_guid = a.guid
-		WHERE a.account_type IN ('INCOME', 'EXPENSE')
-		  AND t.post_date >= ?
-		  AND t.post_date <= ?
-		GROUP BY month, a.account_type
-		ORDER BY month
-	`, startDate+" 00:00:00", endDate+" 23:59:59")
-	if err != nil {
-		return nil, fmt.Errorf("query monthly totals: %w", err)
-	}
-	defer rows.Close()
+// neutralTime returns the time GnuCash stores for a posting date: 10:59 UTC,
+// which reads as the same day in every time zone.
+func neutralTime(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 10, 59, 0, 0, time.UTC)
+}
 
-	type row struct {
-		Month   string
-		AccType string
-		Total   int64
-		Denom   int64
-	}
-	var results []row
-	for rows.Next() {
-		var r row
-		if err := rows.Scan(&r.Month, &r.AccType, &r.Total, &r.Denom); err != nil {
-			return nil, fmt.Errorf("scan monthly total: %w", err)
-		}
-		results = append(results, r)
-	}
+// parseDate parses a stored UTC timestamp as the wall clock time of the
+// book's time zone, labelled UTC as the dates of reports are. Books saved by
+// older GnuCash versions store the local midnight of a date, which is the
+// day before in UTC east of Greenwich.
+func (d *DB) parseDate(s string) (time.Time, error) {
+	t, err := parseTimestamp(s)
+	if err != nil || d.loc == nil {
+		return t, err
+	}
+	l := t.In(d.loc)
+	return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), 0, time.UTC), nil
+}
 
-	type returnRow = struct {
-		Month   string
-		AccType string
-		Total   int64
-		Denom   int64
-	}
-	var ret []returnRow
-	for _, r := range results {
-		ret = append(ret, returnRow(r))
+// day returns the date (YYYY-MM-DD) of a stored timestamp in the book's
+// time zone, "" when empty.
+func (d *DB) day(timestamp string) string {
+	t, err := d.parseDate(timestamp)
+	if err != nil {
+		return timestamp
 	}
-	return ret, rows.Err()
+	return t.Format("2006-01-02")
 }
 
-func parseDate(s string) (time.Time, error) {
-	// Try the actual DB format first
-	t, err := time.Parse("2006-01-02 15:04:05", s)
+func parseTimestamp(s string) (time.Time, error) {
+	t, err := time.Parse(timestampLayout, s)
 	if err != nil {
-		// Fallback to the format documented in CLAUDE.md
-		t, err = time.Parse("20060102150405", s)
+		t, err = time.Parse(legacyTimestampLayout, s)
 	}
 	return t, err
 }
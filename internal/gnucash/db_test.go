@@ -0,0 +1,298 @@
+package gnucash
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDB_RejectsXMLBook(t *testing.T) {
+	for name, content := range map[string][]byte{
+		"plain.gnucash": []byte(`<?xml version="1.0" encoding="utf-8" ?>`),
+		"gzip.gnucash":  {0x1f, 0x8b, 0x08, 0x00},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, content, 0o600); err != nil {
+				t.Fatalf("write book: %v", err)
+			}
+			_, err := NewDB(path)
+			if !errors.Is(err, ErrXMLBook) {
+				t.Errorf("NewDB(%s) error = %v, want ErrXMLBook", name, err)
+			}
+		})
+	}
+}
+
+func TestQueryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	if _, err := setupTestDB(t).db.Exec(`VACUUM INTO ?`, path); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() returned error: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	// Nothing is logged until a logger is set.
+	if _, err := db.GetAllAccounts(ctx); err != nil {
+		t.Fatalf("GetAllAccounts() returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	db.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if _, err := db.GetAllAccounts(ctx); err != nil {
+		t.Fatalf("GetAllAccounts() returned error: %v", err)
+	}
+	if _, err := db.db.QueryContext(ctx, `SELECT missing FROM accounts WHERE guid = ?`, "root"); err == nil {
+		t.Fatal("expected an error for an unknown column")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 logged queries, got:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[0], "level=DEBUG msg=query sql=\"SELECT") || !strings.Contains(lines[0], "rows=10") {
+		t.Errorf("expected the accounts query with 10 rows, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=WARN") || !strings.Contains(lines[1], "args=[root]") || !strings.Contains(lines[1], "missing") {
+		t.Errorf("expected the failed query with its argument, got %s", lines[1])
+	}
+}
+
+func TestPreparedStatements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	if _, err := setupTestDB(t).db.Exec(`VACUUM INTO ?`, path); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() returned error: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := db.GetAllAccounts(ctx); err != nil {
+			t.Fatalf("GetAllAccounts() returned error: %v", err)
+		}
+		if balance, err := db.GetBalanceForAccount(ctx, "groceries", ""); err != nil || balance.Format(100) != "127.50" {
+			t.Fatalf("GetBalanceForAccount() = %s, %v", balance.Format(100), err)
+		}
+		db.retireConnections() // statements are prepared again on new connections
+	}
+	if n := len(db.stmts.stmts); n != 2 {
+		t.Errorf("expected 2 prepared statements, got %d", n)
+	}
+
+	// Past the limit, queries run unprepared.
+	for i := range maxPrepared {
+		var n int
+		if err := db.queryRowPrepared(ctx, fmt.Sprintf("SELECT %d", i)).Scan(&n); err != nil || n != i {
+			t.Fatalf("query %d returned %d, %v", i, n, err)
+		}
+	}
+	if n := len(db.stmts.stmts); n != maxPrepared {
+		t.Errorf("expected %d prepared statements, got %d", maxPrepared, n)
+	}
+	if _, err := db.queryPrepared(ctx, `SELECT missing FROM accounts`); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "book.gnucash")
+	if _, err := setupTestDB(t).db.Exec(`VACUUM INTO ?`, path); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() returned error: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	count := func() int {
+		t.Helper()
+		accounts, err := db.GetAllAccounts(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAccounts() returned error: %v", err)
+		}
+		return len(accounts)
+	}
+	before := count()
+	if db.Refresh() {
+		t.Fatal("expected no change before the book is saved")
+	}
+	stamp := db.FileStamp()
+
+	// Saved in place, as GnuCash does.
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open book: %v", err)
+	}
+	if _, err := rw.Exec(`INSERT INTO accounts VALUES ('cash', 'Cash', 'CASH', 'assets', '', '', 0, 0)`); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	rw.Close()
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if db.FileStamp() == stamp {
+		t.Error("expected the save to change the file stamp before Refresh")
+	}
+	if !db.Refresh() || db.Version() != 1 {
+		t.Fatalf("expected the save to be seen, version %d", db.Version())
+	}
+	if got := count(); got != before+1 {
+		t.Errorf("expected %d accounts after the save, got %d", before+1, got)
+	}
+
+	// Replaced by another file, as by Save As.
+	other := filepath.Join(dir, "other.gnucash")
+	if _, err := setupTestDB(t).db.Exec(`VACUUM INTO ?`, other); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	if err := os.Rename(other, path); err != nil {
+		t.Fatalf("replace book: %v", err)
+	}
+	if !db.Refresh() || db.Version() != 2 {
+		t.Fatalf("expected the replacement to be seen, version %d", db.Version())
+	}
+	if got := count(); got != before {
+		t.Errorf("expected %d accounts in the replaced book, got %d", before, got)
+	}
+	if db.Refresh() {
+		t.Error("expected no change after the replacement was seen")
+	}
+}
+
+func TestAccountTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	if _, err := setupTestDB(t).db.Exec(`VACUUM INTO ?`, path); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() returned error: %v", err)
+	}
+	defer db.Close()
+	if err := db.EnableWrites(); err != nil {
+		t.Fatalf("EnableWrites() returned error: %v", err)
+	}
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+	tree := func() map[string]*Account {
+		t.Helper()
+		accounts, err := svc.accountTree(ctx)
+		if err != nil {
+			t.Fatalf("accountTree() returned error: %v", err)
+		}
+		return accounts
+	}
+
+	first := tree()
+	if groceries := first["groceries"]; groceries == nil || groceries.FullName != "Expenses:Groceries" {
+		t.Fatalf("expected Expenses:Groceries in the tree, got %+v", groceries)
+	}
+	if second := tree(); second["groceries"] != first["groceries"] {
+		t.Error("expected the tree to be read once while the book is unchanged")
+	}
+
+	// Written in write mode, even within the resolution of file times.
+	if _, err := svc.RenameAccount(ctx, "Groceries", "Food"); err != nil {
+		t.Fatalf("RenameAccount() returned error: %v", err)
+	}
+	if got := tree()["groceries"].FullName; got != "Expenses:Food" {
+		t.Errorf("expected the renamed account, got %s", got)
+	}
+	found, err := db.FindAccountsByName(ctx, "FOO")
+	if err != nil || len(found) != 1 || found[0].FullName != "Expenses:Food" {
+		t.Errorf("FindAccountsByName() = %+v, %v", found, err)
+	}
+}
+
+func TestSnapshotReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	if _, err := setupTestDB(t).db.Exec(`VACUUM INTO ?`, path); err != nil {
+		t.Fatalf("save book: %v", err)
+	}
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() returned error: %v", err)
+	}
+	defer db.Close()
+	if err := db.EnableSnapshotReads(); err != nil {
+		t.Fatalf("EnableSnapshotReads() returned error: %v", err)
+	}
+	if db.readPath() != path {
+		t.Fatalf("expected to read the book while it is closed, read %s", db.readPath())
+	}
+	ctx := context.Background()
+	count := func() int {
+		t.Helper()
+		accounts, err := db.GetAllAccounts(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAccounts() returned error: %v", err)
+		}
+		return len(accounts)
+	}
+	before := count()
+
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open book: %v", err)
+	}
+	defer rw.Close()
+	save := func(query string) {
+		t.Helper()
+		if _, err := rw.Exec(query); err != nil {
+			t.Fatalf("save book: %v", err)
+		}
+		later := time.Now().Add(time.Duration(db.Version()+1) * time.Minute)
+		os.Chtimes(path, later, later)
+		if !db.Refresh() {
+			t.Fatal("expected the save to be seen")
+		}
+	}
+
+	// GnuCash opens the book, then saves a change.
+	save(`CREATE TABLE gnclock (hostname text(255), pid int)`)
+	save(`INSERT INTO gnclock VALUES ('laptop', 4242)`)
+	snapshot := db.readPath()
+	if snapshot == path {
+		t.Fatal("expected to read a snapshot while GnuCash has the book open")
+	}
+	save(`INSERT INTO accounts VALUES ('cash', 'Cash', 'CASH', 'assets', '', '', 0, 0)`)
+	if db.readPath() == snapshot {
+		t.Error("expected a new snapshot after the save")
+	}
+	if _, err := os.Stat(snapshot); !os.IsNotExist(err) {
+		t.Errorf("expected the previous snapshot to be removed, got %v", err)
+	}
+	if got := count(); got != before+1 {
+		t.Errorf("expected %d accounts in the snapshot, got %d", before+1, got)
+	}
+
+	// GnuCash closes the book.
+	save(`DELETE FROM gnclock`)
+	if db.readPath() != path {
+		t.Errorf("expected to read the book once closed, read %s", db.readPath())
+	}
+	if got := count(); got != before+1 {
+		t.Errorf("expected %d accounts in the book, got %d", before+1, got)
+	}
+	dir := db.snapshot.dir
+	db.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the snapshot directory to be removed, got %v", err)
+	}
+}
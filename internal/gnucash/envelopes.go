@@ -0,0 +1,326 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EnvelopeStore keeps envelope budgets, monthly amounts allocated to
+// expense accounts, in a separate, writable SQLite database. The GnuCash
+// book itself is never written to.
+type EnvelopeStore struct {
+	db *sql.DB
+}
+
+// envelope is the allocation history of one expense account, oldest first.
+type envelope struct {
+	AccountGUID string
+	Rollover    bool // unspent or overspent amounts carry over to the next month
+	Allocations []envelopeAllocation
+}
+
+// envelopeAllocation is the monthly amount of an envelope from Month on.
+type envelopeAllocation struct {
+	Month  string // YYYY-MM
+	Amount float64
+}
+
+// allocation returns the amount allocated to e for month, zero before its
+// first allocation.
+func (e envelope) allocation(month string) float64 {
+	var amount float64
+	for _, a := range e.Allocations {
+		if a.Month > month {
+			break
+		}
+		amount = a.Amount
+	}
+	return amount
+}
+
+// OpenEnvelopeStore opens or creates an envelope database at path.
+func OpenEnvelopeStore(path string) (*EnvelopeStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("open envelope store: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS envelopes (
+			account_guid TEXT PRIMARY KEY,
+			rollover INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS envelope_allocations (
+			account_guid TEXT NOT NULL,
+			month TEXT NOT NULL,
+			amount REAL NOT NULL,
+			PRIMARY KEY (account_guid, month)
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create envelope tables: %w", err)
+	}
+	return &EnvelopeStore{db: db}, nil
+}
+
+// Close closes the envelope database.
+func (st *EnvelopeStore) Close() error {
+	return st.db.Close()
+}
+
+// set allocates amount a month to the account from month (YYYY-MM) on.
+func (st *EnvelopeStore) set(ctx context.Context, accountGUID, month string, amount float64, rollover bool) error {
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin envelope: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO envelopes (account_guid, rollover) VALUES (?, ?)
+		ON CONFLICT (account_guid) DO UPDATE SET rollover = excluded.rollover
+	`, accountGUID, rollover); err != nil {
+		return fmt.Errorf("save envelope: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO envelope_allocations (account_guid, month, amount) VALUES (?, ?, ?)
+		ON CONFLICT (account_guid, month) DO UPDATE SET amount = excluded.amount
+	`, accountGUID, month, amount); err != nil {
+		return fmt.Errorf("save envelope allocation: %w", err)
+	}
+	return tx.Commit()
+}
+
+// remove deletes the envelope of the account and reports whether there was
+// one.
+func (st *EnvelopeStore) remove(ctx context.Context, accountGUID string) (bool, error) {
+	res, err := st.db.ExecContext(ctx, `DELETE FROM envelopes WHERE account_guid = ?`, accountGUID)
+	if err != nil {
+		return false, fmt.Errorf("delete envelope: %w", err)
+	}
+	if _, err := st.db.ExecContext(ctx, `DELETE FROM envelope_allocations WHERE account_guid = ?`, accountGUID); err != nil {
+		return false, fmt.Errorf("delete envelope allocations: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// envelopes returns every envelope with its allocations.
+func (st *EnvelopeStore) envelopes(ctx context.Context) ([]envelope, error) {
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT e.account_guid, e.rollover, a.month, a.amount
+		FROM envelopes e
+		JOIN envelope_allocations a ON a.account_guid = e.account_guid
+		ORDER BY e.account_guid, a.month
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query envelopes: %w", err)
+	}
+	defer rows.Close()
+	var envelopes []envelope
+	for rows.Next() {
+		var guid string
+		var rollover bool
+		var a envelopeAllocation
+		if err := rows.Scan(&guid, &rollover, &a.Month, &a.Amount); err != nil {
+			return nil, fmt.Errorf("scan envelope: %w", err)
+		}
+		if len(envelopes) == 0 || envelopes[len(envelopes)-1].AccountGUID != guid {
+			envelopes = append(envelopes, envelope{AccountGUID: guid, Rollover: rollover})
+		}
+		last := &envelopes[len(envelopes)-1]
+		last.Allocations = append(last.Allocations, a)
+	}
+	return envelopes, rows.Err()
+}
+
+// WithEnvelopeStore enables envelope budgeting, kept in st.
+func WithEnvelopeStore(st *EnvelopeStore) Option {
+	return func(s *Service) { s.envelopes = st }
+}
+
+// envelopeStore returns the envelope store, or an error when none is
+// configured.
+func (s *Service) envelopeStore() (*EnvelopeStore, error) {
+	if s.envelopes == nil {
+		return nil, fmt.Errorf("envelope budgeting is not enabled (set GNUCASH_ENVELOPE_DB to a writable SQLite file)")
+	}
+	return s.envelopes, nil
+}
+
+// SetEnvelope allocates amount a month to the expense account accountName,
+// with its sub-accounts, from month (YYYY-MM, the current month when empty)
+// on. With rollover, what is left of a month's envelope, or overspent, is
+// carried over to the next. With remove, the envelope is deleted instead.
+// Envelopes are kept in the envelope store; the book is not changed.
+func (s *Service) SetEnvelope(ctx context.Context, accountName string, amount float64, month string, rollover, remove bool) (string, error) {
+	st, err := s.envelopeStore()
+	if err != nil {
+		return "", err
+	}
+	account, err := s.resolveAccount(ctx, accountName)
+	if err != nil {
+		return "", err
+	}
+	if remove {
+		removed, err := st.remove(ctx, account.GUID)
+		if err != nil {
+			return "", err
+		}
+		if !removed {
+			return "", fmt.Errorf("%s has no envelope", account.FullName)
+		}
+		return fmt.Sprintf("Removed the envelope of %s.", account.FullName), nil
+	}
+	if account.AccountType != "EXPENSE" {
+		return "", fmt.Errorf("%s is a %s account; envelopes are for expense accounts", account.FullName, account.AccountType)
+	}
+	if amount < 0 {
+		return "", fmt.Errorf("the amount of an envelope cannot be negative")
+	}
+	if month == "" {
+		month = s.now().Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return "", fmt.Errorf("invalid month '%s' (expected YYYY-MM)", month)
+	}
+	if err := st.set(ctx, account.GUID, month, amount, rollover); err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	msg := fmt.Sprintf("Envelope of %s: %.2f %s a month from %s", account.FullName, amount, cur.Mnemonic, month)
+	if rollover {
+		msg += "; what is left or overspent carries over to the next month"
+	}
+	return msg + ".", nil
+}
+
+// envelopeLine is the state of one envelope in a month.
+type envelopeLine struct {
+	Account   string
+	Allocated float64
+	Carried   float64 // from the months before, with rollover
+	Spent     float64
+}
+
+// remaining returns what is left in the envelope, negative when overspent.
+func (l envelopeLine) remaining() float64 {
+	return l.Allocated + l.Carried - l.Spent
+}
+
+// EnvelopeStatus reports each envelope in month (YYYY-MM, the current month
+// when empty): the amount allocated, what rolled over from the months
+// before, what the account and its sub-accounts spent in the book, and what
+// is left.
+func (s *Service) EnvelopeStatus(ctx context.Context, month, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	st, err := s.envelopeStore()
+	if err != nil {
+		return "", err
+	}
+	if month == "" {
+		month = s.now().Format("2006-01")
+	}
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return "", fmt.Errorf("invalid month '%s' (expected YYYY-MM)", month)
+	}
+	envelopes, err := st.envelopes(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(envelopes) == 0 {
+		return "No envelopes set. Use set_envelope to allocate a monthly amount to an expense account.", nil
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Spending by account for every month from the first allocation.
+	first := month
+	for _, e := range envelopes {
+		if e.Allocations[0].Month < first {
+			first = e.Allocations[0].Month
+		}
+	}
+	spending := make(map[string]map[string]float64)
+	from, _ := time.Parse("2006-01", first)
+	for m := from; !m.After(start); m = m.AddDate(0, 1, 0) {
+		totals, err := s.db.GetAccountTotals(ctx, []string{"EXPENSE"}, m.Format("2006-01-02"), m.AddDate(0, 1, -1).Format("2006-01-02"), s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		spending[m.Format("2006-01")] = totals
+	}
+	spent := func(acc *Account, month string) float64 {
+		var total float64
+		for _, guid := range descendantGUIDs(acc) {
+			total += spending[month][guid]
+		}
+		return total
+	}
+
+	var lines []envelopeLine
+	var total envelopeLine
+	for _, e := range envelopes {
+		acc := accounts[e.AccountGUID]
+		if acc == nil || e.Allocations[0].Month > month {
+			continue // account deleted from the book, or envelope not started
+		}
+		l := envelopeLine{Account: acc.FullName, Allocated: e.allocation(month), Spent: spent(acc, month)}
+		if e.Rollover {
+			from, _ := time.Parse("2006-01", e.Allocations[0].Month)
+			for m := from; m.Before(start); m = m.AddDate(0, 1, 0) {
+				l.Carried += e.allocation(m.Format("2006-01")) - spent(acc, m.Format("2006-01"))
+			}
+		}
+		lines = append(lines, l)
+		total.Allocated += l.Allocated
+		total.Carried += l.Carried
+		total.Spent += l.Spent
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("No envelope covers %s.", month), nil
+	}
+	slices.SortFunc(lines, func(a, b envelopeLine) int { return strings.Compare(a.Account, b.Account) })
+
+	if format != FormatText {
+		t := table{Headers: []string{"envelope", "allocated", "carried", "spent", "remaining"}}
+		for _, l := range lines {
+			t.add(l.Account, fmt.Sprintf("%.2f", l.Allocated), fmt.Sprintf("%.2f", l.Carried), fmt.Sprintf("%.2f", l.Spent), fmt.Sprintf("%.2f", l.remaining()))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Envelopes for %s, in %s:\n\n", month, cur.Mnemonic)
+	fmt.Fprintf(&sb, "  %-40s %12s %12s %12s %12s\n", "Envelope", "Allocated", "Carried", "Spent", "Remaining")
+	var overspent []string
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "  %-40s %12.2f %12.2f %12.2f %12.2f", l.Account, l.Allocated, l.Carried, l.Spent, l.remaining())
+		if cents(l.remaining()) < 0 {
+			sb.WriteString("  overspent")
+			overspent = append(overspent, l.Account)
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "  %-40s %12.2f %12.2f %12.2f %12.2f\n", "Total", total.Allocated, total.Carried, total.Spent, total.remaining())
+	if len(overspent) > 0 {
+		fmt.Fprintf(&sb, "\nOverspent: %s\n", strings.Join(overspent, ", "))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,218 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// equityMovement is the activity of an equity account around a period,
+// credits positive as equity is credit-normal.
+type equityMovement struct {
+	Opening float64 // balance before the period
+	Credits float64 // credited in the period
+	Debits  float64 // debited in the period, positive
+}
+
+// getEquityMovements returns the balance of each EQUITY account before
+// startDate and what was credited and debited to it from startDate to
+// endDate.
+func (d *DB) getEquityMovements(ctx context.Context, startDate, endDate string, includeClosing bool) (map[string]equityMovement, error) {
+	closing, err := d.closingFilter(ctx, includeClosing)
+	if err != nil {
+		return nil, err
+	}
+	start := d.dayStart(startDate)
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT s.account_guid,
+		       SUM(CASE WHEN t.post_date < ? THEN -CAST(s.value_num AS REAL) / s.value_denom ELSE 0 END),
+		       SUM(CASE WHEN t.post_date >= ? AND s.value_num < 0 THEN -CAST(s.value_num AS REAL) / s.value_denom ELSE 0 END),
+		       SUM(CASE WHEN t.post_date >= ? AND s.value_num > 0 THEN CAST(s.value_num AS REAL) / s.value_denom ELSE 0 END)
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		WHERE a.account_type = 'EQUITY' AND t.post_date <= ?`+closing+`
+		GROUP BY s.account_guid
+	`, start, start, start, d.dayEnd(endDate))
+	if err != nil {
+		return nil, fmt.Errorf("query equity movements: %w", err)
+	}
+	defer rows.Close()
+	movements := make(map[string]equityMovement)
+	for rows.Next() {
+		var guid string
+		var m equityMovement
+		if err := rows.Scan(&guid, &m.Opening, &m.Credits, &m.Debits); err != nil {
+			return nil, fmt.Errorf("scan equity movement: %w", err)
+		}
+		movements[guid] = m
+	}
+	return movements, rows.Err()
+}
+
+// getOpeningBalanceAccounts returns the GUIDs of the accounts GnuCash marks
+// as holding opening balances (the "equity-type" slot it sets on the
+// account it creates for them).
+func (d *DB) getOpeningBalanceAccounts(ctx context.Context) ([]string, error) {
+	if ok, err := d.hasTable(ctx, "slots"); err != nil || !ok {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT obj_guid FROM slots WHERE name = 'equity-type' AND string_val = 'opening-balance'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query opening balance accounts: %w", err)
+	}
+	defer rows.Close()
+	var guids []string
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, fmt.Errorf("scan opening balance account: %w", err)
+		}
+		guids = append(guids, guid)
+	}
+	return guids, rows.Err()
+}
+
+// equityLine is one line of the equity statement.
+type equityLine struct {
+	Name            string
+	Opening         float64
+	OpeningBalances float64 // opening balances entered in the period
+	Contributions   float64
+	Draws           float64 // positive
+	NetIncome       float64
+}
+
+// closing returns the balance of the line at the end of the period.
+func (l equityLine) closing() float64 {
+	return l.Opening + l.OpeningBalances + l.Contributions - l.Draws + l.NetIncome
+}
+
+// add adds the amounts of o to l.
+func (l *equityLine) add(o equityLine) {
+	l.Opening += o.Opening
+	l.OpeningBalances += o.OpeningBalances
+	l.Contributions += o.Contributions
+	l.Draws += o.Draws
+	l.NetIncome += o.NetIncome
+}
+
+// EquityStatement reports the movement of equity between startDate (the
+// start of endDate's year when empty) and endDate (today when empty): the
+// equity at the start, the opening balances entered in the period, capital
+// contributions (credits to the other equity accounts) and draws (debits),
+// and the net income of the period, which moves retained earnings. Opening
+// balances are those of the account GnuCash marks for them, or of equity
+// accounts named "Opening Balances". Retained earnings are the income less
+// the expenses of the book; as closing transactions are left out (see
+// closingFilter), what Close Book moved to equity stays in them.
+func (s *Service) EquityStatement(ctx context.Context, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid end date '%s': %w", endDate, err)
+	}
+	if startDate == "" {
+		startDate = fmt.Sprintf("%d-01-01", end.Year())
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid start date '%s': %w", startDate, err)
+	}
+	if start.After(end) {
+		return "", fmt.Errorf("start date %s is after end date %s", startDate, endDate)
+	}
+	before := start.AddDate(0, 0, -1).Format("2006-01-02")
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	movements, err := s.db.getEquityMovements(ctx, startDate, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	marked, err := s.db.getOpeningBalanceAccounts(ctx)
+	if err != nil {
+		return "", err
+	}
+	earnedBefore, err := s.db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, "", before, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	earned, err := s.db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, startDate, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []equityLine
+	for guid, m := range movements {
+		acc := accounts[guid]
+		if acc == nil || (cents(m.Opening) == 0 && cents(m.Credits) == 0 && cents(m.Debits) == 0) {
+			continue
+		}
+		l := equityLine{Name: acc.FullName, Opening: m.Opening}
+		if slices.Contains(marked, guid) || strings.HasPrefix(strings.ToLower(acc.Name), "opening balance") {
+			l.OpeningBalances = m.Credits - m.Debits
+		} else {
+			l.Contributions, l.Draws = m.Credits, m.Debits
+		}
+		lines = append(lines, l)
+	}
+	slices.SortFunc(lines, func(a, b equityLine) int { return strings.Compare(a.Name, b.Name) })
+	retained := equityLine{Name: "Retained earnings"}
+	for _, v := range earnedBefore {
+		retained.Opening -= v // income is credited
+	}
+	for _, v := range earned {
+		retained.NetIncome -= v
+	}
+	lines = append(lines, retained)
+	total := equityLine{Name: "Total equity"}
+	for _, l := range lines {
+		total.add(l)
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"component", "opening", "opening_balances", "contributions", "draws", "net_income", "closing"}}
+		for _, l := range append(lines, total) {
+			t.add(l.Name, fmt.Sprintf("%.2f", l.Opening), fmt.Sprintf("%.2f", l.OpeningBalances), fmt.Sprintf("%.2f", l.Contributions),
+				fmt.Sprintf("%.2f", l.Draws), fmt.Sprintf("%.2f", l.NetIncome), fmt.Sprintf("%.2f", l.closing()))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Equity statement from %s to %s, in %s:\n\n", startDate, endDate, cur.Mnemonic)
+	fmt.Fprintf(&sb, "  %-40s %14.2f\n", "Equity at "+before, total.Opening)
+	for _, l := range lines {
+		if cents(l.Opening) != 0 {
+			fmt.Fprintf(&sb, "    %-38s %14.2f\n", l.Name, l.Opening)
+		}
+	}
+	fmt.Fprintf(&sb, "  %-40s %+14.2f\n", "Opening balances entered", total.OpeningBalances)
+	fmt.Fprintf(&sb, "  %-40s %+14.2f\n", "Capital contributions", total.Contributions)
+	fmt.Fprintf(&sb, "  %-40s %+14.2f\n", "Draws", -total.Draws)
+	fmt.Fprintf(&sb, "  %-40s %+14.2f\n", "Net income", total.NetIncome)
+	fmt.Fprintf(&sb, "  %-40s %14.2f\n", "Equity at "+endDate, total.closing())
+	for _, l := range lines {
+		if cents(l.closing()) != 0 {
+			fmt.Fprintf(&sb, "    %-38s %14.2f\n", l.Name, l.closing())
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,365 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exports write the book, or part of it, as files in the export directory
+// for other accounting tools.
+
+// exportSplit is a split with its value in the transaction currency and its
+// quantity in the commodity of its account.
+type exportSplit struct {
+	GUID          string
+	AccountGUID   string
+	Memo          string
+	ValueNum      int64
+	ValueDenom    int64
+	QuantityNum   int64
+	QuantityDenom int64
+}
+
+// exportTransaction is a transaction with all its splits.
+type exportTransaction struct {
+	GUID         string
+	CurrencyGUID string
+	PostDate     time.Time
+	Description  string
+	Splits       []exportSplit
+}
+
+// getExportTransactions returns the transactions posted between startDate
+// and endDate (either may be empty) with all their splits, oldest first.
+// When accountGUIDs is not empty, only transactions with a split in one of
+// those accounts are returned.
+func (d *DB) getExportTransactions(ctx context.Context, startDate, endDate string, accountGUIDs []string) ([]exportTransaction, error) {
+	query := `
+		SELECT t.guid, t.currency_guid, t.post_date, COALESCE(t.description, ''),
+		       s.guid, s.account_guid, COALESCE(s.memo, ''),
+		       s.value_num, s.value_denom, s.quantity_num, s.quantity_denom
+		FROM transactions t
+		JOIN splits s ON s.tx_guid = t.guid
+		WHERE 1 = 1
+	`
+	var args []any
+	if startDate != "" {
+		query += " AND t.post_date >= ?"
+		args = append(args, d.dayStart(startDate))
+	}
+	if endDate != "" {
+		query += " AND t.post_date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	if len(accountGUIDs) > 0 {
+		list, _ := json.Marshal(accountGUIDs) // a string slice always marshals
+		query += " AND t.guid IN (SELECT tx_guid FROM splits WHERE account_guid IN (SELECT value FROM json_each(?)))"
+		args = append(args, string(list))
+	}
+	query += " ORDER BY t.post_date, t.enter_date, t.guid, s.rowid"
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var txs []exportTransaction
+	for rows.Next() {
+		var tx exportTransaction
+		var sp exportSplit
+		var postDate string
+		if err := rows.Scan(&tx.GUID, &tx.CurrencyGUID, &postDate, &tx.Description,
+			&sp.GUID, &sp.AccountGUID, &sp.Memo, &sp.ValueNum, &sp.ValueDenom, &sp.QuantityNum, &sp.QuantityDenom); err != nil {
+			return nil, fmt.Errorf("scan transaction: %w", err)
+		}
+		sp.Memo = d.redacted(sp.Memo)
+		if n := len(txs); n > 0 && txs[n-1].GUID == tx.GUID {
+			txs[n-1].Splits = append(txs[n-1].Splits, sp)
+			continue
+		}
+		tx.PostDate, _ = d.parseDate(postDate)
+		tx.Description = d.redacted(tx.Description)
+		tx.Splits = []exportSplit{sp}
+		txs = append(txs, tx)
+	}
+	return txs, rows.Err()
+}
+
+// bookTransactions leaves out the templates of scheduled transactions,
+// whose splits are in template accounts.
+func bookTransactions(txs []exportTransaction, accounts map[string]*Account) []exportTransaction {
+	return slices.DeleteFunc(txs, func(tx exportTransaction) bool {
+		return slices.ContainsFunc(tx.Splits, func(sp exportSplit) bool {
+			_, ok := accounts[sp.AccountGUID]
+			return !ok
+		})
+	})
+}
+
+// getCommodities returns every commodity of the book by GUID.
+func (d *DB) getCommodities(ctx context.Context) (map[string]Commodity, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT guid, namespace, mnemonic, COALESCE(fullname, ''), COALESCE(cusip, ''), fraction
+		FROM commodities
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query commodities: %w", err)
+	}
+	defer rows.Close()
+
+	commodities := make(map[string]Commodity)
+	for rows.Next() {
+		var c Commodity
+		if err := rows.Scan(&c.GUID, &c.Namespace, &c.Mnemonic, &c.FullName, &c.CUSIP, &c.Fraction); err != nil {
+			return nil, fmt.Errorf("scan commodity: %w", err)
+		}
+		commodities[c.GUID] = c
+	}
+	return commodities, rows.Err()
+}
+
+// getAllPrices returns the prices of all commodities between startDate and
+// endDate (either may be empty), oldest first.
+func (d *DB) getAllPrices(ctx context.Context, commodities map[string]Commodity, startDate, endDate string) ([]Price, error) {
+	query := `
+		SELECT p.commodity_guid, p.date, cur.mnemonic, p.value_num, p.value_denom
+		FROM prices p
+		JOIN commodities cur ON p.currency_guid = cur.guid
+		WHERE 1 = 1
+	`
+	var args []any
+	if startDate != "" {
+		query += " AND p.date >= ?"
+		args = append(args, d.dayStart(startDate))
+	}
+	if endDate != "" {
+		query += " AND p.date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += " ORDER BY p.date, cur.mnemonic"
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []Price
+	for rows.Next() {
+		var p Price
+		var commodityGUID, dateStr string
+		if err := rows.Scan(&commodityGUID, &dateStr, &p.Currency, &p.ValueNum, &p.ValueDenom); err != nil {
+			return nil, fmt.Errorf("scan price: %w", err)
+		}
+		p.Commodity = commodities[commodityGUID]
+		p.Date, _ = d.parseDate(dateStr)
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// getOpeningQuantities returns the balance of every account with splits
+// posted before date, in the commodity of the account.
+func (d *DB) getOpeningQuantities(ctx context.Context, date string) (map[string]float64, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT s.account_guid, SUM(CAST(s.quantity_num AS REAL) / s.quantity_denom)
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE t.post_date < ?
+		GROUP BY s.account_guid
+	`, d.dayStart(date))
+	if err != nil {
+		return nil, fmt.Errorf("query opening balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := make(map[string]float64)
+	for rows.Next() {
+		var guid string
+		var quantity float64
+		if err := rows.Scan(&guid, &quantity); err != nil {
+			return nil, fmt.Errorf("scan opening balance: %w", err)
+		}
+		balances[guid] = quantity
+	}
+	return balances, rows.Err()
+}
+
+// accountCommodity returns the GUID of the commodity of an account's
+// quantities; accounts without one are kept in currencyGUID.
+func accountCommodity(acc *Account, currencyGUID string) string {
+	if acc.CommodityGUID != "" {
+		return acc.CommodityGUID
+	}
+	return currencyGUID
+}
+
+// openingBalance is a balance carried into an export's period.
+type openingBalance struct {
+	Account   *Account // nil for the opposite total of a commodity
+	Commodity Commodity
+	Quantity  float64
+}
+
+// Amount formats the balance to the fraction of its commodity.
+func (b openingBalance) Amount() string {
+	return strconv.FormatFloat(b.Quantity, 'f', fractionDigits(b.Commodity.Fraction), 64)
+}
+
+// openingBalances returns the balances at startDate of the asset, liability
+// and equity accounts include accepts, by account name, and their opposite
+// total per commodity, to be booked to an opening balances account. Income
+// and expense accounts are left out: their past balances are part of
+// equity. Accounts without a commodity are taken to be in currencyGUID.
+func (s *Service) openingBalances(ctx context.Context, startDate string, accounts map[string]*Account,
+	commodities map[string]Commodity, currencyGUID string, include func(*Account) bool) ([]openingBalance, []openingBalance, error) {
+	quantities, err := s.db.getOpeningQuantities(ctx, startDate)
+	if err != nil {
+		return nil, nil, err
+	}
+	var balances []openingBalance
+	totals := make(map[string]float64)
+	for guid, quantity := range quantities {
+		acc, ok := accounts[guid]
+		if !ok || acc.AccountType == "INCOME" || acc.AccountType == "EXPENSE" || !include(acc) {
+			continue
+		}
+		b := openingBalance{Account: acc, Commodity: commodities[accountCommodity(acc, currencyGUID)], Quantity: quantity}
+		if strings.Trim(b.Amount(), "-0.") == "" {
+			continue
+		}
+		balances = append(balances, b)
+		totals[b.Commodity.GUID] += quantity
+	}
+	slices.SortFunc(balances, func(a, b openingBalance) int { return cmp.Compare(a.Account.FullName, b.Account.FullName) })
+
+	var opposite []openingBalance
+	for _, c := range sortedCommodities(commodities) {
+		if q, ok := totals[c.GUID]; ok {
+			opposite = append(opposite, openingBalance{Commodity: c, Quantity: -q})
+		}
+	}
+	return balances, opposite, nil
+}
+
+// sortedCommodities returns the commodities currencies first, then by
+// mnemonic.
+func sortedCommodities(commodities map[string]Commodity) []Commodity {
+	sorted := make([]Commodity, 0, len(commodities))
+	for _, c := range commodities {
+		sorted = append(sorted, c)
+	}
+	slices.SortFunc(sorted, func(a, b Commodity) int {
+		return cmp.Or(cmp.Compare(currencyRank(a), currencyRank(b)), cmp.Compare(a.Mnemonic, b.Mnemonic),
+			cmp.Compare(a.Namespace, b.Namespace))
+	})
+	return sorted
+}
+
+func currencyRank(c Commodity) int {
+	if c.Namespace == "CURRENCY" {
+		return 0
+	}
+	return 1
+}
+
+// checkExportPeriod validates the optional dates of an export.
+func checkExportPeriod(startDate, endDate string) error {
+	if startDate != "" {
+		if _, err := time.Parse("2006-01-02", startDate); err != nil {
+			return fmt.Errorf("invalid start_date '%s' (expected YYYY-MM-DD)", startDate)
+		}
+	}
+	if endDate != "" {
+		if _, err := time.Parse("2006-01-02", endDate); err != nil {
+			return fmt.Errorf("invalid end_date '%s' (expected YYYY-MM-DD)", endDate)
+		}
+	}
+	if startDate != "" && endDate != "" && endDate < startDate {
+		return fmt.Errorf("end_date %s is before start_date %s", endDate, startDate)
+	}
+	return nil
+}
+
+// checkExports reports whether exports are enabled.
+func (s *Service) checkExports() error {
+	if s.exportDir == "" {
+		return fmt.Errorf("exports are disabled (set GNUCASH_EXPORT_DIR to enable)")
+	}
+	return nil
+}
+
+// writeExport writes content to a new file of the export directory named
+// after the export, its period and the current time, and returns its path.
+func (s *Service) writeExport(name, startDate, endDate, ext, content string) (string, error) {
+	parts := []string{name}
+	for _, date := range []string{startDate, endDate} {
+		if date != "" {
+			parts = append(parts, date)
+		}
+	}
+	parts = append(parts, time.Now().Format("20060102T150405"))
+	if err := os.MkdirAll(s.exportDir, 0o755); err != nil {
+		return "", fmt.Errorf("create export directory: %w", err)
+	}
+	path := filepath.Join(s.exportDir, strings.Join(parts, "_")+"."+ext)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return path, nil
+}
+
+// exportName turns an account path into a file name ("Assets-Checking").
+func exportName(fullName string) string {
+	return strings.NewReplacer(":", "-", " ", "-", "/", "-", `\`, "-").Replace(fullName)
+}
+
+// periodLabel describes an export's period.
+func periodLabel(startDate, endDate string) string {
+	switch {
+	case startDate == "" && endDate == "":
+		return "all dates"
+	case startDate == "":
+		return "up to " + endDate
+	case endDate == "":
+		return "from " + startDate
+	}
+	return startDate + " to " + endDate
+}
+
+// exactDecimal formats num/denom without rounding when denom is a power of
+// ten, as GnuCash denominators are, and to 8 decimals otherwise.
+func exactDecimal(num, denom int64) string {
+	if denom <= 0 {
+		return "0"
+	}
+	digits, d := 0, denom
+	for ; d > 1 && d%10 == 0; d /= 10 {
+		digits++
+	}
+	if d != 1 {
+		s := big.NewRat(num, denom).FloatString(8)
+		return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return big.NewRat(num, denom).FloatString(digits)
+}
+
+// fractionDigits returns the number of decimals of a commodity fraction
+// (2 for 100).
+func fractionDigits(fraction int64) int {
+	digits := 0
+	for ; fraction > 1; fraction /= 10 {
+		digits++
+	}
+	return digits
+}
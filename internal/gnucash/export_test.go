@@ -0,0 +1,253 @@
+package gnucash
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readExport returns the content of the only file of dir with the given
+// extension.
+func readExport(t *testing.T, dir, ext string) string {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, "*."+ext))
+	if len(matches) != 1 {
+		t.Fatalf("expected one .%s file in the export directory, got %v", ext, matches)
+	}
+	content, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	os.Remove(matches[0])
+	return string(content)
+}
+
+func TestExportBeancount(t *testing.T) {
+	db := setupTestDB(t)
+	dir := t.TempDir()
+	svc := NewService(db, WithExportDir(dir))
+	ctx := context.Background()
+
+	result, err := svc.ExportBeancount(ctx, "", "")
+	if err != nil {
+		t.Fatalf("ExportBeancount() returned error: %v", err)
+	}
+	if !strings.Contains(result, "6 account(s), 6 transaction(s), 2 price(s)") {
+		t.Errorf("unexpected summary: %s", result)
+	}
+	content := readExport(t, dir, "beancount")
+	for _, want := range []string{
+		`option "operating_currency" "EUR"`,
+		"2025-01-10 commodity ACME\n  name: \"ACME Corporation\"\n  code: \"US0000000001\"",
+		"2025-01-10 open Assets:Checking\n  description: \"Main checking account\"",
+		"2025-01-10 open Assets:Investments:Brokerage-Cash",
+		"2025-01-20 * \"Supermarket\"\n  guid: \"tx2\"\n  Assets:Checking  -85.50 EUR\n  Expenses:Groceries  85.50 EUR",
+		"  Assets:Investments:ACME  10.00 ACME @@ 1000.00 EUR",
+		"2025-02-20 price ACME 120.00 EUR",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in export:\n%s", want, content)
+		}
+	}
+
+	// A later start opens the balances carried from before it.
+	if _, err := svc.ExportBeancount(ctx, "2025-02-01", "2025-02-28"); err != nil {
+		t.Fatalf("ExportBeancount() returned error: %v", err)
+	}
+	content = readExport(t, dir, "beancount")
+	for _, want := range []string{
+		"2025-02-01 open Equity:Opening-Balances",
+		"2025-02-01 * \"Opening balances\"\n  Assets:Checking  2889.50 EUR\n  Assets:Investments:ACME  10.0000 ACME\n  Assets:Investments:Brokerage-Cash  -1000.00 EUR\n  Equity:Opening-Balances  -1889.50 EUR\n  Equity:Opening-Balances  -10.0000 ACME",
+		"2025-02-05 * \"Market\"",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in export:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "Supermarket") {
+		t.Errorf("expected January transactions left out:\n%s", content)
+	}
+
+	if _, err := NewService(db).ExportBeancount(ctx, "", ""); err == nil {
+		t.Error("expected an error without an export directory")
+	}
+}
+
+func TestBeancountNames(t *testing.T) {
+	for in, want := range map[string]string{
+		"Checking":         "Checking",
+		"food & drinks":    "Food-drinks",
+		"Épargne Logement": "Épargne-Logement",
+		"401(k)":           "401-k",
+		"":                 "X",
+	} {
+		if got := beancountComponent(in); got != want {
+			t.Errorf("beancountComponent(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{"EUR": "EUR", "brk.b": "BRK.B", "1INCH": "X1INCH", "S&P 500": "S-P-500"} {
+		if got := beancountCommodity(in); got != want {
+			t.Errorf("beancountCommodity(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExportLedger(t *testing.T) {
+	db := setupTestDB(t)
+	dir := t.TempDir()
+	svc := NewService(db, WithExportDir(dir))
+	ctx := context.Background()
+
+	result, err := svc.ExportLedger(ctx, "Investments", "", "")
+	if err != nil {
+		t.Fatalf("ExportLedger() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Ledger journal of Assets:Investments and its sub-accounts (all dates)") ||
+		!strings.Contains(result, "2 account(s), 1 transaction(s), 2 price(s)") {
+		t.Errorf("unexpected summary: %s", result)
+	}
+	content := readExport(t, dir, "journal")
+	for _, want := range []string{
+		"commodity ACME\n    note ACME Corporation US0000000001\n    format 1000.0000 ACME",
+		"account Assets:Investments:Brokerage Cash  ; type: C",
+		"2025-01-10 Buy ACME\n    ; guid: tx6\n" +
+			"    Assets:Investments:ACME            10.00 ACME {100.00 EUR} [2025-01-10] @@ 1000.00 EUR\n" +
+			"    Assets:Investments:Brokerage Cash  -1000.00 EUR\n",
+		"P 2025-02-20 ACME 120.00 EUR",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in export:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "Supermarket") {
+		t.Errorf("expected transactions of other accounts left out:\n%s", content)
+	}
+
+	// With a start date, the subtree's balances are opened.
+	if _, err := svc.ExportLedger(ctx, "Checking", "2025-02-01", ""); err != nil {
+		t.Fatalf("ExportLedger() returned error: %v", err)
+	}
+	content = readExport(t, dir, "journal")
+	for _, want := range []string{
+		"2025-02-01 Opening balances\n    Assets:Checking          2889.50 EUR\n    Equity:Opening Balances  -2889.50 EUR\n",
+		"2025-02-05 Market\n    ; guid: tx3\n    Assets:Checking     -42.00 EUR\n    Expenses:Groceries  42.00 EUR\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in export:\n%s", want, content)
+		}
+	}
+
+	if _, err := svc.ExportLedger(ctx, "", "2025-03-01", "2025-01-01"); err == nil {
+		t.Error("expected an error for an end date before the start date")
+	}
+}
+
+func TestExportAccount(t *testing.T) {
+	db := setupTestDB(t)
+	dir := t.TempDir()
+	svc := NewService(db, WithExportDir(dir))
+	ctx := context.Background()
+
+	result, err := svc.ExportAccount(ctx, "Checking", AccountExportQIF, "", "2025-01-31", "DD/MM/YYYY")
+	if err != nil {
+		t.Fatalf("ExportAccount() returned error: %v", err)
+	}
+	if !strings.Contains(result, "QIF register of Assets:Checking (up to 2025-01-31)") || !strings.Contains(result, ": 3 transaction(s).") {
+		t.Errorf("unexpected summary: %s", result)
+	}
+	content := readExport(t, dir, "qif")
+	for _, want := range []string{
+		"!Account\nNAssets:Checking\nTBank\nDMain checking account\n^\n!Type:Bank\n",
+		"D20/1/2025\nT-85.50\nPSupermarket\nLExpenses:Groceries\n^\n",
+		"D15/1/2025\nT3000.00\nPJanuary salary\nLIncome:Salary\n^\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in export:\n%s", want, content)
+		}
+	}
+	// The file reads back with the QIF importer.
+	rows, failures, err := parseQIF(content, "DD/MM/YYYY")
+	if err != nil || len(rows) != 3 || len(failures) != 0 {
+		t.Errorf("expected the export to read back as 3 rows, got %d rows, %v, %v", len(rows), failures, err)
+	}
+
+	if _, err := svc.ExportAccount(ctx, "Checking", AccountExportOFX, "", "", ""); err != nil {
+		t.Fatalf("ExportAccount() returned error: %v", err)
+	}
+	content = readExport(t, dir, "ofx")
+	for _, want := range []string{
+		"<CURDEF>EUR</CURDEF>",
+		"<TRNTYPE>DEBIT</TRNTYPE>\n<DTPOSTED>20250120</DTPOSTED>\n<TRNAMT>-85.50</TRNAMT>\n<FITID>sp2a</FITID>\n<NAME>Supermarket</NAME>",
+		"<LEDGERBAL><BALAMT>5847.50</BALAMT><DTASOF>20250215</DTASOF></LEDGERBAL>",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected %q in export:\n%s", want, content)
+		}
+	}
+	st, err := parseOFX(content)
+	if err != nil || len(st.Rows) != 5 || st.Currency != "EUR" {
+		t.Errorf("expected the export to read back as 5 EUR rows, got %d rows in %q, %v", len(st.Rows), st.Currency, err)
+	}
+
+	if _, err := svc.ExportAccount(ctx, "Groceries", AccountExportQIF, "", "", ""); err == nil {
+		t.Error("expected an error for an expense account")
+	}
+	if _, err := svc.ExportAccount(ctx, "Checking", "csv", "", "", ""); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	db := setupTestDB(t)
+	if _, err := db.db.Exec(`
+		CREATE TABLE budgets (guid TEXT PRIMARY KEY, name TEXT, description TEXT, num_periods INTEGER);
+		CREATE TABLE recurrences (id INTEGER PRIMARY KEY, obj_guid TEXT, recurrence_mult INTEGER,
+			recurrence_period_type TEXT, recurrence_period_start TEXT, recurrence_weekend_adjust TEXT);
+		CREATE TABLE budget_amounts (id INTEGER PRIMARY KEY, budget_guid TEXT, account_guid TEXT,
+			period_num INTEGER, amount_num INTEGER, amount_denom INTEGER);
+		INSERT INTO budgets VALUES ('b1', '2025', 'Household budget', 12);
+		INSERT INTO recurrences VALUES (1, 'b1', 1, 'month', '20250101', 'none');
+		INSERT INTO budget_amounts VALUES (1, 'b1', 'groceries', 0, 30000, 100);
+		INSERT INTO budget_amounts VALUES (2, 'b1', 'groceries', 1, 25000, 100);
+	`); err != nil {
+		t.Fatalf("create budgets: %v", err)
+	}
+	dir := t.TempDir()
+	svc := NewService(db, WithExportDir(dir))
+	ctx := context.Background()
+
+	result, err := svc.ExportJSON(ctx, "2025-02-01", "")
+	if err != nil {
+		t.Fatalf("ExportJSON() returned error: %v", err)
+	}
+	if !strings.Contains(result, "10 account(s), 2 commodities, 2 transaction(s) with 4 split(s), 1 price(s), 1 budget(s)") {
+		t.Errorf("unexpected summary: %s", result)
+	}
+	var doc bookExport
+	if err := json.Unmarshal([]byte(readExport(t, dir, "json")), &doc); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if doc.StartDate != "2025-02-01" || doc.Transactions[0].Date != "2025-02-05" ||
+		doc.Transactions[0].Splits[0] != (jsonSplit{GUID: "sp3a", AccountGUID: "checking", Account: "Assets:Checking", Value: "-42.00", Quantity: "-42.00"}) {
+		t.Errorf("unexpected transactions: %+v", doc.Transactions)
+	}
+	if len(doc.OpeningBalances) != 3 || doc.OpeningBalances[1] != (jsonOpeningBalance{AccountGUID: "acme-stock", Account: "Assets:Investments:ACME", Commodity: "ACME", Quantity: "10.0000"}) {
+		t.Errorf("unexpected opening balances: %+v", doc.OpeningBalances)
+	}
+	b := doc.Budgets[0]
+	if b.Name != "2025" || b.Periods != 12 || b.Recurrence == nil || b.Recurrence.Start != "2025-01-01" ||
+		len(b.Amounts) != 2 || b.Amounts[1] != (jsonBudgetAmount{AccountGUID: "groceries", Account: "Expenses:Groceries", Period: 1, Amount: "250.00"}) {
+		t.Errorf("unexpected budget: %+v", b)
+	}
+
+	// Books without budgets export an empty list.
+	if _, err := NewService(setupTestDB(t), WithExportDir(dir)).ExportJSON(ctx, "", ""); err != nil {
+		t.Fatalf("ExportJSON() returned error: %v", err)
+	}
+	if content := readExport(t, dir, "json"); !strings.Contains(content, `"budgets": []`) {
+		t.Errorf("expected an empty budget list:\n%s", content)
+	}
+}
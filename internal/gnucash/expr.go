@@ -0,0 +1,313 @@
+package gnucash
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxExpressions   = 10
+	maxExpressionLen = 256
+)
+
+// Expression is a named computed column evaluated over a report row,
+// e.g. "savings_rate = net / income".
+//
+// Expressions use Go expression syntax restricted to arithmetic, comparisons,
+// boolean operators, string and number literals, row variables and a few
+// builtin functions (iif, abs, min, max, round). There is no way to loop,
+// assign or reach anything outside the row, which keeps evaluation sandboxed.
+type Expression struct {
+	Name string
+	Src  string
+	node ast.Expr
+}
+
+// ParseExpressions parses a list of "name = expr" definitions separated by
+// semicolons or newlines. An empty input yields no expressions.
+func ParseExpressions(defs string) ([]*Expression, error) {
+	var exprs []*Expression
+	for _, def := range strings.FieldsFunc(defs, func(r rune) bool { return r == ';' || r == '\n' }) {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		e, err := ParseExpression(def)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) > maxExpressions {
+		return nil, fmt.Errorf("too many expressions: %d (max %d)", len(exprs), maxExpressions)
+	}
+	return exprs, nil
+}
+
+// ParseExpression parses a single "name = expr" definition.
+func ParseExpression(def string) (*Expression, error) {
+	if len(def) > maxExpressionLen {
+		return nil, fmt.Errorf("expression too long: %d characters (max %d)", len(def), maxExpressionLen)
+	}
+	name, src, ok := strings.Cut(def, "=")
+	name, src = strings.TrimSpace(name), strings.TrimSpace(src)
+	// Guard against "a == b" being split on the first '='.
+	if !ok || name == "" || src == "" || strings.HasPrefix(src, "=") || !token.IsIdentifier(name) {
+		return nil, fmt.Errorf("invalid expression '%s': expected 'name = expr'", def)
+	}
+	node, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("parse expression '%s': %w", name, err)
+	}
+	return &Expression{Name: name, Src: src, node: node}, nil
+}
+
+// Eval evaluates the expression against a row of named values.
+// The result is a float64, string or bool.
+func (e *Expression) Eval(row map[string]any) (any, error) {
+	return evalNode(e.node, row)
+}
+
+// FormatExprValue renders an expression result for text output.
+func FormatExprValue(v any, err error) string {
+	if err != nil {
+		return "n/a"
+	}
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// evalExpressions evaluates each expression against row and formats the results.
+func evalExpressions(exprs []*Expression, row map[string]any) []string {
+	values := make([]string, len(exprs))
+	for i, e := range exprs {
+		values[i] = FormatExprValue(e.Eval(row))
+	}
+	return values
+}
+
+// expressionNames returns the column names of exprs.
+func expressionNames(exprs []*Expression) []string {
+	names := make([]string, len(exprs))
+	for i, e := range exprs {
+		names[i] = e.Name
+	}
+	return names
+}
+
+func evalNode(n ast.Expr, row map[string]any) (any, error) {
+	switch n := n.(type) {
+	case *ast.ParenExpr:
+		return evalNode(n.X, row)
+	case *ast.BasicLit:
+		switch n.Kind {
+		case token.INT, token.FLOAT:
+			return strconv.ParseFloat(n.Value, 64)
+		case token.STRING:
+			return strconv.Unquote(n.Value)
+		}
+	case *ast.Ident:
+		switch n.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		v, ok := row[n.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable '%s'", n.Name)
+		}
+		return v, nil
+	case *ast.UnaryExpr:
+		x, err := evalNode(n.X, row)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case token.SUB:
+			f, err := asFloat(x)
+			return -f, err
+		case token.ADD:
+			return asFloat(x)
+		case token.NOT:
+			b, err := asBool(x)
+			return !b, err
+		}
+	case *ast.BinaryExpr:
+		return evalBinary(n, row)
+	case *ast.CallExpr:
+		return evalCall(n, row)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", n)
+}
+
+func evalBinary(n *ast.BinaryExpr, row map[string]any) (any, error) {
+	x, err := evalNode(n.X, row)
+	if err != nil {
+		return nil, err
+	}
+	// Short-circuit boolean operators.
+	if n.Op == token.LAND || n.Op == token.LOR {
+		b, err := asBool(x)
+		if err != nil {
+			return nil, err
+		}
+		if (n.Op == token.LAND && !b) || (n.Op == token.LOR && b) {
+			return b, nil
+		}
+		y, err := evalNode(n.Y, row)
+		if err != nil {
+			return nil, err
+		}
+		return asBool(y)
+	}
+	y, err := evalNode(n.Y, row)
+	if err != nil {
+		return nil, err
+	}
+
+	if xs, ok := x.(string); ok {
+		ys, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %T", y)
+		}
+		switch n.Op {
+		case token.EQL:
+			return xs == ys, nil
+		case token.NEQ:
+			return xs != ys, nil
+		case token.ADD:
+			return xs + ys, nil
+		}
+		return nil, fmt.Errorf("unsupported string operator %s", n.Op)
+	}
+
+	a, err := asFloat(x)
+	if err != nil {
+		return nil, err
+	}
+	b, err := asFloat(y)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Op {
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.QUO:
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	case token.EQL:
+		return a == b, nil
+	case token.NEQ:
+		return a != b, nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", n.Op)
+}
+
+func evalCall(n *ast.CallExpr, row map[string]any) (any, error) {
+	fn, ok := n.Fun.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("unsupported function call")
+	}
+	args := make([]any, len(n.Args))
+	if fn.Name == "iif" {
+		// iif(cond, then, else) only evaluates the selected branch.
+		if len(n.Args) != 3 {
+			return nil, fmt.Errorf("iif expects 3 arguments, got %d", len(n.Args))
+		}
+		c, err := evalNode(n.Args[0], row)
+		if err != nil {
+			return nil, err
+		}
+		cond, err := asBool(c)
+		if err != nil {
+			return nil, err
+		}
+		if cond {
+			return evalNode(n.Args[1], row)
+		}
+		return evalNode(n.Args[2], row)
+	}
+	for i, a := range n.Args {
+		v, err := evalNode(a, row)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch fn.Name {
+	case "abs":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("abs expects 1 argument, got %d", len(args))
+		}
+		f, err := asFloat(args[0])
+		return math.Abs(f), err
+	case "round":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("round expects 1 argument, got %d", len(args))
+		}
+		f, err := asFloat(args[0])
+		return math.Round(f), err
+	case "min", "max":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s expects at least 1 argument", fn.Name)
+		}
+		best, err := asFloat(args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range args[1:] {
+			f, err := asFloat(a)
+			if err != nil {
+				return nil, err
+			}
+			if (fn.Name == "min" && f < best) || (fn.Name == "max" && f > best) {
+				best = f
+			}
+		}
+		return best, nil
+	}
+	return nil, fmt.Errorf("unknown function '%s'", fn.Name)
+}
+
+func asFloat(v any) (float64, error) {
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("expected number, got %T", v)
+	}
+	return f, nil
+}
+
+func asBool(v any) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected boolean, got %T", v)
+	}
+	return b, nil
+}
@@ -0,0 +1,47 @@
+package gnucash
+
+import (
+	"testing"
+)
+
+func TestExpressionEval(t *testing.T) {
+	row := map[string]any{"income": 3000.0, "expenses": 110.5, "net": 2889.5}
+
+	tests := []struct {
+		name string
+		def  string
+		want string
+	}{
+		{name: "ratio", def: "rate = net / income", want: "0.96"},
+		{name: "arithmetic precedence", def: "x = income - expenses * 2", want: "2779.00"},
+		{name: "conditional bucket", def: "b = iif(expenses > 100, \"high\", \"low\")", want: "high"},
+		{name: "builtins", def: "m = max(abs(-5), min(income, 2))", want: "5.00"},
+		{name: "boolean", def: "ok = net > 0 && !(expenses == 0)", want: "true"},
+		{name: "division by zero", def: "z = net / 0", want: "n/a"},
+		{name: "unknown variable", def: "u = foo + 1", want: "n/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := ParseExpression(tt.def)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) returned error: %v", tt.def, err)
+			}
+			if got := FormatExprValue(e.Eval(row)); got != tt.want {
+				t.Errorf("Eval(%q) = %q, want %q", tt.def, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressions_Invalid(t *testing.T) {
+	for _, def := range []string{"no_equals", "a == b", "1x = 2", "f = func() {}", "x = os.Exit(1)"} {
+		exprs, err := ParseExpressions(def)
+		if err == nil {
+			// Parsing may succeed for unsupported constructs; evaluation must then fail.
+			if _, evalErr := exprs[0].Eval(nil); evalErr == nil {
+				t.Errorf("expected %q to be rejected", def)
+			}
+		}
+	}
+}
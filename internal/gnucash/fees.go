@@ -0,0 +1,208 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Kinds of costs reported by InterestAndFees.
+const (
+	CostInterest = "interest"
+	CostFees     = "fees"
+)
+
+// costKeywords tell interest and fee expense accounts by the words of their
+// names, unaccented and lowercased, in the languages NormalizeAccountType
+// knows. A keyword matches the start of a word: "fee" matches "Fees" but
+// not "Coffee".
+var costKeywords = []struct{ keyword, kind string }{
+	{"interest", CostInterest}, {"interet", CostInterest}, {"zins", CostInterest}, {"interes", CostInterest},
+	{"fee", CostFees}, {"service charge", CostFees}, {"bank charge", CostFees}, {"frais", CostFees},
+	{"gebuhr", CostFees}, {"gebuehr", CostFees}, {"comision", CostFees}, {"commission", CostFees},
+}
+
+// costKind returns the kind of cost an expense account is for, from its name
+// or those of its ancestors, or "" when it is neither interest nor fees.
+func costKind(acc *Account, accounts map[string]*Account) string {
+	for a := acc; a != nil && a.AccountType == "EXPENSE"; a = accounts[a.ParentGUID] {
+		words := strings.FieldsFunc(unaccent.Replace(strings.ToLower(a.Name)), func(r rune) bool { return !unicode.IsLetter(r) })
+		name := " " + strings.Join(words, " ")
+		for _, k := range costKeywords {
+			if strings.Contains(name, " "+k.keyword) {
+				return k.kind
+			}
+		}
+	}
+	return ""
+}
+
+// costLine is the interest or fees paid to an expense account through one
+// institution account in a year.
+type costLine struct {
+	Year        int
+	Institution string // full name of the account paying, "" when none
+	Account     *Account
+	Kind        string
+	Amount      float64
+}
+
+// InterestAndFees reports what debt and banking cost between startDate and
+// endDate (today when empty): the interest and fee expense accounts, found
+// by their names or given as accountNames (comma-separated), per year and
+// per institution, the bank, card or loan account the cost was paid from or
+// charged to.
+func (s *Service) InterestAndFees(ctx context.Context, accountNames, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	kinds := make(map[string]string)
+	if accountNames != "" {
+		for _, name := range strings.Split(accountNames, ",") {
+			acc, err := s.resolveAccount(ctx, strings.TrimSpace(name))
+			if err != nil {
+				return "", err
+			}
+			for _, guid := range descendantGUIDs(acc) {
+				kind := costKind(accounts[guid], accounts)
+				if kind == "" {
+					kind = CostFees
+				}
+				kinds[guid] = kind
+			}
+		}
+	} else {
+		for guid, acc := range accounts {
+			if kind := costKind(acc, accounts); kind != "" {
+				kinds[guid] = kind
+			}
+		}
+		if len(kinds) == 0 {
+			return "No interest or fee expense accounts found; name them with the accounts parameter.", nil
+		}
+	}
+	guids := make([]string, 0, len(kinds))
+	for guid := range kinds {
+		guids = append(guids, guid)
+	}
+	slices.Sort(guids)
+	splits, err := s.db.getRelatedSplits(ctx, guids, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	type key struct {
+		year                 int
+		institution, account string
+	}
+	lines := make(map[key]*costLine)
+	for i := 0; i < len(splits); {
+		tx := splits[i].TxGUID
+		j := i
+		for j < len(splits) && splits[j].TxGUID == tx {
+			j++
+		}
+		txSplits := splits[i:j]
+		i = j
+		if startDate != "" && txSplits[0].Date.Format("2006-01-02") < startDate {
+			continue
+		}
+		// The institution is the loan or card of the transaction, else its
+		// largest asset split: a loan payment from a bank account pays
+		// the loan's interest.
+		institution, debt, largest := "", false, 0.0
+		for _, sp := range txSplits {
+			acc := accounts[sp.AccountGUID]
+			if acc == nil || acc.AccountType == "INCOME" || acc.AccountType == "EXPENSE" || acc.AccountType == "TRADING" {
+				continue
+			}
+			isDebt := acc.AccountType == "LIABILITY" || acc.AccountType == "CREDIT"
+			if v := math.Abs(sp.Value); (isDebt && !debt) || (isDebt == debt && v > largest) {
+				institution, debt, largest = acc.FullName, isDebt, v
+			}
+		}
+		for _, sp := range txSplits {
+			kind, ok := kinds[sp.AccountGUID]
+			if !ok {
+				continue
+			}
+			k := key{sp.Date.Year(), institution, sp.AccountGUID}
+			line, ok := lines[k]
+			if !ok {
+				line = &costLine{Year: k.year, Institution: institution, Account: accounts[sp.AccountGUID], Kind: kind}
+				lines[k] = line
+			}
+			line.Amount += sp.Value
+		}
+	}
+	if len(lines) == 0 {
+		return "No interest or fees paid in the period.", nil
+	}
+	sorted := make([]*costLine, 0, len(lines))
+	for _, l := range lines {
+		sorted = append(sorted, l)
+	}
+	slices.SortFunc(sorted, func(a, b *costLine) int {
+		return cmp.Or(cmp.Compare(a.Year, b.Year), cmp.Compare(a.Institution, b.Institution),
+			cmp.Compare(a.Account.FullName, b.Account.FullName))
+	})
+
+	if format != FormatText {
+		t := table{Headers: []string{"year", "institution", "account", "kind", "amount"}}
+		for _, l := range sorted {
+			t.add(fmt.Sprint(l.Year), l.Institution, l.Account.FullName, l.Kind, fmt.Sprintf("%.2f", l.Amount))
+		}
+		return t.render(format), nil
+	}
+
+	institutionLabel := func(name string) string {
+		if name == "" {
+			return "(no institution account)"
+		}
+		return name
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Interest and fees paid, in %s:\n", cur.Mnemonic)
+	type costs struct{ interest, fees float64 }
+	add := func(c *costs, l *costLine) {
+		if l.Kind == CostInterest {
+			c.interest += l.Amount
+		} else {
+			c.fees += l.Amount
+		}
+	}
+	var total, year costs
+	for i, l := range sorted {
+		if i == 0 || l.Year != sorted[i-1].Year {
+			fmt.Fprintf(&sb, "\n%d\n", l.Year)
+			year = costs{}
+		}
+		if i == 0 || l.Year != sorted[i-1].Year || l.Institution != sorted[i-1].Institution {
+			fmt.Fprintf(&sb, "  %s\n", institutionLabel(l.Institution))
+		}
+		fmt.Fprintf(&sb, "    %-40s %-8s %12.2f\n", l.Account.FullName, l.Kind, l.Amount)
+		add(&year, l)
+		add(&total, l)
+		if i == len(sorted)-1 || sorted[i+1].Year != l.Year {
+			fmt.Fprintf(&sb, "  Total %d: interest %.2f, fees %.2f, together %.2f\n", l.Year, year.interest, year.fees, year.interest+year.fees)
+		}
+	}
+	fmt.Fprintf(&sb, "\nTOTAL: interest %.2f, fees %.2f, together %.2f %s\n", total.interest, total.fees, total.interest+total.fees, cur.Mnemonic)
+	return sb.String(), nil
+}
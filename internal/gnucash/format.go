@@ -0,0 +1,161 @@
+package gnucash
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output formats accepted by tabular reports.
+const (
+	FormatText     = "text"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// tabularFormats lists the formats supported by every tabular report.
+var tabularFormats = []string{FormatText, FormatCSV, FormatMarkdown}
+
+// checkFormat normalizes a requested output format, defaulting to text.
+func checkFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		return FormatText, nil
+	}
+	if !slices.Contains(tabularFormats, format) {
+		return "", fmt.Errorf("unsupported format '%s' (expected one of: %s)", format, strings.Join(tabularFormats, ", "))
+	}
+	return format, nil
+}
+
+// WithGUIDs makes text listings show the GUIDs of their accounts,
+// transactions and splits, for clients that chain calls on them. Listings in
+// csv and markdown always include them.
+func WithGUIDs() Option {
+	return func(s *Service) { s.guids = true }
+}
+
+// WithSubtotals makes transaction listings add a subtotal after each month
+// and a grand total, to make long registers easier to digest.
+func WithSubtotals() Option {
+	return func(s *Service) { s.subtotals = true }
+}
+
+// table is a report rendered as rows of cells, for non-text formats.
+type table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// render returns the table in the given non-text format.
+func (t table) render(format string) string {
+	if format == FormatMarkdown {
+		return t.markdown()
+	}
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write(t.Headers)
+	w.WriteAll(t.Rows) // flushes; writing to a strings.Builder cannot fail
+	return sb.String()
+}
+
+// markdown renders the table as a padded markdown table. Columns whose cells
+// are all numbers are right-aligned.
+func (t table) markdown() string {
+	escape := func(s string) string { return strings.ReplaceAll(s, "|", `\|`) }
+
+	widths := make([]int, len(t.Headers))
+	numeric := make([]bool, len(t.Headers))
+	for i, h := range t.Headers {
+		widths[i] = max(3, utf8.RuneCountInString(escape(h)))
+		numeric[i] = len(t.Rows) > 0
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(escape(cell)))
+			if _, err := strconv.ParseFloat(cell, 64); err != nil {
+				numeric[i] = false
+			}
+		}
+	}
+
+	pad := func(s string, i int) string {
+		gap := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(s))
+		if numeric[i] {
+			return gap + s
+		}
+		return s + gap
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for i, cell := range cells {
+			sb.WriteString(" " + pad(escape(cell), i) + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(t.Headers)
+	sb.WriteString("|")
+	for i := range t.Headers {
+		if numeric[i] {
+			sb.WriteString(" " + strings.Repeat("-", widths[i]-1) + ": |")
+		} else {
+			sb.WriteString(" " + strings.Repeat("-", widths[i]) + " |")
+		}
+	}
+	sb.WriteString("\n")
+	for _, row := range t.Rows {
+		writeRow(row)
+	}
+	return sb.String()
+}
+
+// barWidth is the width, in characters, of the bars of text reports.
+const barWidth = 20
+
+// bar returns a bar of block characters, padded to barWidth, whose length
+// is value scaled to max, in eighths of a character. Values of zero or less
+// have no bar.
+func bar(value, max float64) string {
+	var sb strings.Builder
+	n := 0
+	if value > 0 && max > 0 {
+		eighths := int(min(value/max, 1)*barWidth*8 + 0.5)
+		n = eighths / 8
+		sb.WriteString(strings.Repeat("█", n))
+		if rest := eighths % 8; rest > 0 {
+			sb.WriteRune([]rune("▏▎▍▌▋▊▉")[rest-1])
+			n++
+		}
+	}
+	sb.WriteString(strings.Repeat(" ", barWidth-n))
+	return sb.String()
+}
+
+// sparkline returns one block character per value, from ▁ for the lowest to
+// █ for the highest.
+func sparkline(values []float64) string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := slices.Min(values), slices.Max(values)
+	var sb strings.Builder
+	for _, v := range values {
+		level := len(levels) / 2
+		if hi > lo {
+			level = int((v-lo)/(hi-lo)*float64(len(levels)-1) + 0.5)
+		}
+		sb.WriteRune(levels[level])
+	}
+	return sb.String()
+}
@@ -0,0 +1,56 @@
+package gnucash
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+type fuzzyMatch struct {
+	Account  *Account
+	Distance int
+}
+
+// fuzzyMatches ranks accounts by edit distance between name and the account
+// name (or full path when name contains ':'), keeping those within maxDist.
+func fuzzyMatches(name string, accounts map[string]*Account, maxDist int) []fuzzyMatch {
+	name = strings.ToLower(name)
+	usePath := strings.Contains(name, ":")
+
+	var matches []fuzzyMatch
+	for _, acc := range accounts {
+		candidate := acc.Name
+		if usePath {
+			candidate = acc.FullName
+		}
+		if d := levenshtein(name, strings.ToLower(candidate)); d <= maxDist {
+			matches = append(matches, fuzzyMatch{Account: acc, Distance: d})
+		}
+	}
+	slices.SortFunc(matches, func(a, b fuzzyMatch) int {
+		return cmp.Or(cmp.Compare(a.Distance, b.Distance), cmp.Compare(a.Account.FullName, b.Account.FullName))
+	})
+	return matches
+}
@@ -0,0 +1,187 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// securityGains is the performance of one security for GainsSummary.
+type securityGains struct {
+	Commodity  Commodity
+	Realized   float64 // gains on the sales of the period
+	Sales      int
+	Quantity   float64 // held at the end of the period
+	Cost       float64 // cost basis of the quantity held
+	Worth      float64
+	Priced     bool
+	Unrealized float64
+}
+
+// GainsSummary splits the performance of investments into realized gains,
+// on the sales between startDate and endDate, and unrealized gains, the
+// worth of the holdings at the latest price on endDate (today when empty)
+// minus their cost basis, per security and in total. A sale from a lot
+// relieves the cost of the lot's shares; other sales relieve the average
+// cost per share across all accounts. Stock splits change the shares held
+// but not their cost. The zero-quantity splits GnuCash records when it
+// books the gains of closed lots are left out, the gains being computed
+// here.
+func (s *Service) GainsSummary(ctx context.Context, symbol, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	splits, err := s.db.GetInvestmentSplits(ctx, endDate)
+	if err != nil {
+		return "", err
+	}
+
+	type position struct {
+		commodity      string
+		quantity, cost float64
+	}
+	lots := make(map[string]*position)
+	var gains []*securityGains
+	bySecurity := make(map[string]*securityGains)
+	for _, sp := range splits {
+		c := sp.Commodity
+		if symbol != "" && !strings.EqualFold(c.Mnemonic, symbol) && !strings.EqualFold(c.CUSIP, symbol) {
+			continue
+		}
+		if sp.Quantity == 0 {
+			continue
+		}
+		g, ok := bySecurity[c.GUID]
+		if !ok {
+			g = &securityGains{Commodity: c}
+			bySecurity[c.GUID] = g
+			gains = append(gains, g)
+		}
+		lot := lots[sp.LotGUID]
+		if sp.LotGUID != "" && lot == nil {
+			lot = &position{commodity: c.GUID}
+			lots[sp.LotGUID] = lot
+		}
+		if sp.IsStockSplit() {
+			// The shares of every lot split alike, unless GnuCash
+			// recorded the split in the lot itself.
+			if lot == nil && g.Quantity > 0 {
+				ratio := (g.Quantity + sp.Quantity) / g.Quantity
+				for _, l := range lots {
+					if l.commodity == c.GUID {
+						l.quantity *= ratio
+					}
+				}
+			}
+			if lot != nil {
+				lot.quantity += sp.Quantity
+			}
+			g.Quantity += sp.Quantity
+			continue
+		}
+		if sp.Quantity > 0 {
+			g.Quantity += sp.Quantity
+			g.Cost += sp.Value
+			if lot != nil {
+				lot.quantity += sp.Quantity
+				lot.cost += sp.Value
+			}
+			continue
+		}
+
+		sold := -sp.Quantity
+		var basis float64
+		switch {
+		case lot != nil && lot.quantity > 0:
+			basis = lot.cost / lot.quantity * math.Min(sold, lot.quantity)
+			lot.quantity -= sold
+			lot.cost -= basis
+		case g.Quantity > 0:
+			basis = g.Cost / g.Quantity * sold
+		}
+		g.Quantity -= sold
+		g.Cost -= basis
+		if date := sp.Date.Format("2006-01-02"); startDate == "" || date >= startDate {
+			g.Realized += -sp.Value - basis
+			g.Sales++
+		}
+	}
+	if len(gains) == 0 {
+		if symbol != "" {
+			return fmt.Sprintf("No investment transactions found for symbol '%s'.", symbol), nil
+		}
+		return "No investment transactions found.", nil
+	}
+
+	var realized, unrealized float64
+	unpriced := 0
+	for _, g := range gains {
+		realized += g.Realized
+		if math.Abs(g.Quantity) < 1e-9 {
+			g.Quantity, g.Cost = 0, 0
+			continue
+		}
+		price, ok, err := s.db.GetLatestPrice(ctx, g.Commodity, endDate)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			unpriced++
+			continue
+		}
+		g.Priced = true
+		g.Worth = g.Quantity * price.Value()
+		g.Unrealized = g.Worth - g.Cost
+		unrealized += g.Unrealized
+	}
+
+	amount := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+	if format != FormatText {
+		t := table{Headers: []string{"security", "realized", "sales", "quantity", "cost", "worth", "unrealized", "total"}}
+		for _, g := range gains {
+			worth, unrealizedGain := "", ""
+			if g.Priced || g.Quantity == 0 {
+				worth, unrealizedGain = amount(g.Worth), amount(g.Unrealized)
+			}
+			t.add(g.Commodity.Mnemonic, amount(g.Realized), fmt.Sprint(g.Sales), fmt.Sprintf("%.4f", g.Quantity),
+				amount(g.Cost), worth, unrealizedGain, amount(g.Realized+g.Unrealized))
+		}
+		return t.render(format), nil
+	}
+
+	period := "up to " + endDate
+	if startDate != "" {
+		period = fmt.Sprintf("from %s to %s", startDate, endDate)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Investment gains (realized %s, unrealized at %s), in %s:\n\n", period, endDate, cur.Mnemonic)
+	for _, g := range gains {
+		fmt.Fprintf(&sb, "  %s\n", g.Commodity.Label())
+		fmt.Fprintf(&sb, "    Realized:   %s (%d sale(s))\n", amount(g.Realized), g.Sales)
+		switch {
+		case g.Quantity == 0:
+			sb.WriteString("    Unrealized: 0.00 (no shares held)\n")
+		case !g.Priced:
+			fmt.Fprintf(&sb, "    Unrealized: %.4f shares at a cost of %s, no price available\n", g.Quantity, amount(g.Cost))
+		default:
+			fmt.Fprintf(&sb, "    Unrealized: %s (%.4f shares worth %s at a cost of %s)\n",
+				amount(g.Unrealized), g.Quantity, amount(g.Worth), amount(g.Cost))
+		}
+	}
+	fmt.Fprintf(&sb, "\n  TOTAL realized:   %s %s\n", amount(realized), cur.Mnemonic)
+	fmt.Fprintf(&sb, "  TOTAL unrealized: %s %s\n", amount(unrealized), cur.Mnemonic)
+	fmt.Fprintf(&sb, "  TOTAL:            %s %s\n", amount(realized+unrealized), cur.Mnemonic)
+	if unpriced > 0 {
+		fmt.Fprintf(&sb, "\n%d security(ies) without a price are left out of the unrealized total.\n", unpriced)
+	}
+	return sb.String(), nil
+}
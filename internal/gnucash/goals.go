@@ -0,0 +1,201 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SavingsGoal is a target balance for a set of accounts, such as an
+// emergency fund kept in a savings account.
+type SavingsGoal struct {
+	Accounts []string `json:"accounts"` // names or colon paths, with their sub-accounts
+	Target   float64  `json:"target"`
+	Date     string   `json:"date,omitempty"` // YYYY-MM-DD the target should be reached by
+}
+
+// SavingsGoals maps goal names to their definitions. Like category groups,
+// goals only exist in the server's configuration.
+type SavingsGoals map[string]SavingsGoal
+
+// LoadSavingsGoals reads savings goals from a JSON file holding an object of
+// goal name to goal, e.g. {"Emergency fund": {"accounts": ["Savings"],
+// "target": 10000, "date": "2026-12-31"}}.
+func LoadSavingsGoals(path string) (SavingsGoals, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read savings goals: %w", err)
+	}
+	var goals SavingsGoals
+	if err := json.Unmarshal(data, &goals); err != nil {
+		return nil, fmt.Errorf("parse savings goals %s: %w", path, err)
+	}
+	for name, g := range goals {
+		if len(g.Accounts) == 0 {
+			return nil, fmt.Errorf("savings goal '%s' has no accounts", name)
+		}
+		if g.Target <= 0 {
+			return nil, fmt.Errorf("savings goal '%s' needs a positive target", name)
+		}
+		if g.Date != "" {
+			if _, err := time.Parse("2006-01-02", g.Date); err != nil {
+				return nil, fmt.Errorf("savings goal '%s' has an invalid date '%s' (expected YYYY-MM-DD)", name, g.Date)
+			}
+		}
+	}
+	return goals, nil
+}
+
+// WithSavingsGoals enables goal progress reports for goals.
+func WithSavingsGoals(goals SavingsGoals) Option {
+	return func(s *Service) { s.goals = goals }
+}
+
+// goalPaceMonths is the number of months the recent saving pace of a goal is
+// measured over.
+const goalPaceMonths = 3
+
+// goalStatus is the progress of a savings goal at a date.
+type goalStatus struct {
+	Name       string
+	Accounts   []string // full names
+	Saved      float64
+	Target     float64
+	Date       string
+	MonthsLeft int     // whole months until Date
+	Required   float64 // monthly contribution reaching the target by Date
+	Pace       float64 // average monthly change over the last goalPaceMonths
+}
+
+// remaining returns what is left to save, zero once the goal is reached.
+func (g goalStatus) remaining() float64 {
+	return max(0, g.Target-g.Saved)
+}
+
+// GoalProgress reports the balance of the accounts of each savings goal, or
+// of the goal named name, at date (today when empty) against its target:
+// what is left to save, the monthly contribution that reaches the target by
+// its date, and whether the saving pace of the last three months keeps up.
+func (s *Service) GoalProgress(ctx context.Context, name, date, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if len(s.goals) == 0 {
+		return "", fmt.Errorf("no savings goals configured (set GNUCASH_GOALS to a JSON file)")
+	}
+	if date == "" {
+		date = s.now().Format("2006-01-02")
+	}
+	asOf, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date '%s': %w", date, err)
+	}
+	names := make([]string, 0, len(s.goals))
+	for n := range s.goals {
+		if name == "" || strings.EqualFold(n, name) {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no savings goal named '%s'", name)
+	}
+	slices.Sort(names)
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	paceStart := asOf.AddDate(0, -goalPaceMonths, 0).Format("2006-01-02")
+	var goals []goalStatus
+	for _, n := range names {
+		def := s.goals[n]
+		g := goalStatus{Name: n, Target: def.Target, Date: def.Date}
+		var guids []string
+		for _, a := range def.Accounts {
+			acc, err := s.resolveAccount(ctx, a)
+			if err != nil {
+				return "", fmt.Errorf("savings goal '%s': %w", n, err)
+			}
+			g.Accounts = append(g.Accounts, acc.FullName)
+			guids = append(guids, descendantGUIDs(acc)...)
+		}
+		saved, err := s.db.GetBalanceForAccounts(ctx, guids, date)
+		if err != nil {
+			return "", err
+		}
+		before, err := s.db.GetBalanceForAccounts(ctx, guids, paceStart)
+		if err != nil {
+			return "", err
+		}
+		g.Saved = saved.Float64()
+		g.Pace = (g.Saved - before.Float64()) / goalPaceMonths
+		if def.Date != "" {
+			target, _ := time.Parse("2006-01-02", def.Date)
+			g.MonthsLeft = (target.Year()-asOf.Year())*12 + int(target.Month()-asOf.Month())
+			if target.Day() < asOf.Day() {
+				g.MonthsLeft--
+			}
+			if g.MonthsLeft > 0 {
+				g.Required = g.remaining() / float64(g.MonthsLeft)
+			}
+		}
+		goals = append(goals, g)
+	}
+	slices.SortStableFunc(goals, func(a, b goalStatus) int {
+		return cmp.Compare(cmp.Or(a.Date, "9999"), cmp.Or(b.Date, "9999"))
+	})
+
+	if format != FormatText {
+		t := table{Headers: []string{"goal", "accounts", "saved", "target", "remaining", "progress_pct", "target_date", "months_left", "required_monthly", "pace_monthly"}}
+		for _, g := range goals {
+			monthsLeft, required := "", ""
+			if g.Date != "" {
+				monthsLeft, required = fmt.Sprint(max(0, g.MonthsLeft)), fmt.Sprintf("%.2f", g.Required)
+			}
+			t.add(g.Name, strings.Join(g.Accounts, "; "), fmt.Sprintf("%.2f", g.Saved), fmt.Sprintf("%.2f", g.Target),
+				fmt.Sprintf("%.2f", g.remaining()), fmt.Sprintf("%.1f", g.Saved/g.Target*100), g.Date, monthsLeft, required, fmt.Sprintf("%.2f", g.Pace))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Savings goals at %s, in %s:\n", date, cur.Mnemonic)
+	for _, g := range goals {
+		fmt.Fprintf(&sb, "\n%s (%s)\n", g.Name, strings.Join(g.Accounts, ", "))
+		fmt.Fprintf(&sb, "  Saved %.2f of %.2f (%.0f%%)", g.Saved, g.Target, math.Floor(g.Saved/g.Target*100))
+		remaining := g.remaining()
+		if remaining == 0 {
+			sb.WriteString(", goal reached\n")
+			continue
+		}
+		fmt.Fprintf(&sb, ", %.2f to go", remaining)
+		switch {
+		case g.Date == "":
+			sb.WriteString(", no target date\n")
+		case g.MonthsLeft <= 0:
+			fmt.Fprintf(&sb, "; the target date %s has passed\n", g.Date)
+		default:
+			fmt.Fprintf(&sb, " by %s (%d month(s))\n", g.Date, g.MonthsLeft)
+			fmt.Fprintf(&sb, "  Required: %.2f a month\n", g.Required)
+		}
+		fmt.Fprintf(&sb, "  Pace over the last %d months: %+.2f a month", goalPaceMonths, g.Pace)
+		switch {
+		case g.Pace <= 0:
+			sb.WriteString(", not growing\n")
+		case g.Date != "" && g.MonthsLeft > 0 && g.Pace >= g.Required:
+			sb.WriteString(", on track\n")
+		case g.Date != "" && g.MonthsLeft > 0:
+			fmt.Fprintf(&sb, ", behind by %.2f a month\n", g.Required-g.Pace)
+		default:
+			fmt.Fprintf(&sb, "; reached in about %.0f month(s) at this pace\n", math.Ceil(remaining/g.Pace))
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,91 @@
+package gnucash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Report groupings accepted by spending reports.
+const (
+	GroupByAccount = "account"
+	GroupByGroup   = "group"
+)
+
+// otherGroup labels accounts not covered by any category group.
+const otherGroup = "Other"
+
+// CategoryGroups maps a super-category name to the accounts it covers, e.g.
+// "Essentials": ["Rent", "Groceries", "Utilities"]. Members are account names
+// or full colon paths and include their sub-accounts. Groups only exist in
+// the server's configuration; the GnuCash chart is not touched.
+type CategoryGroups map[string][]string
+
+// LoadCategoryGroups reads category groups from a JSON file holding an object
+// of group name to account list.
+func LoadCategoryGroups(path string) (CategoryGroups, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read category groups: %w", err)
+	}
+	var groups CategoryGroups
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("parse category groups %s: %w", path, err)
+	}
+	seen := make(map[string]string)
+	for group, members := range groups {
+		for _, m := range members {
+			key := strings.ToLower(m)
+			if other, ok := seen[key]; ok && other != group {
+				return nil, fmt.Errorf("account '%s' is in both groups '%s' and '%s'", m, other, group)
+			}
+			seen[key] = group
+		}
+	}
+	return groups, nil
+}
+
+// labeler returns a function naming the group of an account by GUID: the group of
+// the nearest ancestor (or the account itself) listed by name or full path,
+// or "Other".
+func (g CategoryGroups) labeler(accounts map[string]*Account) func(guid string) string {
+	members := make(map[string]string)
+	for group, names := range g {
+		for _, n := range names {
+			members[strings.ToLower(n)] = group
+		}
+	}
+	return func(guid string) string {
+		for a := accounts[guid]; a != nil; a = accounts[a.ParentGUID] {
+			if group, ok := members[strings.ToLower(a.FullName)]; ok {
+				return group
+			}
+			if group, ok := members[strings.ToLower(a.Name)]; ok {
+				return group
+			}
+		}
+		return otherGroup
+	}
+}
+
+// groupLabeler returns the labeler for a report grouping, or nil when rows
+// stay per account.
+func (s *Service) groupLabeler(ctx context.Context, grouping string) (func(guid string) string, error) {
+	switch strings.ToLower(grouping) {
+	case "", GroupByAccount:
+		return nil, nil
+	case GroupByGroup:
+	default:
+		return nil, fmt.Errorf("unsupported grouping '%s' (expected %s or %s)", grouping, GroupByAccount, GroupByGroup)
+	}
+	if len(s.groups) == 0 {
+		return nil, fmt.Errorf("no category groups configured (set GNUCASH_CATEGORY_GROUPS to a JSON file)")
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.groups.labeler(accounts), nil
+}
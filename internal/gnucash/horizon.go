@@ -0,0 +1,56 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithAllHistory makes queries ignore the service's date horizon, for
+// callers that explicitly ask for older data.
+func WithAllHistory() Option {
+	return func(s *Service) { s.allHistory = true }
+}
+
+// HasTransactionsBetween reports whether any transaction was posted on or
+// after startDate (unbounded if empty) and before endDate.
+func (d *DB) HasTransactionsBetween(ctx context.Context, startDate, endDate string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM transactions WHERE post_date < ?`
+	args := []any{d.dayStart(endDate)}
+	if startDate != "" {
+		query += ` AND post_date >= ?`
+		args = append(args, d.dayStart(startDate))
+	}
+	var exists bool
+	if err := d.db.QueryRowContext(ctx, query+`)`, args...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("query transactions before horizon: %w", err)
+	}
+	return exists, nil
+}
+
+// horizonStart clamps a report's start date to the date horizon. It returns
+// the effective start date and, when older transactions are left out, a
+// notice saying so. An empty startDate means "from the beginning".
+func (s *Service) horizonStart(ctx context.Context, startDate string) (string, string, error) {
+	if s.horizon <= 0 || s.allHistory {
+		return startDate, "", nil
+	}
+	floor := s.now().AddDate(-s.horizon, 0, 0).Format("2006-01-02")
+	if startDate != "" && startDate >= floor {
+		return startDate, "", nil
+	}
+	excluded, err := s.db.HasTransactionsBetween(ctx, startDate, floor)
+	if err != nil || !excluded {
+		return floor, "", err
+	}
+	return floor, fmt.Sprintf("Note: transactions before %s are excluded by the %d-year date horizon; pass all_history=true to include them.\n",
+		floor, s.horizon), nil
+}
+
+// horizonNote renders a horizon notice at the end of a report. CSV output
+// gets no note so it stays machine-readable.
+func horizonNote(format, notice string) string {
+	if notice == "" || format == FormatCSV {
+		return ""
+	}
+	return "\n" + notice
+}
@@ -0,0 +1,392 @@
+package gnucash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// importRow is a statement line to import into a bank or card account.
+type importRow struct {
+	Line        int // line or record number in the file, for the report
+	Date        time.Time
+	Description string
+	Memo        string
+	Amount      float64       // positive when money enters the account
+	Category    string        // counterpart account name or path given by the file, if any
+	OnlineID    string        // bank's unique transaction ID (OFX FITID), if any
+	Splits      []importSplit // counterparts of a split line; they sum to Amount
+}
+
+// importSplit is one counterpart of a statement line split across several
+// categories.
+type importSplit struct {
+	Category string
+	Memo     string
+	Amount   float64
+}
+
+// importKey identifies a statement line for duplicate detection.
+func importKey(date time.Time, num int64, description string) string {
+	return fmt.Sprintf("%s|%d|%s", date.Format("2006-01-02"), num, strings.Join(strings.Fields(strings.ToLower(description)), " "))
+}
+
+// importKeys counts the splits of an account between two dates (inclusive,
+// in the book's time zone) by importKey, with values expressed in fraction.
+func (d *DB) importKeys(ctx context.Context, accountGUID string, from, to time.Time, fraction int64) (map[string]int, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT t.post_date, s.value_num, s.value_denom, COALESCE(t.description, '')
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE s.account_guid = ? AND t.post_date >= ? AND t.post_date <= ?
+	`, accountGUID, d.dayStart(from.Format("2006-01-02")), d.dayEnd(to.Format("2006-01-02")))
+	if err != nil {
+		return nil, fmt.Errorf("query existing transactions: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]int)
+	for rows.Next() {
+		var postDate, description string
+		var num, denom int64
+		if err := rows.Scan(&postDate, &num, &denom, &description); err != nil {
+			return nil, fmt.Errorf("scan existing transaction: %w", err)
+		}
+		date, _ := d.parseDate(postDate)
+		if denom != 0 && denom != fraction {
+			num = num * fraction / denom
+		}
+		keys[importKey(date, num, description)]++
+	}
+	return keys, rows.Err()
+}
+
+// onlineIDs returns the online IDs recorded for imported transactions of an
+// account. GnuCash keeps them in an online_id slot of the account's split,
+// or of the transaction in books imported by older versions.
+func (d *DB) onlineIDs(ctx context.Context, accountGUID string) (map[string]bool, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT string_val FROM slots
+		WHERE name = 'online_id' AND COALESCE(string_val, '') != ''
+		  AND (obj_guid IN (SELECT guid FROM splits WHERE account_guid = ?1)
+		       OR obj_guid IN (SELECT tx_guid FROM splits WHERE account_guid = ?1))
+	`, accountGUID)
+	if err != nil {
+		return nil, fmt.Errorf("query online ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan online id: %w", err)
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// importOptions controls how statement lines find their counterpart account.
+type importOptions struct {
+	DefaultAccount string // counterpart of lines without a category
+	CreateAccounts bool   // create categories that match no account
+}
+
+// importRows records statement lines as transactions between account and
+// the counterpart of each line, skipping lines already in the book (same
+// date, amount and description). failures are lines the caller could not
+// parse; they are reported with the lines that could not be imported.
+func (s *Service) importRows(ctx context.Context, op string, input any, account string, rows []importRow, failures []string, opts importOptions) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	bank, err := s.resolveWriteAccount(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if bank.Placeholder {
+		return "", fmt.Errorf("account %s is a placeholder and cannot hold transactions", bank.FullName)
+	}
+	currency, err := s.transactionCurrency(ctx, []*Account{bank})
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 && len(failures) == 0 {
+		return "", fmt.Errorf("the file holds no transactions")
+	}
+
+	existing, known := map[string]int{}, map[string]bool{}
+	if slices.ContainsFunc(rows, func(row importRow) bool { return row.OnlineID != "" }) {
+		if known, err = s.db.onlineIDs(ctx, bank.GUID); err != nil {
+			return "", err
+		}
+	}
+	if len(rows) > 0 {
+		from, to := rows[0].Date, rows[0].Date
+		for _, row := range rows {
+			from, to = minTime(from, row.Date), maxTime(to, row.Date)
+		}
+		if existing, err = s.db.importKeys(ctx, bank.GUID, from, to, currency.Fraction); err != nil {
+			return "", err
+		}
+	}
+	counterparts := &counterpartResolver{s: s, currency: currency, opts: opts, resolved: map[string]*Account{}}
+
+	var txs []newTransaction
+	var imported, skipped []string
+	for _, row := range rows {
+		scaled := row.Amount * float64(currency.Fraction)
+		num := int64(math.Round(scaled))
+		line := fmt.Sprintf("%s  %s  %s %s", row.Date.Format("2006-01-02"), row.Description, FormatFraction(num, currency.Fraction, currency.Fraction), currency.Mnemonic)
+		switch {
+		case math.Abs(scaled-float64(num)) > 1e-6:
+			failures = append(failures, fmt.Sprintf("line %d: amount %g has more decimals than %s allows", row.Line, row.Amount, currency.Mnemonic))
+			continue
+		case num == 0:
+			failures = append(failures, fmt.Sprintf("line %d: zero amount", row.Line))
+			continue
+		case strings.TrimSpace(row.Description) == "":
+			failures = append(failures, fmt.Sprintf("line %d: no description", row.Line))
+			continue
+		}
+		// Like GnuCash, a line whose online ID was already imported is a
+		// duplicate; so is one matching an existing transaction exactly.
+		key := importKey(row.Date, num, row.Description)
+		if known[row.OnlineID] || existing[key] > 0 {
+			if !known[row.OnlineID] {
+				existing[key]--
+			}
+			skipped = append(skipped, fmt.Sprintf("line %d: %s", row.Line, line))
+			continue
+		}
+		if row.OnlineID != "" {
+			known[row.OnlineID] = true
+		}
+		splits, names, err := counterparts.legs(ctx, row, num, bank)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("line %d: %v", row.Line, err))
+			continue
+		}
+		txs = append(txs, newTransaction{
+			GUID:         newGUID(),
+			CurrencyGUID: currency.GUID,
+			PostDate:     row.Date,
+			Description:  strings.TrimSpace(row.Description),
+			Denom:        currency.Fraction,
+			Splits: append([]newSplit{
+				{GUID: newGUID(), AccountGUID: bank.GUID, Memo: row.Memo, ValueNum: num, OnlineID: row.OnlineID},
+			}, splits...),
+		})
+		imported = append(imported, line+"  -> "+strings.Join(names, ", "))
+	}
+
+	var sb strings.Builder
+	summary := fmt.Sprintf("%d transaction(s) into %s, skipped %d already present, %d failed", len(txs), bank.FullName, len(skipped), len(failures))
+	sb.WriteString(s.changeHeading("Imported "+summary, "import "+summary) + "\n")
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{
+		{"Imported", imported},
+		{"Created accounts", counterparts.createdNames()},
+		{"Skipped (already in the book)", skipped},
+		{"Failed", failures},
+	} {
+		if len(section.lines) > 0 {
+			fmt.Fprintf(&sb, "\n%s:\n  %s\n", section.title, strings.Join(section.lines, "\n  "))
+		}
+	}
+	if s.dryRun || len(txs) == 0 {
+		return sb.String(), nil
+	}
+
+	undo, err := s.db.insertBatch(ctx, counterparts.created, txs)
+	if err != nil {
+		return "", err
+	}
+	var guids []string
+	for _, acc := range counterparts.created {
+		guids = append(guids, acc.GUID)
+	}
+	for _, tx := range txs {
+		guids = append(guids, tx.GUID)
+	}
+	s.journal(fmt.Sprintf("import %d transaction(s) into %s", len(txs), bank.FullName), undo, guids...)
+	if err := s.audit(op, input, guids...); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// counterpartResolver finds, and plans to create when allowed, the
+// counterpart accounts of imported lines.
+type counterpartResolver struct {
+	s        *Service
+	currency Commodity
+	opts     importOptions
+	resolved map[string]*Account // by category, "" being the default account
+	created  []newAccount
+}
+
+// legs returns the counterpart splits of a line worth num into bank, with
+// the names of their accounts.
+func (r *counterpartResolver) legs(ctx context.Context, row importRow, num int64, bank *Account) ([]newSplit, []string, error) {
+	parts := row.Splits
+	if len(parts) == 0 {
+		parts = []importSplit{{Category: row.Category, Amount: row.Amount}}
+	}
+	var splits []newSplit
+	var names []string
+	var total int64
+	for _, part := range parts {
+		scaled := part.Amount * float64(r.currency.Fraction)
+		partNum := int64(math.Round(scaled))
+		if math.Abs(scaled-float64(partNum)) > 1e-6 {
+			return nil, nil, fmt.Errorf("split amount %g has more decimals than %s allows", part.Amount, r.currency.Mnemonic)
+		}
+		counterpart, err := r.resolve(ctx, part.Category, num < 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if counterpart.GUID == bank.GUID {
+			return nil, nil, fmt.Errorf("the counterpart is the imported account itself")
+		}
+		total += partNum
+		splits = append(splits, newSplit{GUID: newGUID(), AccountGUID: counterpart.GUID, Memo: part.Memo, ValueNum: -partNum})
+		names = append(names, counterpart.FullName)
+	}
+	if total != num {
+		return nil, nil, fmt.Errorf("the splits sum to %s instead of %s", FormatFraction(total, r.currency.Fraction, r.currency.Fraction), FormatFraction(num, r.currency.Fraction, r.currency.Fraction))
+	}
+	return splits, names, nil
+}
+
+// resolve returns the counterpart for a line's category. Lines without one
+// go to the default account, else to an Imbalance-<currency> account as in
+// GnuCash's importers. outflow tells whether money leaves the imported
+// account, which makes a new category an expense rather than an income.
+func (r *counterpartResolver) resolve(ctx context.Context, category string, outflow bool) (*Account, error) {
+	category = strings.TrimSpace(category)
+	if acc, ok := r.resolved[category]; ok {
+		return acc, nil
+	}
+	acc, err := r.lookup(ctx, category, outflow)
+	if err != nil {
+		return nil, err
+	}
+	if acc.Placeholder {
+		return nil, fmt.Errorf("account %s is a placeholder and cannot hold transactions", acc.FullName)
+	}
+	if acc.CommodityGUID != "" && acc.CommodityGUID != r.currency.GUID {
+		return nil, fmt.Errorf("account %s is not kept in %s", acc.FullName, r.currency.Mnemonic)
+	}
+	r.resolved[category] = acc
+	return acc, nil
+}
+
+func (r *counterpartResolver) lookup(ctx context.Context, category string, outflow bool) (*Account, error) {
+	if category == "" && r.opts.DefaultAccount != "" {
+		return r.s.resolveWriteAccount(ctx, r.opts.DefaultAccount)
+	}
+	accounts, err := r.s.accountTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if category == "" {
+		// GnuCash books unbalanced imports to a top-level Imbalance account.
+		name := "Imbalance-" + r.currency.Mnemonic
+		for _, acc := range accounts {
+			if acc.FullName == name {
+				return acc, nil
+			}
+		}
+		return r.create(ctx, accounts, name, "", "BANK")
+	}
+
+	// Categories are matched exactly: a line whose category only resembles
+	// accounts fails, listing them, rather than being booked to one.
+	acc, err := r.s.resolveWriteAccount(ctx, category)
+	if err == nil || !r.opts.CreateAccounts || !errors.Is(err, errNoAccount) {
+		return acc, err
+	}
+	parent, name := "", category
+	if i := strings.LastIndex(category, ":"); i >= 0 {
+		parent, name = category[:i], category[i+1:]
+	}
+	accountType := "INCOME"
+	if outflow {
+		accountType = "EXPENSE"
+	}
+	return r.create(ctx, accounts, name, parent, accountType)
+}
+
+// create plans a new account named name under parent (a top-level account
+// of accountType when empty), taking the parent's type when it has one.
+func (r *counterpartResolver) create(ctx context.Context, accounts map[string]*Account, name, parent, accountType string) (*Account, error) {
+	name, err := checkAccountName(name)
+	if err != nil {
+		return nil, err
+	}
+	acc := &Account{GUID: newGUID(), Name: name, AccountType: accountType, CommodityGUID: r.currency.GUID}
+	switch {
+	case parent != "":
+		p, err := r.s.resolveWriteAccount(ctx, parent)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create %s:%s: %w", parent, name, err)
+		}
+		acc.ParentGUID, acc.AccountType, acc.FullName = p.GUID, p.AccountType, p.FullName+":"+name
+	case accountType == "BANK":
+		if acc.ParentGUID, err = r.s.db.rootAccountGUID(ctx); err != nil {
+			return nil, err
+		}
+		acc.FullName = name
+	default:
+		var top *Account
+		for _, a := range accounts {
+			if a.AccountType == accountType && !strings.Contains(a.FullName, ":") && (top == nil || a.FullName < top.FullName) {
+				top = a
+			}
+		}
+		if top == nil {
+			return nil, fmt.Errorf("cannot create %s: the book has no top-level %s account", name, strings.ToLower(accountType))
+		}
+		acc.ParentGUID, acc.FullName = top.GUID, top.FullName+":"+name
+	}
+	for _, planned := range r.created {
+		if planned.ParentGUID == acc.ParentGUID && strings.EqualFold(planned.Name, name) {
+			return &planned.Account, nil
+		}
+	}
+	if err := checkSiblingName(accounts, acc.ParentGUID, name, ""); err != nil {
+		return nil, err
+	}
+	r.created = append(r.created, newAccount{*acc, r.currency})
+	return acc, nil
+}
+
+func (r *counterpartResolver) createdNames() []string {
+	names := make([]string, len(r.created))
+	for i, acc := range r.created {
+		names[i] = fmt.Sprintf("%s (%s, %s)", acc.FullName, acc.AccountType, r.currency.Mnemonic)
+	}
+	return names
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
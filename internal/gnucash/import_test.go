@@ -0,0 +1,316 @@
+package gnucash
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStatementAmount(t *testing.T) {
+	tests := []struct {
+		in           string
+		decimalComma bool
+		want         float64
+	}{
+		{"-18.40", false, -18.4},
+		{"1,234.56", false, 1234.56},
+		{"1.234,56 €", true, 1234.56},
+		{"(25.00)", false, -25},
+		{"12.00-", false, -12},
+		{"", false, 0},
+	}
+	for _, tt := range tests {
+		got, err := parseStatementAmount(tt.in, tt.decimalComma)
+		if err != nil || got != tt.want {
+			t.Errorf("parseStatementAmount(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := parseStatementAmount("abc", false); err == nil {
+		t.Error("expected an error for a non-numeric amount")
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	statement := strings.Join([]string{
+		"Date;Payee;Debit;Credit;Category",
+		"20/01/2025;Supermarket;85,50;;",         // already in the book
+		"22/01/2025;Bistro;18,40;;Restaurant",    // existing category
+		"23/01/2025;Vet;80,00;;Pets",             // new category
+		"24/01/2025;Refund;;12,00;",              // no category
+		"31/02/2025;Bad date;1,00;;",             // invalid
+		"25/01/2025;Kibble;30,00;;Expenses:Pets", // same new category by path
+	}, "\n")
+	profile := CSVProfile{Date: "Date", DateFormat: "DD/MM/YYYY", Description: "payee", Debit: "Debit", Credit: "Credit",
+		Category: "Category", Delimiter: ";", DecimalComma: true}
+
+	result, err := svc.With(WithDryRun()).ImportCSV(ctx, "Checking", statement, profile, "", false)
+	if err != nil {
+		t.Fatalf("ImportCSV() dry run returned error: %v", err)
+	}
+	if !strings.Contains(result, "Would import 2 transaction(s) into Assets:Checking, skipped 1 already present, 3 failed") ||
+		!strings.Contains(result, "no account found matching 'Pets'") {
+		t.Errorf("unexpected dry run result:\n%s", result)
+	}
+
+	result, err = svc.ImportCSV(ctx, "Checking", statement, profile, "", true)
+	if err != nil {
+		t.Fatalf("ImportCSV() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Imported 4 transaction(s) into Assets:Checking, skipped 1 already present, 1 failed",
+		"2025-01-22  Bistro  -18.40 EUR  -> Expenses:Restaurant",
+		"2025-01-25  Kibble  -30.00 EUR  -> Expenses:Pets",
+		"2025-01-24  Refund  12.00 EUR  -> Imbalance-EUR",
+		"Expenses:Pets (EXPENSE, EUR)",
+		"Imbalance-EUR (BANK, EUR)",
+		"line 2: 2025-01-20  Supermarket  -85.50 EUR",
+		"line 6: invalid date '31/02/2025'",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	// Importing the statement again skips every line.
+	result, err = svc.ImportCSV(ctx, "Checking", statement, profile, "", true)
+	if err != nil {
+		t.Fatalf("ImportCSV() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Imported 0 transaction(s) into Assets:Checking, skipped 5 already present") {
+		t.Errorf("expected all lines skipped, got:\n%s", result)
+	}
+
+	// The import is undone as a whole, created accounts included.
+	if _, err := svc.UndoLastChange(ctx); err != nil {
+		t.Fatalf("UndoLastChange() returned error: %v", err)
+	}
+	var accounts, transactions int
+	db.db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE name IN ('Pets', 'Imbalance-EUR')`).Scan(&accounts)
+	db.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE description IN ('Bistro', 'Vet', 'Refund', 'Kibble')`).Scan(&transactions)
+	if accounts != 0 || transactions != 0 {
+		t.Errorf("expected the import undone, %d accounts and %d transactions remain", accounts, transactions)
+	}
+}
+
+func TestImportCSVExactAccounts(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	statement := strings.Join([]string{
+		"Date,Payee,Amount,Category",
+		"2025-01-22,Bistro,-18.40,Restaurnt",        // typo
+		"2025-01-23,Market,-9.90,Groc",              // partial name
+		"2025-01-24,Shop,-5.00,Expenses:Restaurant", // exact path
+		"2025-01-25,Vet,-80.00,Pets",                // resembles nothing
+	}, "\n")
+	profile := CSVProfile{Date: "Date", Description: "Payee", Amount: "Amount", Category: "Category"}
+
+	if _, err := svc.ImportCSV(ctx, "Check", statement, profile, "", true); err == nil || !strings.Contains(err.Error(), "did you mean Assets:Checking?") {
+		t.Errorf("expected a partial account name to be refused, got: %v", err)
+	}
+	uncategorized := "Date,Payee,Amount,Category\n2025-01-26,Cash,-1.00,"
+	result, err := svc.With(WithDryRun()).ImportCSV(ctx, "Checking", uncategorized, profile, "Restaurnt", false)
+	if err != nil || !strings.Contains(result, "line 2: no account is named exactly 'Restaurnt'") {
+		t.Errorf("expected a misspelled default account to be refused, got %v:\n%s", err, result)
+	}
+
+	result, err = svc.ImportCSV(ctx, "Checking", statement, profile, "", true)
+	if err != nil {
+		t.Fatalf("ImportCSV() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Imported 2 transaction(s) into Assets:Checking, skipped 0 already present, 2 failed",
+		"2025-01-24  Shop  -5.00 EUR  -> Expenses:Restaurant",
+		"2025-01-25  Vet  -80.00 EUR  -> Expenses:Pets",
+		"line 2: no account is named exactly 'Restaurnt' (did you mean Expenses:Restaurant?)",
+		"line 3: no account is named exactly 'Groc' (did you mean Expenses:Groceries?)",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+}
+
+func TestImportSkipsKnownLinesInOlderBooks(t *testing.T) {
+	ctx := context.Background()
+	statement := "Date,Payee,Amount\n2025-01-20,Supermarket,-85.50\n2025-01-25,Pizza place,-25.00"
+	profile := CSVProfile{Date: "Date", Description: "Payee", Amount: "Amount"}
+
+	for _, tt := range []struct {
+		name  string
+		setup string
+		opts  []Option
+	}{
+		// Books of GnuCash before 2.6 store 14-digit numbers.
+		{"legacy timestamps", `UPDATE transactions SET post_date = CAST(strftime('%Y%m%d%H%M%S', post_date) AS INTEGER),
+		                                           enter_date = CAST(strftime('%Y%m%d%H%M%S', enter_date) AS INTEGER)`, nil},
+		// Older versions store the local midnight of the date, the day before
+		// in UTC east of Greenwich.
+		{"local midnight", `UPDATE transactions SET post_date = datetime(post_date, '-1 hour')`,
+			[]Option{WithTimezone(time.FixedZone("CET", 3600))}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupWriteTestDB(t)
+			if _, err := db.db.Exec(tt.setup); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.detectTimestampLayout(ctx); err != nil {
+				t.Fatal(err)
+			}
+			svc := NewService(db, append(tt.opts, WithWrites())...)
+
+			result, err := svc.ImportCSV(ctx, "Checking", statement, profile, "", false)
+			if err != nil {
+				t.Fatalf("ImportCSV() returned error: %v", err)
+			}
+			if !strings.Contains(result, "Imported 0 transaction(s) into Assets:Checking, skipped 2 already present") {
+				t.Errorf("expected the lines already in the book skipped, got:\n%s", result)
+			}
+		})
+	}
+}
+
+const testOFX = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>EUR
+<BANKACCTFROM><BANKID>12345<ACCTID>987654<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20250101<DTEND>20250131
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250122120000.000[+1:CET]
+<TRNAMT>-18.40
+<FITID>202501220001
+<NAME>BISTRO &amp; CO
+<MEMO>Card payment
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250124
+<TRNAMT>12,00
+<FITID>202501240001
+<PAYEE><NAME>Refund shop</PAYEE>
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>2025
+<TRNAMT>-1.00
+<FITID>202501250001
+<NAME>Broken
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func TestImportOFX(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	result, err := svc.ImportOFX(ctx, "Checking", testOFX, "Restaurant")
+	if err != nil {
+		t.Fatalf("ImportOFX() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Imported 2 transaction(s) into Assets:Checking, skipped 0 already present, 1 failed",
+		"2025-01-22  BISTRO & CO  -18.40 EUR  -> Expenses:Restaurant",
+		"2025-01-24  Refund shop  12.00 EUR",
+		"invalid DTPOSTED '2025'",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+	var ids int
+	db.db.QueryRow(`SELECT COUNT(*) FROM slots WHERE name = 'online_id' AND string_val IN ('202501220001', '202501240001')`).Scan(&ids)
+	if ids != 2 {
+		t.Errorf("expected 2 online_id slots, got %d", ids)
+	}
+
+	// The FITID identifies a transaction even after its description changed,
+	// e.g. when it was edited in GnuCash.
+	db.db.Exec(`UPDATE transactions SET description = 'Bistro' WHERE description = 'BISTRO & CO'`)
+	result, err = svc.ImportOFX(ctx, "Checking", testOFX, "Restaurant")
+	if err != nil {
+		t.Fatalf("ImportOFX() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Imported 0 transaction(s) into Assets:Checking, skipped 2 already present") {
+		t.Errorf("expected known FITIDs skipped, got:\n%s", result)
+	}
+
+	if _, err := svc.ImportOFX(ctx, "Checking", strings.Replace(testOFX, "<CURDEF>EUR", "<CURDEF>USD", 1), ""); err == nil || !strings.Contains(err.Error(), "in USD") {
+		t.Errorf("expected currency mismatch error, got: %v", err)
+	}
+
+	if _, err := svc.UndoLastChange(ctx); err != nil {
+		t.Fatalf("UndoLastChange() returned error: %v", err)
+	}
+	db.db.QueryRow(`SELECT COUNT(*) FROM slots WHERE name = 'online_id'`).Scan(&ids)
+	if ids != 0 {
+		t.Errorf("expected online_id slots removed by undo, %d remain", ids)
+	}
+}
+
+func TestImportQIF(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	qif := strings.Join([]string{
+		"!Type:Bank",
+		"D1/22'25", "T-18.40", "PBistro", "LRestaurants/Business", "^",
+		"D1/23/2025", "T-100.00", "PHypermarket", "SGrocerys", "EFood", "$-60.00", "SHobbies", "$-40.00", "^",
+		"D1/24/2025", "T3,000.00", "PSalary", "LSalary", "^",
+		"D13/45/2025", "T-1.00", "PBroken", "^",
+	}, "\n")
+
+	result, err := svc.With(WithDryRun()).ImportQIF(ctx, "Checking", qif, "", nil, "")
+	if err != nil {
+		t.Fatalf("ImportQIF() dry run returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Would import 3 transaction(s) into Assets:Checking, skipped 0 already present, 1 failed",
+		"2025-01-23  Hypermarket  -100.00 EUR  -> Expenses:Groceries, Imbalance-EUR",
+		"Grocerys     -> Expenses:Groceries (fuzzy)",
+		"Hobbies      -> unmatched (no close match), booked to the Imbalance account",
+		"Restaurants  -> Expenses:Restaurant (fuzzy)",
+		"Salary       -> Income:Salary\n",
+		"line 21: invalid date '13/45/2025'",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.ImportQIF(ctx, "Checking", qif, "MM/DD/YYYY", map[string]string{"Hobbies": "Restaurnt"}, ""); err == nil ||
+		!strings.Contains(err.Error(), "mapping of 'Hobbies': no account is named exactly 'Restaurnt'") {
+		t.Errorf("expected a misspelled mapping to be refused, got: %v", err)
+	}
+	result, err = svc.ImportQIF(ctx, "Checking", qif, "MM/DD/YYYY", map[string]string{"Hobbies": "Restaurant"}, "")
+	if err != nil {
+		t.Fatalf("ImportQIF() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Hobbies      -> Expenses:Restaurant (mapping)") {
+		t.Errorf("expected the mapping applied, got:\n%s", result)
+	}
+	var value int64
+	db.db.QueryRow(`SELECT s.value_num FROM splits s JOIN transactions t ON s.tx_guid = t.guid WHERE t.description = 'Hypermarket' AND s.account_guid = 'groceries'`).Scan(&value)
+	if value != 6000 {
+		t.Errorf("expected 60.00 booked to groceries, got %d", value)
+	}
+
+	if _, err := svc.ImportQIF(ctx, "Checking", "!Type:Invst\nD1/1/2025\n^", "", nil, ""); err == nil {
+		t.Error("expected an error for an investment QIF file")
+	}
+}
@@ -0,0 +1,174 @@
+package gnucash
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriceIndex is a consumer price index: the price level of each month or
+// year it covers, such as the CPI published by a statistics office. Reports
+// restate an amount of a past month in current money by multiplying it by
+// the ratio of the current level to that month's. A yearly level covers the
+// whole year; months outside the index take its nearest level.
+type PriceIndex struct {
+	points []indexPoint // by month
+}
+
+// indexPoint is the price level from Month until the next point.
+type indexPoint struct {
+	Month time.Time
+	Level float64
+}
+
+// newPriceIndex builds an index from levels keyed by YYYY-MM or YYYY.
+func newPriceIndex(levels map[string]float64) (*PriceIndex, error) {
+	p := &PriceIndex{}
+	for period, level := range levels {
+		month, err := time.Parse("2006-01", period)
+		if err != nil {
+			if month, err = time.Parse("2006", period); err != nil {
+				return nil, fmt.Errorf("invalid price index period '%s' (expected YYYY-MM or YYYY)", period)
+			}
+		}
+		if level <= 0 {
+			return nil, fmt.Errorf("invalid price index level %g for %s (expected a positive number)", level, period)
+		}
+		p.points = append(p.points, indexPoint{month, level})
+	}
+	if len(p.points) == 0 {
+		return nil, fmt.Errorf("the price index is empty")
+	}
+	slices.SortFunc(p.points, func(a, b indexPoint) int { return a.Month.Compare(b.Month) })
+	return p, nil
+}
+
+// LoadPriceIndex reads a price index from a CSV file of period,level rows,
+// the period being YYYY-MM or YYYY ("2024-01,308.4"). A header row is
+// skipped.
+func LoadPriceIndex(path string) (*PriceIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read price index: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse price index %s: %w", path, err)
+	}
+	levels := make(map[string]float64, len(records))
+	for i, rec := range records {
+		level, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("parse price index %s: invalid level '%s' on line %d", path, rec[1], i+1)
+		}
+		levels[strings.TrimSpace(rec[0])] = level
+	}
+	p, err := newPriceIndex(levels)
+	if err != nil {
+		return nil, fmt.Errorf("parse price index %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ParseInflationSeries builds a price index from yearly inflation rates in
+// percent, as "2022:8.0, 2023:4.1, 2024:2.9": each year's level is the
+// previous one raised by its rate.
+func ParseInflationSeries(series string) (*PriceIndex, error) {
+	rates := make(map[int]float64)
+	for _, item := range strings.Split(series, ",") {
+		year, rate, ok := strings.Cut(strings.TrimSpace(item), ":")
+		y, err := strconv.Atoi(strings.TrimSpace(year))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid inflation rate '%s' (expected YEAR:PERCENT, e.g. 2024:2.9)", item)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(rate), "%"), 64)
+		if err != nil || r <= -100 {
+			return nil, fmt.Errorf("invalid inflation rate '%s' for %d", rate, y)
+		}
+		rates[y] = r
+	}
+	years := make([]int, 0, len(rates))
+	for y := range rates {
+		years = append(years, y)
+	}
+	slices.Sort(years)
+	levels := map[string]float64{}
+	level := 100.0
+	for y := years[0]; y <= years[len(years)-1]; y++ {
+		level *= 1 + rates[y]/100 // years left out count as no inflation
+		levels[strconv.Itoa(y)] = level
+	}
+	return newPriceIndex(levels)
+}
+
+// level returns the price level of the month of t.
+func (p *PriceIndex) level(t time.Time) float64 {
+	month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	i, found := slices.BinarySearchFunc(p.points, month, func(pt indexPoint, m time.Time) int { return pt.Month.Compare(m) })
+	if !found {
+		i = max(0, i-1)
+	}
+	return p.points[i].Level
+}
+
+// WithPriceIndex lets reports restate amounts in current money with p when
+// a call asks for it (see WithInflationAdjustment).
+func WithPriceIndex(p *PriceIndex) Option {
+	return func(s *Service) { s.priceIndex = p }
+}
+
+// WithInflationAdjustment makes reports restate past amounts in current
+// money with p, or with the service's price index when p is nil.
+func WithInflationAdjustment(p *PriceIndex) Option {
+	return func(s *Service) {
+		s.inflate = true
+		if p != nil {
+			s.priceIndex = p
+		}
+	}
+}
+
+// restater restates amounts in current money.
+type restater struct {
+	index *PriceIndex
+	now   time.Time
+}
+
+// restate returns amount, of the month of t, in current money.
+func (r *restater) restate(amount float64, t time.Time) float64 {
+	if r == nil {
+		return amount
+	}
+	return amount * r.index.level(r.now) / r.index.level(t)
+}
+
+// note describes the adjustment for report headers, "" without one.
+func (r *restater) note() string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("Amounts restated in %s money with the price index.\n", r.now.Format("January 2006"))
+}
+
+// inflation returns the restater of the call, nil when it does not ask for
+// inflation-adjusted amounts.
+func (s *Service) inflation() (*restater, error) {
+	if !s.inflate {
+		return nil, nil
+	}
+	if s.priceIndex == nil {
+		return nil, fmt.Errorf("no price index is configured (set GNUCASH_CPI_FILE, or pass an inflation_series)")
+	}
+	return &restater{index: s.priceIndex, now: s.now()}, nil
+}
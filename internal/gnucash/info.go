@@ -0,0 +1,210 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// currentLock returns who holds the book open in GnuCash, or "" if nobody
+// does.
+func (d *DB) currentLock(ctx context.Context) (string, error) {
+	dbTx, err := d.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", fmt.Errorf("begin read: %w", err)
+	}
+	defer dbTx.Rollback()
+	return d.bookLock(ctx, dbTx)
+}
+
+// gnucashVersion formats a version of the versions table, stored as
+// major*1000000 + minor*10000 + micro, e.g. 5040000 for 5.4.
+func gnucashVersion(v int) string {
+	if micro := v % 10000; micro != 0 {
+		return fmt.Sprintf("%d.%d.%d", v/1000000, v/10000%100, micro)
+	}
+	return fmt.Sprintf("%d.%d", v/1000000, v/10000%100)
+}
+
+// getSchemaVersions returns the versions of GnuCash that created and last
+// saved the book, from its versions table; empty if it has none.
+func (d *DB) getSchemaVersions(ctx context.Context) (created, resaved string, err error) {
+	ok, err := d.hasTable(ctx, "versions")
+	if err != nil || !ok {
+		return "", "", err
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT table_name, table_version FROM versions WHERE table_name IN ('Gnucash', 'Gnucash-Resave')`)
+	if err != nil {
+		return "", "", fmt.Errorf("query schema versions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		var version int
+		if err := rows.Scan(&table, &version); err != nil {
+			return "", "", fmt.Errorf("scan schema version: %w", err)
+		}
+		if table == "Gnucash" {
+			created = gnucashVersion(version)
+		} else {
+			resaved = gnucashVersion(version)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", "", fmt.Errorf("iterate schema versions: %w", err)
+	}
+	return created, resaved, nil
+}
+
+// getFeatures returns the GnuCash features the book uses, which versions
+// of GnuCash that do not know them refuse to open it.
+func (d *DB) getFeatures(ctx context.Context) ([]string, error) {
+	if ok, err := d.hasTable(ctx, "slots"); err != nil || !ok {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT substr(name, 10) FROM slots WHERE name LIKE 'features/%' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("query features: %w", err)
+	}
+	defer rows.Close()
+	var features []string
+	for rows.Next() {
+		var feature string
+		if err := rows.Scan(&feature); err != nil {
+			return nil, fmt.Errorf("scan feature: %w", err)
+		}
+		features = append(features, feature)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate features: %w", err)
+	}
+	return features, nil
+}
+
+// BookInfo describes the book file and how the service reads it: path,
+// size and modification time, contents, the GnuCash versions and features
+// of the schema, whether it uses trading accounts, whether GnuCash has it
+// open, and the optional features enabled.
+func (s *Service) BookInfo(ctx context.Context) (string, error) {
+	var sb strings.Builder
+	if s.db.path != "" {
+		path, err := filepath.Abs(s.db.path)
+		if err != nil {
+			path = s.db.path
+		}
+		fmt.Fprintf(&sb, "File: %s\n", path)
+		if info, err := os.Stat(s.db.path); err == nil {
+			fmt.Fprintf(&sb, "Size: %s, modified %s\n", fileSize(info.Size()), info.ModTime().Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	st, err := s.db.getBookStats(ctx)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&sb, "Contents: %d account(s), %d transaction(s), %d price(s)\n", st.Accounts, st.Transactions, st.Prices)
+
+	mode := "read-only"
+	if s.write && s.db.rw != nil {
+		mode = "read-write (write mode)"
+	}
+	if s.db.snapshot.current.Load() != nil {
+		mode += ", reading a snapshot taken while GnuCash has the book open"
+	}
+	if s.db.redact {
+		mode += ", descriptions, memos and notes redacted"
+	}
+	fmt.Fprintf(&sb, "Mode: %s\n", mode)
+
+	created, resaved, err := s.db.getSchemaVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case created == "":
+		sb.WriteString("Schema: no versions table\n")
+	case resaved != "" && resaved != created:
+		fmt.Fprintf(&sb, "Schema: created by GnuCash %s, last saved by GnuCash %s\n", created, resaved)
+	default:
+		fmt.Fprintf(&sb, "Schema: GnuCash %s\n", created)
+	}
+	features, err := s.db.getFeatures(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(features) == 0 {
+		sb.WriteString("Features: none\n")
+	} else {
+		fmt.Fprintf(&sb, "Features: %s\n", strings.Join(features, "; "))
+	}
+	trading, err := s.db.hasTradingAccounts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if trading {
+		sb.WriteString("Trading accounts: yes (left out of transaction amounts; see currency_gains)\n")
+	} else {
+		sb.WriteString("Trading accounts: no\n")
+	}
+
+	holder, err := s.db.currentLock(ctx)
+	if err != nil {
+		return "", err
+	}
+	if holder != "" {
+		fmt.Fprintf(&sb, "Open in GnuCash: locked by %s\n", holder)
+	} else {
+		sb.WriteString("Open in GnuCash: no\n")
+	}
+
+	var enabled []string
+	if s.horizon > 0 {
+		enabled = append(enabled, fmt.Sprintf("date horizon (%d years)", s.horizon))
+	}
+	if s.index != nil {
+		enabled = append(enabled, "search index")
+	}
+	if s.aggregates != nil {
+		enabled = append(enabled, "monthly aggregates cache")
+	}
+	if s.snapshots != nil {
+		enabled = append(enabled, "chart snapshots")
+	}
+	if s.auditLog != nil {
+		enabled = append(enabled, "audit log")
+	}
+	if s.exportDir != "" {
+		enabled = append(enabled, "exports")
+	}
+	if s.sql {
+		enabled = append(enabled, "SQL queries")
+	}
+	if s.expressions {
+		enabled = append(enabled, "expressions")
+	}
+	if len(s.groups) > 0 {
+		enabled = append(enabled, "category groups")
+	}
+	if len(enabled) == 0 {
+		enabled = append(enabled, "none")
+	}
+	fmt.Fprintf(&sb, "Optional features: %s\n", strings.Join(enabled, ", "))
+	return sb.String(), nil
+}
+
+// fileSize formats a size in bytes with a binary unit.
+func fileSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,332 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// bookExport is the document written by export_json. Amounts are exact
+// decimal strings; dates are YYYY-MM-DD.
+type bookExport struct {
+	Format      string `json:"format"`
+	Version     int    `json:"version"`
+	GeneratedAt string `json:"generated_at"`
+	StartDate   string `json:"start_date,omitempty"`
+	EndDate     string `json:"end_date,omitempty"`
+	Book        struct {
+		Path   string `json:"path,omitempty"`
+		SHA256 string `json:"sha256,omitempty"`
+	} `json:"book"`
+	Commodities     []jsonCommodity      `json:"commodities"`
+	Accounts        []jsonAccount        `json:"accounts"`
+	OpeningBalances []jsonOpeningBalance `json:"opening_balances,omitempty"`
+	Transactions    []jsonTransaction    `json:"transactions"`
+	Prices          []jsonPrice          `json:"prices"`
+	Budgets         []jsonBudget         `json:"budgets"`
+}
+
+type jsonCommodity struct {
+	GUID      string `json:"guid"`
+	Namespace string `json:"namespace"`
+	Mnemonic  string `json:"mnemonic"`
+	FullName  string `json:"full_name,omitempty"`
+	CUSIP     string `json:"cusip,omitempty"`
+	Fraction  int64  `json:"fraction"`
+}
+
+type jsonAccount struct {
+	GUID        string `json:"guid"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Type        string `json:"type"`
+	ParentGUID  string `json:"parent_guid,omitempty"`
+	Commodity   string `json:"commodity,omitempty"`
+	Description string `json:"description,omitempty"`
+	Hidden      bool   `json:"hidden,omitempty"`
+	Placeholder bool   `json:"placeholder,omitempty"`
+}
+
+type jsonOpeningBalance struct {
+	AccountGUID string `json:"account_guid"`
+	Account     string `json:"account"`
+	Commodity   string `json:"commodity"`
+	Quantity    string `json:"quantity"`
+}
+
+type jsonTransaction struct {
+	GUID        string      `json:"guid"`
+	Date        string      `json:"date"`
+	Currency    string      `json:"currency"`
+	Description string      `json:"description"`
+	Splits      []jsonSplit `json:"splits"`
+}
+
+type jsonSplit struct {
+	GUID        string `json:"guid"`
+	AccountGUID string `json:"account_guid"`
+	Account     string `json:"account"`
+	Memo        string `json:"memo,omitempty"`
+	Value       string `json:"value"`    // in the transaction currency
+	Quantity    string `json:"quantity"` // in the account's commodity
+}
+
+type jsonPrice struct {
+	Commodity string `json:"commodity"`
+	Namespace string `json:"namespace"`
+	Currency  string `json:"currency"`
+	Date      string `json:"date"`
+	Value     string `json:"value"`
+}
+
+type jsonBudget struct {
+	GUID        string             `json:"guid"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Periods     int                `json:"periods"`
+	Recurrence  *jsonRecurrence    `json:"recurrence,omitempty"`
+	Amounts     []jsonBudgetAmount `json:"amounts"`
+}
+
+// jsonRecurrence is the period of a budget: Multiplier PeriodType long
+// (e.g. 1 month), starting on Start.
+type jsonRecurrence struct {
+	Multiplier int    `json:"multiplier"`
+	PeriodType string `json:"period_type"`
+	Start      string `json:"start"`
+}
+
+type jsonBudgetAmount struct {
+	AccountGUID string `json:"account_guid"`
+	Account     string `json:"account"`
+	Period      int    `json:"period"` // 0-based
+	Amount      string `json:"amount"`
+}
+
+// hasTable reports whether the book has a table, for tables GnuCash only
+// creates once a feature is used.
+func (d *DB) hasTable(ctx context.Context, name string) (bool, error) {
+	var n int
+	err := d.queryRowPrepared(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("query tables: %w", err)
+	}
+	return n > 0, nil
+}
+
+// hasColumn reports whether a table of the book has a column, for columns
+// the books of older GnuCash versions or minimal exports lack.
+func (d *DB) hasColumn(ctx context.Context, table, column string) (bool, error) {
+	var n int
+	err := d.queryRowPrepared(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("query columns of %s: %w", table, err)
+	}
+	return n > 0, nil
+}
+
+// getBudgets returns the budgets of the book with their amounts, by name.
+// Account names of the amounts are left for the caller to fill in.
+func (d *DB) getBudgets(ctx context.Context) ([]jsonBudget, error) {
+	if ok, err := d.hasTable(ctx, "budgets"); err != nil || !ok {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT guid, name, COALESCE(description, ''), num_periods FROM budgets ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query budgets: %w", err)
+	}
+	defer rows.Close()
+	var budgets []jsonBudget
+	for rows.Next() {
+		var b jsonBudget
+		if err := rows.Scan(&b.GUID, &b.Name, &b.Description, &b.Periods); err != nil {
+			return nil, fmt.Errorf("scan budget: %w", err)
+		}
+		b.Amounts = []jsonBudgetAmount{}
+		budgets = append(budgets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if ok, err := d.hasTable(ctx, "recurrences"); err != nil {
+		return nil, err
+	} else if ok {
+		for i := range budgets {
+			var r jsonRecurrence
+			err := d.db.QueryRowContext(ctx, `
+				SELECT recurrence_mult, recurrence_period_type, recurrence_period_start
+				FROM recurrences WHERE obj_guid = ? LIMIT 1
+			`, budgets[i].GUID).Scan(&r.Multiplier, &r.PeriodType, &r.Start)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("query budget recurrence: %w", err)
+			}
+			if start, err := time.Parse("20060102", r.Start); err == nil {
+				r.Start = start.Format("2006-01-02")
+			}
+			budgets[i].Recurrence = &r
+		}
+	}
+
+	if ok, err := d.hasTable(ctx, "budget_amounts"); err != nil || !ok {
+		return budgets, err
+	}
+	amounts, err := d.db.QueryContext(ctx, `
+		SELECT budget_guid, account_guid, period_num, amount_num, amount_denom
+		FROM budget_amounts ORDER BY budget_guid, account_guid, period_num
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query budget amounts: %w", err)
+	}
+	defer amounts.Close()
+	index := make(map[string]int, len(budgets))
+	for i, b := range budgets {
+		index[b.GUID] = i
+	}
+	for amounts.Next() {
+		var budgetGUID string
+		var a jsonBudgetAmount
+		var num, denom int64
+		if err := amounts.Scan(&budgetGUID, &a.AccountGUID, &a.Period, &num, &denom); err != nil {
+			return nil, fmt.Errorf("scan budget amount: %w", err)
+		}
+		if i, ok := index[budgetGUID]; ok {
+			a.Amount = exactDecimal(num, denom)
+			budgets[i].Amounts = append(budgets[i].Amounts, a)
+		}
+	}
+	return budgets, amounts.Err()
+}
+
+// ExportJSON writes a structured dump of the book to a JSON file in the
+// export directory: commodities, accounts, budgets, and the transactions
+// and prices between startDate and endDate (both optional). With a start
+// date, the balances carried into the period are included.
+func (s *Service) ExportJSON(ctx context.Context, startDate, endDate string) (string, error) {
+	if err := s.checkExports(); err != nil {
+		return "", err
+	}
+	if err := checkExportPeriod(startDate, endDate); err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	commodities, err := s.db.getCommodities(ctx)
+	if err != nil {
+		return "", err
+	}
+	txs, err := s.db.getExportTransactions(ctx, startDate, endDate, nil)
+	if err != nil {
+		return "", err
+	}
+	txs = bookTransactions(txs, accounts)
+	prices, err := s.db.getAllPrices(ctx, commodities, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	budgets, err := s.db.getBudgets(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	doc := bookExport{Format: "gnucash-mcp-book", Version: 1, GeneratedAt: time.Now().Format(time.RFC3339),
+		StartDate: startDate, EndDate: endDate}
+	doc.Book.Path = s.db.path
+	if doc.Book.SHA256, err = s.db.BookHash(); err != nil {
+		return "", err
+	}
+
+	doc.Commodities = make([]jsonCommodity, 0, len(commodities))
+	for _, c := range sortedCommodities(commodities) {
+		if c.Namespace == "template" {
+			continue
+		}
+		doc.Commodities = append(doc.Commodities, jsonCommodity{GUID: c.GUID, Namespace: c.Namespace, Mnemonic: c.Mnemonic,
+			FullName: c.FullName, CUSIP: c.CUSIP, Fraction: c.Fraction})
+	}
+
+	sorted := make([]*Account, 0, len(accounts))
+	for _, acc := range accounts {
+		sorted = append(sorted, acc)
+	}
+	slices.SortFunc(sorted, func(a, b *Account) int { return cmp.Compare(a.FullName, b.FullName) })
+	doc.Accounts = make([]jsonAccount, 0, len(sorted))
+	for _, acc := range sorted {
+		ja := jsonAccount{GUID: acc.GUID, Name: acc.Name, FullName: acc.FullName, Type: acc.AccountType,
+			Commodity: commodities[acc.CommodityGUID].Mnemonic, Description: acc.Description,
+			Hidden: acc.Hidden, Placeholder: acc.Placeholder}
+		if _, ok := accounts[acc.ParentGUID]; ok {
+			ja.ParentGUID = acc.ParentGUID
+		}
+		doc.Accounts = append(doc.Accounts, ja)
+	}
+
+	if startDate != "" {
+		currency, err := s.db.defaultCurrency(ctx)
+		if err != nil {
+			return "", err
+		}
+		balances, _, err := s.openingBalances(ctx, startDate, accounts, commodities, currency, func(*Account) bool { return true })
+		if err != nil {
+			return "", err
+		}
+		for _, b := range balances {
+			doc.OpeningBalances = append(doc.OpeningBalances, jsonOpeningBalance{AccountGUID: b.Account.GUID,
+				Account: b.Account.FullName, Commodity: b.Commodity.Mnemonic, Quantity: b.Amount()})
+		}
+	}
+
+	splits := 0
+	doc.Transactions = make([]jsonTransaction, 0, len(txs))
+	for _, tx := range txs {
+		jt := jsonTransaction{GUID: tx.GUID, Date: tx.PostDate.Format("2006-01-02"),
+			Currency: commodities[tx.CurrencyGUID].Mnemonic, Description: tx.Description}
+		for _, sp := range tx.Splits {
+			jt.Splits = append(jt.Splits, jsonSplit{GUID: sp.GUID, AccountGUID: sp.AccountGUID,
+				Account: accounts[sp.AccountGUID].FullName, Memo: sp.Memo,
+				Value: exactDecimal(sp.ValueNum, sp.ValueDenom), Quantity: exactDecimal(sp.QuantityNum, sp.QuantityDenom)})
+		}
+		splits += len(jt.Splits)
+		doc.Transactions = append(doc.Transactions, jt)
+	}
+
+	doc.Prices = make([]jsonPrice, 0, len(prices))
+	for _, p := range prices {
+		doc.Prices = append(doc.Prices, jsonPrice{Commodity: p.Commodity.Mnemonic, Namespace: p.Commodity.Namespace,
+			Currency: p.Currency, Date: p.Date.Format("2006-01-02"), Value: exactDecimal(p.ValueNum, p.ValueDenom)})
+	}
+
+	doc.Budgets = make([]jsonBudget, 0, len(budgets))
+	for _, b := range budgets {
+		for i := range b.Amounts {
+			if acc, ok := accounts[b.Amounts[i].AccountGUID]; ok {
+				b.Amounts[i].Account = acc.FullName
+			}
+		}
+		doc.Budgets = append(doc.Budgets, b)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode export: %w", err)
+	}
+	path, err := s.writeExport("book", startDate, endDate, "json", string(data)+"\n")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("JSON export (%s) written to %s: %d account(s), %d commodities, %d transaction(s) with %d split(s), %d price(s), %d budget(s).\n",
+		periodLabel(startDate, endDate), path, len(doc.Accounts), len(doc.Commodities), len(doc.Transactions), splits,
+		len(doc.Prices), len(doc.Budgets)), nil
+}
@@ -0,0 +1,222 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ledgerCommodity writes a mnemonic as a Ledger commodity, quoted unless it
+// is made of letters only.
+func ledgerCommodity(mnemonic string) string {
+	if mnemonic != "" && strings.IndexFunc(mnemonic, func(r rune) bool { return !unicode.IsLetter(r) }) < 0 {
+		return mnemonic
+	}
+	return `"` + strings.ReplaceAll(mnemonic, `"`, "'") + `"`
+}
+
+// ledgerAccount collapses the runs of white space of an account path, two
+// spaces ending the account name of a Ledger posting.
+func ledgerAccount(fullName string) string {
+	return strings.Join(strings.Fields(fullName), " ")
+}
+
+// ledgerAccountType maps a GnuCash account type to the account type tag of
+// hledger, which Ledger keeps as a comment.
+func ledgerAccountType(accountType string) string {
+	switch accountType {
+	case "BANK", "CASH":
+		return "C"
+	case "LIABILITY", "CREDIT", "PAYABLE":
+		return "L"
+	case "EQUITY", "TRADING":
+		return "E"
+	case "INCOME":
+		return "R"
+	case "EXPENSE":
+		return "X"
+	}
+	return "A"
+}
+
+// unitCost returns the value of one unit of a split's quantity, with at
+// least two decimals.
+func unitCost(sp exportSplit) string {
+	r := new(big.Rat).Quo(big.NewRat(sp.ValueNum, sp.ValueDenom), big.NewRat(sp.QuantityNum, sp.QuantityDenom))
+	s := strings.TrimRight(r.Abs(r).FloatString(8), "0")
+	if i := strings.IndexByte(s, '.'); len(s)-i < 3 {
+		s += strings.Repeat("0", 3-(len(s)-i))
+	}
+	return s
+}
+
+// ExportLedger writes the transactions of the book between startDate and
+// endDate (both optional) to a Ledger journal in the export directory, read
+// by both Ledger and hledger. When account is set, only transactions with a
+// split in it or its sub-accounts are written, with all their splits. Each
+// split keeps its memo; securities bought carry their cost per unit and
+// date as a lot annotation, and every split in another commodity than its
+// transaction its total cost, so that the journal balances as the book.
+func (s *Service) ExportLedger(ctx context.Context, account, startDate, endDate string) (string, error) {
+	if err := s.checkExports(); err != nil {
+		return "", err
+	}
+	if err := checkExportPeriod(startDate, endDate); err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var subtree []string
+	selection, name := "the book", "book"
+	if account != "" {
+		acc, err := s.resolveAccount(ctx, account)
+		if err != nil {
+			return "", err
+		}
+		subtree = descendantGUIDs(acc)
+		selection = acc.FullName + " and its sub-accounts"
+		name = exportName(acc.FullName)
+	}
+	commodities, err := s.db.getCommodities(ctx)
+	if err != nil {
+		return "", err
+	}
+	txs, err := s.db.getExportTransactions(ctx, startDate, endDate, subtree)
+	if err != nil {
+		return "", err
+	}
+	txs = bookTransactions(txs, accounts)
+	prices, err := s.db.getAllPrices(ctx, commodities, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	defaultCurrency, err := s.db.defaultCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	usedAccounts := make(map[string]bool)
+	usedCommodities := make(map[string]bool)
+	var opening strings.Builder
+	equity := "Equity:Opening Balances"
+	if startDate != "" {
+		balances, totals, err := s.openingBalances(ctx, startDate, accounts, commodities, defaultCurrency,
+			func(acc *Account) bool { return subtree == nil || slices.Contains(subtree, acc.GUID) })
+		if err != nil {
+			return "", err
+		}
+		if len(balances) > 0 {
+			width := len(equity)
+			for _, b := range balances {
+				width = max(width, len(ledgerAccount(b.Account.FullName)))
+			}
+			fmt.Fprintf(&opening, "%s Opening balances\n", startDate)
+			for _, b := range balances {
+				fmt.Fprintf(&opening, "    %-*s  %s %s\n", width, ledgerAccount(b.Account.FullName), b.Amount(), ledgerCommodity(b.Commodity.Mnemonic))
+				usedAccounts[b.Account.GUID] = true
+				usedCommodities[b.Commodity.GUID] = true
+			}
+			for _, b := range totals {
+				fmt.Fprintf(&opening, "    %-*s  %s %s\n", width, equity, b.Amount(), ledgerCommodity(b.Commodity.Mnemonic))
+			}
+			opening.WriteString("\n")
+		}
+	}
+
+	var journal strings.Builder
+	for _, tx := range txs {
+		currency := commodities[tx.CurrencyGUID]
+		usedCommodities[tx.CurrencyGUID] = true
+		date := tx.PostDate.Format("2006-01-02")
+		fmt.Fprintf(&journal, "%s %s\n    ; guid: %s\n", date, strings.TrimSpace(tx.Description), tx.GUID)
+		width := 0
+		for _, sp := range tx.Splits {
+			width = max(width, len(ledgerAccount(accounts[sp.AccountGUID].FullName)))
+		}
+		for _, sp := range tx.Splits {
+			acc := accounts[sp.AccountGUID]
+			usedAccounts[acc.GUID] = true
+			value := exactDecimal(sp.ValueNum, sp.ValueDenom)
+			amount := value + " " + ledgerCommodity(currency.Mnemonic)
+			if c := accountCommodity(acc, tx.CurrencyGUID); c != tx.CurrencyGUID && sp.QuantityNum != 0 {
+				usedCommodities[c] = true
+				amount = exactDecimal(sp.QuantityNum, sp.QuantityDenom) + " " + ledgerCommodity(commodities[c].Mnemonic)
+				if sp.QuantityNum > 0 && sp.ValueNum > 0 {
+					amount += fmt.Sprintf(" {%s %s} [%s]", unitCost(sp), ledgerCommodity(currency.Mnemonic), date)
+				}
+				amount += fmt.Sprintf(" @@ %s %s", strings.TrimPrefix(value, "-"), ledgerCommodity(currency.Mnemonic))
+			}
+			fmt.Fprintf(&journal, "    %-*s  %s", width, ledgerAccount(acc.FullName), amount)
+			if memo := strings.Join(strings.Fields(sp.Memo), " "); memo != "" {
+				fmt.Fprintf(&journal, "  ; %s", memo)
+			}
+			journal.WriteString("\n")
+		}
+		journal.WriteString("\n")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "; Ledger journal of %s, %s, exported from GnuCash\n", selection, periodLabel(startDate, endDate))
+	fmt.Fprintf(&sb, "; Generated on %s\n\n", time.Now().Format("2006-01-02 15:04"))
+	for _, c := range sortedCommodities(commodities) {
+		if !usedCommodities[c.GUID] {
+			continue
+		}
+		fmt.Fprintf(&sb, "commodity %s\n", ledgerCommodity(c.Mnemonic))
+		note := c.FullName
+		if c.CUSIP != "" && c.Namespace != "CURRENCY" {
+			note = strings.TrimSpace(note + " " + c.CUSIP)
+		}
+		if note != "" && note != c.Mnemonic {
+			fmt.Fprintf(&sb, "    note %s\n", note)
+		}
+		sample := "1000"
+		if digits := fractionDigits(c.Fraction); digits > 0 {
+			sample += "." + strings.Repeat("0", digits)
+		}
+		fmt.Fprintf(&sb, "    format %s %s\n", sample, ledgerCommodity(c.Mnemonic))
+	}
+	sb.WriteString("\n")
+
+	var declared []*Account
+	for guid := range usedAccounts {
+		declared = append(declared, accounts[guid])
+	}
+	slices.SortFunc(declared, func(a, b *Account) int { return strings.Compare(a.FullName, b.FullName) })
+	for _, acc := range declared {
+		fmt.Fprintf(&sb, "account %s  ; type: %s\n", ledgerAccount(acc.FullName), ledgerAccountType(acc.AccountType))
+		if acc.Description != "" {
+			fmt.Fprintf(&sb, "    note %s\n", strings.Join(strings.Fields(acc.Description), " "))
+		}
+	}
+	if opening.Len() > 0 && !slices.ContainsFunc(declared, func(acc *Account) bool { return ledgerAccount(acc.FullName) == equity }) {
+		fmt.Fprintf(&sb, "account %s  ; type: E\n", equity)
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(opening.String())
+	sb.WriteString(journal.String())
+
+	written := 0
+	for _, p := range prices {
+		if !usedCommodities[p.Commodity.GUID] {
+			continue
+		}
+		fmt.Fprintf(&sb, "P %s %s %s %s\n", p.Date.Format("2006-01-02"), ledgerCommodity(p.Commodity.Mnemonic),
+			exactDecimal(p.ValueNum, p.ValueDenom), ledgerCommodity(p.Currency))
+		written++
+	}
+
+	path, err := s.writeExport(name, startDate, endDate, "journal", sb.String())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Ledger journal of %s (%s) written to %s: %d account(s), %d transaction(s), %d price(s). Check it with hledger check or ledger balance.\n",
+		selection, periodLabel(startDate, endDate), path, len(declared), len(txs), written), nil
+}
@@ -0,0 +1,91 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// While GnuCash has a SQLite book open it saves each change as it is made,
+// so reads can meet its write locks or, across several queries, a book
+// halfway through a change. With snapshot reads, the book is copied to a
+// temporary file in a single read transaction whenever it is found locked
+// and changed, and reads go to the copy until GnuCash closes the book.
+
+// liveSnapshot is the copy of a locked book that reads go to.
+type liveSnapshot struct {
+	dir     string                 // temporary directory, "" unless EnableSnapshotReads was called
+	current atomic.Pointer[string] // snapshot read from, nil when reading the book itself
+	copies  int                    // snapshots taken, to name the next one
+}
+
+// readPath returns the file reads go to: the current snapshot, or the book.
+func (d *DB) readPath() string {
+	if p := d.snapshot.current.Load(); p != nil {
+		return *p
+	}
+	return d.path
+}
+
+// EnableSnapshotReads makes reads go to a snapshot of the book while GnuCash
+// has it open, taking a new one whenever Refresh sees the book change. A
+// database that is not backed by a file (tests) is always read directly.
+func (d *DB) EnableSnapshotReads() error {
+	if d.path == "" {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "gnucash-snapshot-")
+	if err != nil {
+		return fmt.Errorf("create snapshot directory: %w", err)
+	}
+	d.stamp.mu.Lock()
+	defer d.stamp.mu.Unlock()
+	d.snapshot.dir = dir
+	return d.syncSnapshot(context.Background())
+}
+
+// syncSnapshot takes a new snapshot if GnuCash has the book open, or goes
+// back to reading the book if not. The caller holds d.stamp.mu.
+func (d *DB) syncSnapshot(ctx context.Context) error {
+	live, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(5000)", d.path))
+	if err != nil {
+		return fmt.Errorf("open book for snapshot: %w", err)
+	}
+	defer live.Close()
+
+	dbTx, err := live.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("open book for snapshot: %w", err)
+	}
+	holder, err := d.bookLock(ctx, dbTx)
+	dbTx.Rollback()
+	if err != nil {
+		return err
+	}
+	previous := d.snapshot.current.Load()
+	if holder == "" {
+		if previous == nil {
+			return nil
+		}
+		d.snapshot.current.Store(nil)
+	} else {
+		d.snapshot.copies++
+		path := filepath.Join(d.snapshot.dir, fmt.Sprintf("snapshot-%d.gnucash", d.snapshot.copies))
+		// VACUUM INTO copies the book as of a single read transaction.
+		if _, err := live.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("snapshot book: %w", err)
+		}
+		d.snapshot.current.Store(&path)
+	}
+	d.retireConnections()
+	if previous != nil {
+		// Connections still reading it keep it open until released, which
+		// Unix allows; elsewhere it is left for Close.
+		os.Remove(*previous)
+	}
+	return nil
+}
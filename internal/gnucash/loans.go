@@ -0,0 +1,191 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// loanPaymentWindow is the number of latest payments whose pace projects
+// the payoff date of a loan.
+const loanPaymentWindow = 6
+
+// loanSummary is the history of one loan for LoanSummary.
+type loanSummary struct {
+	Account       *Account
+	Borrowed      float64 // in the period
+	PrincipalPaid float64 // in the period
+	InterestPaid  float64 // in the period
+	Payments      int     // in the period
+	Balance       float64 // owed at the end of the period
+	LastPayment   time.Time
+	Payoff        time.Time // zero when it cannot be projected
+}
+
+// LoanSummary reports on LIABILITY accounts, the one named accountName and
+// its descendants or all of them: the principal borrowed and paid between
+// startDate and endDate, the interest paid, and the balance owed at
+// endDate (today when empty). A payment is a transaction reducing the
+// balance; its expense splits are the interest (and fees) it pays. An
+// expense credited to the loan itself is interest added to the balance.
+// The payoff date is projected from the pace at which the latest payments
+// reduced the balance.
+func (s *Service) LoanSummary(ctx context.Context, accountName, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var loans []*Account
+	if accountName != "" {
+		acc, err := s.resolveAccount(ctx, accountName)
+		if err != nil {
+			return "", err
+		}
+		for _, guid := range descendantGUIDs(acc) {
+			if a := accounts[guid]; a != nil && a.AccountType == "LIABILITY" && !a.Placeholder {
+				loans = append(loans, a)
+			}
+		}
+		if len(loans) == 0 {
+			return "", fmt.Errorf("account '%s' is not a LIABILITY account and has none below it", acc.FullName)
+		}
+	} else {
+		for _, a := range accounts {
+			if a.AccountType == "LIABILITY" && !a.Placeholder {
+				loans = append(loans, a)
+			}
+		}
+		if len(loans) == 0 {
+			return "No LIABILITY accounts found.", nil
+		}
+	}
+	slices.SortFunc(loans, func(a, b *Account) int { return strings.Compare(a.FullName, b.FullName) })
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var summaries []loanSummary
+	for _, loan := range loans {
+		splits, err := s.db.getRelatedSplits(ctx, []string{loan.GUID}, endDate, s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		if len(splits) == 0 {
+			continue
+		}
+		summaries = append(summaries, summarizeLoan(loan, splits, accounts, startDate))
+	}
+	if len(summaries) == 0 {
+		return "No loan transactions found.", nil
+	}
+
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	if format != FormatText {
+		t := table{Headers: []string{"account", "borrowed", "principal_paid", "interest_paid", "payments", "balance", "last_payment", "payoff_date"}}
+		for _, l := range summaries {
+			t.add(l.Account.FullName, fmt.Sprintf("%.2f", l.Borrowed), fmt.Sprintf("%.2f", l.PrincipalPaid),
+				fmt.Sprintf("%.2f", l.InterestPaid), fmt.Sprint(l.Payments), fmt.Sprintf("%.2f", l.Balance),
+				date(l.LastPayment), date(l.Payoff))
+		}
+		return t.render(format), nil
+	}
+
+	period := "up to " + endDate
+	if startDate != "" {
+		period = fmt.Sprintf("from %s to %s", startDate, endDate)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Loans (%s), in %s:\n", period, cur.Mnemonic)
+	for _, l := range summaries {
+		fmt.Fprintf(&sb, "\n  %s\n", l.Account.FullName)
+		if l.Borrowed != 0 {
+			fmt.Fprintf(&sb, "    Borrowed:        %12.2f\n", l.Borrowed)
+		}
+		fmt.Fprintf(&sb, "    Principal paid:  %12.2f  (%d payment(s))\n", l.PrincipalPaid, l.Payments)
+		fmt.Fprintf(&sb, "    Interest paid:   %12.2f\n", l.InterestPaid)
+		fmt.Fprintf(&sb, "    Balance owed:    %12.2f  at %s\n", l.Balance, endDate)
+		switch {
+		case l.Balance <= 0.005:
+			sb.WriteString("    Paid off\n")
+		case !l.Payoff.IsZero():
+			fmt.Fprintf(&sb, "    Projected payoff: %s at the pace of the last payments\n", date(l.Payoff))
+		default:
+			sb.WriteString("    Projected payoff: unknown (not enough recent payments)\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// summarizeLoan computes the history of loan from the splits of the
+// transactions touching it.
+func summarizeLoan(loan *Account, splits []relatedSplit, accounts map[string]*Account, startDate string) loanSummary {
+	l := loanSummary{Account: loan}
+	type payment struct {
+		date      time.Time
+		principal float64
+	}
+	var payments []payment
+	for i := 0; i < len(splits); {
+		tx := splits[i].TxGUID
+		date := splits[i].Date
+		var change, expenses float64 // change in the loan's value, negative when owed more
+		for ; i < len(splits) && splits[i].TxGUID == tx; i++ {
+			sp := splits[i]
+			if sp.AccountGUID == loan.GUID {
+				change += sp.Value
+			} else if acc := accounts[sp.AccountGUID]; acc != nil && acc.AccountType == "EXPENSE" {
+				expenses += sp.Value
+			}
+		}
+		l.Balance -= change
+		inPeriod := startDate == "" || date.Format("2006-01-02") >= startDate
+		switch {
+		case change > 0:
+			payments = append(payments, payment{date, change})
+			l.LastPayment = date
+			if inPeriod {
+				l.PrincipalPaid += change
+				l.InterestPaid += expenses
+				l.Payments++
+			}
+		case change < 0 && expenses > 0:
+			if inPeriod {
+				l.InterestPaid += expenses
+			}
+		case change < 0 && inPeriod:
+			l.Borrowed -= change
+		}
+	}
+
+	// Pace of the latest payments, from the first of them to the last.
+	if len(payments) > loanPaymentWindow {
+		payments = payments[len(payments)-loanPaymentWindow:]
+	}
+	if l.Balance > 0.005 && len(payments) >= 2 {
+		first, last := payments[0], payments[len(payments)-1]
+		var paid float64
+		for _, p := range payments[1:] {
+			paid += p.principal
+		}
+		if days := last.date.Sub(first.date).Hours() / 24; paid > 0 && days > 0 {
+			l.Payoff = last.date.AddDate(0, 0, int(math.Ceil(l.Balance/(paid/days))))
+		}
+	}
+	return l
+}
@@ -0,0 +1,212 @@
+package gnucash
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Text reports print amounts as plain decimals followed by the currency
+// code ("-1234.56 EUR") unless a locale is set for the service (WithLocale)
+// or the call (Service.With). With a locale, amounts get its digit
+// grouping and decimal separator and the currency symbol where the locale
+// puts it ("-1 234,56 €" in fr-FR, "-€1,234.56" in en-US). CSV and Markdown
+// output always keeps plain decimals for other programs to read.
+
+// ParseLocale parses a BCP 47 locale name such as fr-FR or en-US.
+func ParseLocale(name string) (language.Tag, error) {
+	tag, err := language.Parse(name)
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid locale '%s' (expected a name like en-US or fr-FR)", name)
+	}
+	return tag, nil
+}
+
+// WithLocale formats the amounts of text reports for tag, for the service or
+// for a single call whatever the service's locale.
+func WithLocale(tag language.Tag) Option {
+	return func(s *Service) {
+		s.locale = &tag
+		s.numbers = numbersOf(tag)
+	}
+}
+
+// localeNumbers is how a locale writes numbers. golang.org/x/text formats
+// float64 values only, which would lose the exactness of Numeric, and does
+// not expose the symbols it uses: they are read from numbers it formats.
+type localeNumbers struct {
+	digits    [10]rune
+	decimal   string
+	group     string // "" for locales that do not group digits
+	primary   int    // digits in the group left of the decimal separator
+	secondary int    // digits in the groups further left
+	minGroup  int    // integer digits below which no separator is written
+	minus     [2]string
+}
+
+// numbersOf reads the numbers of tag.
+func numbersOf(tag language.Tag) *localeNumbers {
+	p := message.NewPrinter(tag)
+	ln := &localeNumbers{}
+
+	// 1234567890.5: the digits, then the runs between them, the last one
+	// being the decimal separator.
+	var digits []rune
+	var runs []int
+	var seps []string
+	sep := ""
+	for _, r := range p.Sprint(number.Decimal(1234567890.5, number.Scale(1))) {
+		if !unicode.IsDigit(r) {
+			sep += string(r)
+			continue
+		}
+		if len(digits) == 0 || sep != "" {
+			runs = append(runs, 0)
+			if len(digits) > 0 {
+				seps = append(seps, sep)
+			}
+			sep = ""
+		}
+		digits = append(digits, r)
+		runs[len(runs)-1]++
+	}
+	for i, r := range digits[:10] {
+		ln.digits[(i+1)%10] = r
+	}
+	ln.decimal = seps[len(seps)-1]
+	groups := runs[:len(runs)-1]
+	if len(groups) > 1 {
+		ln.group = seps[0]
+		ln.primary = groups[len(groups)-1]
+		ln.secondary = groups[len(groups)-2]
+		if len(groups) == 2 {
+			ln.secondary = ln.primary
+		}
+		// Some locales leave 4-digit numbers ungrouped.
+		ln.minGroup = ln.primary + 1
+		for ln.minGroup < 10 && !strings.Contains(p.Sprint(number.Decimal(int64(math.Pow10(ln.minGroup-1)))), ln.group) {
+			ln.minGroup++
+		}
+	}
+
+	negative := p.Sprint(number.Decimal(-5))
+	prefix, suffix, _ := strings.Cut(negative, string(ln.digits[5]))
+	ln.minus = [2]string{prefix, suffix}
+	return ln
+}
+
+// format writes plain, a decimal as Numeric.Format returns it, with the
+// locale's digits, separators and minus sign.
+func (ln *localeNumbers) format(plain string) string {
+	plain, negative := strings.CutPrefix(plain, "-")
+	integer, fraction, _ := strings.Cut(plain, ".")
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteString(ln.minus[0])
+	}
+	size := ln.primary
+	var groups []string
+	if ln.group == "" || len(integer) < ln.minGroup {
+		groups = []string{integer}
+	} else {
+		for len(integer) > size {
+			groups = append(groups, integer[len(integer)-size:])
+			integer = integer[:len(integer)-size]
+			size = ln.secondary
+		}
+		groups = append(groups, integer)
+		slices.Reverse(groups)
+	}
+	for i, group := range groups {
+		if i > 0 {
+			sb.WriteString(ln.group)
+		}
+		ln.writeDigits(&sb, group)
+	}
+	if fraction != "" {
+		sb.WriteString(ln.decimal)
+		ln.writeDigits(&sb, fraction)
+	}
+	if negative {
+		sb.WriteString(ln.minus[1])
+	}
+	return sb.String()
+}
+
+func (ln *localeNumbers) writeDigits(sb *strings.Builder, digits string) {
+	for _, d := range digits {
+		sb.WriteRune(ln.digits[d-'0'])
+	}
+}
+
+// symbolAfter lists the languages whose CLDR currency pattern puts the
+// symbol after the amount, which golang.org/x/text does not expose, with
+// the regions that put it first instead.
+var symbolAfter = map[string][]string{
+	"bg": nil, "ca": nil, "cs": nil, "da": nil, "de": {"AT", "CH", "LI"}, "el": nil,
+	"es": {"MX", "US"}, "et": nil, "fi": nil, "fr": {"CH"}, "hr": nil, "hu": nil,
+	"is": nil, "it": {"CH"}, "lt": nil, "lv": nil, "nb": nil, "nn": nil, "no": nil,
+	"pl": nil, "pt": {"BR"}, "ro": nil, "ru": nil, "sk": nil, "sl": nil, "sr": nil,
+	"sv": nil, "uk": nil, "vi": nil,
+}
+
+func placesSymbolAfter(tag language.Tag) bool {
+	base, _ := tag.Base()
+	exceptions, ok := symbolAfter[base.String()]
+	if !ok {
+		return false
+	}
+	region, confidence := tag.Region()
+	if confidence == language.No {
+		return true
+	}
+	for _, r := range exceptions {
+		if region.String() == r {
+			return false
+		}
+	}
+	return true
+}
+
+// formatAmount formats n to the precision of fraction (see Numeric.Format)
+// for the call's locale.
+func (s *Service) formatAmount(n Numeric, fraction int64) string {
+	if s.locale == nil {
+		return n.Format(fraction)
+	}
+	return s.numbers.format(n.Format(fraction))
+}
+
+// formatMoney formats n in currency c for the call's locale.
+func (s *Service) formatMoney(n Numeric, c Commodity) string {
+	if s.locale == nil {
+		return n.Format(c.Fraction) + " " + c.Mnemonic
+	}
+	tag := *s.locale
+	p := message.NewPrinter(tag)
+	symbol := c.Mnemonic
+	if unit, err := currency.ParseISO(c.Mnemonic); err == nil {
+		symbol = p.Sprint(currency.Symbol(unit))
+	}
+	sign := ""
+	if n.Sign() < 0 {
+		sign, n = "-", n.Neg()
+	}
+	digits := s.numbers.format(n.Format(c.Fraction))
+	if placesSymbolAfter(tag) {
+		return sign + digits + "\u00a0" + symbol
+	}
+	// Letter symbols such as CHF are set apart from the digits.
+	if !strings.ContainsFunc(symbol, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		return sign + symbol + "\u00a0" + digits
+	}
+	return sign + symbol + digits
+}
@@ -0,0 +1,78 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// GnuCash marks a book it has open with a row in the gnclock table of the
+// book (SQL backends) or a <file>.LCK file next to it (XML backend, and
+// older versions). Writing to a book open in GnuCash would be overwritten or
+// corrupt the session, so write mode refuses to.
+
+// bookLock returns who holds the book open, e.g. "laptop (PID 4242)", or ""
+// if nobody does.
+func (d *DB) bookLock(ctx context.Context, dbTx *sql.Tx) (string, error) {
+	var tables int
+	err := dbTx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'gnclock'`).Scan(&tables)
+	if err != nil {
+		return "", fmt.Errorf("query book lock: %w", err)
+	}
+	if tables > 0 {
+		var host string
+		var pid int64
+		err := dbTx.QueryRowContext(ctx, `SELECT COALESCE(Hostname, ''), COALESCE(PID, 0) FROM gnclock LIMIT 1`).Scan(&host, &pid)
+		if err == nil {
+			if host == "" {
+				host = "an unknown host"
+			}
+			return fmt.Sprintf("%s (PID %d)", host, pid), nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("query book lock: %w", err)
+		}
+	}
+	if d.path != "" {
+		if _, err := os.Stat(d.path + ".LCK"); err == nil {
+			return "the lock file " + d.path + ".LCK", nil
+		}
+	}
+	return "", nil
+}
+
+// beginWrite starts a write transaction, refusing if GnuCash has the book
+// open. The lock is checked inside the transaction, so GnuCash cannot open
+// the book between the check and the write.
+func (d *DB) beginWrite(ctx context.Context) (*sql.Tx, error) {
+	if d.rw == nil {
+		return nil, errReadOnly
+	}
+	dbTx, err := d.rw.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin write: %w", err)
+	}
+	holder, err := d.bookLock(ctx, dbTx)
+	if err == nil && holder != "" {
+		err = fmt.Errorf("the book is open in GnuCash (locked by %s): close it there before writing. "+
+			"If GnuCash is not running the lock is stale; opening the book in GnuCash and closing it clears it", holder)
+	}
+	if err != nil {
+		dbTx.Rollback()
+		return nil, err
+	}
+	return dbTx, nil
+}
+
+// commitWrite commits a write transaction started by beginWrite, counting
+// the change in Version so that what was cached from the book is read again
+// even where file times are too coarse to tell.
+func (d *DB) commitWrite(dbTx *sql.Tx) error {
+	if err := dbTx.Commit(); err != nil {
+		return err
+	}
+	d.version.Add(1)
+	return nil
+}
@@ -1,29 +1,28 @@
 package gnucash
 
-import (
-	"fmt"
-	"time"
-)
+import "time"
 
 // Account represents a GnuCash account in the chart of accounts.
 type Account struct {
-	GUID        string
-	Name        string
-	AccountType string
-	ParentGUID  string
-	Description string
-	Hidden      bool
-	Placeholder bool
-	Children    []*Account
-	FullName    string // computed: "Parent:Child:Grandchild"
+	GUID          string
+	Name          string
+	AccountType   string
+	ParentGUID    string
+	Description   string
+	CommodityGUID string // currency or security the account is kept in
+	Hidden        bool
+	Placeholder   bool
+	Children      []*Account
+	FullName      string // computed: "Parent:Child:Grandchild"
 }
 
 // Transaction represents a GnuCash transaction header.
 type Transaction struct {
-	GUID        string
-	PostDate    time.Time
-	Description string
-	Splits      []Split
+	GUID         string
+	PostDate     time.Time
+	Description  string
+	CurrencyGUID string // currency of the split values, when loaded
+	Splits       []Split
 }
 
 // Split represents one leg of a double-entry transaction.
@@ -35,6 +34,10 @@ type Split struct {
 	Memo        string
 	ValueNum    int64
 	ValueDenom  int64
+	// Quantity in the commodity of the account, when loaded; it equals the
+	// value unless the account is not kept in the transaction currency.
+	QuantityNum   int64
+	QuantityDenom int64
 }
 
 // Amount returns the split value as a float64.
@@ -45,28 +48,31 @@ func (s Split) Amount() float64 {
 	return float64(s.ValueNum) / float64(s.ValueDenom)
 }
 
+// Value returns the split value as an exact Numeric.
+func (s Split) Value() Numeric {
+	return NewNumeric(s.ValueNum, s.ValueDenom)
+}
+
+// Quantity returns the split quantity as an exact Numeric.
+func (s Split) Quantity() Numeric {
+	return NewNumeric(s.QuantityNum, s.QuantityDenom)
+}
+
 // FormatAmount returns the split value as a 2-decimal string.
 func (s Split) FormatAmount() string {
 	return FormatDecimal(s.ValueNum, s.ValueDenom)
 }
 
-// FormatDecimal formats a num/denom pair as a 2-decimal-place string.
+// FormatDecimal formats a num/denom pair as a 2-decimal-place string,
+// rounded half away from zero.
 func FormatDecimal(num, denom int64) string {
-	if denom == 0 {
-		return "0.00"
-	}
-	negative := false
-	if num < 0 {
-		negative = true
-		num = -num
-	}
-	whole := num / denom
-	frac := (num % denom) * 100 / denom
-	sign := ""
-	if negative {
-		sign = "-"
-	}
-	return fmt.Sprintf("%s%d.%02d", sign, whole, frac)
+	return FormatFraction(num, denom, 100)
+}
+
+// FormatFraction formats a num/denom pair to the precision of a commodity
+// whose smallest unit is 1/fraction (see Numeric.Format).
+func FormatFraction(num, denom, fraction int64) string {
+	return NewNumeric(num, denom).Format(fraction)
 }
 
 // CategoryTotal holds aggregated spending for one expense category.
@@ -83,3 +89,42 @@ type MonthSummary struct {
 	Expenses string
 	Net      string
 }
+
+// Commodity represents a currency or security from the commodities table.
+type Commodity struct {
+	GUID      string
+	Namespace string // CURRENCY for currencies, exchange or type otherwise
+	Mnemonic  string // ticker symbol or ISO currency code
+	FullName  string
+	CUSIP     string // ISIN, CUSIP or other identifier, when set
+	Fraction  int64  // smallest traded unit, e.g. 100 for cents
+}
+
+// Label returns the commodity as "MNEMONIC (Full Name) [identifier]".
+func (c Commodity) Label() string {
+	label := c.Mnemonic
+	if c.FullName != "" && c.FullName != c.Mnemonic {
+		label += " (" + c.FullName + ")"
+	}
+	if c.CUSIP != "" && c.Namespace != "CURRENCY" {
+		label += " [" + c.CUSIP + "]"
+	}
+	return label
+}
+
+// Price is a quote of a commodity in a currency at a date.
+type Price struct {
+	Commodity  Commodity
+	Currency   string // currency mnemonic
+	Date       time.Time
+	ValueNum   int64
+	ValueDenom int64
+}
+
+// Value returns the price as a float64.
+func (p Price) Value() float64 {
+	if p.ValueDenom == 0 {
+		return 0
+	}
+	return float64(p.ValueNum) / float64(p.ValueDenom)
+}
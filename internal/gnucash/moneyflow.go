@@ -0,0 +1,193 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Diagram styles of MoneyFlowDiagram.
+const (
+	DiagramSankey    = "sankey"
+	DiagramFlowchart = "flowchart"
+)
+
+// flowSplit is the value of a split in a transaction.
+type flowSplit struct {
+	TxGUID      string
+	AccountGUID string
+	Value       float64 // debit positive
+}
+
+// getFlowSplits returns the splits of the transactions posted between
+// startDate and endDate, those of a transaction adjacent.
+func (d *DB) getFlowSplits(ctx context.Context, startDate, endDate string, includeClosing bool) ([]flowSplit, error) {
+	closing, err := d.closingFilter(ctx, includeClosing)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT t.guid, s.account_guid, CAST(s.value_num AS REAL) / s.value_denom
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE t.post_date >= ? AND t.post_date <= ?`+closing+`
+		ORDER BY t.guid
+	`, d.dayStart(startDate), d.dayEnd(endDate))
+	if err != nil {
+		return nil, fmt.Errorf("query flow splits: %w", err)
+	}
+	defer rows.Close()
+	var splits []flowSplit
+	for rows.Next() {
+		var sp flowSplit
+		if err := rows.Scan(&sp.TxGUID, &sp.AccountGUID, &sp.Value); err != nil {
+			return nil, fmt.Errorf("scan flow split: %w", err)
+		}
+		splits = append(splits, sp)
+	}
+	return splits, rows.Err()
+}
+
+// moneyFlow is the money moved from one account group to another.
+type moneyFlow struct {
+	From, To string
+	Amount   float64
+}
+
+// flowGroup returns the name of acc cut to its first depth levels.
+func flowGroup(acc *Account, depth int) string {
+	parts := strings.Split(acc.FullName, ":")
+	return strings.Join(parts[:min(depth, len(parts))], ":")
+}
+
+// sankeyField quotes a node name for the CSV lines of a Mermaid sankey.
+func sankeyField(name string) string {
+	if strings.ContainsAny(name, ",\"") {
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return name
+}
+
+// MoneyFlowDiagram returns a Mermaid diagram, in a Markdown code block, of
+// the money that flowed between accounts from startDate (the start of the
+// month when empty) to endDate (today when empty), accounts grouped to their
+// first depth levels (the top-level accounts for 1). In each transaction
+// the credited accounts are the sources of what the debited ones received,
+// shared in proportion when there are several of each; flows within a group
+// are left out and flows both ways between two groups are netted. Amounts
+// are transaction values, in the book currency. The diagram is a sankey,
+// or a flowchart with the amounts on its arrows for clients whose Mermaid
+// does not draw sankeys.
+func (s *Service) MoneyFlowDiagram(ctx context.Context, startDate, endDate string, depth int, style string) (string, error) {
+	style = cmp.Or(strings.ToLower(style), DiagramSankey)
+	if style != DiagramSankey && style != DiagramFlowchart {
+		return "", fmt.Errorf("unsupported diagram style '%s' (expected %s or %s)", style, DiagramSankey, DiagramFlowchart)
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	now := s.now()
+	if startDate == "" {
+		startDate = now.Format("2006-01") + "-01"
+	}
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	for _, date := range []string{startDate, endDate} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return "", fmt.Errorf("invalid date '%s': %w", date, err)
+		}
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	splits, err := s.db.getFlowSplits(ctx, startDate, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	group := func(guid string) string {
+		if acc := accounts[guid]; acc != nil {
+			return flowGroup(acc, depth)
+		}
+		return guid
+	}
+	amounts := make(map[[2]string]float64)
+	for i := 0; i < len(splits); {
+		j := i
+		var debited float64
+		for ; j < len(splits) && splits[j].TxGUID == splits[i].TxGUID; j++ {
+			if splits[j].Value > 0 {
+				debited += splits[j].Value
+			}
+		}
+		for _, from := range splits[i:j] {
+			if from.Value >= 0 {
+				continue
+			}
+			for _, to := range splits[i:j] {
+				if to.Value > 0 {
+					amounts[[2]string{group(from.AccountGUID), group(to.AccountGUID)}] += -from.Value * to.Value / debited
+				}
+			}
+		}
+		i = j
+	}
+	var flows []moneyFlow
+	netted := make(map[[2]string]bool)
+	for pair := range amounts {
+		from, to := min(pair[0], pair[1]), max(pair[0], pair[1])
+		if from == to || netted[[2]string{from, to}] {
+			continue
+		}
+		netted[[2]string{from, to}] = true
+		net := amounts[[2]string{from, to}] - amounts[[2]string{to, from}]
+		if net < 0 {
+			from, to, net = to, from, -net
+		}
+		if cents(net) != 0 {
+			flows = append(flows, moneyFlow{from, to, cents(net)})
+		}
+	}
+	slices.SortFunc(flows, func(a, b moneyFlow) int {
+		return cmp.Or(cmp.Compare(b.Amount, a.Amount), strings.Compare(a.From, b.From), strings.Compare(a.To, b.To))
+	})
+
+	if len(flows) == 0 {
+		return fmt.Sprintf("No money flowed between accounts from %s to %s.", startDate, endDate), nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Money flow from %s to %s, in %s:\n\n```mermaid\n", startDate, endDate, cur.Mnemonic)
+	if style == DiagramSankey {
+		sb.WriteString("sankey-beta\n\n")
+		for _, f := range flows {
+			fmt.Fprintf(&sb, "%s,%s,%.2f\n", sankeyField(f.From), sankeyField(f.To), f.Amount)
+		}
+	} else {
+		sb.WriteString("flowchart LR\n")
+		ids := make(map[string]string)
+		node := func(name string) string {
+			id, ok := ids[name]
+			if !ok {
+				id = fmt.Sprintf("n%d", len(ids))
+				ids[name] = id
+				fmt.Fprintf(&sb, "    %s[\"%s\"]\n", id, strings.ReplaceAll(name, `"`, "#quot;"))
+			}
+			return id
+		}
+		for _, f := range flows {
+			from, to := node(f.From), node(f.To)
+			fmt.Fprintf(&sb, "    %s -->|%.2f| %s\n", from, f.Amount, to)
+		}
+	}
+	sb.WriteString("```\n")
+	return sb.String(), nil
+}
@@ -0,0 +1,134 @@
+package gnucash
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Numeric is an exact amount: GnuCash stores values and quantities as
+// rationals num/denom, and the denominators of the splits summed by a
+// report need not agree (100 for most currency values, more for prices
+// and quantities, different again after a currency change). Numeric sums
+// them without rounding. The zero value is zero; Numerics are immutable.
+type Numeric struct {
+	rat *big.Rat // nil for zero
+}
+
+// NewNumeric returns num/denom, or zero when denom is zero.
+func NewNumeric(num, denom int64) Numeric {
+	if num == 0 || denom == 0 {
+		return Numeric{}
+	}
+	return Numeric{rat: big.NewRat(num, denom)}
+}
+
+func (n Numeric) value() *big.Rat {
+	if n.rat == nil {
+		return new(big.Rat)
+	}
+	return n.rat
+}
+
+// Add returns n + m.
+func (n Numeric) Add(m Numeric) Numeric {
+	switch {
+	case m.rat == nil:
+		return n
+	case n.rat == nil:
+		return m
+	}
+	return Numeric{rat: new(big.Rat).Add(n.rat, m.rat)}
+}
+
+// Sub returns n - m.
+func (n Numeric) Sub(m Numeric) Numeric {
+	return n.Add(m.Neg())
+}
+
+// Neg returns -n.
+func (n Numeric) Neg() Numeric {
+	if n.rat == nil {
+		return n
+	}
+	return Numeric{rat: new(big.Rat).Neg(n.rat)}
+}
+
+// Mul returns n * m.
+func (n Numeric) Mul(m Numeric) Numeric {
+	if n.rat == nil || m.rat == nil {
+		return Numeric{}
+	}
+	return Numeric{rat: new(big.Rat).Mul(n.rat, m.rat)}
+}
+
+// scale returns n * f, for inexact factors such as price index ratios.
+func (n Numeric) scale(f float64) Numeric {
+	r := new(big.Rat).SetFloat64(f)
+	if n.rat == nil || r == nil {
+		return n
+	}
+	return Numeric{rat: r.Mul(r, n.rat)}
+}
+
+// Inv returns 1/n, or zero when n is zero.
+func (n Numeric) Inv() Numeric {
+	if n.rat == nil {
+		return n
+	}
+	return Numeric{rat: new(big.Rat).Inv(n.rat)}
+}
+
+// Sign returns -1, 0 or +1 as n is negative, zero or positive.
+func (n Numeric) Sign() int {
+	return n.value().Sign()
+}
+
+// Cmp returns -1, 0 or +1 as n is less than, equal to or greater than m.
+func (n Numeric) Cmp(m Numeric) int {
+	return n.value().Cmp(m.value())
+}
+
+// Float64 returns the nearest float64 to n, for computations that do not
+// need to be exact such as report expressions.
+func (n Numeric) Float64() float64 {
+	f, _ := n.value().Float64()
+	return f
+}
+
+// Format formats n to the precision of a commodity whose smallest unit is
+// 1/fraction (100 for cents, 1 for yen), rounded half away from zero:
+// 12.345 is "12.35" with a fraction of 100.
+func (n Numeric) Format(fraction int64) string {
+	digits := fractionDigits(fraction)
+	r := n.value()
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	// Units of 10^-digits, rounded: (2*|num|*scale + denom) / (2*denom).
+	units := new(big.Int).Mul(new(big.Int).Abs(r.Num()), scale)
+	units.Lsh(units, 1).Add(units, r.Denom())
+	units.Quo(units, new(big.Int).Lsh(r.Denom(), 1))
+
+	s := units.String()
+	if digits > 0 {
+		if len(s) <= digits {
+			s = strings.Repeat("0", digits-len(s)+1) + s
+		}
+		s = s[:len(s)-digits] + "." + s[len(s)-digits:]
+	}
+	if r.Sign() < 0 && units.Sign() != 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// String formats n exactly, as an integer or a decimal when its
+// denominator allows, as num/denom otherwise.
+func (n Numeric) String() string {
+	r := n.value()
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	if digits, exact := r.FloatPrec(); exact {
+		return r.FloatString(digits)
+	}
+	return r.String()
+}
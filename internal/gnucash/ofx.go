@@ -0,0 +1,152 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// ofxStatement is what import_ofx uses of an OFX or QFX file.
+type ofxStatement struct {
+	Currency string // CURDEF
+	Rows     []importRow
+	Failures []string
+}
+
+// parseOFX reads the transactions of an OFX file. Both OFX 1.x (SGML, where
+// leaf elements have no closing tag) and 2.x (XML) are accepted, as are
+// Quicken's QFX files, which are OFX with extra elements.
+func parseOFX(content string) (ofxStatement, error) {
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return ofxStatement{}, fmt.Errorf("not an OFX file: no <OFX> element")
+	}
+	var st ofxStatement
+	var tx map[string]string // fields of the current STMTTRN
+	var txLine int
+	inPayee := false
+	finish := func() {
+		if tx != nil {
+			st.addTransaction(txLine, tx)
+			tx = nil
+		}
+	}
+
+	rest := content[start:]
+	for {
+		open := strings.IndexByte(rest, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[open:], '>')
+		if end < 0 {
+			break
+		}
+		line := strings.Count(content[:len(content)-len(rest)+open], "\n") + 1
+		tag := strings.ToUpper(strings.TrimSpace(rest[open+1 : open+end]))
+		rest = rest[open+end+1:]
+		value := rest
+		if next := strings.IndexByte(rest, '<'); next >= 0 {
+			value = rest[:next]
+		}
+		value = html.UnescapeString(strings.TrimSpace(value))
+
+		switch tag {
+		case "STMTTRN":
+			finish()
+			tx, txLine = map[string]string{}, line
+		case "/STMTTRN", "/BANKTRANLIST":
+			finish()
+		case "PAYEE":
+			inPayee = true
+		case "/PAYEE":
+			inPayee = false
+		case "CURDEF":
+			if st.Currency == "" {
+				st.Currency = value
+			}
+		default:
+			if tx == nil || value == "" || strings.HasPrefix(tag, "/") {
+				continue
+			}
+			if inPayee && tag == "NAME" {
+				tag = "PAYEE"
+			}
+			if _, seen := tx[tag]; !seen {
+				tx[tag] = value
+			}
+		}
+	}
+	finish()
+	return st, nil
+}
+
+// addTransaction converts the fields of a STMTTRN into an import row.
+func (st *ofxStatement) addTransaction(line int, tx map[string]string) {
+	posted := tx["DTPOSTED"]
+	if len(posted) < 8 {
+		st.Failures = append(st.Failures, fmt.Sprintf("line %d: missing or invalid DTPOSTED '%s'", line, posted))
+		return
+	}
+	date, err := time.Parse("20060102", posted[:8])
+	if err != nil {
+		st.Failures = append(st.Failures, fmt.Sprintf("line %d: invalid DTPOSTED '%s'", line, posted))
+		return
+	}
+	raw := tx["TRNAMT"]
+	amount, err := parseStatementAmount(raw, strings.Contains(raw, ",") && !strings.Contains(raw, "."))
+	if err != nil || raw == "" {
+		st.Failures = append(st.Failures, fmt.Sprintf("line %d: invalid TRNAMT '%s'", line, raw))
+		return
+	}
+	description := tx["NAME"]
+	if description == "" {
+		description = tx["PAYEE"]
+	}
+	memo := tx["MEMO"]
+	if description == "" {
+		description, memo = memo, ""
+	}
+	if memo == description {
+		memo = ""
+	}
+	st.Rows = append(st.Rows, importRow{
+		Line:        line,
+		Date:        date,
+		Description: description,
+		Memo:        memo,
+		Amount:      amount,
+		OnlineID:    tx["FITID"],
+	})
+}
+
+// ImportOFX imports an OFX or QFX statement downloaded from a bank into
+// account. Transactions whose FITID was already imported, by this server or
+// by GnuCash's own importer, are skipped. All lines go to defaultAccount,
+// else to Imbalance-<currency>, to be categorized afterwards.
+func (s *Service) ImportOFX(ctx context.Context, account, content, defaultAccount string) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	st, err := parseOFX(content)
+	if err != nil {
+		return "", err
+	}
+	if st.Currency != "" {
+		bank, err := s.resolveWriteAccount(ctx, account)
+		if err != nil {
+			return "", err
+		}
+		currency, err := s.transactionCurrency(ctx, []*Account{bank})
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(st.Currency, currency.Mnemonic) {
+			return "", fmt.Errorf("the statement is in %s but %s is kept in %s", st.Currency, bank.FullName, currency.Mnemonic)
+		}
+	}
+	input := map[string]any{"account": account, "default_account": defaultAccount, "transactions": len(st.Rows) + len(st.Failures)}
+	return s.importRows(ctx, "import_ofx", input, account, st.Rows, st.Failures, importOptions{DefaultAccount: defaultAccount})
+}
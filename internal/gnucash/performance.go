@@ -0,0 +1,273 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// relatedSplit is a split of a transaction touching a set of accounts, in
+// one of them or not.
+type relatedSplit struct {
+	TxGUID      string
+	Date        time.Time
+	Description string
+	AccountGUID string
+	Quantity    float64
+	Value       float64
+	LotGUID     string // "" when not in a lot
+}
+
+// getRelatedSplits returns all the splits of the transactions touching
+// accountGUIDs up to endDate, oldest first.
+func (d *DB) getRelatedSplits(ctx context.Context, accountGUIDs []string, endDate string, includeClosing bool) ([]relatedSplit, error) {
+	args := make([]any, 0, len(accountGUIDs)+1)
+	for _, guid := range accountGUIDs {
+		args = append(args, guid)
+	}
+	args = append(args, d.dayEnd(endDate))
+	lot := "''"
+	if ok, err := d.hasColumn(ctx, "splits", "lot_guid"); err != nil {
+		return nil, err
+	} else if ok {
+		lot = "COALESCE(s.lot_guid, '')"
+	}
+	closing, err := d.closingFilter(ctx, includeClosing)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT t.guid, t.post_date, COALESCE(t.description, ''), s.account_guid,
+		       CAST(s.quantity_num AS REAL) / s.quantity_denom,
+		       CAST(s.value_num AS REAL) / s.value_denom, `+lot+`
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE t.guid IN (SELECT tx_guid FROM splits WHERE account_guid IN (`+placeholders(len(accountGUIDs))+`))
+		  AND t.post_date <= ?`+closing+`
+		ORDER BY t.post_date, t.guid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query related splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []relatedSplit
+	for rows.Next() {
+		var sp relatedSplit
+		var dateStr string
+		if err := rows.Scan(&sp.TxGUID, &dateStr, &sp.Description, &sp.AccountGUID, &sp.Quantity, &sp.Value, &sp.LotGUID); err != nil {
+			return nil, fmt.Errorf("scan related split: %w", err)
+		}
+		sp.Date, _ = d.parseDate(dateStr)
+		sp.Description = d.redacted(sp.Description)
+		splits = append(splits, sp)
+	}
+	return splits, rows.Err()
+}
+
+// PortfolioPerformance measures the return of an investment account and its
+// descendants (all STOCK and MUTUAL accounts when accountName is empty)
+// between startDate and endDate, by default over the last year. The
+// holdings are valued with the latest prices of the price table, cash in
+// the book currency at face value. External cash flows are the amounts
+// moved in or out of the accounts from other asset or liability accounts;
+// income and expenses such as dividends and fees are part of the return.
+// The money-weighted return (IRR) is that of these cash flows between the
+// start and end values; the time-weighted return (TWR) chains the returns
+// between flows, leaving out their timing.
+func (s *Service) PortfolioPerformance(ctx context.Context, accountName, startDate, endDate string) (string, error) {
+	now := s.now()
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	if startDate == "" {
+		startDate = now.AddDate(-1, 0, 0).Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid start_date '%s': %w", startDate, err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid end_date '%s': %w", endDate, err)
+	}
+	if end.Before(start) {
+		return "", fmt.Errorf("end_date %s is before start_date %s", endDate, startDate)
+	}
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	label := "Investments (all STOCK and MUTUAL accounts)"
+	var guids []string
+	if accountName != "" {
+		acc, err := s.resolveAccount(ctx, accountName)
+		if err != nil {
+			return "", err
+		}
+		label = acc.FullName
+		guids = descendantGUIDs(acc)
+	} else {
+		for guid, acc := range accounts {
+			if acc.AccountType == "STOCK" || acc.AccountType == "MUTUAL" {
+				guids = append(guids, guid)
+			}
+		}
+		if len(guids) == 0 {
+			return "No investment accounts found.", nil
+		}
+		slices.Sort(guids)
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	commodities, err := s.db.getCommodities(ctx)
+	if err != nil {
+		return "", err
+	}
+	splits, err := s.db.getRelatedSplits(ctx, guids, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+
+	inScope := make(map[string]bool, len(guids))
+	for _, guid := range guids {
+		inScope[guid] = true
+	}
+	quantities := make(map[string]float64)
+	missing := make(map[string]bool)
+	value := func(date time.Time) (float64, error) {
+		var total float64
+		for guid, q := range quantities {
+			if q == 0 {
+				continue
+			}
+			c, ok := commodities[accounts[guid].CommodityGUID]
+			if !ok || c.GUID == cur.GUID {
+				total += q
+				continue
+			}
+			rate, ok, err := s.db.exchangeRate(ctx, c, cur, date.Format("2006-01-02"))
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				missing[c.Mnemonic] = true
+				continue
+			}
+			total += q * rate.Float64()
+		}
+		return total, nil
+	}
+	// flow is what a split brings into the accounts from outside: the
+	// opposite of its value, for asset and liability accounts only.
+	flow := func(sp relatedSplit) float64 {
+		acc, ok := accounts[sp.AccountGUID]
+		if inScope[sp.AccountGUID] || !ok {
+			return 0
+		}
+		switch acc.AccountType {
+		case "INCOME", "EXPENSE", "TRADING":
+			return 0
+		}
+		return -sp.Value
+	}
+
+	i := 0
+	for ; i < len(splits) && splits[i].Date.Before(start); i++ {
+		if inScope[splits[i].AccountGUID] {
+			quantities[splits[i].AccountGUID] += splits[i].Quantity
+		}
+	}
+	startValue, err := value(start.AddDate(0, 0, -1))
+	if err != nil {
+		return "", err
+	}
+
+	flows := []cashFlow{{Date: start, Amount: -startValue}}
+	var contributions, withdrawals float64
+	growth, periods := 1.0, 0
+	previous := startValue
+	for i < len(splits) {
+		day := splits[i].Date
+		var net float64
+		for ; i < len(splits) && splits[i].Date.Equal(day); i++ {
+			sp := splits[i]
+			if inScope[sp.AccountGUID] {
+				quantities[sp.AccountGUID] += sp.Quantity
+			}
+			net += flow(sp)
+		}
+		if math.Abs(net) < 0.005 {
+			continue
+		}
+		v, err := value(day)
+		if err != nil {
+			return "", err
+		}
+		// A flow closes a sub-period: the growth until then excludes it.
+		if previous > 0 {
+			growth *= (v - net) / previous
+			periods++
+		}
+		previous = v
+		flows = append(flows, cashFlow{Date: day, Amount: -net})
+		if net > 0 {
+			contributions += net
+		} else {
+			withdrawals -= net
+		}
+	}
+	endValue, err := value(end)
+	if err != nil {
+		return "", err
+	}
+	if previous > 0 {
+		growth *= endValue / previous
+		periods++
+	}
+	flows = append(flows, cashFlow{Date: end, Amount: endValue})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Performance of %s (%s to %s), in %s:\n\n", label, startDate, endDate, cur.Mnemonic)
+	fmt.Fprintf(&sb, "  Start value:             %12.2f\n", startValue)
+	fmt.Fprintf(&sb, "  Contributions:           %12.2f\n", contributions)
+	fmt.Fprintf(&sb, "  Withdrawals:             %12.2f\n", withdrawals)
+	fmt.Fprintf(&sb, "  End value:               %12.2f\n", endValue)
+	fmt.Fprintf(&sb, "  Gain:                    %12.2f\n\n", endValue-startValue-contributions+withdrawals)
+
+	years := end.Sub(start).Hours() / 24 / 365
+	if periods == 0 {
+		sb.WriteString("  Time-weighted return:    n/a (nothing invested in the period)\n")
+	} else {
+		twr := growth - 1
+		fmt.Fprintf(&sb, "  Time-weighted return:    %+.2f%% (%d sub-period(s))", twr*100, periods)
+		if years >= 1 {
+			fmt.Fprintf(&sb, ", %+.2f%% annualized", (math.Pow(growth, 1/years)-1)*100)
+		}
+		sb.WriteString("\n")
+	}
+	if irr, err := xirr(flows); err != nil {
+		sb.WriteString("  Money-weighted return:   n/a (no investment activity in the period)\n")
+	} else {
+		if math.Abs(irr) < 0.00005 {
+			irr = 0 // not -0.00%
+		}
+		fmt.Fprintf(&sb, "  Money-weighted return:   %+.2f%% annualized (IRR)\n", irr*100)
+	}
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Fprintf(&sb, "\nWarning: no price available for %s on some valuation dates; those holdings are valued at zero.\n", strings.Join(names, ", "))
+	}
+	return sb.String(), nil
+}
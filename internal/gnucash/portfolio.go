@@ -0,0 +1,451 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Holding is the share quantity of one investment account.
+type Holding struct {
+	AccountGUID string
+	Commodity   Commodity
+	Quantity    float64
+}
+
+// GetHoldings returns the share quantity held in each STOCK or MUTUAL account
+// up to the given date. Quantities are summed exactly from the splits' share
+// quantities, stock splits included, whatever their value.
+func (d *DB) GetHoldings(ctx context.Context, endDate string) ([]Holding, error) {
+	query := `
+		SELECT a.guid, c.guid, c.namespace, c.mnemonic, COALESCE(c.fullname, ''),
+		       COALESCE(c.cusip, ''), c.fraction,
+		       COALESCE(SUM(s.quantity_num), 0), COALESCE(s.quantity_denom, 1)
+		FROM accounts a
+		JOIN commodities c ON a.commodity_guid = c.guid
+		LEFT JOIN splits s ON s.account_guid = a.guid
+		LEFT JOIN transactions t ON s.tx_guid = t.guid
+		WHERE a.account_type IN ('STOCK', 'MUTUAL')
+	`
+	var args []any
+	if endDate != "" {
+		query += " AND (t.post_date IS NULL OR t.post_date <= ?)"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += " GROUP BY a.guid, s.quantity_denom ORDER BY c.mnemonic, a.guid"
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var holdings []Holding
+	var quantities []Numeric
+	for rows.Next() {
+		var h Holding
+		var num, denom int64
+		c := &h.Commodity
+		if err := rows.Scan(&h.AccountGUID, &c.GUID, &c.Namespace, &c.Mnemonic, &c.FullName,
+			&c.CUSIP, &c.Fraction, &num, &denom); err != nil {
+			return nil, fmt.Errorf("scan holding: %w", err)
+		}
+		// Rows of an account differ by denominators only.
+		if n := len(holdings); n > 0 && holdings[n-1].AccountGUID == h.AccountGUID {
+			quantities[n-1] = quantities[n-1].Add(NewNumeric(num, denom))
+			continue
+		}
+		holdings = append(holdings, h)
+		quantities = append(quantities, NewNumeric(num, denom))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate holdings: %w", err)
+	}
+	for i, q := range quantities {
+		holdings[i].Quantity = q.Float64()
+	}
+	return holdings, nil
+}
+
+// FindCommodities returns commodities whose mnemonic or identifier equals
+// symbol, case-insensitively.
+func (d *DB) FindCommodities(ctx context.Context, symbol string) ([]Commodity, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT guid, namespace, mnemonic, COALESCE(fullname, ''), COALESCE(cusip, ''), fraction
+		FROM commodities
+		WHERE LOWER(mnemonic) = LOWER(?) OR LOWER(cusip) = LOWER(?)
+		ORDER BY namespace, mnemonic
+	`, symbol, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("query commodities: %w", err)
+	}
+	defer rows.Close()
+
+	var commodities []Commodity
+	for rows.Next() {
+		var c Commodity
+		if err := rows.Scan(&c.GUID, &c.Namespace, &c.Mnemonic, &c.FullName, &c.CUSIP, &c.Fraction); err != nil {
+			return nil, fmt.Errorf("scan commodity: %w", err)
+		}
+		commodities = append(commodities, c)
+	}
+	return commodities, rows.Err()
+}
+
+// GetPrices returns the prices of a commodity within a date range, oldest first.
+func (d *DB) GetPrices(ctx context.Context, commodity Commodity, startDate, endDate string) ([]Price, error) {
+	query := `
+		SELECT p.date, cur.mnemonic, p.value_num, p.value_denom
+		FROM prices p
+		JOIN commodities cur ON p.currency_guid = cur.guid
+		WHERE p.commodity_guid = ?
+	`
+	args := []any{commodity.GUID}
+	if startDate != "" {
+		query += " AND p.date >= ?"
+		args = append(args, d.dayStart(startDate))
+	}
+	if endDate != "" {
+		query += " AND p.date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += " ORDER BY p.date"
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []Price
+	for rows.Next() {
+		p := Price{Commodity: commodity}
+		var dateStr string
+		if err := rows.Scan(&dateStr, &p.Currency, &p.ValueNum, &p.ValueDenom); err != nil {
+			return nil, fmt.Errorf("scan price: %w", err)
+		}
+		p.Date, _ = d.parseDate(dateStr)
+		prices = append(prices, p)
+	}
+	return prices, rows.Err()
+}
+
+// GetLatestPrice returns the most recent price of a commodity on or before
+// endDate (or overall when endDate is empty). ok is false when none exists.
+func (d *DB) GetLatestPrice(ctx context.Context, commodity Commodity, endDate string) (Price, bool, error) {
+	query := `
+		SELECT p.date, cur.mnemonic, p.value_num, p.value_denom
+		FROM prices p
+		JOIN commodities cur ON p.currency_guid = cur.guid
+		WHERE p.commodity_guid = ?
+	`
+	args := []any{commodity.GUID}
+	if endDate != "" {
+		query += " AND p.date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += " ORDER BY p.date DESC LIMIT 1"
+
+	p := Price{Commodity: commodity}
+	var dateStr string
+	err := d.db.QueryRowContext(ctx, query, args...).Scan(&dateStr, &p.Currency, &p.ValueNum, &p.ValueDenom)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Price{}, false, nil
+	}
+	if err != nil {
+		return Price{}, false, fmt.Errorf("query latest price: %w", err)
+	}
+	p.Date, _ = d.parseDate(dateStr)
+	return p, true, nil
+}
+
+// Portfolio lists investment holdings with their commodity identifiers,
+// latest price and market value, optionally filtered by ticker or ISIN/CUSIP.
+func (s *Service) Portfolio(ctx context.Context, symbol, date string) (string, error) {
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	holdings, err := s.db.GetHoldings(ctx, date)
+	if err != nil {
+		return "", err
+	}
+
+	dateLabel := "current"
+	if date != "" {
+		dateLabel = "as of " + date
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Portfolio (%s):\n\n", dateLabel)
+
+	totals := make(map[string]float64)
+	var currencies []string
+	count := 0
+	for _, h := range holdings {
+		if symbol != "" && !strings.EqualFold(h.Commodity.Mnemonic, symbol) && !strings.EqualFold(h.Commodity.CUSIP, symbol) {
+			continue
+		}
+		if h.Quantity == 0 && symbol == "" {
+			continue
+		}
+		count++
+
+		name := h.AccountGUID
+		if acc, ok := accounts[h.AccountGUID]; ok {
+			name = acc.FullName
+		}
+		fmt.Fprintf(&sb, "  %s  %s\n", h.Commodity.Label(), name)
+
+		price, ok, err := s.db.GetLatestPrice(ctx, h.Commodity, date)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			fmt.Fprintf(&sb, "    %.4f shares (no price available)\n", h.Quantity)
+			continue
+		}
+		value := h.Quantity * price.Value()
+		fmt.Fprintf(&sb, "    %.4f shares @ %.2f %s (%s) = %.2f %s\n",
+			h.Quantity, price.Value(), price.Currency, price.Date.Format("2006-01-02"), value, price.Currency)
+		if _, seen := totals[price.Currency]; !seen {
+			currencies = append(currencies, price.Currency)
+		}
+		totals[price.Currency] += value
+	}
+
+	if count == 0 {
+		if symbol != "" {
+			return fmt.Sprintf("No holdings found for symbol '%s'.", symbol), nil
+		}
+		return "No holdings found.", nil
+	}
+	sb.WriteString("\n")
+	for _, cur := range currencies {
+		fmt.Fprintf(&sb, "  TOTAL  %.2f %s\n", totals[cur], cur)
+	}
+	return sb.String(), nil
+}
+
+// PriceHistory lists the recorded prices of a commodity, identified by its
+// ticker or ISIN/CUSIP, within a date range.
+func (s *Service) PriceHistory(ctx context.Context, symbol, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	commodities, err := s.db.FindCommodities(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	switch len(commodities) {
+	case 0:
+		return "", fmt.Errorf("no commodity found matching '%s'", symbol)
+	case 1:
+	default:
+		labels := make([]string, len(commodities))
+		for i, c := range commodities {
+			labels[i] = fmt.Sprintf("  - %s:%s", c.Namespace, c.Label())
+		}
+		return "", fmt.Errorf("multiple commodities match '%s':\n%s", symbol, strings.Join(labels, "\n"))
+	}
+	commodity := commodities[0]
+
+	prices, err := s.db.GetPrices(ctx, commodity, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	if len(prices) == 0 {
+		return fmt.Sprintf("No prices found for %s in the given period.", commodity.Label()), nil
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"date", "price", "currency"}}
+		for _, p := range prices {
+			t.add(p.Date.Format("2006-01-02"), FormatDecimal(p.ValueNum, p.ValueDenom), p.Currency)
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Prices for %s (%d quotes):\n\n", commodity.Label(), len(prices))
+	for _, p := range prices {
+		fmt.Fprintf(&sb, "  %s  %s %s\n", p.Date.Format("2006-01-02"), FormatDecimal(p.ValueNum, p.ValueDenom), p.Currency)
+	}
+	return sb.String(), nil
+}
+
+// InvestmentSplit is one movement of shares in a STOCK or MUTUAL account.
+type InvestmentSplit struct {
+	Date        time.Time
+	TxGUID      string
+	Description string
+	AccountGUID string
+	Commodity   Commodity
+	Quantity    float64 // positive for purchases, negative for sales
+	Value       float64 // in the transaction currency, same sign as Quantity
+	LotGUID     string  // lot the split is assigned to, "" for none
+}
+
+// IsStockSplit reports whether sp changes the number of shares without any
+// money changing hands, which is how GnuCash records a stock split or a
+// reverse split: the shares change, their cost basis does not.
+func (sp InvestmentSplit) IsStockSplit() bool {
+	return sp.Value == 0 && sp.Quantity != 0
+}
+
+// GetInvestmentSplits returns all splits of STOCK and MUTUAL accounts up to
+// endDate (or all of them when endDate is empty), oldest first.
+func (d *DB) GetInvestmentSplits(ctx context.Context, endDate string) ([]InvestmentSplit, error) {
+	lot := "''"
+	if ok, err := d.hasColumn(ctx, "splits", "lot_guid"); err != nil {
+		return nil, err
+	} else if ok {
+		lot = "COALESCE(s.lot_guid, '')"
+	}
+	query := `
+		SELECT t.post_date, t.guid, COALESCE(t.description, ''), a.guid,
+		       c.guid, c.namespace, c.mnemonic, COALESCE(c.fullname, ''), COALESCE(c.cusip, ''), c.fraction,
+		       CAST(s.quantity_num AS REAL) / s.quantity_denom,
+		       CAST(s.value_num AS REAL) / s.value_denom, ` + lot + `
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		JOIN commodities c ON a.commodity_guid = c.guid
+		WHERE a.account_type IN ('STOCK', 'MUTUAL')
+	`
+	var args []any
+	if endDate != "" {
+		query += " AND t.post_date <= ?"
+		args = append(args, d.dayEnd(endDate))
+	}
+	query += " ORDER BY t.post_date, t.guid"
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query investment splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []InvestmentSplit
+	for rows.Next() {
+		var sp InvestmentSplit
+		var dateStr string
+		c := &sp.Commodity
+		if err := rows.Scan(&dateStr, &sp.TxGUID, &sp.Description, &sp.AccountGUID,
+			&c.GUID, &c.Namespace, &c.Mnemonic, &c.FullName, &c.CUSIP, &c.Fraction,
+			&sp.Quantity, &sp.Value, &sp.LotGUID); err != nil {
+			return nil, fmt.Errorf("scan investment split: %w", err)
+		}
+		sp.Date, _ = d.parseDate(dateStr)
+		sp.Description = d.redacted(sp.Description)
+		splits = append(splits, sp)
+	}
+	return splits, rows.Err()
+}
+
+// washSaleWindow is the number of days before and after a loss sale in which
+// a purchase of the same security makes it a wash-sale candidate.
+const washSaleWindow = 30
+
+// WashSales flags sales at a loss within the date range that have purchases of
+// the same commodity, in any account, within 30 days before or after.
+// Losses are computed against the average cost per share across all accounts.
+func (s *Service) WashSales(ctx context.Context, startDate, endDate string) (string, error) {
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Purchases up to 30 days after the range count as well.
+	horizon := ""
+	if endDate != "" {
+		end, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return "", fmt.Errorf("invalid end_date '%s': %w", endDate, err)
+		}
+		horizon = end.AddDate(0, 0, washSaleWindow).Format("2006-01-02")
+	}
+	splits, err := s.db.GetInvestmentSplits(ctx, horizon)
+	if err != nil {
+		return "", err
+	}
+
+	accountName := func(guid string) string {
+		if acc, ok := accounts[guid]; ok {
+			return acc.FullName
+		}
+		return guid
+	}
+
+	type position struct{ quantity, cost float64 }
+	positions := make(map[string]*position)
+
+	var sb strings.Builder
+	found := 0
+	for i, sp := range splits {
+		pos, ok := positions[sp.Commodity.GUID]
+		if !ok {
+			pos = &position{}
+			positions[sp.Commodity.GUID] = pos
+		}
+		if sp.Quantity == 0 {
+			continue // a gain booked on the account, not a trade
+		}
+		if sp.Quantity > 0 || sp.IsStockSplit() {
+			pos.quantity += sp.Quantity
+			pos.cost += sp.Value
+			continue
+		}
+
+		// Sale: relieve average cost.
+		sold := -sp.Quantity
+		basis := 0.0
+		if pos.quantity > 0 {
+			basis = pos.cost / pos.quantity * sold
+		}
+		pos.quantity -= sold
+		pos.cost -= basis
+
+		date := sp.Date.Format("2006-01-02")
+		if (startDate != "" && date < startDate) || (endDate != "" && date > endDate) {
+			continue
+		}
+		loss := basis - (-sp.Value)
+		if loss <= 0.005 {
+			continue
+		}
+
+		var matches []InvestmentSplit
+		for j, other := range splits {
+			if j == i || other.Quantity <= 0 || other.IsStockSplit() || other.Commodity.GUID != sp.Commodity.GUID || other.TxGUID == sp.TxGUID {
+				continue
+			}
+			days := other.Date.Sub(sp.Date).Hours() / 24
+			if days >= -washSaleWindow && days <= washSaleWindow {
+				matches = append(matches, other)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		found++
+		fmt.Fprintf(&sb, "  %s  SELL %.4f %s  loss %.2f  %s  (%s)\n",
+			date, sold, sp.Commodity.Mnemonic, loss, accountName(sp.AccountGUID), sp.Description)
+		for _, m := range matches {
+			days := int(m.Date.Sub(sp.Date).Hours() / 24)
+			fmt.Fprintf(&sb, "    %s  BUY %.4f (%+d days)  %s  (%s)\n",
+				m.Date.Format("2006-01-02"), m.Quantity, days, accountName(m.AccountGUID), m.Description)
+		}
+	}
+
+	if found == 0 {
+		return "No wash-sale candidates found.", nil
+	}
+	header := fmt.Sprintf("Wash-sale candidates (%d sales at a loss with purchases within %d days):\n\n", found, washSaleWindow)
+	footer := "\nLosses use the average cost per share across all accounts. Candidates only; this is not tax advice.\n"
+	return header + sb.String() + footer, nil
+}
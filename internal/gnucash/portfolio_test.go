@@ -0,0 +1,304 @@
+package gnucash
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPortfolio(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.Portfolio(ctx, "", "")
+	if err != nil {
+		t.Fatalf("Portfolio() returned error: %v", err)
+	}
+	// 10 shares at the latest price of 120.00
+	for _, want := range []string{"ACME (ACME Corporation) [US0000000001]", "10.0000 shares @ 120.00 EUR", "= 1200.00 EUR"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Portfolio() missing %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestPortfolio_FilterBySymbol(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Filter by ISIN and value as of January
+	result, err := svc.Portfolio(ctx, "us0000000001", "2025-01-31")
+	if err != nil {
+		t.Fatalf("Portfolio(isin) returned error: %v", err)
+	}
+	if !strings.Contains(result, "= 1000.00 EUR") {
+		t.Errorf("expected January valuation 1000.00, got:\n%s", result)
+	}
+
+	result, err = svc.Portfolio(ctx, "XYZ", "")
+	if err != nil {
+		t.Fatalf("Portfolio(XYZ) returned error: %v", err)
+	}
+	if !strings.Contains(result, "No holdings found for symbol 'XYZ'") {
+		t.Errorf("expected no holdings for XYZ, got:\n%s", result)
+	}
+}
+
+func TestPriceHistory(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.PriceHistory(ctx, "acme", "", "", "")
+	if err != nil {
+		t.Fatalf("PriceHistory() returned error: %v", err)
+	}
+	for _, want := range []string{"ACME (ACME Corporation)", "2025-01-10  100.00 EUR", "2025-02-20  120.00 EUR"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("PriceHistory() missing %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestWashSales(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Sell 5 ACME at 80.00 (average cost 100.00, loss 100.00), then buy 2 back 19 days later
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Sell ACME');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'acme-stock', '', -40000, 100, -500, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'brokerage',  '', 40000, 100, 40000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'eur', '2025-03-20 00:00:00', '2025-03-20 00:00:00', 'Buy ACME again');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'acme-stock', '', 17000, 100, 200, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'brokerage',  '', -17000, 100, -17000, 100);
+	`); err != nil {
+		t.Fatalf("seed trades: %v", err)
+	}
+
+	result, err := svc.WashSales(ctx, "2025-01-01", "2025-03-31")
+	if err != nil {
+		t.Fatalf("WashSales() returned error: %v", err)
+	}
+	for _, want := range []string{"SELL 5.0000 ACME  loss 100.00", "BUY 2.0000 (+19 days)"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("WashSales() missing %q in:\n%s", want, result)
+		}
+	}
+
+	// The original purchase is more than 30 days before the sale: no candidates in February
+	result, err = svc.WashSales(ctx, "2025-02-01", "2025-02-28")
+	if err != nil {
+		t.Fatalf("WashSales() returned error: %v", err)
+	}
+	if !strings.Contains(result, "No wash-sale candidates") {
+		t.Errorf("expected no candidates, got:\n%s", result)
+	}
+}
+
+func TestPriceHistory_Markdown(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.PriceHistory(ctx, "ACME", "", "", "markdown")
+	if err != nil {
+		t.Fatalf("PriceHistory(markdown) returned error: %v", err)
+	}
+	want := "| date       |  price | currency |\n" +
+		"| ---------- | -----: | -------- |\n" +
+		"| 2025-01-10 | 100.00 | EUR      |\n" +
+		"| 2025-02-20 | 120.00 | EUR      |\n"
+	if result != want {
+		t.Errorf("PriceHistory(markdown) =\n%s\nwant:\n%s", result, want)
+	}
+}
+
+func TestPortfolioVsBenchmark(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// 1000.00 invested on Jan 10 grows to 1200.00; the benchmark only rises 10%.
+	csv := "date,close\n2025-01-01,50\n2025-01-10,50\n2025-02-20,55\n"
+	result, err := svc.PortfolioVsBenchmark(ctx, "", csv, "2025-01-01", "2025-02-20")
+	if err != nil {
+		t.Fatalf("PortfolioVsBenchmark() returned error: %v", err)
+	}
+	for _, want := range []string{"Net contributions:            1000.00", "Portfolio end value:          1200.00", "Benchmark end value:          1100.00", "+10.00%"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("PortfolioVsBenchmark() missing %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rate, err := xirr([]cashFlow{
+		{Date: start, Amount: -1000},
+		{Date: start.AddDate(0, 0, 365), Amount: 1100},
+	})
+	if err != nil {
+		t.Fatalf("xirr() returned error: %v", err)
+	}
+	if math.Abs(rate-0.10) > 1e-6 {
+		t.Errorf("xirr() = %f, want 0.10", rate)
+	}
+}
+
+func TestGainsSummary(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// 10 ACME bought at 100.00 and 10 at 140.00, 5 sold at 130.00 and the
+	// 15 left priced 150.00.
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-01 00:00:00', '2025-02-01 00:00:00', 'Buy ACME');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'acme-stock', '', 140000, 100, 1000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'brokerage',  '', -140000, 100, -140000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Sell ACME');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'acme-stock', '', -65000, 100, -500, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'brokerage',  '', 65000, 100, 65000, 100);
+		INSERT INTO prices VALUES ('pr3', 'acme', 'eur', '2025-03-10 00:00:00', 'user:price', 'last', 15000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Average cost: 120.00 a share.
+	result, err := svc.GainsSummary(ctx, "", "", "2025-03-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ACME,50.00,1,15.0000,1800.00,2250.00,450.00,500.00\n"; !strings.HasSuffix(result, want) {
+		t.Errorf("average cost gains = %q, want suffix %q", result, want)
+	}
+
+	// Sold from the lot of the first purchase, with GnuCash's gain split.
+	if _, err := db.db.Exec(`
+		ALTER TABLE splits ADD COLUMN lot_guid TEXT;
+		UPDATE splits SET lot_guid = 'lot1' WHERE guid IN ('sp6a', 'sp8a');
+		INSERT INTO transactions VALUES ('tx9', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Realized Gain/Loss');
+		INSERT INTO splits VALUES ('sp9a', 'tx9', 'acme-stock', '', 15000, 100, 0, 1, 'lot1');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	result, err = svc.GainsSummary(ctx, "ACME", "", "2025-03-31", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Realized:   150.00 (1 sale(s))",
+		"Unrealized: 350.00 (15.0000 shares worth 2250.00 at a cost of 1900.00)",
+		"TOTAL:            500.00 EUR",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	// Sales before the period are not realized in it.
+	result, err = svc.GainsSummary(ctx, "", "2025-03-02", "2025-03-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "ACME,0.00,0,") {
+		t.Errorf("expected no realized gains after the sale:\n%s", result)
+	}
+}
+
+func TestPortfolioPerformance(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// 10 ACME bought at 100.00 on Jan 10, up 20% when 10 more are bought at
+	// 120.00 on Feb 20, then down to 110.00: +10% time-weighted, while the
+	// money invested has broken even.
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-20 00:00:00', '2025-02-20 00:00:00', 'Buy ACME');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'acme-stock', '', 120000, 100, 1000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'brokerage',  '', -120000, 100, -120000, 100);
+		INSERT INTO prices VALUES ('pr3', 'acme', 'eur', '2025-03-31 00:00:00', 'user:price', 'last', 11000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.PortfolioPerformance(ctx, "ACME", "2025-01-01", "2025-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Contributions:                2200.00",
+		"End value:                    2200.00",
+		"Gain:                            0.00",
+		"Time-weighted return:    +10.00% (2 sub-period(s))",
+		"Money-weighted return:   +0.00% annualized",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	// Purchases within the Investments subtree are not cash flows.
+	result, err = svc.PortfolioPerformance(ctx, "Investments", "2025-01-01", "2025-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Contributions:                   0.00") {
+		t.Errorf("expected no contributions:\n%s", result)
+	}
+
+	if _, err := svc.PortfolioPerformance(ctx, "", "2025-03-31", "2025-01-01"); err == nil {
+		t.Error("expected an error for an end date before the start date")
+	}
+}
+
+func TestStockSplit(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// A 2-for-1 split of the 10 ACME shares on Feb 1, recorded without
+	// value, then 5 of the 20 shares sold at 40.00 (cost 50.00 each).
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-01 00:00:00', '2025-02-01 00:00:00', 'Stock split');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'acme-stock', '', 0, 100, 1000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Sell ACME');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'acme-stock', '', -20000, 100, -500, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'brokerage',  '', 20000, 100, 20000, 100);
+		INSERT INTO prices VALUES ('pr3', 'acme', 'eur', '2025-03-10 00:00:00', 'user:price', 'last', 6500, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.Portfolio(ctx, "ACME", "2025-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "15.0000 shares @ 65.00 EUR") {
+		t.Errorf("expected 15 shares after the split and the sale:\n%s", result)
+	}
+
+	result, err = svc.GainsSummary(ctx, "", "", "2025-03-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ACME,-50.00,1,15.0000,750.00,975.00,225.00,175.00\n"; !strings.HasSuffix(result, want) {
+		t.Errorf("gains = %q, want suffix %q", result, want)
+	}
+
+	// The split is not a purchase of the shares sold at a loss.
+	result, err = svc.WashSales(ctx, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "No wash-sale candidates found." {
+		t.Errorf("expected no wash sale:\n%s", result)
+	}
+}
@@ -0,0 +1,303 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// QIF account types import_qif accepts: registers of a single account.
+var qifRegisterTypes = []string{"bank", "cash", "ccard", "oth a", "oth l"}
+
+// parseQIF reads the transactions of a QIF file. Dates are read with the
+// DD/MM/YYYY-style dateFormat, MM/DD/YYYY by default as in Quicken; the
+// apostrophe some exports put before the year is accepted. Categories are
+// kept as written, with transfers in brackets.
+func parseQIF(content, dateFormat string) ([]importRow, []string, error) {
+	if dateFormat == "" {
+		dateFormat = "MM/DD/YYYY"
+	}
+	layouts := []string{dateLayout(dateFormat), dateLayout(strings.Replace(dateFormat, "YYYY", "YY", 1))}
+
+	var rows []importRow
+	var failures []string
+	var row importRow
+	var split *importSplit
+	var account string
+	inRegister, started := false, false
+	for i, raw := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if line[0] == '!' {
+			header := strings.ToLower(line)
+			switch {
+			case strings.HasPrefix(header, "!type:"):
+				kind := strings.TrimSpace(strings.TrimPrefix(header, "!type:"))
+				inRegister = slices.Contains(qifRegisterTypes, kind)
+				if kind == "invst" {
+					return nil, nil, fmt.Errorf("investment QIF files are not supported")
+				}
+				if inRegister && started && account != "" {
+					return nil, nil, fmt.Errorf("the file holds several accounts; export them one at a time")
+				}
+				started = started || inRegister
+			case header == "!account":
+				inRegister = false
+			}
+			continue
+		}
+		code, value := line[0], strings.TrimSpace(line[1:])
+		if !inRegister {
+			if code == 'N' {
+				account = value // !Account block preceding a register
+			}
+			continue
+		}
+		if row.Line == 0 {
+			row.Line = i + 1
+		}
+		switch code {
+		case 'D':
+			value = strings.ReplaceAll(strings.ReplaceAll(value, "'", "/"), " ", "")
+			var err error
+			for _, layout := range layouts {
+				if row.Date, err = time.Parse(layout, value); err == nil {
+					break
+				}
+			}
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("line %d: invalid date '%s' (expected %s)", i+1, value, dateFormat))
+				row.Line = -1 // skip the record
+			}
+		case 'T', 'U':
+			amount, err := parseQIFAmount(value)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("line %d: invalid amount '%s'", i+1, value))
+				row.Line = -1
+			}
+			row.Amount = amount
+		case 'P':
+			row.Description = value
+		case 'M':
+			row.Memo = value
+		case 'L':
+			row.Category = qifCategory(value)
+		case 'S':
+			row.Splits = append(row.Splits, importSplit{Category: qifCategory(value)})
+			split = &row.Splits[len(row.Splits)-1]
+		case 'E':
+			if split != nil {
+				split.Memo = value
+			}
+		case '$':
+			if split != nil {
+				amount, err := parseQIFAmount(value)
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("line %d: invalid split amount '%s'", i+1, value))
+					row.Line = -1
+				}
+				split.Amount = amount
+			}
+		case '^':
+			if row.Line > 0 {
+				if row.Description == "" {
+					row.Description, row.Memo = row.Memo, ""
+				}
+				rows = append(rows, row)
+			}
+			row, split = importRow{}, nil
+		}
+	}
+	return rows, failures, nil
+}
+
+// parseQIFAmount parses a QIF amount, which uses a decimal comma in some
+// locales.
+func parseQIFAmount(s string) (float64, error) {
+	return parseStatementAmount(s, strings.Contains(s, ",") && !strings.Contains(s, "."))
+}
+
+// qifCategory strips the class from a QIF category ("Auto:Fuel/Business").
+func qifCategory(s string) string {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// qifMapping is how a QIF category maps to an account.
+type qifMapping struct {
+	Account *Account // nil when unmatched
+	How     string   // exact, fuzzy, mapping, transfer or a reason it is unmatched
+}
+
+// mapQIFCategory finds the account for a QIF category. Transfers ("[Savings]")
+// match any account; other categories match income and expense accounts, by
+// path suffix first, then by fuzzy match on the last segment. outflow picks
+// between an income and an expense account of the same name.
+func mapQIFCategory(category string, accounts map[string]*Account, outflow bool) qifMapping {
+	if strings.HasPrefix(category, "[") && strings.HasSuffix(category, "]") {
+		name := strings.TrimSpace(category[1 : len(category)-1])
+		candidates := map[string]*Account{}
+		for guid, acc := range accounts {
+			if !acc.Placeholder {
+				candidates[guid] = acc
+			}
+		}
+		matches := matchPathSuffix(name, candidates)
+		if len(matches) == 1 {
+			return qifMapping{matches[0], "transfer"}
+		}
+		if len(matches) > 1 {
+			return qifMapping{nil, "ambiguous transfer account"}
+		}
+		return qifMapping{nil, "no such account"}
+	}
+
+	preferred := "INCOME"
+	if outflow {
+		preferred = "EXPENSE"
+	}
+	candidates := map[string]*Account{}
+	for guid, acc := range accounts {
+		if !acc.Placeholder && (acc.AccountType == "EXPENSE" || acc.AccountType == "INCOME") {
+			candidates[guid] = acc
+		}
+	}
+	pick := func(matches []*Account) *Account {
+		if len(matches) == 1 {
+			return matches[0]
+		}
+		var typed []*Account
+		for _, acc := range matches {
+			if acc.AccountType == preferred {
+				typed = append(typed, acc)
+			}
+		}
+		if len(typed) == 1 {
+			return typed[0]
+		}
+		return nil
+	}
+
+	segments := strings.Split(category, ":")
+	last := strings.ToLower(segments[len(segments)-1])
+	var exact []*Account
+	for _, acc := range matchPathSuffix(category, candidates) {
+		if strings.EqualFold(acc.Name, last) {
+			exact = append(exact, acc)
+		}
+	}
+	if acc := pick(exact); acc != nil {
+		return qifMapping{acc, "exact"}
+	}
+	if len(exact) > 1 {
+		return qifMapping{nil, "ambiguous"}
+	}
+
+	matches := fuzzyMatches(last, candidates, max(1, len([]rune(last))/3))
+	var closest []*Account
+	for _, m := range matches {
+		if m.Distance == matches[0].Distance {
+			closest = append(closest, m.Account)
+		}
+	}
+	if acc := pick(closest); acc != nil {
+		return qifMapping{acc, "fuzzy"}
+	}
+	return qifMapping{nil, "no close match"}
+}
+
+// ImportQIF imports a QIF bank, cash or credit card register into account.
+// QIF categories are mapped to income and expense accounts (transfers to
+// any account) by name, then by fuzzy matching; mapping overrides the
+// account of a category. Lines of unmatched categories are booked to
+// defaultAccount, else Imbalance-<currency>. The report lists the mapping
+// so it can be reviewed with a dry run and corrected.
+func (s *Service) ImportQIF(ctx context.Context, account, content, dateFormat string, mapping map[string]string, defaultAccount string) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	rows, failures, err := parseQIF(content, dateFormat)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	mapped := map[string]qifMapping{}
+	var categories []string
+	resolve := func(category string, outflow bool) (string, error) {
+		if category == "" {
+			return "", nil
+		}
+		m, ok := mapped[category]
+		if !ok {
+			if target, ok := mapping[category]; ok {
+				acc, err := s.resolveWriteAccount(ctx, target)
+				if err != nil {
+					return "", fmt.Errorf("mapping of '%s': %w", category, err)
+				}
+				m = qifMapping{acc, "mapping"}
+			} else {
+				m = mapQIFCategory(category, accounts, outflow)
+			}
+			mapped[category] = m
+			categories = append(categories, category)
+		}
+		if m.Account == nil {
+			return "", nil
+		}
+		return m.Account.GUID, nil
+	}
+	for i := range rows {
+		row := &rows[i]
+		if row.Category, err = resolve(row.Category, row.Amount < 0); err != nil {
+			return "", err
+		}
+		for j := range row.Splits {
+			if row.Splits[j].Category, err = resolve(row.Splits[j].Category, row.Amount < 0); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	input := map[string]any{"account": account, "date_format": dateFormat, "mapping": mapping, "default_account": defaultAccount, "transactions": len(rows) + len(failures)}
+	result, err := s.importRows(ctx, "import_qif", input, account, rows, failures, importOptions{DefaultAccount: defaultAccount})
+	if err != nil || len(categories) == 0 {
+		return result, err
+	}
+
+	unmatched := "Imbalance account"
+	if defaultAccount != "" {
+		unmatched = defaultAccount
+	}
+	slices.Sort(categories)
+	width := 0
+	for _, c := range categories {
+		width = max(width, len(c))
+	}
+	var sb strings.Builder
+	sb.WriteString(result)
+	sb.WriteString("\nCategory mapping:\n")
+	for _, c := range categories {
+		m := mapped[c]
+		if m.Account == nil {
+			fmt.Fprintf(&sb, "  %-*s  -> unmatched (%s), booked to the %s\n", width, c, m.How, unmatched)
+			continue
+		}
+		fmt.Fprintf(&sb, "  %-*s  -> %s", width, c, m.Account.FullName)
+		if m.How != "exact" {
+			fmt.Fprintf(&sb, " (%s)", m.How)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nTo change a mapping, run again with mapping, e.g. {\"" + categories[0] + "\": \"Expenses:...\"}; lines already imported are skipped.\n")
+	return sb.String(), nil
+}
@@ -0,0 +1,198 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The connections of a DB go through a thin wrapper of the SQLite driver
+// that logs every statement, with its duration and the number of rows read
+// or changed, to the DB's logger (see SetLogger). Statements are logged at
+// debug level, failed ones at warning level. The wrapper does nothing more
+// while no logger is set.
+
+// sqliteDriver is the driver registered by modernc.org/sqlite.
+var sqliteDriver = func() driver.Driver {
+	db, _ := sql.Open("sqlite", "")
+	defer db.Close()
+	return db.Driver()
+}()
+
+// queryLog holds the logger of a DB's connections, and their generation:
+// connections opened before the current one are closed rather than reused
+// (see DB.Refresh).
+type queryLog struct {
+	logger     atomic.Pointer[slog.Logger]
+	generation atomic.Uint64
+}
+
+// SetLogger logs the statements run on the book to logger, or stops
+// logging them if logger is nil.
+func (d *DB) SetLogger(logger *slog.Logger) {
+	d.queries.logger.Store(logger)
+}
+
+// openLogged opens a connection pool whose connections open the SQLite
+// database dsn returns at the time, and log their statements to ql.
+func openLogged(dsn func() string, ql *queryLog) *sql.DB {
+	return sql.OpenDB(loggedConnector{dsn: dsn, log: ql})
+}
+
+func (ql *queryLog) record(ctx context.Context, query string, args []driver.NamedValue, start time.Time, rows int64, err error) {
+	logger := ql.logger.Load()
+	if logger == nil {
+		return
+	}
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("sql", strings.Join(strings.Fields(query), " ")),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("rows", rows),
+	}
+	if len(args) > 0 {
+		values := make([]any, len(args))
+		for i, arg := range args {
+			values[i] = arg.Value
+		}
+		attrs = append(attrs, slog.Any("args", values))
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "query", attrs...)
+}
+
+type loggedConnector struct {
+	dsn func() string
+	log *queryLog
+}
+
+func (c loggedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := sqliteDriver.Open(c.dsn())
+	if err != nil {
+		return nil, err
+	}
+	return &loggedConn{Conn: conn, log: c.log, generation: c.log.generation.Load()}, nil
+}
+
+func (c loggedConnector) Driver() driver.Driver {
+	return sqliteDriver
+}
+
+// loggedConn forwards to a SQLite connection, logging statements.
+type loggedConn struct {
+	driver.Conn
+	log        *queryLog
+	generation uint64
+}
+
+func (c *loggedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		c.log.record(ctx, query, args, start, 0, err)
+		return nil, err
+	}
+	return &loggedRows{Rows: rows, ctx: ctx, query: query, args: args, start: start, log: c.log}, nil
+}
+
+func (c *loggedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	var n int64
+	if err == nil {
+		n, _ = result.RowsAffected()
+	}
+	c.log.record(ctx, query, args, start, n, err)
+	return result, err
+}
+
+func (c *loggedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedStmt{Stmt: stmt, query: query, log: c.log}, nil
+}
+
+func (c *loggedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *loggedConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *loggedConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *loggedConn) IsValid() bool {
+	return c.generation == c.log.generation.Load() && c.Conn.(driver.Validator).IsValid()
+}
+
+// loggedStmt forwards to a prepared SQLite statement, logging its runs.
+type loggedStmt struct {
+	driver.Stmt
+	query string
+	log   *queryLog
+}
+
+func (s *loggedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		s.log.record(ctx, s.query, args, start, 0, err)
+		return nil, err
+	}
+	return &loggedRows{Rows: rows, ctx: ctx, query: s.query, args: args, start: start, log: s.log}, nil
+}
+
+func (s *loggedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+	var n int64
+	if err == nil {
+		n, _ = result.RowsAffected()
+	}
+	s.log.record(ctx, s.query, args, start, n, err)
+	return result, err
+}
+
+// loggedRows counts the rows read and logs the query when they are closed,
+// so that the duration includes reading them.
+type loggedRows struct {
+	driver.Rows
+	ctx   context.Context
+	query string
+	args  []driver.NamedValue
+	start time.Time
+	log   *queryLog
+	n     int64
+	err   error
+}
+
+func (r *loggedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.n++
+	case !errors.Is(err, io.EOF):
+		r.err = err
+	}
+	return err
+}
+
+func (r *loggedRows) Close() error {
+	err := r.Rows.Close()
+	r.log.record(r.ctx, r.query, r.args, r.start, r.n, r.err)
+	return err
+}
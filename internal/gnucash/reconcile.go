@@ -0,0 +1,231 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Reconcile states of a split, as stored by GnuCash.
+const (
+	stateNew        = "n"
+	stateCleared    = "c"
+	stateReconciled = "y"
+	stateVoided     = "v"
+)
+
+// reconcileSplit is a split with its reconcile state.
+type reconcileSplit struct {
+	Split
+	PostDate      time.Time
+	Description   string
+	State         string
+	ReconcileDate sql.NullString
+}
+
+// getReconcileSplits returns the splits with the given GUIDs by date of
+// their transaction. Unknown GUIDs are left out.
+func (d *DB) getReconcileSplits(ctx context.Context, guids []string) ([]reconcileSplit, error) {
+	list, _ := json.Marshal(guids) // a string slice always marshals
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT s.guid, s.tx_guid, s.account_guid, s.value_num, s.value_denom,
+		       t.post_date, COALESCE(t.description, ''), s.reconcile_state, s.reconcile_date
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		WHERE s.guid IN (SELECT value FROM json_each(?))
+		ORDER BY t.post_date, s.guid
+	`, string(list))
+	if err != nil {
+		return nil, fmt.Errorf("query splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []reconcileSplit
+	for rows.Next() {
+		var sp reconcileSplit
+		var postDate string
+		if err := rows.Scan(&sp.GUID, &sp.TxGUID, &sp.AccountGUID, &sp.ValueNum, &sp.ValueDenom,
+			&postDate, &sp.Description, &sp.State, &sp.ReconcileDate); err != nil {
+			return nil, fmt.Errorf("scan split: %w", err)
+		}
+		sp.PostDate, _ = d.parseDate(postDate)
+		sp.Description = d.redacted(sp.Description)
+		splits = append(splits, sp)
+	}
+	return splits, rows.Err()
+}
+
+// setReconcileState gives the splits the reconcile state and, when date is
+// valid, the reconcile date. A split whose state changed since it was read
+// fails the whole update. It returns the statements that undo it.
+func (d *DB) setReconcileState(ctx context.Context, splits []reconcileSplit, state string, date sql.NullString) ([]sqlStmt, error) {
+	dbTx, err := d.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbTx.Rollback()
+
+	var undo []sqlStmt
+	for _, sp := range splits {
+		newDate := sp.ReconcileDate
+		if date.Valid {
+			newDate = date
+		}
+		res, err := dbTx.ExecContext(ctx, `
+			UPDATE splits SET reconcile_state = ?, reconcile_date = ? WHERE guid = ? AND reconcile_state = ?
+		`, state, newDate, sp.GUID, sp.State)
+		if err != nil {
+			return nil, fmt.Errorf("reconcile split: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return nil, fmt.Errorf("split %s was changed meanwhile; nothing was reconciled", sp.GUID)
+		}
+		undo = append(undo, sqlStmt{
+			`UPDATE splits SET reconcile_state = ?, reconcile_date = ? WHERE guid = ? AND reconcile_state = ?`,
+			[]any{sp.State, sp.ReconcileDate, sp.GUID, state},
+		})
+	}
+	if err := d.commitWrite(dbTx); err != nil {
+		return nil, fmt.Errorf("commit reconciliation: %w", err)
+	}
+	return undo, nil
+}
+
+// Reconcile states accepted by ReconcileSplits.
+const (
+	ReconcileReconciled = "reconciled"
+	ReconcileCleared    = "cleared"
+)
+
+// ReconcileSplits marks splits as reconciled against a statement dated date
+// (default today), or only as cleared. It is meant to run once the splits
+// were matched against the statement and its balance checked.
+func (s *Service) ReconcileSplits(ctx context.Context, splitGUIDs []string, date, state string) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	if state == "" {
+		state = ReconcileReconciled
+	}
+	if state != ReconcileReconciled && state != ReconcileCleared {
+		return "", fmt.Errorf("unsupported state '%s' (expected %s or %s)", state, ReconcileReconciled, ReconcileCleared)
+	}
+	statementDate := s.now()
+	if date != "" {
+		var err error
+		if statementDate, err = time.Parse("2006-01-02", date); err != nil {
+			return "", fmt.Errorf("invalid date '%s' (expected YYYY-MM-DD)", date)
+		}
+	}
+	var guids []string
+	for _, guid := range splitGUIDs {
+		if guid = strings.TrimSpace(guid); guid != "" && !slices.Contains(guids, guid) {
+			guids = append(guids, guid)
+		}
+	}
+	if len(guids) == 0 {
+		return "", fmt.Errorf("at least one split GUID is required")
+	}
+
+	splits, err := s.db.getReconcileSplits(ctx, guids)
+	if err != nil {
+		return "", err
+	}
+	if len(splits) < len(guids) {
+		var missing []string
+		for _, guid := range guids {
+			if !slices.ContainsFunc(splits, func(sp reconcileSplit) bool { return sp.GUID == guid }) {
+				missing = append(missing, guid)
+			}
+		}
+		return "", fmt.Errorf("no split found with GUID %s", strings.Join(missing, ", "))
+	}
+
+	target, verb := stateReconciled, "reconciled"
+	if state == ReconcileCleared {
+		target, verb = stateCleared, "cleared"
+	}
+	var pending []reconcileSplit
+	var already int
+	for _, sp := range splits {
+		switch {
+		case sp.State == stateVoided:
+			return "", fmt.Errorf("split %s belongs to a voided transaction and cannot be %s", sp.GUID, verb)
+		case sp.State == stateReconciled && target == stateCleared:
+			return "", fmt.Errorf("split %s is already reconciled", sp.GUID)
+		case sp.State == target:
+			already++
+		default:
+			pending = append(pending, sp)
+		}
+	}
+	if len(pending) == 0 {
+		return "", fmt.Errorf("all %d split(s) are already %s", already, verb)
+	}
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	change := fmt.Sprintf("clear %d split(s)", len(pending))
+	done := fmt.Sprintf("Cleared %d split(s)", len(pending))
+	if target == stateReconciled {
+		change = fmt.Sprintf("reconcile %d split(s) as of %s", len(pending), statementDate.Format("2006-01-02"))
+		done = fmt.Sprintf("Reconciled %d split(s) as of %s", len(pending), statementDate.Format("2006-01-02"))
+	}
+	sb.WriteString(s.changeHeading(done, change) + ":\n\n")
+	totals := make(map[string]Numeric)
+	for _, sp := range pending {
+		name := sp.AccountGUID
+		if acc, ok := accounts[sp.AccountGUID]; ok {
+			name = acc.FullName
+		}
+		fmt.Fprintf(&sb, "  %s  %s: %s %s  %s\n", sp.PostDate.Format("2006-01-02"), name,
+			sp.Value().Format(cur.Fraction), cur.Mnemonic, sp.Description)
+		totals[name] = totals[name].Add(sp.Value())
+	}
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	sb.WriteString("\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "Total %s: %s %s\n", name, totals[name].Format(cur.Fraction), cur.Mnemonic)
+	}
+	if already > 0 {
+		fmt.Fprintf(&sb, "\n%d split(s) were already %s and left unchanged.\n", already, verb)
+	}
+	if s.dryRun {
+		return sb.String(), nil
+	}
+
+	// GnuCash keeps the reconcile date only for reconciled splits.
+	var reconcileDate sql.NullString
+	if target == stateReconciled {
+		reconcileDate = sql.NullString{String: s.db.timestamp(neutralTime(statementDate)), Valid: true}
+	}
+	undo, err := s.db.setReconcileState(ctx, pending, target, reconcileDate)
+	if err != nil {
+		return "", err
+	}
+	changed := make([]string, len(pending))
+	for i, sp := range pending {
+		changed[i] = sp.GUID
+	}
+	s.journal(change, undo, changed...)
+	input := map[string]any{"split_guids": splitGUIDs, "date": date, "state": state}
+	if err := s.audit("reconcile_splits", input, changed...); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,23 @@
+package gnucash
+
+// redactedText stands for a description, memo or note of a book read with
+// EnableRedaction.
+const redactedText = "[redacted]"
+
+// EnableRedaction hides the descriptions, split memos and notes of
+// transactions in everything read from the book for display, reports and
+// exports, leaving dates, amounts and accounts. Searching, sorting,
+// category suggestions and duplicate detection on import still compare the
+// stored text, and writes and undo keep it.
+func (d *DB) EnableRedaction() {
+	d.redact = true
+}
+
+// redacted returns text as read from the book: redactedText in place of
+// text that is not empty once redaction is enabled.
+func (d *DB) redacted(text string) string {
+	if !d.redact || text == "" {
+		return text
+	}
+	return redactedText
+}
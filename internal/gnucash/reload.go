@@ -0,0 +1,100 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// bookStamp is the state of the book file last seen by Refresh.
+type bookStamp struct {
+	mu   sync.Mutex
+	info os.FileInfo // nil until the file was first seen
+}
+
+// Refresh reports whether the book file changed since it was opened or last
+// refreshed, as when GnuCash saves it. GnuCash writes SQLite books in place,
+// which open connections read at once; when the file was replaced instead,
+// e.g. by Save As or a sync tool, the connections to the old file are
+// retired so that queries reopen it. With snapshot reads, each change also
+// takes a new snapshot (see EnableSnapshotReads), or keeps reading the last
+// one if that fails. Each change bumps Version.
+func (d *DB) Refresh() bool {
+	if d.path == "" {
+		return false
+	}
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return false // missing while being replaced; seen on the next call
+	}
+	d.stamp.mu.Lock()
+	defer d.stamp.mu.Unlock()
+	old := d.stamp.info
+	d.stamp.info = info
+	if old == nil || (os.SameFile(old, info) && old.Size() == info.Size() && old.ModTime().Equal(info.ModTime())) {
+		return false
+	}
+	if !os.SameFile(old, info) {
+		d.retireConnections()
+	}
+	if d.snapshot.dir != "" {
+		d.syncSnapshot(context.Background())
+	}
+	d.version.Add(1)
+	return true
+}
+
+// retireConnections makes the read connections reopen the file they read
+// before their next query.
+func (d *DB) retireConnections() {
+	d.queries.generation.Add(1)
+	// Close the idle connections now; those in use are closed when released.
+	d.db.SetMaxIdleConns(0)
+	d.db.SetMaxIdleConns(readIdleConns)
+}
+
+// Version counts the changes of the book file seen by Refresh or made in
+// write mode, for caches of what was read from it.
+func (d *DB) Version() uint64 {
+	return d.version.Load()
+}
+
+// FileStamp identifies the state of the book file, for caches of what was
+// read from it: it changes with every save, even before Refresh sees it. It
+// is "" for databases not backed by a file.
+func (d *DB) FileStamp() string {
+	if d.path == "" {
+		return ""
+	}
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d/%d", info.ModTime().UnixNano(), info.Size(), d.Version())
+}
+
+// FileStamp identifies the state of the book file (see DB.FileStamp).
+func (s *Service) FileStamp() string {
+	return s.db.FileStamp()
+}
+
+// Refresh checks whether GnuCash saved the book since the last check (see
+// DB.Refresh) and, if so, records the chart of accounts when a snapshot
+// store is configured, so that renames made in GnuCash show up in the chart
+// history without waiting for the next call to chart_history. It also syncs
+// the aggregate cache, when there is one and it is behind the book, so that
+// reports do not have to.
+func (s *Service) Refresh(ctx context.Context) (bool, error) {
+	changed := s.db.Refresh()
+	if changed {
+		if err := s.RecordChartSnapshot(ctx); err != nil {
+			return true, err
+		}
+	}
+	if !changed && (s.aggregates == nil || s.aggregates.current(s.db)) {
+		return false, nil
+	}
+	// Also retries a sync that failed, or that a write overtook.
+	return changed, s.SyncAggregates(ctx)
+}
@@ -0,0 +1,237 @@
+package gnucash
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cashFlow is an amount moving in (negative) or out (positive) of an
+// investment from the investor's point of view.
+type cashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// xirr returns the annualized internal rate of return of irregular cash flows.
+func xirr(flows []cashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, errors.New("at least two cash flows are required")
+	}
+	t0 := flows[0].Date
+	npv := func(rate float64) float64 {
+		var total float64
+		for _, f := range flows {
+			years := f.Date.Sub(t0).Hours() / 24 / 365
+			total += f.Amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	lo, hi := -0.9999, 1.0
+	for npv(hi) > 0 && hi < 1e6 {
+		hi *= 2
+	}
+	if npv(lo)*npv(hi) > 0 {
+		return 0, errors.New("return cannot be determined from these cash flows")
+	}
+	for range 200 {
+		mid := (lo + hi) / 2
+		if npv(mid) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, nil
+}
+
+// pricePoint is one observation of a price series.
+type pricePoint struct {
+	Date  time.Time
+	Value float64
+}
+
+// priceAt returns the latest price on or before date from a series sorted by date.
+func priceAt(series []pricePoint, date time.Time) (float64, bool) {
+	i := sort.Search(len(series), func(i int) bool { return series[i].Date.After(date) })
+	if i == 0 {
+		return 0, false
+	}
+	return series[i-1].Value, true
+}
+
+// parsePriceCSV parses "YYYY-MM-DD,price" lines. A header line is skipped.
+func parsePriceCSV(content string) ([]pricePoint, error) {
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse benchmark CSV: %w", err)
+	}
+	var series []pricePoint
+	for i, rec := range records {
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("benchmark CSV line %d: expected date,price", i+1)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(rec[0]))
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("benchmark CSV line %d: invalid date '%s'", i+1, rec[0])
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("benchmark CSV line %d: invalid price '%s'", i+1, rec[1])
+		}
+		series = append(series, pricePoint{Date: date, Value: value})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Date.Before(series[j].Date) })
+	return series, nil
+}
+
+// portfolioValue values all STOCK and MUTUAL holdings at date using the latest
+// known prices. Holdings without a price are counted in missing.
+func (s *Service) portfolioValue(ctx context.Context, date string) (value float64, missing []string, err error) {
+	holdings, err := s.db.GetHoldings(ctx, date)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, h := range holdings {
+		if h.Quantity == 0 {
+			continue
+		}
+		price, ok, err := s.db.GetLatestPrice(ctx, h.Commodity, date)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !ok {
+			missing = append(missing, h.Commodity.Mnemonic)
+			continue
+		}
+		value += h.Quantity * price.Value()
+	}
+	return value, missing, nil
+}
+
+// PortfolioVsBenchmark compares the money-weighted return of the book's
+// investment holdings over a period with a benchmark, given either as a
+// commodity symbol from the book or as CSV price data. The benchmark is also
+// evaluated as if every purchase and sale had been made in it instead.
+func (s *Service) PortfolioVsBenchmark(ctx context.Context, benchmark, benchmarkCSV, startDate, endDate string) (string, error) {
+	now := s.now()
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	if startDate == "" {
+		startDate = now.AddDate(-1, 0, 0).Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid start_date '%s': %w", startDate, err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid end_date '%s': %w", endDate, err)
+	}
+
+	// Benchmark series
+	var series []pricePoint
+	label := "CSV benchmark"
+	switch {
+	case benchmarkCSV != "":
+		if series, err = parsePriceCSV(benchmarkCSV); err != nil {
+			return "", err
+		}
+	case benchmark != "":
+		commodities, err := s.db.FindCommodities(ctx, benchmark)
+		if err != nil {
+			return "", err
+		}
+		if len(commodities) != 1 {
+			return "", fmt.Errorf("benchmark '%s' must match exactly one commodity, found %d", benchmark, len(commodities))
+		}
+		label = commodities[0].Label()
+		prices, err := s.db.GetPrices(ctx, commodities[0], "", endDate)
+		if err != nil {
+			return "", err
+		}
+		for _, p := range prices {
+			series = append(series, pricePoint{Date: p.Date, Value: p.Value()})
+		}
+	default:
+		return "", errors.New("either benchmark or benchmark_csv is required")
+	}
+	benchStart, ok1 := priceAt(series, start)
+	benchEnd, ok2 := priceAt(series, end)
+	if !ok1 || !ok2 || benchStart == 0 {
+		return "", fmt.Errorf("benchmark has no price on or before %s", startDate)
+	}
+
+	// Portfolio cash flows: starting value, purchases and sales, ending value.
+	startValue, missingStart, err := s.portfolioValue(ctx, start.AddDate(0, 0, -1).Format("2006-01-02"))
+	if err != nil {
+		return "", err
+	}
+	endValue, missingEnd, err := s.portfolioValue(ctx, endDate)
+	if err != nil {
+		return "", err
+	}
+	splits, err := s.db.GetInvestmentSplits(ctx, endDate)
+	if err != nil {
+		return "", err
+	}
+
+	flows := []cashFlow{{Date: start, Amount: -startValue}}
+	benchUnits := startValue / benchStart
+	var contributions float64
+	for _, sp := range splits {
+		if sp.Date.Before(start) || sp.Value == 0 {
+			continue
+		}
+		flows = append(flows, cashFlow{Date: sp.Date, Amount: -sp.Value})
+		contributions += sp.Value
+		if p, ok := priceAt(series, sp.Date); ok && p != 0 {
+			benchUnits += sp.Value / p
+		}
+	}
+	benchEndValue := benchUnits * benchEnd
+
+	portfolioFlows := append(flows, cashFlow{Date: end, Amount: endValue})
+	benchFlows := append(flows[:len(flows):len(flows)], cashFlow{Date: end, Amount: benchEndValue})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Portfolio vs %s (%s to %s):\n\n", label, startDate, endDate)
+	fmt.Fprintf(&sb, "  Portfolio start value:   %12.2f\n", startValue)
+	fmt.Fprintf(&sb, "  Net contributions:       %12.2f\n", contributions)
+	fmt.Fprintf(&sb, "  Portfolio end value:     %12.2f\n", endValue)
+	fmt.Fprintf(&sb, "  Benchmark end value:     %12.2f  (same cash flows invested in the benchmark)\n\n", benchEndValue)
+
+	years := end.Sub(start).Hours() / 24 / 365
+	benchReturn := benchEnd/benchStart - 1
+	fmt.Fprintf(&sb, "  Benchmark price return:  %+.2f%% (%.2f -> %.2f)", benchReturn*100, benchStart, benchEnd)
+	if years > 0 {
+		fmt.Fprintf(&sb, ", %+.2f%% annualized", (math.Pow(1+benchReturn, 1/years)-1)*100)
+	}
+	sb.WriteString("\n")
+
+	portfolioIRR, errP := xirr(portfolioFlows)
+	benchIRR, errB := xirr(benchFlows)
+	if errP != nil || errB != nil {
+		sb.WriteString("  Money-weighted returns: n/a (no investment activity in the period)\n")
+	} else {
+		fmt.Fprintf(&sb, "  Portfolio MWR:           %+.2f%% annualized\n", portfolioIRR*100)
+		fmt.Fprintf(&sb, "  Benchmark MWR:           %+.2f%% annualized\n", benchIRR*100)
+		fmt.Fprintf(&sb, "  Difference:              %+.2f percentage points\n", (portfolioIRR-benchIRR)*100)
+	}
+
+	if missing := append(missingStart, missingEnd...); len(missing) > 0 {
+		fmt.Fprintf(&sb, "\nWarning: no price available for %s; those holdings are valued at zero.\n", strings.Join(missing, ", "))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,183 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// searchSignature summarizes the searchable text of the book cheaply, so a
+// stale search index can be detected without comparing documents.
+func (d *DB) searchSignature(ctx context.Context) (string, error) {
+	var txCount, descLen, splitCount, memoLen int64
+	var lastEntered string
+	err := d.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(MAX(enter_date), ''), COALESCE(SUM(LENGTH(description)), 0)
+		FROM transactions
+	`).Scan(&txCount, &lastEntered, &descLen)
+	if err != nil {
+		return "", fmt.Errorf("query search signature: %w", err)
+	}
+	err = d.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(LENGTH(memo)), 0) FROM splits
+	`).Scan(&splitCount, &memoLen)
+	if err != nil {
+		return "", fmt.Errorf("query search signature: %w", err)
+	}
+	return fmt.Sprintf("%d/%s/%d/%d/%d", txCount, lastEntered, descLen, splitCount, memoLen), nil
+}
+
+// searchDocuments calls fn with the description and the concatenated split
+// memos of every transaction.
+func (d *DB) searchDocuments(ctx context.Context, fn func(guid, description, memos string) error) error {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT t.guid, COALESCE(t.description, ''), COALESCE(GROUP_CONCAT(NULLIF(s.memo, ''), ' '), '')
+		FROM transactions t
+		LEFT JOIN splits s ON s.tx_guid = t.guid
+		GROUP BY t.guid
+	`)
+	if err != nil {
+		return fmt.Errorf("query search documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var guid, description, memos string
+		if err := rows.Scan(&guid, &description, &memos); err != nil {
+			return fmt.Errorf("scan search document: %w", err)
+		}
+		if err := fn(guid, description, memos); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SearchIndex is a full-text index of transaction descriptions and memos,
+// kept in a separate, writable SQLite database (FTS5). It is rebuilt whenever
+// the book's searchable text changes; the book itself is never written to.
+type SearchIndex struct {
+	db *sql.DB
+	mu sync.Mutex // serializes rebuilds
+}
+
+// OpenSearchIndex opens or creates a search index database at path. The
+// index is empty until the first Sync.
+func OpenSearchIndex(path string) (*SearchIndex, error) {
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("open search index: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS tx_fts USING fts5(
+			guid UNINDEXED, description, memos,
+			tokenize = 'unicode61 remove_diacritics 2'
+		);
+		CREATE TABLE IF NOT EXISTS tx_fts_meta (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			signature TEXT NOT NULL
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create search index tables: %w", err)
+	}
+	return &SearchIndex{db: db}, nil
+}
+
+// Close closes the search index database.
+func (ix *SearchIndex) Close() error {
+	return ix.db.Close()
+}
+
+// Sync rebuilds the index from book if the book changed since the last
+// build, and reports whether it did.
+func (ix *SearchIndex) Sync(ctx context.Context, book *DB) (bool, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	signature, err := book.searchSignature(ctx)
+	if err != nil {
+		return false, err
+	}
+	var indexed string
+	err = ix.db.QueryRowContext(ctx, `SELECT signature FROM tx_fts_meta WHERE id = 1`).Scan(&indexed)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("read search index state: %w", err)
+	}
+	if indexed == signature {
+		return false, nil
+	}
+
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tx_fts`); err != nil {
+		return false, fmt.Errorf("clear search index: %w", err)
+	}
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO tx_fts (guid, description, memos) VALUES (?, ?, ?)`)
+	if err != nil {
+		return false, fmt.Errorf("index transactions: %w", err)
+	}
+	defer insert.Close()
+	err = book.searchDocuments(ctx, func(guid, description, memos string) error {
+		if _, err := insert.ExecContext(ctx, guid, description, memos); err != nil {
+			return fmt.Errorf("index transaction %s: %w", guid, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO tx_fts_meta (id, signature) VALUES (1, ?)`, signature)
+	if err != nil {
+		return false, fmt.Errorf("record search index state: %w", err)
+	}
+	return true, tx.Commit()
+}
+
+// Match returns the GUIDs of the transactions whose description or memos
+// contain words starting with every word of text, best match first. ok is
+// false when text has no words to search for.
+func (ix *SearchIndex) Match(ctx context.Context, text string) (guids []string, ok bool, err error) {
+	query := ftsQuery(text)
+	if query == "" {
+		return nil, false, nil
+	}
+	// Descriptions weigh more than memos in the ranking.
+	rows, err := ix.db.QueryContext(ctx, `
+		SELECT guid FROM tx_fts WHERE tx_fts MATCH ? ORDER BY bm25(tx_fts, 0, 2, 1)
+	`, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("query search index: %w", err)
+	}
+	defer rows.Close()
+
+	guids = []string{}
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, false, fmt.Errorf("scan search hit: %w", err)
+		}
+		guids = append(guids, guid)
+	}
+	return guids, true, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query requiring a prefix match for
+// each word, so user input never reaches the FTS5 query syntax.
+func ftsQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, w := range words {
+		words[i] = `"` + w + `"*`
+	}
+	return strings.Join(words, " ")
+}
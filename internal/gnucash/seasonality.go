@@ -0,0 +1,231 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rolling windows of SpendingSeasonality, in months.
+var rollingWindows = []int{3, 6, 12}
+
+// spikeFactor is how many times its trailing 12-month average a month must
+// reach to be reported as a spike.
+const spikeFactor = 1.5
+
+// seasonMonth is one month of a category's spending with its rolling
+// averages, zero when the category has fewer months of history.
+type seasonMonth struct {
+	Month    time.Time
+	Total    float64
+	History  int       // months of history up to this one
+	Rolling  []float64 // one per rollingWindows
+	Trailing float64   // average of the up to 12 months before, for spikes
+	Spike    bool
+}
+
+// rolling returns the rolling averages of sm as cells, blank for the
+// windows longer than its history.
+func (sm seasonMonth) rolling() []string {
+	cells := make([]string, len(rollingWindows))
+	for w, n := range rollingWindows {
+		if sm.History >= n {
+			cells[w] = fmt.Sprintf("%.2f", sm.Rolling[w])
+		}
+	}
+	return cells
+}
+
+// SpendingSeasonality reports the monthly totals of category (an expense or
+// income account, with its sub-accounts) over the months months ending with
+// the month of endDate (today when empty), with their 3, 6 and 12-month
+// rolling averages, and the average of each calendar month over the whole
+// history of the category with its seasonal index (the ratio to the average
+// month). Months reaching 1.5 times the average of the 12 months before them
+// are flagged as spikes, and the 3-month average is compared with the
+// 12-month one to tell a trend. Income is counted positive, and amounts are
+// restated in current money when the call asks for it.
+func (s *Service) SpendingSeasonality(ctx context.Context, category, endDate string, months int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if months <= 0 {
+		months = 12
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid end_date '%s': %w", endDate, err)
+	}
+	account, err := s.resolveAccount(ctx, category)
+	if err != nil {
+		return "", err
+	}
+	guids := descendantGUIDs(account)
+	own := make(map[string]bool, len(guids))
+	for _, guid := range guids {
+		own[guid] = true
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	infl, err := s.inflation()
+	if err != nil {
+		return "", err
+	}
+
+	monthOf := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC) }
+	totals := make(map[time.Time]float64)
+	var first time.Time
+	add := func(date time.Time, value float64) {
+		m := monthOf(date)
+		if first.IsZero() || m.Before(first) {
+			first = m
+		}
+		value = infl.restate(value, date)
+		if account.AccountType == "INCOME" {
+			totals[m] -= value
+		} else {
+			totals[m] += value
+		}
+	}
+	if account.AccountType == "INCOME" || account.AccountType == "EXPENSE" {
+		// Through the aggregate cache when there is one.
+		monthly, err := s.monthTotals(ctx, "", endDate)
+		if err != nil {
+			return "", err
+		}
+		for _, t := range monthly {
+			if own[t.AccountGUID] {
+				month, _ := time.Parse("2006-01", t.Month)
+				add(month, t.Value.Float64())
+			}
+		}
+	} else {
+		splits, err := s.db.getRelatedSplits(ctx, guids, endDate, s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		for _, sp := range splits {
+			if own[sp.AccountGUID] {
+				add(sp.Date, sp.Value)
+			}
+		}
+	}
+	if first.IsZero() {
+		return fmt.Sprintf("Nothing recorded in %s up to %s.", account.FullName, endDate), nil
+	}
+
+	// The whole history, for the rolling and seasonal averages.
+	last := monthOf(end)
+	var history []float64
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		history = append(history, totals[m])
+	}
+	average := func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+
+	var series []seasonMonth
+	for i := max(0, len(history)-months); i < len(history); i++ {
+		sm := seasonMonth{Month: first.AddDate(0, i, 0), Total: history[i], History: i + 1, Rolling: make([]float64, len(rollingWindows))}
+		for w, n := range rollingWindows {
+			if i+1 >= n {
+				sm.Rolling[w] = average(history[i+1-n : i+1])
+			}
+		}
+		if i >= 3 {
+			sm.Trailing = average(history[max(0, i-12):i])
+			sm.Spike = sm.Trailing > 0 && sm.Total >= spikeFactor*sm.Trailing
+		}
+		series = append(series, sm)
+	}
+
+	// Seasonal averages over every occurrence of each calendar month.
+	var seasonal [12]float64
+	var count [12]int
+	for i, v := range history {
+		m := first.AddDate(0, i, 0).Month() - 1
+		seasonal[m] += v
+		count[m]++
+	}
+	overall := average(history)
+	for m := range seasonal {
+		if count[m] > 0 {
+			seasonal[m] /= float64(count[m])
+		}
+	}
+	index := func(m time.Month) float64 {
+		if overall == 0 {
+			return 0
+		}
+		return seasonal[m-1] / overall
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"month", "total", "avg_3m", "avg_6m", "avg_12m", "seasonal_avg", "seasonal_index", "spike"}}
+		for _, sm := range series {
+			spike := ""
+			if sm.Spike {
+				spike = "yes"
+			}
+			cells := append([]string{sm.Month.Format("2006-01"), fmt.Sprintf("%.2f", sm.Total)}, sm.rolling()...)
+			t.add(append(cells, fmt.Sprintf("%.2f", seasonal[sm.Month.Month()-1]), fmt.Sprintf("%.2f", index(sm.Month.Month())), spike)...)
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Seasonality of %s, in %s:\n", account.FullName, cur.Mnemonic)
+	sb.WriteString(infl.note())
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "  %-8s %12s %12s %12s %12s\n", "Month", "Total", "3-mo avg", "6-mo avg", "12-mo avg")
+	var spikes []string
+	for _, sm := range series {
+		fmt.Fprintf(&sb, "  %-8s %12.2f", sm.Month.Format("2006-01"), sm.Total)
+		for _, cell := range sm.rolling() {
+			fmt.Fprintf(&sb, " %12s", cell)
+		}
+		if sm.Spike {
+			sb.WriteString("  spike")
+			spikes = append(spikes, fmt.Sprintf("%s (%.2f, %.1fx the average of the months before)", sm.Month.Format("2006-01"), sm.Total, sm.Total/sm.Trailing))
+		}
+		sb.WriteString("\n")
+	}
+
+	years := float64(len(history)) / 12
+	fmt.Fprintf(&sb, "\nAverage per calendar month, over %d month(s) of history (%.1f years):\n", len(history), years)
+	for m := time.January; m <= time.December; m++ {
+		if count[m-1] == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "  %-4s %12.2f  index %.2f  (%d year(s))\n", m.String()[:3], seasonal[m-1], index(m), count[m-1])
+	}
+
+	latest := series[len(series)-1]
+	if len(spikes) > 0 {
+		fmt.Fprintf(&sb, "\nOne-off spikes: %s\n", strings.Join(spikes, "; "))
+	}
+	if len(history) >= 12 && latest.Rolling[2] != 0 {
+		change := (latest.Rolling[0] - latest.Rolling[2]) / latest.Rolling[2] * 100
+		trend := "stable"
+		switch {
+		case change >= 10:
+			trend = "rising"
+		case change <= -10:
+			trend = "falling"
+		}
+		fmt.Fprintf(&sb, "\nTrend: %s; the 3-month average (%.2f) is %+.0f%% from the 12-month average (%.2f).\n",
+			trend, latest.Rolling[0], change, latest.Rolling[2])
+	}
+	return sb.String(), nil
+}
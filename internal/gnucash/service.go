@@ -4,26 +4,154 @@ import (
 	"cmp"
 	"context"
 	"fmt"
-	"maps"
 	"slices"
 	"sort"
 	"strings"
 	"time"
+
+	"golang.org/x/text/language"
 )
 
 // Service provides business logic for GnuCash data access.
 type Service struct {
-	db *DB
+	db          *DB
+	expressions bool
+	snapshots   *SnapshotStore
+	index       *SearchIndex
+	aggregates  *AggregateCache
+	groups      CategoryGroups
+	horizon     int // years; 0 means no horizon
+	exportDir   string
+	sql         bool
+	write       bool
+	auditLog    *AuditLog
+	undo        *undoJournal
+	locale      *language.Tag  // nil prints plain decimals
+	numbers     *localeNumbers // how locale writes numbers
+	weekStart   time.Weekday
+	priceIndex  *PriceIndex // nil without one configured
+	goals       SavingsGoals
+	envelopes   *EnvelopeStore
+	tree        *accountCache
+
+	// Options of a single call (see With).
+	subtotals      bool
+	guids          bool
+	closingEntries bool
+	allHistory     bool
+	inflate        bool
+	dryRun         bool
+	toolCall       *toolCall // nil outside tool calls
+}
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithExpressions enables computed report expressions (see Expression).
+// They are disabled by default.
+func WithExpressions() Option {
+	return func(s *Service) { s.expressions = true }
+}
+
+// WithSnapshotStore enables chart-of-accounts history tracking in st.
+func WithSnapshotStore(st *SnapshotStore) Option {
+	return func(s *Service) { s.snapshots = st }
+}
+
+// WithSearchIndex makes text searches use the full-text index ix, which is
+// synced with the book before each search.
+func WithSearchIndex(ix *SearchIndex) Option {
+	return func(s *Service) { s.index = ix }
+}
+
+// WithAggregateCache makes monthly reports read the totals of whole months
+// from c, which the book watcher syncs when the book changed.
+func WithAggregateCache(c *AggregateCache) Option {
+	return func(s *Service) { s.aggregates = c }
+}
+
+// WithCategoryGroups enables grouping spending reports by super-category.
+func WithCategoryGroups(g CategoryGroups) Option {
+	return func(s *Service) { s.groups = g }
+}
+
+// WithDateHorizon restricts transaction listings and reports to the last
+// years years unless a call opts out with WithAllHistory. Balances always
+// cover the whole book.
+func WithDateHorizon(years int) Option {
+	return func(s *Service) { s.horizon = years }
+}
+
+// WithSQL enables the ad-hoc read-only SQL query tool. It is disabled by default.
+func WithSQL() Option {
+	return func(s *Service) { s.sql = true }
+}
+
+// WithWrites enables the write-mode tools, which modify the book. The DB must
+// have been opened for writing with EnableWrites. Disabled by default.
+func WithWrites() Option {
+	return func(s *Service) { s.write = true }
+}
+
+// WithAuditLog records every change made in write mode in log.
+func WithAuditLog(log *AuditLog) Option {
+	return func(s *Service) { s.auditLog = log }
+}
+
+// WithExportDir enables report bundle exports, written under dir.
+func WithExportDir(dir string) Option {
+	return func(s *Service) { s.exportDir = dir }
 }
 
 // NewService creates a new Service wrapping a database connection.
-func NewService(db *DB) *Service {
-	return &Service{db: db}
+func NewService(db *DB, opts ...Option) *Service {
+	s := &Service{db: db, undo: &undoJournal{}, tree: &accountCache{}, weekStart: time.Monday}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// With returns the service with opts applied for a single call, such as
+// WithSubtotals; the copy shares the book, stores and caches of s.
+func (s *Service) With(opts ...Option) *Service {
+	if len(opts) == 0 {
+		return s
+	}
+	c := *s
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
 }
 
-// ListAccounts returns accounts as a tree, optionally filtered by type.
-func (s *Service) ListAccounts(ctx context.Context, accountType string) (string, error) {
-	accounts, err := s.db.GetAllAccounts(ctx)
+// parseExpressions parses user-supplied report expressions, refusing them
+// unless the service was created WithExpressions.
+func (s *Service) parseExpressions(defs string) ([]*Expression, error) {
+	if strings.TrimSpace(defs) == "" {
+		return nil, nil
+	}
+	if !s.expressions {
+		return nil, fmt.Errorf("computed expressions are disabled (set GNUCASH_EXPRESSIONS=1 to enable)")
+	}
+	return ParseExpressions(defs)
+}
+
+// ListAccounts returns accounts as an indented tree, optionally filtered by type
+// (see NormalizeAccountType for accepted spellings).
+// Each line shows the account's own balance; parent accounts also show the
+// subtotal of their whole subtree. A positive maxDepth limits how many levels
+// are printed, while subtotals still include the hidden levels.
+func (s *Service) ListAccounts(ctx context.Context, accountType string, maxDepth int) (string, error) {
+	accountType, err := NormalizeAccountType(accountType)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -32,21 +160,48 @@ func (s *Service) ListAccounts(ctx context.Context, accountType string) (string,
 		return "", err
 	}
 
-	values := slices.Collect(maps.Values(accounts))
-	if accountType != "" {
-		values = slices.DeleteFunc(values, func(a *Account) bool {
-			return a.AccountType != accountType
-		})
-	}
+	include := func(a *Account) bool { return accountType == "" || a.AccountType == accountType }
 
-	slices.SortFunc(values, func(a, b *Account) int {
+	// Roots are included accounts whose parent is not part of the listing.
+	var roots []*Account
+	for _, acc := range accounts {
+		if !include(acc) {
+			continue
+		}
+		if parent, ok := accounts[acc.ParentGUID]; ok && include(parent) {
+			continue
+		}
+		roots = append(roots, acc)
+	}
+	slices.SortFunc(roots, func(a, b *Account) int {
 		return cmp.Compare(a.FullName, b.FullName)
 	})
 
-	// Format output
 	var sb strings.Builder
-	for _, acc := range values {
-		fmt.Fprintf(&sb, "%s\t%s\t%.2f\n", acc.FullName, acc.AccountType, balances[acc.GUID])
+	var render func(acc *Account, depth int)
+	render = func(acc *Account, depth int) {
+		if maxDepth > 0 && depth >= maxDepth {
+			return
+		}
+		name := acc.Name
+		if depth == 0 {
+			name = acc.FullName
+		}
+		fmt.Fprintf(&sb, "%s%s\t%s\t%s", strings.Repeat("  ", depth), name, acc.AccountType, s.formatAmount(balances[acc.GUID], cur.Fraction))
+		children := slices.DeleteFunc(slices.Clone(acc.Children), func(c *Account) bool { return !include(c) })
+		if len(children) > 0 {
+			fmt.Fprintf(&sb, "\t(subtotal %s)", s.formatAmount(subtreeBalance(acc, balances, include), cur.Fraction))
+		}
+		if s.guids {
+			fmt.Fprintf(&sb, "\t%s", acc.GUID)
+		}
+		sb.WriteString("\n")
+		for _, child := range children {
+			render(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		render(root, 0)
 	}
 
 	result := sb.String()
@@ -56,64 +211,289 @@ func (s *Service) ListAccounts(ctx context.Context, accountType string) (string,
 	return result, nil
 }
 
-// resolveAccount finds a single account by name. Returns an error if no match or ambiguous.
+// subtreeBalance sums the balances of acc and every included descendant.
+func subtreeBalance(acc *Account, balances map[string]Numeric, include func(*Account) bool) Numeric {
+	total := balances[acc.GUID]
+	for _, child := range acc.Children {
+		if include(child) {
+			total = total.Add(subtreeBalance(child, balances, include))
+		}
+	}
+	return total
+}
+
+// resolveAccount finds a single account by GUID, path or name. Returns an error
+// if no match or ambiguous.
+//
+// Names containing ':' are matched against full paths: the exact path wins,
+// otherwise the segments must match the end of an account's path, the last
+// segment being a partial match ("Auto:Insur" finds "Expenses:Auto:Insurance").
+// Names that match nothing fall back to fuzzy matching: a single close match is
+// used as-is, otherwise the error lists the nearest accounts as suggestions.
 func (s *Service) resolveAccount(ctx context.Context, name string) (*Account, error) {
-	mAccount, err := s.db.GetAllAccounts(ctx) // TODO: cache
+	mAccount, err := s.accountTree(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if acc, ok := mAccount[name]; ok {
+		return acc, nil
+	}
 	if strings.Contains(name, ":") {
 		for _, acc := range mAccount {
 			if acc.FullName == name {
 				return acc, nil
 			}
 		}
-		return nil, fmt.Errorf("no account found matching '%s'", name)
+		matches := matchPathSuffix(name, mAccount)
+		switch len(matches) {
+		case 0:
+			return resolveFuzzy(name, mAccount)
+		case 1:
+			return matches[0], nil
+		}
+		return nil, ambiguousAccountError(name, matches)
 	}
 
-	accounts, err := s.db.FindAccountsByName(ctx, name)
-	if err != nil {
-		return nil, err
-	}
-	if len(accounts) == 0 {
-		return nil, fmt.Errorf("no account found matching '%s'", name)
+	named := accountsNamed(mAccount, name)
+	switch len(named) {
+	case 0:
+		return resolveFuzzy(name, mAccount)
+	case 1:
+		return named[0], nil
 	}
+	return nil, ambiguousAccountError(name, named)
+}
+
+// matchPathSuffix returns the accounts whose path ends with the segments of
+// path, compared case-insensitively, with the last segment matched partially.
+// Exact matches on the last segment take precedence over partial ones.
+func matchPathSuffix(path string, accounts map[string]*Account) []*Account {
+	query := strings.Split(strings.ToLower(path), ":")
+	last := query[len(query)-1]
 
-	if len(accounts) > 1 {
-		names := make([]string, len(accounts))
-		for i, a := range accounts {
-			names[i] = fmt.Sprintf("  - %s [%s]", mAccount[a.GUID].FullName, a.AccountType)
+	var exact, partial []*Account
+	for _, acc := range accounts {
+		segments := strings.Split(strings.ToLower(acc.FullName), ":")
+		if len(segments) < len(query) {
+			continue
+		}
+		tail := segments[len(segments)-len(query):]
+		if !slices.Equal(tail[:len(tail)-1], query[:len(query)-1]) {
+			continue
+		}
+		switch leaf := tail[len(tail)-1]; {
+		case leaf == last:
+			exact = append(exact, acc)
+		case strings.Contains(leaf, last):
+			partial = append(partial, acc)
 		}
-		return nil, fmt.Errorf("multiple accounts match '%s':\n%s\nPlease be more specific", name, strings.Join(names, "\n"))
 	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return partial
+}
 
-	return &accounts[0], nil
+func ambiguousAccountError(name string, matches []*Account) error {
+	slices.SortFunc(matches, func(a, b *Account) int {
+		return cmp.Compare(a.FullName, b.FullName)
+	})
+	names := make([]string, len(matches))
+	for i, a := range matches {
+		names[i] = fmt.Sprintf("  - %s [%s]", a.FullName, a.AccountType)
+	}
+	return fmt.Errorf("multiple accounts match '%s':\n%s\nPlease be more specific", name, strings.Join(names, "\n"))
+}
+
+// resolveFuzzy resolves a misspelled account name. A unique closest account
+// within a quarter of the name's length is accepted; otherwise up to three
+// accounts within half its length are offered as suggestions.
+func resolveFuzzy(name string, accounts map[string]*Account) (*Account, error) {
+	n := len([]rune(name))
+	matches := fuzzyMatches(name, accounts, max(1, n/2))
+
+	if len(matches) > 0 && matches[0].Distance <= max(1, n/4) &&
+		(len(matches) == 1 || matches[1].Distance > matches[0].Distance) {
+		return matches[0].Account, nil
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no account found matching '%s'", name)
+	}
+
+	suggestions := make([]string, 0, 3)
+	for _, m := range matches[:min(3, len(matches))] {
+		suggestions = append(suggestions, m.Account.FullName)
+	}
+	return nil, fmt.Errorf("no account found matching '%s'; did you mean %s?", name, strings.Join(suggestions, ", "))
 }
 
 // GetBalance returns the balance for a named account as of a given date.
-func (s *Service) GetBalance(ctx context.Context, accountName, date string) (string, error) {
+// When includeChildren is nil, sub-accounts are included for placeholder and
+// parent accounts and excluded for leaf accounts.
+func (s *Service) GetBalance(ctx context.Context, accountName, date string, includeChildren *bool) (string, error) {
 	account, err := s.resolveAccount(ctx, accountName)
 	if err != nil {
 		return "", err
 	}
-
-	num, denom, err := s.db.GetBalanceForAccount(ctx, account.GUID, date)
+	accounts, err := s.accountTree(ctx)
 	if err != nil {
 		return "", err
 	}
+	if full, ok := accounts[account.GUID]; ok {
+		account = full
+	}
+
+	recursive := account.Placeholder || len(account.Children) > 0
+	if includeChildren != nil {
+		recursive = *includeChildren
+	}
 
-	balance := FormatDecimal(num, denom)
+	guids := []string{account.GUID}
+	if recursive {
+		guids = descendantGUIDs(account)
+	}
+
+	balance, err := s.db.GetBalanceForAccounts(ctx, guids, date)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
 
 	dateLabel := "current"
 	if date != "" {
 		dateLabel = "as of " + date
 	}
+	if recursive && len(guids) > 1 {
+		dateLabel += fmt.Sprintf(", including %d sub-accounts", len(guids)-1)
+	}
 
-	return fmt.Sprintf("Account: %s [%s]\nBalance (%s): %s EUR", account.FullName, account.AccountType, dateLabel, balance), nil
+	return fmt.Sprintf("Account: %s [%s]\nBalance (%s): %s", account.FullName, account.AccountType, dateLabel, s.formatMoney(balance, cur)), nil
 }
 
-// GetTransactions returns transactions for a named account within a date range.
-func (s *Service) GetTransactions(ctx context.Context, accountName, startDate, endDate string, limit int) (string, error) {
+// GetAccount describes one account: its GUID, full name, type, commodity,
+// code, description, notes, flags, parent and children, the dates of its
+// first and last transactions, and its current balance, with its
+// sub-accounts when it has any.
+func (s *Service) GetAccount(ctx context.Context, accountName string) (string, error) {
+	account, err := s.resolveAccount(ctx, accountName)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	if full, ok := accounts[account.GUID]; ok {
+		account = full
+	}
+	det, err := s.db.getAccountDetails(ctx, account.GUID)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	var commodity Commodity
+	if account.CommodityGUID != "" {
+		if commodity, err = s.db.GetCommodity(ctx, account.CommodityGUID); err != nil {
+			return "", err
+		}
+	}
+	balance, err := s.db.GetBalanceForAccounts(ctx, []string{account.GUID}, "")
+	if err != nil {
+		return "", err
+	}
+
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Account: %s\n", account.FullName)
+	fmt.Fprintf(&sb, "GUID: %s\n", account.GUID)
+	fmt.Fprintf(&sb, "Type: %s\n", account.AccountType)
+	if commodity.Mnemonic != "" {
+		fmt.Fprintf(&sb, "Commodity: %s (%s)\n", commodity.Mnemonic, commodity.Namespace)
+	}
+	if det.Code != "" {
+		fmt.Fprintf(&sb, "Code: %s\n", det.Code)
+	}
+	if account.Description != "" {
+		fmt.Fprintf(&sb, "Description: %s\n", account.Description)
+	}
+	if det.Notes != "" {
+		fmt.Fprintf(&sb, "Notes: %s\n", det.Notes)
+	}
+	fmt.Fprintf(&sb, "Placeholder: %s\n", yesNo(account.Placeholder))
+	fmt.Fprintf(&sb, "Hidden: %s\n", yesNo(account.Hidden))
+	if parent, ok := accounts[account.ParentGUID]; ok && parent.AccountType != "ROOT" {
+		fmt.Fprintf(&sb, "Parent: %s\n", parent.FullName)
+	}
+	if len(account.Children) > 0 {
+		names := make([]string, len(account.Children))
+		for i, child := range account.Children {
+			names[i] = child.Name
+		}
+		fmt.Fprintf(&sb, "Children: %s\n", strings.Join(names, ", "))
+	}
+	if det.Splits == 0 {
+		sb.WriteString("Transactions: none\n")
+	} else {
+		fmt.Fprintf(&sb, "Opened: %s\n", det.FirstDate)
+		fmt.Fprintf(&sb, "Transactions: %d, the last on %s\n", det.Splits, det.LastDate)
+	}
+	fmt.Fprintf(&sb, "Balance: %s", s.formatMoney(balance, cur))
+	if commodity.Mnemonic != "" && commodity.Namespace != "CURRENCY" {
+		fmt.Fprintf(&sb, " (%s %s)", det.Quantity.Format(commodity.Fraction), commodity.Mnemonic)
+	}
+	sb.WriteString("\n")
+	if len(account.Children) > 0 {
+		total, err := s.db.GetBalanceForAccounts(ctx, descendantGUIDs(account), "")
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "Balance with sub-accounts: %s\n", s.formatMoney(total, cur))
+	}
+	return sb.String(), nil
+}
+
+// bookCurrency returns the currency most transactions of the book are in,
+// which reports show amounts in; EUR for a book without transactions.
+func (s *Service) bookCurrency(ctx context.Context) (Commodity, error) {
+	guid, err := s.db.defaultCurrency(ctx)
+	if err != nil {
+		return Commodity{}, err
+	}
+	if guid == "" {
+		return Commodity{Namespace: "CURRENCY", Mnemonic: "EUR", Fraction: 100}, nil
+	}
+	return s.db.GetCommodity(ctx, guid)
+}
+
+// descendantGUIDs returns the GUID of acc followed by those of all its descendants.
+func descendantGUIDs(acc *Account) []string {
+	guids := []string{acc.GUID}
+	for _, child := range acc.Children {
+		guids = append(guids, descendantGUIDs(child)...)
+	}
+	return guids
+}
+
+// GetTransactions returns transactions for a named account within a date range,
+// sorted by sortBy (date, amount or description) in the given order.
+// Results are paged: when more transactions exist, the output ends with a
+// cursor to pass back to fetch the next page.
+func (s *Service) GetTransactions(ctx context.Context, accountName, startDate, endDate string, limit int, sortBy, order, cursor, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
 	account, err := s.resolveAccount(ctx, accountName)
 	if err != nil {
 		return "", err
@@ -123,13 +503,94 @@ func (s *Service) GetTransactions(ctx context.Context, accountName, startDate, e
 		limit = 50
 	}
 
-	transactions, err := s.db.GetSplitsForAccount(ctx, account.GUID, startDate, endDate, limit)
+	startDate, notice, err := s.horizonStart(ctx, startDate)
+	if err != nil {
+		return "", err
+	}
+	q, err := newTxQuery(startDate, endDate, limit, sortBy, order, cursor)
+	if err != nil {
+		return "", err
+	}
+	if q.Order.By == SortByRelevance {
+		return "", fmt.Errorf("sort_by relevance is only supported by text searches")
+	}
+	transactions, next, err := s.db.GetSplitsForAccount(ctx, account.GUID, q)
 	if err != nil {
 		return "", err
 	}
 
 	if len(transactions) == 0 {
-		return fmt.Sprintf("No transactions found for %s in the given period.", account.Name), nil
+		return fmt.Sprintf("No transactions found for %s in the given period.", account.Name) + horizonNote(format, notice), nil
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	commodities, err := s.db.getCommodities(ctx)
+	if err != nil {
+		return "", err
+	}
+	// currency returns the currency the amounts of tx are in.
+	currency := func(tx Transaction) Commodity {
+		if c, ok := commodities[tx.CurrencyGUID]; ok {
+			return c
+		}
+		return cur
+	}
+	// Registers of stocks and funds show the shares each transaction moved
+	// besides its value.
+	security, shares := commodities[account.CommodityGUID]
+	shares = shares && security.Namespace != "CURRENCY"
+	quantity := func(n Numeric) string {
+		return n.Format(security.Fraction) + " " + security.Mnemonic
+	}
+
+	// With subtotals, a month ends where the next transaction is in another
+	// month; only listings in date order have months to subtotal.
+	subtotals := s.subtotals
+	byMonth := subtotals && q.Order.By == SortByDate
+	var monthTotal, total, monthShares, totalShares Numeric
+	monthCount := 0
+	monthEnds := func(i int) bool {
+		return byMonth && (i == len(transactions)-1 ||
+			transactions[i+1].PostDate.Format("2006-01") != transactions[i].PostDate.Format("2006-01"))
+	}
+	totalLabel := "Total"
+	if next != "" {
+		totalLabel = "Total of this page"
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"date", "description", "amount", "currency", "counterparts", "counterpart_amounts", "transaction_guid", "split_guid"}}
+		if shares {
+			t.Headers = slices.Insert(t.Headers, 4, "quantity")
+		}
+		add := func(shareCount Numeric, cells ...string) {
+			if shares {
+				cells = slices.Insert(cells, 4, shareCount.Format(security.Fraction))
+			}
+			t.add(cells...)
+		}
+		for i, tx := range transactions {
+			c := currency(tx)
+			var counterparts, amounts []string
+			for _, sp := range tx.Splits[1:] {
+				counterparts = append(counterparts, sp.AccountName)
+				amounts = append(amounts, sp.Value().Format(c.Fraction))
+			}
+			add(tx.Splits[0].Quantity(), tx.PostDate.Format("2006-01-02"), tx.Description, tx.Splits[0].Value().Format(c.Fraction), c.Mnemonic,
+				strings.Join(counterparts, "; "), strings.Join(amounts, "; "), tx.GUID, tx.Splits[0].GUID)
+			monthTotal, total = monthTotal.Add(tx.Splits[0].Value()), total.Add(tx.Splits[0].Value())
+			monthShares, totalShares = monthShares.Add(tx.Splits[0].Quantity()), totalShares.Add(tx.Splits[0].Quantity())
+			if monthEnds(i) {
+				add(monthShares, tx.PostDate.Format("2006-01"), "Subtotal", monthTotal.Format(cur.Fraction), cur.Mnemonic, "", "", "", "")
+				monthTotal, monthShares = Numeric{}, Numeric{}
+			}
+		}
+		if subtotals {
+			add(totalShares, "", totalLabel, total.Format(cur.Fraction), cur.Mnemonic, "", "", "", "")
+		}
+		return t.render(format) + nextPageNote(format, next) + horizonNote(format, notice), nil
 	}
 
 	var sb strings.Builder
@@ -149,34 +610,91 @@ func (s *Service) GetTransactions(ctx context.Context, accountName, startDate, e
 	}
 	fmt.Fprintf(&sb, "\nShowing %d transactions:\n\n", len(transactions))
 
-	for _, tx := range transactions {
+	for i, tx := range transactions {
 		// The first split is for the queried account
-		amount := tx.Splits[0].FormatAmount()
-		counterparts := make([]string, 0, len(tx.Splits)-1)
-		for _, sp := range tx.Splits[1:] {
-			counterparts = append(counterparts, sp.AccountName)
+		c := currency(tx)
+		amount := s.formatMoney(tx.Splits[0].Value(), c)
+		if shares {
+			amount = quantity(tx.Splits[0].Quantity()) + "  " + amount
 		}
-		counter := strings.Join(counterparts, ", ")
-
-		fmt.Fprintf(&sb, "%s  %s EUR  %s", tx.PostDate.Format("2006-01-02"), amount, tx.Description)
-		if counter != "" {
-			fmt.Fprintf(&sb, "  [%s]", counter)
+		fmt.Fprintf(&sb, "%s  %s  %s", tx.PostDate.Format("2006-01-02"), amount, tx.Description)
+		if len(tx.Splits) == 2 {
+			fmt.Fprintf(&sb, "  [%s]", tx.Splits[1].AccountName)
+		}
+		if s.guids {
+			fmt.Fprintf(&sb, "  (transaction %s, split %s)", tx.GUID, tx.Splits[0].GUID)
 		}
 		sb.WriteString("\n")
+		// Each leg of a split transaction, such as a paycheck, with its own
+		// amount.
+		if len(tx.Splits) > 2 {
+			for _, sp := range tx.Splits[1:] {
+				fmt.Fprintf(&sb, "    %-30s %s\n", sp.AccountName, s.formatMoney(sp.Value(), c))
+			}
+		}
+		monthTotal, total = monthTotal.Add(tx.Splits[0].Value()), total.Add(tx.Splits[0].Value())
+		monthShares, totalShares = monthShares.Add(tx.Splits[0].Quantity()), totalShares.Add(tx.Splits[0].Quantity())
+		monthCount++
+		if monthEnds(i) {
+			sum := s.formatMoney(monthTotal, cur)
+			if shares {
+				sum = quantity(monthShares) + "  " + sum
+			}
+			fmt.Fprintf(&sb, "%s subtotal  %s  (%d transactions)\n\n", tx.PostDate.Format("2006-01"), sum, monthCount)
+			monthTotal, monthShares, monthCount = Numeric{}, Numeric{}, 0
+		}
+	}
+	if subtotals {
+		if !byMonth {
+			sb.WriteString("\n")
+		}
+		sum := s.formatMoney(total, cur)
+		if shares {
+			sum = quantity(totalShares) + "  " + sum
+		}
+		fmt.Fprintf(&sb, "%s  %s  (%d transactions)\n", totalLabel, sum, len(transactions))
 	}
 
+	sb.WriteString(nextPageNote(format, next))
+	sb.WriteString(horizonNote(format, notice))
 	return sb.String(), nil
 }
 
-// SpendingByCategory returns expense totals grouped by category.
-func (s *Service) SpendingByCategory(ctx context.Context, startDate, endDate, parentAccount string) (string, error) {
-	now := time.Now()
+// SpendingByCategory returns expense totals grouped by category, or by the
+// configured category groups when grouping is GroupByGroup, with a bar
+// scaled to the largest category in text output.
+// Each category row exposes the variables total and count to expressions.
+//
+// Totals are in the currency of their accounts: when expense accounts are
+// kept in several currencies, categories are listed per currency with a
+// subtotal for each. With convertTo, an ISO currency code, the report ends
+// with a grand total converted at the latest exchange rates of the book on
+// or before endDate.
+func (s *Service) SpendingByCategory(ctx context.Context, startDate, endDate, parentAccount, grouping, convertTo, expressions, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	exprs, err := s.parseExpressions(expressions)
+	if err != nil {
+		return "", err
+	}
+	groupOf, err := s.groupLabeler(ctx, grouping)
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
 	if startDate == "" {
 		startDate = now.Format("2006-01") + "-01"
 	}
 	if endDate == "" {
 		endDate = now.Format("2006-01-02")
 	}
+	startDate, notice, err := s.horizonStart(ctx, startDate)
+	if err != nil {
+		return "", err
+	}
 
 	var parentGUID string
 	if parentAccount != "" {
@@ -187,78 +705,240 @@ func (s *Service) SpendingByCategory(ctx context.Context, startDate, endDate, pa
 		parentGUID = acc.GUID
 	}
 
-	byAccount, names, err := s.db.GetExpenseSplits(ctx, startDate, endDate, parentGUID)
+	totals, err := s.monthTotals(ctx, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
 	if err != nil {
 		return "", err
 	}
+	// Expense accounts, or the direct children of parentAccount, with the
+	// sum of their split quantities and the number of splits.
+	type accountTotal struct {
+		Quantity Numeric
+		Count    int
+	}
+	byAccount := make(map[string]*accountTotal)
+	for _, t := range totals {
+		acc, ok := accounts[t.AccountGUID]
+		if !ok || acc.AccountType != "EXPENSE" || (parentGUID != "" && acc.ParentGUID != parentGUID) {
+			continue
+		}
+		at, ok := byAccount[t.AccountGUID]
+		if !ok {
+			at = &accountTotal{}
+			byAccount[t.AccountGUID] = at
+		}
+		at.Quantity = at.Quantity.Add(t.Quantity)
+		at.Count += t.Splits
+	}
 
 	if len(byAccount) == 0 {
-		return fmt.Sprintf("No expenses found from %s to %s.", startDate, endDate), nil
+		return fmt.Sprintf("No expenses found from %s to %s.", startDate, endDate) + horizonNote(format, notice), nil
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	currencies := map[string]Commodity{cur.GUID: cur}
+	currencyOf := func(guid string) (Commodity, error) {
+		acc, ok := accounts[guid]
+		if !ok || acc.CommodityGUID == "" {
+			return cur, nil
+		}
+		if c, ok := currencies[acc.CommodityGUID]; ok {
+			return c, nil
+		}
+		c, err := s.db.GetCommodity(ctx, acc.CommodityGUID)
+		if err != nil {
+			return Commodity{}, err
+		}
+		currencies[c.GUID] = c
+		return c, nil
 	}
 
 	type catEntry struct {
-		Name  string
-		Total int64
-		Denom int64
-		Count int
+		Name     string
+		Currency Commodity
+		Total    Numeric
+		Count    int
+	}
+	// Rows are keyed by currency and account GUID, or group name when
+	// grouping. Totals add up quantities, which are in the account's currency.
+	byKey := make(map[[2]string]*catEntry)
+	used := make(map[string]bool)
+	for guid, at := range byAccount {
+		currency, err := currencyOf(guid)
+		if err != nil {
+			return "", err
+		}
+		key, name := guid, accounts[guid].Name
+		if groupOf != nil {
+			key = groupOf(guid)
+			name = key
+		}
+		cat, ok := byKey[[2]string{currency.GUID, key}]
+		if !ok {
+			cat = &catEntry{Name: name, Currency: currency}
+			byKey[[2]string{currency.GUID, key}] = cat
+		}
+		cat.Total = cat.Total.Add(at.Quantity)
+		cat.Count += at.Count
+		used[currency.GUID] = true
 	}
 	var categories []catEntry
-	for guid, splits := range byAccount {
-		var total int64
-		var denom int64 = 100
-		for _, sp := range splits {
-			total += sp.ValueNum
-			denom = sp.ValueDenom
-		}
-		categories = append(categories, catEntry{
-			Name:  names[guid],
-			Total: total,
-			Denom: denom,
-			Count: len(splits),
-		})
+	for _, cat := range byKey {
+		categories = append(categories, *cat)
 	}
 
-	// Sort by total descending
+	// Sort by currency, the book's first, then by total descending
 	sort.Slice(categories, func(i, j int) bool {
-		return categories[i].Total > categories[j].Total
+		a, b := categories[i], categories[j]
+		if a.Currency.GUID != b.Currency.GUID {
+			if a.Currency.GUID == cur.GUID || b.Currency.GUID == cur.GUID {
+				return a.Currency.GUID == cur.GUID
+			}
+			return a.Currency.Mnemonic < b.Currency.Mnemonic
+		}
+		return a.Total.Cmp(b.Total) > 0
 	})
+	multi := len(used) > 1
 
-	var sb strings.Builder
-	fmt.Fprintf(&sb, "Spending by category (%s to %s):\n\n", startDate, endDate)
+	var target Commodity
+	if convertTo != "" {
+		if target, err = s.findCurrency(ctx, convertTo); err != nil {
+			return "", err
+		}
+	}
+
+	computed := make([][]string, len(categories))
+	for i, cat := range categories {
+		computed[i] = evalExpressions(exprs, map[string]any{
+			"total": cat.Total.Float64(),
+			"count": float64(cat.Count),
+		})
+	}
 
-	var grandTotal int64
-	var grandDenom int64 = 100
+	if format != FormatText {
+		headers := []string{"category", "total", "count"}
+		if multi {
+			headers = append(headers, "currency")
+		}
+		t := table{Headers: append(headers, expressionNames(exprs)...)}
+		for i, cat := range categories {
+			row := []string{cat.Name, cat.Total.Format(cat.Currency.Fraction), fmt.Sprint(cat.Count)}
+			if multi {
+				row = append(row, cat.Currency.Mnemonic)
+			}
+			t.add(append(row, computed[i]...)...)
+		}
+		return t.render(format) + horizonNote(format, notice), nil
+	}
+
+	// Categories are sorted by currency: one section per currency.
+	var sections []Commodity
+	subtotals := make(map[string]Numeric)
 	for _, cat := range categories {
-		fmt.Fprintf(&sb, "  %-30s %10s EUR  (%d transactions)\n",
-			cat.Name, FormatDecimal(cat.Total, cat.Denom), cat.Count)
-		grandTotal += cat.Total
-		grandDenom = cat.Denom
+		if _, ok := subtotals[cat.Currency.GUID]; !ok {
+			sections = append(sections, cat.Currency)
+		}
+		subtotals[cat.Currency.GUID] = subtotals[cat.Currency.GUID].Add(cat.Total)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Spending by category (%s to %s):\n", startDate, endDate)
+	i := 0
+	for _, c := range sections {
+		sb.WriteString("\n")
+		if multi {
+			fmt.Fprintf(&sb, "  %s\n", c.Label())
+		}
+		// Bars are scaled to the largest category, the first of the section.
+		largest := categories[i].Total.Float64()
+		for ; i < len(categories) && categories[i].Currency.GUID == c.GUID; i++ {
+			cat := categories[i]
+			fmt.Fprintf(&sb, "  %-30s %14s  %s  (%d transactions)",
+				cat.Name, s.formatMoney(cat.Total, c), bar(cat.Total.Float64(), largest), cat.Count)
+			for j, e := range exprs {
+				fmt.Fprintf(&sb, "  %s=%s", e.Name, computed[i][j])
+			}
+			sb.WriteString("\n")
+		}
+		label := "TOTAL"
+		if multi {
+			label = "Subtotal " + c.Mnemonic
+		}
+		fmt.Fprintf(&sb, "\n  %-30s %14s\n", label, s.formatMoney(subtotals[c.GUID], c))
 	}
-	fmt.Fprintf(&sb, "\n  %-30s %10s EUR\n", "TOTAL", FormatDecimal(grandTotal, grandDenom))
+
+	switch {
+	case convertTo != "":
+		var total Numeric
+		var rates []string
+		for _, c := range sections {
+			rate, ok, err := s.db.exchangeRate(ctx, c, target, endDate)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "", fmt.Errorf("no exchange rate from %s to %s on or before %s in the book", c.Mnemonic, target.Mnemonic, endDate)
+			}
+			total = total.Add(subtotals[c.GUID].Mul(rate))
+			if c.GUID != target.GUID {
+				rates = append(rates, fmt.Sprintf("1 %s = %s %s", c.Mnemonic, formatRate(rate), target.Mnemonic))
+			}
+		}
+		fmt.Fprintf(&sb, "\n  %-30s %14s\n", "TOTAL in "+target.Mnemonic, s.formatMoney(total, target))
+		if len(rates) > 0 {
+			fmt.Fprintf(&sb, "  (converted at %s)\n", strings.Join(rates, ", "))
+		}
+	case multi:
+		sb.WriteString("\nAmounts in different currencies are not added up; pass convert_to for a converted total.\n")
+	}
+	sb.WriteString(horizonNote(format, notice))
 
 	return sb.String(), nil
 }
 
-// IncomeVsExpenses returns a monthly comparison of income and expenses.
-func (s *Service) IncomeVsExpenses(ctx context.Context, months int) (string, error) {
+// IncomeVsExpenses returns a monthly comparison of income and expenses,
+// followed in text output by sparklines of income, expenses and net.
+// Each month row exposes the variables income, expenses and net to expressions.
+// Months are restated in current money when the call asks for it (see
+// WithInflationAdjustment).
+func (s *Service) IncomeVsExpenses(ctx context.Context, months int, expressions, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	exprs, err := s.parseExpressions(expressions)
+	if err != nil {
+		return "", err
+	}
+
 	if months <= 0 {
 		months = 6
 	}
 
-	now := time.Now()
-	endDate := now.Format("2006-01-02")
-	startDate := now.AddDate(0, -months+1, -now.Day()+1).Format("2006-01-02")
+	startDate, endDate := monthsPeriod(s.now(), months)
+	startDate, notice, err := s.horizonStart(ctx, startDate)
+	if err != nil {
+		return "", err
+	}
 
-	rows, err := s.db.GetMonthlyIncomeExpenses(ctx, startDate, endDate)
+	rows, err := s.monthlyIncomeExpenses(ctx, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+	infl, err := s.inflation()
 	if err != nil {
 		return "", err
 	}
 
 	// Organize by month
 	type monthData struct {
-		Income   int64
-		Expenses int64
-		Denom    int64
+		Income   Numeric
+		Expenses Numeric
 	}
 	byMonth := make(map[string]*monthData)
 	var monthOrder []string
@@ -266,71 +946,302 @@ func (s *Service) IncomeVsExpenses(ctx context.Context, months int) (string, err
 	for _, r := range rows {
 		md, exists := byMonth[r.Month]
 		if !exists {
-			md = &monthData{Denom: 100}
+			md = &monthData{}
 			byMonth[r.Month] = md
 			monthOrder = append(monthOrder, r.Month)
 		}
-		if r.Denom > 0 {
-			md.Denom = r.Denom
+		total := r.Total
+		if infl != nil {
+			month, _ := time.Parse("2006-01", r.Month)
+			total = total.scale(infl.restate(1, month))
 		}
 		switch r.AccType {
 		case "INCOME":
 			// Income splits are negative in GnuCash (credit), negate for display
-			md.Income = -r.Total
+			md.Income = total.Neg()
 		case "EXPENSE":
-			md.Expenses = r.Total
+			md.Expenses = total
 		}
 	}
 
 	sort.Strings(monthOrder)
 
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	computed := make(map[string][]string, len(monthOrder))
+	for _, month := range monthOrder {
+		md := byMonth[month]
+		computed[month] = evalExpressions(exprs, map[string]any{
+			"income":   md.Income.Float64(),
+			"expenses": md.Expenses.Float64(),
+			"net":      md.Income.Sub(md.Expenses).Float64(),
+		})
+	}
+
+	if format != FormatText {
+		t := table{Headers: append([]string{"month", "income", "expenses", "net"}, expressionNames(exprs)...)}
+		for _, month := range monthOrder {
+			md := byMonth[month]
+			t.add(append([]string{month, md.Income.Format(cur.Fraction), md.Expenses.Format(cur.Fraction),
+				md.Income.Sub(md.Expenses).Format(cur.Fraction)}, computed[month]...)...)
+		}
+		return t.render(format) + horizonNote(format, notice), nil
+	}
+
 	var sb strings.Builder
-	fmt.Fprintf(&sb, "Income vs Expenses (last %d months):\n\n", months)
-	fmt.Fprintf(&sb, "  %-10s %12s %12s %12s\n", "Month", "Income", "Expenses", "Net")
-	fmt.Fprintf(&sb, "  %s\n", strings.Repeat("-", 48))
+	fmt.Fprintf(&sb, "Income vs Expenses (last %d months):\n", months)
+	sb.WriteString(infl.note())
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "  %-10s %12s %12s %12s", "Month", "Income", "Expenses", "Net")
+	for _, e := range exprs {
+		fmt.Fprintf(&sb, " %12s", e.Name)
+	}
+	fmt.Fprintf(&sb, "\n  %s\n", strings.Repeat("-", 48+13*len(exprs)))
 
 	for _, month := range monthOrder {
 		md := byMonth[month]
-		net := md.Income - md.Expenses
-		fmt.Fprintf(&sb, "  %-10s %12s %12s %12s\n",
+		net := md.Income.Sub(md.Expenses)
+		fmt.Fprintf(&sb, "  %-10s %12s %12s %12s",
 			month,
-			FormatDecimal(md.Income, md.Denom),
-			FormatDecimal(md.Expenses, md.Denom),
-			FormatDecimal(net, md.Denom))
+			s.formatAmount(md.Income, cur.Fraction),
+			s.formatAmount(md.Expenses, cur.Fraction),
+			s.formatAmount(net, cur.Fraction))
+		for _, v := range computed[month] {
+			fmt.Fprintf(&sb, " %12s", v)
+		}
+		sb.WriteString("\n")
+	}
+	if len(monthOrder) > 1 {
+		var income, expenses, net []float64
+		for _, month := range monthOrder {
+			md := byMonth[month]
+			income = append(income, md.Income.Float64())
+			expenses = append(expenses, md.Expenses.Float64())
+			net = append(net, md.Income.Sub(md.Expenses).Float64())
+		}
+		fmt.Fprintf(&sb, "\n  %-10s %s\n", "Income", sparkline(income))
+		fmt.Fprintf(&sb, "  %-10s %s\n", "Expenses", sparkline(expenses))
+		fmt.Fprintf(&sb, "  %-10s %s\n", "Net", sparkline(net))
 	}
+	sb.WriteString(horizonNote(format, notice))
 
 	return sb.String(), nil
 }
 
-// SearchTransactions searches for transactions by description or memo.
-func (s *Service) SearchTransactions(ctx context.Context, query string, limit int) (string, error) {
+// monthsPeriod returns the period covering the last months calendar months,
+// the current one included, up to now.
+func monthsPeriod(now time.Time, months int) (string, string) {
+	return now.AddDate(0, -months+1, -now.Day()+1).Format("2006-01-02"), now.Format("2006-01-02")
+}
+
+// SearchFilter holds the criteria of a transaction search. Every non-zero
+// field must match.
+type SearchFilter struct {
+	Text           string  // substring of the description or a split memo
+	Account        string  // account name, path or GUID; includes sub-accounts
+	StartDate      string  // YYYY-MM-DD
+	EndDate        string  // YYYY-MM-DD
+	MinAmount      float64 // total of debit splits, ignored unless positive
+	MaxAmount      float64 // total of debit splits, ignored unless positive
+	ReconcileState string  // n/c/y/f/v or unreconciled/cleared/reconciled/frozen/voided
+}
+
+// reconcileStates maps reconcile state names to GnuCash's one-letter codes.
+var reconcileStates = map[string]string{
+	"n": "n", "unreconciled": "n",
+	"c": "c", "cleared": "c",
+	"y": "y", "reconciled": "y",
+	"f": "f", "frozen": "f",
+	"v": "v", "voided": "v",
+}
+
+// SearchTransactions returns the transactions matching all criteria of f.
+// When an account is given, the reconcile state applies to the account's own
+// splits. Results are sorted and paged like GetTransactions.
+func (s *Service) SearchTransactions(ctx context.Context, f SearchFilter, limit int, sortBy, order, cursor string) (string, error) {
 	if limit <= 0 {
 		limit = 20
 	}
+	if f.MinAmount < 0 || f.MaxAmount < 0 {
+		return "", fmt.Errorf("min_amount and max_amount must not be negative")
+	}
+	if f.MaxAmount > 0 && f.MinAmount > f.MaxAmount {
+		return "", fmt.Errorf("min_amount %.2f is greater than max_amount %.2f", f.MinAmount, f.MaxAmount)
+	}
+	if f == (SearchFilter{}) {
+		return "", fmt.Errorf("at least one search criterion is required")
+	}
+	startDate, notice, err := s.horizonStart(ctx, f.StartDate)
+	if err != nil {
+		return "", err
+	}
+	q, err := newTxQuery(startDate, f.EndDate, limit, sortBy, order, cursor)
+	if err != nil {
+		return "", err
+	}
+	q.Text, q.MinAmount, q.MaxAmount = f.Text, f.MinAmount, f.MaxAmount
+	if s.index != nil && f.Text != "" {
+		if _, err := s.index.Sync(ctx, s.db); err != nil {
+			return "", err
+		}
+		matches, ok, err := s.index.Match(ctx, f.Text)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			q.Matches = matches
+		}
+	}
+	if q.Order.By == SortByRelevance && q.Matches == nil {
+		return "", fmt.Errorf("sort_by relevance requires a text query and the search index")
+	}
+	if f.ReconcileState != "" {
+		state, ok := reconcileStates[strings.ToLower(f.ReconcileState)]
+		if !ok {
+			return "", fmt.Errorf("unsupported reconcile_state '%s' (expected unreconciled, cleared, reconciled, frozen or voided)", f.ReconcileState)
+		}
+		q.ReconcileStates = []string{state}
+	}
+	var account *Account
+	if f.Account != "" {
+		if account, err = s.resolveAccount(ctx, f.Account); err != nil {
+			return "", err
+		}
+		q.AccountGUIDs = descendantGUIDs(account)
+	}
 
-	transactions, err := s.db.SearchTransactions(ctx, query, limit)
+	transactions, next, err := s.db.SearchTransactions(ctx, q)
 	if err != nil {
 		return "", err
 	}
 
+	label := searchLabel(f, account)
 	if len(transactions) == 0 {
-		return fmt.Sprintf("No transactions found matching '%s'.", query), nil
+		return fmt.Sprintf("No transactions found matching %s.", label) + horizonNote(FormatText, notice), nil
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
 	}
 
 	var sb strings.Builder
-	fmt.Fprintf(&sb, "Search results for '%s' (%d found):\n\n", query, len(transactions))
+	fmt.Fprintf(&sb, "Search results for %s (%d found):\n\n", label, len(transactions))
 
 	for _, tx := range transactions {
-		fmt.Fprintf(&sb, "%s  %s\n", tx.PostDate.Format("2006-01-02"), tx.Description)
+		fmt.Fprintf(&sb, "%s  %s", tx.PostDate.Format("2006-01-02"), tx.Description)
+		if s.guids {
+			fmt.Fprintf(&sb, "  (transaction %s)", tx.GUID)
+		}
+		sb.WriteString("\n")
 		for _, sp := range tx.Splits {
-			fmt.Fprintf(&sb, "    %s: %s EUR", sp.AccountName, sp.FormatAmount())
+			fmt.Fprintf(&sb, "    %s: %s", sp.AccountName, s.formatMoney(sp.Value(), cur))
 			if sp.Memo != "" {
 				fmt.Fprintf(&sb, "  (%s)", sp.Memo)
 			}
+			if s.guids {
+				fmt.Fprintf(&sb, "  (split %s, account %s)", sp.GUID, sp.AccountGUID)
+			}
 			sb.WriteString("\n")
 		}
 		sb.WriteString("\n")
 	}
 
+	sb.WriteString(nextPageNote(FormatText, next))
+	sb.WriteString(horizonNote(FormatText, notice))
+	return sb.String(), nil
+}
+
+// RecordChartSnapshot stores the current chart of accounts in the snapshot
+// store if it changed since the last snapshot. It is a no-op without a store.
+func (s *Service) RecordChartSnapshot(ctx context.Context) error {
+	if s.snapshots == nil {
+		return nil
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.snapshots.Record(ctx, accounts, time.Now())
+	return err
+}
+
+// ChartHistory reports accounts added, removed, renamed or re-parented
+// across the recorded snapshots of the chart of accounts.
+func (s *Service) ChartHistory(ctx context.Context) (string, error) {
+	if s.snapshots == nil {
+		return "", fmt.Errorf("chart history is not enabled (set GNUCASH_SNAPSHOT_DB to a writable file)")
+	}
+	if err := s.RecordChartSnapshot(ctx); err != nil {
+		return "", err
+	}
+	since, count, changes, err := s.snapshots.History(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Chart of accounts history (%d snapshots since %s):\n\n", count, since.Format("2006-01-02"))
+	if len(changes) == 0 {
+		sb.WriteString("No changes recorded.\n")
+		return sb.String(), nil
+	}
+
+	var lastDate string
+	for _, c := range changes {
+		if date := c.At.Format("2006-01-02"); date != lastDate {
+			fmt.Fprintf(&sb, "%s\n", date)
+			lastDate = date
+		}
+		switch c.Kind {
+		case "added":
+			fmt.Fprintf(&sb, "  added    %s\n", c.New)
+		case "removed":
+			fmt.Fprintf(&sb, "  removed  %s\n", c.Old)
+		default:
+			fmt.Fprintf(&sb, "  %-8s %s -> %s\n", c.Kind, c.Old, c.New)
+		}
+	}
 	return sb.String(), nil
 }
+
+// searchLabel describes search criteria, e.g. "'rent' in Checking, amount 900.00-1100.00".
+func searchLabel(f SearchFilter, account *Account) string {
+	var parts []string
+	if f.Text != "" {
+		parts = append(parts, "'"+f.Text+"'")
+	}
+	if account != nil {
+		parts = append(parts, "in "+account.FullName)
+	}
+	switch {
+	case f.StartDate != "" && f.EndDate != "":
+		parts = append(parts, fmt.Sprintf("from %s to %s", f.StartDate, f.EndDate))
+	case f.StartDate != "":
+		parts = append(parts, "since "+f.StartDate)
+	case f.EndDate != "":
+		parts = append(parts, "until "+f.EndDate)
+	}
+	switch {
+	case f.MinAmount > 0 && f.MaxAmount > 0:
+		parts = append(parts, fmt.Sprintf("amount %.2f-%.2f", f.MinAmount, f.MaxAmount))
+	case f.MinAmount > 0:
+		parts = append(parts, fmt.Sprintf("amount >= %.2f", f.MinAmount))
+	case f.MaxAmount > 0:
+		parts = append(parts, fmt.Sprintf("amount <= %.2f", f.MaxAmount))
+	}
+	if f.ReconcileState != "" {
+		parts = append(parts, "reconcile state "+f.ReconcileState)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// nextPageNote tells the client how to fetch the next page, if any. CSV output
+// gets no note so it stays machine-readable.
+func nextPageNote(format, next string) string {
+	if next == "" || format == FormatCSV {
+		return ""
+	}
+	return fmt.Sprintf("\nMore results available. Pass cursor=%s to get the next page.\n", next)
+}
@@ -3,9 +3,16 @@ package gnucash
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"golang.org/x/text/language"
 	_ "modernc.org/sqlite"
 )
 
@@ -47,6 +54,28 @@ func setupTestDB(t *testing.T) *DB {
 			quantity_num INTEGER,
 			quantity_denom INTEGER
 		);
+		CREATE TABLE commodities (
+			guid TEXT PRIMARY KEY,
+			namespace TEXT,
+			mnemonic TEXT,
+			fullname TEXT,
+			cusip TEXT,
+			fraction INTEGER
+		);
+		CREATE TABLE prices (
+			guid TEXT PRIMARY KEY,
+			commodity_guid TEXT,
+			currency_guid TEXT,
+			date TEXT,
+			source TEXT,
+			type TEXT,
+			value_num INTEGER,
+			value_denom INTEGER
+		);
+
+		-- Commodities
+		INSERT INTO commodities VALUES ('eur',  'CURRENCY', 'EUR',  'Euro',             '978',          100);
+		INSERT INTO commodities VALUES ('acme', 'NASDAQ',   'ACME', 'ACME Corporation', 'US0000000001', 10000);
 
 		-- Root account
 		INSERT INTO accounts VALUES ('root', 'Root Account', 'ROOT', NULL, '', '', 0, 0);
@@ -62,6 +91,11 @@ func setupTestDB(t *testing.T) *DB {
 		INSERT INTO accounts VALUES ('restaurant', 'Restaurant', 'EXPENSE', 'expenses', '', '', 0, 0);
 		INSERT INTO accounts VALUES ('salary',     'Salary',     'INCOME',  'income',   '', '', 0, 0);
 
+		-- Investments
+		INSERT INTO accounts VALUES ('investments', 'Investments',    'ASSET', 'assets',      '', 'eur',  0, 1);
+		INSERT INTO accounts VALUES ('brokerage',   'Brokerage Cash', 'BANK',  'investments', '', 'eur',  0, 0);
+		INSERT INTO accounts VALUES ('acme-stock',  'ACME',           'STOCK', 'investments', '', 'acme', 0, 0);
+
 		-- Transaction 1: salary deposit of 3000.00 EUR on Jan 15
 		INSERT INTO transactions VALUES ('tx1', 'eur', '2025-01-15 00:00:00', '2025-01-15 00:00:00', 'January salary');
 		INSERT INTO splits VALUES ('sp1a', 'tx1', 'checking',  '', 300000, 100, 300000, 100);
@@ -86,6 +120,15 @@ func setupTestDB(t *testing.T) *DB {
 		INSERT INTO transactions VALUES ('tx5', 'eur', '2025-02-15 00:00:00', '2025-02-15 00:00:00', 'February salary');
 		INSERT INTO splits VALUES ('sp5a', 'tx5', 'checking',  '', 300000, 100, 300000, 100);
 		INSERT INTO splits VALUES ('sp5b', 'tx5', 'salary',    '', -300000, 100, -300000, 100);
+
+		-- Transaction 6: buy 10 ACME shares for 1000.00 EUR on Jan 10
+		INSERT INTO transactions VALUES ('tx6', 'eur', '2025-01-10 00:00:00', '2025-01-10 00:00:00', 'Buy ACME');
+		INSERT INTO splits VALUES ('sp6a', 'tx6', 'acme-stock', '', 100000, 100, 1000, 100);
+		INSERT INTO splits VALUES ('sp6b', 'tx6', 'brokerage',  '', -100000, 100, -100000, 100);
+
+		-- ACME prices
+		INSERT INTO prices VALUES ('pr1', 'acme', 'eur', '2025-01-10 00:00:00', 'user:price', 'last', 10000, 100);
+		INSERT INTO prices VALUES ('pr2', 'acme', 'eur', '2025-02-20 00:00:00', 'user:price', 'last', 12000, 100);
 	`
 	if _, err := db.Exec(schema); err != nil {
 		t.Fatalf("seed database: %v", err)
@@ -129,7 +172,7 @@ func TestGetBalance(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := svc.GetBalance(ctx, tt.account, tt.date)
+			result, err := svc.GetBalance(ctx, tt.account, tt.date, nil)
 			if err != nil {
 				t.Fatalf("GetBalance(%q, %q) returned error: %v", tt.account, tt.date, err)
 			}
@@ -145,7 +188,7 @@ func TestGetBalance_AccountNotFound(t *testing.T) {
 	svc := NewService(db)
 	ctx := context.Background()
 
-	_, err := svc.GetBalance(ctx, "Nonexistent", "")
+	_, err := svc.GetBalance(ctx, "Nonexistent", "", nil)
 	if err == nil {
 		t.Fatal("expected error for nonexistent account, got nil")
 	}
@@ -157,7 +200,7 @@ func TestGetBalance_AmbiguousAccount(t *testing.T) {
 	ctx := context.Background()
 
 	// "e" matches Expenses, Checking, Groceries, Salary, etc.
-	_, err := svc.GetBalance(ctx, "e", "")
+	_, err := svc.GetBalance(ctx, "e", "", nil)
 	if err == nil {
 		t.Fatal("expected error for ambiguous account name, got nil")
 	}
@@ -166,24 +209,306 @@ func TestGetBalance_AmbiguousAccount(t *testing.T) {
 	}
 }
 
+func TestGetBalance_IncludeChildren(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Expenses has children, so sub-accounts are included by default:
+	// groceries 127.50 + restaurant 25.00 = 152.50
+	result, err := svc.GetBalance(ctx, "Expenses", "", nil)
+	if err != nil {
+		t.Fatalf("GetBalance(Expenses) returned error: %v", err)
+	}
+	if !strings.Contains(result, "152.50 EUR") {
+		t.Errorf("expected rolled-up 152.50 EUR, got:\n%s", result)
+	}
+
+	// Explicitly disabled: Expenses has no splits of its own
+	off := false
+	result, err = svc.GetBalance(ctx, "Expenses", "", &off)
+	if err != nil {
+		t.Fatalf("GetBalance(Expenses, include_children=false) returned error: %v", err)
+	}
+	if !strings.Contains(result, "0.00 EUR") {
+		t.Errorf("expected 0.00 EUR without children, got:\n%s", result)
+	}
+}
+
+func TestGetBalance_FuzzyName(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// A small typo resolves to the single closest account
+	result, err := svc.GetBalance(ctx, "Grocerys", "", nil)
+	if err != nil {
+		t.Fatalf("GetBalance(Grocerys) returned error: %v", err)
+	}
+	if !strings.Contains(result, "Expenses:Groceries") || !strings.Contains(result, "127.50 EUR") {
+		t.Errorf("expected Groceries balance, got:\n%s", result)
+	}
+
+	// A distant name only yields suggestions
+	_, err = svc.GetBalance(ctx, "Restaurnt xyz", "", nil)
+	if err == nil || !strings.Contains(err.Error(), "did you mean Expenses:Restaurant?") {
+		t.Errorf("expected suggestion for Restaurant, got: %v", err)
+	}
+}
+
+func TestGetBalance_PathSuffixAndGUID(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Two accounts share the leaf name "Insurance"
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('auto',      'Auto',      'EXPENSE', 'expenses', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('auto-ins',  'Insurance', 'EXPENSE', 'auto',     '', '', 0, 0);
+		INSERT INTO accounts VALUES ('home',      'Home',      'EXPENSE', 'expenses', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('home-ins',  'Insurance', 'EXPENSE', 'home',     '', '', 0, 0);
+	`); err != nil {
+		t.Fatalf("seed accounts: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		account string
+		wantSub string
+	}{
+		{name: "path suffix", account: "Auto:Insurance", wantSub: "Expenses:Auto:Insurance"},
+		{name: "partial last segment", account: "home:insur", wantSub: "Expenses:Home:Insurance"},
+		{name: "partial path", account: "Expenses:Gro", wantSub: "Expenses:Groceries"},
+		{name: "guid", account: "restaurant", wantSub: "Expenses:Restaurant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.GetBalance(ctx, tt.account, "", nil)
+			if err != nil {
+				t.Fatalf("GetBalance(%q) returned error: %v", tt.account, err)
+			}
+			if !strings.Contains(result, tt.wantSub) {
+				t.Errorf("GetBalance(%q) = %q, want substring %q", tt.account, result, tt.wantSub)
+			}
+		})
+	}
+
+	_, err := svc.GetBalance(ctx, "Insurance", "", nil)
+	if err == nil || !strings.Contains(err.Error(), "multiple accounts match") {
+		t.Errorf("expected ambiguity error for bare leaf name, got: %v", err)
+	}
+}
+
 // --- ListAccounts ---
 
+func TestFormatFraction(t *testing.T) {
+	tests := []struct {
+		num, denom, fraction int64
+		want                 string
+	}{
+		{12345, 100, 100, "123.45"},
+		{12345, 1000, 100, "12.35"}, // rounded, not truncated
+		{-12345, 1000, 100, "-12.35"},
+		{-4, 1000, 100, "0.00"},
+		{5, 1000, 100, "0.01"},
+		{12345, 10, 1, "1235"},
+		{150000000, 100000000, 100000000, "1.50000000"},
+		{1, 3, 100, "0.33"},
+		{2, 3, 100, "0.67"},
+		{7, 0, 100, "0.00"},
+	}
+	for _, tt := range tests {
+		if got := FormatFraction(tt.num, tt.denom, tt.fraction); got != tt.want {
+			t.Errorf("FormatFraction(%d, %d, %d) = %q, want %q", tt.num, tt.denom, tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestNumeric(t *testing.T) {
+	// 1/3 + 1/6 + 12.5 is exactly 13.
+	sum := NewNumeric(1, 3).Add(NewNumeric(1, 6)).Add(NewNumeric(125, 10))
+	if got := sum.String(); got != "13" {
+		t.Errorf("sum = %s, want 13", got)
+	}
+	if got := NewNumeric(-1255, 1000).String(); got != "-1.255" {
+		t.Errorf("String = %s, want -1.255", got)
+	}
+	if got := NewNumeric(2, 3).String(); got != "2/3" {
+		t.Errorf("String = %s, want 2/3", got)
+	}
+	var zero Numeric
+	if zero.Sign() != 0 || zero.Format(100) != "0.00" || zero.Sub(NewNumeric(1, 2)).Format(100) != "-0.50" {
+		t.Error("the zero Numeric does not behave as zero")
+	}
+	if NewNumeric(5, 0).Sign() != 0 {
+		t.Error("a zero denominator should give zero")
+	}
+	if NewNumeric(1, 100).Cmp(NewNumeric(1, 1000)) <= 0 {
+		t.Error("Cmp: 0.01 should be greater than 0.001")
+	}
+}
+
+func TestGetBalance_MixedDenominators(t *testing.T) {
+	db := setupTestDB(t)
+	// Values in thousandths next to the fixture's cents: 5847.50 + 0.125 + 0.125.
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('txm', 'eur', '2025-02-20 10:00:00', '2025-02-20 10:00:00', 'Mixed');
+		INSERT INTO splits VALUES ('spma', 'txm', 'checking',  '', 125, 1000, 125, 1000);
+		INSERT INTO splits VALUES ('spmb', 'txm', 'checking',  '', 125, 1000, 125, 1000);
+		INSERT INTO splits VALUES ('spmc', 'txm', 'salary',    '', -25, 100, -25, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+	result, err := svc.GetBalance(context.Background(), "Checking", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "5847.75 EUR") {
+		t.Errorf("expected 5847.75 EUR, got:\n%s", result)
+	}
+}
+
+func TestGetBalance_CurrencyFraction(t *testing.T) {
+	db := setupTestDB(t)
+	if _, err := db.db.Exec(`UPDATE commodities SET mnemonic = 'JPY', fullname = 'Yen', fraction = 1 WHERE guid = 'eur'`); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(db)
+
+	result, err := svc.GetBalance(context.Background(), "Checking", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5847.50 rounds half away from zero.
+	if !strings.Contains(result, "5848 JPY") {
+		t.Errorf("expected the balance in whole yen, got:\n%s", result)
+	}
+}
+
+func TestGetAccount(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+	if _, err := db.db.Exec(`
+		ALTER TABLE accounts ADD COLUMN code TEXT;
+		UPDATE accounts SET code = '1010' WHERE guid = 'checking';
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, string_val) VALUES ('checking', 'notes', 4, 'Joint account');
+	`); err != nil {
+		t.Fatalf("add code and notes: %v", err)
+	}
+
+	result, err := svc.GetAccount(ctx, "Checking")
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	for _, want := range []string{
+		"Account: Assets:Checking\n",
+		"GUID: checking\n",
+		"Type: BANK\n",
+		"Code: 1010\n",
+		"Notes: Joint account\n",
+		"Placeholder: no\n",
+		"Parent: Assets\n",
+		"Opened: 2025-01-15\n",
+		"Transactions: 5, the last on 2025-02-",
+		"Balance: 5847.50 EUR\n",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q, got:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.GetAccount(ctx, "Expenses")
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	for _, want := range []string{"Children: ", "Transactions: none\n", "Balance with sub-accounts: 152.50 EUR\n"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q, got:\n%s", want, result)
+		}
+	}
+}
+
 func TestListAccounts(t *testing.T) {
 	db := setupTestDB(t)
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.ListAccounts(ctx, "")
+	result, err := svc.ListAccounts(ctx, "", 0)
 	if err != nil {
 		t.Fatalf("ListAccounts() returned error: %v", err)
 	}
 
-	// Should contain all non-root accounts with full paths
-	for _, want := range []string{"Assets:Checking", "Expenses:Groceries", "Expenses:Restaurant", "Income:Salary"} {
+	// Top-level accounts at depth 0, children indented beneath them
+	for _, want := range []string{"Assets\t", "\n  Checking\t", "Expenses\t", "\n  Groceries\t", "\n  Restaurant\t", "Income\t", "\n  Salary\t"} {
 		if !strings.Contains(result, want) {
 			t.Errorf("ListAccounts() missing %q in:\n%s", want, result)
 		}
 	}
+	// Expenses subtotal rolls up groceries 127.50 + restaurant 25.00
+	if !strings.Contains(result, "(subtotal 152.50)") {
+		t.Errorf("ListAccounts() missing Expenses subtotal in:\n%s", result)
+	}
+}
+
+func TestListAccounts_TypeAlias(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.ListAccounts(ctx, "Dépenses", 0)
+	if err != nil {
+		t.Fatalf("ListAccounts(Dépenses) returned error: %v", err)
+	}
+	if !strings.Contains(result, "Groceries") || strings.Contains(result, "Checking") {
+		t.Errorf("expected only expense accounts, got:\n%s", result)
+	}
+
+	if _, err := svc.ListAccounts(ctx, "gizmo", 0); err == nil || !strings.Contains(err.Error(), "unknown account type") {
+		t.Errorf("expected unknown account type error, got %v", err)
+	}
+}
+
+func TestNormalizeAccountType(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"bank":          "BANK",
+		"Chequing":      "BANK",
+		"dépenses":      "EXPENSE",
+		"liability":     "LIABILITY",
+		"debt":          "LIABILITY",
+		" Credit  Card": "CREDIT",
+		"Einnahmen":     "INCOME",
+		"mutual funds":  "MUTUAL",
+		"EQUITY":        "EQUITY",
+	}
+	for in, want := range tests {
+		got, err := NormalizeAccountType(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeAccountType(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestListAccounts_MaxDepth(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.ListAccounts(ctx, "", 1)
+	if err != nil {
+		t.Fatalf("ListAccounts(max_depth=1) returned error: %v", err)
+	}
+
+	if strings.Contains(result, "Groceries") {
+		t.Errorf("max_depth=1 should hide child accounts, got:\n%s", result)
+	}
+	if !strings.Contains(result, "(subtotal 152.50)") {
+		t.Errorf("subtotals should still include hidden levels, got:\n%s", result)
+	}
 }
 
 func TestListAccounts_FilterByType(t *testing.T) {
@@ -191,7 +516,7 @@ func TestListAccounts_FilterByType(t *testing.T) {
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.ListAccounts(ctx, "EXPENSE")
+	result, err := svc.ListAccounts(ctx, "EXPENSE", 0)
 	if err != nil {
 		t.Fatalf("ListAccounts(EXPENSE) returned error: %v", err)
 	}
@@ -211,7 +536,7 @@ func TestGetTransactions(t *testing.T) {
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.GetTransactions(ctx, "Checking", "2025-01-01", "2025-01-31", 50)
+	result, err := svc.GetTransactions(ctx, "Checking", "2025-01-01", "2025-01-31", 50, "", "", "", "")
 	if err != nil {
 		t.Fatalf("GetTransactions() returned error: %v", err)
 	}
@@ -233,7 +558,7 @@ func TestGetTransactions_Limit(t *testing.T) {
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.GetTransactions(ctx, "Checking", "", "", 2)
+	result, err := svc.GetTransactions(ctx, "Checking", "", "", 2, "", "", "", "")
 	if err != nil {
 		t.Fatalf("GetTransactions(limit=2) returned error: %v", err)
 	}
@@ -243,12 +568,46 @@ func TestGetTransactions_Limit(t *testing.T) {
 	}
 }
 
+func TestGetTransactions_Cursor(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Checking has 5 transactions: page through them two at a time.
+	var pages []string
+	cursor := ""
+	for range 3 {
+		result, err := svc.GetTransactions(ctx, "Checking", "", "", 2, "", "", cursor, "")
+		if err != nil {
+			t.Fatalf("GetTransactions(cursor=%q) returned error: %v", cursor, err)
+		}
+		pages = append(pages, result)
+		_, after, ok := strings.Cut(result, "cursor=")
+		if !ok {
+			break
+		}
+		cursor, _, _ = strings.Cut(after, " ")
+	}
+
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(pages))
+	}
+	for i, want := range []string{"February salary", "Pizza place", "January salary"} {
+		if !strings.Contains(pages[i], want) {
+			t.Errorf("page %d missing %q:\n%s", i+1, want, pages[i])
+		}
+	}
+	if strings.Contains(pages[2], "More results available") {
+		t.Errorf("last page should not have a cursor:\n%s", pages[2])
+	}
+}
+
 func TestGetTransactions_NoResults(t *testing.T) {
 	db := setupTestDB(t)
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.GetTransactions(ctx, "Checking", "2020-01-01", "2020-12-31", 50)
+	result, err := svc.GetTransactions(ctx, "Checking", "2020-01-01", "2020-12-31", 50, "", "", "", "")
 	if err != nil {
 		t.Fatalf("GetTransactions() returned error: %v", err)
 	}
@@ -258,6 +617,159 @@ func TestGetTransactions_NoResults(t *testing.T) {
 	}
 }
 
+func TestGetTransactions_CSV(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.GetTransactions(ctx, "Groceries", "", "", 50, "", "", "", "csv")
+	if err != nil {
+		t.Fatalf("GetTransactions(csv) returned error: %v", err)
+	}
+
+	want := "date,description,amount,currency,counterparts,counterpart_amounts,transaction_guid,split_guid\n" +
+		"2025-02-05,Market,42.00,EUR,Checking,-42.00,tx3,sp3b\n2025-01-20,Supermarket,85.50,EUR,Checking,-85.50,tx2,sp2b\n"
+	if result != want {
+		t.Errorf("GetTransactions(csv) = %q, want %q", result, want)
+	}
+
+	if _, err := svc.GetTransactions(ctx, "Groceries", "", "", 50, "", "", "", "xlsx"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestShowGUIDs(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.GetTransactions(ctx, "Groceries", "", "", 50, "", "", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if strings.Contains(result, "sp3b") {
+		t.Errorf("expected no GUIDs by default, got:\n%s", result)
+	}
+
+	svc = svc.With(WithGUIDs())
+	result, err = svc.GetTransactions(ctx, "Groceries", "", "", 50, "", "", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if !strings.Contains(result, "Market  [Checking]  (transaction tx3, split sp3b)\n") {
+		t.Errorf("expected the transaction and split GUIDs, got:\n%s", result)
+	}
+	result, err = svc.SearchTransactions(ctx, SearchFilter{Text: "Pizza"}, 0, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions: %v", err)
+	}
+	for _, want := range []string{"Pizza place  (transaction tx4)\n", "(split sp4b, account restaurant)\n"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q, got:\n%s", want, result)
+		}
+	}
+	result, err = svc.ListAccounts(ctx, "BANK", 0)
+	if err != nil {
+		t.Fatalf("ListAccounts: %v", err)
+	}
+	if !strings.Contains(result, "\tchecking\n") {
+		t.Errorf("expected the account GUIDs, got:\n%s", result)
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+	if _, err := db.db.Exec(`
+		ALTER TABLE transactions ADD COLUMN num TEXT;
+		ALTER TABLE splits ADD COLUMN action TEXT;
+		ALTER TABLE splits ADD COLUMN reconcile_state TEXT;
+		ALTER TABLE splits ADD COLUMN reconcile_date TEXT;
+		UPDATE transactions SET num = '1042' WHERE guid = 'tx6';
+		UPDATE splits SET action = 'Buy', reconcile_state = 'n' WHERE guid = 'sp6a';
+		UPDATE splits SET reconcile_state = 'y', reconcile_date = '2025-01-31 10:59:00' WHERE guid = 'sp6b';
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, string_val) VALUES
+			('tx6', 'notes', 4, 'Limit order'),
+			('tx6', 'assoc_uri', 4, 'file:///docs/acme-confirmation.pdf');
+	`); err != nil {
+		t.Fatalf("add transaction details: %v", err)
+	}
+
+	result, err := svc.GetTransaction(ctx, "tx6")
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+	for _, want := range []string{
+		"Transaction: tx6\n",
+		"Date: 2025-01-10\n",
+		"Num: 1042\n",
+		"Description: Buy ACME\n",
+		"Currency: EUR\n",
+		"Notes: Limit order\n",
+		"Document link: file:///docs/acme-confirmation.pdf\n",
+		"Assets:Investments:ACME: 1000.00 EUR (10",
+		"    Action: Buy\n    Reconcile state: unreconciled\n",
+		"Assets:Investments:Brokerage Cash: -1000.00 EUR\n",
+		"Reconcile state: reconciled on 2025-01-31\n",
+		"Split GUID: sp6b\n",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q, got:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.GetTransaction(ctx, "missing"); err == nil {
+		t.Error("expected an error for an unknown GUID")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	db := setupTestDB(t)
+	db.EnableRedaction()
+	svc := NewService(db)
+	ctx := context.Background()
+	if _, err := db.db.Exec(`
+		UPDATE splits SET memo = 'Birthday cake' WHERE guid = 'sp2b';
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, string_val) VALUES ('tx2', 'notes', 4, 'For Alice');
+	`); err != nil {
+		t.Fatalf("add memo and notes: %v", err)
+	}
+
+	listed, err := svc.GetTransactions(ctx, "Checking", "2025-01-01", "2025-01-31", 50, "", "", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions() returned error: %v", err)
+	}
+	// The stored text is still searched, but not shown.
+	found, err := svc.SearchTransactions(ctx, SearchFilter{Text: "birthday"}, 20, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions() returned error: %v", err)
+	}
+	shown, err := svc.GetTransaction(ctx, "tx2")
+	if err != nil {
+		t.Fatalf("GetTransaction() returned error: %v", err)
+	}
+	for name, result := range map[string]string{"GetTransactions": listed, "SearchTransactions": found, "GetTransaction": shown} {
+		for _, hidden := range []string{"January salary", "Supermarket", "Pizza place", "Birthday cake", "For Alice"} {
+			if strings.Contains(result, hidden) {
+				t.Errorf("%s shows %q:\n%s", name, hidden, result)
+			}
+		}
+		if !strings.Contains(result, "[redacted]") || !strings.Contains(result, "85.50") {
+			t.Errorf("%s: expected the redacted transaction with its amount, got:\n%s", name, result)
+		}
+	}
+	for _, want := range []string{"Description: [redacted]\n", "Notes: [redacted]\n", "Memo: [redacted]\n"} {
+		if !strings.Contains(shown, want) {
+			t.Errorf("GetTransaction(): expected %q, got:\n%s", want, shown)
+		}
+	}
+}
+
 // --- SpendingByCategory ---
 
 func TestSpendingByCategory(t *testing.T) {
@@ -265,7 +777,7 @@ func TestSpendingByCategory(t *testing.T) {
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "")
+	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "", "", "")
 	if err != nil {
 		t.Fatalf("SpendingByCategory() returned error: %v", err)
 	}
@@ -287,6 +799,10 @@ func TestSpendingByCategory(t *testing.T) {
 	if !strings.Contains(result, "152.50") {
 		t.Errorf("expected grand total 152.50, got:\n%s", result)
 	}
+	// Bars are scaled to Groceries: 25.00 / 127.50 of 20 characters
+	if !strings.Contains(result, "████████████████████  (2 transactions)") || !strings.Contains(result, "███▉                  (1 transactions)") {
+		t.Errorf("expected bars scaled to Groceries, got:\n%s", result)
+	}
 }
 
 func TestSpendingByCategory_FilterByParent(t *testing.T) {
@@ -295,7 +811,7 @@ func TestSpendingByCategory_FilterByParent(t *testing.T) {
 	ctx := context.Background()
 
 	// Filter by "Expenses" parent — both Groceries and Restaurant are direct children
-	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "Expenses")
+	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "Expenses", "", "", "", "")
 	if err != nil {
 		t.Fatalf("SpendingByCategory(parent=Expenses) returned error: %v", err)
 	}
@@ -310,7 +826,7 @@ func TestSpendingByCategory_NoExpenses(t *testing.T) {
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.SpendingByCategory(ctx, "2020-01-01", "2020-12-31", "")
+	result, err := svc.SpendingByCategory(ctx, "2020-01-01", "2020-12-31", "", "", "", "", "")
 	if err != nil {
 		t.Fatalf("SpendingByCategory() returned error: %v", err)
 	}
@@ -320,63 +836,220 @@ func TestSpendingByCategory_NoExpenses(t *testing.T) {
 	}
 }
 
-// --- IncomeVsExpenses ---
-
-func TestIncomeVsExpenses(t *testing.T) {
+func TestSpendingByCategory_CSV(t *testing.T) {
 	db := setupTestDB(t)
 	svc := NewService(db)
 	ctx := context.Background()
 
-	// Use enough months to cover our fixture data (Jan-Feb 2025)
-	result, err := svc.IncomeVsExpenses(ctx, 24)
+	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "", "", "csv")
 	if err != nil {
-		t.Fatalf("IncomeVsExpenses() returned error: %v", err)
+		t.Fatalf("SpendingByCategory(csv) returned error: %v", err)
 	}
 
-	// January: income 3000, expenses 85.50 + 25.00 = 110.50
-	if !strings.Contains(result, "2025-01") {
-		t.Errorf("expected 2025-01 in output, got:\n%s", result)
-	}
-	// February: income 3000, expenses 42.00
-	if !strings.Contains(result, "2025-02") {
-		t.Errorf("expected 2025-02 in output, got:\n%s", result)
-	}
-	// Should have column headers
-	if !strings.Contains(result, "Income") || !strings.Contains(result, "Expenses") || !strings.Contains(result, "Net") {
-		t.Errorf("expected column headers, got:\n%s", result)
+	want := "category,total,count\nGroceries,127.50,2\nRestaurant,25.00,1\n"
+	if result != want {
+		t.Errorf("SpendingByCategory(csv) = %q, want %q", result, want)
 	}
 }
 
-// --- SearchTransactions ---
-
-func TestSearchTransactions(t *testing.T) {
+func TestSpendingByCategory_Currencies(t *testing.T) {
 	db := setupTestDB(t)
 	svc := NewService(db)
 	ctx := context.Background()
 
-	result, err := svc.SearchTransactions(ctx, "salary", 20)
-	if err != nil {
-		t.Fatalf("SearchTransactions() returned error: %v", err)
+	// A trip paid in dollars from a dollar account: 120.00 USD of travel.
+	if _, err := db.db.Exec(`
+		INSERT INTO commodities VALUES ('usd', 'CURRENCY', 'USD', 'US Dollar', '840', 100);
+		INSERT INTO accounts VALUES ('usd-bank', 'Dollar Account', 'BANK',    'assets',   '', 'usd', 0, 0);
+		INSERT INTO accounts VALUES ('travel',   'Travel',         'EXPENSE', 'expenses', '', 'usd', 0, 0);
+		INSERT INTO transactions VALUES ('txu', 'usd', '2025-02-05 00:00:00', '2025-02-05 00:00:00', 'Hotel');
+		INSERT INTO splits VALUES ('spua', 'txu', 'travel',   '', 12000, 100, 12000, 100);
+		INSERT INTO splits VALUES ('spub', 'txu', 'usd-bank', '', -12000, 100, -12000, 100);
+		INSERT INTO prices VALUES ('pru', 'eur', 'usd', '2025-02-01 00:00:00', 'user:price', 'last', 125, 100);
+	`); err != nil {
+		t.Fatal(err)
 	}
 
-	if !strings.Contains(result, "January salary") {
-		t.Errorf("expected 'January salary' in results, got:\n%s", result)
+	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !strings.Contains(result, "February salary") {
-		t.Errorf("expected 'February salary' in results, got:\n%s", result)
+	for _, want := range []string{"EUR (Euro)", "Subtotal EUR", "152.50 EUR", "USD (US Dollar)", "120.00 USD", "Subtotal USD", "not added up"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
 	}
-	// Each result should show splits with account names
-	if !strings.Contains(result, "Checking") || !strings.Contains(result, "Salary") {
-		t.Errorf("expected split details with account names, got:\n%s", result)
+	if strings.Index(result, "Subtotal EUR") > strings.Index(result, "Travel") {
+		t.Errorf("expected the book currency first:\n%s", result)
 	}
-}
 
-func TestSearchTransactions_NoMatch(t *testing.T) {
-	db := setupTestDB(t)
-	svc := NewService(db)
-	ctx := context.Background()
+	// 1 EUR = 1.25 USD, so 120.00 USD = 96.00 EUR.
+	result, err = svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "EUR", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "TOTAL in EUR                       248.50 EUR") || !strings.Contains(result, "1 USD = 0.8 EUR") {
+		t.Errorf("expected a converted total of 248.50 EUR:\n%s", result)
+	}
+
+	result, err = svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "", "", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "category,total,count,currency\nGroceries,127.50,2,EUR\nRestaurant,25.00,1,EUR\nTravel,120.00,1,USD\n"; result != want {
+		t.Errorf("csv = %q, want %q", result, want)
+	}
+
+	if _, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-01-31", "", "", "USD", "", ""); err == nil {
+		t.Error("expected an error without an exchange rate on or before the end date")
+	}
+}
+
+func TestExchangeRates(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// 1 USD = 0.80 EUR on Jan 1, then 1 EUR = 1.00 USD on Mar 2.
+	if _, err := db.db.Exec(`
+		INSERT INTO commodities VALUES ('usd', 'CURRENCY', 'USD', 'US Dollar', '840', 100);
+		INSERT INTO prices VALUES ('pu1', 'usd', 'eur', '2025-01-01 00:00:00', 'user:price', 'last', 80, 100);
+		INSERT INTO prices VALUES ('pu2', 'eur', 'usd', '2025-03-02 00:00:00', 'user:price', 'last', 100, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.ExchangeRates(ctx, "USD", "EUR", "", "2025-03-31", "", "", 0, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "date,rate,source,stale\n2025-01-01,0.8,recorded,false\n2025-03-02,1,recorded,false\n"; result != want {
+		t.Errorf("recorded rates = %q, want %q", result, want)
+	}
+
+	result, err = svc.ExchangeRates(ctx, "USD", "EUR", "", "2025-03-31", "", "", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "no rate for 60 days between 2025-01-01 and 2025-03-02") {
+		t.Errorf("expected a gap warning:\n%s", result)
+	}
+
+	// Jan 31 is 30 days into the 60-day gap: halfway from 0.80 to 1.
+	result, err = svc.ExchangeRates(ctx, "USD", "EUR", "", "2025-03-31", InterpolateLinear, StepMonth, 0, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"2025-01-31,0.9,between 2025-01-01 and 2025-03-02,false",
+		"2025-02-28,0.993333,between 2025-01-01 and 2025-03-02,false",
+		"2025-03-31,1,rate of 2025-03-02,false",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.ExchangeRates(ctx, "EUR", "USD", "2025-01-01", "2025-03-01", InterpolatePrevious, StepMonth, 20, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "2025-02-28,1.25,rate of 2025-01-01,true") {
+		t.Errorf("expected a stale inverted rate on Feb 28:\n%s", result)
+	}
+
+	if _, err := svc.ExchangeRates(ctx, "USD", "EUR", "", "", "spline", "", 0, ""); err == nil {
+		t.Error("expected an error for an unsupported interpolation")
+	}
+	if _, err := svc.ExchangeRates(ctx, "USD", "XYZ", "", "", "", "", 0, ""); err == nil {
+		t.Error("expected an error for an unknown currency")
+	}
+}
+
+// --- IncomeVsExpenses ---
+
+func TestIncomeVsExpenses(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Use enough months to cover our fixture data (Jan-Feb 2025)
+	result, err := svc.IncomeVsExpenses(ctx, 24, "", "")
+	if err != nil {
+		t.Fatalf("IncomeVsExpenses() returned error: %v", err)
+	}
+
+	// January: income 3000, expenses 85.50 + 25.00 = 110.50
+	if !strings.Contains(result, "2025-01") {
+		t.Errorf("expected 2025-01 in output, got:\n%s", result)
+	}
+	// February: income 3000, expenses 42.00
+	if !strings.Contains(result, "2025-02") {
+		t.Errorf("expected 2025-02 in output, got:\n%s", result)
+	}
+	// Should have column headers
+	if !strings.Contains(result, "Income") || !strings.Contains(result, "Expenses") || !strings.Contains(result, "Net") {
+		t.Errorf("expected column headers, got:\n%s", result)
+	}
+	// Sparklines: expenses fall from January to February, net rises
+	if !strings.Contains(result, "Expenses   █▁") || !strings.Contains(result, "Net        ▁█") {
+		t.Errorf("expected sparklines, got:\n%s", result)
+	}
+}
+
+func TestBarAndSparkline(t *testing.T) {
+	for _, tt := range []struct {
+		value, max float64
+		want       string
+	}{
+		{100, 100, strings.Repeat("█", 20)},
+		{50, 100, strings.Repeat("█", 10) + strings.Repeat(" ", 10)},
+		{1, 32, "▋" + strings.Repeat(" ", 19)},
+		{0, 100, strings.Repeat(" ", 20)},
+		{-5, 100, strings.Repeat(" ", 20)},
+	} {
+		if got := bar(tt.value, tt.max); got != tt.want {
+			t.Errorf("bar(%v, %v) = %q, want %q", tt.value, tt.max, got, tt.want)
+		}
+	}
+	if got := sparkline([]float64{-10, 0, 20, 60}); got != "▁▂▄█" {
+		t.Errorf("sparkline = %q, want ▁▂▄█", got)
+	}
+	if got := sparkline([]float64{3, 3}); got != "▅▅" {
+		t.Errorf("sparkline of equal values = %q, want ▅▅", got)
+	}
+}
+
+// --- SearchTransactions ---
+
+func TestSearchTransactions(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.SearchTransactions(ctx, SearchFilter{Text: "salary"}, 20, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions() returned error: %v", err)
+	}
+
+	if !strings.Contains(result, "January salary") {
+		t.Errorf("expected 'January salary' in results, got:\n%s", result)
+	}
+	if !strings.Contains(result, "February salary") {
+		t.Errorf("expected 'February salary' in results, got:\n%s", result)
+	}
+	// Each result should show splits with account names
+	if !strings.Contains(result, "Checking") || !strings.Contains(result, "Salary") {
+		t.Errorf("expected split details with account names, got:\n%s", result)
+	}
+}
+
+func TestSearchTransactions_NoMatch(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
 
-	result, err := svc.SearchTransactions(ctx, "nonexistent_xyz", 20)
+	result, err := svc.SearchTransactions(ctx, SearchFilter{Text: "nonexistent_xyz"}, 20, "", "", "")
 	if err != nil {
 		t.Fatalf("SearchTransactions() returned error: %v", err)
 	}
@@ -392,7 +1065,7 @@ func TestSearchTransactions_Limit(t *testing.T) {
 	ctx := context.Background()
 
 	// "a" matches most descriptions — limit to 1
-	result, err := svc.SearchTransactions(ctx, "a", 1)
+	result, err := svc.SearchTransactions(ctx, SearchFilter{Text: "a"}, 1, "", "", "")
 	if err != nil {
 		t.Fatalf("SearchTransactions(limit=1) returned error: %v", err)
 	}
@@ -410,7 +1083,7 @@ func TestGetBalance_FullPath(t *testing.T) {
 	ctx := context.Background()
 
 	// Use colon-separated full path to resolve unambiguously
-	result, err := svc.GetBalance(ctx, "Expenses:Groceries", "")
+	result, err := svc.GetBalance(ctx, "Expenses:Groceries", "", nil)
 	if err != nil {
 		t.Fatalf("GetBalance with full path returned error: %v", err)
 	}
@@ -419,3 +1092,2348 @@ func TestGetBalance_FullPath(t *testing.T) {
 		t.Errorf("expected 127.50 EUR, got:\n%s", result)
 	}
 }
+
+// --- Computed expressions ---
+
+func TestIncomeVsExpenses_Expressions(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db, WithExpressions())
+	ctx := context.Background()
+
+	result, err := svc.IncomeVsExpenses(ctx, 24, "rate = net / income", "")
+	if err != nil {
+		t.Fatalf("IncomeVsExpenses() returned error: %v", err)
+	}
+
+	// January: (3000 - 110.50) / 3000 = 0.96
+	if !strings.Contains(result, "rate") || !strings.Contains(result, "0.96") {
+		t.Errorf("expected computed rate column, got:\n%s", result)
+	}
+}
+
+func TestSpendingByCategory_ExpressionsDisabled(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	_, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "", "avg = total / count", "")
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected disabled error, got: %v", err)
+	}
+}
+
+// --- ChartHistory ---
+
+func TestChartHistory(t *testing.T) {
+	db := setupTestDB(t)
+	store, err := OpenSnapshotStore(filepath.Join(t.TempDir(), "snapshots.db"))
+	if err != nil {
+		t.Fatalf("OpenSnapshotStore() returned error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	svc := NewService(db, WithSnapshotStore(store))
+	ctx := context.Background()
+
+	if err := svc.RecordChartSnapshot(ctx); err != nil {
+		t.Fatalf("RecordChartSnapshot() returned error: %v", err)
+	}
+	if _, err := db.db.Exec(`
+		UPDATE accounts SET name = 'Dining' WHERE guid = 'restaurant';
+		UPDATE accounts SET parent_guid = 'restaurant' WHERE guid = 'groceries';
+	`); err != nil {
+		t.Fatalf("modify chart: %v", err)
+	}
+
+	result, err := svc.ChartHistory(ctx)
+	if err != nil {
+		t.Fatalf("ChartHistory() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"2 snapshots",
+		"renamed  Expenses:Restaurant -> Expenses:Dining",
+		"moved    Expenses:Groceries -> Expenses:Dining:Groceries",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("ChartHistory() missing %q in:\n%s", want, result)
+		}
+	}
+}
+
+// --- IdleCash ---
+
+func TestIdleCash(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Checking stays above 1000 from Jan 15 to Feb 28 (45 days);
+	// excess-days total 126697, at 3.65% that is 12.67 of interest.
+	result, err := svc.IdleCash(ctx, "2025-01-01", "2025-02-28", 1000, 10, 3.65)
+	if err != nil {
+		t.Fatalf("IdleCash() returned error: %v", err)
+	}
+	for _, want := range []string{"Assets:Checking", "45 idle days", "foregone interest 12.67"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("IdleCash() missing %q in:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "Brokerage Cash") {
+		t.Errorf("Brokerage Cash never exceeds the buffer, got:\n%s", result)
+	}
+}
+
+func TestWaterfall(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	w, err := svc.Waterfall(ctx, "2025-01-01", "2025-02-28", "", 0)
+	if err != nil {
+		t.Fatalf("Waterfall returned error: %v", err)
+	}
+
+	want := []WaterfallStep{
+		{Label: "Income", Kind: "income", Amount: 6000, Start: 0, End: 6000},
+		{Label: "Groceries", Kind: "expense", Amount: -127.5, Start: 6000, End: 5872.5},
+		{Label: "Restaurant", Kind: "expense", Amount: -25, Start: 5872.5, End: 5847.5},
+		{Label: "Net", Kind: "net", Amount: 5847.5, Start: 0, End: 5847.5},
+	}
+	if !slices.Equal(w.Steps, want) {
+		t.Errorf("steps = %+v, want %+v", w.Steps, want)
+	}
+	if !strings.Contains(w.String(), "NET") {
+		t.Errorf("expected NET line in text:\n%s", w.String())
+	}
+
+	// With a single expense step, everything is folded into Other.
+	w, err = svc.Waterfall(ctx, "2025-01-01", "2025-02-28", "", 1)
+	if err != nil {
+		t.Fatalf("Waterfall returned error: %v", err)
+	}
+	if len(w.Steps) != 3 || w.Steps[1].Label != "Other" || w.Steps[1].Amount != -152.5 {
+		t.Errorf("expected a single Other step of -152.50, got %+v", w.Steps)
+	}
+}
+
+func TestCounterpartySummary(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.CounterpartySummary(ctx, "Checking", "2025-01-01", "2025-02-28", 0, "csv")
+	if err != nil {
+		t.Fatalf("CounterpartySummary returned error: %v", err)
+	}
+	want := "counterparty,type,inflow,outflow,net,splits\n" +
+		"Income:Salary,INCOME,6000.00,0.00,6000.00,2\n" +
+		"Expenses:Groceries,EXPENSE,0.00,127.50,-127.50,2\n" +
+		"Expenses:Restaurant,EXPENSE,0.00,25.00,-25.00,1\n"
+	if result != want {
+		t.Errorf("CounterpartySummary(csv) = %q, want %q", result, want)
+	}
+
+	result, err = svc.CounterpartySummary(ctx, "Checking", "2025-02-01", "2025-02-28", 1, "")
+	if err != nil {
+		t.Fatalf("CounterpartySummary returned error: %v", err)
+	}
+	for _, want := range []string{"Income:Salary", "Other (1 more)", "Largest source: Income:Salary (100% of the money in)"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+}
+
+func TestSpendingSeasonality(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+	// 50.00 of groceries a month from March, 200.00 in November.
+	for m := 3; m <= 12; m++ {
+		amount := 5000
+		if m == 11 {
+			amount = 20000
+		}
+		tx, date := fmt.Sprintf("g%02d", m), fmt.Sprintf("2025-%02d-10 10:59:00", m)
+		for _, stmt := range []struct {
+			query string
+			args  []any
+		}{
+			{`INSERT INTO transactions VALUES (?, 'eur', ?, ?, 'Groceries')`, []any{tx, date, date}},
+			{`INSERT INTO splits VALUES (?, ?, 'checking', '', ?, 100, ?, 100)`, []any{tx + "a", tx, -amount, -amount}},
+			{`INSERT INTO splits VALUES (?, ?, 'groceries', '', ?, 100, ?, 100)`, []any{tx + "b", tx, amount, amount}},
+		} {
+			if _, err := db.db.Exec(stmt.query, stmt.args...); err != nil {
+				t.Fatalf("insert groceries: %v", err)
+			}
+		}
+	}
+
+	result, err := svc.SpendingSeasonality(ctx, "Groceries", "2025-12-31", 12, "csv")
+	if err != nil {
+		t.Fatalf("SpendingSeasonality returned error: %v", err)
+	}
+	for _, want := range []string{
+		"month,total,avg_3m,avg_6m,avg_12m,seasonal_avg,seasonal_index,spike\n",
+		"2025-01,85.50,,,,85.50,1.32,\n",
+		"2025-03,50.00,59.17,,,50.00,0.77,\n",
+		"2025-11,200.00,",
+		",yes\n",
+		// (85.50 + 42 + 8 × 50 + 200 + 50) / 12 = 64.79
+		"2025-12,50.00,100.00,75.00,64.79,",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+	if strings.Count(result, "yes") != 1 {
+		t.Errorf("expected a single spike, got:\n%s", result)
+	}
+
+	result, err = svc.SpendingSeasonality(ctx, "Groceries", "2025-12-31", 3, "")
+	if err != nil {
+		t.Fatalf("SpendingSeasonality returned error: %v", err)
+	}
+	for _, want := range []string{"2025-10", "One-off spikes: 2025-11 (200.00", "Trend: rising", "Nov"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+}
+
+func TestPeriodDiff(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.PeriodDiff(ctx, "2025-01-01", "2025-01-31", "2025-02-01", "2025-02-28", "", 0, "csv")
+	if err != nil {
+		t.Fatalf("PeriodDiff returned error: %v", err)
+	}
+	want := "line,type,base,period,change,change_pct\n" +
+		"Income,,3000.00,3000.00,0.00,0.0\n" +
+		"Expenses,,110.50,42.00,-68.50,-62.0\n" +
+		"Net,,2889.50,2958.00,68.50,2.4\n" +
+		"Expenses:Groceries,EXPENSE,85.50,42.00,-43.50,-50.9\n" +
+		"Expenses:Restaurant,EXPENSE,25.00,0.00,-25.00,-100.0\n"
+	if result != want {
+		t.Errorf("PeriodDiff(csv) = %q, want %q", result, want)
+	}
+
+	result, err = svc.PeriodDiff(ctx, "2025-01-01", "2025-01-31", "2025-02-01", "2025-02-28", "", 1, "")
+	if err != nil {
+		t.Fatalf("PeriodDiff returned error: %v", err)
+	}
+	for _, want := range []string{"2025-02-01 to 2025-02-28 compared with 2025-01-01 to 2025-01-31", "differ in length: 28 days against 31", "-62%"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "Restaurant") {
+		t.Errorf("expected a single category change, got:\n%s", result)
+	}
+
+	if _, err := svc.PeriodDiff(ctx, "2025-01-01", "", "2025-02-01", "2025-02-28", "", 0, ""); err == nil {
+		t.Error("expected an error without compare_end_date")
+	}
+}
+
+func TestInflationAdjustment(t *testing.T) {
+	db := setupTestDB(t)
+	path := filepath.Join(t.TempDir(), "cpi.csv")
+	if err := os.WriteFile(path, []byte("period,level\n2024,100\n2025-01,110\n2025-02,120\n"), 0o600); err != nil {
+		t.Fatalf("write price index: %v", err)
+	}
+	index, err := LoadPriceIndex(path)
+	if err != nil {
+		t.Fatalf("LoadPriceIndex() returned error: %v", err)
+	}
+	svc := NewService(db, WithPriceIndex(index))
+	ctx := context.Background()
+
+	// Today is past the index, so it takes its last level: January amounts
+	// are raised by 120/110 and February ones are unchanged.
+	result, err := svc.With(WithInflationAdjustment(nil)).PeriodDiff(ctx, "2025-01-01", "2025-01-31", "2025-02-01", "2025-02-28", "", 0, "csv")
+	if err != nil {
+		t.Fatalf("PeriodDiff returned error: %v", err)
+	}
+	for _, want := range []string{"Income,,3272.73,3000.00,", "Expenses:Groceries,EXPENSE,93.27,42.00,"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+	result, err = svc.With(WithInflationAdjustment(nil)).SpendingSeasonality(ctx, "Groceries", "2025-02-28", 2, "")
+	if err != nil {
+		t.Fatalf("SpendingSeasonality returned error: %v", err)
+	}
+	for _, want := range []string{"Amounts restated in", "93.27", "42.00"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	// A series of yearly rates replaces the configured index.
+	series, err := ParseInflationSeries("2024:5, 2025:10")
+	if err != nil {
+		t.Fatalf("ParseInflationSeries() returned error: %v", err)
+	}
+	result, err = NewService(db, WithInflationAdjustment(series)).PeriodDiff(ctx, "2025-01-01", "2025-01-31", "2025-02-01", "2025-02-28", "", 0, "csv")
+	if err != nil {
+		t.Fatalf("PeriodDiff returned error: %v", err)
+	}
+	if !strings.Contains(result, "Income,,3000.00,3000.00,") {
+		t.Errorf("expected unchanged amounts within the last year of the series:\n%s", result)
+	}
+
+	if _, err := NewService(db, WithInflationAdjustment(nil)).PeriodDiff(ctx, "2025-01-01", "2025-01-31", "2025-02-01", "2025-02-28", "", 0, ""); err == nil {
+		t.Error("expected an error without a price index")
+	}
+	for _, bad := range []string{"", "2024", "2024:abc", "x:2"} {
+		if _, err := ParseInflationSeries(bad); err == nil {
+			t.Errorf("ParseInflationSeries(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestSpendingByCategory_Groups(t *testing.T) {
+	db := setupTestDB(t)
+	path := filepath.Join(t.TempDir(), "groups.json")
+	if err := os.WriteFile(path, []byte(`{"Essentials": ["groceries"], "Fun": ["Expenses:Restaurant"]}`), 0o600); err != nil {
+		t.Fatalf("write groups: %v", err)
+	}
+	groups, err := LoadCategoryGroups(path)
+	if err != nil {
+		t.Fatalf("LoadCategoryGroups() returned error: %v", err)
+	}
+	svc := NewService(db, WithCategoryGroups(groups))
+	ctx := context.Background()
+
+	result, err := svc.SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", GroupByGroup, "", "", "")
+	if err != nil {
+		t.Fatalf("SpendingByCategory returned error: %v", err)
+	}
+	for _, want := range []string{"Essentials", "127.50", "(2 transactions)", "Fun", "25.00"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "Groceries") {
+		t.Errorf("expected accounts to be replaced by groups:\n%s", result)
+	}
+
+	// Accounts outside every group fall into Other.
+	delete(groups, "Fun")
+	w, err := svc.Waterfall(ctx, "2025-01-01", "2025-02-28", GroupByGroup, 0)
+	if err != nil {
+		t.Fatalf("Waterfall returned error: %v", err)
+	}
+	if len(w.Steps) != 4 || w.Steps[1].Label != "Essentials" || w.Steps[2].Label != "Other" {
+		t.Errorf("expected Essentials then Other steps, got %+v", w.Steps)
+	}
+}
+
+func TestSpendingByCategory_GroupsNotConfigured(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+
+	_, err := svc.SpendingByCategory(context.Background(), "2025-01-01", "2025-02-28", "", GroupByGroup, "", "", "")
+	if err == nil || !strings.Contains(err.Error(), "GNUCASH_CATEGORY_GROUPS") {
+		t.Errorf("expected not-configured error, got %v", err)
+	}
+}
+
+func TestLoadCategoryGroups_Overlap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.json")
+	if err := os.WriteFile(path, []byte(`{"A": ["Rent"], "B": ["rent"]}`), 0o600); err != nil {
+		t.Fatalf("write groups: %v", err)
+	}
+	if _, err := LoadCategoryGroups(path); err == nil {
+		t.Error("expected error for an account in two groups")
+	}
+}
+
+func TestGetTransactions_SortByAmount(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Page through Checking by ascending amount, two at a time.
+	var got []string
+	cursor := ""
+	for range 5 {
+		result, err := svc.GetTransactions(ctx, "Checking", "", "", 2, "amount", "asc", cursor, "csv")
+		if err != nil {
+			t.Fatalf("GetTransactions(cursor=%q) returned error: %v", cursor, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(result), "\n")[1:] {
+			got = append(got, strings.Split(line, ",")[1])
+		}
+		text, err := svc.GetTransactions(ctx, "Checking", "", "", 2, "amount", "asc", cursor, "")
+		if err != nil {
+			t.Fatalf("GetTransactions(cursor=%q) returned error: %v", cursor, err)
+		}
+		_, after, ok := strings.Cut(text, "cursor=")
+		if !ok {
+			break
+		}
+		cursor, _, _ = strings.Cut(after, " ")
+	}
+
+	want := []string{"Supermarket", "Market", "Pizza place", "January salary", "February salary"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	// A cursor only continues the listing order it was issued for.
+	if _, err := svc.GetTransactions(ctx, "Checking", "", "", 2, "date", "", cursor, ""); err == nil {
+		t.Error("expected error for a cursor from another sort order")
+	}
+}
+
+func TestSearchTransactions_SortByDescription(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.SearchTransactions(ctx, SearchFilter{Text: "salary"}, 10, "description", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions returned error: %v", err)
+	}
+	feb, jan := strings.Index(result, "February salary"), strings.Index(result, "January salary")
+	if feb < 0 || jan < 0 || feb > jan {
+		t.Errorf("expected February before January when sorted by description:\n%s", result)
+	}
+
+	if _, err := svc.SearchTransactions(ctx, SearchFilter{Text: "salary"}, 10, "payee", "", ""); err == nil {
+		t.Error("expected error for unsupported sort_by")
+	}
+}
+
+func TestSearchTransactions_AmountRange(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Transactions around 50: Market (42.00) but not Supermarket (85.50) or Pizza (25.00).
+	result, err := svc.SearchTransactions(ctx, SearchFilter{MinAmount: 30, MaxAmount: 60}, 20, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions returned error: %v", err)
+	}
+	if !strings.Contains(result, "Market") || strings.Contains(result, "Supermarket") || strings.Contains(result, "Pizza") {
+		t.Errorf("expected only Market in 30-60 range:\n%s", result)
+	}
+	if !strings.Contains(result, "for amount 30.00-60.00") {
+		t.Errorf("expected range in header:\n%s", result)
+	}
+
+	// Combined with text.
+	result, err = svc.SearchTransactions(ctx, SearchFilter{Text: "market", MinAmount: 50}, 20, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions returned error: %v", err)
+	}
+	if !strings.Contains(result, "Supermarket") || strings.Contains(result, "\nMarket") {
+		t.Errorf("expected only Supermarket for 'market' >= 50:\n%s", result)
+	}
+
+	if _, err := svc.SearchTransactions(ctx, SearchFilter{MinAmount: 100, MaxAmount: 50}, 20, "", "", ""); err == nil {
+		t.Error("expected error when min_amount exceeds max_amount")
+	}
+	if _, err := svc.SearchTransactions(ctx, SearchFilter{}, 20, "", "", ""); err == nil {
+		t.Error("expected error without any search criterion")
+	}
+}
+
+func TestDateHorizon(t *testing.T) {
+	db := setupTestDB(t)
+	// The fixture's transactions are all more than a year old.
+	svc := NewService(db, WithDateHorizon(1))
+	ctx := context.Background()
+
+	result, err := svc.GetTransactions(ctx, "Checking", "2025-01-01", "", 50, "", "", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	if strings.Contains(result, "January salary") {
+		t.Errorf("expected transactions beyond the horizon to be excluded:\n%s", result)
+	}
+	if !strings.Contains(result, "excluded by the 1-year date horizon") {
+		t.Errorf("expected a horizon notice:\n%s", result)
+	}
+
+	result, err = svc.With(WithAllHistory()).SpendingByCategory(ctx, "2025-01-01", "2025-02-28", "", "", "", "", "")
+	if err != nil {
+		t.Fatalf("SpendingByCategory returned error: %v", err)
+	}
+	if !strings.Contains(result, "Groceries") || strings.Contains(result, "date horizon") {
+		t.Errorf("expected all_history to lift the horizon:\n%s", result)
+	}
+}
+
+func TestSearchTransactions_Combined(t *testing.T) {
+	db := setupTestDB(t)
+	// January checking splits are reconciled, Market is cleared.
+	if _, err := db.db.Exec(`
+		ALTER TABLE splits ADD COLUMN reconcile_state TEXT NOT NULL DEFAULT 'n';
+		UPDATE splits SET reconcile_state = 'y' WHERE guid IN ('sp1a', 'sp2a', 'sp4a');
+		UPDATE splits SET reconcile_state = 'c' WHERE guid = 'sp3a';
+	`); err != nil {
+		t.Fatalf("set reconcile states: %v", err)
+	}
+	svc := NewService(db)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "account includes sub-accounts",
+			filter: SearchFilter{Account: "Expenses"},
+			want:   []string{"Supermarket", "Market", "Pizza place"},
+			absent: []string{"salary"},
+		},
+		{
+			name:   "date range",
+			filter: SearchFilter{StartDate: "2025-02-01", EndDate: "2025-02-28"},
+			want:   []string{"Market", "February salary"},
+			absent: []string{"Supermarket", "January salary"},
+		},
+		{
+			name:   "reconcile state in account",
+			filter: SearchFilter{Account: "Checking", ReconcileState: "reconciled"},
+			want:   []string{"January salary", "Supermarket", "Pizza place"},
+			absent: []string{"\n2025-02-05  Market", "February salary"},
+		},
+		{
+			name:   "all criteria",
+			filter: SearchFilter{Text: "market", Account: "Checking", StartDate: "2025-01-01", EndDate: "2025-01-31", MinAmount: 50, ReconcileState: "y"},
+			want:   []string{"Supermarket", "(1 found)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.SearchTransactions(ctx, tt.filter, 20, "", "", "")
+			if err != nil {
+				t.Fatalf("SearchTransactions(%+v) returned error: %v", tt.filter, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(result, want) {
+					t.Errorf("expected %q in result:\n%s", want, result)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(result, absent) {
+					t.Errorf("unexpected %q in result:\n%s", absent, result)
+				}
+			}
+		})
+	}
+
+	if _, err := svc.SearchTransactions(ctx, SearchFilter{ReconcileState: "maybe"}, 20, "", "", ""); err == nil {
+		t.Error("expected error for unknown reconcile state")
+	}
+}
+
+func TestSearchTransactions_Index(t *testing.T) {
+	db := setupTestDB(t)
+	if _, err := db.db.Exec(`UPDATE splits SET memo = 'weekly market run' WHERE guid = 'sp2b'`); err != nil {
+		t.Fatalf("set memo: %v", err)
+	}
+	index, err := OpenSearchIndex(filepath.Join(t.TempDir(), "search.db"))
+	if err != nil {
+		t.Fatalf("OpenSearchIndex() returned error: %v", err)
+	}
+	t.Cleanup(func() { index.Close() })
+	svc := NewService(db, WithSearchIndex(index))
+	ctx := context.Background()
+
+	// Description hits rank above memo hits; words match by prefix.
+	result, err := svc.SearchTransactions(ctx, SearchFilter{Text: "market"}, 20, SortByRelevance, "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions(relevance) returned error: %v", err)
+	}
+	market, super := strings.Index(result, "Market\n"), strings.Index(result, "Supermarket")
+	if !strings.Contains(result, "(2 found)") || market < 0 || super < market {
+		t.Errorf("expected Market ranked before Supermarket, got:\n%s", result)
+	}
+
+	result, err = svc.SearchTransactions(ctx, SearchFilter{Text: "sal jan"}, 20, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions() returned error: %v", err)
+	}
+	if !strings.Contains(result, "January salary") || strings.Contains(result, "February salary") {
+		t.Errorf("expected only January salary, got:\n%s", result)
+	}
+
+	// The index follows changes to the book, ignoring accents.
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Café Central');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking',   '', -900, 100, -900, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'restaurant', '', 900, 100, 900, 100);
+	`); err != nil {
+		t.Fatalf("insert transaction: %v", err)
+	}
+	result, err = svc.SearchTransactions(ctx, SearchFilter{Text: "cafe", Account: "Restaurant"}, 20, "", "", "")
+	if err != nil {
+		t.Fatalf("SearchTransactions() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Café Central") {
+		t.Errorf("expected new transaction to be indexed, got:\n%s", result)
+	}
+
+	// Relevance needs both the index and a text query.
+	if _, err := svc.SearchTransactions(ctx, SearchFilter{Account: "Checking"}, 20, SortByRelevance, "", ""); err == nil {
+		t.Error("expected error for relevance sort without a text query")
+	}
+	if _, err := NewService(db).SearchTransactions(ctx, SearchFilter{Text: "market"}, 20, SortByRelevance, "", ""); err == nil {
+		t.Error("expected error for relevance sort without an index")
+	}
+}
+
+func TestExportReportBundle(t *testing.T) {
+	db := setupTestDB(t)
+	dir := t.TempDir()
+	svc := NewService(db, WithExportDir(dir))
+	ctx := context.Background()
+
+	result, err := svc.ExportReportBundle(ctx, BundleRequest{Report: ReportSpendingByCategory, StartDate: "2025-01-01", EndDate: "2025-01-31"})
+	if err != nil {
+		t.Fatalf("ExportReportBundle returned error: %v", err)
+	}
+	if !strings.Contains(result, "2 source transactions") {
+		t.Errorf("expected 2 source transactions in summary:\n%s", result)
+	}
+
+	bundles, err := filepath.Glob(filepath.Join(dir, "spending_by_category_2025-01-01_2025-01-31_*"))
+	if err != nil || len(bundles) != 1 {
+		t.Fatalf("expected one bundle directory, got %v (%v)", bundles, err)
+	}
+	for file, want := range map[string]string{
+		"report.md":        "| Groceries",
+		"report.csv":       "Groceries,85.50,1",
+		"report.json":      `"category": "Restaurant"`,
+		"transactions.csv": "Expenses:Groceries,85.50",
+		"manifest.json":    `"report": "spending_by_category"`,
+	} {
+		content, err := os.ReadFile(filepath.Join(bundles[0], file))
+		if err != nil {
+			t.Errorf("read %s: %v", file, err)
+			continue
+		}
+		if !strings.Contains(string(content), want) {
+			t.Errorf("%s missing %q:\n%s", file, want, content)
+		}
+	}
+}
+
+func TestExportReportBundle_Disabled(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+
+	_, err := svc.ExportReportBundle(context.Background(), BundleRequest{Report: ReportIncomeVsExpenses})
+	if err == nil || !strings.Contains(err.Error(), "GNUCASH_EXPORT_DIR") {
+		t.Errorf("expected disabled error, got %v", err)
+	}
+}
+
+func TestSuggestCategory(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.SuggestCategory(ctx, "SUPERMARKET 0042 Lyon", 0)
+	if err != nil {
+		t.Fatalf("SuggestCategory() returned error: %v", err)
+	}
+	if !strings.Contains(result, "1. Expenses:Groceries  100%  (1 similar transaction(s), last 2025-01-20)") {
+		t.Errorf("expected Groceries suggested, got:\n%s", result)
+	}
+
+	// Words shared with several accounts split the probability.
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Market bistro');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking',   '', -900, 100, -900, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'restaurant', '', 900, 100, 900, 100);
+	`); err != nil {
+		t.Fatalf("insert transaction: %v", err)
+	}
+	result, err = svc.SuggestCategory(ctx, "Market", 0)
+	if err != nil {
+		t.Fatalf("SuggestCategory() returned error: %v", err)
+	}
+	if !strings.Contains(result, "1. Expenses:Groceries  50%") || !strings.Contains(result, "2. Expenses:Restaurant  50%") {
+		t.Errorf("expected Groceries and Restaurant suggested, got:\n%s", result)
+	}
+
+	result, err = svc.SuggestCategory(ctx, "Bookshop", 0)
+	if err != nil || !strings.Contains(result, "no category to suggest") {
+		t.Errorf("expected no suggestion, got %q, %v", result, err)
+	}
+	if _, err := svc.SuggestCategory(ctx, "12/03", 0); err == nil {
+		t.Error("expected an error for a description without words")
+	}
+}
+
+func TestCheckBook(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, problems, err := svc.CheckBook(ctx)
+	if err != nil {
+		t.Fatalf("CheckBook() returned error: %v", err)
+	}
+	if problems != 0 || !strings.Contains(result, "6 transaction(s), 12 split(s), 2 price(s), from 2025-01-10 to 2025-02-15") ||
+		!strings.Contains(result, "Problems: none") {
+		t.Errorf("expected a healthy book, got %d problem(s):\n%s", problems, result)
+	}
+
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Half entered');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking', '', -900, 100, -900, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'gone',     '', 800, 100, 800, 100);
+	`); err != nil {
+		t.Fatalf("insert transaction: %v", err)
+	}
+	result, problems, err = svc.CheckBook(ctx)
+	if err != nil {
+		t.Fatalf("CheckBook() returned error: %v", err)
+	}
+	if problems != 2 || !strings.Contains(result, "transaction tx7 (2025-02-10 Half entered) is unbalanced by -1.00") ||
+		!strings.Contains(result, "1 split(s) belong to a missing account") {
+		t.Errorf("expected 2 problems, got %d:\n%s", problems, result)
+	}
+}
+
+func TestBookInfo(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	if _, err := db.db.Exec(`
+		CREATE TABLE versions (table_name TEXT PRIMARY KEY, table_version INTEGER);
+		INSERT INTO versions VALUES ('Gnucash', 4130000), ('Gnucash-Resave', 5040000), ('accounts', 1);
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, string_val) VALUES
+			('book', 'features', 9, NULL),
+			('book', 'features/Register sort and filter settings stored in .gcm file', 4, 'Store the register sort and filter settings in .gcm metadata file (requires at least GnuCash 3.3)');
+	`); err != nil {
+		t.Fatalf("create schema tables: %v", err)
+	}
+
+	result, err := NewService(db, WithSQL(), WithDateHorizon(3)).BookInfo(ctx)
+	if err != nil {
+		t.Fatalf("BookInfo() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Contents: 11 account(s), 6 transaction(s), 2 price(s)",
+		"Mode: read-only",
+		"Schema: created by GnuCash 4.13, last saved by GnuCash 5.4",
+		"Features: Register sort and filter settings stored in .gcm file\n",
+		"Trading accounts: no\n",
+		"Open in GnuCash: no",
+		"Optional features: date horizon (3 years), SQL queries",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestCurrencyGains(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := svc.CurrencyGains(ctx, "", ""); err == nil {
+		t.Error("expected an error for a book without trading accounts")
+	}
+
+	// 100 USD bought for 80 EUR on Feb 1, worth 90 EUR at the rate of Mar 1.
+	if _, err := db.db.Exec(`
+		INSERT INTO commodities VALUES ('usd', 'CURRENCY', 'USD', 'US Dollar', '840', 100);
+		INSERT INTO accounts VALUES ('usd-cash', 'USD Cash', 'BANK', 'assets', '', 'usd', 0, 0);
+		INSERT INTO accounts VALUES ('trading', 'Trading', 'TRADING', 'root', '', 'eur', 0, 1);
+		INSERT INTO accounts VALUES ('trading-usd', 'USD', 'TRADING', 'trading', '', 'usd', 0, 0);
+		INSERT INTO accounts VALUES ('trading-eur', 'EUR', 'TRADING', 'trading', '', 'eur', 0, 0);
+		INSERT INTO transactions VALUES ('txfx', 'eur', '2025-02-01 00:00:00', '2025-02-01 00:00:00', 'Buy dollars');
+		INSERT INTO splits VALUES ('sfx1', 'txfx', 'checking', '', -8000, 100, -8000, 100);
+		INSERT INTO splits VALUES ('sfx2', 'txfx', 'usd-cash', '', 8000, 100, 10000, 100);
+		INSERT INTO splits VALUES ('sfx3', 'txfx', 'trading-usd', '', -8000, 100, -10000, 100);
+		INSERT INTO splits VALUES ('sfx4', 'txfx', 'trading-eur', '', 8000, 100, 8000, 100);
+		INSERT INTO prices VALUES ('pu1', 'usd', 'eur', '2025-03-01 00:00:00', 'user:price', 'last', 90, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.CurrencyGains(ctx, "2025-03-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "currency,holding,cost,rate,worth,gain\nUSD,100.00,80.00,0.9,90.00,10.00\n"; result != want {
+		t.Errorf("CurrencyGains() = %q, want %q", result, want)
+	}
+
+	// Trading splits are not counterparts of the exchange.
+	result, err = svc.GetTransactions(ctx, "Checking", "2025-02-01", "2025-02-01", 0, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "USD Cash") || strings.Contains(result, "Trading") {
+		t.Errorf("expected USD Cash as the only counterpart:\n%s", result)
+	}
+
+	info, err := svc.BookInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(info, "Trading accounts: yes") {
+		t.Errorf("expected trading accounts in book info:\n%s", info)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	fr, err := ParseLocale("fr-FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(db, WithLocale(fr))
+	result, err := svc.GetBalance(ctx, "Checking", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "5\u00a0847,50\u00a0€"; !strings.Contains(result, want) {
+		t.Errorf("expected %q in fr-FR balance, got:\n%s", want, result)
+	}
+
+	tests := []struct {
+		locale, currency, want string
+	}{
+		{"en-US", "USD", "$5,847.50"},
+		{"de-CH", "CHF", "CHF\u00a05’847.50"},
+		{"es-MX", "MXN", "$5,847.50"},
+		{"de-DE", "EUR", "5.847,50\u00a0€"},
+	}
+	for _, tt := range tests {
+		tag, err := ParseLocale(tt.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := svc.With(WithLocale(tag)).formatMoney(NewNumeric(-584750, 100), Commodity{Mnemonic: tt.currency, Fraction: 100}); got != "-"+tt.want {
+			t.Errorf("%s: formatMoney = %q, want %q", tt.locale, got, "-"+tt.want)
+		}
+	}
+
+	// Amounts are formatted exactly, however large or precise.
+	us := svc.With(WithLocale(language.MustParse("en-US")))
+	for _, tt := range []struct {
+		n        Numeric
+		fraction int64
+		want     string
+	}{
+		{NewNumeric(-1234567890123456789, 100), 100, "-12,345,678,901,234,567.89"},
+		{NewNumeric(12345678912345678, 100000000), 100000000, "123,456,789.12345678"},
+		{NewNumeric(5, 1000), 100, "0.01"},
+	} {
+		if got := us.formatAmount(tt.n, tt.fraction); got != tt.want {
+			t.Errorf("formatAmount(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+
+	// Without a locale, amounts stay plain.
+	plain := NewService(db)
+	result, err = plain.GetBalance(ctx, "Checking", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "5847.50 EUR") {
+		t.Errorf("expected plain amount, got:\n%s", result)
+	}
+
+	if _, err := ParseLocale("not a locale"); err == nil {
+		t.Error("expected an error for an invalid locale")
+	}
+}
+
+func TestLoanSummary(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// 10000.00 borrowed on Jan 1, then three monthly payments of 600.00:
+	// 500.00 of principal and 100.00 of interest.
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('liabilities', 'Liabilities', 'LIABILITY', 'root', '', '', 0, 1);
+		INSERT INTO accounts VALUES ('mortgage', 'Mortgage', 'LIABILITY', 'liabilities', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('interest', 'Interest', 'EXPENSE', 'expenses', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('ln0', 'eur', '2025-01-01 00:00:00', '2025-01-01 00:00:00', 'Loan');
+		INSERT INTO splits VALUES ('ln0a', 'ln0', 'mortgage', '', -1000000, 100, -1000000, 100);
+		INSERT INTO splits VALUES ('ln0b', 'ln0', 'checking', '', 1000000, 100, 1000000, 100);
+		INSERT INTO transactions VALUES ('ln1', 'eur', '2025-01-31 00:00:00', '2025-01-31 00:00:00', 'Payment');
+		INSERT INTO splits VALUES ('ln1a', 'ln1', 'mortgage', '', 50000, 100, 50000, 100);
+		INSERT INTO splits VALUES ('ln1b', 'ln1', 'interest', '', 10000, 100, 10000, 100);
+		INSERT INTO splits VALUES ('ln1c', 'ln1', 'checking', '', -60000, 100, -60000, 100);
+		INSERT INTO transactions VALUES ('ln2', 'eur', '2025-02-28 00:00:00', '2025-02-28 00:00:00', 'Payment');
+		INSERT INTO splits VALUES ('ln2a', 'ln2', 'mortgage', '', 50000, 100, 50000, 100);
+		INSERT INTO splits VALUES ('ln2b', 'ln2', 'interest', '', 10000, 100, 10000, 100);
+		INSERT INTO splits VALUES ('ln2c', 'ln2', 'checking', '', -60000, 100, -60000, 100);
+		INSERT INTO transactions VALUES ('ln3', 'eur', '2025-03-31 00:00:00', '2025-03-31 00:00:00', 'Payment');
+		INSERT INTO splits VALUES ('ln3a', 'ln3', 'mortgage', '', 50000, 100, 50000, 100);
+		INSERT INTO splits VALUES ('ln3b', 'ln3', 'interest', '', 10000, 100, 10000, 100);
+		INSERT INTO splits VALUES ('ln3c', 'ln3', 'checking', '', -60000, 100, -60000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1000.00 paid over the 59 days between the first and last payments.
+	result, err := svc.LoanSummary(ctx, "", "", "2025-03-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "account,borrowed,principal_paid,interest_paid,payments,balance,last_payment,payoff_date\n" +
+		"Liabilities:Mortgage,10000.00,1500.00,300.00,3,8500.00,2025-03-31,2026-08-15\n"
+	if result != want {
+		t.Errorf("LoanSummary() = %q, want %q", result, want)
+	}
+
+	result, err = svc.LoanSummary(ctx, "Mortgage", "2025-02-01", "2025-03-31", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Principal paid:       1000.00  (2 payment(s))", "Interest paid:         200.00", "Balance owed:         8500.00"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.LoanSummary(ctx, "Groceries", "", "", ""); err == nil {
+		t.Error("expected an error for an expense account")
+	}
+}
+
+func TestInterestAndFees(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('mortgage', 'Mortgage', 'LIABILITY', 'root', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('interest', 'Mortgage Interest', 'EXPENSE', 'expenses', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('fees', 'Bank Fees', 'EXPENSE', 'expenses', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('coffee', 'Coffee', 'EXPENSE', 'expenses', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('f1', 'eur', '2024-12-31 00:00:00', '2024-12-31 00:00:00', 'Account fee');
+		INSERT INTO splits VALUES ('f1a', 'f1', 'fees', '', 500, 100, 500, 100);
+		INSERT INTO splits VALUES ('f1b', 'f1', 'checking', '', -500, 100, -500, 100);
+		INSERT INTO transactions VALUES ('f2', 'eur', '2025-01-31 00:00:00', '2025-01-31 00:00:00', 'Account fee');
+		INSERT INTO splits VALUES ('f2a', 'f2', 'fees', '', 300, 100, 300, 100);
+		INSERT INTO splits VALUES ('f2b', 'f2', 'checking', '', -300, 100, -300, 100);
+		INSERT INTO transactions VALUES ('f3', 'eur', '2025-01-31 00:00:00', '2025-01-31 00:00:00', 'Mortgage payment');
+		INSERT INTO splits VALUES ('f3a', 'f3', 'mortgage', '', 50000, 100, 50000, 100);
+		INSERT INTO splits VALUES ('f3b', 'f3', 'interest', '', 10000, 100, 10000, 100);
+		INSERT INTO splits VALUES ('f3c', 'f3', 'checking', '', -60000, 100, -60000, 100);
+		INSERT INTO transactions VALUES ('f4', 'eur', '2025-02-01 00:00:00', '2025-02-01 00:00:00', 'Espresso');
+		INSERT INTO splits VALUES ('f4a', 'f4', 'coffee', '', 400, 100, 400, 100);
+		INSERT INTO splits VALUES ('f4b', 'f4', 'checking', '', -400, 100, -400, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.InterestAndFees(ctx, "", "", "2025-12-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "year,institution,account,kind,amount\n" +
+		"2024,Assets:Checking,Expenses:Bank Fees,fees,5.00\n" +
+		"2025,Assets:Checking,Expenses:Bank Fees,fees,3.00\n" +
+		"2025,Mortgage,Expenses:Mortgage Interest,interest,100.00\n"
+	if result != want {
+		t.Errorf("InterestAndFees() = %q, want %q", result, want)
+	}
+
+	result, err = svc.InterestAndFees(ctx, "", "2025-01-01", "2025-12-31", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Total 2025: interest 100.00, fees 3.00, together 103.00", "TOTAL: interest 100.00, fees 3.00, together 103.00 EUR"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	// Named accounts are fees unless their names say interest.
+	result, err = svc.InterestAndFees(ctx, "Coffee", "", "2025-12-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "2025,Assets:Checking,Expenses:Coffee,fees,4.00") {
+		t.Errorf("expected the named account:\n%s", result)
+	}
+}
+
+func TestCreditCardSummary(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Statements close on the 15th: the last one ran from Feb 16 to Mar 15.
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('visa', 'Visa', 'CREDIT', 'root', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('c1', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Older purchase');
+		INSERT INTO splits VALUES ('c1a', 'c1', 'visa', '', -10000, 100, -10000, 100);
+		INSERT INTO splits VALUES ('c1b', 'c1', 'groceries', '', 10000, 100, 10000, 100);
+		INSERT INTO transactions VALUES ('c2', 'eur', '2025-02-20 00:00:00', '2025-02-20 00:00:00', 'Dinner');
+		INSERT INTO splits VALUES ('c2a', 'c2', 'visa', '', -20000, 100, -20000, 100);
+		INSERT INTO splits VALUES ('c2b', 'c2', 'restaurant', '', 20000, 100, 20000, 100);
+		INSERT INTO transactions VALUES ('c3', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Card payment');
+		INSERT INTO splits VALUES ('c3a', 'c3', 'visa', '', 10000, 100, 10000, 100);
+		INSERT INTO splits VALUES ('c3b', 'c3', 'checking', '', -10000, 100, -10000, 100);
+		INSERT INTO transactions VALUES ('c4', 'eur', '2025-03-05 00:00:00', '2025-03-05 00:00:00', 'Refund');
+		INSERT INTO splits VALUES ('c4a', 'c4', 'visa', '', 2000, 100, 2000, 100);
+		INSERT INTO splits VALUES ('c4b', 'c4', 'restaurant', '', -2000, 100, -2000, 100);
+		INSERT INTO transactions VALUES ('c5', 'eur', '2025-03-18 00:00:00', '2025-03-18 00:00:00', 'Market');
+		INSERT INTO splits VALUES ('c5a', 'c5', 'visa', '', -5000, 100, -5000, 100);
+		INSERT INTO splits VALUES ('c5b', 'c5', 'groceries', '', 5000, 100, 5000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 230.00 paid off at 100.00 a month and 12% APR: 2.30, 1.32 and 0.34 of interest.
+	result, err := svc.CreditCardSummary(ctx, "Visa", "2025-03-20", 15, 1000, 100, 12, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "account,balance,utilization,statement_start,statement_end,statement_spend,statement_payments,statement_credits,current_spend,current_payments,current_credits,payoff_months,payoff_interest\n" +
+		"Visa,230.00,23.0,2025-02-16,2025-03-15,200.00,100.00,20.00,50.00,0.00,0.00,3,3.96\n"
+	if result != want {
+		t.Errorf("CreditCardSummary() = %q, want %q", result, want)
+	}
+
+	result, err = svc.CreditCardSummary(ctx, "", "2025-03-20", 0, 0, 1, 12, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Statement 2025-02-01 to 2025-02-28:", "spent 50.00, paid 100.00, credited 20.00", "never paid off"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.CreditCardSummary(ctx, "Checking", "", 0, 0, 0, 0, ""); err == nil {
+		t.Error("expected an error for a bank account")
+	}
+}
+
+func TestBurnRate(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('b1', 'eur', '2024-12-01 00:00:00', '2024-12-01 00:00:00', 'Funding');
+		INSERT INTO splits VALUES ('b1a', 'b1', 'checking', '', 2000000, 100, 2000000, 100);
+		INSERT INTO splits VALUES ('b1b', 'b1', 'salary', '', -2000000, 100, -2000000, 100);
+		INSERT INTO transactions VALUES ('b2', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Transfer');
+		INSERT INTO splits VALUES ('b2a', 'b2', 'checking', '', -50000, 100, -50000, 100);
+		INSERT INTO splits VALUES ('b2b', 'b2', 'brokerage', '', 50000, 100, 50000, 100);
+		INSERT INTO transactions VALUES ('b3', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Payroll');
+		INSERT INTO splits VALUES ('b3a', 'b3', 'checking', '', -900000, 100, -900000, 100);
+		INSERT INTO splits VALUES ('b3b', 'b3', 'groceries', '', 900000, 100, 900000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transfer between cash accounts is neither in nor out.
+	result, err := svc.BurnRate(ctx, "", "2025-04-10", 3, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "month,inflow,outflow,net,ending_balance\n" +
+		"2025-01,3000.00,1110.50,1889.50,21889.50\n" +
+		"2025-02,3000.00,42.00,2958.00,24847.50\n" +
+		"2025-03,0.00,9000.00,-9000.00,15847.50\n"
+	if result != want {
+		t.Errorf("BurnRate() = %q, want %q", result, want)
+	}
+
+	// 15847.50 at 1384.17 a month.
+	result, err = svc.BurnRate(ctx, "", "2025-04-10", 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Burn rate: 1384.17 a month", "Runway: 11.4 months, until about 2026-03-23"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.BurnRate(ctx, "", "2025-02-28", 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "cash is not burning") {
+		t.Errorf("expected a positive cash flow:\n%s", result)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// January and February average 3000 of salary and 76.25 of expenses.
+	result, err := svc.Simulate(ctx, "remove Restaurant; income +10%; add €300/month expense", "2025-02-28", 2, 3, "")
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+	for _, want := range []string{"monthly averages of 2025-01 to 2025-02", "+2923.75", "remove Restaurant", "+12.50 a month", "+300.00 a month", "-300.00 a month", "4847.50 on 2025-02-28", "+2936.25", "2025-05"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.Simulate(ctx, "income -100%", "2025-02-28", 2, 2, "csv")
+	if err != nil {
+		t.Fatalf("Simulate returned error: %v", err)
+	}
+	want := "month,baseline_net,simulated_net,baseline_balance,simulated_balance\n" +
+		"2025-03,2923.75,-76.25,7771.25,4771.25\n" +
+		"2025-04,2923.75,-76.25,10695.00,4695.00\n"
+	if result != want {
+		t.Errorf("Simulate(csv) = %q, want %q", result, want)
+	}
+
+	for _, bad := range []string{"", "Groceries", "Groceries lots", "remove income", "Checking -10%"} {
+		if _, err := svc.Simulate(ctx, bad, "2025-02-28", 2, 2, ""); err == nil {
+			t.Errorf("Simulate(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestGoalProgress(t *testing.T) {
+	db := setupTestDB(t)
+	path := filepath.Join(t.TempDir(), "goals.json")
+	goals := `{"Emergency fund": {"accounts": ["Checking"], "target": 10000, "date": "2025-12-31"}, "Car": {"accounts": ["Assets:Checking"], "target": 5000}}`
+	if err := os.WriteFile(path, []byte(goals), 0o600); err != nil {
+		t.Fatalf("write goals: %v", err)
+	}
+	loaded, err := LoadSavingsGoals(path)
+	if err != nil {
+		t.Fatalf("LoadSavingsGoals() returned error: %v", err)
+	}
+	svc := NewService(db, WithSavingsGoals(loaded))
+	ctx := context.Background()
+
+	result, err := svc.GoalProgress(ctx, "", "2025-02-28", "")
+	if err != nil {
+		t.Fatalf("GoalProgress returned error: %v", err)
+	}
+	for _, want := range []string{"Emergency fund (Assets:Checking)", "Saved 5847.50 of 10000.00 (58%)", "by 2025-12-31 (10 month(s))", "Required: 415.25 a month", "on track", "Car (Assets:Checking)", "goal reached"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.GoalProgress(ctx, "emergency fund", "2025-02-28", "csv")
+	if err != nil {
+		t.Fatalf("GoalProgress returned error: %v", err)
+	}
+	want := "goal,accounts,saved,target,remaining,progress_pct,target_date,months_left,required_monthly,pace_monthly\n" +
+		"Emergency fund,Assets:Checking,5847.50,10000.00,4152.50,58.5,2025-12-31,10,415.25,1949.17\n"
+	if result != want {
+		t.Errorf("GoalProgress(csv) = %q, want %q", result, want)
+	}
+
+	if _, err := svc.GoalProgress(ctx, "Holidays", "", ""); err == nil {
+		t.Error("expected an error for an unknown goal")
+	}
+	if _, err := NewService(db).GoalProgress(ctx, "", "", ""); err == nil {
+		t.Error("expected an error without goals configured")
+	}
+	if err := os.WriteFile(path, []byte(`{"Car": {"accounts": ["Checking"], "target": 0}}`), 0o600); err != nil {
+		t.Fatalf("write goals: %v", err)
+	}
+	if _, err := LoadSavingsGoals(path); err == nil {
+		t.Error("expected an error for a goal without a target")
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+	db := setupTestDB(t)
+	store, err := OpenEnvelopeStore(filepath.Join(t.TempDir(), "envelopes.db"))
+	if err != nil {
+		t.Fatalf("OpenEnvelopeStore() returned error: %v", err)
+	}
+	defer store.Close()
+	svc := NewService(db, WithEnvelopeStore(store))
+	ctx := context.Background()
+
+	if _, err := svc.SetEnvelope(ctx, "Groceries", 100, "2025-01", true, false); err != nil {
+		t.Fatalf("SetEnvelope returned error: %v", err)
+	}
+	result, err := svc.SetEnvelope(ctx, "Restaurant", 20, "2025-01", false, false)
+	if err != nil {
+		t.Fatalf("SetEnvelope returned error: %v", err)
+	}
+	if !strings.Contains(result, "Expenses:Restaurant: 20.00 EUR a month from 2025-01") {
+		t.Errorf("unexpected confirmation: %s", result)
+	}
+	if _, err := svc.SetEnvelope(ctx, "Checking", 100, "", false, false); err == nil {
+		t.Error("expected an error for a non-expense account")
+	}
+
+	// January: 85.50 of groceries and 25 at restaurants.
+	result, err = svc.EnvelopeStatus(ctx, "2025-01", "")
+	if err != nil {
+		t.Fatalf("EnvelopeStatus returned error: %v", err)
+	}
+	for _, want := range []string{"Envelopes for 2025-01", "14.50", "-5.00  overspent", "Overspent: Expenses:Restaurant"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	// February: 42 of groceries, with the 14.50 left in January rolled
+	// over, and a smaller restaurant envelope from then on.
+	if _, err := svc.SetEnvelope(ctx, "Restaurant", 10, "2025-02", false, false); err != nil {
+		t.Fatalf("SetEnvelope returned error: %v", err)
+	}
+	result, err = svc.EnvelopeStatus(ctx, "2025-02", "csv")
+	if err != nil {
+		t.Fatalf("EnvelopeStatus returned error: %v", err)
+	}
+	want := "envelope,allocated,carried,spent,remaining\n" +
+		"Expenses:Groceries,100.00,14.50,42.00,72.50\n" +
+		"Expenses:Restaurant,10.00,0.00,0.00,10.00\n"
+	if result != want {
+		t.Errorf("EnvelopeStatus(csv) = %q, want %q", result, want)
+	}
+	result, err = svc.EnvelopeStatus(ctx, "2025-01", "csv")
+	if err != nil {
+		t.Fatalf("EnvelopeStatus returned error: %v", err)
+	}
+	if !strings.Contains(result, "Expenses:Restaurant,20.00,") {
+		t.Errorf("expected January to keep its amount:\n%s", result)
+	}
+
+	if _, err := svc.SetEnvelope(ctx, "Restaurant", 0, "", false, true); err != nil {
+		t.Fatalf("SetEnvelope(remove) returned error: %v", err)
+	}
+	if _, err := svc.SetEnvelope(ctx, "Restaurant", 0, "", false, true); err == nil {
+		t.Error("expected an error removing a missing envelope")
+	}
+	if _, err := NewService(db).EnvelopeStatus(ctx, "", ""); err == nil {
+		t.Error("expected an error without an envelope store")
+	}
+}
+
+func TestBudgetAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	if _, err := db.db.Exec(`
+		CREATE TABLE budgets (guid TEXT PRIMARY KEY, name TEXT, description TEXT, num_periods INTEGER);
+		CREATE TABLE recurrences (id INTEGER PRIMARY KEY, obj_guid TEXT, recurrence_mult INTEGER,
+			recurrence_period_type TEXT, recurrence_period_start TEXT, recurrence_weekend_adjust TEXT);
+		CREATE TABLE budget_amounts (id INTEGER PRIMARY KEY, budget_guid TEXT, account_guid TEXT,
+			period_num INTEGER, amount_num INTEGER, amount_denom INTEGER);
+		INSERT INTO budgets VALUES ('b1', '2025', 'Household budget', 12);
+		INSERT INTO recurrences VALUES (1, 'b1', 1, 'month', '20250101', 'none');
+		INSERT INTO budget_amounts VALUES (1, 'b1', 'groceries', 0, 30000, 100);
+		INSERT INTO budget_amounts VALUES (2, 'b1', 'restaurant', 0, 2000, 100);
+		INSERT INTO budget_amounts VALUES (3, 'b1', 'groceries', 1, 5000, 100);
+	`); err != nil {
+		t.Fatalf("create budgets: %v", err)
+	}
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// January 25: 25 at restaurants against 20, 85.50 of groceries well
+	// within 300.
+	result, err := svc.BudgetAlerts(ctx, "", "2025-01-25", "csv")
+	if err != nil {
+		t.Fatalf("BudgetAlerts returned error: %v", err)
+	}
+	want := "account,budget,prorated,actual,projected,status\n" +
+		"Expenses:Restaurant,20.00,16.13,25.00,31.00,over\n"
+	if result != want {
+		t.Errorf("BudgetAlerts(csv) = %q, want %q", result, want)
+	}
+
+	// February 10: 42 of groceries is ahead of the prorated 50.
+	result, err = svc.BudgetAlerts(ctx, "2025", "2025-02-10", "")
+	if err != nil {
+		t.Fatalf("BudgetAlerts returned error: %v", err)
+	}
+	for _, want := range []string{"period 2025-02-01 to 2025-02-28", "36% elapsed", "Expenses:Groceries", "117.60  trending over", "1 of 1 budgeted"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+	result, err = svc.BudgetAlerts(ctx, "", "2025-01-10", "")
+	if err != nil {
+		t.Fatalf("BudgetAlerts returned error: %v", err)
+	}
+	if !strings.Contains(result, "All 2 budgeted expense account(s) are on track") {
+		t.Errorf("expected no alert on January 10:\n%s", result)
+	}
+
+	if _, err := svc.BudgetAlerts(ctx, "Holidays", "2025-01-10", ""); err == nil {
+		t.Error("expected an error for an unknown budget")
+	}
+	if _, err := svc.BudgetAlerts(ctx, "", "2026-06-01", ""); err == nil {
+		t.Error("expected an error for a date outside the budget")
+	}
+}
+
+func TestMonthClose(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// A book without reconcile states, scheduled transactions or budgets.
+	result, err := svc.MonthClose(ctx, "2025-01", "")
+	if err != nil {
+		t.Fatalf("MonthClose returned error: %v", err)
+	}
+	for _, want := range []string{"Month-end close for 2025-01", "[x] Unbalanced entries: none", "[-] Unreconciled splits: the book does not record reconcile states",
+		"[x] Uncategorized transactions: none", "[-] Missing scheduled transactions", "[-] Budget overruns: the book has no budgets", "ready to close"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	for _, stmt := range []string{
+		`ALTER TABLE splits ADD COLUMN reconcile_state TEXT NOT NULL DEFAULT 'y'`,
+		`UPDATE splits SET reconcile_state = 'n' WHERE guid IN ('sp2a', 'sp4a')`,
+		`INSERT INTO accounts VALUES ('imbalance', 'Imbalance-EUR', 'BANK', 'root', '', 'eur', 0, 0)`,
+		`INSERT INTO transactions VALUES ('tx7', 'eur', '2025-01-28 10:59:00', '2025-01-28 10:59:00', 'Unknown transfer')`,
+		`INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking', '', -1000, 100, -1000, 100, 'y')`,
+		`INSERT INTO splits VALUES ('sp7b', 'tx7', 'imbalance', '', 1000, 100, 1000, 100, 'y')`,
+		`INSERT INTO transactions VALUES ('tx8', 'eur', '2025-01-29 10:59:00', '2025-01-29 10:59:00', 'Half entry')`,
+		`INSERT INTO splits VALUES ('sp8a', 'tx8', 'groceries', '', 500, 100, 500, 100, 'y')`,
+		`CREATE TABLE schedxactions (guid TEXT PRIMARY KEY, name TEXT, enabled INTEGER, start_date TEXT, end_date TEXT,
+			last_occur TEXT, num_occur INTEGER, rem_occur INTEGER, auto_create INTEGER, auto_notify INTEGER,
+			adv_creation INTEGER, adv_notify INTEGER, instance_count INTEGER, template_act_guid TEXT)`,
+		`CREATE TABLE recurrences (id INTEGER PRIMARY KEY, obj_guid TEXT, recurrence_mult INTEGER,
+			recurrence_period_type TEXT, recurrence_period_start TEXT, recurrence_weekend_adjust TEXT)`,
+		`INSERT INTO schedxactions VALUES ('sx1', 'Rent', 1, '20241201', NULL, '20241201', 0, 0, 0, 0, 0, 0, 1, 'tpl')`,
+		`INSERT INTO recurrences VALUES (1, 'sx1', 1, 'month', '20241201', 'none')`,
+		`INSERT INTO schedxactions VALUES ('sx2', 'Old plan', 0, '20240101', NULL, NULL, 0, 0, 0, 0, 0, 0, 0, 'tpl')`,
+		`INSERT INTO recurrences VALUES (2, 'sx2', 1, 'week', '20240101', 'none')`,
+	} {
+		if _, err := db.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	result, err = svc.MonthClose(ctx, "2025-01", "")
+	if err != nil {
+		t.Fatalf("MonthClose returned error: %v", err)
+	}
+	for _, want := range []string{"[ ] Unbalanced entries: 1", "2025-01-29 Half entry: off by 5.00", "[ ] Unreconciled splits: 2 in 1 account(s)",
+		"Assets:Checking: 2 split(s), -110.50", "[ ] Uncategorized transactions: 1", "2025-01-28 Unknown transfer: 10.00 in Imbalance-EUR",
+		"[ ] Missing scheduled transactions: 1", "Rent due 2025-01-01", "4 of 5 check(s) need attention"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.MonthClose(ctx, "2025-02", "csv")
+	if err != nil {
+		t.Fatalf("MonthClose returned error: %v", err)
+	}
+	if !strings.Contains(result, "Missing scheduled transactions,attention,1,Rent due 2025-02-01\n") {
+		t.Errorf("unexpected scheduled transactions row:\n%s", result)
+	}
+	if _, err := svc.MonthClose(ctx, "January", ""); err == nil {
+		t.Error("expected an error for an invalid month")
+	}
+}
+
+func TestUncategorizedTransactions(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.UncategorizedTransactions(ctx, "", "", "")
+	if err != nil {
+		t.Fatalf("UncategorizedTransactions returned error: %v", err)
+	}
+	if !strings.Contains(result, "No transactions in Imbalance or Orphan accounts") {
+		t.Errorf("unexpected result for a clean book:\n%s", result)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO accounts VALUES ('imbalance', 'Imbalance-EUR', 'BANK', 'root', '', 'eur', 0, 0)`,
+		`INSERT INTO accounts VALUES ('orphan', 'Orphan-EUR', 'BANK', 'root', '', 'eur', 0, 0)`,
+		`INSERT INTO transactions VALUES ('tx7', 'eur', '2025-01-28 10:59:00', '2025-01-28 10:59:00', 'Unknown transfer')`,
+		`INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking', '', -1000, 100, -1000, 100)`,
+		`INSERT INTO splits VALUES ('sp7b', 'tx7', 'imbalance', '', 1000, 100, 1000, 100)`,
+		`INSERT INTO transactions VALUES ('tx8', 'eur', '2025-02-03 10:59:00', '2025-02-03 10:59:00', 'CARD 1234')`,
+		`INSERT INTO splits VALUES ('sp8a', 'tx8', 'checking', '', -2500, 100, -2500, 100)`,
+		`INSERT INTO splits VALUES ('sp8b', 'tx8', 'orphan', '', 2500, 100, 2500, 100)`,
+		`INSERT INTO transactions VALUES ('tx9', 'eur', '2025-02-09 10:59:00', '2025-02-09 10:59:00', 'Refund')`,
+		`INSERT INTO splits VALUES ('sp9a', 'tx9', 'checking', '', 500, 100, 500, 100)`,
+		`INSERT INTO splits VALUES ('sp9b', 'tx9', 'imbalance', '', -500, 100, -500, 100)`,
+	} {
+		if _, err := db.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	result, err = svc.UncategorizedTransactions(ctx, "", "", "")
+	if err != nil {
+		t.Fatalf("UncategorizedTransactions returned error: %v", err)
+	}
+	for _, want := range []string{"2025-01: 1 transaction(s), total 10.00", "2025-02: 2 transaction(s), total 20.00",
+		"Imbalance-EUR (with Assets:Checking)", "Orphan-EUR", "Total: 3 transaction(s) over 2 month(s), 30.00."} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.UncategorizedTransactions(ctx, "2025-02-01", "2025-02-05", "csv")
+	if err != nil {
+		t.Fatalf("UncategorizedTransactions returned error: %v", err)
+	}
+	want := "date,description,account,amount,other_accounts,transaction_guid\n2025-02-03,CARD 1234,Orphan-EUR,25.00,Assets:Checking,tx8\n"
+	if result != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", result, want)
+	}
+	if _, err := svc.UncategorizedTransactions(ctx, "February", "", ""); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
+
+func TestEquityStatement(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('equity', 'Equity', 'EQUITY', 'root', '', 'eur', 0, 1);
+		INSERT INTO accounts VALUES ('opening', 'Soldes d''ouverture', 'EQUITY', 'equity', '', 'eur', 0, 0);
+		INSERT INTO accounts VALUES ('capital', 'Owner Capital', 'EQUITY', 'equity', '', 'eur', 0, 0);
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, string_val) VALUES ('opening', 'equity-type', 4, 'opening-balance');
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-01-01 10:59:00', '2025-01-01 10:59:00', 'Opening balance');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking', '', 100000, 100, 100000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'opening', '', -100000, 100, -100000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'eur', '2025-02-10 10:59:00', '2025-02-10 10:59:00', 'Capital');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'checking', '', 50000, 100, 50000, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'capital', '', -50000, 100, -50000, 100);
+		INSERT INTO transactions VALUES ('tx9', 'eur', '2025-02-20 10:59:00', '2025-02-20 10:59:00', 'Draw');
+		INSERT INTO splits VALUES ('sp9a', 'tx9', 'checking', '', -20000, 100, -20000, 100);
+		INSERT INTO splits VALUES ('sp9b', 'tx9', 'capital', '', 20000, 100, 20000, 100);
+	`); err != nil {
+		t.Fatalf("add equity: %v", err)
+	}
+
+	result, err := svc.EquityStatement(ctx, "2025-02-01", "2025-02-28", "")
+	if err != nil {
+		t.Fatalf("EquityStatement returned error: %v", err)
+	}
+	for _, want := range []string{"Equity statement from 2025-02-01 to 2025-02-28, in EUR", "Equity at 2025-01-31", "3889.50",
+		"Equity:Soldes d'ouverture", "1000.00", "Retained earnings", "2889.50", "Capital contributions", "+500.00",
+		"Draws", "-200.00", "Net income", "+2958.00", "Equity at 2025-02-28", "7147.50", "Equity:Owner Capital"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.EquityStatement(ctx, "2025-01-01", "2025-02-28", "csv")
+	if err != nil {
+		t.Fatalf("EquityStatement returned error: %v", err)
+	}
+	want := "component,opening,opening_balances,contributions,draws,net_income,closing\n" +
+		"Equity:Owner Capital,0.00,0.00,500.00,200.00,0.00,300.00\n" +
+		"Equity:Soldes d'ouverture,0.00,1000.00,0.00,0.00,0.00,1000.00\n" +
+		"Retained earnings,0.00,0.00,0.00,0.00,5847.50,5847.50\n" +
+		"Total equity,0.00,1000.00,500.00,200.00,5847.50,7147.50\n"
+	if result != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", result, want)
+	}
+	if _, err := svc.EquityStatement(ctx, "2025-03-01", "2025-02-28", ""); err == nil {
+		t.Error("expected an error for a start date after the end date")
+	}
+}
+
+func TestClosingEntries(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Close Book marks its transactions with a slot; older closings are
+	// recognized by their description.
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('retained', 'Retained Earnings', 'EQUITY', 'root', '', 'eur', 0, 0);
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, int64_val) VALUES ('tx7', 'book_closing', 1, 1);
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-28 10:59:00', '2025-02-28 10:59:00', 'Year-end close');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'salary', '', 600000, 100, 600000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'retained', '', -600000, 100, -600000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'eur', '2025-02-28 10:59:00', '2025-02-28 10:59:00', 'Closing Entries');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'groceries', '', -12750, 100, -12750, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'restaurant', '', -2500, 100, -2500, 100);
+		INSERT INTO splits VALUES ('sp8c', 'tx8', 'retained', '', 15250, 100, 15250, 100);
+	`); err != nil {
+		t.Fatalf("add closing transactions: %v", err)
+	}
+
+	totals, err := db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, "2025-01-01", "2025-02-28", false)
+	if err != nil {
+		t.Fatalf("GetAccountTotals returned error: %v", err)
+	}
+	if totals["salary"] != -6000 || totals["groceries"] != 127.5 || totals["restaurant"] != 25 {
+		t.Errorf("expected closing transactions left out, got %v", totals)
+	}
+	totals, err = db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, "2025-01-01", "2025-02-28", true)
+	if err != nil {
+		t.Fatalf("GetAccountTotals returned error: %v", err)
+	}
+	if totals["salary"] != 0 || totals["groceries"] != 0 || totals["restaurant"] != 0 {
+		t.Errorf("expected closing transactions included, got %v", totals)
+	}
+
+	result, err := svc.SpendingByCategory(ctx, "2025-02-01", "2025-02-28", "", "", "", "", "csv")
+	if err != nil {
+		t.Fatalf("SpendingByCategory returned error: %v", err)
+	}
+	if !strings.Contains(result, "Groceries") || !strings.Contains(result, "42.00") {
+		t.Errorf("expected February groceries without the closing entry:\n%s", result)
+	}
+
+	// Left out of equity too, the closed earnings stay in retained earnings.
+	result, err = svc.EquityStatement(ctx, "2025-01-01", "2025-02-28", "csv")
+	if err != nil {
+		t.Fatalf("EquityStatement returned error: %v", err)
+	}
+	want := "component,opening,opening_balances,contributions,draws,net_income,closing\n" +
+		"Retained earnings,0.00,0.00,0.00,0.00,5847.50,5847.50\n" +
+		"Total equity,0.00,0.00,0.00,0.00,5847.50,5847.50\n"
+	if result != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", result, want)
+	}
+}
+
+func TestGetTransactionsSubtotals(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db, WithSubtotals())
+	ctx := context.Background()
+
+	result, err := svc.GetTransactions(ctx, "Checking", "", "", 50, "", "asc", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	for _, want := range []string{"2025-01 subtotal  2889.50 EUR  (3 transactions)", "2025-02 subtotal  2958.00 EUR  (2 transactions)",
+		"Total  5847.50 EUR  (5 transactions)"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.GetTransactions(ctx, "Groceries", "", "", 50, "", "asc", "", "csv")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	want := "date,description,amount,currency,counterparts,counterpart_amounts,transaction_guid,split_guid\n" +
+		"2025-01-20,Supermarket,85.50,EUR,Checking,-85.50,tx2,sp2b\n" +
+		"2025-01,Subtotal,85.50,EUR,,,,\n" +
+		"2025-02-05,Market,42.00,EUR,Checking,-42.00,tx3,sp3b\n" +
+		"2025-02,Subtotal,42.00,EUR,,,,\n" +
+		",Total,127.50,EUR,,,,\n"
+	if result != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", result, want)
+	}
+
+	// Months are only subtotaled in date order, and a paged total says so.
+	result, err = svc.GetTransactions(ctx, "Checking", "", "", 50, "amount", "", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	if strings.Contains(result, "subtotal") || !strings.Contains(result, "Total  5847.50 EUR") {
+		t.Errorf("expected a total only when sorted by amount:\n%s", result)
+	}
+	result, err = svc.GetTransactions(ctx, "Checking", "", "", 2, "", "", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	if !strings.Contains(result, "Total of this page  2958.00 EUR  (2 transactions)") {
+		t.Errorf("expected the total of the page:\n%s", result)
+	}
+}
+
+func TestGetTransactionsMultiSplit(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec(`
+		INSERT INTO commodities VALUES ('usd', 'CURRENCY', 'USD', 'US Dollar', '840', 100);
+		INSERT INTO accounts VALUES ('taxes', 'Taxes', 'EXPENSE', 'expenses', '', 'eur', 0, 0);
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-03-15 10:59:00', '2025-03-15 10:59:00', 'March paycheck');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'checking', '', 240000, 100, 240000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'salary', '', -300000, 100, -300000, 100);
+		INSERT INTO splits VALUES ('sp7c', 'tx7', 'taxes', '', 60000, 100, 60000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'usd', '2025-03-20 10:59:00', '2025-03-20 10:59:00', 'US bookstore');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'checking', '', -3000, 100, -2700, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'restaurant', '', 3000, 100, 2700, 100);
+	`); err != nil {
+		t.Fatalf("add transactions: %v", err)
+	}
+
+	result, err := svc.GetTransactions(ctx, "Checking", "2025-03-01", "2025-03-31", 50, "", "asc", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	for _, want := range []string{"2025-03-15  2400.00 EUR  March paycheck\n", "    Salary                         -3000.00 EUR\n",
+		"    Taxes                          600.00 EUR\n", "2025-03-20  -30.00 USD  US bookstore  [Restaurant]\n"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.GetTransactions(ctx, "Checking", "2025-03-01", "2025-03-31", 50, "", "asc", "", "csv")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	want := "date,description,amount,currency,counterparts,counterpart_amounts,transaction_guid,split_guid\n" +
+		"2025-03-15,March paycheck,2400.00,EUR,Salary; Taxes,-3000.00; 600.00,tx7,sp7a\n" +
+		"2025-03-20,US bookstore,-30.00,USD,Restaurant,30.00,tx8,sp8a\n"
+	if result != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", result, want)
+	}
+}
+
+func TestGetTransactionsShares(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-10 10:59:00', '2025-02-10 10:59:00', 'Sell ACME');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'acme-stock', '', -45000, 100, -40000, 10000);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'brokerage', '', 45000, 100, 45000, 100);
+	`); err != nil {
+		t.Fatalf("add sale: %v", err)
+	}
+
+	result, err := svc.GetTransactions(ctx, "ACME", "", "", 50, "", "asc", "", "")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	for _, want := range []string{"2025-01-10  10.0000 ACME  1000.00 EUR  Buy ACME", "2025-02-10  -4.0000 ACME  -450.00 EUR  Sell ACME"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.With(WithSubtotals()).GetTransactions(ctx, "ACME", "", "", 50, "", "asc", "", "csv")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	want := "date,description,amount,currency,quantity,counterparts,counterpart_amounts,transaction_guid,split_guid\n" +
+		"2025-01-10,Buy ACME,1000.00,EUR,10.0000,Brokerage Cash,-1000.00,tx6,sp6a\n" +
+		"2025-01,Subtotal,1000.00,EUR,10.0000,,,,\n" +
+		"2025-02-10,Sell ACME,-450.00,EUR,-4.0000,Brokerage Cash,450.00,tx7,sp7a\n" +
+		"2025-02,Subtotal,-450.00,EUR,-4.0000,,,,\n" +
+		",Total,550.00,EUR,6.0000,,,,\n"
+	if result != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", result, want)
+	}
+
+	// Currency accounts have no quantity column.
+	result, err = svc.GetTransactions(ctx, "Groceries", "", "", 50, "", "", "", "csv")
+	if err != nil {
+		t.Fatalf("GetTransactions returned error: %v", err)
+	}
+	if strings.Contains(result, "quantity") {
+		t.Errorf("unexpected quantity column for a currency account:\n%s", result)
+	}
+}
+
+func TestChartSpec(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('card', 'Visa', 'CREDIT', 'root', '', 'eur', 0, 0);
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-12 10:59:00', '2025-02-12 10:59:00', 'Dinner');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'restaurant', '', 6000, 100, 6000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'card', '', -6000, 100, -6000, 100);
+	`); err != nil {
+		t.Fatalf("add card: %v", err)
+	}
+
+	type spec struct {
+		Schema string `json:"$schema"`
+		Title  string
+		Data   struct {
+			Values []map[string]any
+		}
+	}
+	chart := func(report string) spec {
+		t.Helper()
+		out, err := svc.ChartSpec(ctx, report, "2025-01-01", "2025-02-28")
+		if err != nil {
+			t.Fatalf("ChartSpec(%s) returned error: %v", report, err)
+		}
+		var s spec
+		if err := json.Unmarshal([]byte(out), &s); err != nil {
+			t.Fatalf("ChartSpec(%s) is not JSON: %v\n%s", report, err, out)
+		}
+		if s.Schema != vegaLiteSchema {
+			t.Errorf("ChartSpec(%s) schema = %q", report, s.Schema)
+		}
+		return s
+	}
+
+	trend := chart(ChartTrend)
+	if len(trend.Data.Values) != 6 {
+		t.Fatalf("expected 2 months of 3 series, got %v", trend.Data.Values)
+	}
+	if v := trend.Data.Values[4]; v["month"] != "2025-02" || v["series"] != "Expenses" || v["amount"] != 102.0 {
+		t.Errorf("unexpected February expenses: %v", v)
+	}
+
+	categories := chart(ChartCategories)
+	if got := fmt.Sprint(categories.Data.Values); got != "[map[amount:127.5 category:Groceries] map[amount:85 category:Restaurant]]" {
+		t.Errorf("unexpected categories: %s", got)
+	}
+
+	netWorth := chart(ChartNetWorth)
+	if !strings.HasPrefix(netWorth.Title, "Net worth at month end") || len(netWorth.Data.Values) != 6 {
+		t.Fatalf("unexpected net worth chart: %+v", netWorth)
+	}
+	if v := netWorth.Data.Values[4]; v["series"] != "Liabilities" || v["amount"] != 60.0 {
+		t.Errorf("expected 60.00 owed on the card at the end of February, got %v", v)
+	}
+	if a, l, n := netWorth.Data.Values[3]["amount"].(float64), netWorth.Data.Values[4]["amount"].(float64), netWorth.Data.Values[5]["amount"].(float64); cents(a-l) != n {
+		t.Errorf("net worth %.2f is not assets %.2f less liabilities %.2f", n, a, l)
+	}
+
+	if _, err := svc.ChartSpec(ctx, "pie", "", ""); err == nil || !strings.Contains(err.Error(), "unsupported chart report") {
+		t.Errorf("expected an unsupported report error, got %v", err)
+	}
+}
+
+func TestMoneyFlowDiagram(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// A paycheck shared between two accounts, and money flowing back to
+	// income, netted against the salary.
+	if _, err := db.db.Exec(`
+		INSERT INTO accounts VALUES ('savings', 'Savings', 'BANK', 'assets', '', 'eur', 0, 0);
+		INSERT INTO transactions VALUES ('tx7', 'eur', '2025-02-16 10:59:00', '2025-02-16 10:59:00', 'Split paycheck');
+		INSERT INTO splits VALUES ('sp7a', 'tx7', 'salary', '', -100000, 100, -100000, 100);
+		INSERT INTO splits VALUES ('sp7b', 'tx7', 'checking', '', 60000, 100, 60000, 100);
+		INSERT INTO splits VALUES ('sp7c', 'tx7', 'savings', '', 40000, 100, 40000, 100);
+		INSERT INTO transactions VALUES ('tx8', 'eur', '2025-02-20 10:59:00', '2025-02-20 10:59:00', 'Refund of salary advance');
+		INSERT INTO splits VALUES ('sp8a', 'tx8', 'checking', '', -5000, 100, -5000, 100);
+		INSERT INTO splits VALUES ('sp8b', 'tx8', 'salary', '', 5000, 100, 5000, 100);
+	`); err != nil {
+		t.Fatalf("add transactions: %v", err)
+	}
+
+	result, err := svc.MoneyFlowDiagram(ctx, "2025-01-01", "2025-02-28", 1, "")
+	if err != nil {
+		t.Fatalf("MoneyFlowDiagram returned error: %v", err)
+	}
+	// Salary 3000 + 3000 + 1000 less the 50 refunded; expenses 127.50 + 25
+	want := "Money flow from 2025-01-01 to 2025-02-28, in EUR:\n\n```mermaid\nsankey-beta\n\nIncome,Assets,6950.00\nAssets,Expenses,152.50\n```\n"
+	if result != want {
+		t.Errorf("unexpected sankey:\n%s", result)
+	}
+
+	result, err = svc.MoneyFlowDiagram(ctx, "2025-02-16", "2025-02-16", 2, "flowchart")
+	if err != nil {
+		t.Fatalf("MoneyFlowDiagram returned error: %v", err)
+	}
+	for _, want := range []string{"flowchart LR", `n0["Income:Salary"]`, `n1["Assets:Checking"]`, "n0 -->|600.00| n1", `n2["Assets:Savings"]`, "n0 -->|400.00| n2"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in flowchart, got:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.MoneyFlowDiagram(ctx, "", "", 1, "pie"); err == nil {
+		t.Error("expected an error for an unsupported style")
+	}
+	if got := sankeyField(`Dining, "fancy"`); got != `"Dining, ""fancy"""` {
+		t.Errorf("sankeyField = %s", got)
+	}
+}
+
+func TestAggregateCache(t *testing.T) {
+	db := setupTestDB(t)
+	cache, err := OpenAggregateCache(filepath.Join(t.TempDir(), "aggregates.db"))
+	if err != nil {
+		t.Fatalf("OpenAggregateCache() returned error: %v", err)
+	}
+	defer cache.Close()
+	plain, cached := NewService(db), NewService(db, WithAggregateCache(cache))
+	ctx := context.Background()
+
+	if changed, err := cache.Sync(ctx, db); err != nil || !changed {
+		t.Fatalf("expected the first sync to fill the cache, got %v, %v", changed, err)
+	}
+	if changed, err := cache.Sync(ctx, db); err != nil || changed {
+		t.Fatalf("expected no change on a second sync, got %v, %v", changed, err)
+	}
+
+	compare := func() {
+		t.Helper()
+		// Whole months from the cache, partial ones from the book, or both.
+		for _, period := range [][2]string{{"2025-01-01", "2025-02-28"}, {"2025-01-01", "2025-02-10"}, {"2025-01-18", "2025-02-28"}, {"2025-01-16", "2025-01-31"}} {
+			want, err := plain.SpendingByCategory(ctx, period[0], period[1], "", "", "", "", "csv")
+			if err != nil {
+				t.Fatalf("SpendingByCategory() returned error: %v", err)
+			}
+			got, err := cached.SpendingByCategory(ctx, period[0], period[1], "", "", "", "", "csv")
+			if err != nil {
+				t.Fatalf("SpendingByCategory() with the cache returned error: %v", err)
+			}
+			if got != want {
+				t.Errorf("spending from %s to %s with the cache:\n%s\nwant:\n%s", period[0], period[1], got, want)
+			}
+		}
+		want, _ := plain.IncomeVsExpenses(ctx, 24, "", "csv")
+		if got, err := cached.IncomeVsExpenses(ctx, 24, "", "csv"); err != nil || got != want {
+			t.Errorf("income vs expenses with the cache:\n%s\nwant:\n%s (%v)", got, want, err)
+		}
+		want, _ = plain.SpendingSeasonality(ctx, "Groceries", "2025-02-28", 2, "csv")
+		if got, err := cached.SpendingSeasonality(ctx, "Groceries", "2025-02-28", 2, "csv"); err != nil || got != want {
+			t.Errorf("seasonality with the cache:\n%s\nwant:\n%s (%v)", got, want, err)
+		}
+	}
+	compare()
+
+	// A change in February is picked up by the next sync.
+	if _, err := db.db.Exec(`UPDATE splits SET value_num = 5000, quantity_num = 5000 WHERE guid = 'sp3b'`); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := cache.Sync(ctx, db); err != nil || !changed {
+		t.Fatalf("expected the sync to update February, got %v, %v", changed, err)
+	}
+	compare()
+	result, err := cached.SpendingByCategory(ctx, "2025-02-01", "2025-02-28", "", "", "", "", "csv")
+	if err != nil || !strings.Contains(result, "50.00") {
+		t.Errorf("expected the changed amount, got:\n%s (%v)", result, err)
+	}
+
+	// Reports on a book file changed since the last sync read the book
+	// rather than wait for the watcher to sync the cache.
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	if _, err := db.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		t.Fatal(err)
+	}
+	book, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() returned error: %v", err)
+	}
+	defer book.Close()
+	fileCache, err := OpenAggregateCache(filepath.Join(t.TempDir(), "book-aggregates.db"))
+	if err != nil {
+		t.Fatalf("OpenAggregateCache() returned error: %v", err)
+	}
+	defer fileCache.Close()
+	svc := NewService(book, WithAggregateCache(fileCache))
+	if err := svc.SyncAggregates(ctx); err != nil {
+		t.Fatalf("SyncAggregates() returned error: %v", err)
+	}
+	rw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rw.Exec(`UPDATE splits SET value_num = 7000, quantity_num = 7000 WHERE guid = 'sp3b'`)
+	rw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if fileCache.current(book) {
+		t.Fatal("expected the cache to be behind the changed book")
+	}
+	result, err = svc.SpendingByCategory(ctx, "2025-02-01", "2025-02-28", "", "", "", "", "csv")
+	if err != nil || !strings.Contains(result, "70.00") {
+		t.Errorf("expected the amount saved since the sync, got:\n%s (%v)", result, err)
+	}
+	if fileCache.current(book) {
+		t.Error("expected the report not to sync the cache")
+	}
+	if changed, err := svc.Refresh(ctx); err != nil || !changed || !fileCache.current(book) {
+		t.Errorf("expected Refresh to sync the cache, got %v, %v", changed, err)
+	}
+	result, err = svc.SpendingByCategory(ctx, "2025-02-01", "2025-02-28", "", "", "", "", "csv")
+	if err != nil || !strings.Contains(result, "70.00") {
+		t.Errorf("expected the synced amount, got:\n%s (%v)", result, err)
+	}
+
+	for _, tt := range []struct {
+		start, end  string
+		first, last string
+		edges       int
+		ok          bool
+	}{
+		{"2025-01-01", "2025-03-31", "2025-01", "2025-03", 0, true},
+		{"2025-01-15", "2025-03-10", "2025-02", "2025-02", 2, true},
+		{"", "2025-02-28", "", "2025-02", 0, true},
+		{"2025-01-15", "2025-02-10", "", "", 0, false},
+		{"2025-01-01", "2025-01-30", "", "", 0, false},
+	} {
+		first, last, edges, ok := wholeMonths(tt.start, tt.end)
+		if first != tt.first || last != tt.last || len(edges) != tt.edges || ok != tt.ok {
+			t.Errorf("wholeMonths(%q, %q) = %q, %q, %v, %v", tt.start, tt.end, first, last, edges, ok)
+		}
+	}
+}
+
+func TestTaxLiability(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := svc.TaxLiability(ctx, "", "", "", "", ""); err == nil {
+		t.Error("expected an error for a book without tax tables")
+	}
+
+	// 20.00 of VAT collected on a sale and 10.00 paid on a purchase in Q1,
+	// the 10.00 due paid in Q2.
+	if _, err := db.db.Exec(`
+		CREATE TABLE taxtable_entries (id INTEGER PRIMARY KEY, taxtable TEXT, account TEXT, amount_num INTEGER, amount_denom INTEGER, type INTEGER);
+		INSERT INTO taxtable_entries (taxtable, account, amount_num, amount_denom, type) VALUES ('tt', 'vat', 20, 1, 2);
+		INSERT INTO accounts VALUES ('vat', 'VAT', 'LIABILITY', 'root', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('v1', 'eur', '2025-01-15 00:00:00', '2025-01-15 00:00:00', 'Sale');
+		INSERT INTO splits VALUES ('v1a', 'v1', 'checking', '', 12000, 100, 12000, 100);
+		INSERT INTO splits VALUES ('v1b', 'v1', 'salary', '', -10000, 100, -10000, 100);
+		INSERT INTO splits VALUES ('v1c', 'v1', 'vat', '', -2000, 100, -2000, 100);
+		INSERT INTO transactions VALUES ('v2', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Purchase');
+		INSERT INTO splits VALUES ('v2a', 'v2', 'checking', '', -6000, 100, -6000, 100);
+		INSERT INTO splits VALUES ('v2b', 'v2', 'groceries', '', 5000, 100, 5000, 100);
+		INSERT INTO splits VALUES ('v2c', 'v2', 'vat', '', 1000, 100, 1000, 100);
+		INSERT INTO transactions VALUES ('v3', 'eur', '2025-04-10 00:00:00', '2025-04-10 00:00:00', 'VAT return');
+		INSERT INTO splits VALUES ('v3a', 'v3', 'vat', '', 1000, 100, 1000, 100);
+		INSERT INTO splits VALUES ('v3b', 'v3', 'checking', '', -1000, 100, -1000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.TaxLiability(ctx, "", "", "2025-06-30", "", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "period,account,output_tax,input_tax,net_due,settled\n" +
+		"2025-Q1,VAT,20.00,10.00,10.00,0.00\n" +
+		"2025-Q2,VAT,0.00,0.00,0.00,10.00\n"
+	if result != want {
+		t.Errorf("TaxLiability() = %q, want %q", result, want)
+	}
+
+	result, err = svc.TaxLiability(ctx, "VAT", "2025-01-01", "2025-03-31", PeriodMonth, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Tax liability per month", "Net due for 2025-01: 20.00", "Net due for 2025-02: -10.00"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.TaxLiability(ctx, "VAT", "", "", "week", ""); err == nil {
+		t.Error("expected an error for an unsupported period")
+	}
+}
+
+func TestTaxReport(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.TaxReport(ctx, 2025, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "No accounts are marked tax-related") {
+		t.Errorf("expected no tax-related accounts, got:\n%s", result)
+	}
+
+	if _, err := db.db.Exec(`
+		CREATE TABLE slots (id INTEGER PRIMARY KEY, obj_guid TEXT, name TEXT, slot_type INTEGER, int64_val INTEGER,
+			string_val TEXT, double_val REAL, numeric_val_num INTEGER, numeric_val_denom INTEGER);
+		INSERT INTO slots (obj_guid, name, slot_type, int64_val, string_val) VALUES
+			('salary', 'tax-related', 1, 1, NULL),
+			('salary', 'tax-US/code', 4, NULL, 'N261'),
+			('groceries', 'tax-related', 1, 1, NULL),
+			('restaurant', 'tax-related', 1, 0, NULL),
+			('restaurant', 'tax-US/code', 4, NULL, 'N300');
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = svc.TaxReport(ctx, 2025, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "code,account,type,total\n" +
+		"N261,Income:Salary,INCOME,6000.00\n" +
+		",Expenses:Groceries,EXPENSE,127.50\n"
+	if result != want {
+		t.Errorf("TaxReport() = %q, want %q", result, want)
+	}
+
+	result, err = svc.TaxReport(ctx, 2025, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Tax report for 2025, in EUR", "N261", "(no tax code)", "6000.00"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestVendorPayments(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	// Without business records, expenses paid by description.
+	result, err := svc.VendorPayments(ctx, 2025, 0, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "year,vendor,payments,total,by_card\n" +
+		"2025,Market,1,42.00,0.00\n" +
+		"2025,Pizza place,1,25.00,0.00\n" +
+		"2025,Supermarket,1,85.50,0.00\n"
+	if result != want {
+		t.Errorf("VendorPayments() = %q, want %q", result, want)
+	}
+
+	// A bill of 500.00 from ACME Supplies, paid 300.00 from checking and
+	// 200.00 by card.
+	if _, err := db.db.Exec(`
+		ALTER TABLE splits ADD COLUMN lot_guid TEXT;
+		CREATE TABLE vendors (guid TEXT PRIMARY KEY, name TEXT, id TEXT, notes TEXT, currency TEXT, active INTEGER);
+		CREATE TABLE invoices (guid TEXT PRIMARY KEY, id TEXT, owner_type INTEGER, owner_guid TEXT, post_lot TEXT);
+		INSERT INTO vendors VALUES ('v-acme', 'ACME Supplies', '000001', '', 'eur', 1);
+		INSERT INTO invoices VALUES ('bill1', '000001', 4, 'v-acme', 'lot1');
+		INSERT INTO accounts VALUES ('ap', 'Accounts Payable', 'PAYABLE', 'root', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('visa', 'Visa', 'CREDIT', 'root', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('b1', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Bill 000001');
+		INSERT INTO splits VALUES ('b1a', 'b1', 'ap', '', -50000, 100, -50000, 100, 'lot1');
+		INSERT INTO splits VALUES ('b1b', 'b1', 'groceries', '', 50000, 100, 50000, 100, NULL);
+		INSERT INTO transactions VALUES ('p1', 'eur', '2025-03-20 00:00:00', '2025-03-20 00:00:00', 'Payment');
+		INSERT INTO splits VALUES ('p1a', 'p1', 'ap', '', 50000, 100, 50000, 100, 'lot1');
+		INSERT INTO splits VALUES ('p1b', 'p1', 'checking', '', -30000, 100, -30000, 100, NULL);
+		INSERT INTO splits VALUES ('p1c', 'p1', 'visa', '', -20000, 100, -20000, 100, NULL);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = svc.VendorPayments(ctx, 0, 0, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "year,vendor,payments,total,by_card\n" +
+		"2025,ACME Supplies,1,500.00,200.00\n"
+	if result != want {
+		t.Errorf("VendorPayments() = %q, want %q", result, want)
+	}
+
+	result, err = svc.VendorPayments(ctx, 2025, 600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "No vendor payments found." {
+		t.Errorf("expected no vendor paid 600.00, got:\n%s", result)
+	}
+	result, err = svc.VendorPayments(ctx, 2025, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"from the business tables", "ACME Supplies", "(200.00 by card)", "Total 2025: 500.00 to 1 vendor(s)"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestCustomerStatement(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	if _, err := svc.CustomerStatement(ctx, "", "", "", ""); err == nil {
+		t.Error("expected an error for a book without A/R accounts")
+	}
+
+	// Invoice 000001 of 1000.00 in January, 600.00 paid in February, and
+	// invoice 000002 of 200.00 for a job of the same customer in March.
+	if _, err := db.db.Exec(`
+		ALTER TABLE splits ADD COLUMN lot_guid TEXT;
+		CREATE TABLE customers (guid TEXT PRIMARY KEY, name TEXT, id TEXT, notes TEXT, active INTEGER);
+		CREATE TABLE jobs (guid TEXT PRIMARY KEY, id TEXT, name TEXT, reference TEXT, active INTEGER, owner_type INTEGER, owner_guid TEXT);
+		CREATE TABLE invoices (guid TEXT PRIMARY KEY, id TEXT, owner_type INTEGER, owner_guid TEXT, post_txn TEXT, post_lot TEXT);
+		INSERT INTO customers VALUES ('c-bob', 'Bob Builder', '000001', '', 1);
+		INSERT INTO jobs VALUES ('j-roof', '000001', 'Roof', '', 1, 2, 'c-bob');
+		INSERT INTO invoices VALUES ('inv1', '000001', 2, 'c-bob', 'i1', 'lot1'), ('inv2', '000002', 3, 'j-roof', 'i2', 'lot2');
+		INSERT INTO accounts VALUES ('ar', 'Accounts Receivable', 'RECEIVABLE', 'root', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('i1', 'eur', '2025-01-05 00:00:00', '2025-01-05 00:00:00', 'Bob Builder');
+		INSERT INTO splits VALUES ('i1a', 'i1', 'ar', '', 100000, 100, 100000, 100, 'lot1');
+		INSERT INTO splits VALUES ('i1b', 'i1', 'salary', '', -100000, 100, -100000, 100, NULL);
+		INSERT INTO transactions VALUES ('p1', 'eur', '2025-02-10 00:00:00', '2025-02-10 00:00:00', 'Payment');
+		INSERT INTO splits VALUES ('p1a', 'p1', 'ar', '', -60000, 100, -60000, 100, 'lot1');
+		INSERT INTO splits VALUES ('p1b', 'p1', 'checking', '', 60000, 100, 60000, 100, NULL);
+		INSERT INTO transactions VALUES ('i2', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Bob Builder');
+		INSERT INTO splits VALUES ('i2a', 'i2', 'ar', '', 20000, 100, 20000, 100, 'lot2');
+		INSERT INTO splits VALUES ('i2b', 'i2', 'salary', '', -20000, 100, -20000, 100, NULL);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := svc.CustomerStatement(ctx, "", "2025-02-01", "2025-03-31", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "customer,opening_balance,invoiced,paid,adjusted,closing_balance\n" +
+		"Bob Builder,1000.00,200.00,600.00,0.00,600.00\n"
+	if result != want {
+		t.Errorf("CustomerStatement() = %q, want %q", result, want)
+	}
+
+	result, err = svc.CustomerStatement(ctx, "bob", "", "2025-03-31", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Bob Builder", "Invoice 000001", "Payment", "Invoice 000002", "Outstanding:           600.00"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+
+	if _, err := svc.CustomerStatement(ctx, "alice", "", "", ""); err == nil {
+		t.Error("expected an error for an unknown customer")
+	}
+}
+
+func TestJobReport(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db)
+	ctx := context.Background()
+
+	result, err := svc.JobReport(ctx, "", "", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "The book has no jobs") {
+		t.Errorf("expected no jobs, got:\n%s", result)
+	}
+
+	// The Roof job of Bob Builder: invoiced 1000.00 (plus 200.00 of VAT),
+	// with a bill of 300.00 billed to it. The Fence job has no postings.
+	if _, err := db.db.Exec(`
+		CREATE TABLE customers (guid TEXT PRIMARY KEY, name TEXT, id TEXT, notes TEXT, active INTEGER);
+		CREATE TABLE jobs (guid TEXT PRIMARY KEY, id TEXT, name TEXT, reference TEXT, active INTEGER, owner_type INTEGER, owner_guid TEXT);
+		CREATE TABLE invoices (guid TEXT PRIMARY KEY, id TEXT, owner_type INTEGER, owner_guid TEXT, post_txn TEXT, post_lot TEXT,
+			billto_type INTEGER, billto_guid TEXT);
+		INSERT INTO customers VALUES ('c-bob', 'Bob Builder', '000001', '', 1);
+		INSERT INTO jobs VALUES ('j-roof', '000001', 'Roof', '', 1, 2, 'c-bob'), ('j-fence', '000002', 'Fence', '', 0, 2, 'c-bob');
+		INSERT INTO invoices VALUES ('inv1', '000001', 3, 'j-roof', 'i1', NULL, NULL, NULL);
+		INSERT INTO invoices VALUES ('bill1', '000001', 4, 'v-acme', 'b1', NULL, 3, 'j-roof');
+		INSERT INTO accounts VALUES ('ar', 'Accounts Receivable', 'RECEIVABLE', 'root', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('ap', 'Accounts Payable', 'PAYABLE', 'root', '', '', 0, 0);
+		INSERT INTO accounts VALUES ('vat', 'VAT', 'LIABILITY', 'root', '', '', 0, 0);
+		INSERT INTO transactions VALUES ('i1', 'eur', '2025-03-01 00:00:00', '2025-03-01 00:00:00', 'Bob Builder');
+		INSERT INTO splits VALUES ('i1a', 'i1', 'ar', '', 120000, 100, 120000, 100);
+		INSERT INTO splits VALUES ('i1b', 'i1', 'salary', '', -100000, 100, -100000, 100);
+		INSERT INTO splits VALUES ('i1c', 'i1', 'vat', '', -20000, 100, -20000, 100);
+		INSERT INTO transactions VALUES ('b1', 'eur', '2025-03-05 00:00:00', '2025-03-05 00:00:00', 'ACME Supplies');
+		INSERT INTO splits VALUES ('b1a', 'b1', 'ap', '', -30000, 100, -30000, 100);
+		INSERT INTO splits VALUES ('b1b', 'b1', 'groceries', '', 30000, 100, 30000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = svc.JobReport(ctx, "2025-01-01", "2025-12-31", false, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "job_id,job,owner,active,invoices,bills,revenue,expenses,profit,margin\n" +
+		"000001,Roof,Bob Builder,true,1,1,1000.00,300.00,700.00,70.0\n"
+	if result != want {
+		t.Errorf("JobReport() = %q, want %q", result, want)
+	}
+
+	result, err = svc.JobReport(ctx, "", "2025-12-31", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Bob Builder", "000001 Roof", "margin 70.0%", "000002 Fence (closed)", "TOTAL: revenue 1000.00, expenses 300.00, profit 700.00 EUR"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestResolveDate(t *testing.T) {
+	today := time.Date(2025, 5, 14, 15, 30, 0, 0, time.Local) // a Wednesday
+	for _, tc := range []struct {
+		value      string
+		start, end string
+	}{
+		{"2025-02-03", "2025-02-03", "2025-02-03"},
+		{"today", "2025-05-14", "2025-05-14"},
+		{"yesterday", "2025-05-13", "2025-05-13"},
+		{"this_week", "2025-05-12", "2025-05-18"},
+		{"last_week", "2025-05-05", "2025-05-11"},
+		{"last_month", "2025-04-01", "2025-04-30"},
+		{"this_quarter", "2025-04-01", "2025-06-30"},
+		{"last_quarter", "2025-01-01", "2025-03-31"},
+		{"last_year", "2024-01-01", "2024-12-31"},
+		{"ytd", "2025-01-01", "2025-05-14"},
+		{"last_90_days", "2025-02-14", "2025-05-14"},
+		{"last_3_months", "2025-02-15", "2025-05-14"},
+		{"Year to date", "2025-01-01", "2025-05-14"},
+		{"previous month", "2025-04-01", "2025-04-30"},
+		{"2 weeks ago", "2025-04-30", "2025-04-30"},
+	} {
+		start, err := resolveDate(tc.value, false, today, time.Monday)
+		if err != nil {
+			t.Errorf("resolveDate(%q) returned error: %v", tc.value, err)
+			continue
+		}
+		end, _ := resolveDate(tc.value, true, today, time.Monday)
+		if start != tc.start || end != tc.end {
+			t.Errorf("resolveDate(%q) = %s to %s, want %s to %s", tc.value, start, end, tc.start, tc.end)
+		}
+	}
+	if _, err := resolveDate("someday", false, today, time.Monday); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+
+	svc := NewService(setupTestDB(t))
+	args := map[string]any{"start_date": "last_year", "compare_start_date": "last_year", "format": "csv"}
+	if err := svc.ResolveDateArgs(args); err != nil {
+		t.Fatal(err)
+	}
+	year := time.Now().Year() - 1
+	if args["start_date"] != fmt.Sprintf("%d-01-01", year) || args["end_date"] != fmt.Sprintf("%d-12-31", year) || args["format"] != "csv" {
+		t.Errorf("ResolveDateArgs() = %v", args)
+	}
+	if args["compare_start_date"] != args["start_date"] || args["compare_end_date"] != args["end_date"] {
+		t.Errorf("ResolveDateArgs() = %v, want the compared period resolved too", args)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	// Saved by an older GnuCash in Paris: the local midnight of March 1.
+	if _, err := db.db.Exec(`
+		INSERT INTO transactions VALUES ('tz1', 'eur', '2025-02-28 23:00:00', '2025-02-28 23:00:00', 'Night shop');
+		INSERT INTO splits VALUES ('tz1a', 'tz1', 'checking', '', -1000, 100, -1000, 100);
+		INSERT INTO splits VALUES ('tz1b', 'tz1', 'groceries', '', 1000, 100, 1000, 100);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewService(db).GetTransactions(ctx, "Checking", "2025-03-01", "2025-03-31", 0, "", "", "", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(result, "Night shop") {
+		t.Errorf("expected the transaction on February 28 in UTC, got:\n%s", result)
+	}
+
+	svc := NewService(db, WithTimezone(time.FixedZone("CET", 3600)))
+	result, err = svc.GetTransactions(ctx, "Checking", "2025-03-01", "2025-03-31", 0, "", "", "", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "2025-03-01") || !strings.Contains(result, "Night shop") {
+		t.Errorf("expected the transaction on March 1 in Paris, got:\n%s", result)
+	}
+	totals, err := svc.monthlyIncomeExpenses(ctx, "2025-02-01", "2025-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expenses []string
+	for _, m := range totals {
+		if m.AccType == "EXPENSE" {
+			expenses = append(expenses, m.Month+" "+m.Total.String())
+		}
+	}
+	if want := []string{"2025-02 42", "2025-03 10"}; !slices.Equal(expenses, want) {
+		t.Errorf("monthly expenses = %v, want %v", expenses, want)
+	}
+
+	if _, err := ParseTimezone("Mars/Olympus"); err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+	if day, err := ParseWeekday("Sun"); err != nil || day != time.Sunday {
+		t.Errorf("ParseWeekday(Sun) = %v, %v", day, err)
+	}
+}
+
+func TestLegacyTimestamps(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	// Books of GnuCash before 2.6 store 14-digit numbers.
+	if _, err := db.db.Exec(`
+		UPDATE transactions SET post_date = CAST(strftime('%Y%m%d%H%M%S', post_date) AS INTEGER),
+		                        enter_date = CAST(strftime('%Y%m%d%H%M%S', enter_date) AS INTEGER);
+		UPDATE prices SET date = CAST(strftime('%Y%m%d%H%M%S', date) AS INTEGER);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.detectTimestampLayout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !db.legacy {
+		t.Fatal("expected legacy timestamps to be detected")
+	}
+	svc := NewService(db)
+
+	result, err := svc.GetTransactions(ctx, "Checking", "2025-01-16", "2025-01-31", 0, "", "", "", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "2025-01-20") || !strings.Contains(result, "2025-01-25") ||
+		strings.Contains(result, "2025-01-15") || strings.Contains(result, "2025-02") {
+		t.Errorf("expected the transactions of January 16 to 31, got:\n%s", result)
+	}
+	result, err = svc.GetBalance(ctx, "Checking", "2025-01-31", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "2889.50") {
+		t.Errorf("expected a balance of 2889.50 at the end of January, got:\n%s", result)
+	}
+	totals, err := svc.monthlyIncomeExpenses(ctx, "2025-01-01", "2025-02-28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 4 || totals[0].Month != "2025-01" {
+		t.Errorf("monthly totals = %v", totals)
+	}
+	// Imports find the lines already in the book.
+	keys, err := db.importKeys(ctx, "checking", time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 25, 0, 0, 0, 0, time.UTC), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[importKey(time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), -8550, "Supermarket")] != 1 {
+		t.Errorf("import keys = %v", keys)
+	}
+
+	// Written transactions follow the book's layout.
+	if got := db.timestamp(neutralTime(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))); got != "20250301105900" {
+		t.Errorf("timestamp() = %s", got)
+	}
+}
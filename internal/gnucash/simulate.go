@@ -0,0 +1,275 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// simChange is one hypothetical change to the monthly income and expenses
+// of Simulate.
+type simChange struct {
+	Text    string
+	Type    string   // INCOME or EXPENSE
+	Account *Account // nil for all the income or expenses
+	Remove  bool
+	Percent bool
+	Amount  float64 // monthly amount, or percentage when Percent
+}
+
+// simTotals are average monthly amounts by account, income positive, with
+// the amounts added to all the income or expenses keyed by type.
+type simTotals map[string]float64
+
+// sums returns the monthly income and expenses of t.
+func (t simTotals) sums(accounts map[string]*Account) (income, expenses float64) {
+	for key, v := range t {
+		accountType := key
+		if acc := accounts[key]; acc != nil {
+			accountType = acc.AccountType
+		}
+		if accountType == "INCOME" {
+			income += v
+		} else {
+			expenses += v
+		}
+	}
+	return income, expenses
+}
+
+// apply applies c to t.
+func (t simTotals) apply(c simChange, accounts map[string]*Account) {
+	var keys []string
+	if c.Account != nil {
+		keys = descendantGUIDs(c.Account)
+	} else {
+		for key := range t {
+			accountType := key
+			if acc := accounts[key]; acc != nil {
+				accountType = acc.AccountType
+			}
+			if accountType == c.Type {
+				keys = append(keys, key)
+			}
+		}
+	}
+	switch {
+	case c.Remove:
+		for _, key := range keys {
+			delete(t, key)
+		}
+	case c.Percent:
+		for _, key := range keys {
+			t[key] *= 1 + c.Amount/100
+		}
+	case c.Account != nil:
+		t[c.Account.GUID] += c.Amount
+	default:
+		t[c.Type] += c.Amount
+	}
+}
+
+// parseSimChanges parses the changes of Simulate, separated by semicolons or
+// new lines. Each is "remove <account>", "<target> <amount>" or "add
+// <amount> <target>", the target being income, expenses or an income or
+// expense account, and the amount a monthly amount or a percentage: "remove
+// Netflix", "income +10%", "Groceries -50", "add 300/month expense".
+func (s *Service) parseSimChanges(ctx context.Context, changes string) ([]simChange, error) {
+	var parsed []simChange
+	for _, text := range strings.FieldsFunc(changes, func(r rune) bool { return r == ';' || r == '\n' }) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		c := simChange{Text: text}
+		var target, amount string
+		lower := strings.ToLower(text)
+		switch {
+		case strings.HasPrefix(lower, "remove "):
+			c.Remove, target = true, strings.TrimSpace(text[len("remove "):])
+		case strings.HasPrefix(lower, "add "):
+			rest := strings.TrimSpace(text[len("add "):])
+			amount, target, _ = strings.Cut(rest, " ")
+			target = strings.TrimPrefix(strings.TrimSpace(target), "to ")
+		default:
+			i := strings.LastIndex(text, " ")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid change '%s' (expected e.g. 'remove Netflix', 'income +10%%' or 'add 300/month expense')", text)
+			}
+			target, amount = strings.TrimSpace(text[:i]), text[i+1:]
+		}
+		if !c.Remove {
+			amount = strings.TrimSuffix(strings.TrimSuffix(amount, "/month"), "/mo")
+			amount = strings.Map(func(r rune) rune {
+				if strings.ContainsRune("€$£¥", r) {
+					return -1 // currency symbols
+				}
+				return r
+			}, amount)
+			c.Percent = strings.HasSuffix(amount, "%")
+			v, err := strconv.ParseFloat(strings.TrimSuffix(amount, "%"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid amount in change '%s' (expected e.g. +300, -50 or +10%%)", text)
+			}
+			c.Amount = v
+		}
+		switch strings.ToLower(target) {
+		case "income":
+			c.Type = "INCOME"
+		case "expense", "expenses":
+			c.Type = "EXPENSE"
+		case "":
+			return nil, fmt.Errorf("no account or income/expenses in change '%s'", text)
+		default:
+			acc, err := s.resolveAccount(ctx, target)
+			if err != nil {
+				return nil, err
+			}
+			if acc.AccountType != "INCOME" && acc.AccountType != "EXPENSE" {
+				return nil, fmt.Errorf("%s is a %s account; changes apply to income and expense accounts", acc.FullName, acc.AccountType)
+			}
+			c.Type, c.Account = acc.AccountType, acc
+		}
+		if c.Remove && c.Account == nil {
+			return nil, fmt.Errorf("remove takes an account, e.g. 'remove Expenses:Subscriptions:Netflix'")
+		}
+		parsed = append(parsed, c)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no change given")
+	}
+	return parsed, nil
+}
+
+// Simulate recomputes the average monthly income, expenses and net of the
+// months complete months before date (today when empty) after hypothetical
+// changes (see parseSimChanges), and projects the cash of the BANK and CASH
+// accounts at date over the next horizon months with and without them. The
+// net of income and expenses stands for the monthly cash flow.
+func (s *Service) Simulate(ctx context.Context, changes, date string, months, horizon int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if months <= 0 {
+		months = 6
+	}
+	if horizon <= 0 {
+		horizon = 12
+	}
+	if date == "" {
+		date = s.now().Format("2006-01-02")
+	}
+	asOf, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date '%s': %w", date, err)
+	}
+	parsed, err := s.parseSimChanges(ctx, changes)
+	if err != nil {
+		return "", err
+	}
+	windowEnd := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	if asOf.AddDate(0, 0, 1).Day() == 1 {
+		windowEnd = asOf // date closes its month
+	}
+	windowStart := time.Date(windowEnd.Year(), windowEnd.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1-months, 0)
+	totals, err := s.db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, windowStart.Format("2006-01-02"), windowEnd.Format("2006-01-02"), s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var cashGUIDs []string
+	for _, acc := range accounts {
+		if acc.AccountType == "BANK" || acc.AccountType == "CASH" {
+			cashGUIDs = append(cashGUIDs, acc.GUID)
+		}
+	}
+	var cash float64
+	if len(cashGUIDs) > 0 {
+		balance, err := s.db.GetBalanceForAccounts(ctx, cashGUIDs, date)
+		if err != nil {
+			return "", err
+		}
+		cash = balance.Float64()
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	baseline := make(simTotals)
+	for guid, total := range totals {
+		acc := accounts[guid]
+		if acc == nil {
+			continue
+		}
+		if acc.AccountType == "INCOME" {
+			total = -total // credited
+		}
+		baseline[guid] = total / float64(months)
+	}
+	income, expenses := baseline.sums(accounts)
+	baseNet := income - expenses
+
+	simulated := make(simTotals, len(baseline))
+	for guid, v := range baseline {
+		simulated[guid] = v
+	}
+	effects := make([]float64, len(parsed))
+	net := baseNet
+	for i, c := range parsed {
+		simulated.apply(c, accounts)
+		in, out := simulated.sums(accounts)
+		effects[i], net = in-out-net, in-out
+	}
+	simIncome, simExpenses := simulated.sums(accounts)
+
+	type projected struct {
+		month             string
+		baseline, changed float64
+	}
+	first := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+	rows := make([]projected, horizon)
+	for i := range rows {
+		n := float64(i + 1)
+		rows[i] = projected{first.AddDate(0, i+1, 0).Format("2006-01"), cash + baseNet*n, cash + net*n}
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"month", "baseline_net", "simulated_net", "baseline_balance", "simulated_balance"}}
+		for _, r := range rows {
+			t.add(r.month, fmt.Sprintf("%.2f", baseNet), fmt.Sprintf("%.2f", net), fmt.Sprintf("%.2f", r.baseline), fmt.Sprintf("%.2f", r.changed))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "What-if simulation from the monthly averages of %s to %s, in %s:\n\n",
+		windowStart.Format("2006-01"), windowEnd.Format("2006-01"), cur.Mnemonic)
+	fmt.Fprintf(&sb, "  %-40s income %12.2f  expenses %12.2f  net %+12.2f\n", "Baseline", income, expenses, baseNet)
+	for i, c := range parsed {
+		fmt.Fprintf(&sb, "  %-40s net %+12.2f a month\n", c.Text, effects[i])
+	}
+	fmt.Fprintf(&sb, "  %-40s income %12.2f  expenses %12.2f  net %+12.2f\n", "Simulated", simIncome, simExpenses, net)
+
+	fmt.Fprintf(&sb, "\nProjected cash (BANK and CASH accounts, %.2f on %s):\n", cash, date)
+	fmt.Fprintf(&sb, "  %-8s %14s %14s %14s\n", "Month", "Baseline", "Simulated", "Difference")
+	for _, r := range rows {
+		fmt.Fprintf(&sb, "  %-8s %14.2f %14.2f %+14.2f\n", r.month, r.baseline, r.changed, r.changed-r.baseline)
+	}
+	fmt.Fprintf(&sb, "\nOver %d month(s), the changes amount to %+.2f.\n", horizon, (net-baseNet)*float64(horizon))
+	if net < 0 {
+		if cash <= 0 {
+			sb.WriteString("With the changes, the monthly net is negative and there is no cash left.\n")
+		} else {
+			runway := cash / -net
+			fmt.Fprintf(&sb, "With the changes, cash runs out in %.1f months at this pace.\n", runway)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,184 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SnapshotStore records copies of the chart of accounts in a separate,
+// writable SQLite database so changes to the hierarchy can be reported later.
+// The GnuCash book itself is never written to.
+type SnapshotStore struct {
+	db *sql.DB
+}
+
+// ChartChange describes one difference between two consecutive chart snapshots.
+type ChartChange struct {
+	At   time.Time
+	Kind string // added, removed, renamed, moved
+	GUID string
+	Old  string // full name before the change (empty for added)
+	New  string // full name after the change (empty for removed)
+}
+
+type chartEntry struct {
+	Name       string
+	ParentGUID string
+	FullName   string
+}
+
+// OpenSnapshotStore opens or creates a snapshot database at path.
+func OpenSnapshotStore(path string) (*SnapshotStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot store: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS chart_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			taken_at TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS chart_snapshot_accounts (
+			snapshot_id INTEGER NOT NULL,
+			guid TEXT NOT NULL,
+			name TEXT NOT NULL,
+			parent_guid TEXT NOT NULL,
+			full_name TEXT NOT NULL,
+			PRIMARY KEY (snapshot_id, guid)
+		);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create snapshot tables: %w", err)
+	}
+	return &SnapshotStore{db: db}, nil
+}
+
+// Close closes the snapshot database.
+func (st *SnapshotStore) Close() error {
+	return st.db.Close()
+}
+
+// Record stores the chart as a new snapshot unless it is identical to the
+// latest one. It reports whether a snapshot was written.
+func (st *SnapshotStore) Record(ctx context.Context, accounts map[string]*Account, at time.Time) (bool, error) {
+	current := make(map[string]chartEntry, len(accounts))
+	for guid, acc := range accounts {
+		current[guid] = chartEntry{Name: acc.Name, ParentGUID: acc.ParentGUID, FullName: acc.FullName}
+	}
+
+	snapshots, err := st.load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(snapshots) > 0 && len(diffCharts(snapshots[len(snapshots)-1].accounts, current)) == 0 {
+		return false, nil
+	}
+
+	tx, err := st.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin snapshot: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO chart_snapshots (taken_at) VALUES (?)`, at.UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, fmt.Errorf("insert snapshot: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("snapshot id: %w", err)
+	}
+	for guid, e := range current {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO chart_snapshot_accounts (snapshot_id, guid, name, parent_guid, full_name)
+			VALUES (?, ?, ?, ?, ?)
+		`, id, guid, e.Name, e.ParentGUID, e.FullName); err != nil {
+			return false, fmt.Errorf("insert snapshot account: %w", err)
+		}
+	}
+	return true, tx.Commit()
+}
+
+// History returns the first snapshot time, the number of snapshots and all
+// changes between consecutive snapshots in chronological order.
+func (st *SnapshotStore) History(ctx context.Context) (time.Time, int, []ChartChange, error) {
+	snapshots, err := st.load(ctx)
+	if err != nil || len(snapshots) == 0 {
+		return time.Time{}, 0, nil, err
+	}
+	var changes []ChartChange
+	for i := 1; i < len(snapshots); i++ {
+		for _, c := range diffCharts(snapshots[i-1].accounts, snapshots[i].accounts) {
+			c.At = snapshots[i].at
+			changes = append(changes, c)
+		}
+	}
+	return snapshots[0].at, len(snapshots), changes, nil
+}
+
+type chartSnapshot struct {
+	at       time.Time
+	accounts map[string]chartEntry
+}
+
+func (st *SnapshotStore) load(ctx context.Context) ([]chartSnapshot, error) {
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT s.id, s.taken_at, a.guid, a.name, a.parent_guid, a.full_name
+		FROM chart_snapshots s
+		JOIN chart_snapshot_accounts a ON a.snapshot_id = s.id
+		ORDER BY s.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []chartSnapshot
+	lastID := int64(-1)
+	for rows.Next() {
+		var id int64
+		var takenAt, guid string
+		var e chartEntry
+		if err := rows.Scan(&id, &takenAt, &guid, &e.Name, &e.ParentGUID, &e.FullName); err != nil {
+			return nil, fmt.Errorf("scan snapshot: %w", err)
+		}
+		if id != lastID {
+			at, _ := time.Parse(time.RFC3339, takenAt)
+			snapshots = append(snapshots, chartSnapshot{at: at, accounts: make(map[string]chartEntry)})
+			lastID = id
+		}
+		snapshots[len(snapshots)-1].accounts[guid] = e
+	}
+	return snapshots, rows.Err()
+}
+
+// diffCharts lists the accounts added, removed, renamed or re-parented
+// between two snapshots, ordered by full name.
+func diffCharts(before, after map[string]chartEntry) []ChartChange {
+	var changes []ChartChange
+	for guid, a := range after {
+		b, ok := before[guid]
+		switch {
+		case !ok:
+			changes = append(changes, ChartChange{Kind: "added", GUID: guid, New: a.FullName})
+		case b.ParentGUID != a.ParentGUID:
+			changes = append(changes, ChartChange{Kind: "moved", GUID: guid, Old: b.FullName, New: a.FullName})
+		case b.Name != a.Name:
+			changes = append(changes, ChartChange{Kind: "renamed", GUID: guid, Old: b.FullName, New: a.FullName})
+		}
+	}
+	for guid, b := range before {
+		if _, ok := after[guid]; !ok {
+			changes = append(changes, ChartChange{Kind: "removed", GUID: guid, Old: b.FullName})
+		}
+	}
+	slices.SortFunc(changes, func(x, y ChartChange) int {
+		return cmp.Compare(x.New+x.Old, y.New+y.Old)
+	})
+	return changes
+}
@@ -0,0 +1,206 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSQLRows = 100
+	maxSQLRows     = 1000
+	sqlTimeout     = 5 * time.Second
+)
+
+// checkSelect validates an ad-hoc statement: a single SELECT (or WITH ...
+// SELECT) query, optionally ending with a semicolon. Comments are allowed.
+// It returns the statement without its trailing semicolon.
+func checkSelect(stmt string) (string, error) {
+	var sb strings.Builder
+	ended := false
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		switch {
+		case c == '-' && strings.HasPrefix(stmt[i:], "--"):
+			if j := strings.IndexByte(stmt[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(stmt)
+			}
+			sb.WriteByte(' ')
+			continue
+		case c == '/' && strings.HasPrefix(stmt[i:], "/*"):
+			j := strings.Index(stmt[i+2:], "*/")
+			if j < 0 {
+				return "", errors.New("unterminated comment")
+			}
+			i += j + 3
+			sb.WriteByte(' ')
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			sb.WriteByte(c)
+			continue
+		}
+		if ended {
+			return "", errors.New("only a single statement is allowed")
+		}
+		switch c {
+		case ';':
+			ended = true
+		case '\'', '"', '`', '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(stmt[i+1:], end)
+			if j < 0 {
+				return "", errors.New("unterminated quoted string or identifier")
+			}
+			sb.WriteString(stmt[i : i+j+2])
+			i += j + 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	cleaned := strings.TrimSpace(sb.String())
+	if cleaned == "" {
+		return "", errors.New("empty statement")
+	}
+	end := strings.IndexFunc(cleaned, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(cleaned)
+	}
+	switch first := strings.ToUpper(cleaned[:end]); first {
+	case "SELECT", "WITH":
+		return cleaned, nil
+	default:
+		return "", fmt.Errorf("only SELECT statements are allowed, got '%s'", first)
+	}
+}
+
+// QuerySQL runs a validated SELECT statement on a connection switched to
+// query_only mode, returning at most maxRows rows and whether more exist.
+// The book is opened read-only anyway; query_only is defense in depth. It is
+// turned off again before the connection returns to the pool, which for
+// books not backed by a file (tests) also serves writes.
+func (d *DB) QuerySQL(ctx context.Context, stmt string, maxRows int) ([]string, [][]string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, sqlTimeout)
+	defer cancel()
+
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, nil, false, fmt.Errorf("enable query_only: %w", err)
+	}
+	defer func() {
+		// Not ctx, which may have timed out. A connection that cannot be
+		// reset is discarded rather than pooled.
+		if _, err := conn.ExecContext(context.Background(), "PRAGMA query_only = OFF"); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, nil, false, sqlError(ctx, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("read columns: %w", err)
+	}
+	var result [][]string
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result) == maxRows {
+			return columns, result, true, nil
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, false, fmt.Errorf("scan row: %w", err)
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = sqlCell(v)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, false, sqlError(ctx, err)
+	}
+	return columns, result, false, nil
+}
+
+func sqlError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query timed out after %s", sqlTimeout)
+	}
+	return fmt.Errorf("query: %w", err)
+}
+
+// sqlCell renders a scanned SQL value.
+func sqlCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// QuerySQL runs an ad-hoc read-only SELECT against the book and renders the
+// rows as a table (markdown for text output). It is disabled unless the
+// service was created WithSQL.
+func (s *Service) QuerySQL(ctx context.Context, stmt string, maxRows int, format string) (string, error) {
+	if !s.sql {
+		return "", fmt.Errorf("ad-hoc SQL is disabled (set GNUCASH_SQL=1 to enable)")
+	}
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	stmt, err = checkSelect(stmt)
+	if err != nil {
+		return "", err
+	}
+	if maxRows <= 0 {
+		maxRows = defaultSQLRows
+	}
+	maxRows = min(maxRows, maxSQLRows)
+
+	columns, rows, truncated, err := s.db.QuerySQL(ctx, stmt, maxRows)
+	if err != nil {
+		return "", err
+	}
+	if format == FormatText {
+		format = FormatMarkdown
+	}
+	out := table{Headers: columns, Rows: rows}.render(format)
+	if format != FormatCSV {
+		if truncated {
+			out += fmt.Sprintf("\nShowing the first %d rows; more are available (raise max_rows up to %d or narrow the query).\n", maxRows, maxSQLRows)
+		} else {
+			out += fmt.Sprintf("\n%d rows.\n", len(rows))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,78 @@
+package gnucash
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckSelect(t *testing.T) {
+	valid := []string{
+		"SELECT 1",
+		"select name from accounts;",
+		"  -- leading comment\nWITH x AS (SELECT 1) SELECT * FROM x ; -- done",
+		"SELECT ';' AS semi, \"a;b\" FROM accounts /* ; */",
+	}
+	for _, stmt := range valid {
+		if _, err := checkSelect(stmt); err != nil {
+			t.Errorf("checkSelect(%q) returned error: %v", stmt, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"-- only a comment",
+		"DELETE FROM accounts",
+		"PRAGMA query_only = OFF",
+		"SELECT 1; SELECT 2",
+		"SELECT 1; DROP TABLE accounts",
+		"SELECT 'unterminated",
+		"SELECT 1 /* unterminated",
+	}
+	for _, stmt := range invalid {
+		if _, err := checkSelect(stmt); err == nil {
+			t.Errorf("checkSelect(%q) should fail", stmt)
+		}
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(db, WithSQL())
+	ctx := context.Background()
+
+	result, err := svc.QuerySQL(ctx, "SELECT name, account_type FROM accounts WHERE account_type = 'EXPENSE' ORDER BY name", 0, "")
+	if err != nil {
+		t.Fatalf("QuerySQL returned error: %v", err)
+	}
+	for _, want := range []string{"| name", "| Groceries", "| Restaurant", "3 rows."} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	result, err = svc.QuerySQL(ctx, "SELECT guid FROM splits", 2, "")
+	if err != nil {
+		t.Fatalf("QuerySQL returned error: %v", err)
+	}
+	if !strings.Contains(result, "Showing the first 2 rows") {
+		t.Errorf("expected truncation notice:\n%s", result)
+	}
+
+	// Writes hidden in a CTE pass validation but are refused by query_only.
+	if _, err := svc.QuerySQL(ctx, "WITH x AS (SELECT 1) DELETE FROM accounts", 0, ""); err == nil {
+		t.Error("expected write through WITH to fail")
+	}
+	if _, err := NewService(db).QuerySQL(ctx, "SELECT 1", 0, ""); err == nil {
+		t.Error("expected error when SQL is disabled")
+	}
+
+	// Without a file, writes share the read pool: query_only must not
+	// stick to the connection QuerySQL used.
+	if err := db.EnableWrites(); err != nil {
+		t.Fatalf("EnableWrites returned error: %v", err)
+	}
+	if _, err := db.rw.ExecContext(ctx, `UPDATE accounts SET description = 'x' WHERE name = 'Groceries'`); err != nil {
+		t.Errorf("write after QuerySQL failed: %v", err)
+	}
+}
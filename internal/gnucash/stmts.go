@@ -0,0 +1,83 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Tools run the same few queries over and over, account lookups and
+// balances above all, so the DB prepares them once and reuses the
+// statements on every connection of its pool, which database/sql prepares
+// them on as needed.
+
+// maxPrepared bounds the statements kept prepared: queries whose text
+// varies, e.g. with the number of accounts of an IN list, past that many
+// run unprepared.
+const maxPrepared = 64
+
+// readIdleConns is the number of idle read connections kept open, with
+// their prepared statements, between calls.
+const readIdleConns = 4
+
+// stmtCache holds the statements prepared by a DB, by query text.
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// prepared returns the statement of query, preparing it on first use, or
+// nil when too many are prepared already.
+func (d *DB) prepared(ctx context.Context, query string) (*sql.Stmt, error) {
+	d.stmts.mu.Lock()
+	defer d.stmts.mu.Unlock()
+	if stmt, ok := d.stmts.stmts[query]; ok {
+		return stmt, nil
+	}
+	if len(d.stmts.stmts) >= maxPrepared {
+		return nil, nil
+	}
+	stmt, err := d.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if d.stmts.stmts == nil {
+		d.stmts.stmts = make(map[string]*sql.Stmt)
+	}
+	d.stmts.stmts[query] = stmt
+	return stmt, nil
+}
+
+// queryPrepared runs query like QueryContext, through its prepared
+// statement.
+func (d *DB) queryPrepared(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	stmt, err := d.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return d.db.QueryContext(ctx, query, args...)
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// queryRowPrepared runs query like QueryRowContext, through its prepared
+// statement.
+func (d *DB) queryRowPrepared(ctx context.Context, query string, args ...any) *sql.Row {
+	stmt, err := d.prepared(ctx, query)
+	if err != nil || stmt == nil {
+		// An error preparing shows again when the query runs.
+		return d.db.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// closeStatements closes the prepared statements.
+func (d *DB) closeStatements() {
+	d.stmts.mu.Lock()
+	defer d.stmts.mu.Unlock()
+	for _, stmt := range d.stmts.stmts {
+		stmt.Close()
+	}
+	d.stmts.stmts = nil
+}
@@ -0,0 +1,339 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filing periods of tax reports.
+const (
+	PeriodMonth   = "month"
+	PeriodQuarter = "quarter"
+	PeriodYear    = "year"
+)
+
+// periodKey labels the filing period of t: 2025-01, 2025-Q1 or 2025.
+func periodKey(t time.Time, period string) string {
+	switch period {
+	case PeriodMonth:
+		return t.Format("2006-01")
+	case PeriodYear:
+		return t.Format("2006")
+	}
+	return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())+2)/3)
+}
+
+// checkPeriod validates a filing period, PeriodQuarter when empty.
+func checkPeriod(period string) (string, error) {
+	switch period {
+	case "":
+		return PeriodQuarter, nil
+	case PeriodMonth, PeriodQuarter, PeriodYear:
+		return period, nil
+	}
+	return "", fmt.Errorf("invalid period '%s' (expected month, quarter or year)", period)
+}
+
+// getTaxTableAccounts returns the accounts the entries of the book's tax
+// tables post tax to, none when the book has no tax tables.
+func (d *DB) getTaxTableAccounts(ctx context.Context) ([]string, error) {
+	if ok, err := d.hasTable(ctx, "taxtable_entries"); err != nil || !ok {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT DISTINCT account FROM taxtable_entries WHERE account IS NOT NULL ORDER BY account`)
+	if err != nil {
+		return nil, fmt.Errorf("query tax table accounts: %w", err)
+	}
+	defer rows.Close()
+	var guids []string
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, fmt.Errorf("scan tax table account: %w", err)
+		}
+		guids = append(guids, guid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tax table accounts: %w", err)
+	}
+	return guids, nil
+}
+
+// taxLine is the tax posted to one tax account in one filing period.
+type taxLine struct {
+	Period  string
+	Account *Account
+	Output  float64 // collected on sales
+	Input   float64 // paid on purchases, deductible
+	Settled float64 // paid to the tax authority, net of refunds
+}
+
+// TaxLiability aggregates the sales tax or VAT posted to tax accounts
+// between startDate and endDate per filing period: the accounts named in
+// accountNames (comma-separated, with their sub-accounts), or those of the
+// book's tax tables. Tax posted with income or expenses, directly or
+// through an invoice or bill, is output tax when it credits the account
+// and input tax when it debits it; other transactions, such as payments
+// to the tax authority, settle the liability. The net due for a period is
+// its output tax minus its input tax.
+func (s *Service) TaxLiability(ctx context.Context, accountNames, startDate, endDate, period, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	period, err = checkPeriod(period)
+	if err != nil {
+		return "", err
+	}
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	var guids []string
+	if accountNames != "" {
+		for _, name := range strings.Split(accountNames, ",") {
+			acc, err := s.resolveAccount(ctx, strings.TrimSpace(name))
+			if err != nil {
+				return "", err
+			}
+			guids = append(guids, descendantGUIDs(acc)...)
+		}
+	} else {
+		if guids, err = s.db.getTaxTableAccounts(ctx); err != nil {
+			return "", err
+		}
+		if len(guids) == 0 {
+			return "", fmt.Errorf("the book has no tax tables; name the tax accounts with the accounts parameter")
+		}
+	}
+	taxAccounts := make(map[string]bool, len(guids))
+	for _, guid := range guids {
+		taxAccounts[guid] = true
+	}
+	splits, err := s.db.getRelatedSplits(ctx, guids, endDate, s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	type key struct{ period, account string }
+	lines := make(map[key]*taxLine)
+	for i := 0; i < len(splits); {
+		tx := splits[i].TxGUID
+		j := i
+		for j < len(splits) && splits[j].TxGUID == tx {
+			j++
+		}
+		txSplits := splits[i:j]
+		i = j
+		if startDate != "" && txSplits[0].Date.Format("2006-01-02") < startDate {
+			continue
+		}
+		trade := false
+		for _, sp := range txSplits {
+			if acc := accounts[sp.AccountGUID]; acc != nil {
+				switch acc.AccountType {
+				case "INCOME", "EXPENSE", "RECEIVABLE", "PAYABLE":
+					trade = true
+				}
+			}
+		}
+		for _, sp := range txSplits {
+			if !taxAccounts[sp.AccountGUID] || accounts[sp.AccountGUID] == nil {
+				continue
+			}
+			k := key{periodKey(sp.Date, period), sp.AccountGUID}
+			line, ok := lines[k]
+			if !ok {
+				line = &taxLine{Period: k.period, Account: accounts[sp.AccountGUID]}
+				lines[k] = line
+			}
+			switch {
+			case !trade:
+				line.Settled += sp.Value
+			case sp.Value < 0:
+				line.Output -= sp.Value
+			default:
+				line.Input += sp.Value
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "No tax posted in the period.", nil
+	}
+	sorted := make([]*taxLine, 0, len(lines))
+	for _, l := range lines {
+		sorted = append(sorted, l)
+	}
+	slices.SortFunc(sorted, func(a, b *taxLine) int {
+		return cmp.Or(cmp.Compare(a.Period, b.Period), cmp.Compare(a.Account.FullName, b.Account.FullName))
+	})
+
+	if format != FormatText {
+		t := table{Headers: []string{"period", "account", "output_tax", "input_tax", "net_due", "settled"}}
+		for _, l := range sorted {
+			t.add(l.Period, l.Account.FullName, fmt.Sprintf("%.2f", l.Output), fmt.Sprintf("%.2f", l.Input),
+				fmt.Sprintf("%.2f", l.Output-l.Input), fmt.Sprintf("%.2f", l.Settled))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tax liability per %s, in %s:\n", period, cur.Mnemonic)
+	var output, input, settled float64
+	for i, l := range sorted {
+		if i == 0 || l.Period != sorted[i-1].Period {
+			fmt.Fprintf(&sb, "\n%s\n", l.Period)
+			output, input, settled = 0, 0, 0
+		}
+		fmt.Fprintf(&sb, "  %-40s output %10.2f  input %10.2f  net due %10.2f", l.Account.FullName, l.Output, l.Input, l.Output-l.Input)
+		if l.Settled != 0 {
+			fmt.Fprintf(&sb, "  settled %10.2f", l.Settled)
+		}
+		sb.WriteString("\n")
+		output, input, settled = output+l.Output, input+l.Input, settled+l.Settled
+		if i == len(sorted)-1 || sorted[i+1].Period != l.Period {
+			fmt.Fprintf(&sb, "  Net due for %s: %.2f", l.Period, output-input)
+			if settled != 0 {
+				fmt.Fprintf(&sb, " (%.2f settled in the period)", settled)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// taxAccount is an account GnuCash marks as tax-related, with the tax code
+// (TXF code such as N261) assigned to it, if any.
+type taxAccount struct {
+	GUID string
+	Code string
+}
+
+// getTaxRelatedAccounts returns the accounts marked tax-related in their
+// slots ("tax-related" and "tax-US/code", which the US income tax options
+// of GnuCash set), by GUID.
+func (d *DB) getTaxRelatedAccounts(ctx context.Context) ([]taxAccount, error) {
+	if ok, err := d.hasTable(ctx, "slots"); err != nil || !ok {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT a.guid, COALESCE((SELECT string_val FROM slots c WHERE c.obj_guid = a.guid AND c.name = 'tax-US/code'), '')
+		FROM accounts a
+		JOIN slots r ON r.obj_guid = a.guid AND r.name = 'tax-related' AND r.int64_val != 0
+		ORDER BY a.guid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query tax-related accounts: %w", err)
+	}
+	defer rows.Close()
+	var taxAccounts []taxAccount
+	for rows.Next() {
+		var a taxAccount
+		if err := rows.Scan(&a.GUID, &a.Code); err != nil {
+			return nil, fmt.Errorf("scan tax-related account: %w", err)
+		}
+		taxAccounts = append(taxAccounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tax-related accounts: %w", err)
+	}
+	return taxAccounts, nil
+}
+
+// TaxReport summarizes the accounts marked tax-related for the tax year
+// (the previous calendar year when zero) per tax code, as the Tax Schedule
+// Report of GnuCash does: the total of each account in the year, income
+// counted positive, and the total of each code. Accounts without a code
+// are grouped last.
+func (s *Service) TaxReport(ctx context.Context, year int, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if year == 0 {
+		year = s.now().Year() - 1
+	}
+	taxAccounts, err := s.db.getTaxRelatedAccounts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(taxAccounts) == 0 {
+		return "No accounts are marked tax-related (Edit > Tax Report Options in GnuCash).", nil
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	totals, err := s.db.GetAccountTotals(ctx, accountTypes, fmt.Sprintf("%d-01-01", year), fmt.Sprintf("%d-12-31", year), s.closingEntries)
+	if err != nil {
+		return "", err
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	type line struct {
+		code    string
+		account *Account
+		total   float64
+	}
+	var lines []line
+	for _, ta := range taxAccounts {
+		acc := accounts[ta.GUID]
+		if acc == nil {
+			continue
+		}
+		total := totals[ta.GUID]
+		if acc.AccountType == "INCOME" {
+			total = -total
+		}
+		lines = append(lines, line{ta.Code, acc, total})
+	}
+	slices.SortFunc(lines, func(a, b line) int {
+		// Accounts without a code go last.
+		if (a.code == "") != (b.code == "") {
+			return cmp.Compare(b.code, a.code)
+		}
+		return cmp.Or(cmp.Compare(a.code, b.code), cmp.Compare(a.account.FullName, b.account.FullName))
+	})
+
+	if format != FormatText {
+		t := table{Headers: []string{"code", "account", "type", "total"}}
+		for _, l := range lines {
+			t.add(l.code, l.account.FullName, l.account.AccountType, fmt.Sprintf("%.2f", l.total))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tax report for %d, in %s:\n", year, cur.Mnemonic)
+	var codeTotal float64
+	for i, l := range lines {
+		if i == 0 || l.code != lines[i-1].code {
+			code := l.code
+			if code == "" {
+				code = "(no tax code)"
+			}
+			fmt.Fprintf(&sb, "\n  %s\n", code)
+			codeTotal = 0
+		}
+		fmt.Fprintf(&sb, "    %-44s %-8s %12.2f\n", l.account.FullName, l.account.AccountType, l.total)
+		codeTotal += l.total
+		if i == len(lines)-1 || lines[i+1].code != l.code {
+			fmt.Fprintf(&sb, "    %-53s %12.2f\n", "Total", codeTotal)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,183 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Books with "Use Trading Accounts" enabled balance every transaction
+// between currencies or securities with splits in TRADING accounts, one
+// per commodity (Trading:CURRENCY:USD). Reports leave these splits out of
+// transaction amounts and counterparts; their balances are what
+// CurrencyGains reports on.
+
+// hasTradingAccounts tells whether the book has trading accounts.
+func (d *DB) hasTradingAccounts(ctx context.Context) (bool, error) {
+	var found bool
+	err := d.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts WHERE account_type = 'TRADING')`).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("query trading accounts: %w", err)
+	}
+	return found, nil
+}
+
+// tradingBalance is the balance of a trading account in one transaction
+// currency: the quantity of its commodity and the value it was exchanged
+// for.
+type tradingBalance struct {
+	AccountGUID   string
+	CommodityGUID string
+	CurrencyGUID  string
+	Quantity      Numeric
+	Value         Numeric
+}
+
+// getTradingBalances returns the balances of the trading accounts up to
+// endDate, per transaction currency.
+func (d *DB) getTradingBalances(ctx context.Context, endDate string) ([]tradingBalance, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT a.guid, COALESCE(a.commodity_guid, ''), t.currency_guid,
+		       SUM(s.quantity_num), s.quantity_denom, SUM(s.value_num), s.value_denom
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		WHERE a.account_type = 'TRADING' AND t.post_date <= ?
+		GROUP BY a.guid, t.currency_guid, s.quantity_denom, s.value_denom
+		ORDER BY a.guid, t.currency_guid
+	`, d.dayEnd(endDate))
+	if err != nil {
+		return nil, fmt.Errorf("query trading balances: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []tradingBalance
+	for rows.Next() {
+		var b tradingBalance
+		var qNum, qDenom, vNum, vDenom int64
+		if err := rows.Scan(&b.AccountGUID, &b.CommodityGUID, &b.CurrencyGUID, &qNum, &qDenom, &vNum, &vDenom); err != nil {
+			return nil, fmt.Errorf("scan trading balance: %w", err)
+		}
+		b.Quantity, b.Value = NewNumeric(qNum, qDenom), NewNumeric(vNum, vDenom)
+		// Rows of an account and currency differ by denominators only.
+		if n := len(balances); n > 0 && balances[n-1].AccountGUID == b.AccountGUID && balances[n-1].CurrencyGUID == b.CurrencyGUID {
+			balances[n-1].Quantity = balances[n-1].Quantity.Add(b.Quantity)
+			balances[n-1].Value = balances[n-1].Value.Add(b.Value)
+			continue
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// CurrencyGains reports the gains and losses on foreign currencies as of
+// date (today when empty), from the trading accounts of the book. A
+// currency's trading account holds the opposite of the amount of it bought
+// and the book-currency value paid for it: the holding is worth its
+// quantity at the latest exchange rate, and the gain is that worth minus
+// the cost, realized gains included. Values in other transaction currencies
+// are converted at the rate of date too.
+func (s *Service) CurrencyGains(ctx context.Context, date, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if date == "" {
+		date = s.now().Format("2006-01-02")
+	}
+	ok, err := s.db.hasTradingAccounts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("the book has no trading accounts (enable \"Use Trading Accounts\" in the book options of GnuCash)")
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	balances, err := s.db.getTradingBalances(ctx, date)
+	if err != nil {
+		return "", err
+	}
+	commodities, err := s.db.getCommodities(ctx)
+	if err != nil {
+		return "", err
+	}
+	rate := func(from Commodity) (Numeric, error) {
+		r, ok, err := s.db.exchangeRate(ctx, from, cur, date)
+		if err != nil {
+			return Numeric{}, err
+		}
+		if !ok {
+			return Numeric{}, fmt.Errorf("no exchange rate from %s to %s on or before %s in the book", from.Mnemonic, cur.Mnemonic, date)
+		}
+		return r, nil
+	}
+
+	type gain struct {
+		Currency      Commodity
+		Rate          Numeric
+		Holding, Cost Numeric
+		Worth, Gain   Numeric
+	}
+	byCurrency := make(map[string]*gain)
+	for _, b := range balances {
+		c, ok := commodities[b.CommodityGUID]
+		if !ok || c.Namespace != "CURRENCY" || c.GUID == cur.GUID {
+			continue // securities, and the book currency which has no gain
+		}
+		g, ok := byCurrency[c.GUID]
+		if !ok {
+			r, err := rate(c)
+			if err != nil {
+				return "", err
+			}
+			g = &gain{Currency: c, Rate: r}
+			byCurrency[c.GUID] = g
+		}
+		cost := b.Value.Neg()
+		if b.CurrencyGUID != cur.GUID {
+			r, err := rate(commodities[b.CurrencyGUID])
+			if err != nil {
+				return "", err
+			}
+			cost = cost.Mul(r)
+		}
+		g.Holding = g.Holding.Sub(b.Quantity)
+		g.Cost = g.Cost.Add(cost)
+	}
+	if len(byCurrency) == 0 {
+		return fmt.Sprintf("No foreign currency movements in the trading accounts up to %s.", date), nil
+	}
+	var gains []*gain
+	var total Numeric
+	for _, g := range byCurrency {
+		g.Worth = g.Holding.Mul(g.Rate)
+		g.Gain = g.Worth.Sub(g.Cost)
+		total = total.Add(g.Gain)
+		gains = append(gains, g)
+	}
+	slices.SortFunc(gains, func(a, b *gain) int { return cmp.Compare(a.Currency.Mnemonic, b.Currency.Mnemonic) })
+
+	if format != FormatText {
+		t := table{Headers: []string{"currency", "holding", "cost", "rate", "worth", "gain"}}
+		for _, g := range gains {
+			t.add(g.Currency.Mnemonic, g.Holding.Format(g.Currency.Fraction), g.Cost.Format(cur.Fraction),
+				formatRate(g.Rate), g.Worth.Format(cur.Fraction), g.Gain.Format(cur.Fraction))
+		}
+		return t.render(format), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Currency gains and losses as of %s, in %s (from trading accounts, realized gains included):\n\n", date, cur.Mnemonic)
+	for _, g := range gains {
+		fmt.Fprintf(&sb, "  %s: holding %s, cost %s, worth %s at %s: gain %s\n", g.Currency.Label(),
+			s.formatMoney(g.Holding, g.Currency), s.formatMoney(g.Cost, cur),
+			s.formatMoney(g.Worth, cur), formatRate(g.Rate), s.formatMoney(g.Gain, cur))
+	}
+	fmt.Fprintf(&sb, "\n  TOTAL gain: %s\n", s.formatMoney(total, cur))
+	return sb.String(), nil
+}
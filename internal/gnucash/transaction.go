@@ -0,0 +1,225 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// transactionDetails is a transaction with everything GnuCash records about
+// it and its splits.
+type transactionDetails struct {
+	GUID         string
+	PostDate     time.Time
+	Description  string
+	CurrencyGUID string
+	Num          string
+	Entered      time.Time
+	Notes        string
+	Doclink      string // document link (the assoc_uri slot), a URI or file path
+	VoidReason   string // set when the transaction is voided
+	Splits       []splitDetails
+}
+
+// splitDetails is a split with its quantity, action and reconcile state.
+type splitDetails struct {
+	Split
+	Action         string
+	ReconcileState string // GnuCash's one-letter code
+	ReconcileDate  string // YYYY-MM-DD, "" when never reconciled
+}
+
+// getTransactionDetails returns the transaction with the given GUID, its
+// slots and its splits.
+func (d *DB) getTransactionDetails(ctx context.Context, guid string) (transactionDetails, error) {
+	tx, err := d.GetTransaction(ctx, guid)
+	if err != nil {
+		return transactionDetails{}, err
+	}
+	det := transactionDetails{GUID: tx.GUID, PostDate: tx.PostDate, Description: tx.Description}
+	num := "''"
+	if ok, err := d.hasColumn(ctx, "transactions", "num"); err != nil {
+		return det, err
+	} else if ok {
+		num = "COALESCE(num, '')"
+	}
+	var entered string
+	err = d.db.QueryRowContext(ctx, `
+		SELECT COALESCE(currency_guid, ''), `+num+`, COALESCE(enter_date, '') FROM transactions WHERE guid = ?
+	`, guid).Scan(&det.CurrencyGUID, &det.Num, &entered)
+	if err != nil {
+		return det, fmt.Errorf("query transaction: %w", err)
+	}
+	det.Entered, _ = d.parseDate(entered)
+
+	if ok, err := d.hasTable(ctx, "slots"); err != nil {
+		return det, err
+	} else if ok {
+		rows, err := d.db.QueryContext(ctx, `
+			SELECT name, COALESCE(string_val, '') FROM slots
+			WHERE obj_guid = ? AND name IN ('notes', 'assoc_uri', 'void-reason')
+		`, guid)
+		if err != nil {
+			return det, fmt.Errorf("query transaction slots: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name, value string
+			if err := rows.Scan(&name, &value); err != nil {
+				return det, fmt.Errorf("scan transaction slot: %w", err)
+			}
+			switch name {
+			case "notes":
+				det.Notes = d.redacted(value)
+			case "assoc_uri":
+				det.Doclink = value
+			case "void-reason":
+				det.VoidReason = value
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return det, fmt.Errorf("iterate transaction slots: %w", err)
+		}
+	}
+
+	// Books created by other tools may lack the action and reconcile
+	// columns.
+	columns := []string{"quantity_num", "quantity_denom"}
+	for _, c := range []string{"action", "reconcile_state", "reconcile_date"} {
+		ok, err := d.hasColumn(ctx, "splits", c)
+		if err != nil {
+			return det, err
+		}
+		if ok {
+			columns = append(columns, "COALESCE("+c+", '')")
+		} else {
+			columns = append(columns, "''")
+		}
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT guid, `+strings.Join(columns, ", ")+` FROM splits WHERE tx_guid = ?`, guid)
+	if err != nil {
+		return det, fmt.Errorf("query transaction splits: %w", err)
+	}
+	defer rows.Close()
+	extra := make(map[string]splitDetails)
+	for rows.Next() {
+		var sp splitDetails
+		var splitGUID, reconciled string
+		if err := rows.Scan(&splitGUID, &sp.QuantityNum, &sp.QuantityDenom, &sp.Action, &sp.ReconcileState, &reconciled); err != nil {
+			return det, fmt.Errorf("scan transaction split: %w", err)
+		}
+		if reconciled != "" {
+			sp.ReconcileDate = d.day(reconciled)
+		}
+		extra[splitGUID] = sp
+	}
+	if err := rows.Err(); err != nil {
+		return det, fmt.Errorf("iterate transaction splits: %w", err)
+	}
+	for _, sp := range tx.Splits {
+		e := extra[sp.GUID]
+		sp.QuantityNum, sp.QuantityDenom = e.QuantityNum, e.QuantityDenom
+		e.Split = sp
+		det.Splits = append(det.Splits, e)
+	}
+	return det, nil
+}
+
+// reconcileStateNames names GnuCash's reconcile state codes.
+var reconcileStateNames = map[string]string{
+	"n": "unreconciled",
+	"c": "cleared",
+	"y": "reconciled",
+	"f": "frozen",
+	"v": "voided",
+}
+
+// GetTransaction describes the transaction with the given GUID: its dates,
+// number, description, currency, notes and document link, and each split
+// with its account, memo, action, value, quantity when the account is kept
+// in another commodity, and reconcile state.
+func (s *Service) GetTransaction(ctx context.Context, guid string) (string, error) {
+	tx, err := s.db.getTransactionDetails(ctx, strings.TrimSpace(guid))
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	commodities := make(map[string]Commodity)
+	commodity := func(guid string) (Commodity, error) {
+		c, ok := commodities[guid]
+		if !ok && guid != "" {
+			var err error
+			if c, err = s.db.GetCommodity(ctx, guid); err != nil {
+				return c, err
+			}
+			commodities[guid] = c
+		}
+		return c, nil
+	}
+	cur, err := commodity(tx.CurrencyGUID)
+	if err != nil {
+		return "", err
+	}
+	if cur.Mnemonic == "" {
+		if cur, err = s.bookCurrency(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Transaction: %s\n", tx.GUID)
+	fmt.Fprintf(&sb, "Date: %s\n", tx.PostDate.Format("2006-01-02"))
+	if !tx.Entered.IsZero() {
+		fmt.Fprintf(&sb, "Entered: %s\n", tx.Entered.Format("2006-01-02 15:04"))
+	}
+	if tx.Num != "" {
+		fmt.Fprintf(&sb, "Num: %s\n", tx.Num)
+	}
+	fmt.Fprintf(&sb, "Description: %s\n", tx.Description)
+	fmt.Fprintf(&sb, "Currency: %s\n", cur.Mnemonic)
+	if tx.Notes != "" {
+		fmt.Fprintf(&sb, "Notes: %s\n", tx.Notes)
+	}
+	if tx.Doclink != "" {
+		fmt.Fprintf(&sb, "Document link: %s\n", tx.Doclink)
+	}
+	if tx.VoidReason != "" {
+		fmt.Fprintf(&sb, "Voided: %s\n", tx.VoidReason)
+	}
+	fmt.Fprintf(&sb, "\nSplits (%d):\n", len(tx.Splits))
+	for _, sp := range tx.Splits {
+		name := sp.AccountName
+		var accountCommodity string
+		if acc := accounts[sp.AccountGUID]; acc != nil {
+			name, accountCommodity = acc.FullName, acc.CommodityGUID
+		}
+		fmt.Fprintf(&sb, "  %s: %s", name, s.formatMoney(sp.Value(), cur))
+		if accountCommodity != "" && accountCommodity != tx.CurrencyGUID {
+			c, err := commodity(accountCommodity)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&sb, " (%s %s)", sp.Quantity().Format(c.Fraction), c.Mnemonic)
+		}
+		sb.WriteString("\n")
+		if sp.Memo != "" {
+			fmt.Fprintf(&sb, "    Memo: %s\n", sp.Memo)
+		}
+		if sp.Action != "" {
+			fmt.Fprintf(&sb, "    Action: %s\n", sp.Action)
+		}
+		if state, ok := reconcileStateNames[sp.ReconcileState]; ok {
+			fmt.Fprintf(&sb, "    Reconcile state: %s", state)
+			if sp.ReconcileState == "y" && sp.ReconcileDate != "" {
+				fmt.Fprintf(&sb, " on %s", sp.ReconcileDate)
+			}
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "    Split GUID: %s\n", sp.GUID)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,147 @@
+package gnucash
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// uncategorizedSplit is a split booked to an Imbalance or Orphan account.
+type uncategorizedSplit struct {
+	TxGUID      string
+	Date        string // YYYY-MM-DD
+	Description string
+	Account     string   // full name of the Imbalance or Orphan account
+	Value       float64  // debit positive
+	Other       []string // full names of the other accounts of the transaction
+}
+
+// isSuspense reports whether acc is one of the accounts GnuCash books what
+// it cannot categorize to: Imbalance-<currency> for the unbalanced part of a
+// transaction, Orphan-<currency> for splits left without an account.
+func isSuspense(acc *Account) bool {
+	return strings.HasPrefix(acc.Name, "Imbalance") || strings.HasPrefix(acc.Name, "Orphan")
+}
+
+// uncategorized returns the splits booked to Imbalance and Orphan accounts
+// between startDate (the beginning of the book when empty) and endDate,
+// oldest first.
+func (s *Service) uncategorized(ctx context.Context, accounts map[string]*Account, startDate, endDate string) ([]uncategorizedSplit, error) {
+	var suspense []string
+	for guid, acc := range accounts {
+		if isSuspense(acc) {
+			suspense = append(suspense, guid)
+		}
+	}
+	if len(suspense) == 0 {
+		return nil, nil
+	}
+	slices.Sort(suspense)
+	splits, err := s.db.getRelatedSplits(ctx, suspense, endDate, s.closingEntries)
+	if err != nil {
+		return nil, err
+	}
+	name := func(guid string) string {
+		if acc := accounts[guid]; acc != nil {
+			return acc.FullName
+		}
+		return guid
+	}
+	var found []uncategorizedSplit
+	for i, sp := range splits {
+		day := sp.Date.Format("2006-01-02")
+		if day < startDate || !slices.Contains(suspense, sp.AccountGUID) {
+			continue
+		}
+		u := uncategorizedSplit{TxGUID: sp.TxGUID, Date: day, Description: sp.Description, Account: name(sp.AccountGUID), Value: sp.Value}
+		// The splits of a transaction are adjacent.
+		for j := i - 1; j >= 0 && splits[j].TxGUID == sp.TxGUID; j-- {
+			if !slices.Contains(suspense, splits[j].AccountGUID) {
+				u.Other = append(u.Other, name(splits[j].AccountGUID))
+			}
+		}
+		for j := i + 1; j < len(splits) && splits[j].TxGUID == sp.TxGUID; j++ {
+			if !slices.Contains(suspense, splits[j].AccountGUID) {
+				u.Other = append(u.Other, name(splits[j].AccountGUID))
+			}
+		}
+		slices.Sort(u.Other)
+		u.Other = slices.Compact(u.Other)
+		found = append(found, u)
+	}
+	return found, nil
+}
+
+// UncategorizedTransactions lists the transactions booked to Imbalance and
+// Orphan accounts between startDate and endDate (the whole book when both
+// are empty), typically left there by imports, grouped by month with the
+// count and total of each month.
+func (s *Service) UncategorizedTransactions(ctx context.Context, startDate, endDate, format string) (string, error) {
+	format, err := checkFormat(format)
+	if err != nil {
+		return "", err
+	}
+	for _, date := range []string{startDate, endDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return "", fmt.Errorf("invalid date '%s': %w", date, err)
+		}
+	}
+	if endDate == "" {
+		endDate = "9999-12-31"
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	found, err := s.uncategorized(ctx, accounts, startDate, endDate)
+	if err != nil {
+		return "", err
+	}
+
+	if format != FormatText {
+		t := table{Headers: []string{"date", "description", "account", "amount", "other_accounts", "transaction_guid"}}
+		for _, u := range found {
+			t.add(u.Date, u.Description, u.Account, fmt.Sprintf("%.2f", u.Value), strings.Join(u.Other, "; "), u.TxGUID)
+		}
+		return t.render(format), nil
+	}
+
+	if len(found) == 0 {
+		return "No transactions in Imbalance or Orphan accounts.", nil
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Transactions in Imbalance and Orphan accounts, in %s:\n", cur.Mnemonic)
+	var total float64
+	months := 0
+	for i := 0; i < len(found); {
+		month := found[i].Date[:7]
+		j := i
+		var sum float64
+		for ; j < len(found) && found[j].Date[:7] == month; j++ {
+			sum += found[j].Value
+		}
+		fmt.Fprintf(&sb, "\n%s: %d transaction(s), total %.2f\n", month, j-i, sum)
+		for _, u := range found[i:j] {
+			fmt.Fprintf(&sb, "  %s  %-30s %12.2f  %s", u.Date, u.Description, u.Value, u.Account)
+			if len(u.Other) > 0 {
+				fmt.Fprintf(&sb, " (with %s)", strings.Join(u.Other, ", "))
+			}
+			sb.WriteString("\n")
+		}
+		total += sum
+		months++
+		i = j
+	}
+	fmt.Fprintf(&sb, "\nTotal: %d transaction(s) over %d month(s), %.2f.\n", len(found), months, total)
+	sb.WriteString("Move them to the right accounts in GnuCash; suggest_category proposes accounts from their descriptions.\n")
+	return sb.String(), nil
+}
@@ -0,0 +1,136 @@
+package gnucash
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// undoJournalSize is the number of changes that can be undone.
+const undoJournalSize = 20
+
+// sqlStmt is a statement with its arguments.
+type sqlStmt struct {
+	Query string
+	Args  []any
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// rowImages captures the rows of table matching where as INSERT statements
+// that recreate them exactly.
+func rowImages(ctx context.Context, q queryer, table, where string, args ...any) ([]sqlStmt, error) {
+	rows, err := q.QueryContext(ctx, "SELECT * FROM "+table+" WHERE "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("capture %s rows: %w", table, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("capture %s rows: %w", table, err)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders(len(columns)))
+
+	var stmts []sqlStmt
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("capture %s rows: %w", table, err)
+		}
+		stmts = append(stmts, sqlStmt{insert, values})
+	}
+	return stmts, rows.Err()
+}
+
+// applyUndo runs the statements reversing a change in one transaction. Each
+// must affect at least one row; otherwise the book changed since and nothing
+// is undone.
+func (d *DB) applyUndo(ctx context.Context, stmts []sqlStmt) error {
+	dbTx, err := d.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	for _, st := range stmts {
+		res, err := dbTx.ExecContext(ctx, st.Query, st.Args...)
+		if err != nil {
+			return fmt.Errorf("undo: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("cannot undo: the book was changed since")
+		}
+	}
+	if err := d.commitWrite(dbTx); err != nil {
+		return fmt.Errorf("commit undo: %w", err)
+	}
+	return nil
+}
+
+// undoEntry is a change made through this server and how to reverse it.
+type undoEntry struct {
+	Change string // e.g. "record transaction <guid> (2025-02-20 Bistro)"
+	GUIDs  []string
+	Stmts  []sqlStmt
+}
+
+// undoJournal holds the most recent changes, newest last.
+type undoJournal struct {
+	mu      sync.Mutex
+	entries []undoEntry
+}
+
+func (j *undoJournal) push(e undoEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, e)
+	if len(j.entries) > undoJournalSize {
+		j.entries = j.entries[1:]
+	}
+}
+
+// journal records a change so it can be undone.
+func (s *Service) journal(change string, undo []sqlStmt, guids ...string) {
+	s.undo.push(undoEntry{Change: change, GUIDs: guids, Stmts: undo})
+}
+
+// UndoLastChange reverses the most recent change made through this server
+// that has not been undone yet.
+func (s *Service) UndoLastChange(ctx context.Context) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	// Holding the lock for the whole undo keeps concurrent undos from
+	// reversing the same change twice.
+	s.undo.mu.Lock()
+	defer s.undo.mu.Unlock()
+	if len(s.undo.entries) == 0 {
+		return "", fmt.Errorf("nothing to undo: no changes were made through this server since it started")
+	}
+	last := s.undo.entries[len(s.undo.entries)-1]
+	if s.dryRun {
+		return s.changeHeading("", "undo: "+last.Change) + "\n", nil
+	}
+	if err := s.db.applyUndo(ctx, last.Stmts); err != nil {
+		return "", err
+	}
+	s.undo.entries = s.undo.entries[:len(s.undo.entries)-1]
+	if err := s.audit("undo_last_change", map[string]any{"change": last.Change}, last.GUIDs...); err != nil {
+		return "", err
+	}
+
+	result := "Undid: " + last.Change + "\n"
+	if n := len(s.undo.entries); n > 0 {
+		result += fmt.Sprintf("\n%d earlier change(s) can still be undone; the next is: %s\n", n, s.undo.entries[n-1].Change)
+	}
+	return result, nil
+}
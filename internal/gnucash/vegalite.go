@@ -0,0 +1,205 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Reports ChartSpec draws.
+const (
+	ChartTrend      = "trend"
+	ChartCategories = "categories"
+	ChartNetWorth   = "net_worth"
+)
+
+// vegaLiteSchema is the Vega-Lite version of the specs ChartSpec returns.
+const vegaLiteSchema = "https://vega.github.io/schema/vega-lite/v5.json"
+
+// chartMonths returns the months from start to end, each with its last day,
+// the last one cut at end.
+func chartMonths(start, end time.Time) (months []string, ends []string) {
+	for m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(end); m = m.AddDate(0, 1, 0) {
+		last := m.AddDate(0, 1, -1)
+		if last.After(end) {
+			last = end
+		}
+		months = append(months, m.Format("2006-01"))
+		ends = append(ends, last.Format("2006-01-02"))
+	}
+	return months, ends
+}
+
+// ChartSpec returns a Vega-Lite spec, with its data inline, charting report
+// between startDate (the start of the month a year before endDate when
+// empty) and endDate (today when empty), for clients that render charts:
+// ChartTrend draws monthly income, expenses and net, ChartCategories the
+// expenses of the period by top-level category, and ChartNetWorth the
+// assets, liabilities and net worth at each month end, stock and fund
+// holdings valued at the price known then (those without a price left out)
+// and other accounts at their balance.
+func (s *Service) ChartSpec(ctx context.Context, report, startDate, endDate string) (string, error) {
+	if endDate == "" {
+		endDate = s.now().Format("2006-01-02")
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid end date '%s': %w", endDate, err)
+	}
+	if startDate == "" {
+		startDate = time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -11, 0).Format("2006-01-02")
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid start date '%s': %w", startDate, err)
+	}
+	if start.After(end) {
+		return "", fmt.Errorf("start date %s is after end date %s", startDate, endDate)
+	}
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	amountAxis := map[string]any{"field": "amount", "type": "quantitative", "title": "Amount (" + cur.Mnemonic + ")"}
+	period := fmt.Sprintf("%s to %s", startDate, endDate)
+
+	var spec map[string]any
+	switch strings.ToLower(report) {
+	case ChartTrend:
+		totals, err := s.monthlyIncomeExpenses(ctx, startDate, endDate)
+		if err != nil {
+			return "", err
+		}
+		income := make(map[string]float64)
+		expenses := make(map[string]float64)
+		for _, t := range totals {
+			if t.AccType == "INCOME" {
+				income[t.Month] -= t.Total.Float64() // credited
+			} else {
+				expenses[t.Month] += t.Total.Float64()
+			}
+		}
+		months, _ := chartMonths(start, end)
+		var values []map[string]any
+		for _, m := range months {
+			values = append(values,
+				map[string]any{"month": m, "series": "Income", "amount": cents(income[m])},
+				map[string]any{"month": m, "series": "Expenses", "amount": cents(expenses[m])},
+				map[string]any{"month": m, "series": "Net", "amount": cents(income[m] - expenses[m])})
+		}
+		spec = map[string]any{
+			"title": "Income and expenses, " + period,
+			"data":  map[string]any{"values": values},
+			"mark":  map[string]any{"type": "line", "point": true},
+			"encoding": map[string]any{
+				"x":     map[string]any{"field": "month", "type": "ordinal", "title": "Month"},
+				"y":     amountAxis,
+				"color": map[string]any{"field": "series", "type": "nominal", "title": nil, "sort": []string{"Income", "Expenses", "Net"}},
+			},
+		}
+
+	case ChartCategories:
+		accounts, err := s.accountTree(ctx)
+		if err != nil {
+			return "", err
+		}
+		totals, err := s.db.GetAccountTotals(ctx, []string{"EXPENSE"}, startDate, endDate, s.closingEntries)
+		if err != nil {
+			return "", err
+		}
+		groups := make(map[string]float64)
+		for guid, total := range totals {
+			if acc := accounts[guid]; acc != nil {
+				groups[expenseGroup(acc)] += total
+			}
+		}
+		type category struct {
+			Name   string
+			Amount float64
+		}
+		var categories []category
+		for name, amount := range groups {
+			if cents(amount) != 0 {
+				categories = append(categories, category{name, cents(amount)})
+			}
+		}
+		slices.SortFunc(categories, func(a, b category) int {
+			return cmp.Or(cmp.Compare(b.Amount, a.Amount), cmp.Compare(a.Name, b.Name))
+		})
+		values := make([]map[string]any, 0, len(categories))
+		for _, c := range categories {
+			values = append(values, map[string]any{"category": c.Name, "amount": c.Amount})
+		}
+		spec = map[string]any{
+			"title": "Expenses by category, " + period,
+			"data":  map[string]any{"values": values},
+			"mark":  "bar",
+			"encoding": map[string]any{
+				"y":       map[string]any{"field": "category", "type": "nominal", "title": "Category", "sort": "-x"},
+				"x":       amountAxis,
+				"tooltip": []map[string]any{{"field": "category"}, {"field": "amount", "format": ",.2f"}},
+			},
+		}
+
+	case ChartNetWorth:
+		accounts, err := s.accountTree(ctx)
+		if err != nil {
+			return "", err
+		}
+		var assetGUIDs, liabilityGUIDs []string
+		for guid, acc := range accounts {
+			switch acc.AccountType {
+			case "ASSET", "BANK", "CASH", "RECEIVABLE":
+				assetGUIDs = append(assetGUIDs, guid)
+			case "CREDIT", "LIABILITY", "PAYABLE":
+				liabilityGUIDs = append(liabilityGUIDs, guid)
+			}
+		}
+		months, ends := chartMonths(start, end)
+		var values []map[string]any
+		for i, m := range months {
+			assets, err := s.db.GetBalanceForAccounts(ctx, assetGUIDs, ends[i])
+			if err != nil {
+				return "", err
+			}
+			liabilities, err := s.db.GetBalanceForAccounts(ctx, liabilityGUIDs, ends[i])
+			if err != nil {
+				return "", err
+			}
+			holdings, _, err := s.portfolioValue(ctx, ends[i])
+			if err != nil {
+				return "", err
+			}
+			owned, owed := assets.Float64()+holdings, liabilities.Neg().Float64()
+			values = append(values,
+				map[string]any{"month": m, "series": "Assets", "amount": cents(owned)},
+				map[string]any{"month": m, "series": "Liabilities", "amount": cents(owed)},
+				map[string]any{"month": m, "series": "Net worth", "amount": cents(owned - owed)})
+		}
+		spec = map[string]any{
+			"title": "Net worth at month end, " + period,
+			"data":  map[string]any{"values": values},
+			"mark":  map[string]any{"type": "line", "point": true},
+			"encoding": map[string]any{
+				"x":     map[string]any{"field": "month", "type": "ordinal", "title": "Month"},
+				"y":     amountAxis,
+				"color": map[string]any{"field": "series", "type": "nominal", "title": nil, "sort": []string{"Assets", "Liabilities", "Net worth"}},
+			},
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported chart report '%s' (expected %s, %s or %s)", report, ChartTrend, ChartCategories, ChartNetWorth)
+	}
+
+	spec["$schema"] = vegaLiteSchema
+	spec["width"] = "container"
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode chart: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,187 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// GetAccountTotals returns the net split value per account for accounts of
+// the given types, between startDate and endDate inclusive; closing
+// transactions count only if includeClosing is set (see closingFilter).
+func (d *DB) GetAccountTotals(ctx context.Context, accountTypes []string, startDate, endDate string, includeClosing bool) (map[string]float64, error) {
+	args := []any{d.dayStart(startDate), d.dayEnd(endDate)}
+	for _, t := range accountTypes {
+		args = append(args, t)
+	}
+	closing, err := d.closingFilter(ctx, includeClosing)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT s.account_guid, SUM(CAST(s.value_num AS REAL) / s.value_denom)
+		FROM splits s
+		JOIN transactions t ON s.tx_guid = t.guid
+		JOIN accounts a ON s.account_guid = a.guid
+		WHERE t.post_date >= ? AND t.post_date <= ?
+		  AND a.account_type IN (`+placeholders(len(accountTypes))+`)`+closing+`
+		GROUP BY s.account_guid
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query account totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]float64)
+	for rows.Next() {
+		var guid string
+		var total float64
+		if err := rows.Scan(&guid, &total); err != nil {
+			return nil, fmt.Errorf("scan account total: %w", err)
+		}
+		totals[guid] = total
+	}
+	return totals, rows.Err()
+}
+
+// Waterfall is the data behind a net-cash-flow waterfall chart: income first,
+// then one step per major expense group, ending at net.
+type Waterfall struct {
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	Steps     []WaterfallStep `json:"steps"`
+	Notice    string          `json:"notice,omitempty"` // set when the date horizon excluded data
+}
+
+// WaterfallStep is one bar of the waterfall. Start and End are the running
+// total before and after the step; the net step spans from zero.
+type WaterfallStep struct {
+	Label  string  `json:"label"`
+	Kind   string  `json:"kind"` // income, expense or net
+	Amount float64 `json:"amount"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+}
+
+// String renders the waterfall as text.
+func (w Waterfall) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Cash-flow waterfall (%s to %s):\n\n", w.StartDate, w.EndDate)
+	for _, st := range w.Steps {
+		if st.Kind == "net" {
+			fmt.Fprintf(&sb, "\n  %-30s %10.2f EUR\n", strings.ToUpper(st.Label), st.Amount)
+			continue
+		}
+		fmt.Fprintf(&sb, "  %-30s %+10.2f EUR  (%.2f -> %.2f)\n", st.Label, st.Amount, st.Start, st.End)
+	}
+	sb.WriteString(horizonNote(FormatText, w.Notice))
+	return sb.String()
+}
+
+// Waterfall builds a net-cash-flow waterfall for the period. Expenses are
+// grouped by their top-level expense category, or by the configured category
+// groups when grouping is GroupByGroup; groups beyond maxGroups (largest
+// first) are folded into "Other".
+func (s *Service) Waterfall(ctx context.Context, startDate, endDate, grouping string, maxGroups int) (Waterfall, error) {
+	groupOf, err := s.groupLabeler(ctx, grouping)
+	if err != nil {
+		return Waterfall{}, err
+	}
+
+	now := s.now()
+	if startDate == "" {
+		startDate = now.Format("2006-01") + "-01"
+	}
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	}
+	if maxGroups <= 0 {
+		maxGroups = 8
+	}
+	startDate, notice, err := s.horizonStart(ctx, startDate)
+	if err != nil {
+		return Waterfall{}, err
+	}
+
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return Waterfall{}, err
+	}
+	totals, err := s.db.GetAccountTotals(ctx, []string{"INCOME", "EXPENSE"}, startDate, endDate, s.closingEntries)
+	if err != nil {
+		return Waterfall{}, err
+	}
+
+	var income float64
+	groups := make(map[string]float64)
+	for guid, total := range totals {
+		acc, ok := accounts[guid]
+		if !ok {
+			continue
+		}
+		if acc.AccountType == "INCOME" {
+			// Income is credited, so its splits are negative.
+			income -= total
+			continue
+		}
+		if groupOf != nil {
+			groups[groupOf(guid)] += total
+		} else {
+			groups[expenseGroup(acc)] += total
+		}
+	}
+
+	type group struct {
+		Name   string
+		Amount float64
+	}
+	// "Other" always comes last and absorbs the groups that don't fit.
+	other := group{Name: otherGroup, Amount: groups[otherGroup]}
+	var sorted []group
+	for name, amount := range groups {
+		if name != otherGroup && cents(amount) != 0 {
+			sorted = append(sorted, group{name, amount})
+		}
+	}
+	slices.SortFunc(sorted, func(a, b group) int {
+		return cmp.Or(cmp.Compare(b.Amount, a.Amount), cmp.Compare(a.Name, b.Name))
+	})
+	if len(sorted) > maxGroups || (len(sorted) == maxGroups && cents(other.Amount) != 0) {
+		for _, g := range sorted[maxGroups-1:] {
+			other.Amount += g.Amount
+		}
+		sorted = sorted[:maxGroups-1]
+	}
+	if cents(other.Amount) != 0 {
+		sorted = append(sorted, other)
+	}
+
+	w := Waterfall{StartDate: startDate, EndDate: endDate, Notice: notice}
+	running := cents(income)
+	w.Steps = append(w.Steps, WaterfallStep{Label: "Income", Kind: "income", Amount: running, End: running})
+	for _, g := range sorted {
+		amount := -cents(g.Amount)
+		w.Steps = append(w.Steps, WaterfallStep{Label: g.Name, Kind: "expense", Amount: amount, Start: running, End: cents(running + amount)})
+		running = cents(running + amount)
+	}
+	w.Steps = append(w.Steps, WaterfallStep{Label: "Net", Kind: "net", Amount: running, End: running})
+	return w, nil
+}
+
+// expenseGroup returns the top-level expense category of an account, i.e.
+// the second segment of its full name ("Expenses:Auto:Fuel" -> "Auto").
+func expenseGroup(acc *Account) string {
+	parts := strings.Split(acc.FullName, ":")
+	if len(parts) < 2 {
+		return acc.Name
+	}
+	return parts[1]
+}
+
+// cents rounds an amount to two decimals.
+func cents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,819 @@
+package gnucash
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// errReadOnly is returned by write methods of a DB without EnableWrites.
+var errReadOnly = errors.New("the book is open read-only")
+
+// errNoAccount is returned by resolveWriteAccount for a name that resembles
+// no account, neither partially nor as a likely typo.
+var errNoAccount = errors.New("no account found")
+
+// EnableWrites opens a second, writable connection to the book for write
+// mode. Reads keep using the read-only connection. A database that is not
+// backed by a file (tests) is written through its only connection.
+func (d *DB) EnableWrites() error {
+	if d.path == "" {
+		d.rw = d.db
+		return nil
+	}
+	dsn := fmt.Sprintf("file:%s?mode=rw&_pragma=busy_timeout(5000)", d.path)
+	rw := openLogged(func() string { return dsn }, &d.queries)
+	// A single writer connection serializes writes from concurrent tools.
+	rw.SetMaxOpenConns(1)
+	if err := rw.Ping(); err != nil {
+		rw.Close()
+		return fmt.Errorf("open database for writing: %w", err)
+	}
+	d.rw = rw
+	return nil
+}
+
+// GetCommodity returns the commodity with the given GUID.
+func (d *DB) GetCommodity(ctx context.Context, guid string) (Commodity, error) {
+	var c Commodity
+	err := d.db.QueryRowContext(ctx, `
+		SELECT guid, namespace, mnemonic, COALESCE(fullname, ''), COALESCE(cusip, ''), fraction
+		FROM commodities WHERE guid = ?
+	`, guid).Scan(&c.GUID, &c.Namespace, &c.Mnemonic, &c.FullName, &c.CUSIP, &c.Fraction)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Commodity{}, fmt.Errorf("commodity %s not found", guid)
+	}
+	if err != nil {
+		return Commodity{}, fmt.Errorf("query commodity: %w", err)
+	}
+	return c, nil
+}
+
+// defaultCurrency returns the GUID of the currency most transactions of the
+// book are in, or "" for a book without transactions.
+func (d *DB) defaultCurrency(ctx context.Context) (string, error) {
+	var guid string
+	err := d.db.QueryRowContext(ctx, `
+		SELECT currency_guid FROM transactions
+		GROUP BY currency_guid ORDER BY COUNT(*) DESC LIMIT 1
+	`).Scan(&guid)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("query default currency: %w", err)
+	}
+	return guid, nil
+}
+
+// newTransaction is a balanced transaction ready to be inserted. All values
+// share the denominator Denom; quantities equal values because every split
+// is in the transaction currency.
+type newTransaction struct {
+	GUID         string
+	CurrencyGUID string
+	PostDate     time.Time
+	Description  string
+	Denom        int64
+	Splits       []newSplit
+}
+
+type newSplit struct {
+	GUID        string
+	AccountGUID string
+	Memo        string
+	ValueNum    int64
+	OnlineID    string // bank's transaction ID, kept in the split's online_id slot
+}
+
+// insertTransaction writes tx and its splits in a single database
+// transaction, as GnuCash would. It returns the statements that undo it.
+func (d *DB) insertTransaction(ctx context.Context, tx newTransaction) ([]sqlStmt, error) {
+	return d.insertBatch(ctx, nil, []newTransaction{tx})
+}
+
+// newAccount is an account ready to be inserted, kept in Commodity.
+type newAccount struct {
+	Account
+	Commodity Commodity
+}
+
+// insertBatch writes accounts, then transactions, in a single database
+// transaction. It returns the statements that undo it.
+func (d *DB) insertBatch(ctx context.Context, accounts []newAccount, txs []newTransaction) ([]sqlStmt, error) {
+	dbTx, err := d.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbTx.Rollback()
+
+	var undo, undoAccounts []sqlStmt
+	for _, acc := range accounts {
+		stmt, err := insertAccountTx(ctx, dbTx, acc.Account, acc.Commodity)
+		if err != nil {
+			return nil, err
+		}
+		// Accounts may be nested: remove children before their parent.
+		undoAccounts = append([]sqlStmt{stmt}, undoAccounts...)
+	}
+	for _, tx := range txs {
+		stmts, err := d.insertTransactionTx(ctx, dbTx, tx)
+		if err != nil {
+			return nil, err
+		}
+		undo = append(undo, stmts...)
+	}
+	if err := d.commitWrite(dbTx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+	return append(undo, undoAccounts...), nil
+}
+
+// insertTransactionTx writes tx and its splits within dbTx and returns the
+// statements that remove them.
+func (d *DB) insertTransactionTx(ctx context.Context, dbTx *sql.Tx, tx newTransaction) ([]sqlStmt, error) {
+	_, err := dbTx.ExecContext(ctx, `
+		INSERT INTO transactions (guid, currency_guid, num, post_date, enter_date, description)
+		VALUES (?, ?, '', ?, ?, ?)
+	`, tx.GUID, tx.CurrencyGUID, d.timestamp(neutralTime(tx.PostDate)), d.timestamp(time.Now()), tx.Description)
+	if err != nil {
+		return nil, fmt.Errorf("insert transaction: %w", err)
+	}
+	for _, sp := range tx.Splits {
+		_, err = dbTx.ExecContext(ctx, `
+			INSERT INTO splits (guid, tx_guid, account_guid, memo, action, reconcile_state, reconcile_date,
+			                    value_num, value_denom, quantity_num, quantity_denom, lot_guid)
+			VALUES (?, ?, ?, ?, '', 'n', NULL, ?, ?, ?, ?, NULL)
+		`, sp.GUID, tx.GUID, sp.AccountGUID, sp.Memo, sp.ValueNum, tx.Denom, sp.ValueNum, tx.Denom)
+		if err != nil {
+			return nil, fmt.Errorf("insert split: %w", err)
+		}
+	}
+	var undo []sqlStmt
+	for _, sp := range tx.Splits {
+		if sp.OnlineID == "" {
+			continue
+		}
+		_, err = dbTx.ExecContext(ctx, `
+			INSERT INTO slots (obj_guid, name, slot_type, int64_val, string_val, double_val, numeric_val_num, numeric_val_denom)
+			VALUES (?, 'online_id', ?, 0, ?, 0, 0, 1)
+		`, sp.GUID, slotString, sp.OnlineID)
+		if err != nil {
+			return nil, fmt.Errorf("record online id: %w", err)
+		}
+		undo = append(undo, sqlStmt{`DELETE FROM slots WHERE obj_guid = ? AND name = 'online_id'`, []any{sp.GUID}})
+	}
+	return append(undo,
+		sqlStmt{`DELETE FROM splits WHERE tx_guid = ?`, []any{tx.GUID}},
+		sqlStmt{`DELETE FROM transactions WHERE guid = ?`, []any{tx.GUID}},
+	), nil
+}
+
+// GnuCash slot (KVP) types used by write mode.
+const (
+	slotNumeric = 3
+	slotString  = 4
+)
+
+// isVoided reports whether a transaction was voided.
+func (d *DB) isVoided(ctx context.Context, txGUID string) (bool, error) {
+	var n int
+	err := d.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM slots WHERE obj_guid = ? AND name = 'void-reason'
+	`, txGUID).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("query void state: %w", err)
+	}
+	return n > 0, nil
+}
+
+// voidTransaction voids a transaction the way GnuCash does: the reason and
+// time are recorded in the transaction's slots, each split's former amount
+// and value in its own, and the splits are zeroed and marked voided. It
+// returns the statements that undo it.
+func (d *DB) voidTransaction(ctx context.Context, txGUID, reason string, at time.Time) ([]sqlStmt, error) {
+	dbTx, err := d.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbTx.Rollback()
+
+	splits, err := rowImages(ctx, dbTx, "splits", "tx_guid = ?", txGUID)
+	if err != nil {
+		return nil, err
+	}
+	undo := []sqlStmt{
+		{`DELETE FROM slots WHERE obj_guid = ? AND name IN ('void-reason', 'void-time', 'trans-read-only')`, []any{txGUID}},
+		{`DELETE FROM slots WHERE obj_guid IN (SELECT guid FROM splits WHERE tx_guid = ?)
+		  AND name IN ('void-former-amount', 'void-former-value')`, []any{txGUID}},
+		{`DELETE FROM splits WHERE tx_guid = ?`, []any{txGUID}},
+	}
+	undo = append(undo, splits...)
+
+	for name, value := range map[string]string{
+		"void-reason":     reason,
+		"void-time":       at.Format("2006-01-02 15:04:05.000000 -0700"),
+		"trans-read-only": reason,
+	} {
+		_, err := dbTx.ExecContext(ctx, `
+			INSERT INTO slots (obj_guid, name, slot_type, int64_val, string_val, double_val, numeric_val_num, numeric_val_denom)
+			VALUES (?, ?, ?, 0, ?, 0, 0, 1)
+		`, txGUID, name, slotString, value)
+		if err != nil {
+			return nil, fmt.Errorf("record void reason: %w", err)
+		}
+	}
+	_, err = dbTx.ExecContext(ctx, `
+		INSERT INTO slots (obj_guid, name, slot_type, int64_val, double_val, numeric_val_num, numeric_val_denom)
+		SELECT guid, 'void-former-amount', ?, 0, 0, quantity_num, quantity_denom FROM splits WHERE tx_guid = ?
+		UNION ALL
+		SELECT guid, 'void-former-value', ?, 0, 0, value_num, value_denom FROM splits WHERE tx_guid = ?
+	`, slotNumeric, txGUID, slotNumeric, txGUID)
+	if err != nil {
+		return nil, fmt.Errorf("record former split amounts: %w", err)
+	}
+	_, err = dbTx.ExecContext(ctx, `
+		UPDATE splits SET value_num = 0, quantity_num = 0, reconcile_state = 'v' WHERE tx_guid = ?
+	`, txGUID)
+	if err != nil {
+		return nil, fmt.Errorf("void splits: %w", err)
+	}
+	if err := d.commitWrite(dbTx); err != nil {
+		return nil, fmt.Errorf("commit void: %w", err)
+	}
+	return undo, nil
+}
+
+// deleteTransaction removes a transaction with its splits and their slots.
+// It returns the statements that restore them.
+func (d *DB) deleteTransaction(ctx context.Context, txGUID string) ([]sqlStmt, error) {
+	dbTx, err := d.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbTx.Rollback()
+
+	var undo []sqlStmt
+	for _, rows := range []struct{ table, where string }{
+		{"transactions", "guid = ?1"},
+		{"splits", "tx_guid = ?1"},
+		{"slots", "obj_guid IN (SELECT guid FROM splits WHERE tx_guid = ?1) OR obj_guid = ?1"},
+	} {
+		images, err := rowImages(ctx, dbTx, rows.table, rows.where, txGUID)
+		if err != nil {
+			return nil, err
+		}
+		undo = append(undo, images...)
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM slots WHERE obj_guid IN (SELECT guid FROM splits WHERE tx_guid = ?1) OR obj_guid = ?1`,
+		`DELETE FROM splits WHERE tx_guid = ?1`,
+		`DELETE FROM transactions WHERE guid = ?1`,
+	} {
+		if _, err := dbTx.ExecContext(ctx, stmt, txGUID); err != nil {
+			return nil, fmt.Errorf("delete transaction: %w", err)
+		}
+	}
+	if err := d.commitWrite(dbTx); err != nil {
+		return nil, fmt.Errorf("commit delete: %w", err)
+	}
+	return undo, nil
+}
+
+// rootAccountGUID returns the GUID of the book's root account.
+func (d *DB) rootAccountGUID(ctx context.Context) (string, error) {
+	var guid string
+	err := d.db.QueryRowContext(ctx, `
+		SELECT guid FROM accounts
+		WHERE account_type = 'ROOT' AND parent_guid IS NULL AND name != 'Template Root'
+	`).Scan(&guid)
+	if err != nil {
+		return "", fmt.Errorf("query root account: %w", err)
+	}
+	return guid, nil
+}
+
+// insertAccount adds acc, kept in commodity, to the chart of accounts. It
+// returns the statement that removes it again while it is still unused.
+func (d *DB) insertAccount(ctx context.Context, acc Account, commodity Commodity) ([]sqlStmt, error) {
+	return d.insertBatch(ctx, []newAccount{{acc, commodity}}, nil)
+}
+
+// insertAccountTx writes acc within dbTx and returns the statement that
+// removes it while it has no splits or children.
+func insertAccountTx(ctx context.Context, dbTx *sql.Tx, acc Account, commodity Commodity) (sqlStmt, error) {
+	_, err := dbTx.ExecContext(ctx, `
+		INSERT INTO accounts (guid, name, account_type, commodity_guid, commodity_scu, non_std_scu,
+		                      parent_guid, code, description, hidden, placeholder)
+		VALUES (?, ?, ?, ?, ?, 0, ?, '', ?, 0, ?)
+	`, acc.GUID, acc.Name, acc.AccountType, commodity.GUID, commodity.Fraction,
+		acc.ParentGUID, acc.Description, acc.Placeholder)
+	if err != nil {
+		return sqlStmt{}, fmt.Errorf("insert account: %w", err)
+	}
+	return sqlStmt{`
+		DELETE FROM accounts WHERE guid = ?1
+		AND NOT EXISTS (SELECT 1 FROM splits WHERE account_guid = ?1)
+		AND NOT EXISTS (SELECT 1 FROM accounts WHERE parent_guid = ?1)
+	`, []any{acc.GUID}}, nil
+}
+
+// renameAccount changes the name of an account from oldName to name. It
+// returns the statement that restores the old name.
+func (d *DB) renameAccount(ctx context.Context, guid, oldName, name string) ([]sqlStmt, error) {
+	dbTx, err := d.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbTx.Rollback()
+
+	if _, err := dbTx.ExecContext(ctx, `UPDATE accounts SET name = ? WHERE guid = ?`, name, guid); err != nil {
+		return nil, fmt.Errorf("rename account: %w", err)
+	}
+	if err := d.commitWrite(dbTx); err != nil {
+		return nil, fmt.Errorf("commit rename: %w", err)
+	}
+	return []sqlStmt{{`UPDATE accounts SET name = ? WHERE guid = ? AND name = ?`, []any{oldName, guid, name}}}, nil
+}
+
+// newGUID returns a random GUID in GnuCash's format: 32 lowercase hex digits.
+func newGUID() string {
+	b := make([]byte, 16)
+	rand.Read(b) // never fails
+	return hex.EncodeToString(b)
+}
+
+// SplitInput is one split of a transaction to record.
+type SplitInput struct {
+	Account string  `json:"account"` // name, GUID or path, as for account lookups
+	Amount  float64 `json:"amount"`  // positive for debits, negative for credits
+	Memo    string  `json:"memo,omitempty"`
+}
+
+// WithDryRun makes write methods validate and render their change without
+// making it. Dry runs work without write mode.
+func WithDryRun() Option {
+	return func(s *Service) { s.dryRun = true }
+}
+
+// changeHeading returns the first line of a write result: done once the
+// change is made, or what would be done in a dry run.
+func (s *Service) changeHeading(done, would string) string {
+	if s.dryRun {
+		return "Dry run, nothing was written. Would " + would
+	}
+	return done
+}
+
+// checkWrites returns an error unless write mode is enabled or the call is a
+// dry run.
+func (s *Service) checkWrites() error {
+	if !s.write && !s.dryRun {
+		return fmt.Errorf("write mode is disabled (set GNUCASH_WRITE=1 to enable)")
+	}
+	return nil
+}
+
+// resolveWriteAccount finds the account a change is made to: by GUID, exact
+// full path or a leaf name no other account has. Unlike resolveAccount it
+// never guesses, so that a typo cannot book to an account the user did not
+// name: accounts matching only partially or fuzzily are listed in the error.
+func (s *Service) resolveWriteAccount(ctx context.Context, name string) (*Account, error) {
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if acc, ok := accounts[name]; ok {
+		return acc, nil
+	}
+	var leaves []*Account
+	for _, acc := range accounts {
+		if acc.FullName == name {
+			return acc, nil
+		}
+		if acc.Name == name {
+			leaves = append(leaves, acc)
+		}
+	}
+	switch len(leaves) {
+	case 0:
+	case 1:
+		return leaves[0], nil
+	default:
+		return nil, ambiguousAccountError(name, leaves)
+	}
+
+	candidates := accountsNamed(accounts, name)
+	if strings.Contains(name, ":") {
+		candidates = matchPathSuffix(name, accounts)
+		slices.SortFunc(candidates, func(a, b *Account) int { return cmp.Compare(a.FullName, b.FullName) })
+	}
+	// Names within resolveFuzzy's distance are likely typos; farther ones
+	// are only suggestions.
+	n := len([]rune(name))
+	typo := len(candidates) > 0
+	if !typo {
+		for _, m := range fuzzyMatches(name, accounts, max(1, n/2)) {
+			typo = typo || m.Distance <= max(1, n/4)
+			candidates = append(candidates, m.Account)
+		}
+	}
+	names := make([]string, 0, 3)
+	for _, acc := range candidates[:min(3, len(candidates))] {
+		names = append(names, acc.FullName)
+	}
+	switch {
+	case typo:
+		return nil, fmt.Errorf("no account is named exactly '%s' (did you mean %s?); changes need the exact name, full path or GUID of their accounts",
+			name, strings.Join(names, ", "))
+	case len(names) > 0:
+		return nil, fmt.Errorf("%w matching '%s'; did you mean %s?", errNoAccount, name, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("%w matching '%s'", errNoAccount, name)
+}
+
+// AddTransaction records a transfer of amount from fromAccount (credited) to
+// toAccount (debited) on date, e.g. from a bank account to an expense.
+func (s *Service) AddTransaction(ctx context.Context, date, description, fromAccount, toAccount string, amount float64, memo string) (string, error) {
+	if amount <= 0 {
+		return "", fmt.Errorf("amount must be positive")
+	}
+	return s.AddSplitTransaction(ctx, date, description, []SplitInput{
+		{Account: toAccount, Amount: amount, Memo: memo},
+		{Account: fromAccount, Amount: -amount, Memo: memo},
+	})
+}
+
+// AddSplitTransaction records a transaction with arbitrary splits, e.g. a
+// salary with tax withholdings. The splits must sum to zero.
+func (s *Service) AddSplitTransaction(ctx context.Context, date, description string, splits []SplitInput) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	postDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date '%s' (expected YYYY-MM-DD)", date)
+	}
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return "", fmt.Errorf("description is required")
+	}
+	if len(splits) < 2 {
+		return "", fmt.Errorf("a transaction needs at least two splits")
+	}
+
+	accounts := make([]*Account, len(splits))
+	for i, split := range splits {
+		acc, err := s.resolveWriteAccount(ctx, split.Account)
+		if err != nil {
+			return "", err
+		}
+		if acc.Placeholder {
+			return "", fmt.Errorf("account %s is a placeholder and cannot hold transactions", acc.FullName)
+		}
+		accounts[i] = acc
+	}
+	currency, err := s.transactionCurrency(ctx, accounts)
+	if err != nil {
+		return "", err
+	}
+
+	tx := newTransaction{
+		GUID:         newGUID(),
+		CurrencyGUID: currency.GUID,
+		PostDate:     postDate,
+		Description:  description,
+		Denom:        currency.Fraction,
+	}
+	var sb strings.Builder
+	sb.WriteString(s.changeHeading("Recorded transaction "+tx.GUID, "record transaction") + ":\n\n")
+	fmt.Fprintf(&sb, "%s  %s\n", date, description)
+	var total int64
+	for i, split := range splits {
+		acc := accounts[i]
+		if acc.CommodityGUID != "" && acc.CommodityGUID != currency.GUID {
+			return "", fmt.Errorf("account %s is not kept in %s; only single-currency transactions are supported", acc.FullName, currency.Mnemonic)
+		}
+		scaled := split.Amount * float64(currency.Fraction)
+		num := int64(math.Round(scaled))
+		if math.Abs(scaled-float64(num)) > 1e-6 {
+			return "", fmt.Errorf("amount %g has more decimals than %s allows", split.Amount, currency.Mnemonic)
+		}
+		if num == 0 {
+			return "", fmt.Errorf("split amounts must not be zero")
+		}
+		total += num
+		tx.Splits = append(tx.Splits, newSplit{GUID: newGUID(), AccountGUID: acc.GUID, Memo: split.Memo, ValueNum: num})
+		fmt.Fprintf(&sb, "    %s: %s %s", acc.FullName, FormatFraction(num, currency.Fraction, currency.Fraction), currency.Mnemonic)
+		if split.Memo != "" {
+			fmt.Fprintf(&sb, "  (%s)", split.Memo)
+		}
+		sb.WriteString("\n")
+	}
+	if total != 0 {
+		return "", fmt.Errorf("splits do not balance: they sum to %s %s instead of zero (debits are positive, credits negative)",
+			FormatFraction(total, currency.Fraction, currency.Fraction), currency.Mnemonic)
+	}
+
+	if s.dryRun {
+		return sb.String(), nil
+	}
+	undo, err := s.db.insertTransaction(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+	guids := []string{tx.GUID}
+	for _, sp := range tx.Splits {
+		guids = append(guids, sp.GUID)
+	}
+	s.journal(fmt.Sprintf("record transaction %s (%s %s)", tx.GUID, date, description), undo, guids...)
+	input := map[string]any{"date": date, "description": description, "splits": splits}
+	if err := s.audit("add_split_transaction", input, guids...); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// transactionCurrency picks the currency of a new transaction: that of the
+// first account kept in a currency, else the book's most used currency.
+func (s *Service) transactionCurrency(ctx context.Context, accounts []*Account) (Commodity, error) {
+	for _, acc := range accounts {
+		if acc.CommodityGUID == "" {
+			continue
+		}
+		c, err := s.db.GetCommodity(ctx, acc.CommodityGUID)
+		if err != nil {
+			return Commodity{}, err
+		}
+		if c.Namespace == "CURRENCY" {
+			return c, nil
+		}
+	}
+	guid, err := s.db.defaultCurrency(ctx)
+	if err != nil {
+		return Commodity{}, err
+	}
+	if guid == "" {
+		return Commodity{}, fmt.Errorf("cannot determine the currency of the transaction")
+	}
+	return s.db.GetCommodity(ctx, guid)
+}
+
+// Actions of VoidTransaction.
+const (
+	VoidActionVoid   = "void"
+	VoidActionDelete = "delete"
+)
+
+// VoidTransaction voids (GnuCash-style, keeping the transaction and its
+// history) or deletes the transaction with the given GUID. Nothing is changed
+// unless confirm is true.
+func (s *Service) VoidTransaction(ctx context.Context, txGUID, action, reason string, confirm bool) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	if action == "" {
+		action = VoidActionVoid
+	}
+	if action != VoidActionVoid && action != VoidActionDelete {
+		return "", fmt.Errorf("unsupported action '%s' (expected %s or %s)", action, VoidActionVoid, VoidActionDelete)
+	}
+	reason = strings.TrimSpace(reason)
+	if action == VoidActionVoid && reason == "" {
+		return "", fmt.Errorf("a reason is required to void a transaction")
+	}
+	tx, err := s.db.GetTransaction(ctx, txGUID)
+	if err != nil {
+		return "", err
+	}
+	if action == VoidActionVoid {
+		voided, err := s.db.isVoided(ctx, tx.GUID)
+		if err != nil {
+			return "", err
+		}
+		if voided {
+			return "", fmt.Errorf("transaction %s is already voided", tx.GUID)
+		}
+	}
+	if !confirm && !s.dryRun {
+		return "", fmt.Errorf("set confirm to true to %s transaction %s (%s %s)", action, tx.GUID, tx.PostDate.Format("2006-01-02"), tx.Description)
+	}
+
+	cur, err := s.bookCurrency(ctx)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	done := "Deleted transaction " + tx.GUID
+	if action == VoidActionVoid {
+		done = "Voided transaction " + tx.GUID
+	}
+	sb.WriteString(s.changeHeading(done, action+" transaction "+tx.GUID) + ":\n\n")
+	fmt.Fprintf(&sb, "%s  %s\n", tx.PostDate.Format("2006-01-02"), tx.Description)
+	for _, sp := range tx.Splits {
+		fmt.Fprintf(&sb, "    %s: %s %s\n", sp.AccountName, FormatFraction(sp.ValueNum, sp.ValueDenom, cur.Fraction), cur.Mnemonic)
+	}
+	if action == VoidActionVoid {
+		fmt.Fprintf(&sb, "\nReason: %s\n", reason)
+	}
+	if s.dryRun {
+		return sb.String(), nil
+	}
+
+	var undo []sqlStmt
+	if action == VoidActionVoid {
+		undo, err = s.db.voidTransaction(ctx, tx.GUID, reason, time.Now())
+	} else {
+		undo, err = s.db.deleteTransaction(ctx, tx.GUID)
+	}
+	if err != nil {
+		return "", err
+	}
+	guids := []string{tx.GUID}
+	for _, sp := range tx.Splits {
+		guids = append(guids, sp.GUID)
+	}
+	s.journal(fmt.Sprintf("%s transaction %s (%s %s)", action, tx.GUID, tx.PostDate.Format("2006-01-02"), tx.Description), undo, guids...)
+	input := map[string]any{"transaction_guid": txGUID, "action": action, "reason": reason, "confirm": confirm}
+	if err := s.audit("void_transaction", input, guids...); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// AccountInput describes an account to create.
+type AccountInput struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`   // GnuCash type or alias; defaults to the parent's type
+	Parent      string `json:"parent,omitempty"` // parent account; empty for a top-level account
+	Description string `json:"description,omitempty"`
+	Commodity   string `json:"commodity,omitempty"` // currency or security symbol; defaults to the parent's
+	Placeholder bool   `json:"placeholder,omitempty"`
+}
+
+// CreateAccount adds an account to the chart of accounts, enforcing
+// GnuCash's rules on names and on which account types may be nested.
+func (s *Service) CreateAccount(ctx context.Context, in AccountInput) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	name, err := checkAccountName(in.Name)
+	if err != nil {
+		return "", err
+	}
+	accountType, err := NormalizeAccountType(in.Type)
+	if err != nil {
+		return "", err
+	}
+
+	acc := Account{GUID: newGUID(), Name: name, AccountType: accountType, Description: strings.TrimSpace(in.Description), Placeholder: in.Placeholder}
+	parentType, fullName := "ROOT", name
+	var parent *Account
+	if in.Parent != "" {
+		if parent, err = s.resolveWriteAccount(ctx, in.Parent); err != nil {
+			return "", err
+		}
+		acc.ParentGUID, parentType, fullName = parent.GUID, parent.AccountType, parent.FullName+":"+name
+		if acc.AccountType == "" {
+			acc.AccountType = parent.AccountType
+		}
+	} else if acc.ParentGUID, err = s.db.rootAccountGUID(ctx); err != nil {
+		return "", err
+	}
+	if acc.AccountType == "" {
+		return "", fmt.Errorf("type is required for a top-level account")
+	}
+	if err := checkAccountNesting(parentType, acc.AccountType); err != nil {
+		return "", err
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSiblingName(accounts, acc.ParentGUID, name, ""); err != nil {
+		return "", err
+	}
+
+	commodity, err := s.accountCommodity(ctx, in.Commodity, parent)
+	if err != nil {
+		return "", err
+	}
+	security := acc.AccountType == "STOCK" || acc.AccountType == "MUTUAL"
+	if security && commodity.Namespace == "CURRENCY" {
+		return "", fmt.Errorf("%s accounts hold a security: give its symbol as commodity", acc.AccountType)
+	}
+	if !security && acc.AccountType != "TRADING" && commodity.Namespace != "CURRENCY" {
+		return "", fmt.Errorf("%s accounts must be kept in a currency, not %s", acc.AccountType, commodity.Mnemonic)
+	}
+	summary := fmt.Sprintf("%s (%s, %s)", fullName, acc.AccountType, commodity.Mnemonic)
+	if s.dryRun {
+		return s.changeHeading("", "create account "+summary) + ".\n", nil
+	}
+	undo, err := s.db.insertAccount(ctx, acc, commodity)
+	if err != nil {
+		return "", err
+	}
+	s.journal("create account "+summary, undo, acc.GUID)
+	if err := s.audit("create_account", in, acc.GUID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created account %s, GUID %s.\n", summary, acc.GUID), nil
+}
+
+// RenameAccount gives an account a new name, keeping its place in the tree
+// and its transactions.
+func (s *Service) RenameAccount(ctx context.Context, account, newName string) (string, error) {
+	if err := s.checkWrites(); err != nil {
+		return "", err
+	}
+	name, err := checkAccountName(newName)
+	if err != nil {
+		return "", err
+	}
+	acc, err := s.resolveWriteAccount(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if acc.Name == name {
+		return "", fmt.Errorf("account %s is already named '%s'", acc.FullName, name)
+	}
+	accounts, err := s.accountTree(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := checkSiblingName(accounts, acc.ParentGUID, name, acc.GUID); err != nil {
+		return "", err
+	}
+	change := fmt.Sprintf("account %s to %s", acc.FullName, strings.TrimSuffix(acc.FullName, acc.Name)+name)
+	if s.dryRun {
+		return s.changeHeading("", "rename "+change) + ".\n", nil
+	}
+	undo, err := s.db.renameAccount(ctx, acc.GUID, acc.Name, name)
+	if err != nil {
+		return "", err
+	}
+	s.journal("rename "+change, undo, acc.GUID)
+	input := map[string]any{"account_name": account, "new_name": newName}
+	if err := s.audit("rename_account", input, acc.GUID); err != nil {
+		return "", err
+	}
+	return "Renamed " + change + ".\n", nil
+}
+
+// checkAccountName trims an account name and rejects names GnuCash would
+// not accept: empty ones and ones containing the ':' separator.
+func checkAccountName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("account name is required")
+	}
+	if strings.Contains(name, ":") {
+		return "", fmt.Errorf("account name '%s' must not contain ':', the account separator", name)
+	}
+	return name, nil
+}
+
+// checkSiblingName rejects a name already used by another child of parent.
+func checkSiblingName(accounts map[string]*Account, parentGUID, name, exceptGUID string) error {
+	for _, acc := range accounts {
+		if acc.ParentGUID == parentGUID && acc.GUID != exceptGUID && strings.EqualFold(acc.Name, name) {
+			return fmt.Errorf("an account named %s already exists", acc.FullName)
+		}
+	}
+	return nil
+}
+
+// accountCommodity resolves the commodity of a new account: the given
+// symbol, else the parent's commodity, else the book's main currency.
+func (s *Service) accountCommodity(ctx context.Context, symbol string, parent *Account) (Commodity, error) {
+	if symbol != "" {
+		commodities, err := s.db.FindCommodities(ctx, symbol)
+		if err != nil {
+			return Commodity{}, err
+		}
+		switch len(commodities) {
+		case 0:
+			return Commodity{}, fmt.Errorf("no commodity found matching '%s'", symbol)
+		case 1:
+			return commodities[0], nil
+		}
+		return Commodity{}, fmt.Errorf("multiple commodities match '%s'", symbol)
+	}
+	if parent != nil && parent.CommodityGUID != "" {
+		return s.db.GetCommodity(ctx, parent.CommodityGUID)
+	}
+	guid, err := s.db.defaultCurrency(ctx)
+	if err != nil {
+		return Commodity{}, err
+	}
+	if guid == "" {
+		return Commodity{}, fmt.Errorf("commodity is required: the book has no transactions to infer its currency from")
+	}
+	return s.db.GetCommodity(ctx, guid)
+}
@@ -0,0 +1,538 @@
+package gnucash
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupWriteTestDB returns the seed database with the columns and tables
+// GnuCash itself writes (account code and scu, num, action, reconcile state
+// and date, lot, slots) and writes enabled.
+func setupWriteTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := setupTestDB(t)
+	if _, err := db.db.Exec(`
+		ALTER TABLE accounts ADD COLUMN commodity_scu INTEGER NOT NULL DEFAULT 100;
+		ALTER TABLE accounts ADD COLUMN non_std_scu INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE accounts ADD COLUMN code TEXT;
+		ALTER TABLE transactions ADD COLUMN num TEXT NOT NULL DEFAULT '';
+		ALTER TABLE splits ADD COLUMN action TEXT NOT NULL DEFAULT '';
+		ALTER TABLE splits ADD COLUMN reconcile_state TEXT NOT NULL DEFAULT 'n';
+		ALTER TABLE splits ADD COLUMN reconcile_date TEXT;
+		ALTER TABLE splits ADD COLUMN lot_guid TEXT;
+		CREATE TABLE slots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			obj_guid TEXT NOT NULL,
+			name TEXT NOT NULL,
+			slot_type INTEGER NOT NULL,
+			int64_val INTEGER,
+			string_val TEXT,
+			double_val REAL,
+			timespec_val TEXT,
+			guid_val TEXT,
+			numeric_val_num INTEGER,
+			numeric_val_denom INTEGER,
+			gdate_val TEXT
+		);
+	`); err != nil {
+		t.Fatalf("extend schema: %v", err)
+	}
+	if err := db.EnableWrites(); err != nil {
+		t.Fatalf("EnableWrites() returned error: %v", err)
+	}
+	return db
+}
+
+func TestAddTransaction(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	result, err := svc.AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Restaurant", 18.4, "lunch")
+	if err != nil {
+		t.Fatalf("AddTransaction() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"2025-02-20  Bistro",
+		"Expenses:Restaurant: 18.40 EUR  (lunch)",
+		"Assets:Checking: -18.40 EUR  (lunch)",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("AddTransaction() missing %q in:\n%s", want, result)
+		}
+	}
+
+	var postDate, currency string
+	var splits, total int64
+	if err := db.db.QueryRow(`
+		SELECT t.post_date, t.currency_guid, COUNT(*), SUM(s.value_num)
+		FROM transactions t JOIN splits s ON s.tx_guid = t.guid
+		WHERE t.description = 'Bistro'
+		  AND s.value_denom = 100 AND s.quantity_num = s.value_num AND s.quantity_denom = 100
+	`).Scan(&postDate, &currency, &splits, &total); err != nil {
+		t.Fatalf("query inserted transaction: %v", err)
+	}
+	if postDate != "2025-02-20 10:59:00" || currency != "eur" || splits != 2 || total != 0 {
+		t.Errorf("inserted post_date=%s currency=%s splits=%d total=%d", postDate, currency, splits, total)
+	}
+
+	balance, err := svc.GetBalance(ctx, "Restaurant", "", nil)
+	if err != nil {
+		t.Fatalf("GetBalance() returned error: %v", err)
+	}
+	if !strings.Contains(balance, "43.40 EUR") {
+		t.Errorf("expected Restaurant balance 43.40 EUR, got:\n%s", balance)
+	}
+}
+
+func TestAddTransaction_Errors(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		date    string
+		from    string
+		to      string
+		amount  float64
+		wantErr string
+	}{
+		{"negative amount", "2025-02-20", "Checking", "Restaurant", -5, "must be positive"},
+		{"bad date", "20/02/2025", "Checking", "Restaurant", 5, "invalid date"},
+		{"placeholder", "2025-02-20", "Checking", "Investments", 5, "placeholder"},
+		{"sub-cent amount", "2025-02-20", "Checking", "Restaurant", 5.001, "more decimals"},
+		{"security account", "2025-02-20", "Brokerage Cash", "ACME", 5, "not kept in EUR"},
+		{"unknown account", "2025-02-20", "Checking", "Zzzzzzzz", 5, "no account found"},
+		{"partial name", "2025-02-20", "Checking", "Restau", 5, "no account is named exactly 'Restau' (did you mean Expenses:Restaurant?)"},
+		{"misspelled name", "2025-02-20", "Checking", "Grocerys", 5, "did you mean Expenses:Groceries?"},
+		{"partial path", "2025-02-20", "Checking", "Expenses:Gro", 5, "did you mean Expenses:Groceries?"},
+		{"case differs", "2025-02-20", "Checking", "RESTAURANT", 5, "no account is named exactly 'RESTAURANT'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.AddTransaction(ctx, tt.date, "Test", tt.from, tt.to, tt.amount, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	var count int
+	db.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE description = 'Test'`).Scan(&count)
+	if count != 0 {
+		t.Errorf("failed calls inserted %d transactions", count)
+	}
+
+	if _, err := NewService(db).AddTransaction(ctx, "2025-02-20", "Test", "Checking", "Restaurant", 5, ""); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected write mode disabled error, got: %v", err)
+	}
+}
+
+func TestAddSplitTransaction(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	// A receipt split between groceries and a restaurant meal, accounts given
+	// by full path, GUID and name.
+	result, err := svc.AddSplitTransaction(ctx, "2025-02-21", "Market hall", []SplitInput{
+		{Account: "Expenses:Groceries", Amount: 30.25, Memo: "vegetables"},
+		{Account: "restaurant", Amount: 12},
+		{Account: "Checking", Amount: -42.25},
+	})
+	if err != nil {
+		t.Fatalf("AddSplitTransaction() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Expenses:Groceries: 30.25 EUR  (vegetables)",
+		"Expenses:Restaurant: 12.00 EUR",
+		"Assets:Checking: -42.25 EUR",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("AddSplitTransaction() missing %q in:\n%s", want, result)
+		}
+	}
+	var splits int
+	db.db.QueryRow(`SELECT COUNT(*) FROM splits s JOIN transactions t ON s.tx_guid = t.guid WHERE t.description = 'Market hall'`).Scan(&splits)
+	if splits != 3 {
+		t.Errorf("expected 3 splits, got %d", splits)
+	}
+
+	_, err = svc.AddSplitTransaction(ctx, "2025-02-21", "Unbalanced", []SplitInput{
+		{Account: "Groceries", Amount: 30},
+		{Account: "Checking", Amount: -29.99},
+	})
+	if err == nil || !strings.Contains(err.Error(), "sum to 0.01 EUR") {
+		t.Errorf("expected unbalanced error, got: %v", err)
+	}
+	_, err = svc.AddSplitTransaction(ctx, "2025-02-21", "Single", []SplitInput{{Account: "Groceries", Amount: 30}})
+	if err == nil || !strings.Contains(err.Error(), "at least two splits") {
+		t.Errorf("expected too few splits error, got: %v", err)
+	}
+}
+
+func TestVoidTransaction(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	if _, err := svc.VoidTransaction(ctx, "tx4", "", "duplicate", false); err == nil || !strings.Contains(err.Error(), "confirm") {
+		t.Fatalf("expected confirmation error, got: %v", err)
+	}
+	if _, err := svc.VoidTransaction(ctx, "tx4", "", "", true); err == nil || !strings.Contains(err.Error(), "reason is required") {
+		t.Errorf("expected missing reason error, got: %v", err)
+	}
+
+	result, err := svc.VoidTransaction(ctx, "tx4", "", "duplicate", true)
+	if err != nil {
+		t.Fatalf("VoidTransaction() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Voided transaction tx4") || !strings.Contains(result, "Reason: duplicate") {
+		t.Errorf("unexpected result:\n%s", result)
+	}
+
+	var nonZero, notVoided int
+	db.db.QueryRow(`SELECT COUNT(*) FILTER (WHERE value_num != 0 OR quantity_num != 0), COUNT(*) FILTER (WHERE reconcile_state != 'v') FROM splits WHERE tx_guid = 'tx4'`).Scan(&nonZero, &notVoided)
+	if nonZero != 0 || notVoided != 0 {
+		t.Errorf("splits not voided: %d non-zero, %d not marked voided", nonZero, notVoided)
+	}
+	var former int64
+	db.db.QueryRow(`SELECT numeric_val_num FROM slots WHERE obj_guid = 'sp4b' AND name = 'void-former-value'`).Scan(&former)
+	if former != 2500 {
+		t.Errorf("expected former value 2500 in slots, got %d", former)
+	}
+	if _, err := svc.VoidTransaction(ctx, "tx4", "void", "again", true); err == nil || !strings.Contains(err.Error(), "already voided") {
+		t.Errorf("expected already voided error, got: %v", err)
+	}
+
+	// Deleting removes the splits and slots too.
+	if _, err := svc.VoidTransaction(ctx, "tx4", "delete", "", true); err != nil {
+		t.Fatalf("VoidTransaction(delete) returned error: %v", err)
+	}
+	var rows int
+	db.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM transactions WHERE guid = 'tx4')
+		     + (SELECT COUNT(*) FROM splits WHERE tx_guid = 'tx4')
+		     + (SELECT COUNT(*) FROM slots WHERE obj_guid IN ('tx4', 'sp4a', 'sp4b'))
+	`).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("expected transaction, splits and slots deleted, %d rows remain", rows)
+	}
+	if _, err := svc.VoidTransaction(ctx, "tx4", "delete", "", true); err == nil || !strings.Contains(err.Error(), "no transaction found") {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+}
+
+func TestCreateAccount(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	result, err := svc.CreateAccount(ctx, AccountInput{Name: "Pets", Parent: "Expenses", Description: "Vet and food"})
+	if err != nil {
+		t.Fatalf("CreateAccount() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Created account Expenses:Pets (EXPENSE, EUR)") {
+		t.Errorf("unexpected result: %s", result)
+	}
+	if _, err := svc.AddTransaction(ctx, "2025-02-22", "Vet", "Checking", "Expenses:Pets", 60, ""); err != nil {
+		t.Errorf("AddTransaction() to the new account returned error: %v", err)
+	}
+
+	result, err = svc.CreateAccount(ctx, AccountInput{Name: "Globex", Type: "stock", Parent: "Investments", Commodity: "ACME"})
+	if err != nil {
+		t.Fatalf("CreateAccount(stock) returned error: %v", err)
+	}
+	if !strings.Contains(result, "Assets:Investments:Globex (STOCK, ACME)") {
+		t.Errorf("unexpected result: %s", result)
+	}
+
+	tests := []struct {
+		name    string
+		in      AccountInput
+		wantErr string
+	}{
+		{"separator in name", AccountInput{Name: "Pets:Dog", Parent: "Expenses"}, "must not contain ':'"},
+		{"duplicate sibling", AccountInput{Name: "groceries", Parent: "Expenses"}, "Expenses:Groceries already exists"},
+		{"incompatible nesting", AccountInput{Name: "Wallet", Type: "CASH", Parent: "Expenses"}, "cannot be placed under"},
+		{"top-level without type", AccountInput{Name: "Misc"}, "type is required"},
+		{"unknown type", AccountInput{Name: "Misc", Type: "gizmo"}, "unknown account type"},
+		{"stock in a currency", AccountInput{Name: "Initech", Type: "STOCK", Parent: "Investments"}, "hold a security"},
+		{"bank in a security", AccountInput{Name: "Odd", Type: "BANK", Parent: "Assets", Commodity: "ACME"}, "kept in a currency"},
+		{"partial parent", AccountInput{Name: "Pets", Parent: "Expens"}, "no account is named exactly 'Expens'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateAccount(ctx, tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRenameAccount(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	result, err := svc.RenameAccount(ctx, "Restaurant", "Dining")
+	if err != nil {
+		t.Fatalf("RenameAccount() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Renamed account Expenses:Restaurant to Expenses:Dining") {
+		t.Errorf("unexpected result: %s", result)
+	}
+	balance, err := svc.GetBalance(ctx, "Expenses:Dining", "", nil)
+	if err != nil || !strings.Contains(balance, "25.00 EUR") {
+		t.Errorf("expected renamed account to keep its balance, got %q, %v", balance, err)
+	}
+
+	if _, err := svc.RenameAccount(ctx, "Dining", "Groceries"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected duplicate name error, got: %v", err)
+	}
+	if _, err := svc.RenameAccount(ctx, "Dining", "Food:Out"); err == nil || !strings.Contains(err.Error(), "must not contain") {
+		t.Errorf("expected separator error, got: %v", err)
+	}
+
+	// A partial or misspelled name renames nothing.
+	for _, account := range []string{"Din", "Dinning", "Expenses:Din"} {
+		if _, err := svc.RenameAccount(ctx, account, "Eating out"); err == nil || !strings.Contains(err.Error(), "did you mean Expenses:Dining?") {
+			t.Errorf("RenameAccount(%q): expected the account to be refused with a suggestion, got: %v", account, err)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	db := setupWriteTestDB(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog() returned error: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	svc := NewService(db, WithWrites(), WithAuditLog(log))
+	ctx := context.Background()
+
+	args := map[string]any{"date": "2025-02-20", "amount": 18.4}
+	if _, err := svc.With(WithToolCall("add_transaction", args)).AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Restaurant", 18.4, ""); err != nil {
+		t.Fatalf("AddTransaction() returned error: %v", err)
+	}
+	if _, err := svc.RenameAccount(ctx, "Restaurant", "Restaurant"); err == nil {
+		t.Fatal("expected error renaming to the same name")
+	}
+	if _, err := svc.CreateAccount(ctx, AccountInput{Name: "Pets", Parent: "Expenses"}); err != nil {
+		t.Fatalf("CreateAccount() returned error: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("parse audit line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+
+	// Failed calls are not logged; calls without a tool call use the
+	// operation name and service input.
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Tool != "add_transaction" || len(e.GUIDs) != 3 || e.Arguments.(map[string]any)["amount"] != 18.4 {
+		t.Errorf("unexpected first entry: %+v", e)
+	}
+	if e := entries[1]; e.Tool != "create_account" || len(e.GUIDs) != 1 || e.Arguments.(map[string]any)["parent"] != "Expenses" {
+		t.Errorf("unexpected second entry: %+v", e)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithDryRun()) // dry runs need no write mode
+	ctx := context.Background()
+
+	count := func() (n int) {
+		db.db.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM transactions) + (SELECT COUNT(*) FROM splits)
+			     + (SELECT COUNT(*) FROM accounts) + (SELECT COUNT(*) FROM slots)
+		`).Scan(&n)
+		return n
+	}
+	before := count()
+
+	result, err := svc.AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Restaurant", 18.4, "")
+	if err != nil {
+		t.Fatalf("AddTransaction() returned error: %v", err)
+	}
+	if !strings.HasPrefix(result, "Dry run, nothing was written. Would record transaction:") || !strings.Contains(result, "Expenses:Restaurant: 18.40 EUR") {
+		t.Errorf("unexpected dry-run result:\n%s", result)
+	}
+
+	// Dry runs still validate.
+	if _, err := svc.AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Investments", 18.4, ""); err == nil {
+		t.Error("expected placeholder error in dry run")
+	}
+
+	result, err = svc.VoidTransaction(ctx, "tx4", "delete", "", false)
+	if err != nil {
+		t.Fatalf("VoidTransaction() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Would delete transaction tx4:") || !strings.Contains(result, "Pizza place") {
+		t.Errorf("unexpected dry-run result:\n%s", result)
+	}
+
+	result, err = svc.CreateAccount(ctx, AccountInput{Name: "Pets", Parent: "Expenses"})
+	if err != nil || !strings.Contains(result, "Would create account Expenses:Pets (EXPENSE, EUR)") {
+		t.Errorf("unexpected dry-run result %q, %v", result, err)
+	}
+	result, err = svc.RenameAccount(ctx, "Restaurant", "Dining")
+	if err != nil || !strings.Contains(result, "Would rename account Expenses:Restaurant to Expenses:Dining") {
+		t.Errorf("unexpected dry-run result %q, %v", result, err)
+	}
+
+	if after := count(); after != before {
+		t.Errorf("dry runs changed the book: %d rows before, %d after", before, after)
+	}
+}
+
+func TestUndoLastChange(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	if _, err := svc.UndoLastChange(ctx); err == nil || !strings.Contains(err.Error(), "nothing to undo") {
+		t.Fatalf("expected nothing to undo error, got: %v", err)
+	}
+	steps := []func() (string, error){
+		func() (string, error) { return svc.CreateAccount(ctx, AccountInput{Name: "Pets", Parent: "Expenses"}) },
+		func() (string, error) { return svc.RenameAccount(ctx, "Expenses:Pets", "Animals") },
+		func() (string, error) {
+			return svc.AddTransaction(ctx, "2025-02-20", "Vet", "Checking", "Expenses:Animals", 80, "")
+		},
+		func() (string, error) { return svc.VoidTransaction(ctx, "tx4", "void", "duplicate", true) },
+		func() (string, error) { return svc.VoidTransaction(ctx, "tx4", "delete", "", true) },
+	}
+	for i, step := range steps {
+		if _, err := step(); err != nil {
+			t.Fatalf("step %d returned error: %v", i, err)
+		}
+	}
+
+	// Undoing the delete and the void restores tx4 as it was.
+	result, err := svc.UndoLastChange(ctx)
+	if err != nil {
+		t.Fatalf("UndoLastChange() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Undid: delete transaction tx4") || !strings.Contains(result, "4 earlier change(s)") {
+		t.Errorf("unexpected result:\n%s", result)
+	}
+	if _, err := svc.With(WithDryRun()).UndoLastChange(ctx); err != nil {
+		t.Fatalf("UndoLastChange() dry run returned error: %v", err)
+	}
+	if _, err := svc.UndoLastChange(ctx); err != nil {
+		t.Fatalf("UndoLastChange() returned error: %v", err)
+	}
+	var value int64
+	var state string
+	var slots int
+	db.db.QueryRow(`SELECT value_num, reconcile_state FROM splits WHERE guid = 'sp4b'`).Scan(&value, &state)
+	db.db.QueryRow(`SELECT COUNT(*) FROM slots`).Scan(&slots)
+	if value != 2500 || state != "n" || slots != 0 {
+		t.Errorf("tx4 not restored: value %d, state %q, %d slots left", value, state, slots)
+	}
+
+	// A change made outside the server blocks undoing the changes it touches.
+	if _, err := svc.UndoLastChange(ctx); err != nil {
+		t.Fatalf("UndoLastChange() returned error: %v", err)
+	}
+	db.db.Exec(`UPDATE accounts SET name = 'Critters' WHERE name = 'Animals'`)
+	if _, err := svc.UndoLastChange(ctx); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Fatalf("expected book changed error, got: %v", err)
+	}
+	db.db.Exec(`UPDATE accounts SET name = 'Animals' WHERE name = 'Critters'`)
+	for range 2 {
+		if _, err := svc.UndoLastChange(ctx); err != nil {
+			t.Fatalf("UndoLastChange() returned error: %v", err)
+		}
+	}
+	var accounts, transactions int
+	db.db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE name IN ('Pets', 'Animals')`).Scan(&accounts)
+	db.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE description = 'Vet'`).Scan(&transactions)
+	if accounts != 0 || transactions != 0 {
+		t.Errorf("expected all changes undone, %d accounts and %d transactions remain", accounts, transactions)
+	}
+}
+
+func TestWriteRefusedWhileBookOpen(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	db.db.Exec(`CREATE TABLE gnclock (Hostname varchar(255), PID int); INSERT INTO gnclock VALUES ('laptop', 4242)`)
+	_, err := svc.AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Restaurant", 18.4, "")
+	if err == nil || !strings.Contains(err.Error(), "open in GnuCash (locked by laptop (PID 4242))") {
+		t.Fatalf("expected book locked error, got: %v", err)
+	}
+	if _, err := svc.With(WithDryRun()).AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Restaurant", 18.4, ""); err != nil {
+		t.Errorf("dry run returned error: %v", err)
+	}
+
+	db.db.Exec(`DELETE FROM gnclock`)
+	if _, err := svc.AddTransaction(ctx, "2025-02-20", "Bistro", "Checking", "Restaurant", 18.4, ""); err != nil {
+		t.Errorf("AddTransaction() returned error after the book was closed: %v", err)
+	}
+}
+
+func TestReconcileSplits(t *testing.T) {
+	db := setupWriteTestDB(t)
+	svc := NewService(db, WithWrites())
+	ctx := context.Background()
+
+	if _, err := svc.ReconcileSplits(ctx, []string{"sp1a", "nope"}, "2025-01-31", ""); err == nil || !strings.Contains(err.Error(), "no split found with GUID nope") {
+		t.Fatalf("expected unknown split error, got: %v", err)
+	}
+	result, err := svc.ReconcileSplits(ctx, []string{"sp1a", "sp2a", "sp2a"}, "2025-01-31", "")
+	if err != nil {
+		t.Fatalf("ReconcileSplits() returned error: %v", err)
+	}
+	if !strings.Contains(result, "Reconciled 2 split(s) as of 2025-01-31") || !strings.Contains(result, "Total Assets:Checking: 2914.50 EUR") {
+		t.Errorf("unexpected result:\n%s", result)
+	}
+	var state, date string
+	db.db.QueryRow(`SELECT reconcile_state, reconcile_date FROM splits WHERE guid = 'sp2a'`).Scan(&state, &date)
+	if state != "y" || date != "2025-01-31 10:59:00" {
+		t.Errorf("expected sp2a reconciled on 2025-01-31, got state %q, date %q", state, date)
+	}
+
+	// Reconciled splits are skipped, and cannot be downgraded to cleared.
+	result, err = svc.ReconcileSplits(ctx, []string{"sp1a", "sp3a"}, "2025-02-28", "")
+	if err != nil {
+		t.Fatalf("ReconcileSplits() returned error: %v", err)
+	}
+	if !strings.Contains(result, "1 split(s) were already reconciled") {
+		t.Errorf("expected skipped split noted, got:\n%s", result)
+	}
+	if _, err := svc.ReconcileSplits(ctx, []string{"sp1a"}, "", ReconcileCleared); err == nil || !strings.Contains(err.Error(), "already reconciled") {
+		t.Errorf("expected already reconciled error, got: %v", err)
+	}
+
+	if _, err := svc.UndoLastChange(ctx); err != nil {
+		t.Fatalf("UndoLastChange() returned error: %v", err)
+	}
+	db.db.QueryRow(`SELECT reconcile_state FROM splits WHERE guid = 'sp3a'`).Scan(&state)
+	if state != "n" {
+		t.Errorf("expected sp3a unreconciled after undo, got %q", state)
+	}
+}
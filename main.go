@@ -1,42 +1,359 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/mark3labs/mcp-go/server"
-
-	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
-	"github.com/michelgermain/gnucash-mcp/tools"
+	"github.com/michelgermain/gnucash-mcp/server"
 )
 
+const usage = `Usage:
+  gnucash-mcp [serve] [-transport stdio|sse] [-addr host:port] [-base-url URL]
+  gnucash-mcp check
+  gnucash-mcp report [<tool> [name=value ...]]
+  gnucash-mcp schedule
+
+serve runs the MCP server (the default). check opens the configured books and
+reports their contents and any inconsistency, exiting with status 1 if it
+finds one. report runs a tool once and prints its result, for scripts and
+cron jobs; without a tool name it lists them. Values that parse as JSON
+(numbers, booleans, arrays) are passed as such, others as strings. schedule
+writes the reports of GNUCASH_SCHEDULE on their schedules without serving
+MCP, until interrupted; serve writes them too while it runs.
+
+The books and features are configured with the GNUCASH_* environment
+variables, as for the server.
+`
+
 func main() {
-	filepath := os.Getenv("GNUCASH_FILE")
-	if filepath == "" {
-		fmt.Fprintln(os.Stderr, "GNUCASH_FILE environment variable is required")
-		fmt.Fprintln(os.Stderr, "Set it to the path of your GnuCash SQLite file")
-		os.Exit(1)
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
+	switch cmd {
+	case "serve":
+		os.Exit(serve(args))
+	case "check":
+		os.Exit(check(args))
+	case "report":
+		os.Exit(report(args))
+	case "schedule":
+		os.Exit(schedule(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
 
-	db, err := gnucash.NewDB(filepath)
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	transport := flags.String("transport", "stdio", "MCP transport: stdio, or sse for clients that connect over HTTP")
+	addr := flags.String("addr", "localhost:8080", "address the sse transport listens on")
+	baseURL := flags.String("base-url", "", "URL clients reach the sse transport at, when it differs from -addr (e.g. behind a proxy)")
+	flags.Parse(args)
+	if *transport != "stdio" && *transport != "sse" {
+		fmt.Fprintf(os.Stderr, "Unknown transport %q (expected stdio or sse)\n", *transport)
+		return 2
+	}
+
+	interval, err := watchInterval()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open GnuCash database: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	s, ok := openServer()
+	if !ok {
+		return 1
 	}
-	defer db.Close()
+	defer s.Close()
 
-	svc := gnucash.NewService(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.WatchBooks(ctx, interval)
+	go func() {
+		if err := s.RunSchedule(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Scheduled reports stopped: %v\n", err)
+		}
+	}()
 
-	s := server.NewMCPServer(
-		"gnucash",
-		"1.0.0",
-		server.WithToolCapabilities(false),
-	)
+	switch *transport {
+	case "sse":
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Fprintf(os.Stderr, "Serving MCP over SSE on http://%s/sse\n", *addr)
+		err = s.ServeSSE(ctx, *addr, *baseURL)
+	default:
+		err = s.ServeStdio()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+		return 1
+	}
+	return 0
+}
 
-	tools.RegisterTools(s, svc)
+func check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Parse(args)
 
-	if err := server.ServeStdio(s); err != nil {
-		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-		os.Exit(1)
+	s, ok := openServer()
+	if !ok {
+		return 1
+	}
+	defer s.Close()
+
+	result, healthy, err := s.Check(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Check failed: %v\n", err)
+		return 1
+	}
+	fmt.Print(result)
+	if !healthy {
+		return 1
+	}
+	return 0
+}
+
+func report(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	flags.Parse(args)
+	args = flags.Args()
+
+	s, ok := openServer()
+	if !ok {
+		return 1
+	}
+	defer s.Close()
+
+	if len(args) == 0 {
+		registered := s.MCPServer().ListTools()
+		names := slices.Sorted(maps.Keys(registered))
+		for _, name := range names {
+			fmt.Printf("%-26s %s\n", name, firstSentence(registered[name].Tool.Description))
+		}
+		return 0
+	}
+	toolArgs := make(map[string]any)
+	for _, arg := range args[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			fmt.Fprintf(os.Stderr, "Invalid argument %q (expected name=value)\n", arg)
+			return 2
+		}
+		var parsed any
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			parsed = value
+		}
+		toolArgs[name] = parsed
+	}
+	result, err := s.CallTool(context.Background(), args[0], toolArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	fmt.Print(result)
+	if !strings.HasSuffix(result, "\n") {
+		fmt.Println()
+	}
+	return 0
+}
+
+func schedule(args []string) int {
+	flags := flag.NewFlagSet("schedule", flag.ExitOnError)
+	flags.Parse(args)
+	if os.Getenv("GNUCASH_SCHEDULE") == "" {
+		fmt.Fprintln(os.Stderr, "GNUCASH_SCHEDULE environment variable is required")
+		fmt.Fprintln(os.Stderr, "Set it to the path of a JSON file of scheduled reports")
+		return 2
+	}
+	interval, err := watchInterval()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	s, ok := openServer()
+	if !ok {
+		return 1
+	}
+	defer s.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go s.WatchBooks(ctx, interval)
+	fmt.Fprintln(os.Stderr, "Writing scheduled reports; stop with Ctrl-C")
+	if err := s.RunSchedule(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Scheduled reports stopped: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// firstSentence returns the first sentence of a tool description, without
+// its period.
+func firstSentence(description string) string {
+	for i := 0; i < len(description); i++ {
+		if description[i] != '.' || (i+1 < len(description) && description[i+1] != ' ') {
+			continue
+		}
+		if before := description[:i]; !strings.HasSuffix(before, "e.g") && !strings.HasSuffix(before, "i.e") {
+			return before
+		}
+	}
+	return description
+}
+
+// watchInterval returns how often the books are checked for changes, from
+// GNUCASH_WATCH_INTERVAL in seconds: every 2 seconds by default, never for 0.
+func watchInterval() (time.Duration, error) {
+	value := os.Getenv("GNUCASH_WATCH_INTERVAL")
+	if value == "" {
+		return 2 * time.Second, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("GNUCASH_WATCH_INTERVAL: expected a number of seconds, got %q", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// openServer opens the books configured by the environment, reporting
+// problems on stderr.
+func openServer() (*server.Server, bool) {
+	bookPath := os.Getenv("GNUCASH_FILE")
+	if bookPath == "" && os.Getenv("GNUCASH_BOOKS") == "" {
+		fmt.Fprintln(os.Stderr, "GNUCASH_FILE environment variable is required")
+		fmt.Fprintln(os.Stderr, "Set it to the path of your GnuCash SQLite file")
+		return nil, false
+	}
+	opts, err := optionsFromEnv(bookPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return nil, false
+	}
+	s, err := server.New(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start server: %v\n", err)
+		return nil, false
+	}
+	return s, true
+}
+
+// optionsFromEnv maps the GNUCASH_* environment variables to server options.
+func optionsFromEnv(bookPath string) ([]server.Option, error) {
+	var opts []server.Option
+	if bookPath != "" {
+		opts = append(opts, server.WithBookFile(bookPath))
+	}
+	if books := os.Getenv("GNUCASH_BOOKS"); books != "" {
+		for _, entry := range strings.Split(books, ",") {
+			name, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || name == "" || path == "" {
+				return nil, fmt.Errorf("GNUCASH_BOOKS: expected name=path, got %q", entry)
+			}
+			opts = append(opts, server.WithBook(name, path))
+		}
+	}
+	if dirs := os.Getenv("GNUCASH_BOOK_DIRS"); dirs != "" {
+		opts = append(opts, server.WithBookDirs(filepath.SplitList(dirs)...))
+	}
+	if path := os.Getenv("GNUCASH_LOG_FILE"); path != "" {
+		level := slog.LevelDebug
+		if name := os.Getenv("GNUCASH_LOG_LEVEL"); name != "" {
+			if err := level.UnmarshalText([]byte(name)); err != nil {
+				return nil, fmt.Errorf("GNUCASH_LOG_LEVEL: %w", err)
+			}
+		}
+		opts = append(opts, server.WithLogFile(path, level))
+	}
+	if os.Getenv("GNUCASH_EXPRESSIONS") == "1" {
+		opts = append(opts, server.WithExpressions())
+	}
+	if os.Getenv("GNUCASH_SQL") == "1" {
+		opts = append(opts, server.WithSQL())
+	}
+	if os.Getenv("GNUCASH_REDACT") == "1" {
+		opts = append(opts, server.WithRedaction())
+	}
+	if os.Getenv("GNUCASH_SNAPSHOT_READS") == "1" {
+		opts = append(opts, server.WithSnapshotReads())
+	}
+	if os.Getenv("GNUCASH_WRITE") == "1" {
+		opts = append(opts, server.WithWriteMode())
+	}
+	if path := os.Getenv("GNUCASH_AUDIT_LOG"); path != "" {
+		opts = append(opts, server.WithAuditLog(path))
+	}
+	if value := os.Getenv("GNUCASH_RESULT_MEMORY"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("GNUCASH_RESULT_MEMORY: expected a positive integer, got %q", value)
+		}
+		opts = append(opts, server.WithResultMemory(n))
+	}
+	if value := os.Getenv("GNUCASH_CACHE_TTL"); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("GNUCASH_CACHE_TTL: expected a number of seconds, got %q", value)
+		}
+		opts = append(opts, server.WithResultCache(time.Duration(seconds*float64(time.Second))))
+	}
+	if path := os.Getenv("GNUCASH_SNAPSHOT_DB"); path != "" {
+		opts = append(opts, server.WithSnapshotStore(path))
+	}
+	if path := os.Getenv("GNUCASH_ENVELOPE_DB"); path != "" {
+		opts = append(opts, server.WithEnvelopeStore(path))
+	}
+	if path := os.Getenv("GNUCASH_SEARCH_INDEX"); path != "" {
+		opts = append(opts, server.WithSearchIndex(path))
+	}
+	if path := os.Getenv("GNUCASH_AGGREGATE_CACHE"); path != "" {
+		opts = append(opts, server.WithAggregateCache(path))
+	}
+	if value := os.Getenv("GNUCASH_HORIZON_YEARS"); value != "" {
+		years, err := strconv.Atoi(value)
+		if err != nil || years <= 0 {
+			return nil, fmt.Errorf("GNUCASH_HORIZON_YEARS: expected a positive number of years, got %q", value)
+		}
+		opts = append(opts, server.WithDateHorizon(years))
+	}
+	if dir := os.Getenv("GNUCASH_EXPORT_DIR"); dir != "" {
+		opts = append(opts, server.WithExportDir(dir))
+	}
+	if path := os.Getenv("GNUCASH_CATEGORY_GROUPS"); path != "" {
+		opts = append(opts, server.WithCategoryGroups(path))
+	}
+	if path := os.Getenv("GNUCASH_CPI_FILE"); path != "" {
+		opts = append(opts, server.WithPriceIndex(path))
+	}
+	if path := os.Getenv("GNUCASH_GOALS"); path != "" {
+		opts = append(opts, server.WithSavingsGoals(path))
+	}
+	if name := os.Getenv("GNUCASH_LOCALE"); name != "" {
+		opts = append(opts, server.WithLocale(name))
+	}
+	if name := os.Getenv("GNUCASH_TIMEZONE"); name != "" {
+		opts = append(opts, server.WithTimezone(name))
+	}
+	if name := os.Getenv("GNUCASH_WEEK_START"); name != "" {
+		opts = append(opts, server.WithWeekStart(name))
+	}
+	if path := os.Getenv("GNUCASH_SCHEDULE"); path != "" {
+		opts = append(opts, server.WithSchedule(path))
 	}
+	return opts, nil
 }
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+// Tool calls are logged at info level and the statements they run on the
+// books at debug level (see gnucash.DB.SetLogger), both to the MCP client
+// that made the call, at the level it chose with logging/setLevel, and to
+// the log file if one is configured.
+
+// clientLogHandler sends log records to the client session of the request
+// they were logged for, as MCP log messages.
+type clientLogHandler struct {
+	srv   *Server
+	attrs []slog.Attr
+}
+
+// clientLevel returns the level the client of ctx asked for, if any.
+func clientLevel(ctx context.Context) (mcp.LoggingLevel, bool) {
+	session, ok := mcpserver.ClientSessionFromContext(ctx).(mcpserver.SessionWithLogging)
+	if !ok || !session.Initialized() {
+		return "", false
+	}
+	return session.GetLogLevel(), true
+}
+
+func mcpLevel(level slog.Level) mcp.LoggingLevel {
+	switch {
+	case level >= slog.LevelError:
+		return mcp.LoggingLevelError
+	case level >= slog.LevelWarn:
+		return mcp.LoggingLevelWarning
+	case level >= slog.LevelInfo:
+		return mcp.LoggingLevelInfo
+	}
+	return mcp.LoggingLevelDebug
+}
+
+func (h *clientLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	threshold, ok := clientLevel(ctx)
+	return ok && h.srv.mcp != nil && mcpLevel(level).ShouldSendTo(threshold)
+}
+
+func (h *clientLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	data := map[string]any{"message": r.Message}
+	add := func(a slog.Attr) bool {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindDuration {
+			data[a.Key] = v.Duration().String()
+		} else {
+			data[a.Key] = v.Any()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+	// A client that went away must not fail the request being logged.
+	h.srv.mcp.SendLogMessageToClient(ctx, mcp.NewLoggingMessageNotification(mcpLevel(r.Level), name, data))
+	return nil
+}
+
+func (h *clientLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &clientLogHandler{srv: h.srv, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+// WithGroup is not supported: attributes are sent flat.
+func (h *clientLogHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// fanoutHandler passes records on to several handlers.
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanoutHandler) WithGroup(group string) slog.Handler {
+	handlers := make(fanoutHandler, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(group)
+	}
+	return handlers
+}
+
+// logToolCalls logs every tool call with its duration and outcome.
+func logToolCalls(logger *slog.Logger) mcpserver.ToolHandlerMiddleware {
+	return func(next mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, request)
+			attrs := []slog.Attr{
+				slog.String("tool", request.Params.Name),
+				slog.Any("arguments", request.GetArguments()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			level := slog.LevelInfo
+			switch {
+			case err != nil:
+				level = slog.LevelError
+				attrs = append(attrs, slog.String("error", err.Error()))
+			case result != nil && result.IsError:
+				level = slog.LevelWarn
+				if len(result.Content) > 0 {
+					if text, ok := result.Content[0].(mcp.TextContent); ok {
+						attrs = append(attrs, slog.String("error", text.Text))
+					}
+				}
+			}
+			logger.LogAttrs(ctx, level, "tool call", attrs...)
+			return result, err
+		}
+	}
+}
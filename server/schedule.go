@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule lists reports to write to a directory periodically, e.g.
+// {"dir": "/var/lib/gnucash/reports", "reports": [{"name": "spending",
+// "cron": "0 6 1 * *", "tool": "spending_by_category",
+// "args": {"start_date": "last_month", "format": "csv"}}]}.
+type Schedule struct {
+	Dir     string            `json:"dir"`
+	Reports []ScheduledReport `json:"reports"`
+}
+
+// ScheduledReport is a tool run on a cron schedule, its result written to
+// a file named after the report and the time it ran.
+type ScheduledReport struct {
+	Name string         `json:"name"`
+	Cron string         `json:"cron"` // minute hour day-of-month month day-of-week
+	Tool string         `json:"tool"`
+	Args map[string]any `json:"args"`
+
+	spec cronSpec
+}
+
+// LoadSchedule reads a report schedule from a JSON file (see Schedule).
+func LoadSchedule(path string) (Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("read schedule: %w", err)
+	}
+	var sched Schedule
+	if err := json.Unmarshal(data, &sched); err != nil {
+		return Schedule{}, fmt.Errorf("parse schedule %s: %w", path, err)
+	}
+	if sched.Dir == "" {
+		return Schedule{}, fmt.Errorf("schedule %s has no output dir", path)
+	}
+	names := make(map[string]bool)
+	for i := range sched.Reports {
+		r := &sched.Reports[i]
+		switch {
+		case r.Name == "" || strings.ContainsAny(r.Name, `/\`):
+			return Schedule{}, fmt.Errorf("scheduled report %d needs a name usable in file names", i+1)
+		case names[r.Name]:
+			return Schedule{}, fmt.Errorf("scheduled report '%s' is defined twice", r.Name)
+		case r.Tool == "":
+			return Schedule{}, fmt.Errorf("scheduled report '%s' has no tool", r.Name)
+		}
+		names[r.Name] = true
+		if r.spec, err = parseCron(r.Cron); err != nil {
+			return Schedule{}, fmt.Errorf("scheduled report '%s': %w", r.Name, err)
+		}
+	}
+	return sched, nil
+}
+
+// cronSpec is a parsed cron expression: the minutes, hours, days of the
+// month, months and days of the week it matches, as bit sets.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// A day matches either field when both are restricted, as in cron.
+	domAny, dowAny bool
+}
+
+// cronMacros are the shorthands cron accepts for common schedules.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a standard five-field cron expression. Fields take *,
+// numbers, ranges (1-5), steps (*/15, 1-10/2) and comma-separated lists;
+// months and days of the week also take English abbreviations (jan, mon),
+// and Sunday is 0 or 7.
+func parseCron(expr string) (cronSpec, error) {
+	if macro, ok := cronMacros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("invalid cron expression '%s' (expected minute hour day-of-month month day-of-week)", expr)
+	}
+	months := []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	days := []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+	var spec cronSpec
+	var err error
+	if spec.minute, err = parseCronField(fields[0], 0, 59, nil, 0); err != nil {
+		return cronSpec{}, fmt.Errorf("cron minute: %w", err)
+	}
+	if spec.hour, err = parseCronField(fields[1], 0, 23, nil, 0); err != nil {
+		return cronSpec{}, fmt.Errorf("cron hour: %w", err)
+	}
+	if spec.dom, err = parseCronField(fields[2], 1, 31, nil, 0); err != nil {
+		return cronSpec{}, fmt.Errorf("cron day of month: %w", err)
+	}
+	if spec.month, err = parseCronField(fields[3], 1, 12, months, 1); err != nil {
+		return cronSpec{}, fmt.Errorf("cron month: %w", err)
+	}
+	if spec.dow, err = parseCronField(fields[4], 0, 7, days, 0); err != nil {
+		return cronSpec{}, fmt.Errorf("cron day of week: %w", err)
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1 // Sunday
+	}
+	spec.domAny = strings.HasPrefix(fields[2], "*")
+	spec.dowAny = strings.HasPrefix(fields[4], "*")
+	if spec.next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return cronSpec{}, fmt.Errorf("cron expression '%s' never matches", expr)
+	}
+	return spec, nil
+}
+
+// parseCronField parses one field of a cron expression into a bit set of
+// the values from lo to hi it matches. names, when given, are the names of
+// the values from first.
+func parseCronField(field string, lo, hi int, names []string, first int) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return first + i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < lo || n > hi {
+			return 0, fmt.Errorf("invalid value '%s' (expected %d to %d)", s, lo, hi)
+		}
+		return n, nil
+	}
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", stepStr)
+			}
+		}
+		start, end := lo, hi
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = value(from); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = hi
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range '%s'", rng)
+			}
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// matchesDay reports whether the day of t matches the spec.
+func (c cronSpec) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t the spec matches, in t's location,
+// or the zero time when there is none in the next five years.
+func (c cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// reportExtensions maps the format argument of a scheduled report to the
+// extension of its files.
+var reportExtensions = map[string]string{"csv": ".csv", "markdown": ".md"}
+
+// RunSchedule runs the scheduled reports (see WithSchedule) until ctx is
+// done, writing each result to the schedule's directory as
+// <name>-<YYYY-MM-DDTHHMM>.<txt|csv|md>. Failed runs are logged and retried
+// at the next scheduled time. It returns at once when no report is
+// scheduled.
+func (s *Server) RunSchedule(ctx context.Context) error {
+	if len(s.schedule.Reports) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(s.schedule.Dir, 0o755); err != nil {
+		return fmt.Errorf("create report dir: %w", err)
+	}
+	due := make([]time.Time, len(s.schedule.Reports))
+	now := time.Now().In(s.location)
+	for i, r := range s.schedule.Reports {
+		due[i] = r.spec.next(now)
+	}
+	for {
+		var at time.Time
+		for _, t := range due {
+			if !t.IsZero() && (at.IsZero() || t.Before(at)) {
+				at = t
+			}
+		}
+		if at.IsZero() {
+			return nil
+		}
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		for i, r := range s.schedule.Reports {
+			if due[i].After(at) {
+				continue
+			}
+			if path, err := s.writeReport(ctx, r, at); err != nil {
+				s.logger.Warn("scheduled report failed", "report", r.Name, "tool", r.Tool, "error", err)
+			} else {
+				s.logger.Info("scheduled report", "report", r.Name, "tool", r.Tool, "file", path)
+			}
+			due[i] = r.spec.next(at)
+		}
+	}
+}
+
+// writeReport runs r and writes its result for time at, through a
+// temporary file so readers never see a partial report.
+func (s *Server) writeReport(ctx context.Context, r ScheduledReport, at time.Time) (string, error) {
+	result, err := s.CallTool(ctx, r.Tool, r.Args)
+	if err != nil {
+		return "", err
+	}
+	ext := ".txt"
+	if format, _ := r.Args["format"].(string); reportExtensions[format] != "" {
+		ext = reportExtensions[format]
+	}
+	path := filepath.Join(s.schedule.Dir, r.Name+"-"+at.Format("2006-01-02T1504")+ext)
+	tmp, err := os.CreateTemp(s.schedule.Dir, "."+r.Name+"-*")
+	if err != nil {
+		return "", fmt.Errorf("write report: %w", err)
+	}
+	_, err = tmp.WriteString(result)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write report: %w", err)
+	}
+	return path, nil
+}
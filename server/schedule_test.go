@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+func TestCronNext(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	from := time.Date(2025, 3, 14, 10, 7, 30, 0, paris) // a Friday
+	for _, tt := range []struct {
+		expr string
+		want string
+	}{
+		{"*/15 * * * *", "2025-03-14 10:15"},
+		{"0 6 1 * *", "2025-04-01 06:00"},
+		{"@monthly", "2025-04-01 00:00"},
+		{"30 9 * * mon-fri", "2025-03-17 09:30"},
+		{"0 0 * * 7", "2025-03-16 00:00"},
+		{"0 8 13 * 5", "2025-03-21 08:00"},   // the 13th or a Friday
+		{"0 0 29 feb *", "2028-02-29 00:00"}, // next leap day
+		{"0 2 30 3 *", "2026-03-30 02:00"},   // 2025-03-30 02:00 does not exist in Paris
+		{"5,10-12/2 1 * * *", "2025-03-15 01:05"},
+	} {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) returned error: %v", tt.expr, err)
+			continue
+		}
+		if got := spec.next(from).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("next of %q = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "0 0 31 2 *", "0 0 * * fun"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schedule.json")
+	if err := os.WriteFile(path, []byte(`{"dir": "`+filepath.ToSlash(dir)+`/out", "reports": [
+		{"name": "echo", "cron": "@daily", "tool": "echo", "args": {"format": "csv", "text": "a,b\n"}}
+	]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sched, err := LoadSchedule(path)
+	if err != nil {
+		t.Fatalf("LoadSchedule returned error: %v", err)
+	}
+
+	s := &Server{mcp: mcpserver.NewMCPServer("test", "1"), schedule: sched, location: time.UTC, logger: slog.New(slog.DiscardHandler)}
+	s.mcp.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(mcp.ParseString(request, "text", "")), nil
+	})
+	if err := os.MkdirAll(sched.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	written, err := s.writeReport(context.Background(), sched.Reports[0], time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("writeReport returned error: %v", err)
+	}
+	if want := filepath.Join(sched.Dir, "echo-2025-03-01T0000.csv"); written != want {
+		t.Errorf("report written to %s, want %s", written, want)
+	}
+	if data, err := os.ReadFile(written); err != nil || string(data) != "a,b\n" {
+		t.Errorf("unexpected report %q (%v)", data, err)
+	}
+	if entries, _ := os.ReadDir(sched.Dir); len(entries) != 1 {
+		t.Errorf("expected only the report in %s, got %d files", sched.Dir, len(entries))
+	}
+
+	for _, bad := range []string{`{"reports": []}`, `{"dir": "x", "reports": [{"name": "a/b", "cron": "@daily", "tool": "echo"}]}`,
+		`{"dir": "x", "reports": [{"name": "a", "cron": "daily", "tool": "echo"}]}`} {
+		os.WriteFile(path, []byte(bad), 0o600)
+		if _, err := LoadSchedule(path); err == nil || !strings.Contains(err.Error(), "schedule") {
+			t.Errorf("LoadSchedule(%s) should fail, got %v", bad, err)
+		}
+	}
+}
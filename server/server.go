@@ -0,0 +1,663 @@
+// Package server assembles the GnuCash MCP server so it can be embedded in
+// other Go programs without going through environment variables.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+	"github.com/michelgermain/gnucash-mcp/tools"
+)
+
+const (
+	name    = "gnucash"
+	version = "1.0.0"
+)
+
+// Server is a configured GnuCash MCP server serving one or more books.
+type Server struct {
+	mcp      *mcpserver.MCPServer
+	registry *tools.Books
+
+	mu    sync.Mutex
+	books []*book
+
+	logger  *slog.Logger
+	logFile *os.File
+
+	schedule Schedule
+	location *time.Location // of schedule times
+}
+
+// book is the database connection and side stores of a served book.
+type book struct {
+	db         *gnucash.DB
+	snapshots  *gnucash.SnapshotStore
+	index      *gnucash.SearchIndex
+	aggregates *gnucash.AggregateCache
+	auditLog   *gnucash.AuditLog
+	envelopes  *gnucash.EnvelopeStore
+}
+
+// Option configures a Server.
+type Option func(*config)
+
+type config struct {
+	bookPath      string
+	books         []bookFile
+	serviceOpts   []gnucash.Option
+	resultMemory  int
+	cacheTTL      time.Duration
+	snapshotPath  string
+	indexPath     string
+	aggregatePath string
+	envelopePath  string
+	write         bool
+	liveSnapshot  bool
+	sql           bool
+	redact        bool
+	auditPath     string
+	groupsPath    string
+	cpiPath       string
+	goalsPath     string
+	locale        string
+	timezone      string
+	weekStart     string
+	schedulePath  string
+	bookDirs      []string
+	logPath       string
+	logLevel      slog.Level
+}
+
+type bookFile struct {
+	name, path string
+}
+
+// WithBookFile sets the path of the default GnuCash SQLite book to serve,
+// named after its file name without extension. A book must be configured
+// with WithBookFile or WithBook.
+func WithBookFile(path string) Option {
+	return func(c *config) { c.bookPath = path }
+}
+
+// WithBook serves another GnuCash SQLite book under name, which tools take as
+// their book parameter. Without WithBookFile, the first book added is the
+// default one.
+func WithBook(name, path string) Option {
+	return func(c *config) { c.books = append(c.books, bookFile{name: name, path: path}) }
+}
+
+// WithExpressions allows computed expressions in report tools.
+func WithExpressions() Option {
+	return func(c *config) { c.serviceOpts = append(c.serviceOpts, gnucash.WithExpressions()) }
+}
+
+// WithDateHorizon restricts transaction listings and reports to the last
+// years years; tools accept all_history to lift the limit per call.
+func WithDateHorizon(years int) Option {
+	return func(c *config) { c.serviceOpts = append(c.serviceOpts, gnucash.WithDateHorizon(years)) }
+}
+
+// WithExportDir enables the export_report_bundle tool, which writes bundles
+// into subdirectories of dir.
+func WithExportDir(dir string) Option {
+	return func(c *config) { c.serviceOpts = append(c.serviceOpts, gnucash.WithExportDir(dir)) }
+}
+
+// WithSQL allows ad-hoc read-only SELECT statements through the query_sql tool.
+func WithSQL() Option {
+	return func(c *config) { c.sql = true }
+}
+
+// WithRedaction hides the descriptions, split memos and notes of
+// transactions in tool output and exports, for clients that should see
+// amounts, dates and accounts but not whom money went to or why. It cannot
+// be combined with WithSQL, whose queries read the book directly.
+func WithRedaction() Option {
+	return func(c *config) { c.redact = true }
+}
+
+// WithWriteMode opens the book for writing and enables the tools that modify
+// it, such as add_transaction.
+func WithWriteMode() Option {
+	return func(c *config) { c.write = true }
+}
+
+// WithSnapshotReads reads each book from a temporary copy while GnuCash has
+// it open, taken again whenever it changes, instead of from the book itself,
+// avoiding lock conflicts with GnuCash and changes it is halfway through
+// saving. Writes still go to the book. Changes are seen at the interval of
+// WatchBooks.
+func WithSnapshotReads() Option {
+	return func(c *config) { c.liveSnapshot = true }
+}
+
+// WithBookDirs lets clients open the GnuCash books found in dirs or their
+// subdirectories at runtime with the open_book tool. Books opened this way
+// are read-only and have no side stores.
+func WithBookDirs(dirs ...string) Option {
+	return func(c *config) { c.bookDirs = append(c.bookDirs, dirs...) }
+}
+
+// WithLogFile appends the server's log to the file at path (created if
+// missing) as JSON lines: tool calls at info level, the SQL statements they
+// run with their duration and row count at debug level. Records below level
+// are left out. Clients receive the same log over MCP whether or not a file
+// is configured, at the level they ask for.
+func WithLogFile(path string, level slog.Level) Option {
+	return func(c *config) {
+		c.logPath = path
+		c.logLevel = level
+	}
+}
+
+// WithAuditLog appends every change made in write mode to the JSON Lines
+// file at path (created if missing). With several books, each book has its
+// own file, named after the book (see WithSnapshotStore).
+func WithAuditLog(path string) Option {
+	return func(c *config) { c.auditPath = path }
+}
+
+// WithResultMemory keeps the last n tool results per session and registers
+// the recall_result and diff_results tools.
+func WithResultMemory(n int) Option {
+	return func(c *config) { c.resultMemory = n }
+}
+
+// WithResultCache answers repeated calls to read-only tools with the same
+// arguments from memory for ttl, as long as the book files are unchanged.
+func WithResultCache(ttl time.Duration) Option {
+	return func(c *config) { c.cacheTTL = ttl }
+}
+
+// WithSnapshotStore records chart-of-accounts snapshots in the SQLite file at
+// path (created if missing) to power the chart_history tool. With several
+// books, each book has its own file, the book name inserted before the
+// extension: snapshots.db becomes snapshots-business.db.
+func WithSnapshotStore(path string) Option {
+	return func(c *config) { c.snapshotPath = path }
+}
+
+// WithEnvelopeStore keeps envelope budgets in the SQLite file at path
+// (created if missing) for the set_envelope and envelope_status tools. With
+// several books, each book has its own file (see WithSnapshotStore).
+func WithEnvelopeStore(path string) Option {
+	return func(c *config) { c.envelopePath = path }
+}
+
+// WithSearchIndex keeps a full-text index of transaction descriptions and
+// memos in the SQLite file at path (created if missing) and uses it for text
+// searches. The index is built at startup and rebuilt when the book changes.
+// With several books, each book has its own file (see WithSnapshotStore).
+func WithSearchIndex(path string) Option {
+	return func(c *config) { c.indexPath = path }
+}
+
+// WithAggregateCache keeps the monthly totals of the income and expense
+// accounts in the SQLite file at path (created if missing), for the monthly
+// reports of very large books. The cache is built at startup and updated
+// month by month when the book changes. With several books, each book has
+// its own file (see WithSnapshotStore).
+func WithAggregateCache(path string) Option {
+	return func(c *config) { c.aggregatePath = path }
+}
+
+// WithCategoryGroups loads super-category definitions from the JSON file at
+// path (see gnucash.CategoryGroups) for the grouping parameter of spending
+// reports.
+func WithCategoryGroups(path string) Option {
+	return func(c *config) { c.groupsPath = path }
+}
+
+// WithPriceIndex loads the consumer price index of the CSV file at path (see
+// gnucash.LoadPriceIndex) for the inflation_adjusted parameter of reports.
+func WithPriceIndex(path string) Option {
+	return func(c *config) { c.cpiPath = path }
+}
+
+// WithSavingsGoals loads savings goals from the JSON file at path (see
+// gnucash.LoadSavingsGoals) for the goal_progress tool.
+func WithSavingsGoals(path string) Option {
+	return func(c *config) { c.goalsPath = path }
+}
+
+// WithLocale formats the amounts of text reports for the BCP 47 locale
+// name, e.g. fr-FR; tools accept locale to override it per call.
+func WithLocale(name string) Option {
+	return func(c *config) { c.locale = name }
+}
+
+// WithTimezone reads the books' dates in the IANA time zone name, e.g.
+// Europe/Paris, rather than UTC, and resolves today's date there.
+func WithTimezone(name string) Option {
+	return func(c *config) { c.timezone = name }
+}
+
+// WithWeekStart starts weeks on the day named, e.g. sunday, rather than
+// Monday.
+func WithWeekStart(name string) Option {
+	return func(c *config) { c.weekStart = name }
+}
+
+// WithSchedule writes the reports of the JSON file at path (see Schedule)
+// to its directory on their cron schedules, in the time zone of
+// WithTimezone or the local one, while RunSchedule runs.
+func WithSchedule(path string) Option {
+	return func(c *config) { c.schedulePath = path }
+}
+
+// New opens the books and registers all tools.
+func New(opts ...Option) (*Server, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	files := cfg.books
+	if cfg.bookPath != "" {
+		name := strings.TrimSuffix(filepath.Base(cfg.bookPath), filepath.Ext(cfg.bookPath))
+		files = append([]bookFile{{name: name, path: cfg.bookPath}}, files...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no book file configured (use WithBookFile or WithBook)")
+	}
+	if cfg.sql {
+		if cfg.redact {
+			return nil, errors.New("WithRedaction cannot be combined with WithSQL, whose queries would read the hidden text")
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithSQL())
+	}
+
+	if cfg.groupsPath != "" {
+		groups, err := gnucash.LoadCategoryGroups(cfg.groupsPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithCategoryGroups(groups))
+	}
+	if cfg.cpiPath != "" {
+		index, err := gnucash.LoadPriceIndex(cfg.cpiPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithPriceIndex(index))
+	}
+	if cfg.goalsPath != "" {
+		goals, err := gnucash.LoadSavingsGoals(cfg.goalsPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithSavingsGoals(goals))
+	}
+	if cfg.locale != "" {
+		tag, err := gnucash.ParseLocale(cfg.locale)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithLocale(tag))
+	}
+	location := time.Local
+	if cfg.timezone != "" {
+		loc, err := gnucash.ParseTimezone(cfg.timezone)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithTimezone(loc))
+		location = loc
+	}
+	if cfg.weekStart != "" {
+		day, err := gnucash.ParseWeekday(cfg.weekStart)
+		if err != nil {
+			return nil, err
+		}
+		cfg.serviceOpts = append(cfg.serviceOpts, gnucash.WithWeekStart(day))
+	}
+
+	var schedule Schedule
+	if cfg.schedulePath != "" {
+		var err error
+		if schedule, err = LoadSchedule(cfg.schedulePath); err != nil {
+			return nil, err
+		}
+	}
+
+	srv := &Server{schedule: schedule, location: location}
+	handlers := fanoutHandler{&clientLogHandler{srv: srv}}
+	if cfg.logPath != "" {
+		var err error
+		srv.logFile, err = os.OpenFile(cfg.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open log file: %w", err)
+		}
+		handlers = append(handlers, slog.NewJSONHandler(srv.logFile, &slog.HandlerOptions{Level: cfg.logLevel}))
+	}
+	srv.logger = slog.New(handlers)
+
+	books := tools.NewBooks()
+	for _, f := range files {
+		b, svc, err := openBook(&cfg, f, len(files) > 1)
+		if err != nil {
+			srv.Close()
+			return nil, err
+		}
+		b.db.SetLogger(srv.logger.With("book", f.name))
+		srv.books = append(srv.books, b)
+		if err := books.Add(f.name, f.path, svc); err != nil {
+			srv.Close()
+			return nil, err
+		}
+	}
+	if len(cfg.bookDirs) > 0 {
+		books.AllowOpening(cfg.bookDirs, func(path string) (*gnucash.Service, error) {
+			db, err := gnucash.NewDB(path)
+			if err != nil {
+				return nil, fmt.Errorf("open GnuCash database: %w", err)
+			}
+			if cfg.liveSnapshot {
+				if err := db.EnableSnapshotReads(); err != nil {
+					db.Close()
+					return nil, err
+				}
+			}
+			if cfg.redact {
+				db.EnableRedaction()
+			}
+			db.SetLogger(srv.logger.With("book", filepath.Base(path)))
+			srv.mu.Lock()
+			srv.books = append(srv.books, &book{db: db})
+			srv.mu.Unlock()
+			return gnucash.NewService(db, cfg.serviceOpts...), nil
+		})
+	}
+
+	// Clients are notified when the tool list changes, e.g. when an embedding
+	// program adds tools through MCPServer after startup. The write tools are
+	// only listed in write mode.
+	serverOpts := []mcpserver.ServerOption{
+		mcpserver.WithToolCapabilities(true),
+		mcpserver.WithLogging(),
+		mcpserver.WithToolHandlerMiddleware(logToolCalls(srv.logger)),
+	}
+	var memory *tools.ResultMemory
+	if cfg.resultMemory > 0 {
+		memory = tools.NewResultMemory(cfg.resultMemory)
+		serverOpts = append(serverOpts, mcpserver.WithToolHandlerMiddleware(memory.Middleware()))
+	}
+	if cfg.cacheTTL > 0 {
+		cache := tools.NewResultCache(books, cfg.cacheTTL)
+		serverOpts = append(serverOpts, mcpserver.WithToolHandlerMiddleware(cache.Middleware()))
+	}
+
+	s := mcpserver.NewMCPServer(name, version, serverOpts...)
+	tools.RegisterTools(s, books)
+	if cfg.write {
+		tools.RegisterWriteTools(s, books)
+	}
+	tools.RegisterServerInfo(s, books, version)
+	tools.RegisterBookResources(s, books)
+	if memory != nil {
+		tools.RegisterMemoryTools(s, memory)
+	}
+
+	for _, r := range schedule.Reports {
+		if s.GetTool(r.Tool) == nil {
+			srv.Close()
+			return nil, fmt.Errorf("scheduled report '%s': unknown tool '%s'", r.Name, r.Tool)
+		}
+	}
+
+	srv.mcp = s
+	srv.registry = books
+	return srv, nil
+}
+
+// openBook opens the book of f with its side stores. When several books
+// are served, side stores get a file per book.
+func openBook(cfg *config, f bookFile, several bool) (*book, *gnucash.Service, error) {
+	sidePath := func(path string) string {
+		if !several {
+			return path
+		}
+		ext := filepath.Ext(path)
+		return strings.TrimSuffix(path, ext) + "-" + f.name + ext
+	}
+
+	db, err := gnucash.NewDB(f.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open GnuCash database %s: %w", f.name, err)
+	}
+	b := &book{db: db}
+	serviceOpts := slices.Clip(cfg.serviceOpts)
+	if cfg.liveSnapshot {
+		if err := db.EnableSnapshotReads(); err != nil {
+			b.close()
+			return nil, nil, err
+		}
+	}
+	if cfg.redact {
+		db.EnableRedaction()
+	}
+	if cfg.write {
+		if err := db.EnableWrites(); err != nil {
+			b.close()
+			return nil, nil, err
+		}
+		serviceOpts = append(serviceOpts, gnucash.WithWrites())
+	}
+	if cfg.snapshotPath != "" {
+		b.snapshots, err = gnucash.OpenSnapshotStore(sidePath(cfg.snapshotPath))
+		if err != nil {
+			b.close()
+			return nil, nil, err
+		}
+		serviceOpts = append(serviceOpts, gnucash.WithSnapshotStore(b.snapshots))
+	}
+	if cfg.auditPath != "" {
+		b.auditLog, err = gnucash.OpenAuditLog(sidePath(cfg.auditPath))
+		if err != nil {
+			b.close()
+			return nil, nil, err
+		}
+		serviceOpts = append(serviceOpts, gnucash.WithAuditLog(b.auditLog))
+	}
+	if cfg.envelopePath != "" {
+		b.envelopes, err = gnucash.OpenEnvelopeStore(sidePath(cfg.envelopePath))
+		if err != nil {
+			b.close()
+			return nil, nil, err
+		}
+		serviceOpts = append(serviceOpts, gnucash.WithEnvelopeStore(b.envelopes))
+	}
+	if cfg.indexPath != "" {
+		b.index, err = gnucash.OpenSearchIndex(sidePath(cfg.indexPath))
+		if err != nil {
+			b.close()
+			return nil, nil, err
+		}
+		if _, err := b.index.Sync(context.Background(), db); err != nil {
+			b.close()
+			return nil, nil, fmt.Errorf("build search index: %w", err)
+		}
+		serviceOpts = append(serviceOpts, gnucash.WithSearchIndex(b.index))
+	}
+	if cfg.aggregatePath != "" {
+		b.aggregates, err = gnucash.OpenAggregateCache(sidePath(cfg.aggregatePath))
+		if err != nil {
+			b.close()
+			return nil, nil, err
+		}
+		serviceOpts = append(serviceOpts, gnucash.WithAggregateCache(b.aggregates))
+	}
+	svc := gnucash.NewService(db, serviceOpts...)
+	if err := svc.RecordChartSnapshot(context.Background()); err != nil {
+		b.close()
+		return nil, nil, fmt.Errorf("record chart snapshot: %w", err)
+	}
+	// After NewService, which sets the time zone the months are bucketed in.
+	if err := svc.SyncAggregates(context.Background()); err != nil {
+		b.close()
+		return nil, nil, fmt.Errorf("build aggregate cache: %w", err)
+	}
+	return b, svc, nil
+}
+
+// WatchBooks checks the books every interval until ctx is done (see
+// gnucash.Service.Refresh). When GnuCash saved a book, it logs it and
+// notifies clients that the book's resource (see tools.BookURI) was
+// updated, so they know earlier results may be stale. It returns at once
+// for an interval of zero.
+func (s *Server) WatchBooks(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, b := range s.registry.List() {
+			changed, err := b.Service.Refresh(ctx)
+			if err != nil {
+				s.logger.Warn("refresh book", "book", b.Name, "error", err)
+			}
+			if changed {
+				s.logger.Info("book changed", "book", b.Name)
+				s.mcp.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": tools.BookURI(b.Name)})
+			}
+		}
+	}
+}
+
+// MCPServer returns the underlying MCP server, e.g. to add custom tools or
+// serve it over a transport of the caller's choosing.
+func (s *Server) MCPServer() *mcpserver.MCPServer {
+	return s.mcp
+}
+
+// Check checks every configured book (see gnucash.Service.CheckBook) and
+// reports whether all of them are free of problems.
+func (s *Server) Check(ctx context.Context) (string, bool, error) {
+	var sb strings.Builder
+	healthy := true
+	for _, b := range s.registry.List() {
+		report, problems, err := b.Service.CheckBook(ctx)
+		if err != nil {
+			return "", false, fmt.Errorf("check book %s: %w", b.Name, err)
+		}
+		fmt.Fprintf(&sb, "== %s (%s)\n%s", b.Name, b.File, report)
+		healthy = healthy && problems == 0
+	}
+	return sb.String(), healthy, nil
+}
+
+// CallTool runs a tool in-process with the given arguments, e.g. to use the
+// reports outside an MCP session, and returns its text. A tool reporting an
+// error returns it as an error.
+func (s *Server) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	tool := s.mcp.GetTool(name)
+	if tool == nil {
+		return "", fmt.Errorf("unknown tool '%s'", name)
+	}
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := tool.Handler(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			sb.WriteString(text.Text)
+		}
+	}
+	if result.IsError {
+		return "", errors.New(sb.String())
+	}
+	return sb.String(), nil
+}
+
+// ServeStdio serves MCP requests over stdin/stdout until the input closes.
+func (s *Server) ServeStdio() error {
+	return mcpserver.ServeStdio(s.mcp)
+}
+
+// ServeSSE serves MCP requests over Server-Sent Events on addr, e.g.
+// "localhost:8080", until ctx is done, for clients that do not launch the
+// server themselves. Clients open the event stream at /sse and post their
+// messages to the endpoint it announces. baseURL is the URL clients reach
+// the server at when it differs from addr, e.g. behind a reverse proxy.
+func (s *Server) ServeSSE(ctx context.Context, addr, baseURL string) error {
+	httpServer := &http.Server{Addr: addr}
+	opts := []mcpserver.SSEOption{mcpserver.WithHTTPServer(httpServer), mcpserver.WithKeepAlive(true)}
+	if baseURL != "" {
+		opts = append(opts, mcpserver.WithBaseURL(baseURL))
+	}
+	sse := mcpserver.NewSSEServer(s.mcp, opts...)
+	httpServer.Handler = sse
+
+	errc := make(chan error, 1)
+	go func() { errc <- sse.Start(addr) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sse.Shutdown(shutdown); err != nil {
+		return fmt.Errorf("shut down SSE server: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Close releases the books' database connections and side stores.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, b := range s.books {
+		errs = append(errs, b.close())
+	}
+	if s.logFile != nil {
+		errs = append(errs, s.logFile.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (b *book) close() error {
+	if b.auditLog != nil {
+		b.auditLog.Close()
+	}
+	if b.snapshots != nil {
+		b.snapshots.Close()
+	}
+	if b.index != nil {
+		b.index.Close()
+	}
+	if b.aggregates != nil {
+		b.aggregates.Close()
+	}
+	if b.envelopes != nil {
+		b.envelopes.Close()
+	}
+	return b.db.Close()
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteToolsOnlyInWriteMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE transactions (guid TEXT PRIMARY KEY, post_date TEXT);
+		CREATE TABLE prices (guid TEXT PRIMARY KEY, date TEXT);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, write := range []bool{false, true} {
+		opts := []Option{WithBookFile(path)}
+		if write {
+			opts = append(opts, WithWriteMode())
+		}
+		srv, err := New(opts...)
+		if err != nil {
+			t.Fatalf("New() returned error: %v", err)
+		}
+		for _, name := range []string{"add_transaction", "void_transaction", "import_csv", "undo_last_change"} {
+			if listed := srv.mcp.GetTool(name) != nil; listed != write {
+				t.Errorf("write mode %v: tool %s listed: %v", write, name, listed)
+			}
+		}
+		if srv.mcp.GetTool("get_balance") == nil {
+			t.Errorf("write mode %v: expected get_balance to be listed", write)
+		}
+		srv.Close()
+	}
+}
+
+func TestRedactionRefusesSQL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.gnucash")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE transactions (guid TEXT PRIMARY KEY, post_date TEXT);
+		CREATE TABLE prices (guid TEXT PRIMARY KEY, date TEXT);
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(WithBookFile(path), WithRedaction(), WithSQL()); err == nil {
+		t.Error("expected WithRedaction and WithSQL to be refused together")
+	}
+	srv, err := New(WithBookFile(path), WithRedaction())
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+	srv.Close()
+}
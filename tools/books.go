@@ -0,0 +1,329 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+)
+
+// Book is a GnuCash book served under a name.
+type Book struct {
+	Name    string
+	File    string // file name of the book, without its directory
+	Service *gnucash.Service
+	Opened  bool // opened at runtime with open_book
+
+	path string
+}
+
+// maxOpenedBooks bounds the books open_book serves at once, each with its
+// own connections and caches.
+const maxOpenedBooks = 16
+
+// BookOpener opens the GnuCash book at path for open_book.
+type BookOpener func(path string) (*gnucash.Service, error)
+
+// Books is the registry of the books a server serves. Every tool takes a
+// book parameter naming one; calls without it use the default book, the
+// first one added.
+type Books struct {
+	mu    sync.RWMutex
+	books []*Book
+
+	openDirs []string
+	opener   BookOpener
+}
+
+// NewBooks creates an empty registry.
+func NewBooks() *Books {
+	return &Books{}
+}
+
+// Add registers the book at path under name, which must be unique
+// (case-insensitive).
+func (b *Books) Add(name, path string, svc *gnucash.Service) error {
+	if name == "" {
+		return errors.New("book name is required")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.checkName(name); err != nil {
+		return err
+	}
+	book := &Book{Name: name, File: filepath.Base(path), Service: svc, path: path}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		book.path = resolved
+	}
+	b.books = append(b.books, book)
+	return nil
+}
+
+func (b *Books) checkName(name string) error {
+	for _, book := range b.books {
+		if strings.EqualFold(book.Name, name) {
+			return fmt.Errorf("book '%s' is already configured", name)
+		}
+	}
+	return nil
+}
+
+// AllowOpening lets open_book serve the GnuCash books found in dirs or
+// their subdirectories, opened with opener.
+func (b *Books) AllowOpening(dirs []string, opener BookOpener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openDirs = dirs
+	b.opener = opener
+}
+
+// Open serves the book at path under name, which defaults to the file name
+// without extension. The path must be absolute and, symbolic links
+// resolved, lie in one of the directories allowed by AllowOpening. A book
+// already served is returned as is; past maxOpenedBooks, no more are opened.
+func (b *Books) Open(name, path string) (*Book, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.opener == nil || len(b.openDirs) == 0 {
+		return nil, errors.New("opening books is disabled (set GNUCASH_BOOK_DIRS to enable)")
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("path must be absolute: %s", path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("book file %s not found", path)
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+	if !b.allowed(resolved) {
+		return nil, fmt.Errorf("%s is outside the directories books can be opened from", path)
+	}
+	for _, book := range b.books {
+		if book.path == resolved {
+			return book, nil
+		}
+	}
+	opened := 0
+	for _, book := range b.books {
+		if book.Opened {
+			opened++
+		}
+	}
+	if opened >= maxOpenedBooks {
+		return nil, fmt.Errorf("at most %d books can be opened at runtime", maxOpenedBooks)
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := b.checkName(name); err != nil {
+		return nil, fmt.Errorf("%w; pass another name", err)
+	}
+	svc, err := b.opener(resolved)
+	if err != nil {
+		return nil, err
+	}
+	book := &Book{Name: name, File: filepath.Base(path), Service: svc, Opened: true, path: resolved}
+	b.books = append(b.books, book)
+	return book, nil
+}
+
+// allowed reports whether the resolved path lies in an allowed directory.
+func (b *Books) allowed(path string) bool {
+	for _, dir := range b.openDirs {
+		dir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the books in the order they were added, the default first.
+func (b *Books) List() []*Book {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]*Book(nil), b.books...)
+}
+
+// Get returns the book named name, or the default book if name is empty.
+func (b *Books) Get(name string) (*Book, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.books) == 0 {
+		return nil, errors.New("no book is configured")
+	}
+	if name == "" {
+		return b.books[0], nil
+	}
+	names := make([]string, len(b.books))
+	for i, book := range b.books {
+		if strings.EqualFold(book.Name, name) {
+			return book, nil
+		}
+		names[i] = book.Name
+	}
+	return nil, fmt.Errorf("book '%s' not found (available: %s)", name, strings.Join(names, ", "))
+}
+
+// bookHandler handles a tool call against the book it selects.
+type bookHandler func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error)
+
+// addBookTool adds tool with a book parameter, and calls handler with the
+// service of the book the call selects. Date presets in the date parameters
+// are resolved to dates first (see gnucash.Service.ResolveDateArgs).
+func addBookTool(s *server.MCPServer, books *Books, tool mcp.Tool, handler bookHandler) {
+	mcp.WithString("book",
+		mcp.Description("Name of the book to query, as listed by list_books (default: the first configured book)"),
+	)(&tool)
+	for _, key := range gnucash.DateArgs {
+		if p, ok := tool.InputSchema.Properties[key].(map[string]any); ok {
+			if d, ok := p["description"].(string); ok {
+				if !strings.HasSuffix(d, ".") {
+					d += "."
+				}
+				p["description"] = d + " Presets such as last_month, this_quarter, ytd or last_90_days are accepted too."
+			}
+		}
+	}
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		book, err := books.Get(mcp.ParseString(request, "book", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if args := request.GetArguments(); args != nil {
+			// Resolve date presets on a copy, leaving the client's request as sent.
+			resolved := maps.Clone(args)
+			if err := book.Service.ResolveDateArgs(resolved); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			request.Params.Arguments = resolved
+		}
+		return handler(ctx, request, book.Service)
+	})
+}
+
+func registerListBooks(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("list_books",
+		mcp.WithDescription("List the GnuCash books this server serves, by the name other tools take as their book parameter. The first one is the default book."),
+		readOnlyHints(),
+	)
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var sb strings.Builder
+		for i, book := range books.List() {
+			fmt.Fprintf(&sb, "- %s (%s)", book.Name, book.File)
+			if i == 0 {
+				sb.WriteString(" [default]")
+			}
+			if book.Opened {
+				sb.WriteString(" [opened with open_book, read-only]")
+			}
+			sb.WriteString("\n")
+		}
+		if sb.Len() == 0 {
+			return mcp.NewToolResultText("No book configured.\n"), nil
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
+
+func registerOpenBook(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("open_book",
+		mcp.WithDescription("Open another GnuCash SQLite book at runtime and serve it under a name other tools take as their book parameter. The file must lie in one of the directories the server allows (GNUCASH_BOOK_DIRS); books opened this way are read-only."),
+		withHints(false, false, true),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Absolute path of the GnuCash SQLite file"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Name to serve the book under (default: the file name without extension)"),
+		),
+	)
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError("path is required"), nil
+		}
+		book, err := books.Open(mcp.ParseString(request, "name", ""), path)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Book '%s' (%s) is open. Pass book: \"%s\" to other tools to query it.\n", book.Name, book.File, book.Name)), nil
+	})
+}
+
+// BookURI returns the URI of the resource describing the book named name.
+func BookURI(name string) string {
+	return "gnucash://books/" + url.PathEscape(name)
+}
+
+// RegisterBookResources adds a resource per served book describing it as
+// server_info does. The server notifies clients that a book's resource was
+// updated when GnuCash saves the book.
+func RegisterBookResources(s *server.MCPServer, books *Books) {
+	template := mcp.NewResourceTemplate("gnucash://books/{name}", "GnuCash book",
+		mcp.WithTemplateDescription("A served GnuCash book: its file, contents, schema version and features. Updated when GnuCash saves the book."),
+		mcp.WithTemplateMIMEType("text/plain"),
+	)
+	s.AddResourceTemplate(template, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		name, err := url.PathUnescape(strings.TrimPrefix(request.Params.URI, "gnucash://books/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid book URI %s", request.Params.URI)
+		}
+		book, err := books.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		info, err := book.Service.BookInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "text/plain", Text: info}}, nil
+	})
+}
+
+// RegisterServerInfo adds the server_info tool, reporting version as the
+// server's.
+func RegisterServerInfo(s *server.MCPServer, books *Books, version string) {
+	tool := mcp.NewTool("server_info",
+		mcp.WithDescription("Describe this server and the books it serves, to troubleshoot a client setup: server version, and for each book its file path, size and last modification, contents, GnuCash schema version and features, whether GnuCash has it open, read-only or write mode, and the optional features enabled."),
+		readOnlyHints(),
+	)
+	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var sb strings.Builder
+		list := books.List()
+		fmt.Fprintf(&sb, "Server: gnucash-mcp %s (%s, %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		fmt.Fprintf(&sb, "Books: %d\n", len(list))
+		for i, book := range list {
+			info, err := book.Service.BookInfo(ctx)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("book %s: %v", book.Name, err)), nil
+			}
+			fmt.Fprintf(&sb, "\n== %s", book.Name)
+			if i == 0 {
+				sb.WriteString(" [default]")
+			}
+			if book.Opened {
+				sb.WriteString(" [opened with open_book]")
+			}
+			fmt.Fprintf(&sb, "\n%s", info)
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	})
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+)
+
+// openableBooks returns a registry allowing books to be opened from
+// <root>/books, and root.
+func openableBooks(t *testing.T) (*Books, string) {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"books", "books2", "outside"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	books := NewBooks()
+	books.AllowOpening([]string{filepath.Join(root, "books")}, func(path string) (*gnucash.Service, error) {
+		db, err := gnucash.NewDB(path)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { db.Close() })
+		return gnucash.NewService(db), nil
+	})
+	return books, root
+}
+
+func TestOpenBook(t *testing.T) {
+	books, root := openableBooks(t)
+	path := filepath.Join(root, "books", "sub", "home.gnucash")
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	createTestBook(t, path)
+
+	book, err := books.Open("", path)
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	if book.Name != "home" || !book.Opened {
+		t.Errorf("expected book 'home' opened at runtime, got %+v", book)
+	}
+	again, err := books.Open("other", path)
+	if err != nil || again != book {
+		t.Errorf("expected the book already served, got %v, %v", again, err)
+	}
+	if _, err := books.Open("", "books/sub/home.gnucash"); err == nil {
+		t.Error("expected a relative path to be refused")
+	}
+}
+
+func TestOpenBookOutsideAllowedDirs(t *testing.T) {
+	books, root := openableBooks(t)
+	outside := filepath.Join(root, "outside", "secret.gnucash")
+	createTestBook(t, outside)
+	sibling := filepath.Join(root, "books2", "secret.gnucash")
+	createTestBook(t, sibling)
+	link := filepath.Join(root, "books", "link.gnucash")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	linkedDir := filepath.Join(root, "books", "linked")
+	if err := os.Symlink(filepath.Join(root, "outside"), linkedDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, path := range map[string]string{
+		"symbolic link to a file outside":      link,
+		"file in a linked directory outside":   filepath.Join(linkedDir, "secret.gnucash"),
+		"path escaping with ..":                filepath.Join(root, "books", "..", "outside", "secret.gnucash"),
+		"sibling directory sharing the prefix": sibling,
+		"directory itself":                     filepath.Join(root, "books"),
+	} {
+		if book, err := books.Open("", path); err == nil {
+			t.Errorf("%s: expected %s to be refused, got book '%s'", name, path, book.Name)
+		}
+	}
+	if n := len(books.List()); n != 0 {
+		t.Errorf("expected no book opened, got %d", n)
+	}
+}
+
+func TestOpenBookLimit(t *testing.T) {
+	books, root := openableBooks(t)
+	open := func(i int) error {
+		path := filepath.Join(root, "books", fmt.Sprintf("book%d.gnucash", i))
+		createTestBook(t, path)
+		_, err := books.Open("", path)
+		return err
+	}
+	for i := range maxOpenedBooks {
+		if err := open(i); err != nil {
+			t.Fatalf("Open() of book %d returned error: %v", i, err)
+		}
+	}
+	if err := open(maxOpenedBooks); err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Errorf("expected the book past the limit to be refused, got %v", err)
+	}
+}
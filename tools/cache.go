@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ResultCache keeps the results of read-only tools for a while, so that
+// follow-up questions asking for the same report again do not rescan the
+// book. Results are keyed by tool, arguments and the state of the book
+// files, so a save in GnuCash invalidates them at once, and a call to a tool
+// that changes a book or the server's files about it (a write,
+// set_envelope) drops the results of that book.
+type ResultCache struct {
+	ttl   time.Duration
+	books *Books
+
+	mu      sync.Mutex
+	entries map[string]cachedResult
+}
+
+type cachedResult struct {
+	result  *mcp.CallToolResult
+	book    string // name of the book the call selected
+	expires time.Time
+}
+
+// NewResultCache creates a cache keeping results for ttl.
+func NewResultCache(books *Books, ttl time.Duration) *ResultCache {
+	return &ResultCache{ttl: ttl, books: books, entries: make(map[string]cachedResult)}
+}
+
+// Middleware answers calls to read-only tools from the cache when it can and
+// caches their successful results.
+func (c *ResultCache) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			tool := toolOf(ctx, request.Params.Name)
+			book := c.bookOf(request)
+			switch {
+			case changesState(tool):
+				result, err := next(ctx, request)
+				c.clearBook(book)
+				return result, err
+			case !cacheable(tool):
+				return next(ctx, request)
+			}
+			key, ok := c.key(request)
+			if !ok {
+				return next(ctx, request)
+			}
+			if result, ok := c.get(key); ok {
+				return result, nil
+			}
+			result, err := next(ctx, request)
+			if err != nil || result == nil || result.IsError {
+				return result, err
+			}
+			c.put(key, book, result)
+			return copyResult(result), nil
+		}
+	}
+}
+
+// toolOf returns the tool called, or nil outside a server.
+func toolOf(ctx context.Context, name string) *mcp.Tool {
+	s := server.ServerFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	tool := s.GetTool(name)
+	if tool == nil {
+		return nil
+	}
+	return &tool.Tool
+}
+
+// cacheable reports whether the results of the tool can be cached: those of
+// read-only tools, except the ones recalling the results of the session.
+func cacheable(tool *mcp.Tool) bool {
+	if tool == nil {
+		return false
+	}
+	switch tool.Name {
+	case "recall_result", "diff_results":
+		return false
+	}
+	readOnly := tool.Annotations.ReadOnlyHint
+	return readOnly != nil && *readOnly
+}
+
+// changesState reports whether calls to the tool can change what the tools
+// of the book they select report. Export tools only write files elsewhere,
+// and open_book adds a book, which changes every key.
+func changesState(tool *mcp.Tool) bool {
+	if tool == nil || strings.HasPrefix(tool.Name, "export_") || tool.Name == "open_book" {
+		return false
+	}
+	readOnly := tool.Annotations.ReadOnlyHint
+	return readOnly == nil || !*readOnly
+}
+
+// bookOf returns the name of the book a call selects, "" if there is none.
+func (c *ResultCache) bookOf(request mcp.CallToolRequest) string {
+	book, err := c.books.Get(mcp.ParseString(request, "book", ""))
+	if err != nil {
+		return ""
+	}
+	return book.Name
+}
+
+// key returns the cache key of a call: the tool, its arguments and the state
+// of every book file.
+func (c *ResultCache) key(request mcp.CallToolRequest) (string, bool) {
+	args, err := json.Marshal(request.Params.Arguments)
+	if err != nil {
+		return "", false
+	}
+	var sb strings.Builder
+	sb.WriteString(request.Params.Name)
+	sb.WriteByte(0)
+	sb.Write(args)
+	for _, book := range c.books.List() {
+		sb.WriteByte(0)
+		sb.WriteString(book.Name)
+		sb.WriteByte('@')
+		sb.WriteString(book.Service.FileStamp())
+	}
+	return sb.String(), true
+}
+
+func (c *ResultCache) get(key string) (*mcp.CallToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return copyResult(entry.result), true
+}
+
+func (c *ResultCache) put(key, book string, result *mcp.CallToolResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedResult{result: copyResult(result), book: book, expires: now.Add(c.ttl)}
+}
+
+// clearBook drops the results of calls that selected book.
+func (c *ResultCache) clearBook(book string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if entry.book == book {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// copyResult copies a result, so that middlewares tagging the one returned
+// (see ResultMemory) do not change the cached one.
+func copyResult(result *mcp.CallToolResult) *mcp.CallToolResult {
+	copied := *result
+	copied.Content = slices.Clone(result.Content)
+	return &copied
+}
@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+)
+
+// createTestBook creates an empty GnuCash SQLite book at path.
+func createTestBook(t *testing.T, path string) {
+	t.Helper()
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+	_, err = raw.Exec(`
+		CREATE TABLE transactions (guid TEXT PRIMARY KEY, post_date TEXT);
+		CREATE TABLE prices (guid TEXT PRIMARY KEY, date TEXT);
+	`)
+	raw.Close()
+	if err != nil {
+		t.Fatalf("create book: %v", err)
+	}
+}
+
+// newTestBook creates an empty GnuCash SQLite book at path and opens it.
+func newTestBook(t *testing.T, path string) *gnucash.Service {
+	t.Helper()
+	createTestBook(t, path)
+	db, err := gnucash.NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB returned error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return gnucash.NewService(db)
+}
+
+// callTool calls a tool of s as a client would.
+func callTool(t *testing.T, s *server.MCPServer, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	msg, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := s.HandleMessage(context.Background(), msg).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("call to %s failed", name)
+	}
+	result, ok := resp.Result.(mcp.CallToolResult)
+	if !ok {
+		t.Fatalf("call to %s returned %T", name, resp.Result)
+	}
+	return &result
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	if len(result.Content) != 1 {
+		return ""
+	}
+	text, _ := result.Content[0].(mcp.TextContent)
+	return text.Text
+}
+
+// cacheServer serves books "main" and "other" through a result cache, with a
+// read-only report tool counting its runs, a tool changing state and an
+// export tool.
+func cacheServer(t *testing.T) (*server.MCPServer, *ResultCache, *int, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	books := NewBooks()
+	var paths []string
+	for _, name := range []string{"main", "other"} {
+		path := filepath.Join(dir, name+".gnucash")
+		if err := books.Add(name, path, newTestBook(t, path)); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	cache := NewResultCache(books, time.Hour)
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(cache.Middleware()))
+
+	runs := new(int)
+	report := mcp.NewTool("report", readOnlyHints(), mcp.WithString("book"))
+	s.AddTool(report, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		*runs++
+		return mcp.NewToolResultText(fmt.Sprintf("run %d of %s", *runs, mcp.ParseString(request, "book", "main"))), nil
+	})
+	noop := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("done"), nil
+	}
+	s.AddTool(mcp.NewTool("set_envelope", withHints(false, true, true), mcp.WithString("book")), noop)
+	s.AddTool(mcp.NewTool("export_beancount", withHints(false, false, false), mcp.WithString("book")), noop)
+	return s, cache, runs, paths
+}
+
+func TestResultCacheHit(t *testing.T) {
+	s, _, runs, _ := cacheServer(t)
+
+	first := resultText(callTool(t, s, "report", nil))
+	second := resultText(callTool(t, s, "report", nil))
+	if *runs != 1 || second != first {
+		t.Errorf("expected the second call to be answered from the cache, got %d runs, %q then %q", *runs, first, second)
+	}
+	callTool(t, s, "report", map[string]any{"book": "other"})
+	if *runs != 2 {
+		t.Errorf("expected other arguments to miss the cache, got %d runs", *runs)
+	}
+}
+
+func TestResultCacheExpiry(t *testing.T) {
+	s, cache, runs, _ := cacheServer(t)
+
+	callTool(t, s, "report", nil)
+	cache.mu.Lock()
+	for k, entry := range cache.entries {
+		entry.expires = time.Now().Add(-time.Second)
+		cache.entries[k] = entry
+	}
+	cache.mu.Unlock()
+	callTool(t, s, "report", nil)
+	if *runs != 2 {
+		t.Errorf("expected an expired result to be run again, got %d runs", *runs)
+	}
+}
+
+func TestResultCacheFileChange(t *testing.T) {
+	s, _, runs, paths := cacheServer(t)
+
+	callTool(t, s, "report", nil)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(paths[0], later, later); err != nil {
+		t.Fatal(err)
+	}
+	callTool(t, s, "report", nil)
+	if *runs != 2 {
+		t.Errorf("expected a saved book to invalidate its results, got %d runs", *runs)
+	}
+}
+
+func TestResultCacheClearsTouchedBook(t *testing.T) {
+	s, _, runs, _ := cacheServer(t)
+
+	callTool(t, s, "report", nil)
+	callTool(t, s, "report", map[string]any{"book": "other"})
+
+	callTool(t, s, "export_beancount", nil)
+	callTool(t, s, "report", nil)
+	if *runs != 2 {
+		t.Errorf("expected an export to leave the cache alone, got %d runs", *runs)
+	}
+
+	callTool(t, s, "set_envelope", map[string]any{"book": "other"})
+	callTool(t, s, "report", nil)
+	if *runs != 2 {
+		t.Errorf("expected the results of the default book to stay cached, got %d runs", *runs)
+	}
+	callTool(t, s, "report", map[string]any{"book": "other"})
+	if *runs != 3 {
+		t.Errorf("expected the results of the changed book to be dropped, got %d runs", *runs)
+	}
+}
+
+func TestResultCacheCopies(t *testing.T) {
+	s, _, _, _ := cacheServer(t)
+
+	miss := callTool(t, s, "report", nil)
+	want := resultText(miss)
+	miss.Content[0] = mcp.NewTextContent("changed by the caller")
+	hit := callTool(t, s, "report", nil)
+	if got := resultText(hit); got != want {
+		t.Fatalf("cached result changed through the first caller: %q", got)
+	}
+	hit.Content[0] = mcp.NewTextContent("changed by the caller")
+	if got := resultText(callTool(t, s, "report", nil)); got != want {
+		t.Errorf("cached result changed through a later caller: %q", got)
+	}
+}
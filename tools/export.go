@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+)
+
+// Export tools write the book, or part of it, to a file of the export
+// directory in the format of another accounting tool.
+
+func registerExportBeancount(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("export_beancount",
+		mcp.WithDescription("Write the book's accounts, commodities, prices and transactions to a Beancount file in the server's export directory, to migrate to or cross-check with plaintext accounting tools. With a start date, balances carried from before it are opened against Equity:Opening-Balances. Requires GNUCASH_EXPORT_DIR."),
+		withHints(false, false, false),
+		mcp.WithString("start_date",
+			mcp.Description("First day to export (YYYY-MM-DD). Defaults to the beginning of the book."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("Last day to export (YYYY-MM-DD). Defaults to the end of the book."),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.ExportBeancount(ctx, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerExportLedger(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("export_ledger",
+		mcp.WithDescription("Write transactions to a Ledger/hledger journal in the server's export directory, with every split, its memo, and commodity annotations (lot cost and date of securities bought, total cost of splits in another commodity). Select an account subtree and a date range, or export the whole book. With a start date, balances carried from before it are opened against Equity:Opening Balances. Requires GNUCASH_EXPORT_DIR."),
+		withHints(false, false, false),
+		mcp.WithString("account",
+			mcp.Description("Only export transactions of this account and its sub-accounts. "+accountNameDescription),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("First day to export (YYYY-MM-DD). Defaults to the beginning of the book."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("Last day to export (YYYY-MM-DD). Defaults to the end of the book."),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account := mcp.ParseString(request, "account", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.ExportLedger(ctx, account, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerExportAccount(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("export_account",
+		mcp.WithDescription("Write the register of a bank, cash, credit card, asset or liability account to a QIF or OFX file in the server's export directory, to import it into another tool or send it to an accountant. QIF keeps the counterpart accounts as categories (transfers in brackets, split transactions as splits); OFX carries the balance at the end of the period. Requires GNUCASH_EXPORT_DIR."),
+		withHints(false, false, false),
+		mcp.WithString("account",
+			mcp.Required(),
+			mcp.Description("Account to export. "+accountNameDescription),
+		),
+		mcp.WithString("format",
+			mcp.Required(),
+			mcp.Description("File format"),
+			mcp.Enum(gnucash.AccountExportQIF, gnucash.AccountExportOFX),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("First day to export (YYYY-MM-DD). Defaults to the account's first transaction."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("Last day to export (YYYY-MM-DD). Defaults to the account's last transaction."),
+		),
+		mcp.WithString("date_format",
+			mcp.Description("Date format of QIF files, e.g. DD/MM/YYYY (default: MM/DD/YYYY, as Quicken reads them)"),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account, err := request.RequireString("account")
+		if err != nil {
+			return mcp.NewToolResultError("account is required"), nil
+		}
+		format, err := request.RequireString("format")
+		if err != nil {
+			return mcp.NewToolResultError("format is required"), nil
+		}
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		dateFormat := mcp.ParseString(request, "date_format", "")
+		result, err := svc.ExportAccount(ctx, account, format, startDate, endDate, dateFormat)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerExportJSON(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("export_json",
+		mcp.WithDescription("Write a structured JSON dump of the book to the server's export directory: commodities, accounts, transactions with their splits, prices and budgets, for backup, analysis pipelines or migration. Amounts are exact decimal strings. Export the whole book or a date range of transactions and prices; with a start date, balances carried from before it are included. Requires GNUCASH_EXPORT_DIR."),
+		withHints(false, false, false),
+		mcp.WithString("start_date",
+			mcp.Description("First day of transactions and prices to export (YYYY-MM-DD). Defaults to the beginning of the book."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("Last day of transactions and prices to export (YYYY-MM-DD). Defaults to the end of the book."),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.ExportJSON(ctx, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+)
+
+// Import tools record bank statements as transactions. They are write-mode
+// tools; a dry run shows what would be imported.
+
+const columnDescription = "column header, or 1-based column number for files without a header"
+
+func registerImportCSV(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("import_csv",
+		mcp.WithDescription("Import a bank or credit card statement exported as CSV into an account. Each line becomes a transaction between the account and its counterpart: the account named exactly in the category column (created if create_accounts is set and no account resembles it), else default_account, else Imbalance-<currency> as in GnuCash. Lines already in the book (same date, amount and description) are skipped. Run with dry_run first to check the column mapping. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, false, true),
+		mcp.WithString("account",
+			mcp.Required(),
+			mcp.Description("Bank or card account the statement is for. "+writeAccountDescription),
+		),
+		mcp.WithString("csv",
+			mcp.Required(),
+			mcp.Description("CSV content of the statement"),
+		),
+		mcp.WithObject("profile",
+			mcp.Required(),
+			mcp.Description("Column mapping of the file. Give amount for a signed amount column, or debit and credit for separate columns"),
+			mcp.Properties(map[string]any{
+				"date":          map[string]any{"type": "string", "description": "Date " + columnDescription},
+				"date_format":   map[string]any{"type": "string", "description": "Date format, e.g. DD/MM/YYYY or MM/DD/YY (default: YYYY-MM-DD)"},
+				"description":   map[string]any{"type": "string", "description": "Description/payee " + columnDescription},
+				"amount":        map[string]any{"type": "string", "description": "Signed amount " + columnDescription},
+				"debit":         map[string]any{"type": "string", "description": "Money out " + columnDescription},
+				"credit":        map[string]any{"type": "string", "description": "Money in " + columnDescription},
+				"memo":          map[string]any{"type": "string", "description": "Memo " + columnDescription},
+				"category":      map[string]any{"type": "string", "description": "Counterpart account name or path, matched exactly " + columnDescription},
+				"delimiter":     map[string]any{"type": "string", "description": "Field delimiter (default: ','; use ';' or \\t as needed)"},
+				"decimal_comma": map[string]any{"type": "boolean", "description": "Amounts use a decimal comma (1.234,56)"},
+				"no_header":     map[string]any{"type": "boolean", "description": "The file has no header line"},
+				"negate":        map[string]any{"type": "boolean", "description": "Amounts are positive for money out, as on many card statements"},
+			}),
+		),
+		mcp.WithString("default_account",
+			mcp.Description("Counterpart of lines without a category, e.g. Expenses:Miscellaneous. "+writeAccountDescription),
+		),
+		mcp.WithBoolean("create_accounts",
+			mcp.Description("Create categories that resemble no account: under the parent given in their path, else under the top-level expense or income account"),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		var args struct {
+			Account        string             `json:"account"`
+			CSV            string             `json:"csv"`
+			Profile        gnucash.CSVProfile `json:"profile"`
+			DefaultAccount string             `json:"default_account"`
+			CreateAccounts bool               `json:"create_accounts"`
+		}
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError("invalid arguments: " + err.Error()), nil
+		}
+		if args.Account == "" {
+			return mcp.NewToolResultError("account is required"), nil
+		}
+		result, err := svc.ImportCSV(ctx, args.Account, args.CSV, args.Profile, args.DefaultAccount, args.CreateAccounts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerImportOFX(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("import_ofx",
+		mcp.WithDescription("Import an OFX or QFX file downloaded from a bank into an account. Transactions already imported (same FITID, whether by this tool or by GnuCash's own OFX importer) or already in the book (same date, amount and description) are skipped. Imported transactions are booked against default_account, else Imbalance-<currency>. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, false, true),
+		mcp.WithString("account",
+			mcp.Required(),
+			mcp.Description("Bank or card account the file is for. "+writeAccountDescription),
+		),
+		mcp.WithString("ofx",
+			mcp.Required(),
+			mcp.Description("Content of the OFX/QFX file"),
+		),
+		mcp.WithString("default_account",
+			mcp.Description("Counterpart of the imported transactions, e.g. Expenses:Miscellaneous. "+writeAccountDescription),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account, err := request.RequireString("account")
+		if err != nil {
+			return mcp.NewToolResultError("account is required"), nil
+		}
+		content, err := request.RequireString("ofx")
+		if err != nil {
+			return mcp.NewToolResultError("ofx is required"), nil
+		}
+		defaultAccount := mcp.ParseString(request, "default_account", "")
+		result, err := svc.ImportOFX(ctx, account, content, defaultAccount)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerImportQIF(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("import_qif",
+		mcp.WithDescription("Import a QIF bank, cash or credit card register into an account. QIF categories are mapped to existing income and expense accounts by name, then by fuzzy matching ([Transfers] to any account); the report lists the mapping. Run with dry_run first, review the mapping with the user and pass corrections in mapping. Lines of unmatched categories go to default_account, else Imbalance-<currency>. Lines already in the book are skipped. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, false, true),
+		mcp.WithString("account",
+			mcp.Required(),
+			mcp.Description("Account the register is for. "+writeAccountDescription),
+		),
+		mcp.WithString("qif",
+			mcp.Required(),
+			mcp.Description("Content of the QIF file"),
+		),
+		mcp.WithString("date_format",
+			mcp.Description("Date format of the file, e.g. DD/MM/YYYY (default: MM/DD/YYYY, as Quicken writes them)"),
+		),
+		mcp.WithObject("mapping",
+			mcp.Description("Accounts to use for QIF categories, overriding the automatic mapping, e.g. {\"Auto:Fuel\": \"Expenses:Car:Gas\"}; accounts are matched exactly, by name, full path or GUID"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("default_account",
+			mcp.Description("Counterpart of lines whose category matches no account. "+writeAccountDescription),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		var args struct {
+			Account        string            `json:"account"`
+			QIF            string            `json:"qif"`
+			DateFormat     string            `json:"date_format"`
+			Mapping        map[string]string `json:"mapping"`
+			DefaultAccount string            `json:"default_account"`
+		}
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError("invalid arguments: " + err.Error()), nil
+		}
+		if args.Account == "" {
+			return mcp.NewToolResultError("account is required"), nil
+		}
+		result, err := svc.ImportQIF(ctx, args.Account, args.QIF, args.DateFormat, args.Mapping, args.DefaultAccount)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
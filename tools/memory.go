@@ -0,0 +1,289 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxSessions bounds the sessions a ResultMemory keeps results for: the
+// transports do not reliably tell when a session ends, so past that many
+// the results of the session used least recently are dropped.
+const maxSessions = 64
+
+// maxDiffLines bounds the lines, past those the results share at either
+// end, that diff_results compares.
+const maxDiffLines = 20000
+
+// ResultMemory keeps the last N text results of each client session so the
+// assistant can refer back to them by ID instead of re-running queries.
+type ResultMemory struct {
+	size int
+
+	mu       sync.Mutex
+	sessions map[string]*sessionResults
+	uses     uint64 // stores and lookups so far, to order sessions by use
+}
+
+type sessionResults struct {
+	next    int
+	results []storedResult // oldest first, at most size entries
+	used    uint64         // value of uses when last stored to or read
+}
+
+type storedResult struct {
+	ID   string
+	Tool string
+	Text string
+}
+
+// NewResultMemory creates a memory keeping up to size results per session.
+func NewResultMemory(size int) *ResultMemory {
+	return &ResultMemory{size: size, sessions: make(map[string]*sessionResults)}
+}
+
+// Middleware records every successful text tool result and tags it with its ID.
+func (m *ResultMemory) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := next(ctx, request)
+			if err != nil || result == nil || result.IsError || len(result.Content) != 1 {
+				return result, err
+			}
+			switch request.Params.Name {
+			case "recall_result", "diff_results":
+				return result, err
+			}
+			text, ok := result.Content[0].(mcp.TextContent)
+			if !ok {
+				return result, err
+			}
+			id := m.store(sessionID(ctx), request.Params.Name, text.Text)
+			text.Text += fmt.Sprintf("\n\n[result %s]", id)
+			result.Content[0] = text
+			return result, err
+		}
+	}
+}
+
+func (m *ResultMemory) store(session, tool, text string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sr, ok := m.sessions[session]
+	if !ok {
+		if len(m.sessions) >= maxSessions {
+			m.evictSession()
+		}
+		sr = &sessionResults{}
+		m.sessions[session] = sr
+	}
+	m.uses++
+	sr.used = m.uses
+	sr.next++
+	id := fmt.Sprintf("r%d", sr.next)
+	sr.results = append(sr.results, storedResult{ID: id, Tool: tool, Text: text})
+	if len(sr.results) > m.size {
+		sr.results = sr.results[len(sr.results)-m.size:]
+	}
+	return id
+}
+
+func (m *ResultMemory) get(session, id string) (storedResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sr, ok := m.sessions[session]; ok {
+		m.uses++
+		sr.used = m.uses
+		for _, r := range sr.results {
+			if r.ID == id {
+				return r, nil
+			}
+		}
+	}
+	return storedResult{}, fmt.Errorf("no stored result '%s' (only the last %d results are kept)", id, m.size)
+}
+
+// evictSession drops the results of the session used least recently.
+func (m *ResultMemory) evictSession() {
+	var oldest string
+	for id, sr := range m.sessions {
+		if oldest == "" || sr.used < m.sessions[oldest].used {
+			oldest = id
+		}
+	}
+	delete(m.sessions, oldest)
+}
+
+func sessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// RegisterMemoryTools adds the recall_result and diff_results tools.
+func RegisterMemoryTools(s *server.MCPServer, m *ResultMemory) {
+	recall := mcp.NewTool("recall_result",
+		mcp.WithDescription("Return a previous tool result of this session by its ID (shown as [result rN] at the end of each result)."),
+		readOnlyHints(),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Result ID, e.g. r3"),
+		),
+	)
+	s.AddTool(recall, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError("id is required"), nil
+		}
+		r, err := m.get(sessionID(ctx), id)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Result %s (%s):\n\n%s", r.ID, r.Tool, r.Text)), nil
+	})
+
+	diff := mcp.NewTool("diff_results",
+		mcp.WithDescription("Compare two previous tool results of this session line by line. Lines only in the first result are prefixed with '-', lines only in the second with '+'."),
+		readOnlyHints(),
+		mcp.WithString("first",
+			mcp.Required(),
+			mcp.Description("ID of the first result, e.g. r1"),
+		),
+		mcp.WithString("second",
+			mcp.Required(),
+			mcp.Description("ID of the second result, e.g. r2"),
+		),
+	)
+	s.AddTool(diff, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		first, err := request.RequireString("first")
+		if err != nil {
+			return mcp.NewToolResultError("first is required"), nil
+		}
+		second, err := request.RequireString("second")
+		if err != nil {
+			return mcp.NewToolResultError("second is required"), nil
+		}
+		a, err := m.get(sessionID(ctx), first)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		b, err := m.get(sessionID(ctx), second)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		changes, err := diffLines(strings.Split(a.Text, "\n"), strings.Split(b.Text, "\n"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(changes) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("Results %s and %s are identical.", a.ID, b.ID)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Differences between %s (%s) and %s (%s):\n\n%s",
+			a.ID, a.Tool, b.ID, b.Tool, strings.Join(changes, "\n"))), nil
+	})
+}
+
+// diffLines returns the lines removed from a ("- ") and added in b ("+ "),
+// in order, based on their longest common subsequence. It runs in space
+// linear in the number of lines (Hirschberg's algorithm), and refuses
+// results differing in more than maxDiffLines lines.
+func diffLines(a, b []string) ([]string, error) {
+	a, b = trimCommon(a, b)
+	if len(a)+len(b) > maxDiffLines {
+		return nil, fmt.Errorf("results differ in too many lines to compare (%d, at most %d)", len(a)+len(b), maxDiffLines)
+	}
+	var out []string
+	diffInto(&out, a, b)
+	return out, nil
+}
+
+// trimCommon drops the lines a and b share at the start and at the end.
+func trimCommon(a, b []string) ([]string, []string) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	return a, b
+}
+
+// diffInto appends the diff of a and b to out, splitting a in two halves
+// and b where the longest common subsequences of the halves meet.
+func diffInto(out *[]string, a, b []string) {
+	a, b = trimCommon(a, b)
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			*out = append(*out, "+ "+line)
+		}
+		return
+	case len(b) == 0:
+		for _, line := range a {
+			*out = append(*out, "- "+line)
+		}
+		return
+	case len(a) == 1:
+		i := slices.Index(b, a[0])
+		if i < 0 {
+			*out = append(*out, "- "+a[0])
+			diffInto(out, nil, b)
+			return
+		}
+		diffInto(out, nil, b[:i])
+		diffInto(out, nil, b[i+1:])
+		return
+	}
+	mid := len(a) / 2
+	front, back := lcsFront(a[:mid], b), lcsBack(a[mid:], b)
+	split := 0
+	for j := range front {
+		if front[j]+back[j] > front[split]+back[split] {
+			split = j
+		}
+	}
+	diffInto(out, a[:mid], b[:split])
+	diffInto(out, a[mid:], b[split:])
+}
+
+// lcsFront returns, for each j, the length of the longest common
+// subsequence of a and b[:j].
+func lcsFront(a, b []string) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for _, line := range a {
+		for j := 1; j <= len(b); j++ {
+			if line == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsBack returns, for each j, the length of the longest common
+// subsequence of a and b[j:].
+func lcsBack(a, b []string) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
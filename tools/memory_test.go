@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// memoryServer serves the memory tools and an echo tool returning its text
+// argument, through a memory of size results.
+func memoryServer(size int) (*server.MCPServer, *ResultMemory) {
+	memory := NewResultMemory(size)
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolHandlerMiddleware(memory.Middleware()))
+	RegisterMemoryTools(s, memory)
+	s.AddTool(mcp.NewTool("echo", readOnlyHints(), mcp.WithString("text")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(mcp.ParseString(request, "text", "")), nil
+	})
+	return s, memory
+}
+
+func TestResultMemoryRecall(t *testing.T) {
+	s, _ := memoryServer(2)
+
+	for i, text := range []string{"first", "second", "third"} {
+		want := fmt.Sprintf("%s\n\n[result r%d]", text, i+1)
+		if got := resultText(callTool(t, s, "echo", map[string]any{"text": text})); got != want {
+			t.Errorf("expected the result tagged with its ID, got %q", got)
+		}
+	}
+	result := callTool(t, s, "recall_result", map[string]any{"id": "r3"})
+	if got := resultText(result); result.IsError || got != "Result r3 (echo):\n\nthird" {
+		t.Errorf("recall_result(r3) = %q", got)
+	}
+	if got := resultText(callTool(t, s, "recall_result", map[string]any{"id": "r3"})); strings.Contains(got, "[result") {
+		t.Errorf("expected recalled results not to be stored again, got %q", got)
+	}
+	// Only the last two results are kept.
+	if result := callTool(t, s, "recall_result", map[string]any{"id": "r1"}); !result.IsError {
+		t.Errorf("expected r1 to be forgotten, got %q", resultText(result))
+	}
+	if result := callTool(t, s, "recall_result", map[string]any{"id": "r9"}); !result.IsError {
+		t.Errorf("expected an error for an unknown ID, got %q", resultText(result))
+	}
+}
+
+func TestResultMemoryDiff(t *testing.T) {
+	s, _ := memoryServer(10)
+
+	callTool(t, s, "echo", map[string]any{"text": "a\nb\nc"})
+	callTool(t, s, "echo", map[string]any{"text": "a\nc\nd"})
+	callTool(t, s, "echo", map[string]any{"text": "a\nb\nc"})
+	callTool(t, s, "echo", map[string]any{"text": ""})
+
+	got := resultText(callTool(t, s, "diff_results", map[string]any{"first": "r1", "second": "r2"}))
+	if !strings.HasSuffix(got, "- b\n+ d") {
+		t.Errorf("diff_results(r1, r2) = %q", got)
+	}
+	got = resultText(callTool(t, s, "diff_results", map[string]any{"first": "r1", "second": "r3"}))
+	if got != "Results r1 and r3 are identical." {
+		t.Errorf("diff_results(r1, r3) = %q", got)
+	}
+	got = resultText(callTool(t, s, "diff_results", map[string]any{"first": "r4", "second": "r4"}))
+	if got != "Results r4 and r4 are identical." {
+		t.Errorf("diff_results(r4, r4) = %q", got)
+	}
+	got = resultText(callTool(t, s, "diff_results", map[string]any{"first": "r4", "second": "r1"}))
+	if !strings.HasSuffix(got, "- \n+ a\n+ b\n+ c") {
+		t.Errorf("diff_results(r4, r1) = %q", got)
+	}
+	if result := callTool(t, s, "diff_results", map[string]any{"first": "r1", "second": "r9"}); !result.IsError {
+		t.Errorf("expected an error for an unknown ID, got %q", resultText(result))
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	lines := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, " ")
+	}
+	for _, tt := range []struct {
+		a, b string
+		want []string
+	}{
+		{"", "", nil},
+		{"a b c", "a b c", nil},
+		{"", "a b", []string{"+ a", "+ b"}},
+		{"a b", "", []string{"- a", "- b"}},
+		{"a b c d", "a c d e", []string{"- b", "+ e"}},
+		{"x a y b z", "a q b", []string{"- x", "- y", "+ q", "- z"}},
+	} {
+		got, err := diffLines(lines(tt.a), lines(tt.b))
+		if err != nil {
+			t.Fatalf("diffLines(%q, %q) returned error: %v", tt.a, tt.b, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	// Diffs of any lines are minimal: as long as the lines outside a longest
+	// common subsequence.
+	for _, pair := range [][2]string{
+		{"a b c a b b a", "c b a b a c"},
+		{"a a a b", "b a a a"},
+		{"x y z x y z", "z y x"},
+		{"1 2 3 4 5 6 7 8", "8 1 3 2 5 4 7 6"},
+	} {
+		a, b := lines(pair[0]), lines(pair[1])
+		got, err := diffLines(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var removed, added []string
+		for _, line := range got {
+			if text, ok := strings.CutPrefix(line, "- "); ok {
+				removed = append(removed, text)
+			} else {
+				added = append(added, strings.TrimPrefix(line, "+ "))
+			}
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); len(got) != want || !isSubsequence(removed, a) || !isSubsequence(added, b) {
+			t.Errorf("diffLines(%q, %q) = %q, want %d changes", pair[0], pair[1], got, want)
+		}
+	}
+
+	// Large results sharing most lines are compared past what they share.
+	big := make([]string, 5*maxDiffLines)
+	for i := range big {
+		big[i] = fmt.Sprint(i)
+	}
+	changed := slices.Clone(big)
+	changed[len(changed)/2] = "changed"
+	got, err := diffLines(big, changed)
+	if err != nil || len(got) != 2 {
+		t.Errorf("expected a single changed line, got %d lines, %v", len(got), err)
+	}
+	if _, err := diffLines(big, nil); err == nil {
+		t.Error("expected an error for results differing in too many lines")
+	}
+}
+
+func TestResultMemorySessions(t *testing.T) {
+	m := NewResultMemory(5)
+	for i := range maxSessions {
+		m.store(fmt.Sprint("session", i), "echo", "text")
+	}
+	// Session 0 used again, session 1 is the least recently used.
+	if _, err := m.get("session0", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	m.store("new", "echo", "text")
+	if len(m.sessions) != maxSessions {
+		t.Errorf("expected %d sessions, got %d", maxSessions, len(m.sessions))
+	}
+	if _, err := m.get("session1", "r1"); err == nil {
+		t.Error("expected the least recently used session to be dropped")
+	}
+	for _, session := range []string{"session0", "session2", "new"} {
+		if _, err := m.get(session, "r1"); err != nil {
+			t.Errorf("expected the results of %s to be kept: %v", session, err)
+		}
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if a[0] == b[0] {
+		return 1 + lcsLength(a[1:], b[1:])
+	}
+	return max(lcsLength(a[1:], b), lcsLength(a, b[1:]))
+}
+
+// isSubsequence reports whether sub is a subsequence of s.
+func isSubsequence(sub, s []string) bool {
+	for _, line := range s {
+		if len(sub) > 0 && sub[0] == line {
+			sub = sub[1:]
+		}
+	}
+	return len(sub) == 0
+}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -9,26 +10,121 @@ import (
 	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
 )
 
-// RegisterTools adds all GnuCash MCP tools to the server.
-func RegisterTools(s *server.MCPServer, svc *gnucash.Service) {
-	registerListAccounts(s, svc)
-	registerGetBalance(s, svc)
-	registerGetTransactions(s, svc)
-	registerSpendingByCategory(s, svc)
-	registerIncomeVsExpenses(s, svc)
-	registerSearchTransactions(s, svc)
+// accountNameDescription documents how account_name parameters are resolved.
+const accountNameDescription = "Account name (case-insensitive, partial match supported, typos tolerated), " +
+	"account GUID, or colon path whose trailing segments identify the account (e.g. \"Auto:Insurance\", \"Expenses:Gro\")"
+
+// withHints sets the hints clients use to decide which tools they may call
+// without asking the user: readOnly for tools that change nothing,
+// destructive for tools that modify or remove existing data, idempotent when
+// repeating a call has no further effect. No tool reaches outside the books
+// and the server's own files, while mcp.NewTool defaults to a destructive
+// open-world tool.
+func withHints(readOnly, destructive, idempotent bool) mcp.ToolOption {
+	return mcp.WithToolAnnotation(mcp.ToolAnnotation{
+		ReadOnlyHint:    mcp.ToBoolPtr(readOnly),
+		DestructiveHint: mcp.ToBoolPtr(destructive),
+		IdempotentHint:  mcp.ToBoolPtr(idempotent),
+		OpenWorldHint:   mcp.ToBoolPtr(false),
+	})
+}
+
+// readOnlyHints marks a tool that only reads.
+func readOnlyHints() mcp.ToolOption {
+	return withHints(true, false, true)
+}
+
+// RegisterTools adds the GnuCash MCP tools that do not modify the books to
+// the server.
+func RegisterTools(s *server.MCPServer, books *Books) {
+	registerListBooks(s, books)
+	registerOpenBook(s, books)
+	registerListAccounts(s, books)
+	registerGetBalance(s, books)
+	registerGetAccount(s, books)
+	registerGetTransactions(s, books)
+	registerSpendingByCategory(s, books)
+	registerIncomeVsExpenses(s, books)
+	registerSpendingSeasonality(s, books)
+	registerPeriodDiff(s, books)
+	registerSearchTransactions(s, books)
+	registerGetTransaction(s, books)
+	registerChartHistory(s, books)
+	registerPortfolio(s, books)
+	registerPriceHistory(s, books)
+	registerExchangeRates(s, books)
+	registerCurrencyGains(s, books)
+	registerWashSales(s, books)
+	registerGainsSummary(s, books)
+	registerPortfolioVsBenchmark(s, books)
+	registerPortfolioPerformance(s, books)
+	registerIdleCash(s, books)
+	registerWaterfall(s, books)
+	registerCounterpartySummary(s, books)
+	registerLoanSummary(s, books)
+	registerInterestAndFees(s, books)
+	registerCreditCardSummary(s, books)
+	registerBurnRate(s, books)
+	registerSimulate(s, books)
+	registerGoalProgress(s, books)
+	registerSetEnvelope(s, books)
+	registerEnvelopeStatus(s, books)
+	registerBudgetAlerts(s, books)
+	registerMonthClose(s, books)
+	registerUncategorizedTransactions(s, books)
+	registerEquityStatement(s, books)
+	registerChartReport(s, books)
+	registerMoneyFlowDiagram(s, books)
+	registerTaxLiability(s, books)
+	registerTaxReport(s, books)
+	registerVendorPayments(s, books)
+	registerCustomerStatement(s, books)
+	registerJobReport(s, books)
+	registerExportReportBundle(s, books)
+	registerQuerySQL(s, books)
+	registerSuggestCategory(s, books)
+	registerExportBeancount(s, books)
+	registerExportLedger(s, books)
+	registerExportAccount(s, books)
+	registerExportJSON(s, books)
+}
+
+// RegisterWriteTools adds the tools that modify the books, for write mode.
+func RegisterWriteTools(s *server.MCPServer, books *Books) {
+	registerAddTransaction(s, books)
+	registerAddSplitTransaction(s, books)
+	registerVoidTransaction(s, books)
+	registerCreateAccount(s, books)
+	registerRenameAccount(s, books)
+	registerReconcileSplits(s, books)
+	registerImportCSV(s, books)
+	registerImportOFX(s, books)
+	registerImportQIF(s, books)
+	registerUndoLastChange(s, books)
 }
 
-func registerListAccounts(s *server.MCPServer, svc *gnucash.Service) {
+func registerListAccounts(s *server.MCPServer, books *Books) {
 	tool := mcp.NewTool("list_accounts",
-		mcp.WithDescription("List all accounts with their hierarchy and types. Returns a tree structure of the chart of accounts."),
+		mcp.WithDescription("List all accounts with their hierarchy and types. Returns an indented tree of the chart of accounts with each account's balance and subtotals for parent accounts."),
+		readOnlyHints(),
 		mcp.WithString("account_type",
-			mcp.Description("Filter by account type: ASSET, BANK, CASH, CREDIT, EQUITY, EXPENSE, INCOME, LIABILITY"),
+			mcp.Description("Filter by account type: ASSET, BANK, CASH, CREDIT, EQUITY, EXPENSE, INCOME, LIABILITY, STOCK, MUTUAL, RECEIVABLE, PAYABLE. Case-insensitive; common synonyms and translations are accepted (e.g. chequing, debt, dépenses)."),
 		),
+		mcp.WithNumber("max_depth",
+			mcp.Description("Maximum tree depth to display (default: unlimited). Subtotals still include deeper accounts."),
+		),
+		withGUIDs(),
+		withLocale(),
 	)
-	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = callGUIDs(svc, request)
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		accountType := mcp.ParseString(request, "account_type", "")
-		result, err := svc.ListAccounts(ctx, accountType)
+		maxDepth := mcp.ParseInt(request, "max_depth", 0)
+		result, err := svc.ListAccounts(ctx, accountType, maxDepth)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -36,24 +132,61 @@ func registerListAccounts(s *server.MCPServer, svc *gnucash.Service) {
 	})
 }
 
-func registerGetBalance(s *server.MCPServer, svc *gnucash.Service) {
+func registerGetBalance(s *server.MCPServer, books *Books) {
 	tool := mcp.NewTool("get_balance",
 		mcp.WithDescription("Get the current balance for a specific account. Returns the sum of all transactions up to the given date."),
+		readOnlyHints(),
 		mcp.WithString("account_name",
 			mcp.Required(),
-			mcp.Description("Account name (case-insensitive, partial match supported)"),
+			mcp.Description(accountNameDescription),
 		),
 		mcp.WithString("date",
 			mcp.Description("Balance as of this date (YYYY-MM-DD). Defaults to today."),
 		),
+		mcp.WithBoolean("include_children",
+			mcp.Description("Include all sub-accounts in the balance. Defaults to true for placeholder and parent accounts, false for leaf accounts."),
+		),
+		withLocale(),
 	)
-	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		name, err := request.RequireString("account_name")
 		if err != nil {
 			return mcp.NewToolResultError("account_name is required"), nil
 		}
 		date := mcp.ParseString(request, "date", "")
-		result, err := svc.GetBalance(ctx, name, date)
+		includeChildren := optionalBool(request, "include_children")
+		result, err := svc.GetBalance(ctx, name, date, includeChildren)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerGetAccount(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("get_account",
+		mcp.WithDescription("Get the details of one account: GUID, full path, type, commodity, code, description, notes, hidden and placeholder flags, parent and children, opening date (first transaction), number of transactions and current balance."),
+		readOnlyHints(),
+		mcp.WithString("account_name",
+			mcp.Required(),
+			mcp.Description(accountNameDescription),
+		),
+		withLocale(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		name, err := request.RequireString("account_name")
+		if err != nil {
+			return mcp.NewToolResultError("account_name is required"), nil
+		}
+		result, err := svc.GetAccount(ctx, name)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -61,12 +194,13 @@ func registerGetBalance(s *server.MCPServer, svc *gnucash.Service) {
 	})
 }
 
-func registerGetTransactions(s *server.MCPServer, svc *gnucash.Service) {
+func registerGetTransactions(s *server.MCPServer, books *Books) {
 	tool := mcp.NewTool("get_transactions",
-		mcp.WithDescription("Retrieve transactions for an account within a date range. Shows date, amount, description, and counterpart account for each transaction."),
+		mcp.WithDescription("Retrieve transactions for an account within a date range. Shows date, amount and currency, description, and counterpart accounts for each transaction, with the amount of each counterpart when the transaction is split across several. Stock and fund registers show the shares moved as well as their value."),
+		readOnlyHints(),
 		mcp.WithString("account_name",
 			mcp.Required(),
-			mcp.Description("Account name (case-insensitive, partial match supported)"),
+			mcp.Description(accountNameDescription),
 		),
 		mcp.WithString("start_date",
 			mcp.Description("Start date (YYYY-MM-DD)"),
@@ -77,8 +211,26 @@ func registerGetTransactions(s *server.MCPServer, svc *gnucash.Service) {
 		mcp.WithNumber("limit",
 			mcp.Description("Maximum number of transactions to return (default: 50)"),
 		),
+		withSort(),
+		withCursor(),
+		withFormat(),
+		withAllHistory(),
+		withGUIDs(),
+		mcp.WithBoolean("subtotals",
+			mcp.Description("Add a subtotal after each month (when sorted by date) and a grand total, to make long registers easier to digest"),
+		),
+		withLocale(),
 	)
-	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = allHistory(svc, request)
+		svc = callGUIDs(svc, request)
+		if mcp.ParseBoolean(request, "subtotals", false) {
+			svc = svc.With(gnucash.WithSubtotals())
+		}
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		name, err := request.RequireString("account_name")
 		if err != nil {
 			return mcp.NewToolResultError("account_name is required"), nil
@@ -86,7 +238,11 @@ func registerGetTransactions(s *server.MCPServer, svc *gnucash.Service) {
 		startDate := mcp.ParseString(request, "start_date", "")
 		endDate := mcp.ParseString(request, "end_date", "")
 		limit := mcp.ParseInt(request, "limit", 50)
-		result, err := svc.GetTransactions(ctx, name, startDate, endDate, limit)
+		sortBy := mcp.ParseString(request, "sort_by", "")
+		order := mcp.ParseString(request, "order", "")
+		cursor := mcp.ParseString(request, "cursor", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.GetTransactions(ctx, name, startDate, endDate, limit, sortBy, order, cursor, format)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -94,9 +250,10 @@ func registerGetTransactions(s *server.MCPServer, svc *gnucash.Service) {
 	})
 }
 
-func registerSpendingByCategory(s *server.MCPServer, svc *gnucash.Service) {
+func registerSpendingByCategory(s *server.MCPServer, books *Books) {
 	tool := mcp.NewTool("spending_by_category",
 		mcp.WithDescription("Aggregate expenses by category (expense accounts). Shows total amount and transaction count per category, sorted by highest spending."),
+		readOnlyHints(),
 		mcp.WithString("start_date",
 			mcp.Description("Start date (YYYY-MM-DD). Defaults to start of current month."),
 		),
@@ -106,12 +263,31 @@ func registerSpendingByCategory(s *server.MCPServer, svc *gnucash.Service) {
 		mcp.WithString("parent_account",
 			mcp.Description("Filter by parent expense account name"),
 		),
+		withGrouping(),
+		mcp.WithString("convert_to",
+			mcp.Description("ISO currency code (e.g. EUR) to add a grand total converted at the book's latest exchange rates on or before end_date. Categories in other currencies are always listed per currency"),
+		),
+		withExpressions("total, count"),
+		withFormat(),
+		withAllHistory(),
+		withClosingEntries(),
+		withLocale(),
 	)
-	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = allHistory(svc, request)
+		svc = closingEntries(svc, request)
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		startDate := mcp.ParseString(request, "start_date", "")
 		endDate := mcp.ParseString(request, "end_date", "")
 		parentAccount := mcp.ParseString(request, "parent_account", "")
-		result, err := svc.SpendingByCategory(ctx, startDate, endDate, parentAccount)
+		grouping := mcp.ParseString(request, "grouping", "")
+		convertTo := mcp.ParseString(request, "convert_to", "")
+		expressions := mcp.ParseString(request, "expressions", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.SpendingByCategory(ctx, startDate, endDate, parentAccount, grouping, convertTo, expressions, format)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -119,16 +295,120 @@ func registerSpendingByCategory(s *server.MCPServer, svc *gnucash.Service) {
 	})
 }
 
-func registerIncomeVsExpenses(s *server.MCPServer, svc *gnucash.Service) {
+func registerIncomeVsExpenses(s *server.MCPServer, books *Books) {
 	tool := mcp.NewTool("income_vs_expenses",
 		mcp.WithDescription("Monthly comparison of income and expenses. Shows per-month breakdown with income total, expense total, and net amount."),
+		readOnlyHints(),
 		mcp.WithNumber("months",
 			mcp.Description("Number of months to include (default: 6)"),
 		),
+		withExpressions("income, expenses, net"),
+		withFormat(),
+		withAllHistory(),
+		withClosingEntries(),
+		withLocale(),
+		withInflation(),
 	)
-	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = allHistory(svc, request)
+		svc = closingEntries(svc, request)
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if svc, err = callInflation(svc, request); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 		months := mcp.ParseInt(request, "months", 6)
-		result, err := svc.IncomeVsExpenses(ctx, months)
+		expressions := mcp.ParseString(request, "expressions", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.IncomeVsExpenses(ctx, months, expressions, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerSpendingSeasonality(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("spending_seasonality",
+		mcp.WithDescription("Monthly totals of a spending (or income) category with 3, 6 and 12-month rolling averages, the average of each calendar month over the category's history with its seasonal index, one-off spikes, and whether the recent trend is rising or falling."),
+		readOnlyHints(),
+		mcp.WithString("category",
+			mcp.Required(),
+			mcp.Description("Expense or income account, with its sub-accounts (name, GUID or colon path)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End of the last month shown (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithNumber("months",
+			mcp.Description("Number of months shown (default: 12); the averages use the whole history"),
+		),
+		withFormat(),
+		withClosingEntries(),
+		withInflation(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		category, err := request.RequireString("category")
+		if err != nil {
+			return mcp.NewToolResultError("category is required"), nil
+		}
+		svc, err = callInflation(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		endDate := mcp.ParseString(request, "end_date", "")
+		months := mcp.ParseInt(request, "months", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.SpendingSeasonality(ctx, category, endDate, months, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerPeriodDiff(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("period_diff",
+		mcp.WithDescription("Compare any two date ranges: income, expenses and net in each, and the income and expense categories that changed the most between them. Not limited to year-over-year; e.g. this quarter against the same quarter of last year, or a holiday month against a normal one."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Required(),
+			mcp.Description("Start of the period to compare (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End of the period to compare (YYYY-MM-DD); required unless start_date is a preset"),
+		),
+		mcp.WithString("compare_start_date",
+			mcp.Required(),
+			mcp.Description("Start of the base period it is compared with (YYYY-MM-DD)"),
+		),
+		mcp.WithString("compare_end_date",
+			mcp.Description("End of the base period (YYYY-MM-DD); required unless compare_start_date is a preset"),
+		),
+		withGrouping(),
+		mcp.WithNumber("limit",
+			mcp.Description("Number of categories with the largest changes listed (default: 10)"),
+		),
+		withFormat(),
+		withClosingEntries(),
+		withInflation(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		svc, err := callInflation(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		compareStartDate := mcp.ParseString(request, "compare_start_date", "")
+		compareEndDate := mcp.ParseString(request, "compare_end_date", "")
+		grouping := mcp.ParseString(request, "grouping", "")
+		limit := mcp.ParseInt(request, "limit", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.PeriodDiff(ctx, compareStartDate, compareEndDate, startDate, endDate, grouping, limit, format)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -136,24 +416,1242 @@ func registerIncomeVsExpenses(s *server.MCPServer, svc *gnucash.Service) {
 	})
 }
 
-func registerSearchTransactions(s *server.MCPServer, svc *gnucash.Service) {
+func registerSearchTransactions(s *server.MCPServer, books *Books) {
 	tool := mcp.NewTool("search_transactions",
-		mcp.WithDescription("Full-text search in transaction descriptions and split memos. Returns matching transactions with all their splits."),
+		mcp.WithDescription("Search transactions combining any of: text in descriptions and split memos, account, date range, amount range and reconcile state. All given criteria must match. Returns matching transactions with all their splits."),
+		readOnlyHints(),
 		mcp.WithString("query",
-			mcp.Required(),
-			mcp.Description("Search term to match against transaction descriptions and memos"),
+			mcp.Description("Search term to match against transaction descriptions and memos. With the search index enabled, every word must start a word of the text, and sort_by relevance ranks the best matches first"),
+		),
+		mcp.WithString("account_name",
+			mcp.Description("Only transactions touching this account or its sub-accounts. "+accountNameDescription),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD)"),
+		),
+		mcp.WithNumber("min_amount",
+			mcp.Description("Only transactions of at least this amount (total of debit splits)"),
+		),
+		mcp.WithNumber("max_amount",
+			mcp.Description("Only transactions of at most this amount (total of debit splits)"),
+		),
+		mcp.WithString("reconcile_state",
+			mcp.Description("Only transactions with a split in this reconcile state (in account_name, if given)"),
+			mcp.Enum("unreconciled", "cleared", "reconciled", "frozen", "voided"),
 		),
 		mcp.WithNumber("limit",
 			mcp.Description("Maximum number of results (default: 20)"),
 		),
+		withSort(gnucash.SortByRelevance),
+		withCursor(),
+		withAllHistory(),
+		withGUIDs(),
+		withLocale(),
 	)
-	s.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		query, err := request.RequireString("query")
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = allHistory(svc, request)
+		svc = callGUIDs(svc, request)
+		svc, err := callLocale(svc, request)
 		if err != nil {
-			return mcp.NewToolResultError("query is required"), nil
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		filter := gnucash.SearchFilter{
+			Text:           mcp.ParseString(request, "query", ""),
+			Account:        mcp.ParseString(request, "account_name", ""),
+			StartDate:      mcp.ParseString(request, "start_date", ""),
+			EndDate:        mcp.ParseString(request, "end_date", ""),
+			MinAmount:      mcp.ParseFloat64(request, "min_amount", 0),
+			MaxAmount:      mcp.ParseFloat64(request, "max_amount", 0),
+			ReconcileState: mcp.ParseString(request, "reconcile_state", ""),
 		}
 		limit := mcp.ParseInt(request, "limit", 20)
-		result, err := svc.SearchTransactions(ctx, query, limit)
+		sortBy := mcp.ParseString(request, "sort_by", "")
+		order := mcp.ParseString(request, "order", "")
+		cursor := mcp.ParseString(request, "cursor", "")
+		result, err := svc.SearchTransactions(ctx, filter, limit, sortBy, order, cursor)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerGetTransaction(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("get_transaction",
+		mcp.WithDescription("Get one transaction by GUID with all its splits: date, entry time, number, description, currency, notes, document link, and for each split the account, memo, action, value, quantity and reconcile state."),
+		readOnlyHints(),
+		mcp.WithString("transaction_guid",
+			mcp.Required(),
+			mcp.Description("GUID of the transaction"),
+		),
+		withLocale(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		guid, err := request.RequireString("transaction_guid")
+		if err != nil {
+			return mcp.NewToolResultError("transaction_guid is required"), nil
+		}
+		result, err := svc.GetTransaction(ctx, guid)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerChartHistory(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("chart_history",
+		mcp.WithDescription("Report when accounts were added, removed, renamed, or re-parented, based on snapshots of the chart of accounts taken by this server. Useful to understand why old reports categorize things differently."),
+		readOnlyHints(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		result, err := svc.ChartHistory(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerPortfolio(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("portfolio",
+		mcp.WithDescription("List investment holdings (STOCK and MUTUAL accounts) with ticker symbol, security name, ISIN/CUSIP, share quantity, latest price and market value."),
+		readOnlyHints(),
+		mcp.WithString("symbol",
+			mcp.Description("Only show holdings of this ticker symbol or ISIN/CUSIP"),
+		),
+		mcp.WithString("date",
+			mcp.Description("Valuation date (YYYY-MM-DD). Defaults to today."),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		symbol := mcp.ParseString(request, "symbol", "")
+		date := mcp.ParseString(request, "date", "")
+		result, err := svc.Portfolio(ctx, symbol, date)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerPriceHistory(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("price_history",
+		mcp.WithDescription("List recorded prices of a security or currency from the price database, identified by ticker symbol or ISIN/CUSIP."),
+		readOnlyHints(),
+		mcp.WithString("symbol",
+			mcp.Required(),
+			mcp.Description("Ticker symbol, currency code or ISIN/CUSIP"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		symbol, err := request.RequireString("symbol")
+		if err != nil {
+			return mcp.NewToolResultError("symbol is required"), nil
+		}
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.PriceHistory(ctx, symbol, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerExchangeRates(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("exchange_rates",
+		mcp.WithDescription("Exchange rate history between two currencies from the price database, using prices recorded in either direction. Lists the recorded rates, or a rate per day, week or month carried forward or interpolated between them, and warns about stale rates and gaps."),
+		readOnlyHints(),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("ISO code of the currency priced, e.g. USD"),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("ISO code of the currency the rate is expressed in, e.g. EUR"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to the first recorded rate."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithString("interpolation",
+			mcp.Description("none (default) lists the recorded rates; previous carries the latest rate forward and linear interpolates between the surrounding rates, one rate per step"),
+			mcp.Enum(gnucash.InterpolateNone, gnucash.InterpolatePrevious, gnucash.InterpolateLinear),
+		),
+		mcp.WithString("step",
+			mcp.Description("Interval of an interpolated series: day, week or month (default: month, at month ends)"),
+			mcp.Enum(gnucash.StepDay, gnucash.StepWeek, gnucash.StepMonth),
+		),
+		mcp.WithNumber("max_age_days",
+			mcp.Description("Flag rates based on a price older than this many days, and longer gaps between recorded rates (default: 30)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		from, err := request.RequireString("from")
+		if err != nil {
+			return mcp.NewToolResultError("from is required"), nil
+		}
+		to, err := request.RequireString("to")
+		if err != nil {
+			return mcp.NewToolResultError("to is required"), nil
+		}
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		interpolation := mcp.ParseString(request, "interpolation", "")
+		step := mcp.ParseString(request, "step", "")
+		maxAge := mcp.ParseInt(request, "max_age_days", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.ExchangeRates(ctx, from, to, startDate, endDate, interpolation, step, maxAge, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerCurrencyGains(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("currency_gains",
+		mcp.WithDescription("Gains and losses on foreign currencies in books that use trading accounts: per currency, the amount held, what it cost in the book currency, what it is worth at the latest exchange rate, and the gain, realized gains included."),
+		readOnlyHints(),
+		mcp.WithString("date",
+			mcp.Description("Date to report at (YYYY-MM-DD). Defaults to today."),
+		),
+		withFormat(),
+		withLocale(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc, err := callLocale(svc, request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		date := mcp.ParseString(request, "date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.CurrencyGains(ctx, date, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerWashSales(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("wash_sales",
+		mcp.WithDescription("Flag sales of securities at a loss that have purchases of the same security within 30 days before or after, across all accounts (wash-sale candidates, for tax awareness)."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Only consider sales from this date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("Only consider sales up to this date (YYYY-MM-DD)"),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.WashSales(ctx, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerGainsSummary(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("gains_summary",
+		mcp.WithDescription("Split investment performance into realized gains, on the sales of a period (lot cost when the sale is from a lot, average cost otherwise), and unrealized gains, the current worth of the holdings at the latest price minus their cost basis, per security and in total."),
+		readOnlyHints(),
+		mcp.WithString("symbol",
+			mcp.Description("Only report this ticker symbol or ISIN/CUSIP"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Only count the gains of sales from this date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End of the period and valuation date (YYYY-MM-DD). Defaults to today."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		symbol := mcp.ParseString(request, "symbol", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.GainsSummary(ctx, symbol, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerPortfolioVsBenchmark(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("portfolio_vs_benchmark",
+		mcp.WithDescription("Compare the money-weighted return of all investment holdings over a period against a benchmark, either a security from the book's price database or a CSV price series. Also shows what the same purchases and sales would have yielded in the benchmark."),
+		readOnlyHints(),
+		mcp.WithString("benchmark",
+			mcp.Description("Benchmark ticker symbol or ISIN from the book's price database"),
+		),
+		mcp.WithString("benchmark_csv",
+			mcp.Description("Benchmark prices as CSV lines 'YYYY-MM-DD,price' (used instead of benchmark)"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to one year ago."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		benchmark := mcp.ParseString(request, "benchmark", "")
+		benchmarkCSV := mcp.ParseString(request, "benchmark_csv", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.PortfolioVsBenchmark(ctx, benchmark, benchmarkCSV, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerPortfolioPerformance(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("portfolio_performance",
+		mcp.WithDescription("Money-weighted (IRR) and time-weighted (TWR) returns of an investment account and its sub-accounts over a period, from the cash moved in and out of them and their value at the prices of the price table. Dividends and fees count in the return, not as cash flows."),
+		readOnlyHints(),
+		mcp.WithString("account",
+			mcp.Description("Investment account or parent account (full path or name). Defaults to all STOCK and MUTUAL accounts."),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to one year ago."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account := mcp.ParseString(request, "account", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.PortfolioPerformance(ctx, account, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerIdleCash(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("idle_cash",
+		mcp.WithDescription("Report cash sitting in bank and cash accounts above a buffer for longer than a number of days, and the interest it could have earned at a given annual rate."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to one year ago."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithNumber("buffer",
+			mcp.Description("Cash buffer to keep in each account; only the excess counts as idle (default: 1000)"),
+		),
+		mcp.WithNumber("min_days",
+			mcp.Description("Only count stretches above the buffer longer than this many days (default: 30)"),
+		),
+		mcp.WithNumber("rate",
+			mcp.Description("Annual interest rate in percent used to value idle cash (default: 3)"),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		buffer := mcp.ParseFloat64(request, "buffer", 1000)
+		minDays := mcp.ParseInt(request, "min_days", 30)
+		rate := mcp.ParseFloat64(request, "rate", 3)
+		result, err := svc.IdleCash(ctx, startDate, endDate, buffer, minDays, rate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerWaterfall(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("waterfall",
+		mcp.WithDescription("Net cash-flow waterfall for a period: income, then each major expense group, ending at net. Returns ordered steps with running totals as structured JSON, plus a text rendering, for a budget waterfall chart."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to first day of current month."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithNumber("max_groups",
+			mcp.Description("Maximum number of expense steps; smaller groups are folded into 'Other' (default: 8)"),
+		),
+		withGrouping(),
+		mcp.WithOutputSchema[gnucash.Waterfall](),
+		withAllHistory(),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = allHistory(svc, request)
+		svc = closingEntries(svc, request)
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		grouping := mcp.ParseString(request, "grouping", "")
+		maxGroups := mcp.ParseInt(request, "max_groups", 8)
+		w, err := svc.Waterfall(ctx, startDate, endDate, grouping, maxGroups)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructured(w, w.String()), nil
+	})
+}
+
+func registerCounterpartySummary(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("counterparty_summary",
+		mcp.WithDescription("Where the money of an account comes from and goes to: groups the inflows and outflows of an asset account (e.g. checking) over a period by the account on the other side of each transaction, such as salary, groceries, rent or transfers to savings."),
+		readOnlyHints(),
+		mcp.WithString("account",
+			mcp.Required(),
+			mcp.Description(accountNameDescription),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to first day of current month."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of counterparties listed, by volume; the others are folded into 'Other' (default: 20)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account, err := request.RequireString("account")
+		if err != nil {
+			return mcp.NewToolResultError("account is required"), nil
+		}
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		limit := mcp.ParseInt(request, "limit", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.CounterpartySummary(ctx, account, startDate, endDate, limit, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerLoanSummary(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("loan_summary",
+		mcp.WithDescription("Summarize loans and mortgages (LIABILITY accounts): principal borrowed and paid, interest paid from the expense splits of the payments, remaining balance, and a payoff date projected from the pace of the latest payments."),
+		readOnlyHints(),
+		mcp.WithString("account",
+			mcp.Description("Loan account or parent account (full path or name). Defaults to all LIABILITY accounts."),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Only count what was borrowed and paid from this date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End of the period and date of the balance (YYYY-MM-DD). Defaults to today."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account := mcp.ParseString(request, "account", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.LoanSummary(ctx, account, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerInterestAndFees(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("interest_and_fees",
+		mcp.WithDescription("What debt and banking cost: interest and fee expenses per year and per institution (the bank, card or loan account they were paid from or charged to). Interest and fee accounts are found by their names (interest, fees, bank charges, frais, Zinsen...) unless given."),
+		readOnlyHints(),
+		mcp.WithString("accounts",
+			mcp.Description("Comma-separated expense accounts to report instead of those found by name, with their sub-accounts"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		accounts := mcp.ParseString(request, "accounts", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.InterestAndFees(ctx, accounts, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerCreditCardSummary(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("credit_card_summary",
+		mcp.WithDescription("Summarize credit cards (CREDIT accounts): balance owed, utilization of a credit limit, spending, payments and credits over the last statement period and since, and the payoff of the balance at a given monthly payment and APR."),
+		readOnlyHints(),
+		mcp.WithString("account",
+			mcp.Description("Credit card account (full path or name). Defaults to all CREDIT accounts."),
+		),
+		mcp.WithString("date",
+			mcp.Description("Date of the summary (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithNumber("statement_day",
+			mcp.Description("Day of the month statements close on (default: the last day of the month)"),
+		),
+		mcp.WithNumber("credit_limit",
+			mcp.Description("Credit limit, to report the utilization of the balance"),
+		),
+		mcp.WithNumber("monthly_payment",
+			mcp.Description("Monthly payment to project the payoff of the balance with"),
+		),
+		mcp.WithNumber("apr",
+			mcp.Description("Yearly interest rate in percent for the payoff projection (default: 0)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account := mcp.ParseString(request, "account", "")
+		date := mcp.ParseString(request, "date", "")
+		statementDay := mcp.ParseInt(request, "statement_day", 0)
+		creditLimit := mcp.ParseFloat64(request, "credit_limit", 0)
+		monthlyPayment := mcp.ParseFloat64(request, "monthly_payment", 0)
+		apr := mcp.ParseFloat64(request, "apr", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.CreditCardSummary(ctx, account, date, statementDay, creditLimit, monthlyPayment, apr, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerBurnRate(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("burn_rate",
+		mcp.WithDescription("For business books: average monthly net cash outflow of BANK and CASH accounts over the trailing complete months, with the inflows and outflows of each month, and the runway the current cash gives at that pace. Transfers between cash accounts are left out."),
+		readOnlyHints(),
+		mcp.WithString("account",
+			mcp.Description("Parent account of the cash accounts to include (full path or name). Defaults to all BANK and CASH accounts."),
+		),
+		mcp.WithString("date",
+			mcp.Description("Date of the cash balance (YYYY-MM-DD); the window ends with the last complete month. Defaults to today."),
+		),
+		mcp.WithNumber("months",
+			mcp.Description("Number of trailing months to average (default: 6)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account := mcp.ParseString(request, "account", "")
+		date := mcp.ParseString(request, "date", "")
+		months := mcp.ParseInt(request, "months", 6)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.BurnRate(ctx, account, date, months, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerSimulate(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("simulate",
+		mcp.WithDescription("What-if simulation: recompute the average monthly income, expenses and net after hypothetical changes, such as removing a subscription, adding a monthly expense or raising income by a percentage, and project cash balances over the coming months with and without them. The baseline is the average of the trailing complete months."),
+		readOnlyHints(),
+		mcp.WithString("changes",
+			mcp.Required(),
+			mcp.Description("Changes separated by ';': 'remove <account>', '<target> <amount>' or 'add <amount> <target>', where the target is income, expenses or an income or expense account and the amount a monthly amount or a percentage. Example: remove Netflix; add 300/month expense; income +10%; Groceries -20%"),
+		),
+		mcp.WithString("date",
+			mcp.Description("Date of the starting cash balance (YYYY-MM-DD); the baseline ends with the last complete month. Defaults to today."),
+		),
+		mcp.WithNumber("months",
+			mcp.Description("Number of trailing months averaged for the baseline (default: 6)"),
+		),
+		mcp.WithNumber("horizon",
+			mcp.Description("Number of months projected (default: 12)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		changes, err := request.RequireString("changes")
+		if err != nil {
+			return mcp.NewToolResultError("changes is required"), nil
+		}
+		date := mcp.ParseString(request, "date", "")
+		months := mcp.ParseInt(request, "months", 0)
+		horizon := mcp.ParseInt(request, "horizon", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.Simulate(ctx, changes, date, months, horizon, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerGoalProgress(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("goal_progress",
+		mcp.WithDescription("Progress of the savings goals configured on the server (GNUCASH_GOALS): the balance of each goal's accounts against its target amount, what is left to save, the monthly contribution needed to reach it by its target date, and whether the saving pace of the last three months keeps up."),
+		readOnlyHints(),
+		mcp.WithString("goal",
+			mcp.Description("Name of the goal to report on. Defaults to all goals."),
+		),
+		mcp.WithString("date",
+			mcp.Description("Date of the balances (YYYY-MM-DD). Defaults to today."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		goal := mcp.ParseString(request, "goal", "")
+		date := mcp.ParseString(request, "date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.GoalProgress(ctx, goal, date, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerSetEnvelope(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("set_envelope",
+		mcp.WithDescription("Envelope budgeting: allocate a monthly amount to an expense account (with its sub-accounts) from a month on, or remove its envelope. Envelopes are kept in the server's envelope store (GNUCASH_ENVELOPE_DB); the book is not changed."),
+		withHints(false, true, true),
+		mcp.WithString("account",
+			mcp.Required(),
+			mcp.Description("Expense account of the envelope. "+accountNameDescription),
+		),
+		mcp.WithNumber("amount",
+			mcp.Description("Amount allocated each month; required unless remove is set"),
+		),
+		mcp.WithString("month",
+			mcp.Description("First month the amount applies to (YYYY-MM). Defaults to the current month; earlier months keep their amounts."),
+		),
+		mcp.WithBoolean("rollover",
+			mcp.Description("Carry what is left of each month, or overspent, over to the next month (default: false)"),
+		),
+		mcp.WithBoolean("remove",
+			mcp.Description("Remove the envelope of the account instead"),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account, err := request.RequireString("account")
+		if err != nil {
+			return mcp.NewToolResultError("account is required"), nil
+		}
+		remove := mcp.ParseBoolean(request, "remove", false)
+		amount, err := request.RequireFloat("amount")
+		if err != nil && !remove {
+			return mcp.NewToolResultError("amount is required"), nil
+		}
+		month := mcp.ParseString(request, "month", "")
+		rollover := mcp.ParseBoolean(request, "rollover", false)
+		result, err := svc.SetEnvelope(ctx, account, amount, month, rollover, remove)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerEnvelopeStatus(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("envelope_status",
+		mcp.WithDescription("Envelope budgeting: for each envelope set with set_envelope, the amount allocated for a month, what rolled over from the months before, what the account actually spent in the book, and what is left, flagging overspent envelopes."),
+		readOnlyHints(),
+		mcp.WithString("month",
+			mcp.Description("Month to report (YYYY-MM). Defaults to the current month."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		month := mcp.ParseString(request, "month", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.EnvelopeStatus(ctx, month, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerBudgetAlerts(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("budget_alerts",
+		mcp.WithDescription("How am I doing this month? Compares spending so far in the current budget period with the amounts of a GnuCash budget, prorated to the days elapsed, and returns only the expense accounts over budget or heading over it, with their projected spending for the whole period."),
+		readOnlyHints(),
+		mcp.WithString("budget",
+			mcp.Description("Name of the GnuCash budget. Defaults to the first budget, by name, covering the date."),
+		),
+		mcp.WithString("date",
+			mcp.Description("Date to compare at (YYYY-MM-DD); the period is the budget period containing it. Defaults to today."),
+		),
+		withFormat(),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		budget := mcp.ParseString(request, "budget", "")
+		date := mcp.ParseString(request, "date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.BudgetAlerts(ctx, budget, date, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerMonthClose(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("month_close",
+		mcp.WithDescription("Month-end close checklist: runs a bundle of checks for a month and reports each as passed, needing attention or skipped, with the items to look at. Checks: unbalanced transactions, unreconciled bank and credit card splits, transactions left in Imbalance or Orphan accounts, scheduled transactions due but not created, and expense accounts over their GnuCash budget."),
+		readOnlyHints(),
+		mcp.WithString("month",
+			mcp.Description("Month to close (YYYY-MM). Defaults to the previous month."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		month := mcp.ParseString(request, "month", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.MonthClose(ctx, month, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerUncategorizedTransactions(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("uncategorized_transactions",
+		mcp.WithDescription("List the transactions booked to Imbalance-* or Orphan-* accounts, typically left there by sloppy imports, grouped by month with the count and total of each month and the other accounts of each transaction, so they can be recategorized."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to the beginning of the book"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to the end of the book"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.UncategorizedTransactions(ctx, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerEquityStatement(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("equity_statement",
+		mcp.WithDescription("Statement of changes in equity between two dates: equity at the start, opening balances entered (GnuCash's Opening Balances account), capital contributions and draws on the other equity accounts, net income moving retained earnings, and equity at the end, per equity account."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to January 1 of the end date's year"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today"),
+		),
+		withFormat(),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.EquityStatement(ctx, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerChartReport(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("chart_report",
+		mcp.WithDescription("Vega-Lite (v5) JSON spec, with its data inline, charting a report for clients that can render charts: 'trend' for monthly income, expenses and net, 'categories' for expenses by top-level category, 'net_worth' for assets, liabilities and net worth at each month end. Render the returned spec as a chart."),
+		readOnlyHints(),
+		mcp.WithString("report",
+			mcp.Required(),
+			mcp.Description("Report to chart: trend, categories or net_worth"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to the start of the month a year before the end date"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today"),
+		),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		report, err := request.RequireString("report")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		result, err := svc.ChartSpec(ctx, report, startDate, endDate)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerMoneyFlowDiagram(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("money_flow_diagram",
+		mcp.WithDescription("Mermaid diagram, in a Markdown code block, of the money that flowed between accounts over a period (e.g. Income -> Assets -> Expenses), accounts grouped to their top levels. Show the returned Markdown as is for the client to render it."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to start of current month"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("Account levels to group by (default: 1, the top-level accounts; 2 for e.g. Expenses:Groceries)"),
+		),
+		mcp.WithString("style",
+			mcp.Description("Diagram: sankey (default) or flowchart, for clients whose Mermaid does not draw sankeys"),
+		),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		depth := mcp.ParseInt(request, "depth", 1)
+		style := mcp.ParseString(request, "style", "")
+		result, err := svc.MoneyFlowDiagram(ctx, startDate, endDate, depth, style)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerTaxLiability(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("tax_liability",
+		mcp.WithDescription("Sales tax / VAT / GST liability per filing period, to prepare returns: output tax collected on sales, input tax paid on purchases, the net due, and the payments to the tax authority. Tax accounts are those of the book's business tax tables unless given."),
+		readOnlyHints(),
+		mcp.WithString("accounts",
+			mcp.Description("Comma-separated tax accounts, with their sub-accounts. Defaults to the accounts of the book's tax tables."),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithString("period",
+			mcp.Description("Filing period (default: quarter)"),
+			mcp.Enum(gnucash.PeriodMonth, gnucash.PeriodQuarter, gnucash.PeriodYear),
+		),
+		withFormat(),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		accounts := mcp.ParseString(request, "accounts", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		period := mcp.ParseString(request, "period", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.TaxLiability(ctx, accounts, startDate, endDate, period, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerTaxReport(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("tax_report",
+		mcp.WithDescription("Annual summary of the accounts marked tax-related in GnuCash, per tax code (TXF code), like GnuCash's Tax Schedule Report: each account's total for the tax year and each code's total."),
+		readOnlyHints(),
+		mcp.WithNumber("year",
+			mcp.Description("Tax year. Defaults to last year."),
+		),
+		withFormat(),
+		withClosingEntries(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = closingEntries(svc, request)
+		year := mcp.ParseInt(request, "year", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.TaxReport(ctx, year, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerVendorPayments(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("vendor_payments",
+		mcp.WithDescription("Total payments to each vendor per calendar year, for 1099 preparation: bill payments from the business tables (vendors, bills and A/P lots), or, in books without them, expenses paid grouped by transaction description. The part paid by credit card is shown apart."),
+		readOnlyHints(),
+		mcp.WithNumber("year",
+			mcp.Description("Calendar year. Defaults to every year."),
+		),
+		mcp.WithNumber("min_amount",
+			mcp.Description("Leave out vendors paid less than this in a year, e.g. the 1099-NEC reporting threshold"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		year := mcp.ParseInt(request, "year", 0)
+		minAmount := mcp.ParseFloat64(request, "min_amount", 0)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.VendorPayments(ctx, year, minAmount, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerCustomerStatement(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("customer_statement",
+		mcp.WithDescription("Per-customer activity over a date range from the A/R accounts: opening balance, invoices issued, payments received and outstanding balance. Customers come from the business tables (invoices, jobs and payment lots), else from transaction descriptions. A single matching customer gets every entry listed."),
+		readOnlyHints(),
+		mcp.WithString("customer",
+			mcp.Description("Only customers whose name contains this (case-insensitive)"),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD). Defaults to the beginning of the book."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		customer := mcp.ParseString(request, "customer", "")
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.CustomerStatement(ctx, customer, startDate, endDate, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerJobReport(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("job_report",
+		mcp.WithDescription("Per-job (project) profitability from the business tables: revenue of the job's invoices and expenses of the bills and vouchers of the job or billed to it, with profit and margin."),
+		readOnlyHints(),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD) of the invoices and bills posted. Defaults to the beginning of the book."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD). Defaults to today."),
+		),
+		mcp.WithBoolean("all",
+			mcp.Description("Include jobs without postings in the period (default: false)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		startDate := mcp.ParseString(request, "start_date", "")
+		endDate := mcp.ParseString(request, "end_date", "")
+		all := mcp.ParseBoolean(request, "all", false)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.JobReport(ctx, startDate, endDate, all, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerExportReportBundle(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("export_report_bundle",
+		mcp.WithDescription("Write an audit-ready report bundle to the server's export directory: the report as Markdown, CSV and JSON, the source transactions, and a manifest with the exact parameters and the book file's SHA-256. Requires GNUCASH_EXPORT_DIR."),
+		withHints(false, false, false),
+		mcp.WithString("report",
+			mcp.Required(),
+			mcp.Description("Report to export"),
+			mcp.Enum(gnucash.ReportSpendingByCategory, gnucash.ReportIncomeVsExpenses),
+		),
+		mcp.WithString("start_date",
+			mcp.Description("Start date (YYYY-MM-DD) for spending_by_category. Defaults to start of current month."),
+		),
+		mcp.WithString("end_date",
+			mcp.Description("End date (YYYY-MM-DD) for spending_by_category. Defaults to today."),
+		),
+		mcp.WithString("parent_account",
+			mcp.Description("Parent expense account for spending_by_category"),
+		),
+		withGrouping(),
+		mcp.WithNumber("months",
+			mcp.Description("Number of months for income_vs_expenses (default: 6)"),
+		),
+		withAllHistory(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = allHistory(svc, request)
+		report, err := request.RequireString("report")
+		if err != nil {
+			return mcp.NewToolResultError("report is required"), nil
+		}
+		result, err := svc.ExportReportBundle(ctx, gnucash.BundleRequest{
+			Report:        report,
+			StartDate:     mcp.ParseString(request, "start_date", ""),
+			EndDate:       mcp.ParseString(request, "end_date", ""),
+			ParentAccount: mcp.ParseString(request, "parent_account", ""),
+			Grouping:      mcp.ParseString(request, "grouping", ""),
+			Months:        mcp.ParseInt(request, "months", 0),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+func registerQuerySQL(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("query_sql",
+		mcp.WithDescription("Run a read-only SQL SELECT against the GnuCash SQLite schema (tables accounts, transactions, splits, commodities, prices, ...) for questions no other tool covers. "+
+			"Amounts are stored as value_num / value_denom. Only single SELECT or WITH statements are accepted; queries time out after 5 seconds. Requires GNUCASH_SQL=1."),
+		readOnlyHints(),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("A single SELECT statement"),
+		),
+		mcp.WithNumber("max_rows",
+			mcp.Description("Maximum number of rows to return (default: 100, max: 1000)"),
+		),
+		withFormat(),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		stmt, err := request.RequireString("sql")
+		if err != nil {
+			return mcp.NewToolResultError("sql is required"), nil
+		}
+		maxRows := mcp.ParseInt(request, "max_rows", 100)
+		format := mcp.ParseString(request, "format", "")
+		result, err := svc.QuerySQL(ctx, stmt, maxRows, format)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	})
+}
+
+// withGrouping declares the optional grouping parameter of spending reports.
+func withGrouping() mcp.ToolOption {
+	return mcp.WithString("grouping",
+		mcp.Description("Aggregate by expense account ('account', default) or by the super-categories configured in GNUCASH_CATEGORY_GROUPS ('group')"),
+		mcp.Enum(gnucash.GroupByAccount, gnucash.GroupByGroup),
+	)
+}
+
+// withAllHistory declares the parameter overriding the server's date horizon.
+func withAllHistory() mcp.ToolOption {
+	return mcp.WithBoolean("all_history",
+		mcp.Description("Include transactions older than the server's date horizon (GNUCASH_HORIZON_YEARS), if one is set"),
+	)
+}
+
+// allHistory lifts the date horizon for this call when all_history is set.
+func allHistory(svc *gnucash.Service, request mcp.CallToolRequest) *gnucash.Service {
+	if mcp.ParseBoolean(request, "all_history", false) {
+		return svc.With(gnucash.WithAllHistory())
+	}
+	return svc
+}
+
+// withClosingEntries declares the parameter including the closing
+// transactions of GnuCash's Close Book in aggregates.
+func withClosingEntries() mcp.ToolOption {
+	return mcp.WithBoolean("include_closing",
+		mcp.Description("Include the closing transactions of GnuCash's Close Book, which move income and expense balances to equity and are left out by default"),
+	)
+}
+
+// closingEntries includes closing transactions in the aggregates of this
+// call when include_closing is set.
+func closingEntries(svc *gnucash.Service, request mcp.CallToolRequest) *gnucash.Service {
+	if mcp.ParseBoolean(request, "include_closing", false) {
+		return svc.With(gnucash.WithClosingEntries())
+	}
+	return svc
+}
+
+// withGUIDs declares the parameter showing GUIDs in text listings.
+func withGUIDs() mcp.ToolOption {
+	return mcp.WithBoolean("show_guids",
+		mcp.Description("Show the GUIDs of accounts, transactions and splits in text output, to pass to get_account, get_transaction, void_transaction or reconcile_splits (csv and markdown always include them)"),
+	)
+}
+
+// callGUIDs shows GUIDs in the text output of this call when show_guids is
+// set.
+func callGUIDs(svc *gnucash.Service, request mcp.CallToolRequest) *gnucash.Service {
+	if mcp.ParseBoolean(request, "show_guids", false) {
+		return svc.With(gnucash.WithGUIDs())
+	}
+	return svc
+}
+
+// withInflation declares the parameters restating amounts in current money.
+func withInflation() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		mcp.WithBoolean("inflation_adjusted",
+			mcp.Description("Restate past amounts in current money with the server's price index (GNUCASH_CPI_FILE), for comparisons over many years"),
+		)(t)
+		mcp.WithString("inflation_series",
+			mcp.Description("Yearly inflation rates to restate past amounts with instead of the server's price index, as YEAR:PERCENT pairs, e.g. 2022:8.0,2023:4.1,2024:2.9; implies inflation_adjusted"),
+		)(t)
+	}
+}
+
+// callInflation restates the amounts of this call in current money when
+// inflation_adjusted or inflation_series is set.
+func callInflation(svc *gnucash.Service, request mcp.CallToolRequest) (*gnucash.Service, error) {
+	if series := mcp.ParseString(request, "inflation_series", ""); series != "" {
+		index, err := gnucash.ParseInflationSeries(series)
+		if err != nil {
+			return svc, err
+		}
+		return svc.With(gnucash.WithInflationAdjustment(index)), nil
+	}
+	if mcp.ParseBoolean(request, "inflation_adjusted", false) {
+		return svc.With(gnucash.WithInflationAdjustment(nil)), nil
+	}
+	return svc, nil
+}
+
+// withLocale declares the parameter overriding the server's locale.
+func withLocale() mcp.ToolOption {
+	return mcp.WithString("locale",
+		mcp.Description("Format amounts for this locale, e.g. fr-FR or en-US: digit grouping, decimal separator and currency symbol (default: the server's GNUCASH_LOCALE, or plain 1234.56 EUR)"),
+	)
+}
+
+// callLocale formats the amounts of this call for the locale parameter, if
+// set.
+func callLocale(svc *gnucash.Service, request mcp.CallToolRequest) (*gnucash.Service, error) {
+	name := mcp.ParseString(request, "locale", "")
+	if name == "" {
+		return svc, nil
+	}
+	tag, err := gnucash.ParseLocale(name)
+	if err != nil {
+		return svc, err
+	}
+	return svc.With(gnucash.WithLocale(tag)), nil
+}
+
+// withExpressions declares the optional computed-expressions parameter shared
+// by report tools. vars lists the row variables available to expressions.
+func withExpressions(vars string) mcp.ToolOption {
+	return mcp.WithString("expressions",
+		mcp.Description("Computed columns as 'name = expr', separated by ';' (disabled unless the server enables expressions). "+
+			"Row variables: "+vars+". Functions: iif(cond, a, b), abs, min, max, round. Example: savings_rate = net / income"),
+	)
+}
+
+// optionalBool returns nil when the boolean argument is absent so the service
+// can apply its own default.
+func optionalBool(request mcp.CallToolRequest, key string) *bool {
+	if _, ok := request.GetArguments()[key]; !ok {
+		return nil
+	}
+	v := mcp.ParseBoolean(request, key, false)
+	return &v
+}
+
+// withFormat declares the optional output format parameter of tabular reports.
+func withFormat() mcp.ToolOption {
+	return mcp.WithString("format",
+		mcp.Description("Output format: text (default), csv, or markdown (aligned table for chat clients)"),
+		mcp.Enum(gnucash.FormatText, gnucash.FormatCSV, gnucash.FormatMarkdown),
+	)
+}
+
+// withSort declares the sort_by and order parameters of transaction listings;
+// extra lists sort keys supported beyond date, amount and description.
+func withSort(extra ...string) mcp.ToolOption {
+	keys := append([]string{gnucash.SortByDate, gnucash.SortByAmount, gnucash.SortByDescription}, extra...)
+	return func(t *mcp.Tool) {
+		mcp.WithString("sort_by",
+			mcp.Description("Sort transactions by "+strings.Join(keys, ", ")+" (default: date)"),
+			mcp.Enum(keys...),
+		)(t)
+		mcp.WithString("order",
+			mcp.Description("Sort direction: asc or desc (default: desc for date and amount, asc otherwise)"),
+			mcp.Enum("asc", "desc"),
+		)(t)
+	}
+}
+
+// withCursor declares the optional pagination cursor of transaction listings.
+func withCursor() mcp.ToolOption {
+	return mcp.WithString("cursor",
+		mcp.Description("Pagination cursor returned by a previous call to fetch the next page"),
+	)
+}
+
+func registerSuggestCategory(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("suggest_category",
+		mcp.WithDescription("Suggest the income or expense accounts a transaction belongs to from its payee or description, based on the words of past transactions (Bayesian matching, as in GnuCash's importer). Use it to categorize statement lines before recording or importing them."),
+		readOnlyHints(),
+		mcp.WithString("description",
+			mcp.Required(),
+			mcp.Description("Payee or description of the transaction, e.g. 'CARREFOUR CITY 0412 PARIS'"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of suggestions (default: 3)"),
+		),
+	)
+	addBookTool(s, books, tool, func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		description, err := request.RequireString("description")
+		if err != nil {
+			return mcp.NewToolResultError("description is required"), nil
+		}
+		limit := mcp.ParseInt(request, "limit", 0)
+		result, err := svc.SuggestCategory(ctx, description, limit)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
@@ -0,0 +1,309 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michelgermain/gnucash-mcp/internal/gnucash"
+)
+
+// Write-mode tools modify the book. They are only registered when the
+// server runs with GNUCASH_WRITE=1 (see RegisterWriteTools).
+
+// writeAccountDescription documents how the accounts a change is made to are
+// resolved: exactly, unlike accountNameDescription, so that a typo cannot
+// book to another account.
+const writeAccountDescription = "Exact account name, if no other account has it, full colon path (e.g. \"Expenses:Groceries\") " +
+	"or account GUID; partial or misspelled names are refused with suggestions"
+
+// writeTool wraps the handler of a write-mode tool: it passes the tool call
+// on to the service for the audit log and honours the dry_run parameter
+// declared by withDryRun.
+func writeTool(handler bookHandler) bookHandler {
+	return func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		svc = svc.With(gnucash.WithToolCall(request.Params.Name, request.GetArguments()))
+		if mcp.ParseBoolean(request, "dry_run", false) {
+			svc = svc.With(gnucash.WithDryRun())
+		}
+		return handler(ctx, request, svc)
+	}
+}
+
+// withDryRun declares the dry_run parameter of write-mode tools.
+func withDryRun() mcp.ToolOption {
+	return mcp.WithBoolean("dry_run",
+		mcp.Description("Validate the change and show the resulting entry without writing anything. Use it to let the user confirm before writing"),
+	)
+}
+
+func registerAddTransaction(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("add_transaction",
+		mcp.WithDescription("Record a transaction moving an amount from one account to another, e.g. an expense paid from a bank account (from_account: the bank, to_account: the expense). Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, false, false),
+		mcp.WithString("date",
+			mcp.Required(),
+			mcp.Description("Date of the transaction (YYYY-MM-DD)"),
+		),
+		mcp.WithString("description",
+			mcp.Required(),
+			mcp.Description("Transaction description, e.g. the payee"),
+		),
+		mcp.WithString("from_account",
+			mcp.Required(),
+			mcp.Description("Account the money comes from (credited). "+writeAccountDescription),
+		),
+		mcp.WithString("to_account",
+			mcp.Required(),
+			mcp.Description("Account the money goes to (debited). "+writeAccountDescription),
+		),
+		mcp.WithNumber("amount",
+			mcp.Required(),
+			mcp.Description("Positive amount in the transaction currency"),
+		),
+		mcp.WithString("memo",
+			mcp.Description("Optional memo for both splits"),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		date, err := request.RequireString("date")
+		if err != nil {
+			return mcp.NewToolResultError("date is required"), nil
+		}
+		description, err := request.RequireString("description")
+		if err != nil {
+			return mcp.NewToolResultError("description is required"), nil
+		}
+		from, err := request.RequireString("from_account")
+		if err != nil {
+			return mcp.NewToolResultError("from_account is required"), nil
+		}
+		to, err := request.RequireString("to_account")
+		if err != nil {
+			return mcp.NewToolResultError("to_account is required"), nil
+		}
+		amount, err := request.RequireFloat("amount")
+		if err != nil {
+			return mcp.NewToolResultError("amount is required"), nil
+		}
+		memo := mcp.ParseString(request, "memo", "")
+		result, err := svc.AddTransaction(ctx, date, description, from, to, amount, memo)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerAddSplitTransaction(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("add_split_transaction",
+		mcp.WithDescription("Record a transaction with any number of splits, e.g. a salary with tax withholdings or a receipt spanning several expense categories. Amounts are positive for debits (money into an account, expenses) and negative for credits; they must sum to zero. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, false, false),
+		mcp.WithString("date",
+			mcp.Required(),
+			mcp.Description("Date of the transaction (YYYY-MM-DD)"),
+		),
+		mcp.WithString("description",
+			mcp.Required(),
+			mcp.Description("Transaction description, e.g. the payee"),
+		),
+		mcp.WithArray("splits",
+			mcp.Required(),
+			mcp.MinItems(2),
+			mcp.Description("The splits of the transaction"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"account": map[string]any{"type": "string", "description": writeAccountDescription},
+					"amount":  map[string]any{"type": "number", "description": "Positive for debits, negative for credits"},
+					"memo":    map[string]any{"type": "string", "description": "Optional split memo"},
+				},
+				"required": []string{"account", "amount"},
+			}),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		var args struct {
+			Date        string               `json:"date"`
+			Description string               `json:"description"`
+			Splits      []gnucash.SplitInput `json:"splits"`
+		}
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError("invalid arguments: " + err.Error()), nil
+		}
+		if args.Date == "" {
+			return mcp.NewToolResultError("date is required"), nil
+		}
+		result, err := svc.AddSplitTransaction(ctx, args.Date, args.Description, args.Splits)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerVoidTransaction(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("void_transaction",
+		mcp.WithDescription("Void a transaction the way GnuCash does (amounts zeroed, original amounts and the reason kept in its history) or delete it with its splits. Nothing changes unless confirm is true; use dry_run to preview the change. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, true, true),
+		mcp.WithString("transaction_guid",
+			mcp.Required(),
+			mcp.Description("GUID of the transaction"),
+		),
+		mcp.WithString("action",
+			mcp.Description("void (default, keeps history) or delete (removes the transaction permanently)"),
+			mcp.Enum(gnucash.VoidActionVoid, gnucash.VoidActionDelete),
+		),
+		mcp.WithString("reason",
+			mcp.Description("Why the transaction is voided; required for void"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Description("Must be true to actually void or delete"),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		guid, err := request.RequireString("transaction_guid")
+		if err != nil {
+			return mcp.NewToolResultError("transaction_guid is required"), nil
+		}
+		action := mcp.ParseString(request, "action", "")
+		reason := mcp.ParseString(request, "reason", "")
+		confirm := mcp.ParseBoolean(request, "confirm", false)
+		result, err := svc.VoidTransaction(ctx, guid, action, reason, confirm)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerCreateAccount(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("create_account",
+		mcp.WithDescription("Add an account to the chart of accounts. GnuCash's rules apply: asset and liability accounts (bank, cash, credit card, stock, ...) nest together, income and expense accounts together, equity and trading accounts apart; sibling names must be unique. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, false, false),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the new account (without its parents, no ':')"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Account type, e.g. BANK, EXPENSE, STOCK, or a synonym such as 'credit card'. Defaults to the parent's type"),
+		),
+		mcp.WithString("parent",
+			mcp.Description("Parent account; omit for a top-level account. "+writeAccountDescription),
+		),
+		mcp.WithString("description",
+			mcp.Description("Optional account description"),
+		),
+		mcp.WithString("commodity",
+			mcp.Description("Currency code or security symbol/ISIN the account is kept in. Defaults to the parent's commodity or the book's currency; required for stock and mutual fund accounts"),
+		),
+		mcp.WithBoolean("placeholder",
+			mcp.Description("Create a placeholder account, which groups sub-accounts but holds no transactions"),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		name, err := request.RequireString("name")
+		if err != nil {
+			return mcp.NewToolResultError("name is required"), nil
+		}
+		result, err := svc.CreateAccount(ctx, gnucash.AccountInput{
+			Name:        name,
+			Type:        mcp.ParseString(request, "type", ""),
+			Parent:      mcp.ParseString(request, "parent", ""),
+			Description: mcp.ParseString(request, "description", ""),
+			Commodity:   mcp.ParseString(request, "commodity", ""),
+			Placeholder: mcp.ParseBoolean(request, "placeholder", false),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerRenameAccount(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("rename_account",
+		mcp.WithDescription("Rename an account, keeping its place in the chart of accounts and its transactions. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, true, true),
+		mcp.WithString("account_name",
+			mcp.Required(),
+			mcp.Description("Account to rename. "+writeAccountDescription),
+		),
+		mcp.WithString("new_name",
+			mcp.Required(),
+			mcp.Description("New name (without its parents, no ':')"),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		account, err := request.RequireString("account_name")
+		if err != nil {
+			return mcp.NewToolResultError("account_name is required"), nil
+		}
+		newName, err := request.RequireString("new_name")
+		if err != nil {
+			return mcp.NewToolResultError("new_name is required"), nil
+		}
+		result, err := svc.RenameAccount(ctx, account, newName)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerReconcileSplits(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("reconcile_splits",
+		mcp.WithDescription("Mark splits as reconciled against a bank or card statement (or only as cleared), once they were matched against the statement and its ending balance checked. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, true, true),
+		mcp.WithArray("split_guids",
+			mcp.Required(),
+			mcp.MinItems(1),
+			mcp.Description("GUIDs of the splits to mark"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("date",
+			mcp.Description("Statement date recorded as the reconcile date (YYYY-MM-DD, default: today)"),
+		),
+		mcp.WithString("state",
+			mcp.Description("reconciled (default) or cleared"),
+			mcp.Enum(gnucash.ReconcileReconciled, gnucash.ReconcileCleared),
+		),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		var args struct {
+			SplitGUIDs []string `json:"split_guids"`
+			Date       string   `json:"date"`
+			State      string   `json:"state"`
+		}
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError("invalid arguments: " + err.Error()), nil
+		}
+		result, err := svc.ReconcileSplits(ctx, args.SplitGUIDs, args.Date, args.State)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}
+
+func registerUndoLastChange(s *server.MCPServer, books *Books) {
+	tool := mcp.NewTool("undo_last_change",
+		mcp.WithDescription("Undo the most recent change made through this server (recorded, voided or deleted transaction, created or renamed account). Call it repeatedly to undo earlier changes, up to the last 20 since the server started. Fails without changing anything if the affected data was modified since. Modifies the book; requires GNUCASH_WRITE=1."),
+		withHints(false, true, false),
+		withDryRun(),
+	)
+	addBookTool(s, books, tool, writeTool(func(ctx context.Context, request mcp.CallToolRequest, svc *gnucash.Service) (*mcp.CallToolResult, error) {
+		result, err := svc.UndoLastChange(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(result), nil
+	}))
+}